@@ -0,0 +1,79 @@
+package genji
+
+import (
+	"context"
+
+	"github.com/genjidb/genji/internal/database"
+)
+
+// A CheckProblem describes an inconsistency found by Check.
+type CheckProblem struct {
+	TableName string
+	// Name of the index, if the problem concerns an index.
+	IndexName string
+	Message   string
+}
+
+func (p CheckProblem) String() string {
+	dp := database.CheckProblem(p)
+	return dp.String()
+}
+
+// CheckReport lists the problems found by Check.
+type CheckReport struct {
+	Problems []CheckProblem
+	// Indexes rebuilt by Repair.
+	Repaired []string
+}
+
+// OK returns true if no problem was found.
+func (r *CheckReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// Check verifies the consistency of the database and reports every problem found:
+// tables and indexes without a store, documents that don't satisfy the constraints of their table,
+// indexes with missing or dangling entries and unique indexes containing duplicates.
+// Check runs in a read-only transaction and doesn't block writers.
+func (db *DB) Check(ctx context.Context) (*CheckReport, error) {
+	return db.check(ctx, false)
+}
+
+// Repair works like Check but also rebuilds the indexes with missing or dangling entries.
+// The problems found before repairing are reported.
+// Tables are locked while being checked, which blocks concurrent writers.
+func (db *DB) Repair(ctx context.Context) (*CheckReport, error) {
+	return db.check(ctx, true)
+}
+
+func (db *DB) check(ctx context.Context, repair bool) (*CheckReport, error) {
+	tx, err := db.DB.BeginTx(ctx, &database.TxOptions{
+		ReadOnly: !repair,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	r, err := database.Check(tx, db.DB.Catalog, repair)
+	if err != nil {
+		return nil, err
+	}
+
+	if repair {
+		err = tx.Commit()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	report := CheckReport{
+		Problems: make([]CheckProblem, len(r.Problems)),
+		Repaired: r.Repaired,
+	}
+	for i, p := range r.Problems {
+		report.Problems[i] = CheckProblem(p)
+	}
+
+	return &report, nil
+}
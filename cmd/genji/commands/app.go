@@ -20,15 +20,27 @@ func NewApp() *cli.App {
 		NewVersionCommand(),
 		NewDumpCommand(),
 		NewRestoreCommand(),
+		NewBackupCommand(),
+		NewCheckCommand(),
+		NewCompactCommand(),
+		NewReplicateCommand(),
 		NewBenchCommand(),
 	}
 
+	app.Flags = append(dbOptionsFlags(), changeLogFlag())
+
 	// Root command
 	app.Action = func(c *cli.Context) error {
 		dbpath := c.Args().First()
 
+		dbOpts, err := dbOptionsFromContext(c)
+		if err != nil {
+			return err
+		}
+		dbOpts.ChangeLog = c.Bool("change-log")
+
 		if dbutil.CanReadFromStandardInput() {
-			db, err := dbutil.OpenDB(c.Context, dbpath)
+			db, err := dbutil.OpenDB(c.Context, dbpath, dbOpts)
 			if err != nil {
 				return err
 			}
@@ -38,7 +50,8 @@ func NewApp() *cli.App {
 		}
 
 		return shell.Run(c.Context, &shell.Options{
-			DBPath: dbpath,
+			DBPath:    dbpath,
+			DBOptions: dbOpts,
 		})
 	}
 
@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"github.com/cockroachdb/errors"
+	"github.com/genjidb/genji/cmd/genji/dbutil"
+	"github.com/urfave/cli/v2"
+)
+
+// NewBackupCommand returns a cli.Command for "genji backup".
+func NewBackupCommand() *cli.Command {
+	cmd := cli.Command{
+		Name:      "backup",
+		Usage:     "Create a copy of a database that can be opened directly",
+		UsageText: `genji backup [options] dbpath backupdir`,
+		Description: `The backup command creates a consistent copy of a database in the given directory,
+which must not exist. Unlike dump, the backup can be opened directly, without having to be restored:
+
+$ genji backup mydb mydb-backup
+$ genji mydb-backup
+
+The database can be used by other processes while the backup is in progress.`,
+		Flags: dbOptionsFlags(),
+	}
+
+	cmd.Action = func(c *cli.Context) error {
+		if c.Args().Len() != 2 {
+			return errors.New(cmd.UsageText)
+		}
+
+		dbPath := c.Args().Get(0)
+		backupDir := c.Args().Get(1)
+
+		dbOpts, err := dbOptionsFromContext(c)
+		if err != nil {
+			return err
+		}
+
+		db, err := dbutil.OpenDB(c.Context, dbPath, dbOpts)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		return db.Backup(c.Context, backupDir)
+	}
+
+	return &cmd
+}
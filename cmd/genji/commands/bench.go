@@ -43,7 +43,7 @@ $ genji bench -p "CREATE TABLE foo; INSERT INTO foo(a) VALUES (1), (2), (3)" "SE
 
 By default, each query is run in a separate transaction. To run everything, including the setup,
 in the same transaction, use -t`,
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			&cli.StringFlag{
 				Name:    "path",
 				Aliases: []string{"p"},
@@ -79,7 +79,7 @@ in the same transaction, use -t`,
 				Name:  "csv",
 				Usage: "Output the results in csv",
 			},
-		},
+		}, dbOptionsFlags()...),
 	}
 
 	cmd.Action = func(c *cli.Context) error {
@@ -90,7 +90,12 @@ in the same transaction, use -t`,
 
 		path := c.String("path")
 
-		db, err := dbutil.OpenDB(c.Context, path)
+		dbOpts, err := dbOptionsFromContext(c)
+		if err != nil {
+			return err
+		}
+
+		db, err := dbutil.OpenDB(c.Context, path, dbOpts)
 		if err != nil {
 			return err
 		}
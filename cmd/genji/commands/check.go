@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"os"
+
+	"github.com/cockroachdb/errors"
+	"github.com/genjidb/genji/cmd/genji/dbutil"
+	"github.com/urfave/cli/v2"
+)
+
+// NewCheckCommand returns a cli.Command for "genji check".
+func NewCheckCommand() *cli.Command {
+	cmd := cli.Command{
+		Name:      "check",
+		Usage:     "Verify the integrity of a database",
+		UsageText: `genji check [options] dbpath`,
+		Description: `The check command verifies that every table and index has a store, that every document
+satisfies the constraints of its table, that every index contains exactly one entry per document
+and that unique indexes don't contain duplicates. Problems are reported per table and index:
+
+$ genji check mydb
+index foo_b_idx on table foo: 2 documents have no entry in the index
+
+With the --repair option, indexes with missing or dangling entries are rebuilt from their table.
+Other problems must be fixed manually. The command fails if problems remain.`,
+		Flags: append([]cli.Flag{
+			&cli.BoolFlag{
+				Name:  "repair",
+				Usage: "rebuild broken indexes.",
+			},
+		}, dbOptionsFlags()...),
+	}
+
+	cmd.Action = func(c *cli.Context) error {
+		if c.Args().Len() != 1 {
+			return errors.New(cmd.UsageText)
+		}
+
+		dbOpts, err := dbOptionsFromContext(c)
+		if err != nil {
+			return err
+		}
+
+		db, err := dbutil.OpenDB(c.Context, c.Args().First(), dbOpts)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		return dbutil.Check(c.Context, db, os.Stdout, c.Bool("repair"))
+	}
+
+	return &cmd
+}
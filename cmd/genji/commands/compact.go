@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/cockroachdb/errors"
+	"github.com/genjidb/genji/cmd/genji/dbutil"
+	"github.com/urfave/cli/v2"
+)
+
+// NewCompactCommand returns a cli.Command for "genji compact".
+func NewCompactCommand() *cli.Command {
+	cmd := cli.Command{
+		Name:      "compact",
+		Usage:     "Reclaim the disk space used by deleted data",
+		UsageText: `genji compact [options] dbpath`,
+		Description: `The compact command reclaims the disk space used by deleted data and prints the number of bytes reclaimed.
+Dropped and truncated tables are deleted instantly, but their disk space is only reclaimed
+once the database compacts them in the background. The compact command does it immediately:
+
+$ genji compact mydb
+reclaimed 524288000 bytes
+
+It has the same effect as running the VACUUM statement.`,
+		Flags: dbOptionsFlags(),
+	}
+
+	cmd.Action = func(c *cli.Context) error {
+		if c.Args().Len() != 1 {
+			return errors.New(cmd.UsageText)
+		}
+
+		dbOpts, err := dbOptionsFromContext(c)
+		if err != nil {
+			return err
+		}
+
+		db, err := dbutil.OpenDB(c.Context, c.Args().First(), dbOpts)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		reclaimed, err := db.Compact(c.Context)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("reclaimed %d bytes\n", reclaimed)
+		return nil
+	}
+
+	return &cmd
+}
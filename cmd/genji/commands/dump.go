@@ -30,7 +30,7 @@ $ genji dump -t foo -f bar my.db
 The dump command can also write directly into a file:
 
 $ genji dump -f dump.sql my.db`,
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			&cli.StringFlag{
 				Name:    "file",
 				Aliases: []string{"f"},
@@ -41,7 +41,7 @@ $ genji dump -f dump.sql my.db`,
 				Aliases: []string{"t"},
 				Usage:   "name of the table, it must already exist. Defaults to all tables.",
 			},
-		},
+		}, dbOptionsFlags()...),
 	}
 
 	cmd.Action = func(c *cli.Context) error {
@@ -52,7 +52,12 @@ $ genji dump -f dump.sql my.db`,
 			return errors.New(cmd.UsageText)
 		}
 
-		db, err := dbutil.OpenDB(c.Context, dbPath)
+		dbOpts, err := dbOptionsFromContext(c)
+		if err != nil {
+			return err
+		}
+
+		db, err := dbutil.OpenDB(c.Context, dbPath, dbOpts)
 		if err != nil {
 			return err
 		}
@@ -41,8 +41,14 @@ Insert can also insert a stream of objects or an array of objects from standard
 
 $ echo '{"a": 1} {"a": 2}' | genji insert --db mydb -t foo
 $ echo '[{"a": 1},{"a": 2}]' | genji insert --db mydb -t foo
-$ curl https://api.github.com/repos/genjidb/genji/issues | genji insert --db mydb -t foo`,
-		Flags: []cli.Flag{
+$ curl https://api.github.com/repos/genjidb/genji/issues | genji insert --db mydb -t foo
+
+Large amounts of documents can be inserted faster with the --bulk flag,
+which writes them directly to the storage engine. Documents of each argument
+are either all inserted or not at all, and the table is locked during the operation.
+
+$ genji insert --db mydb -t foo --bulk < data.json`,
+		Flags: append([]cli.Flag{
 			&cli.StringFlag{
 				Name:     "db",
 				Usage:    "path of the database",
@@ -61,17 +67,27 @@ $ curl https://api.github.com/repos/genjidb/genji/issues | genji insert --db myd
 				Required: false,
 				Value:    false,
 			},
-		},
+			&cli.BoolFlag{
+				Name:     "bulk",
+				Usage:    "bulk load the documents, which is faster for large amounts of data",
+				Required: false,
+				Value:    false,
+			},
+		}, dbOptionsFlags()...),
 		Action: func(c *cli.Context) error {
 			dbPath := c.String("db")
 			table := c.String("table")
 			args := c.Args().Slice()
-			return runInsertCommand(c.Context, dbPath, table, c.Bool("auto"), args)
+			dbOpts, err := dbOptionsFromContext(c)
+			if err != nil {
+				return err
+			}
+			return runInsertCommand(c.Context, dbPath, dbOpts, table, c.Bool("auto"), c.Bool("bulk"), args)
 		},
 	}
 }
 
-func runInsertCommand(ctx context.Context, dbPath, table string, auto bool, args []string) error {
+func runInsertCommand(ctx context.Context, dbPath string, dbOpts *genji.Options, table string, auto, bulk bool, args []string) error {
 	generatedName := "data_" + strconv.FormatInt(time.Now().Unix(), 10)
 	createTable := false
 	if table == "" && auto {
@@ -83,13 +99,13 @@ func runInsertCommand(ctx context.Context, dbPath, table string, auto bool, args
 		dbPath = generatedName
 	}
 
-	db, err := dbutil.OpenDB(ctx, dbPath)
+	db, err := dbutil.OpenDB(ctx, dbPath, dbOpts)
 	if err != nil {
 		return err
 	}
 	defer db.Close()
 
-	err = insert(db, table, createTable, args...)
+	err = insert(db, table, createTable, bulk, args...)
 	if err != nil {
 		if createTable {
 			_ = os.RemoveAll(dbPath)
@@ -101,7 +117,7 @@ func runInsertCommand(ctx context.Context, dbPath, table string, auto bool, args
 	return nil
 }
 
-func insert(db *genji.DB, table string, createTable bool, args ...string) error {
+func insert(db *genji.DB, table string, createTable, bulk bool, args ...string) error {
 	if createTable {
 		err := db.Exec("CREATE TABLE " + table)
 		if err != nil {
@@ -109,8 +125,13 @@ func insert(db *genji.DB, table string, createTable bool, args ...string) error
 		}
 	}
 
+	insertJSON := dbutil.InsertJSON
+	if bulk {
+		insertJSON = dbutil.BulkInsertJSON
+	}
+
 	if dbutil.CanReadFromStandardInput() {
-		return dbutil.InsertJSON(db, table, os.Stdin)
+		return insertJSON(db, table, os.Stdin)
 	}
 
 	if len(args) == 0 {
@@ -118,7 +139,7 @@ func insert(db *genji.DB, table string, createTable bool, args ...string) error
 	}
 
 	for _, arg := range args {
-		if err := dbutil.InsertJSON(db, table, strings.NewReader(arg)); err != nil {
+		if err := insertJSON(db, table, strings.NewReader(arg)); err != nil {
 			return err
 		}
 	}
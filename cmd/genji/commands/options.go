@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/genjidb/genji"
+	"github.com/urfave/cli/v2"
+)
+
+// dbOptionsFlags returns the flags used to configure how a database is opened.
+func dbOptionsFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.Int64Flag{
+			Name:  "cache-size",
+			Usage: "size of the block cache, in bytes. Defaults to 8MB.",
+		},
+		&cli.IntFlag{
+			Name:  "memtable-size",
+			Usage: "size of a memtable, in bytes. Defaults to 4MB.",
+		},
+		&cli.StringFlag{
+			Name:  "compression",
+			Usage: `compression algorithm used for data stored on disk: "snappy", "zstd" or "none". Defaults to "snappy".`,
+		},
+		&cli.BoolFlag{
+			Name:  "no-sync",
+			Usage: "don't wait for data to be synced to disk when committing. Faster, but recent transactions might be lost on crash.",
+		},
+		&cli.BoolFlag{
+			Name:  "read-only",
+			Usage: "open an existing database in read-only mode.",
+		},
+		&cli.StringFlag{
+			Name:  "temp-dir",
+			Usage: "directory where temporary data is stored. Defaults to the system temporary directory.",
+		},
+		&cli.IntFlag{
+			Name:  "max-tx-size",
+			Usage: "maximum size of the writes of a transaction held in memory, in bytes. Unlimited by default.",
+		},
+		&cli.BoolFlag{
+			Name:  "spill-large-tx",
+			Usage: "write transactions larger than --max-tx-size to the temporary directory instead of failing.",
+		},
+	}
+}
+
+// changeLogFlag returns the flag enabling the change log. Once enabled, the change log
+// is kept by every subsequent write, so it is only registered on the commands
+// meant to modify the database, not on the ones doing it as a side effect.
+func changeLogFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:  "change-log",
+		Usage: "record committed changes in a change log, which is used to replicate the database.",
+	}
+}
+
+// dbOptionsFromContext builds the database options from the flags returned by dbOptionsFlags.
+func dbOptionsFromContext(c *cli.Context) (*genji.Options, error) {
+	opts := genji.Options{
+		CacheSize:              c.Int64("cache-size"),
+		MemTableSize:           c.Int("memtable-size"),
+		NoSync:                 c.Bool("no-sync"),
+		ReadOnly:               c.Bool("read-only"),
+		TempDir:                c.String("temp-dir"),
+		MaxTransactionSize:     c.Int("max-tx-size"),
+		SpillLargeTransactions: c.Bool("spill-large-tx"),
+	}
+
+	switch strings.ToLower(c.String("compression")) {
+	case "":
+		opts.Compression = genji.DefaultCompression
+	case "none":
+		opts.Compression = genji.NoCompression
+	case "snappy":
+		opts.Compression = genji.SnappyCompression
+	case "zstd":
+		opts.Compression = genji.ZstdCompression
+	default:
+		return nil, errors.Errorf("unknown compression %q", c.String("compression"))
+	}
+
+	return &opts, nil
+}
@@ -0,0 +1,162 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cockroachdb/errors"
+	"github.com/genjidb/genji"
+	"github.com/genjidb/genji/cmd/genji/dbutil"
+	"github.com/urfave/cli/v2"
+)
+
+// NewReplicateCommand returns a cli.Command for "genji replicate".
+func NewReplicateCommand() *cli.Command {
+	cmd := cli.Command{
+		Name:      "replicate",
+		Usage:     "Replicate the changes made to a database into another database",
+		UsageText: `genji replicate [options] leader follower`,
+		Description: `The replicate command applies to a follower database the changes committed by a leader database
+since the last replication. The leader must record its changes in a change log, which is enabled
+by opening it with the --change-log option at least once:
+
+$ genji --change-log leader.db
+
+The follower must either be a new database, if the change log of the leader was enabled when it was created,
+or a backup of the leader taken after the change log was enabled:
+
+$ genji backup leader.db follower.db
+$ genji replicate leader.db follower.db
+
+Changes can also be exported to a file, or to the standard output using "-",
+and applied on another machine. The position of the follower is printed by the
+--position option and is passed to the export with --from:
+
+$ genji replicate --position follower.db
+42
+$ genji replicate --from 42 -o changes.bin leader.db
+$ genji replicate -i changes.bin follower.db
+
+The follower must not be modified by other means. It can be opened in read-only mode between replications.`,
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:    "output",
+				Aliases: []string{"o"},
+				Usage:   `export the changes of the leader to the given file, "-" for STDOUT.`,
+			},
+			&cli.StringFlag{
+				Name:    "input",
+				Aliases: []string{"i"},
+				Usage:   `apply the changes read from the given file to the follower, "-" for STDIN.`,
+			},
+			&cli.Uint64Flag{
+				Name:  "from",
+				Usage: "position after which changes are exported.",
+			},
+			&cli.BoolFlag{
+				Name:  "position",
+				Usage: "print the position of the last change applied to the follower.",
+			},
+		}, dbOptionsFlags()...),
+	}
+
+	cmd.Action = func(c *cli.Context) error {
+		dbOpts, err := dbOptionsFromContext(c)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case c.Bool("position"):
+			if c.Args().Len() != 1 {
+				return errors.New("genji replicate --position follower")
+			}
+
+			db, err := dbutil.OpenDB(c.Context, c.Args().First(), dbOpts)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			pos, err := db.ReplicationPosition()
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(pos)
+			return nil
+		case c.String("output") != "":
+			if c.Args().Len() != 1 {
+				return errors.New("genji replicate --output file leader")
+			}
+
+			// the leader is only read
+			dbOpts.ReadOnly = true
+			db, err := dbutil.OpenDB(c.Context, c.Args().First(), dbOpts)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			var w io.Writer = os.Stdout
+			if f := c.String("output"); f != "-" {
+				file, err := os.Create(f)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+
+				w = file
+			}
+
+			_, err = db.ExportChangeLog(c.Context, w, c.Uint64("from"))
+			return err
+		case c.String("input") != "":
+			if c.Args().Len() != 1 {
+				return errors.New("genji replicate --input file follower")
+			}
+
+			db, err := dbutil.OpenDB(c.Context, c.Args().First(), dbOpts)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			var r io.Reader = os.Stdin
+			if f := c.String("input"); f != "-" {
+				file, err := os.Open(f)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+
+				r = file
+			}
+
+			_, err = db.ApplyChangeLog(c.Context, r)
+			return err
+		}
+
+		if c.Args().Len() != 2 {
+			return errors.New(cmd.UsageText)
+		}
+
+		leader, err := dbutil.OpenDB(c.Context, c.Args().Get(0), &genji.Options{ReadOnly: true})
+		if err != nil {
+			return err
+		}
+		defer leader.Close()
+
+		follower, err := dbutil.OpenDB(c.Context, c.Args().Get(1), dbOpts)
+		if err != nil {
+			return err
+		}
+		defer follower.Close()
+
+		_, err = dbutil.Replicate(c.Context, leader, follower)
+		return err
+	}
+
+	return &cmd
+}
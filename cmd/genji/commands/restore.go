@@ -13,11 +13,22 @@ func NewRestoreCommand() (cmd *cli.Command) {
 	return &cli.Command{
 		Name:      "restore",
 		Usage:     "Restore a database from a file created by genji dump",
-		UsageText: `genji restore dumpFile dbPath`,
+		UsageText: `genji restore [options] dumpFile dbPath`,
 		Description: `The restore command can restore a database from a text file.
 
-	$ genji restore dump.sql mydb`,
-		Flags: []cli.Flag{},
+	$ genji restore dump.sql mydb
+
+With the --bulk flag, the documents of each table are written directly to the storage engine,
+which is much faster for large databases. Each statement runs in its own transaction:
+if the restore fails, the database might only be partially restored.
+
+	$ genji restore --bulk dump.sql mydb`,
+		Flags: append([]cli.Flag{
+			&cli.BoolFlag{
+				Name:  "bulk",
+				Usage: "bulk load the documents of each table",
+			},
+		}, dbOptionsFlags()...),
 		Action: func(c *cli.Context) error {
 			if c.Args().Len() != 2 {
 				return errors.New(cmd.UsageText)
@@ -38,12 +49,21 @@ func NewRestoreCommand() (cmd *cli.Command) {
 			}
 			defer file.Close()
 
-			db, err := dbutil.OpenDB(c.Context, dbPath)
+			dbOpts, err := dbOptionsFromContext(c)
+			if err != nil {
+				return err
+			}
+
+			db, err := dbutil.OpenDB(c.Context, dbPath, dbOpts)
 			if err != nil {
 				return err
 			}
 			defer db.Close()
 
+			if c.Bool("bulk") {
+				return dbutil.BulkExecSQL(c.Context, db, file, os.Stdout)
+			}
+
 			return dbutil.ExecSQL(c.Context, db, file, os.Stdout)
 		},
 	}
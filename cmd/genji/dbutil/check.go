@@ -0,0 +1,57 @@
+package dbutil
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/cockroachdb/errors"
+	"github.com/genjidb/genji"
+)
+
+// ErrCheckFailed is returned by Check when problems were found.
+var ErrCheckFailed = errors.New("integrity check failed")
+
+// Check verifies the consistency of the database and writes every problem found to w.
+// If repair is true, broken indexes are rebuilt.
+// It returns ErrCheckFailed if problems were found, unless they were all repaired.
+func Check(ctx context.Context, db *genji.DB, w io.Writer, repair bool) error {
+	var r *genji.CheckReport
+	var err error
+	if repair {
+		r, err = db.Repair(ctx)
+	} else {
+		r, err = db.Check(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, p := range r.Problems {
+		fmt.Fprintln(w, p)
+	}
+
+	for _, name := range r.Repaired {
+		fmt.Fprintf(w, "rebuilt index %s\n", name)
+	}
+
+	if r.OK() {
+		fmt.Fprintln(w, "ok")
+		return nil
+	}
+
+	// ensure the repair fixed everything
+	if repair {
+		r, err = db.Check(ctx)
+		if err != nil {
+			return err
+		}
+		if r.OK() {
+			return nil
+		}
+
+		fmt.Fprintf(w, "%d problems could not be repaired\n", len(r.Problems))
+	}
+
+	return ErrCheckFailed
+}
@@ -0,0 +1,33 @@
+package dbutil
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/genjidb/genji"
+	"github.com/genjidb/genji/internal/testutil/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	db, err := genji.Open(":memory:")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	err = db.Exec(`
+		CREATE TABLE foo(a INT PRIMARY KEY, b INT UNIQUE);
+		INSERT INTO foo (a, b) VALUES (1, 10), (2, 20);
+	`)
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = Check(context.Background(), db, &buf, false)
+	assert.NoError(t, err)
+	require.Equal(t, "ok\n", buf.String())
+
+	buf.Reset()
+	err = Check(context.Background(), db, &buf, true)
+	assert.NoError(t, err)
+	require.Equal(t, "ok\n", buf.String())
+}
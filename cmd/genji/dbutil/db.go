@@ -7,9 +7,10 @@ import (
 )
 
 // OpenDB opens a database at the given path.
-func OpenDB(ctx context.Context, dbPath string) (*genji.DB, error) {
+// If opts is nil, default options are used.
+func OpenDB(ctx context.Context, dbPath string, opts *genji.Options) (*genji.DB, error) {
 	if dbPath == "" {
 		dbPath = ":memory:"
 	}
-	return genji.Open(dbPath)
+	return genji.OpenWithOptions(dbPath, opts)
 }
@@ -5,8 +5,11 @@ import (
 	"encoding/json"
 	"io"
 
+	"github.com/cockroachdb/errors"
 	"github.com/genjidb/genji"
+	"github.com/genjidb/genji/internal/environment"
 	"github.com/genjidb/genji/internal/query"
+	"github.com/genjidb/genji/internal/query/statement"
 	"github.com/genjidb/genji/internal/sql/parser"
 	"github.com/genjidb/genji/types"
 )
@@ -24,40 +27,136 @@ func ExecSQL(ctx context.Context, db *genji.DB, r io.Reader, w io.Writer) error
 	enc.SetIndent("", "  ")
 
 	for _, p := range q.Statements {
-		qq := query.New(p)
-		qctx := query.Context{
-			Ctx: ctx,
-			DB:  db.DB,
-		}
-		err = qq.Prepare(&qctx)
+		err = execStatement(ctx, db, p, enc)
 		if err != nil {
 			return err
 		}
+	}
 
-		res, err := qq.Run(&qctx)
-		if err != nil {
-			return err
+	return nil
+}
+
+// BulkExecSQL works like ExecSQL but uses DB.BulkLoad to run consecutive INSERT statements
+// adding documents to the same table, like the ones created by Dump, which makes restoring
+// large databases much faster. Since bulk loading can't be done within a transaction,
+// BEGIN and COMMIT statements are ignored and every other statement runs in its own transaction.
+// Only INSERT statements with a list of documents and without RETURNING or ON CONFLICT clauses
+// are loaded, the others are executed normally. Unlike ExecSQL, the documents
+// inserted by the loaded statements are not written to w.
+func BulkExecSQL(ctx context.Context, db *genji.DB, r io.Reader, w io.Writer) error {
+	q, err := parser.NewParser(r).ParseQuery()
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+
+	var batch insertBatch
+	for _, p := range q.Statements {
+		if stmt, ok := p.(*statement.InsertStmt); ok && isBulkInsert(stmt) {
+			if len(batch) > 0 && batch[0].TableName != stmt.TableName {
+				err = db.BulkLoad(batch[0].TableName, batch)
+				if err != nil {
+					return err
+				}
+				batch = batch[:0]
+			}
+
+			batch = append(batch, stmt)
+			continue
 		}
 
-		err = res.Iterate(func(d types.Document) error {
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			default:
+		if len(batch) > 0 {
+			err = db.BulkLoad(batch[0].TableName, batch)
+			if err != nil {
+				return err
 			}
+			batch = batch[:0]
+		}
 
-			return enc.Encode(d)
-		})
-		if err != nil {
-			res.Close()
-			return err
+		switch p.(type) {
+		case query.BeginStmt, query.CommitStmt:
+			continue
+		case query.RollbackStmt:
+			return errors.New("cannot bulk load statements that are rolled back")
 		}
 
-		err = res.Close()
+		err = execStatement(ctx, db, p, enc)
 		if err != nil {
 			return err
 		}
 	}
 
+	if len(batch) > 0 {
+		return db.BulkLoad(batch[0].TableName, batch)
+	}
+
 	return nil
 }
+
+// isBulkInsert returns true if the documents inserted by the statement
+// can be bulk loaded.
+func isBulkInsert(stmt *statement.InsertStmt) bool {
+	return stmt.SelectStmt == nil && len(stmt.Fields) == 0 && len(stmt.Returning) == 0 && stmt.OnConflict == 0
+}
+
+// insertBatch iterates over the documents of a list of INSERT statements.
+type insertBatch []*statement.InsertStmt
+
+func (b insertBatch) Iterate(fn func(d types.Document) error) error {
+	var env environment.Environment
+
+	for _, stmt := range b {
+		for _, e := range stmt.Values {
+			v, err := e.Eval(&env)
+			if err != nil {
+				return err
+			}
+			if v.Type() != types.DocumentValue {
+				return errors.Errorf("expected document, got %s", v.Type())
+			}
+
+			err = fn(v.V().(types.Document))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func execStatement(ctx context.Context, db *genji.DB, stmt statement.Statement, enc *json.Encoder) error {
+	qq := query.New(stmt)
+	qctx := query.Context{
+		Ctx: ctx,
+		DB:  db.DB,
+	}
+	err := qq.Prepare(&qctx)
+	if err != nil {
+		return err
+	}
+
+	res, err := qq.Run(&qctx)
+	if err != nil {
+		return err
+	}
+
+	err = res.Iterate(func(d types.Document) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		return enc.Encode(d)
+	})
+	if err != nil {
+		res.Close()
+		return err
+	}
+
+	return res.Close()
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/genjidb/genji"
 	"github.com/genjidb/genji/document"
+	"github.com/genjidb/genji/internal/testutil"
 	"github.com/genjidb/genji/internal/testutil/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -41,3 +42,58 @@ func TestExecSQL(t *testing.T) {
 	require.Equal(t, 1, res.A)
 	require.Equal(t, 2, res.B)
 }
+
+func TestBulkExecSQL(t *testing.T) {
+	db, err := genji.Open(":memory:")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	err = db.Exec(`
+		CREATE TABLE test(a INT PRIMARY KEY, b INT);
+		CREATE UNIQUE INDEX test_b ON test (b);
+		CREATE TABLE foo;
+		INSERT INTO test (a, b) VALUES (3, 30), (1, 10), (2, 20);
+		INSERT INTO foo (a) VALUES (1), (2);
+	`)
+	assert.NoError(t, err)
+
+	var dump bytes.Buffer
+	err = Dump(context.Background(), db, &dump)
+	assert.NoError(t, err)
+
+	restored, err := genji.Open(":memory:")
+	assert.NoError(t, err)
+	defer restored.Close()
+
+	var got bytes.Buffer
+	err = BulkExecSQL(context.Background(), restored, bytes.NewReader(dump.Bytes()), &got)
+	assert.NoError(t, err)
+	require.Empty(t, got.String())
+
+	var redump bytes.Buffer
+	err = Dump(context.Background(), restored, &redump)
+	assert.NoError(t, err)
+	require.Equal(t, dump.String(), redump.String())
+
+	doc, err := restored.QueryDocument("SELECT a FROM test WHERE b = 20")
+	assert.NoError(t, err)
+	testutil.RequireDocJSONEq(t, doc, `{"a": 2}`)
+
+	// statements that can't be bulk loaded are executed normally
+	got.Reset()
+	err = BulkExecSQL(context.Background(), restored, strings.NewReader(`
+		INSERT INTO test (a, b) VALUES (4, 40);
+		INSERT INTO test VALUES {a: 5, b: 50}, {a: 6, b: 60};
+		INSERT INTO foo VALUES {a: 3};
+		SELECT COUNT(*) AS n FROM test;
+	`), &got)
+	assert.NoError(t, err)
+	require.Equal(t, "{\n  \"a\": 4,\n  \"b\": 40\n}\n{\n  \"n\": 6\n}\n", got.String())
+
+	// constraints are enforced
+	err = BulkExecSQL(context.Background(), restored, strings.NewReader(`
+		INSERT INTO test VALUES {a: 7, b: 70};
+		INSERT INTO test VALUES {a: 8, b: 70};
+	`), &got)
+	assert.Error(t, err)
+}
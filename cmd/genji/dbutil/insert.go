@@ -10,6 +10,7 @@ import (
 
 	"github.com/genjidb/genji"
 	"github.com/genjidb/genji/document"
+	"github.com/genjidb/genji/types"
 )
 
 // InsertJSON reads json documents from r and inserts them into the selected table.
@@ -22,6 +23,37 @@ func InsertJSON(db *genji.DB, table string, r io.Reader) error {
 	defer tx.Rollback()
 
 	q := fmt.Sprintf("INSERT INTO %s VALUES ?", table)
+
+	err = decodeJSON(r, func(fb *document.FieldBuffer) error {
+		return tx.Exec(q, fb)
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// BulkInsertJSON works like InsertJSON but loads the documents
+// using DB.BulkLoad, which is faster for large amounts of documents.
+func BulkInsertJSON(db *genji.DB, table string, r io.Reader) error {
+	return db.BulkLoad(table, jsonIterator{r: r})
+}
+
+// jsonIterator decodes the documents of a reader lazily.
+type jsonIterator struct {
+	r io.Reader
+}
+
+func (it jsonIterator) Iterate(fn func(d types.Document) error) error {
+	return decodeJSON(it.r, func(fb *document.FieldBuffer) error {
+		return fn(fb)
+	})
+}
+
+// decodeJSON reads json documents from r and calls fn for each of them.
+// The reader can be either a stream of json objects or an array of objects.
+func decodeJSON(r io.Reader, fn func(fb *document.FieldBuffer) error) error {
 	rd := bufio.NewReader(r)
 
 	// read first non-white space byte to determine
@@ -48,7 +80,7 @@ func InsertJSON(db *genji.DB, table string, r io.Reader) error {
 				return err
 			}
 
-			if err := tx.Exec(q, &fb); err != nil {
+			if err := fn(&fb); err != nil {
 				return err
 			}
 		}
@@ -71,7 +103,7 @@ func InsertJSON(db *genji.DB, table string, r io.Reader) error {
 				return err
 			}
 
-			if err := tx.Exec(q, &fb); err != nil {
+			if err := fn(&fb); err != nil {
 				return err
 			}
 		}
@@ -89,7 +121,7 @@ func InsertJSON(db *genji.DB, table string, r io.Reader) error {
 		return fmt.Errorf("found %q, but expected '{' or '['", c)
 	}
 
-	return tx.Commit()
+	return nil
 }
 
 func readByteIgnoreWhitespace(r *bufio.Reader) (byte, error) {
@@ -2,6 +2,7 @@ package dbutil
 
 import (
 	"bytes"
+	"io"
 	"strings"
 	"testing"
 
@@ -29,30 +30,40 @@ func TestInsertJSON(t *testing.T) {
 		{"Non closed json stream", `{"foo":"bar"`, ``, true},
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			db, err := genji.Open(":memory:")
-			assert.NoError(t, err)
-			defer db.Close()
-
-			err = db.Exec(`CREATE TABLE foo`)
-			assert.NoError(t, err)
-			err = InsertJSON(db, "foo", strings.NewReader(tt.data))
-			if tt.fails {
-				assert.Error(t, err)
-				return
-			}
-
-			assert.NoError(t, err)
-			res, err := db.Query("SELECT * FROM foo")
-			defer res.Close()
-			assert.NoError(t, err)
+	insertFuncs := []struct {
+		name string
+		fn   func(db *genji.DB, table string, r io.Reader) error
+	}{
+		{"InsertJSON", InsertJSON},
+		{"BulkInsertJSON", BulkInsertJSON},
+	}
 
-			var buf bytes.Buffer
-			err = testutil.IteratorToJSONArray(&buf, res)
-			assert.NoError(t, err)
-			require.JSONEq(t, tt.want, buf.String())
-		})
+	for _, insert := range insertFuncs {
+		for _, tt := range tests {
+			t.Run(insert.name+"/"+tt.name, func(t *testing.T) {
+				db, err := genji.Open(":memory:")
+				assert.NoError(t, err)
+				defer db.Close()
+
+				err = db.Exec(`CREATE TABLE foo`)
+				assert.NoError(t, err)
+				err = insert.fn(db, "foo", strings.NewReader(tt.data))
+				if tt.fails {
+					assert.Error(t, err)
+					return
+				}
+
+				assert.NoError(t, err)
+				res, err := db.Query("SELECT * FROM foo")
+				defer res.Close()
+				assert.NoError(t, err)
+
+				var buf bytes.Buffer
+				err = testutil.IteratorToJSONArray(&buf, res)
+				assert.NoError(t, err)
+				require.JSONEq(t, tt.want, buf.String())
+			})
+		}
 	}
 
 	t.Run(`Json Array`, func(t *testing.T) {
@@ -0,0 +1,34 @@
+package dbutil
+
+import (
+	"context"
+	"io"
+
+	"github.com/genjidb/genji"
+)
+
+// Replicate applies to the follower the changes committed by the leader
+// since the last replication, and returns the position of the last applied change.
+func Replicate(ctx context.Context, leader, follower *genji.DB) (uint64, error) {
+	from, err := follower.ReplicationPosition()
+	if err != nil {
+		return 0, err
+	}
+
+	r, w := io.Pipe()
+	errc := make(chan error, 1)
+	go func() {
+		_, err := leader.ExportChangeLog(ctx, w, from)
+		_ = w.CloseWithError(err)
+		errc <- err
+	}()
+
+	pos, err := follower.ApplyChangeLog(ctx, r)
+	// unblock the leader if the follower stopped reading early
+	_ = r.CloseWithError(io.ErrClosedPipe)
+	if exportErr := <-errc; err == nil {
+		err = exportErr
+	}
+
+	return pos, err
+}
@@ -0,0 +1,44 @@
+package dbutil
+
+import (
+	"context"
+	"testing"
+
+	"github.com/genjidb/genji"
+	"github.com/genjidb/genji/document"
+	"github.com/genjidb/genji/internal/testutil/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplicate(t *testing.T) {
+	leader, err := genji.OpenWithOptions(":memory:", &genji.Options{ChangeLog: true})
+	assert.NoError(t, err)
+	defer leader.Close()
+
+	follower, err := genji.Open(":memory:")
+	assert.NoError(t, err)
+	defer follower.Close()
+
+	err = leader.Exec(`
+		CREATE TABLE foo(a INT PRIMARY KEY, b INT);
+		CREATE INDEX idx_b ON foo(b);
+		INSERT INTO foo (a, b) VALUES (1, 10), (2, 20);
+	`)
+	assert.NoError(t, err)
+
+	pos, err := Replicate(context.Background(), leader, follower)
+	assert.NoError(t, err)
+	require.Equal(t, leader.ChangeLogPosition(), pos)
+
+	assert.NoError(t, leader.Exec("UPDATE foo SET b = 30 WHERE a = 1"))
+
+	pos, err = Replicate(context.Background(), leader, follower)
+	assert.NoError(t, err)
+	require.Equal(t, leader.ChangeLogPosition(), pos)
+
+	d, err := follower.QueryDocument("SELECT a FROM foo WHERE b = 30")
+	assert.NoError(t, err)
+	var a int
+	assert.NoError(t, document.Scan(d, &a))
+	require.Equal(t, 1, a)
+}
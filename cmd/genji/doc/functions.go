@@ -15,6 +15,22 @@ var builtinDocs = functionDocs{
 	"sum":    "The sum function returns the sum of all values taken by the arg1 expression in a group.",
 	"avg":    "The avg function returns the average of all values taken by the arg1 expression in a group.",
 	"typeof": "The typeof function returns the type of arg1.",
+
+	"row_number":  "Returns the number of the current row within its partition, starting at 1. Must be used with an OVER clause.",
+	"rank":        "Returns the rank of the current row within its partition, with gaps. Rows with equal ORDER BY values have the same rank. Must be used with an OVER clause.",
+	"dense_rank":  "Returns the rank of the current row within its partition, without gaps. Must be used with an OVER clause.",
+	"lag":         "Returns the value of arg1 evaluated at the row that is arg2 rows before the current row within its partition, or arg3 if there is no such row. arg2 defaults to 1 and arg3 to NULL. Must be used with an OVER clause.",
+	"lead":        "Returns the value of arg1 evaluated at the row that is arg2 rows after the current row within its partition, or arg3 if there is no such row. arg2 defaults to 1 and arg3 to NULL. Must be used with an OVER clause.",
+	"first_value": "Returns the value of arg1 evaluated at the first row of the partition. Must be used with an OVER clause.",
+
+	"coalesce": "Returns the first of its arguments arg1, arg2, ... that is not NULL, or NULL if all of them are NULL.",
+	"ifnull":   "Returns arg1 if it is not NULL, and arg2 otherwise.",
+	"nullif":   "Returns NULL if arg1 is equal to arg2, and arg1 otherwise.",
+	"greatest": "Returns the largest of its arguments arg1, arg2, ... that are not NULL, or NULL if all of them are NULL.",
+	"least":    "Returns the smallest of its arguments arg1, arg2, ... that are not NULL, or NULL if all of them are NULL.",
+
+	"regexp_replace": "Returns arg1 with every match of the regular expression arg2 replaced by arg3. arg3 can refer to capture groups with $1, $2, etc.",
+	"regexp_extract": "Returns the first match of the regular expression arg2 in arg1, or the text matched by its first capture group if it has any. Returns NULL if there is no match.",
 }
 
 var mathDocs = functionDocs{
@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/cockroachdb/errors"
 
@@ -73,6 +75,12 @@ var commands = []command{
 		DisplayName: ".save",
 		Description: "Save database content in the specified file.",
 	},
+	{
+		Name:        ".backup",
+		Options:     "directory",
+		DisplayName: ".backup",
+		Description: "Create a copy of the database in the specified directory, which can be opened directly.",
+	},
 	{
 		Name:        ".schema",
 		Options:     "[table_name]",
@@ -81,9 +89,9 @@ var commands = []command{
 	},
 	{
 		Name:        ".import",
-		Options:     "TYPE FILE table",
+		Options:     "[--bulk] TYPE FILE table",
 		DisplayName: ".import",
-		Description: "Import data from a file. Only supported type is 'csv'",
+		Description: "Import data from a file. Only supported type is 'csv'. With --bulk, documents are bulk loaded.",
 	},
 	{
 		Name:        ".timer",
@@ -91,6 +99,17 @@ var commands = []command{
 		DisplayName: ".timer",
 		Description: "Display the execution time after each query or hide it.",
 	},
+	{
+		Name:        ".stats",
+		DisplayName: ".stats",
+		Description: "Display the metrics of the storage engine and the counters of the database.",
+	},
+	{
+		Name:        ".du",
+		Options:     "[table_name]",
+		DisplayName: ".du",
+		Description: "Display the estimated disk usage of every table and index, or of the given table.",
+	},
 }
 
 func getUsage(cmdName string) string {
@@ -177,7 +196,7 @@ func runIndexesCmd(db *genji.DB, tableName string, w io.Writer) error {
 // If a path already exists, existing values in the target database will be overwritten.
 func runSaveCmd(ctx context.Context, db *genji.DB, dbPath string) error {
 	// Open the new database
-	otherDB, err := dbutil.OpenDB(ctx, dbPath)
+	otherDB, err := dbutil.OpenDB(ctx, dbPath, nil)
 	if err != nil {
 		return err
 	}
@@ -194,7 +213,7 @@ func runSaveCmd(ctx context.Context, db *genji.DB, dbPath string) error {
 	return otherDB.Exec(dbDump.String())
 }
 
-func runImportCmd(ctx context.Context, db *genji.DB, fileType, path, table string) error {
+func runImportCmd(ctx context.Context, db *genji.DB, fileType, path, table string, bulk bool) error {
 	if strings.ToLower(fileType) != "csv" {
 		return errors.New("TYPE should be csv")
 	}
@@ -205,37 +224,183 @@ func runImportCmd(ctx context.Context, db *genji.DB, fileType, path, table strin
 	}
 	defer f.Close()
 
+	r := csv.NewReader(f)
+
+	headers, err := r.Read()
+	if err != nil {
+		return err
+	}
+
+	if bulk {
+		// bulk loading can't be done within a transaction
+		err = db.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s", table))
+		if err != nil {
+			return err
+		}
+
+		return db.BulkLoad(table, csvIterator{r: r, headers: headers})
+	}
+
 	tx, err := db.Begin(true)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	r := csv.NewReader(f)
-
 	err = tx.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s", table))
 	if err != nil {
 		return err
 	}
 
-	headers, err := r.Read()
+	err = csvIterator{r: r, headers: headers}.Iterate(func(d types.Document) error {
+		return tx.Exec("INSERT INTO "+table+" VALUES ?", d)
+	})
 	if err != nil {
 		return err
 	}
 
+	return tx.Commit()
+}
+
+// csvIterator returns a document for each record of a csv file.
+type csvIterator struct {
+	r       *csv.Reader
+	headers []string
+}
+
+func (it csvIterator) Iterate(fn func(d types.Document) error) error {
 	for {
-		columns, err := r.Read()
+		columns, err := it.r.Read()
 		if errors.Is(err, io.EOF) {
-			break
+			return nil
 		}
 		if err != nil {
 			return err
 		}
-		err = tx.Exec("INSERT INTO "+table+" VALUES ?", document.NewFromCSV(headers, columns))
+
+		err = fn(document.NewFromCSV(it.headers, columns))
 		if err != nil {
 			return err
 		}
 	}
+}
 
-	return tx.Commit()
+// runStatsCmd displays the metrics of the database.
+func runStatsCmd(db *genji.DB, w io.Writer) error {
+	s, err := db.Stats()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Transactions\tstarted: %d\tcommitted: %d\trolled back: %d\tread-only: %d\n",
+		s.TransactionsStarted, s.TransactionsCommitted, s.TransactionsRolledBack, s.ReadOnlyTransactions)
+
+	kinds := make([]string, 0, len(s.Statements))
+	for typ := range s.Statements {
+		kinds = append(kinds, typ)
+	}
+	sort.Strings(kinds)
+	for i, typ := range kinds {
+		title := ""
+		if i == 0 {
+			title = "Statements"
+		}
+		fmt.Fprintf(tw, "%s\t%s: %d\n", title, typ, s.Statements[typ])
+	}
+
+	fmt.Fprintf(tw, "Temporary stores\tcreated: %d\tin use: %d\tpooled: %d\n",
+		s.TempStores.Created, s.TempStores.InUse, s.TempStores.Pooled)
+
+	e := s.Engine
+	fmt.Fprintf(tw, "Disk usage\t%s\n", formatSize(e.DiskUsage))
+	fmt.Fprintf(tw, "Memtables\t%s\n", formatSize(e.MemTableSize))
+	fmt.Fprintf(tw, "WAL\t%s\n", formatSize(e.WALSize))
+	fmt.Fprintf(tw, "Block cache\tsize: %s\thit rate: %.1f%%\n", formatSize(uint64(e.CacheSize)), e.CacheHitRate()*100)
+	fmt.Fprintf(tw, "Compactions\tflushes: %d\tcompactions: %d\tdebt: %s\tread amplification: %d\n",
+		e.Flushes, e.Compactions, formatSize(e.CompactionDebt), e.ReadAmplification)
+
+	// align the columns of the tables separately
+	err = tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for i, t := range s.Tables {
+		title := ""
+		if i == 0 {
+			title = "Tables"
+		}
+		fmt.Fprintf(tw, "%s\t%s\tread: %d\twritten: %d\n", title, t.TableName, t.DocumentsRead, t.DocumentsWritten)
+	}
+
+	return tw.Flush()
+}
+
+// runDuCmd displays the estimated disk usage of every table and of their indexes,
+// from the largest table to the smallest. If tableName is not empty, only
+// that table is displayed.
+func runDuCmd(db *genji.DB, tableName string, w io.Writer) error {
+	s, err := db.Stats()
+	if err != nil {
+		return err
+	}
+
+	tables := s.Tables
+	if tableName != "" {
+		tables = nil
+		for _, t := range s.Tables {
+			if t.TableName == tableName {
+				tables = append(tables, t)
+			}
+		}
+		if len(tables) == 0 {
+			return fmt.Errorf("%w: %q", errs.NotFoundError{Name: tableName}, tableName)
+		}
+	}
+
+	sort.SliceStable(tables, func(i, j int) bool {
+		return tables[i].TotalSize() > tables[j].TotalSize()
+	})
+
+	for _, t := range tables {
+		_, err = fmt.Fprintf(w, "%9s  %s\n", formatSize(t.TotalSize()), t.TableName)
+		if err != nil {
+			return err
+		}
+		if len(t.Indexes) == 0 {
+			continue
+		}
+
+		_, err = fmt.Fprintf(w, "%9s    (documents)\n", formatSize(t.Size))
+		if err != nil {
+			return err
+		}
+		for _, idx := range t.Indexes {
+			_, err = fmt.Fprintf(w, "%9s    %s\n", formatSize(idx.Size), idx.IndexName)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// formatSize returns a human readable size.
+func formatSize(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
 }
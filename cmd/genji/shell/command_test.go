@@ -140,3 +140,75 @@ func TestSaveCommand(t *testing.T) {
 	require.Len(t, indexes, 1)
 	require.Equal(t, "idx_a_b", indexes[0])
 }
+
+func TestStatsCmd(t *testing.T) {
+	db, err := genji.Open(":memory:")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	err = db.Exec(`
+		CREATE TABLE foo;
+		INSERT INTO foo (a) VALUES (1), (2);
+		INSERT INTO foo (a) VALUES (3);
+		SELECT * FROM foo;
+	`)
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = runStatsCmd(db, &buf)
+	assert.NoError(t, err)
+
+	out := buf.String()
+	require.Contains(t, out, "INSERT: 2")
+	require.Contains(t, out, "SELECT: 1")
+	require.Regexp(t, `foo\s+read: 3\s+written: 3`, out)
+}
+
+func TestDuCmd(t *testing.T) {
+	db, err := genji.Open(":memory:")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	err = db.Exec(`
+		CREATE TABLE foo;
+		CREATE INDEX idx_foo_a ON foo (a);
+		CREATE TABLE bar;
+		INSERT INTO foo (a) VALUES (1), (2), (3);
+		INSERT INTO bar (a) VALUES (1);
+	`)
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = runDuCmd(db, "", &buf)
+	assert.NoError(t, err)
+
+	// largest tables first
+	out := buf.String()
+	require.Regexp(t, `(?s)B  foo\n.*B    \(documents\)\n.*B    idx_foo_a\n.*B  bar\n`, out)
+
+	buf.Reset()
+	err = runDuCmd(db, "bar", &buf)
+	assert.NoError(t, err)
+	require.Regexp(t, `^\s*\d+B  bar\n$`, buf.String())
+
+	err = runDuCmd(db, "baz", &buf)
+	assert.Error(t, err)
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		size uint64
+		want string
+	}{
+		{0, "0B"},
+		{1023, "1023B"},
+		{1024, "1.0KiB"},
+		{1536, "1.5KiB"},
+		{5 << 20, "5.0MiB"},
+		{3 << 30, "3.0GiB"},
+	}
+
+	for _, test := range tests {
+		require.Equal(t, test.want, formatSize(test.size))
+	}
+}
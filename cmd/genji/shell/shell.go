@@ -66,6 +66,10 @@ type Options struct {
 	// Path of the database directory that will be created.
 	// If empty, the database will be in-memory.
 	DBPath string
+
+	// Options used to open the database.
+	// If nil, default options are used.
+	DBOptions *genji.Options
 }
 
 // Run a shell.
@@ -78,7 +82,7 @@ func Run(ctx context.Context, opts *Options) error {
 
 	sh.opts = opts
 
-	db, err := dbutil.OpenDB(ctx, sh.opts.DBPath)
+	db, err := dbutil.OpenDB(ctx, sh.opts.DBPath, sh.opts.DBOptions)
 	if err != nil {
 		return err
 	}
@@ -470,19 +474,45 @@ func (sh *Shell) runCommand(ctx context.Context, in string) error {
 		}
 
 		return runSaveCmd(ctx, sh.db, path)
+	case ".backup":
+		if len(cmd) != 2 {
+			return fmt.Errorf(getUsage(".backup"))
+		}
+
+		return sh.db.Backup(ctx, cmd[1])
 	case ".schema":
 		return dbutil.DumpSchema(ctx, sh.db, os.Stdout, cmd[1:]...)
 	case ".import":
+		if len(cmd) == 5 && cmd[1] == "--bulk" {
+			return runImportCmd(ctx, sh.db, cmd[2], cmd[3], cmd[4], true)
+		}
 		if len(cmd) != 4 {
 			return fmt.Errorf(getUsage(".import"))
 		}
 
-		return runImportCmd(ctx, sh.db, cmd[1], cmd[2], cmd[3])
+		return runImportCmd(ctx, sh.db, cmd[1], cmd[2], cmd[3], false)
 	case ".doc":
 		if len(cmd) != 2 {
 			return fmt.Errorf(getUsage(".doc"))
 		}
 		return runDocCmd(cmd[1])
+	case ".stats":
+		if len(cmd) != 1 {
+			return fmt.Errorf(getUsage(".stats"))
+		}
+
+		return runStatsCmd(sh.db, os.Stdout)
+	case ".du":
+		if len(cmd) > 2 {
+			return fmt.Errorf(getUsage(".du"))
+		}
+
+		var tableName string
+		if len(cmd) > 1 {
+			tableName = cmd[1]
+		}
+
+		return runDuCmd(sh.db, tableName, os.Stdout)
 	default:
 		return displaySuggestions(in)
 	}
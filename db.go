@@ -11,13 +11,14 @@ import (
 
 	"github.com/cockroachdb/errors"
 	"github.com/cockroachdb/pebble"
-	"github.com/cockroachdb/pebble/vfs"
 	"github.com/genjidb/genji/document"
 	errs "github.com/genjidb/genji/errors"
 	"github.com/genjidb/genji/internal/database"
 	"github.com/genjidb/genji/internal/database/catalogstore"
 	"github.com/genjidb/genji/internal/environment"
 	"github.com/genjidb/genji/internal/kv"
+	"github.com/genjidb/genji/internal/kv/memkv"
+	"github.com/genjidb/genji/internal/kv/pebblekv"
 	"github.com/genjidb/genji/internal/query"
 	"github.com/genjidb/genji/internal/query/statement"
 	"github.com/genjidb/genji/internal/sql/parser"
@@ -29,16 +30,18 @@ import (
 type DB struct {
 	DB  *database.Database
 	ctx context.Context
-	ng  *kv.Engine
+	ng  kv.Engine
 }
 
-func New(ctx context.Context, ng *kv.Engine) (*DB, error) {
+func New(ctx context.Context, ng kv.Engine) (*DB, error) {
 	db, err := database.New(ctx, ng)
 	if err != nil {
 		return nil, err
 	}
 
-	tx, err := db.Begin(true)
+	writable := !ng.ReadOnly()
+
+	tx, err := db.Begin(writable)
 	if err != nil {
 		return nil, err
 	}
@@ -49,9 +52,11 @@ func New(ctx context.Context, ng *kv.Engine) (*DB, error) {
 		return nil, err
 	}
 
-	err = tx.Commit()
-	if err != nil {
-		return nil, err
+	if writable {
+		err = tx.Commit()
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return &DB{
@@ -62,23 +67,170 @@ func New(ctx context.Context, ng *kv.Engine) (*DB, error) {
 }
 
 // Open creates a Genji database at the given path.
-// If path is equal to ":memory:" it will open an in-memory database,
-// otherwise it will create an on-disk database using the BoltDB engine.
+// If path is equal to ":memory:" it will open an in-memory database
+// using a pure Go in-memory engine, otherwise it will create an on-disk database
+// using the Pebble engine.
 func Open(path string) (*DB, error) {
-	var opts pebble.Options
+	return OpenWithOptions(path, nil)
+}
+
+// Options are used to configure the database when opening it.
+// The zero value uses the default settings.
+type Options struct {
+	// Size of the block cache, in bytes.
+	// If zero, Pebble's default size is used (8MB).
+	CacheSize int64
+
+	// Size of a memtable, in bytes. Writes are buffered in memtables
+	// before being flushed to disk.
+	// If zero, Pebble's default size is used (4MB).
+	MemTableSize int
+
+	// Compression algorithm used for data stored on disk.
+	Compression Compression
+
+	// If true, committing a transaction doesn't wait for the data to be
+	// synced to disk. This makes writes a lot faster but the most recent
+	// transactions might be lost in case of a crash.
+	NoSync bool
+
+	// Open an existing database in read-only mode.
+	// Any attempt to begin a read/write transaction returns an error.
+	ReadOnly bool
+
+	// Directory where temporary data is stored, for example
+	// when sorting large results.
+	// If empty, the default directory for temporary files is used.
+	TempDir string
+
+	// Maximum size, in bytes, of the writes a transaction can hold in memory.
+	// If zero, the size of transactions is not limited.
+	MaxTransactionSize int
+
+	// If true, the writes of transactions exceeding MaxTransactionSize are
+	// written to a temporary store in TempDir and merged into the database on commit.
+	// Otherwise, writing more than MaxTransactionSize returns errors.ErrTransactionTooLarge.
+	// Such transactions can't be rolled back to a savepoint: transactions with
+	// savepoints that weren't released still return errors.ErrTransactionTooLarge,
+	// and creating a savepoint once a transaction was written to TempDir fails.
+	SpillLargeTransactions bool
+
+	// Number of index entries sampled by the ANALYZE statement to compute
+	// the statistics of an index. Larger samples give more accurate statistics.
+	// If zero, 30000 entries are sampled.
+	AnalyzeSampleSize int
+
+	// Record the changes made by committed transactions in a change log,
+	// which can be exported to replicate the database.
+	// Once enabled, the change log remains enabled every time the database is opened.
+	ChangeLog bool
+}
+
+// Compression algorithm used for data stored on disk.
+type Compression int
+
+// Supported compression algorithms.
+const (
+	// DefaultCompression uses Snappy.
+	DefaultCompression Compression = iota
+	NoCompression
+	SnappyCompression
+	ZstdCompression
+)
+
+func (c Compression) pebbleCompression() (pebble.Compression, error) {
+	switch c {
+	case DefaultCompression:
+		return pebble.DefaultCompression, nil
+	case NoCompression:
+		return pebble.NoCompression, nil
+	case SnappyCompression:
+		return pebble.SnappyCompression, nil
+	case ZstdCompression:
+		return pebble.ZstdCompression, nil
+	}
+
+	return 0, errors.Errorf("unknown compression %d", c)
+}
+
+// OpenWithOptions creates a Genji database at the given path, using the given options.
+// If path is equal to ":memory:" it will open an in-memory database.
+// Options specific to Pebble are ignored by in-memory databases.
+// If opts is nil, default options are used.
+func OpenWithOptions(path string, opts *Options) (*DB, error) {
+	if opts == nil {
+		opts = new(Options)
+	}
+
+	var ng kv.Engine
+	var err error
 
 	if path == ":memory:" {
-		opts.FS = vfs.NewMem()
-		path = ""
+		if opts.ReadOnly {
+			return nil, errors.New("cannot open an in-memory database in read-only mode")
+		}
+
+		ng = memkv.NewEngine()
+	} else {
+		ng, err = newPebbleEngine(path, opts)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	ng, err := kv.NewEngine(path, &opts)
+	ctx := context.Background()
+	db, err := New(ctx, ng)
 	if err != nil {
+		_ = ng.Close()
 		return nil, err
 	}
 
-	ctx := context.Background()
-	return New(ctx, ng)
+	if opts.AnalyzeSampleSize > 0 {
+		db.DB.AnalyzeSampleSize = opts.AnalyzeSampleSize
+	}
+
+	if opts.ChangeLog {
+		err = db.DB.EnableChangeLog()
+		if err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+	}
+
+	return db, nil
+}
+
+func newPebbleEngine(path string, opts *Options) (kv.Engine, error) {
+	var popts pebble.Options
+
+	if opts.CacheSize > 0 {
+		cache := pebble.NewCache(opts.CacheSize)
+		// Pebble holds its own reference to the cache
+		defer cache.Unref()
+		popts.Cache = cache
+	}
+
+	popts.MemTableSize = opts.MemTableSize
+	popts.ReadOnly = opts.ReadOnly
+
+	compression, err := opts.Compression.pebbleCompression()
+	if err != nil {
+		return nil, err
+	}
+	if compression != pebble.DefaultCompression {
+		popts.Levels = make([]pebble.LevelOptions, 7)
+		for i := range popts.Levels {
+			popts.Levels[i].Compression = compression
+		}
+	}
+
+	return pebblekv.NewEngine(path, pebblekv.Options{
+		Pebble:    &popts,
+		NoSync:    opts.NoSync,
+		TempDir:   opts.TempDir,
+		MaxTxSize: opts.MaxTransactionSize,
+		SpillTx:   opts.SpillLargeTransactions,
+	})
 }
 
 // WithContext creates a new database handle using the given context for every operation.
@@ -99,6 +251,46 @@ func (db *DB) Close() error {
 	return db.ng.Close()
 }
 
+// Backup creates a consistent copy of the database in the given directory,
+// which must not exist. The copy can be opened with Open.
+// Only committed transactions are part of the backup and the database
+// can be used normally while the backup is in progress.
+// On-disk backups are mostly made of hard links to the database files,
+// which makes them fast and cheap when dir is on the same filesystem.
+func (db *DB) Backup(ctx context.Context, dir string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return db.ng.Checkpoint(dir)
+}
+
+// Compact reclaims the disk space used by the data deleted by committed transactions
+// and returns the number of bytes reclaimed.
+// Dropped and truncated tables are removed using range deletions, which are cheap
+// but only free disk space once the deleted range is compacted. Pebble eventually compacts
+// them in the background, Compact can be used to do it immediately.
+// It has the same effect as the VACUUM statement.
+func (db *DB) Compact(ctx context.Context) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return db.DB.Compact()
+}
+
+// BulkLoad inserts every document returned by the iterator into the given table,
+// which must exist. Documents are validated like regular inserts, but they are sorted
+// and written directly to the storage engine, along with their index entries,
+// which is much faster than using INSERT statements for large amounts of data.
+// Either all the documents are inserted or none of them are.
+// The table is locked during the whole operation, which can't be run
+// within a transaction, and the inserted documents are not published
+// to the change feed.
+func (db *DB) BulkLoad(table string, it document.Iterator) error {
+	return db.DB.BulkLoad(db.ctx, table, it)
+}
+
 // Begin starts a new transaction.
 // The returned transaction must be closed either by calling Rollback or Commit.
 func (db *DB) Begin(writable bool) (*Tx, error) {
@@ -212,6 +404,25 @@ func (tx *Tx) Commit() error {
 	return tx.tx.Commit()
 }
 
+// Savepoint creates a savepoint with the given name.
+// Changes made after the savepoint can be undone using RollbackTo,
+// without rolling back the whole transaction.
+func (tx *Tx) Savepoint(name string) error {
+	return tx.tx.Savepoint(name)
+}
+
+// RollbackTo undoes every change made after the most recent savepoint
+// with the given name. The savepoint remains and can be rolled back to again.
+func (tx *Tx) RollbackTo(name string) error {
+	return tx.tx.RollbackTo(name)
+}
+
+// ReleaseSavepoint destroys the most recent savepoint with the given name
+// and every savepoint created after it, keeping the changes.
+func (tx *Tx) ReleaseSavepoint(name string) error {
+	return tx.tx.ReleaseSavepoint(name)
+}
+
 // Query the database withing the transaction and returns the result.
 // Closing the returned result after usage is not mandatory.
 func (tx *Tx) Query(q string, args ...interface{}) (*Result, error) {
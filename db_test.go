@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/cockroachdb/errors"
@@ -173,6 +174,478 @@ func TestOpen(t *testing.T) {
 	testutil.RequireDocJSONEq(t, d, `{"name": "seqD", "seq": 500}`)
 }
 
+func TestOpenWithOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genji")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.db")
+	tmp := filepath.Join(dir, "tmp")
+	assert.NoError(t, os.Mkdir(tmp, 0700))
+
+	db, err := genji.OpenWithOptions(path, &genji.Options{
+		CacheSize:    1 << 20,
+		MemTableSize: 1 << 20,
+		Compression:  genji.ZstdCompression,
+		NoSync:       true,
+		TempDir:      tmp,
+	})
+	assert.NoError(t, err)
+
+	err = db.Exec(`
+		CREATE TABLE test(a INT);
+		INSERT INTO test (a) VALUES (2), (1);
+	`)
+	assert.NoError(t, err)
+
+	// sorting uses a transient store, created in the temp directory
+	d, err := db.QueryDocument("SELECT a FROM test ORDER BY a")
+	assert.NoError(t, err)
+	testutil.RequireDocJSONEq(t, d, `{"a": 1}`)
+	entries, err := ioutil.ReadDir(tmp)
+	assert.NoError(t, err)
+	require.NotEmpty(t, entries)
+
+	assert.NoError(t, db.Close())
+
+	t.Run("ReadOnly", func(t *testing.T) {
+		db, err := genji.OpenWithOptions(path, &genji.Options{
+			ReadOnly: true,
+		})
+		assert.NoError(t, err)
+		defer db.Close()
+
+		d, err := db.QueryDocument("SELECT COUNT(*) FROM test")
+		assert.NoError(t, err)
+		testutil.RequireDocJSONEq(t, d, `{"COUNT(*)": 2}`)
+
+		err = db.Exec("INSERT INTO test (a) VALUES (3)")
+		assert.Error(t, err)
+
+		_, err = db.Begin(true)
+		assert.Error(t, err)
+	})
+
+	t.Run("ReadOnly in-memory", func(t *testing.T) {
+		_, err := genji.OpenWithOptions(":memory:", &genji.Options{
+			ReadOnly: true,
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestMaxTransactionSize(t *testing.T) {
+	open := func(t *testing.T, spill bool) *genji.DB {
+		dir, err := ioutil.TempDir("", "genji")
+		assert.NoError(t, err)
+		t.Cleanup(func() { os.RemoveAll(dir) })
+
+		db, err := genji.OpenWithOptions(filepath.Join(dir, "test.db"), &genji.Options{
+			MaxTransactionSize:     16 << 10,
+			SpillLargeTransactions: spill,
+			TempDir:                dir,
+		})
+		assert.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+
+		err = db.Exec(`CREATE TABLE test(a INT PRIMARY KEY, b TEXT); CREATE INDEX test_b ON test(b)`)
+		assert.NoError(t, err)
+		for i := 0; i < 10; i++ {
+			err = db.Exec(`INSERT INTO test (a, b) VALUES (?, ?)`, i, "foo")
+			assert.NoError(t, err)
+		}
+
+		return db
+	}
+
+	insert := func(db *genji.DB, n int) error {
+		return db.Update(func(tx *genji.Tx) error {
+			for i := 10; i < n; i++ {
+				err := tx.Exec(`INSERT INTO test (a, b) VALUES (?, ?)`, i, strings.Repeat("a", 100))
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	t.Run("Error", func(t *testing.T) {
+		db := open(t, false)
+
+		err := insert(db, 1000)
+		assert.ErrorIs(t, err, errs.ErrTransactionTooLarge)
+
+		d, err := db.QueryDocument("SELECT COUNT(*) FROM test")
+		assert.NoError(t, err)
+		testutil.RequireDocJSONEq(t, d, `{"COUNT(*)": 10}`)
+	})
+
+	t.Run("Spill", func(t *testing.T) {
+		db := open(t, true)
+
+		err := db.Update(func(tx *genji.Tx) error {
+			for i := 10; i < 1000; i++ {
+				err := tx.Exec(`INSERT INTO test (a, b) VALUES (?, ?)`, i, strings.Repeat("a", 100))
+				if err != nil {
+					return err
+				}
+			}
+
+			// reads see the spilled writes
+			d, err := tx.QueryDocument("SELECT COUNT(*) FROM test WHERE b < 'b'")
+			if err != nil {
+				return err
+			}
+			testutil.RequireDocJSONEq(t, d, `{"COUNT(*)": 990}`)
+
+			err = tx.Exec("UPDATE test SET b = 'bar' WHERE a >= 500")
+			if err != nil {
+				return err
+			}
+			return tx.Exec("DELETE FROM test WHERE a < 5")
+		})
+		assert.NoError(t, err)
+
+		d, err := db.QueryDocument("SELECT COUNT(*) FROM test")
+		assert.NoError(t, err)
+		testutil.RequireDocJSONEq(t, d, `{"COUNT(*)": 995}`)
+		d, err = db.QueryDocument("SELECT COUNT(*) FROM test WHERE b = 'bar'")
+		assert.NoError(t, err)
+		testutil.RequireDocJSONEq(t, d, `{"COUNT(*)": 500}`)
+
+		r, err := db.Check(context.Background())
+		assert.NoError(t, err)
+		require.True(t, r.OK())
+	})
+
+	t.Run("Savepoints", func(t *testing.T) {
+		db := open(t, true)
+
+		tx, err := db.Begin(true)
+		assert.NoError(t, err)
+		defer tx.Rollback()
+
+		assert.NoError(t, tx.Savepoint("sp"))
+		var i int
+		for i = 10; err == nil; i++ {
+			err = tx.Exec(`INSERT INTO test (a, b) VALUES (?, ?)`, i, strings.Repeat("a", 100))
+		}
+		assert.ErrorIs(t, err, errs.ErrTransactionTooLarge)
+		assert.NoError(t, tx.RollbackTo("sp"))
+
+		// transactions without savepoints are spilled
+		assert.NoError(t, tx.ReleaseSavepoint("sp"))
+		for i = 10; i < 1000; i++ {
+			assert.NoError(t, tx.Exec(`INSERT INTO test (a, b) VALUES (?, ?)`, i, strings.Repeat("a", 100)))
+		}
+		assert.Error(t, tx.Savepoint("sp"))
+		assert.NoError(t, tx.Commit())
+
+		d, err := db.QueryDocument("SELECT COUNT(*) FROM test")
+		assert.NoError(t, err)
+		testutil.RequireDocJSONEq(t, d, `{"COUNT(*)": 1000}`)
+	})
+}
+
+func TestStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genji")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	db, err := genji.Open(filepath.Join(dir, "test.db"))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	before, err := db.Stats()
+	assert.NoError(t, err)
+
+	err = db.Exec(`
+		CREATE TABLE foo(a INT PRIMARY KEY, b TEXT);
+		CREATE INDEX foo_b ON foo(b);
+		CREATE TABLE bar;
+	`)
+	assert.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		err = db.Exec("INSERT INTO foo (a, b) VALUES (?, ?)", i, strings.Repeat("b", 1000))
+		assert.NoError(t, err)
+	}
+	err = db.Exec("UPDATE foo SET b = 'c' WHERE a < 10")
+	assert.NoError(t, err)
+	err = db.Exec("DELETE FROM foo WHERE a >= 90")
+	assert.NoError(t, err)
+	res, err := db.Query("SELECT a FROM foo ORDER BY c")
+	assert.NoError(t, err)
+	var count int
+	err = res.Iterate(func(d types.Document) error {
+		count++
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, res.Close())
+	require.Equal(t, 90, count)
+
+	err = db.Update(func(tx *genji.Tx) error {
+		return errors.New("rollback")
+	})
+	assert.Error(t, err)
+
+	// write the tables to disk
+	_, err = db.Compact(context.Background())
+	assert.NoError(t, err)
+
+	s, err := db.Stats()
+	assert.NoError(t, err)
+
+	// one transaction per statement, the last one being rolled back
+	require.Equal(t, before.TransactionsStarted+106, s.TransactionsStarted)
+	require.Equal(t, before.TransactionsCommitted+105, s.TransactionsCommitted)
+	require.Equal(t, before.TransactionsRolledBack+1, s.TransactionsRolledBack)
+	require.Greater(t, s.ReadOnlyTransactions, before.ReadOnlyTransactions)
+
+	require.Equal(t, int64(3), s.Statements["CREATE"])
+	require.Equal(t, int64(100), s.Statements["INSERT"])
+	require.Equal(t, int64(1), s.Statements["UPDATE"])
+	require.Equal(t, int64(1), s.Statements["DELETE"])
+	require.Equal(t, int64(1), s.Statements["SELECT"])
+	require.Equal(t, int64(0), s.Statements["OTHER"])
+
+	// sorting uses a temporary store
+	require.Equal(t, int64(1), s.TempStores.Created)
+	require.Zero(t, s.TempStores.InUse)
+
+	require.NotZero(t, s.Engine.DiskUsage)
+	require.NotZero(t, s.Engine.Flushes)
+
+	tables := make(map[string]genji.TableStats)
+	for _, ts := range s.Tables {
+		tables[ts.TableName] = ts
+	}
+	require.Contains(t, tables, "bar")
+	foo := tables["foo"]
+	// inserts, updates and deletes
+	require.Equal(t, int64(120), foo.DocumentsWritten)
+	// updated and deleted documents, read again once locked and
+	// to update the index, then all of them for the sort
+	require.Equal(t, int64(150), foo.DocumentsRead)
+	require.Greater(t, foo.Size, uint64(1000))
+	require.Len(t, foo.Indexes, 1)
+	require.Equal(t, "foo_b", foo.Indexes[0].IndexName)
+	require.Greater(t, foo.Indexes[0].Size, uint64(1000))
+	require.Equal(t, foo.Size+foo.Indexes[0].Size, foo.TotalSize())
+	require.Less(t, tables["bar"].TotalSize(), foo.Size)
+
+	// failed statements are not counted
+	err = db.Exec("INSERT INTO foo (a) VALUES (1)")
+	assert.Error(t, err)
+
+	// renamed tables keep their metrics, dropped ones lose them
+	err = db.Exec(`
+		ALTER TABLE foo RENAME TO baz;
+		CREATE TABLE foo;
+		INSERT INTO foo (a) VALUES (1);
+	`)
+	assert.NoError(t, err)
+	err = db.Exec("DROP TABLE bar; CREATE TABLE bar")
+	assert.NoError(t, err)
+
+	s, err = db.Stats()
+	assert.NoError(t, err)
+	require.Equal(t, int64(101), s.Statements["INSERT"])
+	tables = make(map[string]genji.TableStats)
+	for _, ts := range s.Tables {
+		tables[ts.TableName] = ts
+	}
+	require.Equal(t, int64(120), tables["baz"].DocumentsWritten)
+	require.Equal(t, int64(150), tables["baz"].DocumentsRead)
+	require.Equal(t, int64(1), tables["foo"].DocumentsWritten)
+	require.Zero(t, tables["foo"].DocumentsRead)
+	require.Zero(t, tables["bar"].DocumentsWritten)
+
+	// documents written by rolled back transactions are not counted
+	err = db.Update(func(tx *genji.Tx) error {
+		err := tx.Exec("INSERT INTO foo (a) VALUES (1000)")
+		assert.NoError(t, err)
+		return errors.New("rollback")
+	})
+	assert.Error(t, err)
+	err = db.Exec(`
+		BEGIN;
+		SAVEPOINT sp;
+		INSERT INTO foo (a) VALUES (1000);
+		ROLLBACK TO sp;
+		INSERT INTO foo (a) VALUES (1001);
+		DELETE FROM foo WHERE a = 1001;
+		COMMIT;
+	`)
+	assert.NoError(t, err)
+
+	s, err = db.Stats()
+	assert.NoError(t, err)
+	tables = make(map[string]genji.TableStats)
+	for _, ts := range s.Tables {
+		tables[ts.TableName] = ts
+	}
+	require.Equal(t, int64(3), tables["foo"].DocumentsWritten)
+}
+
+func TestBackup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genji")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"on-disk", filepath.Join(dir, "test.db")},
+		{"in-memory", ":memory:"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db, err := genji.Open(test.path)
+			assert.NoError(t, err)
+			defer db.Close()
+
+			err = db.Exec(`
+				CREATE TABLE test(a INT PRIMARY KEY);
+				CREATE SEQUENCE seq;
+				INSERT INTO test (a) VALUES (100), (NEXT VALUE FOR seq);
+			`)
+			assert.NoError(t, err)
+
+			// uncommitted changes are not part of the backup
+			tx, err := db.Begin(true)
+			assert.NoError(t, err)
+			defer tx.Rollback()
+			assert.NoError(t, tx.Exec("INSERT INTO test (a) VALUES (10)"))
+
+			backupDir := filepath.Join(dir, test.name+"-backup")
+			assert.NoError(t, db.Backup(context.Background(), backupDir))
+			assert.NoError(t, tx.Commit())
+
+			// the backup directory must not exist
+			assert.Error(t, db.Backup(context.Background(), backupDir))
+
+			bdb, err := genji.Open(backupDir)
+			assert.NoError(t, err)
+			defer bdb.Close()
+
+			d, err := bdb.QueryDocument("SELECT COUNT(*) FROM test")
+			assert.NoError(t, err)
+			testutil.RequireDocJSONEq(t, d, `{"COUNT(*)": 2}`)
+
+			// sequences don't return values already used by the original database
+			d, err = bdb.QueryDocument("SELECT NEXT VALUE FOR seq")
+			assert.NoError(t, err)
+			var v int
+			assert.NoError(t, document.Scan(d, &v))
+			require.Greater(t, v, 1)
+		})
+	}
+}
+
+func TestCompact(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genji")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.db")
+
+	db, err := genji.Open(path)
+	assert.NoError(t, err)
+
+	err = db.Exec(`CREATE TABLE test(a INT PRIMARY KEY, b TEXT)`)
+	assert.NoError(t, err)
+	err = db.Update(func(tx *genji.Tx) error {
+		for i := 0; i < 5000; i++ {
+			err := tx.Exec("INSERT INTO test (a, b) VALUES (?, ?)", i, strings.Repeat("b", 100))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, db.Close())
+
+	// reopening the database writes the data to disk
+	db, err = genji.Open(path)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	assert.NoError(t, db.Exec("DROP TABLE test"))
+
+	reclaimed, err := db.Compact(context.Background())
+	assert.NoError(t, err)
+	require.Greater(t, reclaimed, int64(5000))
+
+	d, err := db.QueryDocument("VACUUM")
+	assert.NoError(t, err)
+	testutil.RequireDocJSONEq(t, d, `{"reclaimed_bytes": 0}`)
+
+	// VACUUM can't be run within a transaction
+	assert.NoError(t, db.Exec("BEGIN"))
+	assert.Error(t, db.Exec("VACUUM"))
+	assert.NoError(t, db.Exec("ROLLBACK"))
+}
+
+func TestBulkLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genji")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.db")
+
+	db, err := genji.Open(path)
+	assert.NoError(t, err)
+
+	err = db.Exec(`
+		CREATE TABLE test(a INT PRIMARY KEY, b TEXT UNIQUE);
+		CREATE INDEX test_b_a ON test(b, a);
+	`)
+	assert.NoError(t, err)
+
+	var docs testutil.Docs
+	for i := 999; i >= 0; i-- {
+		fb := document.NewFieldBuffer().
+			Add("a", types.NewIntegerValue(int64(i))).
+			Add("b", types.NewTextValue(fmt.Sprintf("b%03d", i)))
+		docs = append(docs, fb)
+	}
+
+	err = db.BulkLoad("test", docs)
+	assert.NoError(t, err)
+
+	// violating a constraint doesn't load anything
+	err = db.BulkLoad("test", testutil.MakeDocuments(t, `{"a": 1000, "b": "foo"}`, `{"a": 1001, "b": "b001"}`))
+	assert.Error(t, err)
+
+	assert.NoError(t, db.Close())
+
+	db, err = genji.Open(path)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	d, err := db.QueryDocument("SELECT COUNT(*) AS n FROM test")
+	assert.NoError(t, err)
+	testutil.RequireDocJSONEq(t, d, `{"n": 1000}`)
+
+	d, err = db.QueryDocument("SELECT a FROM test WHERE b = 'b500'")
+	assert.NoError(t, err)
+	testutil.RequireDocJSONEq(t, d, `{"a": 500}`)
+
+	r, err := db.Check(context.Background())
+	assert.NoError(t, err)
+	require.True(t, r.OK(), r.Problems)
+
+	// bulk loading can't be done within a transaction
+	assert.NoError(t, db.Exec("BEGIN"))
+	assert.Error(t, db.BulkLoad("test", testutil.MakeDocuments(t, `{"a": 1000}`)))
+	assert.NoError(t, db.Exec("ROLLBACK"))
+}
+
 func TestQueryDocument(t *testing.T) {
 	db, err := genji.Open(":memory:")
 	assert.NoError(t, err)
@@ -224,6 +697,64 @@ func TestQueryDocument(t *testing.T) {
 	})
 }
 
+func TestTxSavepoint(t *testing.T) {
+	db, err := genji.Open(":memory:")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	tx, err := db.Begin(true)
+	assert.NoError(t, err)
+	defer tx.Rollback()
+
+	err = tx.Exec(`
+		CREATE TABLE test(a INT PRIMARY KEY, b INT DEFAULT NEXT VALUE FOR seq);
+		CREATE SEQUENCE seq;
+		INSERT INTO test (a) VALUES (1);
+	`)
+	assert.NoError(t, err)
+
+	assert.NoError(t, tx.Savepoint("sp"))
+
+	err = tx.Exec(`
+		CREATE TABLE foo;
+		CREATE INDEX test_b_idx ON test(b);
+		INSERT INTO test (a) VALUES (2);
+		DELETE FROM test WHERE a = 1;
+	`)
+	assert.NoError(t, err)
+
+	assert.NoError(t, tx.RollbackTo("sp"))
+
+	// catalog changes are undone
+	err = tx.Exec("SELECT * FROM foo")
+	assert.Error(t, err)
+	_, err = tx.QueryDocument("SELECT * FROM __genji_catalog WHERE name = 'test_b_idx'")
+	assert.ErrorIs(t, err, errs.ErrDocumentNotFound)
+
+	// documents are restored
+	d, err := tx.QueryDocument("SELECT COUNT(*) FROM test")
+	assert.NoError(t, err)
+	var count int
+	assert.NoError(t, document.Scan(d, &count))
+	require.Equal(t, 1, count)
+
+	// unknown savepoints return an error
+	assert.Error(t, tx.RollbackTo("unknown"))
+
+	assert.NoError(t, tx.ReleaseSavepoint("sp"))
+	assert.Error(t, tx.RollbackTo("sp"))
+
+	// the sequence keeps generating values
+	assert.NoError(t, tx.Exec("INSERT INTO test (a) VALUES (3)"))
+	assert.NoError(t, tx.Commit())
+
+	d, err = db.QueryDocument("SELECT b FROM test WHERE a = 3")
+	assert.NoError(t, err)
+	var b int
+	assert.NoError(t, document.Scan(d, &b))
+	require.Greater(t, b, 1)
+}
+
 func TestPrepareThreadSafe(t *testing.T) {
 	db, err := genji.Open(":memory:")
 	assert.NoError(t, err)
@@ -256,6 +787,70 @@ func TestPrepareThreadSafe(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestConcurrentUpdates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genji")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	db, err := genji.Open(filepath.Join(dir, "pebble"))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	err = db.Exec(`
+		CREATE TABLE test(id int primary key, a int, b int, c int);
+		CREATE INDEX ON test(b);
+		INSERT INTO test(id, a, b, c) VALUES (1, 0, 1, 1), (2, 0, 2, 2);
+	`)
+	assert.NoError(t, err)
+
+	const workers = 4
+	const updates = 100
+
+	tests := []string{
+		// primary key scan
+		"UPDATE test SET a = a + 1 WHERE id = 1",
+		// index scan
+		"UPDATE test SET a = a + 1 WHERE b = 1",
+		// table scan
+		"UPDATE test SET a = a + 1 WHERE c = 1",
+	}
+
+	g, _ := errgroup.WithContext(context.Background())
+
+	for i := 0; i < workers; i++ {
+		g.Go(func() error {
+			for j := 0; j < updates; j++ {
+				for _, q := range tests {
+					err := db.Exec(q)
+					if err != nil {
+						return err
+					}
+				}
+			}
+
+			return nil
+		})
+	}
+
+	err = g.Wait()
+	assert.NoError(t, err)
+
+	d, err := db.QueryDocument("SELECT a FROM test WHERE id = 1")
+	assert.NoError(t, err)
+
+	var a int
+	err = document.Scan(d, &a)
+	assert.NoError(t, err)
+	require.Equal(t, workers*updates*len(tests), a)
+
+	// the other document must not be modified
+	d, err = db.QueryDocument("SELECT a FROM test WHERE id = 2")
+	assert.NoError(t, err)
+	err = document.Scan(d, &a)
+	assert.NoError(t, err)
+	require.Equal(t, 0, a)
+}
+
 func BenchmarkSelect(b *testing.B) {
 	for size := 1; size <= 10000; size *= 10 {
 		b.Run(fmt.Sprintf("%.05d", size), func(b *testing.B) {
@@ -37,6 +37,15 @@ func TestDiff(t *testing.T) {
 				{"delete", document.NewPath("a"), types.NewIntegerValue(1)},
 			},
 		},
+		{
+			name: "remove multiple fields",
+			d1:   `{"a": 1, "b": 2, "c": 3}`,
+			d2:   `{"c": 3}`,
+			want: []document.Op{
+				{"delete", document.NewPath("a"), types.NewIntegerValue(1)},
+				{"delete", document.NewPath("b"), types.NewIntegerValue(2)},
+			},
+		},
 		{
 			name: "same",
 			d1:   `{"a": 1}`,
@@ -5,6 +5,7 @@ import (
 
 	"github.com/cockroachdb/errors"
 	"github.com/genjidb/genji/document"
+	"github.com/genjidb/genji/internal/kv"
 	"github.com/genjidb/genji/internal/tree"
 )
 
@@ -15,6 +16,10 @@ var (
 	// ErrDuplicateDocument is returned when another document is already associated with a given key, primary key,
 	// or if there is a unique index violation.
 	ErrDuplicateDocument = errors.New("duplicate document")
+
+	// ErrTransactionTooLarge is returned when the writes of a transaction exceed
+	// the maximum size of a transaction and spilling them to disk is disabled.
+	ErrTransactionTooLarge = kv.ErrTransactionTooLarge
 )
 
 // AlreadyExistsError is returned when to create a table, an index or a sequence
@@ -75,3 +80,24 @@ func IsConstraintViolationError(err error) bool {
 		return false
 	}
 }
+
+// DeadlockError is returned when a transaction is aborted because waiting
+// for a lock would have caused a deadlock with other transactions.
+// The transaction must be rolled back and can be retried.
+type DeadlockError struct {
+	Txid uint64
+}
+
+func (d DeadlockError) Error() string {
+	return fmt.Sprintf("deadlock detected, transaction %d aborted", d.Txid)
+}
+
+func IsDeadlockError(err error) bool {
+	err = errors.UnwrapAll(err)
+	switch err.(type) {
+	case DeadlockError, *DeadlockError:
+		return true
+	default:
+		return false
+	}
+}
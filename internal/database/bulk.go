@@ -0,0 +1,237 @@
+package database
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/cockroachdb/errors"
+	"github.com/genjidb/genji/document"
+	errs "github.com/genjidb/genji/errors"
+	"github.com/genjidb/genji/internal/kv"
+	"github.com/genjidb/genji/internal/tree"
+	"github.com/genjidb/genji/types"
+	"github.com/genjidb/genji/types/encoding"
+)
+
+// BulkLoad inserts every document returned by the iterator into the given table.
+// Documents are validated like regular inserts, but instead of being written to a transaction,
+// the documents and their index entries are passed to a kv.Loader and written all at once.
+// The table is locked during the whole operation, and if any document can't be inserted,
+// for example because it violates a constraint, none of them are.
+// Documents are written once the transaction that generated their keys is committed,
+// to make sure the state of the docid sequence is durable before them: if writing them fails,
+// the sequence might still have been incremented.
+// Bulk loading doesn't record changes: it fails if the change feed or the change log are enabled.
+func (db *Database) BulkLoad(ctx context.Context, tableName string, it document.Iterator) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if tx.capturesChanges() {
+		return errors.New("cannot bulk load while changes are captured")
+	}
+
+	err = lockRelation(tx, tableName)
+	if err != nil {
+		return err
+	}
+
+	tb, err := db.Catalog.GetTable(tx, tableName)
+	if err != nil {
+		return err
+	}
+	if tb.Info.ReadOnly {
+		return errors.New("cannot write to read-only table")
+	}
+
+	var indexes []*bulkIndex
+	for _, info := range db.Catalog.Cache.GetTableIndexes(tableName) {
+		idx, err := db.Catalog.GetIndex(tx, info.IndexName)
+		if err != nil {
+			return err
+		}
+		indexes = append(indexes, &bulkIndex{info: info, idx: idx})
+	}
+
+	l, err := db.ng.NewLoader()
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	var buf bytes.Buffer
+	err = it.Iterate(func(d types.Document) error {
+		fb, err := tb.Info.ValidateDocument(tx, d)
+		if err != nil {
+			return err
+		}
+
+		key, err := tb.GenerateKey(fb)
+		if err != nil {
+			return err
+		}
+
+		// ensure the key is not already present in the table.
+		// duplicates within the loaded documents are detected by the loader.
+		ok, err := tb.Tree.Exists(key)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return &errs.ConstraintViolationError{
+				Constraint: "PRIMARY KEY",
+				Paths:      primaryKeyPaths(tb.Info),
+				Key:        key,
+			}
+		}
+
+		buf.Reset()
+		err = encoding.EncodeValue(&buf, types.NewDocumentValue(fb))
+		if err != nil {
+			return err
+		}
+		err = l.Put(tb.Info.StoreName, key, buf.Bytes())
+		if err != nil {
+			return err
+		}
+
+		for _, bi := range indexes {
+			err = bi.put(l, fb, key, &buf)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return bulkLoadError(tb.Info, err)
+	}
+
+	// check the uniqueness of the values added to unique indexes,
+	// which are sorted by the loader.
+	unique := make(map[string]*bulkIndex)
+	for _, bi := range indexes {
+		if bi.info.Unique {
+			unique[string(bi.info.StoreName)] = bi
+		}
+	}
+
+	// the docid sequence might have been modified: its state must be committed
+	// before the documents are written, while the table is still locked.
+	return tx.commit(func() error {
+		err := l.Commit(func(store, k, v []byte) error {
+			bi, ok := unique[string(store)]
+			if !ok {
+				return nil
+			}
+
+			return bi.checkUnique(k)
+		})
+
+		return bulkLoadError(tb.Info, err)
+	})
+}
+
+// bulkLoadError converts duplicate keys found by the loader
+// into primary key constraint violations.
+func bulkLoadError(ti *TableInfo, err error) error {
+	if !errors.Is(err, kv.ErrDuplicateKey) {
+		return err
+	}
+
+	return &errs.ConstraintViolationError{
+		Constraint: "PRIMARY KEY",
+		Paths:      primaryKeyPaths(ti),
+	}
+}
+
+// primaryKeyPaths returns the paths of the primary key of the table, if any.
+func primaryKeyPaths(ti *TableInfo) []document.Path {
+	if pk := ti.GetPrimaryKey(); pk != nil {
+		return pk.Paths
+	}
+
+	return nil
+}
+
+type bulkIndex struct {
+	info *IndexInfo
+	idx  *Index
+	// values of the previous entry, used to detect duplicates.
+	prev []byte
+}
+
+// put adds the entry of the document to the loader.
+func (bi *bulkIndex) put(l kv.Loader, d types.Document, key tree.Key, buf *bytes.Buffer) error {
+	vs := make([]types.Value, 0, len(bi.info.Paths))
+
+	var hasNull bool
+	for _, path := range bi.info.Paths {
+		v, err := path.GetValueFromDocument(d)
+		if err != nil {
+			v = types.NewNullValue()
+		}
+		if v.Type() == types.NullValue {
+			hasNull = true
+		}
+		vs = append(vs, v)
+	}
+
+	// duplicates within the loaded documents are detected on commit,
+	// by checkUnique.
+	if bi.info.Unique && !hasNull {
+		duplicate, dkey, err := bi.idx.Exists(vs)
+		if err != nil {
+			return err
+		}
+		if duplicate {
+			return &errs.ConstraintViolationError{
+				Constraint: "UNIQUE",
+				Paths:      bi.info.Paths,
+				Key:        dkey,
+			}
+		}
+	}
+
+	entry, err := tree.NewKey(append(vs, types.NewBlobValue(key))...)
+	if err != nil {
+		return err
+	}
+
+	buf.Reset()
+	err = encoding.EncodeValue(buf, types.NewNullValue())
+	if err != nil {
+		return err
+	}
+
+	return l.Put(bi.info.StoreName, entry, buf.Bytes())
+}
+
+// checkUnique ensures consecutive entries of a unique index
+// don't contain the same values. It must be called in order.
+func (bi *bulkIndex) checkUnique(entry []byte) error {
+	pos := bytes.LastIndex(entry, []byte{encoding.ArrayValueDelim})
+	values := entry[:pos]
+
+	if bi.prev != nil && bytes.Equal(bi.prev, values) {
+		// NULL values are never considered equal by unique indexes
+		hasNull, err := containsNull(entry)
+		if err != nil {
+			return err
+		}
+		if !hasNull {
+			enc := encoding.EncodedValue(entry[pos+1:])
+			return &errs.ConstraintViolationError{
+				Constraint: "UNIQUE",
+				Paths:      bi.info.Paths,
+				Key:        tree.Key(enc.V().([]byte)),
+			}
+		}
+	}
+
+	bi.prev = append(bi.prev[:0], values...)
+	return nil
+}
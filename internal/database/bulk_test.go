@@ -0,0 +1,134 @@
+package database_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/genjidb/genji/document"
+	errs "github.com/genjidb/genji/errors"
+	"github.com/genjidb/genji/internal/database"
+	"github.com/genjidb/genji/internal/testutil"
+	"github.com/genjidb/genji/internal/testutil/assert"
+	"github.com/genjidb/genji/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBulkLoad(t *testing.T) {
+	setup := func(t *testing.T) *database.Database {
+		db, cleanup := testutil.NewTestDB(t)
+		t.Cleanup(cleanup)
+
+		tx, err := db.Begin(true)
+		assert.NoError(t, err)
+		defer tx.Rollback()
+
+		testutil.MustExec(t, db, tx, `
+			CREATE TABLE test(a INT PRIMARY KEY, b INT NOT NULL, c TEXT UNIQUE);
+			CREATE INDEX test_b ON test(b);
+			INSERT INTO test (a, b, c) VALUES (1, 10, 'x1');
+			CREATE TABLE nopk(a INT);
+		`)
+		assert.NoError(t, tx.Commit())
+
+		return db
+	}
+
+	query := func(t *testing.T, db *database.Database, q string) []string {
+		t.Helper()
+
+		tx, err := db.Begin(false)
+		assert.NoError(t, err)
+		defer tx.Rollback()
+
+		res := testutil.MustQuery(t, db, tx, q)
+		defer res.Close()
+
+		var list []string
+		err = res.Iterate(func(d types.Document) error {
+			data, err := document.MarshalJSON(d)
+			if err != nil {
+				return err
+			}
+			list = append(list, string(data))
+			return nil
+		})
+		assert.NoError(t, err)
+		return list
+	}
+
+	check := func(t *testing.T, db *database.Database) {
+		t.Helper()
+
+		tx, err := db.Begin(false)
+		assert.NoError(t, err)
+		defer tx.Rollback()
+
+		r, err := database.Check(tx, db.Catalog, false)
+		assert.NoError(t, err)
+		require.True(t, r.OK(), r.Problems)
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		db := setup(t)
+
+		err := db.BulkLoad(context.Background(), "test", testutil.MakeDocuments(t,
+			`{"a": 4, "b": 40, "c": "x4"}`,
+			`{"a": 2, "b": 20.0}`,
+			`{"a": 3, "b": 30, "c": null}`,
+		))
+		assert.NoError(t, err)
+
+		require.Equal(t, []string{
+			`{"a": 1, "b": 10, "c": "x1"}`,
+			`{"a": 2, "b": 20}`,
+			`{"a": 3, "b": 30, "c": null}`,
+			`{"a": 4, "b": 40, "c": "x4"}`,
+		}, query(t, db, "SELECT * FROM test"))
+		require.Equal(t, []string{`{"a": 3}`}, query(t, db, "SELECT a FROM test WHERE b = 30"))
+		require.Equal(t, []string{`{"a": 4}`}, query(t, db, "SELECT a FROM test WHERE c = 'x4'"))
+		check(t, db)
+
+		// documents without primary key use the docid sequence
+		err = db.BulkLoad(context.Background(), "nopk", testutil.MakeDocuments(t, `{"a": 1}`, `{"a": 2}`))
+		assert.NoError(t, err)
+		err = db.BulkLoad(context.Background(), "nopk", testutil.MakeDocuments(t, `{"a": 3}`))
+		assert.NoError(t, err)
+		require.Equal(t, []string{`{"a": 1}`, `{"a": 2}`, `{"a": 3}`}, query(t, db, "SELECT * FROM nopk"))
+		// the lease of the sequence is committed along with the documents
+		require.Equal(t, []string{`{"ok": true}`}, query(t, db, "SELECT seq >= 3 AS ok FROM __genji_sequence WHERE name = 'nopk_seq'"))
+	})
+
+	tests := []struct {
+		name       string
+		docs       []string
+		constraint string
+	}{
+		{"Existing primary key", []string{`{"a": 2, "b": 20}`, `{"a": 1, "b": 10}`}, "PRIMARY KEY"},
+		{"Duplicate primary key", []string{`{"a": 2, "b": 20}`, `{"a": 2, "b": 30}`}, "PRIMARY KEY"},
+		{"Existing unique value", []string{`{"a": 2, "b": 20, "c": "x1"}`}, "UNIQUE"},
+		{"Duplicate unique value", []string{`{"a": 2, "b": 20, "c": "x2"}`, `{"a": 3, "b": 30, "c": "x2"}`}, "UNIQUE"},
+		{"Not null", []string{`{"a": 2, "b": 20}`, `{"a": 3}`}, "NOT NULL"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := setup(t)
+
+			err := db.BulkLoad(context.Background(), "test", testutil.MakeDocuments(t, test.docs...))
+			cerr, ok := err.(*errs.ConstraintViolationError)
+			require.True(t, ok, "expected a constraint violation, got %v", err)
+			require.Equal(t, test.constraint, cerr.Constraint)
+
+			// nothing was loaded
+			require.Equal(t, []string{`{"a": 1, "b": 10, "c": "x1"}`}, query(t, db, "SELECT * FROM test"))
+			check(t, db)
+		})
+	}
+
+	t.Run("Unknown table", func(t *testing.T) {
+		db := setup(t)
+
+		err := db.BulkLoad(context.Background(), "unknown", testutil.MakeDocuments(t, `{"a": 1}`))
+		require.Error(t, err)
+	})
+}
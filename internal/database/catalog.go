@@ -5,11 +5,13 @@ import (
 	"fmt"
 	"math"
 	"sort"
+	"sync"
 
 	"github.com/cockroachdb/errors"
 	"github.com/genjidb/genji/document"
 	errs "github.com/genjidb/genji/errors"
 	"github.com/genjidb/genji/internal/kv"
+	"github.com/genjidb/genji/internal/lock"
 	"github.com/genjidb/genji/internal/tree"
 	"github.com/genjidb/genji/types"
 )
@@ -28,12 +30,16 @@ const (
 type Catalog struct {
 	Cache        *catalogCache
 	CatalogTable *CatalogStore
+	// Statistics of the tables, computed by the ANALYZE statement
+	// and persisted into the __genji_statistics table.
+	Statistics *statisticsCache
 }
 
 func NewCatalog() *Catalog {
 	return &Catalog{
 		Cache:        newCatalogCache(),
 		CatalogTable: newCatalogStore(),
+		Statistics:   newStatisticsCache(),
 	}
 }
 
@@ -43,6 +49,12 @@ func (c *Catalog) Init(tx *Transaction) error {
 		return err
 	}
 
+	// read-only transactions can only be used on
+	// databases that were already initialized.
+	if !tx.Writable {
+		return nil
+	}
+
 	// ensure the store sequence exists
 	err = c.CreateSequence(tx, &SequenceInfo{
 		Name:        StoreSequence,
@@ -96,6 +108,7 @@ func (c *Catalog) GetTable(tx *Transaction, tableName string) (*Table, error) {
 		Tree:    tree.New(s),
 		Info:    ti,
 		Catalog: c,
+		metrics: tx.tableMetrics(tableName),
 	}, nil
 }
 
@@ -121,7 +134,12 @@ func (c *Catalog) CreateTable(tx *Transaction, tableName string, info *TableInfo
 		return errors.New("table name required")
 	}
 
-	_, err := c.GetTable(tx, tableName)
+	err := lockRelation(tx, tableName)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.GetTable(tx, tableName)
 	if err != nil && !errs.IsNotFoundError(err) {
 		return err
 	}
@@ -166,6 +184,11 @@ func (c *Catalog) CreateTable(tx *Transaction, tableName string, info *TableInfo
 
 // DropTable deletes a table from the catalog
 func (c *Catalog) DropTable(tx *Transaction, tableName string) error {
+	err := lockRelation(tx, tableName)
+	if err != nil {
+		return err
+	}
+
 	ti, err := c.GetTableInfo(tableName)
 	if err != nil {
 		return err
@@ -176,6 +199,11 @@ func (c *Catalog) DropTable(tx *Transaction, tableName string) error {
 	}
 
 	for _, idx := range c.Cache.GetTableIndexes(tableName) {
+		err = lockRelation(tx, idx.IndexName)
+		if err != nil {
+			return err
+		}
+
 		_, err = c.Cache.Delete(tx, RelationIndexType, idx.IndexName)
 		if err != nil {
 			return err
@@ -197,14 +225,28 @@ func (c *Catalog) DropTable(tx *Transaction, tableName string) error {
 		return err
 	}
 
+	err = c.deleteTableStatistics(tx, tableName)
+	if err != nil {
+		return err
+	}
+
+	tx.Metrics.dropTable(tx, tableName)
+
 	return tx.Tx.DropStore(ti.StoreName)
 }
 
 // CreateIndex creates an index with the given name.
 // If it already exists, returns errs.ErrIndexAlreadyExists.
 func (c *Catalog) CreateIndex(tx *Transaction, info *IndexInfo) error {
+	// prevent other transactions from modifying the table
+	// while the index is being created
+	err := lockRelation(tx, info.TableName)
+	if err != nil {
+		return err
+	}
+
 	// check if the associated table exists
-	_, err := c.GetTableInfo(info.TableName)
+	_, err = c.GetTableInfo(info.TableName)
 	if err != nil {
 		return err
 	}
@@ -221,6 +263,11 @@ func (c *Catalog) CreateIndex(tx *Transaction, info *IndexInfo) error {
 		return err
 	}
 
+	err = lockRelation(tx, info.IndexName)
+	if err != nil {
+		return err
+	}
+
 	err = c.CatalogTable.Insert(tx, info)
 	if err != nil {
 		return err
@@ -287,11 +334,26 @@ func (c *Catalog) DropIndex(tx *Transaction, name string) error {
 		return fmt.Errorf("cannot drop index %s because constraint on %s(%s) requires it", info.IndexName, info.TableName, info.Owner.Paths)
 	}
 
+	err = lockRelation(tx, info.TableName)
+	if err != nil {
+		return err
+	}
+
+	err = lockRelation(tx, name)
+	if err != nil {
+		return err
+	}
+
 	_, err = c.Cache.Delete(tx, RelationIndexType, name)
 	if err != nil {
 		return err
 	}
 
+	err = c.deleteIndexStatistics(tx, info)
+	if err != nil {
+		return err
+	}
+
 	return c.dropIndex(tx, info)
 }
 
@@ -309,6 +371,11 @@ func (c *Catalog) dropIndex(tx *Transaction, info *IndexInfo) error {
 
 // AddFieldConstraint adds a field constraint to a table.
 func (c *Catalog) AddFieldConstraint(tx *Transaction, tableName string, fc *FieldConstraint, tcs TableConstraints) error {
+	err := lockRelation(tx, tableName)
+	if err != nil {
+		return err
+	}
+
 	r, err := c.Cache.Get(RelationTableType, tableName)
 	if err != nil {
 		return err
@@ -339,8 +406,18 @@ func (c *Catalog) AddFieldConstraint(tx *Transaction, tableName string, fc *Fiel
 // RenameTable renames a table.
 // If it doesn't exist, it returns errs.ErrTableNotFound.
 func (c *Catalog) RenameTable(tx *Transaction, oldName, newName string) error {
+	err := lockRelation(tx, oldName)
+	if err != nil {
+		return err
+	}
+
+	err = lockRelation(tx, newName)
+	if err != nil {
+		return err
+	}
+
 	// Delete the old table info.
-	err := c.CatalogTable.Delete(tx, oldName)
+	err = c.CatalogTable.Delete(tx, oldName)
 	if errors.Is(err, errs.ErrDocumentNotFound) {
 		return errors.WithStack(errs.NotFoundError{Name: oldName})
 	}
@@ -417,7 +494,9 @@ func (c *Catalog) RenameTable(tx *Transaction, oldName, newName string) error {
 		}
 	}
 
-	return nil
+	tx.Metrics.renameTable(tx, oldName, newName)
+
+	return c.renameTableStatistics(tx, oldName, newName)
 }
 
 func (c *Catalog) GetSequence(name string) (*Sequence, error) {
@@ -448,6 +527,11 @@ func (c *Catalog) CreateSequence(tx *Transaction, info *SequenceInfo) error {
 		return err
 	}
 
+	err = lockRelation(tx, seq.Info.Name)
+	if err != nil {
+		return err
+	}
+
 	err = c.CatalogTable.Insert(tx, &seq)
 	if err != nil {
 		return err
@@ -458,6 +542,11 @@ func (c *Catalog) CreateSequence(tx *Transaction, info *SequenceInfo) error {
 
 // DropSequence deletes a sequence from the catalog.
 func (c *Catalog) DropSequence(tx *Transaction, name string) error {
+	err := lockRelation(tx, name)
+	if err != nil {
+		return err
+	}
+
 	r, err := c.Cache.Delete(tx, RelationSequenceType, name)
 	if err != nil {
 		return err
@@ -477,6 +566,13 @@ func (c *Catalog) ListSequences() []string {
 	return c.Cache.ListObjects(RelationSequenceType)
 }
 
+// lockRelation acquires an exclusive lock on the relation with the given name,
+// which prevents other read/write transactions from modifying it or
+// its documents until the transaction is committed or rolled back.
+func lockRelation(tx *Transaction, name string) error {
+	return tx.Lock(lock.NewTableObject(name), lock.X)
+}
+
 type Relation interface {
 	Type() string
 	Name() string
@@ -484,13 +580,46 @@ type Relation interface {
 	GenerateBaseName() string
 }
 
+// catalogCache is shared by all transactions. Read-only transactions
+// can access it while a read/write transaction modifies it, so every
+// access is protected by mu.
+// Read/write transactions modify the cache directly, and rely on locks
+// to prevent others from using the objects they modify.
+// Read-only transactions must not see these changes until they are committed:
+// they use the snapshot of the cache instead, which is only updated
+// when a transaction commits.
 type catalogCache struct {
+	mu sync.RWMutex
+
 	tables    map[string]Relation
 	indexes   map[string]Relation
 	sequences map[string]Relation
+
+	// snapshotMu is held in exclusive mode while a transaction commits its
+	// changes to the catalog, and in shared mode while a read-only transaction
+	// is started, so that read-only transactions see the changes
+	// if and only if they see the data they describe.
+	snapshotMu sync.RWMutex
+	// snapshot holds the committed objects. Its maps are never modified:
+	// commits replace it with an updated copy.
+	snapshot *catalogCache
+}
+
+// A catalogChange is a change made by a transaction to the catalog cache.
+// A nil relation means the object was deleted.
+type catalogChange struct {
+	tp   string
+	name string
+	r    Relation
 }
 
 func newCatalogCache() *catalogCache {
+	c := newCatalogMaps()
+	c.snapshot = newCatalogMaps()
+	return c
+}
+
+func newCatalogMaps() *catalogCache {
 	return &catalogCache{
 		tables:    make(map[string]Relation),
 		indexes:   make(map[string]Relation),
@@ -498,7 +627,17 @@ func newCatalogCache() *catalogCache {
 	}
 }
 
+// Load adds the given objects to the cache.
+// It must not be called while transactions modify the catalog.
 func (c *catalogCache) Load(tables []TableInfo, indexes []IndexInfo, sequences []Sequence) {
+	c.mu.Lock()
+	c.load(tables, indexes, sequences)
+	c.mu.Unlock()
+
+	c.resetSnapshot()
+}
+
+func (c *catalogCache) load(tables []TableInfo, indexes []IndexInfo, sequences []Sequence) {
 	for i := range tables {
 		c.tables[tables[i].TableName] = &tables[i]
 	}
@@ -512,9 +651,43 @@ func (c *catalogCache) Load(tables []TableInfo, indexes []IndexInfo, sequences [
 	}
 }
 
+// Reset replaces the content of the cache with the given objects.
+// It must not be called while transactions modify the catalog.
+func (c *catalogCache) Reset(tables []TableInfo, indexes []IndexInfo, sequences []Sequence) {
+	fresh := newCatalogMaps()
+	fresh.load(tables, indexes, sequences)
+
+	c.mu.Lock()
+	c.tables, c.indexes, c.sequences = fresh.tables, fresh.indexes, fresh.sequences
+	c.mu.Unlock()
+
+	c.resetSnapshot()
+}
+
+// resetSnapshot replaces the snapshot with the content of the cache.
+func (c *catalogCache) resetSnapshot() {
+	c.snapshotMu.Lock()
+	c.snapshot = c.cloneMaps()
+	c.snapshotMu.Unlock()
+}
+
 // TODO put in tests
 func (c *catalogCache) Clone() *catalogCache {
-	clone := newCatalogCache()
+	clone := c.cloneMaps()
+
+	c.snapshotMu.RLock()
+	clone.snapshot = c.snapshot
+	c.snapshotMu.RUnlock()
+
+	return clone
+}
+
+// cloneMaps returns a copy of the cache without its snapshot.
+func (c *catalogCache) cloneMaps() *catalogCache {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	clone := newCatalogMaps()
 
 	for k, v := range c.tables {
 		clone.tables[k] = v
@@ -529,6 +702,46 @@ func (c *catalogCache) Clone() *catalogCache {
 	return clone
 }
 
+// beginSnapshot calls begin, which is expected to start a read-only transaction
+// reading from a snapshot of the engine, and returns the snapshot of the cache
+// consistent with it.
+func (c *catalogCache) beginSnapshot(begin func() error) (*catalogCache, error) {
+	c.snapshotMu.RLock()
+	defer c.snapshotMu.RUnlock()
+
+	err := begin()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.snapshot, nil
+}
+
+// commit calls commitFn, which is expected to commit the transaction
+// that made the given changes, and adds them to the snapshot.
+func (c *catalogCache) commit(changes []catalogChange, commitFn func() error) error {
+	c.snapshotMu.Lock()
+	defer c.snapshotMu.Unlock()
+
+	err := commitFn()
+	if err != nil {
+		return err
+	}
+
+	snapshot := c.snapshot.cloneMaps()
+	for _, ch := range changes {
+		m := snapshot.getMapByType(ch.tp)
+		if ch.r == nil {
+			delete(m, ch.name)
+		} else {
+			m[ch.name] = ch.r
+		}
+	}
+	c.snapshot = snapshot
+
+	return nil
+}
+
 func (c *catalogCache) objectExists(name string) bool {
 	// checking if table exists with the same name
 	if _, ok := c.tables[name]; ok {
@@ -577,6 +790,9 @@ func (c *catalogCache) getMapByType(tp string) map[string]Relation {
 }
 
 func (c *catalogCache) Add(tx *Transaction, o Relation) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	name := o.Name()
 
 	// if name is provided, ensure it's not duplicated
@@ -593,14 +809,20 @@ func (c *catalogCache) Add(tx *Transaction, o Relation) error {
 	m := c.getMapByType(o.Type())
 	m[name] = o
 
+	tx.catalogChanges = append(tx.catalogChanges, catalogChange{tp: o.Type(), name: name, r: o})
 	tx.OnRollbackHooks = append(tx.OnRollbackHooks, func() {
+		c.mu.Lock()
 		delete(m, name)
+		c.mu.Unlock()
 	})
 
 	return nil
 }
 
 func (c *catalogCache) Replace(tx *Transaction, o Relation) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	m := c.getMapByType(o.Type())
 
 	old, ok := m[o.Name()]
@@ -610,14 +832,20 @@ func (c *catalogCache) Replace(tx *Transaction, o Relation) error {
 
 	m[o.Name()] = o
 
+	tx.catalogChanges = append(tx.catalogChanges, catalogChange{tp: o.Type(), name: o.Name(), r: o})
 	tx.OnRollbackHooks = append(tx.OnRollbackHooks, func() {
+		c.mu.Lock()
 		m[o.Name()] = old
+		c.mu.Unlock()
 	})
 
 	return nil
 }
 
 func (c *catalogCache) Delete(tx *Transaction, tp, name string) (Relation, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	m := c.getMapByType(tp)
 
 	o, ok := m[name]
@@ -627,14 +855,20 @@ func (c *catalogCache) Delete(tx *Transaction, tp, name string) (Relation, error
 
 	delete(m, name)
 
+	tx.catalogChanges = append(tx.catalogChanges, catalogChange{tp: tp, name: name})
 	tx.OnRollbackHooks = append(tx.OnRollbackHooks, func() {
+		c.mu.Lock()
 		m[name] = o
+		c.mu.Unlock()
 	})
 
 	return o, nil
 }
 
 func (c *catalogCache) Get(tp, name string) (Relation, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	m := c.getMapByType(tp)
 
 	o, ok := m[name]
@@ -646,6 +880,9 @@ func (c *catalogCache) Get(tp, name string) (Relation, error) {
 }
 
 func (c *catalogCache) ListObjects(tp string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	m := c.getMapByType(tp)
 
 	list := make([]string, 0, len(m))
@@ -658,6 +895,9 @@ func (c *catalogCache) ListObjects(tp string) []string {
 }
 
 func (c *catalogCache) GetTableIndexes(tableName string) []*IndexInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	var indexes []*IndexInfo
 	for _, o := range c.indexes {
 		idx := o.(*IndexInfo)
@@ -736,6 +976,10 @@ func newCatalogStore() *CatalogStore {
 
 func (s *CatalogStore) Init(tx *Transaction, ctg *Catalog) error {
 	s.Catalog = ctg
+	if !tx.Writable {
+		return nil
+	}
+
 	err := tx.Tx.CreateStore([]byte(TableName))
 	if err == nil || errors.Is(err, kv.ErrStoreAlreadyExists) {
 		return nil
@@ -41,6 +41,7 @@ func cloneCatalog(c *database.Catalog) *database.Catalog {
 
 	clone.CatalogTable = c.CatalogTable
 	clone.Cache = c.Cache.Clone()
+	clone.Statistics = c.Statistics
 
 	return &clone
 }
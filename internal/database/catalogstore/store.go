@@ -12,11 +12,61 @@ import (
 )
 
 func LoadCatalog(tx *database.Transaction, c *database.Catalog) error {
-	tables, indexes, sequences, err := loadCatalogStore(tx, c.CatalogTable)
+	tables, indexes, sequences, err := loadRelations(tx, c)
 	if err != nil {
 		return err
 	}
 
+	// load tables and indexes first
+	c.Cache.Load(tables, indexes, nil)
+
+	if len(sequences) > 0 {
+		var seqList []database.Sequence
+		seqList, err = loadSequences(tx, c, sequences)
+		if err != nil {
+			return err
+		}
+
+		c.Cache.Load(nil, nil, seqList)
+	}
+
+	return c.LoadStatistics(tx)
+}
+
+// ReloadCatalog replaces the content of the catalog cache with the objects
+// stored in the catalog table. It is used when the catalog table is modified
+// without going through the catalog, for example when replicating another database.
+func ReloadCatalog(tx *database.Transaction, c *database.Catalog) error {
+	tables, indexes, sequences, err := loadRelations(tx, c)
+	if err != nil {
+		return err
+	}
+
+	var seqList []database.Sequence
+	if len(sequences) > 0 {
+		seqList, err = loadSequences(tx, c, sequences)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.Cache.Reset(tables, indexes, seqList)
+
+	return c.LoadStatistics(tx)
+}
+
+// LoadTablesAndIndexes returns the tables and indexes stored in the catalog table.
+func LoadTablesAndIndexes(tx *database.Transaction, s *database.CatalogStore) ([]database.TableInfo, []database.IndexInfo, error) {
+	tables, indexes, _, err := loadCatalogStore(tx, s)
+	return tables, indexes, err
+}
+
+func loadRelations(tx *database.Transaction, c *database.Catalog) ([]database.TableInfo, []database.IndexInfo, []database.SequenceInfo, error) {
+	tables, indexes, sequences, err := loadCatalogStore(tx, c.CatalogTable)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
 	for _, tb := range tables {
 		// bind default values with catalog
 		for _, fc := range tb.FieldConstraints {
@@ -35,20 +85,7 @@ func LoadCatalog(tx *database.Transaction, c *database.Catalog) error {
 	ti.ReadOnly = true
 	tables = append(tables, *ti)
 
-	// load tables and indexes first
-	c.Cache.Load(tables, indexes, nil)
-
-	if len(sequences) > 0 {
-		var seqList []database.Sequence
-		seqList, err = loadSequences(tx, c, sequences)
-		if err != nil {
-			return err
-		}
-
-		c.Cache.Load(nil, nil, seqList)
-	}
-
-	return nil
+	return tables, indexes, sequences, nil
 }
 
 func loadSequences(tx *database.Transaction, c *database.Catalog, info []database.SequenceInfo) ([]database.Sequence, error) {
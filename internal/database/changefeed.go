@@ -0,0 +1,410 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cockroachdb/errors"
+	"github.com/genjidb/genji/internal/kv"
+	"github.com/genjidb/genji/internal/tree"
+	"github.com/genjidb/genji/types"
+	"github.com/genjidb/genji/types/encoding"
+)
+
+// ChangeFeedStoreName is the name of the store containing the position of the change feed.
+var ChangeFeedStoreName = []byte(InternalPrefix + "changefeed")
+
+var changeFeedPositionKey = []byte("position")
+
+// defaultChangeFeedHistorySize is the number of changes kept in memory
+// to allow subscribers to resume from a previous position.
+const defaultChangeFeedHistorySize = 10000
+
+// defaultSubscriberBufferSize is the maximum number of changes
+// waiting to be received by a subscriber.
+const defaultSubscriberBufferSize = 10000
+
+// ErrPositionUnavailable is returned when subscribing from a position
+// that is no longer, or not yet, available in the change feed.
+var ErrPositionUnavailable = errors.New("position unavailable")
+
+// ErrSubscriberTooSlow is returned by a subscriber that didn't receive
+// its changes fast enough and was dropped by the change feed.
+var ErrSubscriberTooSlow = errors.New("subscriber too slow")
+
+// A Change describes a modification made to a document by a committed transaction.
+type Change struct {
+	// Position of the change in the feed. Positions are assigned
+	// in commit order and are strictly increasing, even across runs.
+	Position  uint64
+	TableName string
+	Key       tree.Key
+	// Old version of the document. Nil if the document was inserted.
+	Old types.Document
+	// New version of the document. Nil if the document was deleted.
+	New types.Document
+}
+
+// A ChangeFeed broadcasts the changes made by committed transactions
+// to its subscribers.
+// Changes are only captured once the feed has been enabled,
+// which happens the first time someone subscribes to it.
+type ChangeFeed struct {
+	// Number of changes kept in memory to allow subscribers
+	// to resume from a previous position.
+	HistorySize int
+
+	// Maximum number of changes waiting to be received by a subscriber.
+	// Subscribers exceeding it are dropped.
+	SubscriberBufferSize int
+
+	// commitMu is held while committing and publishing, to ensure
+	// changes are published in commit order.
+	commitMu sync.Mutex
+
+	enabled int32
+
+	mu          sync.Mutex
+	position    uint64
+	history     []Change
+	subscribers map[*Subscriber]struct{}
+}
+
+// NewChangeFeed creates a disabled change feed.
+// The position of the last change is written by the transaction publishing it,
+// so that positions keep increasing across runs. The history is only kept in memory:
+// after a restart, subscribers can only resume from the last position.
+func NewChangeFeed() *ChangeFeed {
+	return &ChangeFeed{
+		HistorySize:          defaultChangeFeedHistorySize,
+		SubscriberBufferSize: defaultSubscriberBufferSize,
+		subscribers:          make(map[*Subscriber]struct{}),
+	}
+}
+
+// load reads the position of the last change published
+// before the database was closed, if any.
+func (f *ChangeFeed) load(tx *Transaction) error {
+	ok, err := tx.Tx.StoreExists(ChangeFeedStoreName)
+	if err != nil || !ok {
+		return err
+	}
+
+	v, err := tx.Tx.GetStore(ChangeFeedStoreName).Get(changeFeedPositionKey)
+	if errors.Is(err, kv.ErrKeyNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	f.position = decodePosition(v)
+	f.mu.Unlock()
+
+	return nil
+}
+
+// Enabled returns true if transactions must capture their changes.
+func (f *ChangeFeed) Enabled() bool {
+	return atomic.LoadInt32(&f.enabled) == 1
+}
+
+func (f *ChangeFeed) enable() {
+	atomic.StoreInt32(&f.enabled, 1)
+}
+
+// Position returns the position of the last published change.
+func (f *ChangeFeed) Position() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.position
+}
+
+// Subscribe creates a subscriber receiving every change published after the given position
+// and made to one of the given tables. If no tables are provided, changes made to any table are received.
+// If from is nil, only changes published after the call are received.
+// If the changes following the position are no longer kept in memory, for example because
+// the database was reopened since, it returns ErrPositionUnavailable.
+func (f *ChangeFeed) Subscribe(from *uint64, tables ...string) (*Subscriber, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	pos := f.position
+	if from != nil {
+		pos = *from
+	}
+
+	if pos > f.position {
+		return nil, errors.Wrapf(ErrPositionUnavailable, "position %d is ahead of the feed", pos)
+	}
+
+	s := Subscriber{
+		feed:   f,
+		max:    f.SubscriberBufferSize,
+		notify: make(chan struct{}, 1),
+	}
+	if len(tables) > 0 {
+		s.tables = make(map[string]struct{}, len(tables))
+		for _, t := range tables {
+			s.tables[t] = struct{}{}
+		}
+	}
+
+	if pos < f.position {
+		oldest := f.position - uint64(len(f.history)) + 1
+		if len(f.history) == 0 || pos+1 < oldest {
+			return nil, errors.Wrapf(ErrPositionUnavailable, "position %d is no longer available", pos)
+		}
+
+		if !s.push(f.history[pos+1-oldest:]) {
+			return nil, s.err
+		}
+	}
+
+	f.subscribers[&s] = struct{}{}
+
+	return &s, nil
+}
+
+// publish assigns a position to each change and sends them to every subscriber.
+// The transaction must have written the position of its last change with writeFeedPosition.
+func (f *ChangeFeed) publish(changes []Change) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := range changes {
+		f.position++
+		changes[i].Position = f.position
+	}
+
+	f.history = append(f.history, changes...)
+	if n := len(f.history) - f.HistorySize; n > 0 {
+		f.history = append(f.history[:0:0], f.history[n:]...)
+	}
+
+	for s := range f.subscribers {
+		if !s.push(changes) {
+			delete(f.subscribers, s)
+		}
+	}
+}
+
+func (f *ChangeFeed) unsubscribe(s *Subscriber) {
+	f.mu.Lock()
+	delete(f.subscribers, s)
+	f.mu.Unlock()
+}
+
+// A Subscriber receives changes published by a change feed.
+type Subscriber struct {
+	feed   *ChangeFeed
+	tables map[string]struct{}
+	max    int
+
+	mu      sync.Mutex
+	pending []Change
+	// err is set when the subscriber is dropped by the feed.
+	err    error
+	notify chan struct{}
+}
+
+// push adds the changes to the pending ones. If the subscriber
+// has too many pending changes, it keeps the ones that fit and returns false:
+// the subscriber must then be dropped.
+func (s *Subscriber) push(changes []Change) bool {
+	s.mu.Lock()
+	for _, c := range changes {
+		if s.tables != nil {
+			if _, ok := s.tables[c.TableName]; !ok {
+				continue
+			}
+		}
+
+		if len(s.pending) >= s.max {
+			s.err = errors.Wrapf(ErrSubscriberTooSlow, "more than %d changes pending", s.max)
+			break
+		}
+
+		s.pending = append(s.pending, c)
+	}
+	ok := s.err == nil
+	s.mu.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+
+	return ok
+}
+
+// Next blocks until a change is available or until ctx is canceled.
+// If the subscriber was dropped because it had too many pending changes,
+// it returns ErrSubscriberTooSlow once the changes received before are consumed.
+func (s *Subscriber) Next(ctx context.Context) (*Change, error) {
+	for {
+		s.mu.Lock()
+		if len(s.pending) > 0 {
+			c := s.pending[0]
+			s.pending = s.pending[1:]
+			s.mu.Unlock()
+			return &c, nil
+		}
+		err := s.err
+		s.mu.Unlock()
+
+		if err != nil {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-s.notify:
+		}
+	}
+}
+
+// Close stops receiving changes.
+func (s *Subscriber) Close() {
+	s.feed.unsubscribe(s)
+}
+
+// Subscribe to the changes made by transactions committed after the given position.
+// If from is nil, only changes made by transactions committed after the call are received.
+// The first call waits for in-flight read/write transactions to complete, then enables the
+// change feed for the lifetime of the database.
+func (db *Database) Subscribe(from *uint64, tables ...string) (*Subscriber, error) {
+	if !db.ChangeFeed.Enabled() {
+		err := db.enableChangeFeed()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return db.ChangeFeed.Subscribe(from, tables...)
+}
+
+// enableChangeFeed creates the store of the position of the change feed, then enables it.
+func (db *Database) enableChangeFeed() error {
+	// wait for in-flight read/write transactions, so that every
+	// transaction committing after this point captures its changes.
+	db.txmu.Lock()
+	defer db.txmu.Unlock()
+
+	if db.ChangeFeed.Enabled() {
+		return nil
+	}
+
+	// read-only databases never publish any change
+	if !db.ng.ReadOnly() {
+		tx, err := db.beginTx(context.Background(), nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Tx.Rollback()
+
+		err = tx.Tx.CreateStore(ChangeFeedStoreName)
+		if err != nil && !errors.Is(err, kv.ErrStoreAlreadyExists) {
+			return err
+		}
+
+		err = tx.Tx.Commit()
+		if err != nil {
+			return err
+		}
+	}
+
+	db.ChangeFeed.enable()
+	return nil
+}
+
+// writeFeedPosition writes the position of the last change of the transaction,
+// which will be published once it is committed.
+func (tx *Transaction) writeFeedPosition() error {
+	last := tx.ChangeFeed.Position() + uint64(len(tx.changes))
+
+	return tx.Tx.GetStore(ChangeFeedStoreName).Put(changeFeedPositionKey, encodePosition(last))
+}
+
+// capturesChanges returns true if the transaction records its changes,
+// either to publish them to the change feed or to write them to the change log.
+func (tx *Transaction) capturesChanges() bool {
+	return tx.ChangeFeed != nil || tx.ChangeLog != nil
+}
+
+// recordChange records a change made to a document of the given table,
+// if the transaction captures its changes. Changes made to internal
+// tables are not published to the change feed but are written to the change log.
+func (tx *Transaction) recordChange(tableName string, key tree.Key, old, new types.Document) error {
+	publish := tx.ChangeFeed != nil && !strings.HasPrefix(tableName, InternalPrefix)
+	if !publish && tx.ChangeLog == nil {
+		return nil
+	}
+
+	// documents might be reused by the caller: keep a copy
+	var err error
+	if old != nil && publish {
+		old, err = copyDocument(old)
+		if err != nil {
+			return err
+		}
+	}
+	if new != nil {
+		new, err = copyDocument(new)
+		if err != nil {
+			return err
+		}
+	}
+
+	key = append(tree.Key(nil), key...)
+
+	if publish {
+		tx.changes = append(tx.changes, Change{
+			TableName: tableName,
+			Key:       key,
+			Old:       old,
+			New:       new,
+		})
+	}
+
+	if tx.ChangeLog != nil {
+		op := LogOp{
+			Type:      LogReplace,
+			TableName: tableName,
+			Key:       key,
+			Doc:       new,
+		}
+		switch {
+		case old == nil:
+			op.Type = LogInsert
+		case new == nil:
+			op.Type = LogDelete
+		}
+
+		tx.logOps = append(tx.logOps, op)
+	}
+
+	return nil
+}
+
+func copyDocument(d types.Document) (types.Document, error) {
+	var buf bytes.Buffer
+
+	err := encoding.EncodeValue(&buf, types.NewDocumentValue(d))
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := encoding.DecodeValue(buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	return v.V().(types.Document), nil
+}
@@ -0,0 +1,320 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cockroachdb/errors"
+	"github.com/genjidb/genji/document"
+	"github.com/genjidb/genji/internal/kv"
+	"github.com/genjidb/genji/internal/tree"
+	"github.com/genjidb/genji/types"
+	"github.com/genjidb/genji/types/encoding"
+)
+
+// ChangeLogStoreName is the name of the store containing the entries of the change log.
+var ChangeLogStoreName = []byte(InternalPrefix + "changelog")
+
+// LogOpType describes how a document was modified.
+type LogOpType int
+
+// Types of operations recorded in the change log.
+const (
+	LogInsert LogOpType = iota + 1
+	LogReplace
+	LogDelete
+)
+
+func (t LogOpType) String() string {
+	switch t {
+	case LogInsert:
+		return "insert"
+	case LogReplace:
+		return "replace"
+	case LogDelete:
+		return "delete"
+	}
+
+	return "unknown"
+}
+
+func logOpTypeFromString(s string) (LogOpType, error) {
+	switch s {
+	case "insert":
+		return LogInsert, nil
+	case "replace":
+		return LogReplace, nil
+	case "delete":
+		return LogDelete, nil
+	}
+
+	return 0, errors.Errorf("unknown operation %q", s)
+}
+
+// A LogOp is a modification of a document recorded in the change log.
+type LogOp struct {
+	Type      LogOpType
+	TableName string
+	Key       tree.Key
+	// New version of the document. Nil if the document was deleted.
+	Doc types.Document
+}
+
+// A ChangeLog durably records the modifications made by committed transactions,
+// so that they can be replayed on another database.
+// Unlike the change feed, it also records the modifications made to internal tables,
+// including the catalog, and it survives restarts.
+// Each committed transaction produces one entry, identified by its position.
+// Positions are assigned in commit order and are strictly increasing.
+// Once enabled, the change log remains enabled for the lifetime of the database files.
+type ChangeLog struct {
+	// mu is held while committing, to ensure entries
+	// are written in commit order.
+	mu sync.Mutex
+
+	enabled int32
+
+	// position of the last entry, protected by mu.
+	last uint64
+}
+
+// NewChangeLog creates a disabled change log.
+func NewChangeLog() *ChangeLog {
+	return &ChangeLog{}
+}
+
+// Enabled returns true if transactions must record their changes.
+func (l *ChangeLog) Enabled() bool {
+	return atomic.LoadInt32(&l.enabled) == 1
+}
+
+// Position returns the position of the last committed entry.
+func (l *ChangeLog) Position() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.last
+}
+
+// load enables the change log if its store exists
+// and reads the position of the last entry.
+func (l *ChangeLog) load(tx *Transaction) error {
+	ok, err := tx.Tx.StoreExists(ChangeLogStoreName)
+	if err != nil || !ok {
+		return err
+	}
+
+	st := tx.Tx.GetStore(ChangeLogStoreName)
+	it := st.Iterator(nil)
+	defer it.Close()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if it.Last() {
+		l.last = decodePosition(it.Key())
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+
+	atomic.StoreInt32(&l.enabled, 1)
+	return nil
+}
+
+// Iterate calls fn for every entry whose position is greater than from, in order.
+// The entry passed to fn is only valid until fn returns and can be
+// decoded using DecodeLogEntry.
+func (l *ChangeLog) Iterate(tx *Transaction, from uint64, fn func(position uint64, entry []byte) error) error {
+	st := tx.Tx.GetStore(ChangeLogStoreName)
+	it := st.Iterator(nil)
+	defer it.Close()
+
+	for it.SeekGE(encodePosition(from + 1)); it.Valid(); it.Next() {
+		pos := decodePosition(it.Key())
+
+		err := fn(pos, it.Value())
+		if err != nil {
+			return err
+		}
+	}
+
+	return it.Error()
+}
+
+// Prune deletes every entry whose position is lower than or equal to the given position.
+// The last entry is always kept, to preserve the position of the log.
+func (l *ChangeLog) Prune(tx *Transaction, position uint64) error {
+	last := l.Position()
+	if last == 0 {
+		return nil
+	}
+	if position >= last {
+		position = last - 1
+	}
+
+	st := tx.Tx.GetStore(ChangeLogStoreName)
+	it := st.Iterator(&kv.IterOptions{
+		UpperBound: encodePosition(position + 1),
+	})
+	defer it.Close()
+
+	for it.First(); it.Valid(); it.Next() {
+		err := st.Delete(it.Key())
+		if err != nil {
+			return err
+		}
+	}
+
+	return it.Error()
+}
+
+// EnableChangeLog creates the change log of the database.
+// It waits for in-flight read/write transactions to complete: every
+// transaction committed after the call records its changes in the log.
+func (db *Database) EnableChangeLog() error {
+	if db.ChangeLog.Enabled() {
+		return nil
+	}
+
+	db.txmu.Lock()
+	defer db.txmu.Unlock()
+
+	tx, err := db.beginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Tx.Rollback()
+
+	err = tx.Tx.CreateStore(ChangeLogStoreName)
+	if err != nil && !errors.Is(err, kv.ErrStoreAlreadyExists) {
+		return err
+	}
+
+	err = tx.Tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	atomic.StoreInt32(&db.ChangeLog.enabled, 1)
+	return nil
+}
+
+// writeLogEntry writes the operations recorded by the transaction
+// as the next entry of the change log and returns its position.
+// It must be called with the change log mutex locked.
+func (tx *Transaction) writeLogEntry() (uint64, error) {
+	entry, err := encodeLogOps(tx.logOps)
+	if err != nil {
+		return 0, err
+	}
+
+	pos := tx.ChangeLog.last + 1
+
+	st := tx.Tx.GetStore(ChangeLogStoreName)
+	err = st.Put(encodePosition(pos), entry)
+	if err != nil {
+		return 0, err
+	}
+
+	return pos, nil
+}
+
+func encodePosition(pos uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], pos)
+	return buf[:]
+}
+
+func decodePosition(k []byte) uint64 {
+	return binary.BigEndian.Uint64(k)
+}
+
+func encodeLogOps(ops []LogOp) ([]byte, error) {
+	vb := document.NewValueBuffer()
+	for _, op := range ops {
+		fb := document.NewFieldBuffer()
+		fb.Add("type", types.NewTextValue(op.Type.String()))
+		fb.Add("table", types.NewTextValue(op.TableName))
+		fb.Add("key", types.NewBlobValue(op.Key))
+		if op.Doc != nil {
+			fb.Add("doc", types.NewDocumentValue(op.Doc))
+		}
+
+		vb.Values = append(vb.Values, types.NewDocumentValue(fb))
+	}
+
+	var buf bytes.Buffer
+	err := encoding.EncodeValue(&buf, types.NewDocumentValue(document.NewFieldBuffer().Add("ops", types.NewArrayValue(vb))))
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// DecodeLogEntry decodes the operations of an entry of the change log.
+// The returned operations reference the given buffer.
+func DecodeLogEntry(entry []byte) ([]LogOp, error) {
+	v, err := encoding.DecodeValue(entry)
+	if err != nil {
+		return nil, err
+	}
+	if v.Type() != types.DocumentValue {
+		return nil, errors.New("invalid change log entry")
+	}
+
+	v, err = v.V().(types.Document).GetByField("ops")
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid change log entry")
+	}
+	if v.Type() != types.ArrayValue {
+		return nil, errors.New("invalid change log entry")
+	}
+
+	var ops []LogOp
+	err = v.V().(types.Array).Iterate(func(i int, v types.Value) error {
+		if v.Type() != types.DocumentValue {
+			return errors.New("invalid change log operation")
+		}
+		d := v.V().(types.Document)
+
+		var op LogOp
+		tp, err := d.GetByField("type")
+		if err != nil {
+			return err
+		}
+		op.Type, err = logOpTypeFromString(tp.V().(string))
+		if err != nil {
+			return err
+		}
+
+		tn, err := d.GetByField("table")
+		if err != nil {
+			return err
+		}
+		op.TableName = tn.V().(string)
+
+		k, err := d.GetByField("key")
+		if err != nil {
+			return err
+		}
+		op.Key = k.V().([]byte)
+
+		if op.Type != LogDelete {
+			doc, err := d.GetByField("doc")
+			if err != nil {
+				return err
+			}
+			op.Doc = doc.V().(types.Document)
+		}
+
+		ops = append(ops, op)
+		return nil
+	})
+
+	return ops, err
+}
@@ -0,0 +1,457 @@
+package database
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/cockroachdb/errors"
+	"github.com/genjidb/genji/document"
+	errs "github.com/genjidb/genji/errors"
+	"github.com/genjidb/genji/internal/kv"
+	"github.com/genjidb/genji/internal/tree"
+	"github.com/genjidb/genji/types"
+	"github.com/genjidb/genji/types/encoding"
+)
+
+// maximum number of invalid documents reported per table,
+// the others are only counted.
+const maxReportedDocuments = 10
+
+// A CheckProblem describes an inconsistency found by Check.
+type CheckProblem struct {
+	TableName string
+	// Name of the index, if the problem concerns an index.
+	IndexName string
+	Message   string
+}
+
+func (p *CheckProblem) String() string {
+	if p.IndexName != "" {
+		return fmt.Sprintf("index %s on table %s: %s", p.IndexName, p.TableName, p.Message)
+	}
+
+	return fmt.Sprintf("table %s: %s", p.TableName, p.Message)
+}
+
+// CheckReport lists the problems found by Check.
+type CheckReport struct {
+	Problems []CheckProblem
+	// Indexes that were rebuilt because they were broken.
+	Repaired []string
+}
+
+// OK returns true if no problem was found.
+func (r *CheckReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+func (r *CheckReport) add(tableName, indexName, format string, args ...interface{}) {
+	r.Problems = append(r.Problems, CheckProblem{
+		TableName: tableName,
+		IndexName: indexName,
+		Message:   fmt.Sprintf(format, args...),
+	})
+}
+
+// Check verifies the consistency of every table and index of the catalog:
+// - every table and index of the catalog table must be loaded in the catalog cache, and vice versa
+// - every table and index must have a store
+// - every document must satisfy the constraints of its table
+// - every index must contain exactly one entry per document, and no other entry
+// - unique indexes must not associate the same values with multiple documents
+// If repair is true, indexes with missing or dangling entries, or without a store,
+// are rebuilt from the content of their table. Repairing requires a read/write transaction.
+// Problems that can't be repaired, like documents violating constraints, are only reported.
+func Check(tx *Transaction, catalog *Catalog, repair bool) (*CheckReport, error) {
+	var r CheckReport
+
+	err := checkCatalog(tx, catalog, &r)
+	if err != nil {
+		return nil, err
+	}
+
+	tableNames := catalog.Cache.ListObjects(RelationTableType)
+	sort.Strings(tableNames)
+
+	for _, tableName := range tableNames {
+		// prevent concurrent writers from modifying the table
+		// while its indexes are being rebuilt.
+		if repair {
+			err := lockRelation(tx, tableName)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		tb, err := catalog.GetTable(tx, tableName)
+		if err != nil {
+			return nil, err
+		}
+
+		ok, err := tx.Tx.StoreExists(tb.Info.StoreName)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			r.add(tableName, "", "store %q not found", tb.Info.StoreName)
+			continue
+		}
+
+		err = checkDocuments(tx, tb, &r)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, indexName := range catalog.ListIndexes(tableName) {
+			info, err := catalog.GetIndexInfo(indexName)
+			if err != nil {
+				return nil, err
+			}
+
+			broken, err := checkIndex(tx, tb, info, &r)
+			if err != nil {
+				return nil, err
+			}
+			if !broken || !repair {
+				continue
+			}
+
+			err = rebuildIndex(tx, tb, info)
+			if err != nil {
+				return nil, err
+			}
+			r.Repaired = append(r.Repaired, indexName)
+		}
+	}
+
+	return &r, nil
+}
+
+// checkCatalog ensures the tables and indexes stored in the catalog table
+// are the ones loaded in the catalog cache, which is what the rest of the checks rely on.
+// Stored relations that are missing from the cache are invisible to the database:
+// their store is also verified here since no other check will.
+func checkCatalog(tx *Transaction, catalog *Catalog, r *CheckReport) error {
+	stored := make(map[string]bool)
+
+	err := catalog.CatalogTable.Table(tx).IterateOnRange(nil, false, func(key tree.Key, d types.Document) error {
+		var fields [4]string
+		for i, f := range []string{"type", "name", "table_name", "store_name"} {
+			v, err := d.GetByField(f)
+			if errors.Is(err, types.ErrFieldNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			switch v.Type() {
+			case types.TextValue:
+				fields[i] = v.V().(string)
+			case types.BlobValue:
+				fields[i] = string(v.V().([]byte))
+			}
+		}
+		tp, name, tableName, storeName := fields[0], fields[1], fields[2], fields[3]
+
+		var indexName string
+		switch tp {
+		case RelationTableType:
+			tableName = name
+		case RelationIndexType:
+			indexName = name
+		default:
+			return nil
+		}
+		stored[tp+"/"+name] = true
+
+		o, err := catalog.Cache.Get(tp, name)
+		if err == nil {
+			var cachedStoreName []byte
+			switch t := o.(type) {
+			case *TableInfo:
+				cachedStoreName = t.StoreName
+			case *IndexInfo:
+				cachedStoreName = t.StoreName
+			}
+
+			if storeName != string(cachedStoreName) {
+				r.add(tableName, indexName, "store %q of the catalog table doesn't match store %q of the catalog cache", storeName, cachedStoreName)
+			}
+			return nil
+		}
+		if !errs.IsNotFoundError(err) {
+			return err
+		}
+
+		r.add(tableName, indexName, "not found in the catalog cache")
+
+		ok, err := tx.Tx.StoreExists([]byte(storeName))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			r.add(tableName, indexName, "store %q not found", storeName)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, tableName := range catalog.Cache.ListObjects(RelationTableType) {
+		// the catalog table doesn't describe itself
+		if tableName == TableName {
+			continue
+		}
+		if !stored[RelationTableType+"/"+tableName] {
+			r.add(tableName, "", "not found in the catalog table")
+		}
+	}
+
+	for _, indexName := range catalog.Cache.ListObjects(RelationIndexType) {
+		if stored[RelationIndexType+"/"+indexName] {
+			continue
+		}
+
+		info, err := catalog.GetIndexInfo(indexName)
+		if err != nil {
+			return err
+		}
+		r.add(info.TableName, indexName, "not found in the catalog table")
+	}
+
+	return nil
+}
+
+// checkDocuments ensures every document of the table satisfies
+// the field and table constraints.
+func checkDocuments(tx *Transaction, tb *Table, r *CheckReport) error {
+	var invalid int
+
+	err := tb.IterateOnRange(nil, false, func(key tree.Key, d types.Document) error {
+		err := checkDocument(tx, tb.Info, d)
+		if err == nil {
+			return nil
+		}
+		// stop on errors that are not caused by the document itself
+		if !errs.IsConstraintViolationError(err) && !errors.Is(err, errInvalidDocument) {
+			return err
+		}
+
+		invalid++
+		if invalid <= maxReportedDocuments {
+			r.add(tb.Info.TableName, "", "document %s: %v", key, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if invalid > maxReportedDocuments {
+		r.add(tb.Info.TableName, "", "%d more invalid documents", invalid-maxReportedDocuments)
+	}
+
+	return nil
+}
+
+var errInvalidDocument = errors.New("invalid document")
+
+// checkDocument ensures a stored document satisfies the constraints of the table.
+// Unlike ValidateDocument, it doesn't convert the document nor generate default values:
+// documents are stored once validated, so their values must already have the right type.
+func checkDocument(tx *Transaction, ti *TableInfo, d types.Document) error {
+	fb := document.NewFieldBuffer()
+	err := fb.Copy(d)
+	if err != nil {
+		return err
+	}
+
+	for _, fc := range ti.FieldConstraints {
+		v, err := fc.Path.GetValueFromDocument(fb)
+		if errors.Is(err, types.ErrFieldNotFound) {
+			if fc.IsNotNull {
+				return &errs.ConstraintViolationError{Constraint: "NOT NULL", Paths: []document.Path{fc.Path}}
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if v.Type() == types.NullValue {
+			if fc.IsNotNull {
+				return &errs.ConstraintViolationError{Constraint: "NOT NULL", Paths: []document.Path{fc.Path}}
+			}
+			continue
+		}
+
+		if !fc.Type.IsAny() && v.Type() != fc.Type {
+			return errors.Wrapf(errInvalidDocument, "field %q must be of type %s, got %s", fc.Path, fc.Type, v.Type())
+		}
+	}
+
+	err = ti.TableConstraints.ValidateDocument(tx, fb)
+	if err != nil {
+		return errors.Wrap(errInvalidDocument, err.Error())
+	}
+
+	return nil
+}
+
+// checkIndex ensures the index contains exactly one entry per document of the table
+// and that unique indexes don't contain duplicates.
+// It returns true if the index has missing or dangling entries, which can be
+// repaired by rebuilding it. Duplicates caused by documents sharing the same values
+// can't be repaired that way.
+func checkIndex(tx *Transaction, tb *Table, info *IndexInfo, r *CheckReport) (bool, error) {
+	ok, err := tx.Tx.StoreExists(info.StoreName)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		r.add(info.TableName, info.IndexName, "store %q not found", info.StoreName)
+		return true, nil
+	}
+
+	idx := NewIndex(tree.New(tx.Tx.GetStore(info.StoreName)), *info)
+
+	// every document must have its entry in the index
+	var missing int
+	err = tb.Tree.IterateOnRange(nil, false, func(key tree.Key, v types.Value) error {
+		entry, err := indexEntry(info, v.V().(types.Document), key)
+		if err != nil {
+			return err
+		}
+
+		ok, err := idx.Tree.Exists(entry)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			missing++
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	// every entry of the index must belong to a document
+	// which still has the indexed values.
+	var dangling, duplicates int
+	var prev []byte
+	err = idx.Tree.IterateOnRange(nil, false, func(k tree.Key, _ types.Value) error {
+		pos := bytes.LastIndex(k, []byte{encoding.ArrayValueDelim})
+		values := k[:pos]
+		enc := encoding.EncodedValue(k[pos+1:])
+		pk := tree.Key(enc.V().([]byte))
+
+		if info.Unique && bytes.Equal(prev, values) {
+			hasNull, err := containsNull(k)
+			if err != nil {
+				return err
+			}
+			// NULL values are never considered equal by unique indexes
+			if !hasNull {
+				duplicates++
+			}
+		}
+		prev = append(prev[:0], values...)
+
+		v, err := tb.Tree.Get(pk)
+		if errors.Is(err, kv.ErrKeyNotFound) {
+			dangling++
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		entry, err := indexEntry(info, v.V().(types.Document), pk)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(entry, k) {
+			dangling++
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if missing > 0 {
+		r.add(info.TableName, info.IndexName, "%d documents have no entry in the index", missing)
+	}
+	if dangling > 0 {
+		r.add(info.TableName, info.IndexName, "%d entries don't match any document", dangling)
+	}
+	if duplicates > 0 {
+		r.add(info.TableName, info.IndexName, "%d duplicate values", duplicates)
+	}
+
+	return missing > 0 || dangling > 0, nil
+}
+
+// indexEntry returns the key of the entry of d in the index, as created by Index.Set.
+func indexEntry(info *IndexInfo, d types.Document, key tree.Key) (tree.Key, error) {
+	vs := make([]types.Value, 0, len(info.Paths)+1)
+	for _, path := range info.Paths {
+		v, err := path.GetValueFromDocument(d)
+		if err != nil {
+			v = types.NewNullValue()
+		}
+		vs = append(vs, v)
+	}
+	vs = append(vs, types.NewBlobValue(key))
+
+	return tree.NewKey(vs...)
+}
+
+// containsNull returns true if one of the indexed values of the entry is NULL.
+func containsNull(entry tree.Key) (bool, error) {
+	values, err := entry.Decode()
+	if err != nil {
+		return false, err
+	}
+
+	// the last value is the primary key
+	for _, v := range values[:len(values)-1] {
+		if v.Type() == types.NullValue {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// rebuildIndex deletes the content of the index, creating its store if necessary,
+// and indexes every document of the table.
+func rebuildIndex(tx *Transaction, tb *Table, info *IndexInfo) error {
+	ok, err := tx.Tx.StoreExists(info.StoreName)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		err = tx.Tx.CreateStore(info.StoreName)
+	} else {
+		err = tx.Tx.GetStore(info.StoreName).Truncate()
+	}
+	if err != nil {
+		return err
+	}
+
+	idx := tree.New(tx.Tx.GetStore(info.StoreName))
+
+	return tb.Tree.IterateOnRange(nil, false, func(key tree.Key, v types.Value) error {
+		entry, err := indexEntry(info, v.V().(types.Document), key)
+		if err != nil {
+			return err
+		}
+
+		return idx.Put(entry, nil)
+	})
+}
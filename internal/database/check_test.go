@@ -0,0 +1,182 @@
+package database_test
+
+import (
+	"testing"
+
+	"github.com/genjidb/genji/document"
+	"github.com/genjidb/genji/internal/database"
+	"github.com/genjidb/genji/internal/testutil"
+	"github.com/genjidb/genji/internal/testutil/assert"
+	"github.com/genjidb/genji/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	setup := func(t *testing.T) (*database.Database, *database.Transaction) {
+		db, tx, cleanup := testutil.NewTestTx(t)
+		t.Cleanup(cleanup)
+
+		testutil.MustExec(t, db, tx, `
+			CREATE TABLE test(a INT PRIMARY KEY, b INT NOT NULL, c TEXT UNIQUE, CHECK(b > 0));
+			CREATE INDEX test_b ON test(b);
+			INSERT INTO test (a, b, c) VALUES (1, 10, 'x1'), (2, 20, 'x2'), (3, 30, 'x3'), (4, 40, NULL), (5, 50, NULL);
+		`)
+
+		return db, tx
+	}
+
+	check := func(t *testing.T, db *database.Database, tx *database.Transaction, repair bool) *database.CheckReport {
+		t.Helper()
+
+		r, err := database.Check(tx, db.Catalog, repair)
+		assert.NoError(t, err)
+		return r
+	}
+
+	problems := func(r *database.CheckReport) []string {
+		var list []string
+		for _, p := range r.Problems {
+			list = append(list, p.String())
+		}
+		return list
+	}
+
+	pk := func(t *testing.T, a int64) []byte {
+		return testutil.NewKey(t, types.NewIntegerValue(a))
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		db, tx := setup(t)
+
+		r := check(t, db, tx, false)
+		require.True(t, r.OK(), problems(r))
+	})
+
+	t.Run("Missing entry", func(t *testing.T) {
+		db, tx := setup(t)
+
+		idx, err := db.Catalog.GetIndex(tx, "test_b")
+		assert.NoError(t, err)
+		err = idx.Delete([]types.Value{types.NewIntegerValue(20)}, pk(t, 2))
+		assert.NoError(t, err)
+
+		r := check(t, db, tx, false)
+		require.Equal(t, []string{"index test_b on table test: 1 documents have no entry in the index"}, problems(r))
+
+		r = check(t, db, tx, true)
+		require.Len(t, r.Problems, 1)
+		require.Equal(t, []string{"test_b"}, r.Repaired)
+
+		r = check(t, db, tx, false)
+		require.True(t, r.OK(), problems(r))
+	})
+
+	t.Run("Dangling entries", func(t *testing.T) {
+		db, tx := setup(t)
+
+		// delete a document without updating the indexes
+		tb, err := db.Catalog.GetTable(tx, "test")
+		assert.NoError(t, err)
+		err = tb.Tree.Delete(pk(t, 1))
+		assert.NoError(t, err)
+
+		// add an entry with outdated values
+		idx, err := db.Catalog.GetIndex(tx, "test_b")
+		assert.NoError(t, err)
+		err = idx.Set([]types.Value{types.NewIntegerValue(25)}, pk(t, 2))
+		assert.NoError(t, err)
+
+		r := check(t, db, tx, false)
+		require.Equal(t, []string{
+			"index test_b on table test: 2 entries don't match any document",
+			"index test_c_idx on table test: 1 entries don't match any document",
+		}, problems(r))
+
+		r = check(t, db, tx, true)
+		require.Equal(t, []string{"test_b", "test_c_idx"}, r.Repaired)
+
+		r = check(t, db, tx, false)
+		require.True(t, r.OK(), problems(r))
+	})
+
+	t.Run("Unique duplicates", func(t *testing.T) {
+		db, tx := setup(t)
+
+		// store a document with a duplicate value without going through the unique index
+		tb, err := db.Catalog.GetTable(tx, "test")
+		assert.NoError(t, err)
+		d := testutil.MakeDocument(t, `{"a": 6, "b": 60, "c": "x1"}`)
+		err = tb.Tree.Put(pk(t, 6), types.NewDocumentValue(d))
+		assert.NoError(t, err)
+
+		r := check(t, db, tx, true)
+		require.Contains(t, problems(r), "index test_c_idx on table test: 1 documents have no entry in the index")
+
+		// rebuilding the index can't remove duplicates
+		r = check(t, db, tx, false)
+		require.Equal(t, []string{"index test_c_idx on table test: 1 duplicate values"}, problems(r))
+	})
+
+	t.Run("Invalid documents", func(t *testing.T) {
+		db, tx := setup(t)
+
+		tb, err := db.Catalog.GetTable(tx, "test")
+		assert.NoError(t, err)
+
+		for i, doc := range []string{
+			`{"a": 1, "c": "x1"}`,
+			`{"a": 2, "b": -20, "c": "x2"}`,
+			`{"a": 3, "b": 30, "c": 3}`,
+		} {
+			fb := document.NewFieldBuffer()
+			err = fb.Copy(testutil.MakeDocument(t, doc))
+			assert.NoError(t, err)
+			// keep the indexes consistent
+			if i == 0 {
+				fb.Add("b", types.NewNullValue())
+			}
+			err = tb.Tree.Put(pk(t, int64(i+1)), types.NewDocumentValue(fb))
+			assert.NoError(t, err)
+		}
+
+		r := check(t, db, tx, false)
+		list := problems(r)
+		require.Contains(t, list, `table test: document [1]: NOT NULL constraint error: [b]`)
+		require.Contains(t, list, `table test: document [2]: document violates check constraint "test_check": invalid document`)
+		require.Contains(t, list, `table test: document [3]: field "c" must be of type text, got integer: invalid document`)
+	})
+
+	t.Run("Missing store", func(t *testing.T) {
+		db, tx := setup(t)
+
+		info, err := db.Catalog.GetIndexInfo("test_b")
+		assert.NoError(t, err)
+		err = tx.Tx.DropStore(info.StoreName)
+		assert.NoError(t, err)
+
+		r := check(t, db, tx, true)
+		require.Len(t, r.Problems, 1)
+		require.Contains(t, r.Problems[0].Message, "not found")
+		require.Equal(t, []string{"test_b"}, r.Repaired)
+
+		r = check(t, db, tx, false)
+		require.True(t, r.OK(), problems(r))
+	})
+
+	t.Run("Catalog table not loaded", func(t *testing.T) {
+		db, tx := setup(t)
+
+		// write to the catalog table without updating the cache
+		err := db.Catalog.CatalogTable.Insert(tx, &database.TableInfo{TableName: "ghost", StoreName: []byte("ghost_store")})
+		assert.NoError(t, err)
+		err = db.Catalog.CatalogTable.Delete(tx, "test_b")
+		assert.NoError(t, err)
+
+		r := check(t, db, tx, true)
+		require.Equal(t, []string{
+			"table ghost: not found in the catalog cache",
+			`table ghost: store "ghost_store" not found`,
+			"index test_b on table test: not found in the catalog table",
+		}, problems(r))
+	})
+}
@@ -4,9 +4,11 @@ package database
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 
 	"github.com/cockroachdb/errors"
 	"github.com/genjidb/genji/internal/kv"
+	"github.com/genjidb/genji/internal/lock"
 )
 
 const (
@@ -14,7 +16,7 @@ const (
 )
 
 type Database struct {
-	ng      *kv.Engine
+	ng      kv.Engine
 	Catalog *Catalog
 
 	// If this is non-nil, the user is running an explicit transaction
@@ -24,12 +26,34 @@ type Database struct {
 	attachedTransaction *Transaction
 	attachedTxMu        sync.Mutex
 
-	// This controls concurrency on read-only and read/write transactions.
+	// Read/write transactions hold this lock in shared mode
+	// while closing the database requires it in exclusive mode.
+	// Read-only transactions are not affected by this lock.
 	txmu *sync.RWMutex
 
+	// LockManager is used by read/write transactions to
+	// lock the objects they modify.
+	LockManager *lock.LockManager
+
+	// ID of the last transaction created by the database.
+	lastTxID uint64
+
+	// ChangeFeed publishes the changes made by committed transactions.
+	ChangeFeed *ChangeFeed
+
+	// ChangeLog durably records the changes made by committed transactions.
+	ChangeLog *ChangeLog
+
 	// Pool of reusable transient engines to use for temporary indices.
 	TransientStorePool *TransientStorePool
 
+	// Metrics counts the operations run by the database.
+	Metrics *Metrics
+
+	// Number of index entries sampled by the ANALYZE statement
+	// to compute the statistics of an index.
+	AnalyzeSampleSize int
+
 	closeOnce sync.Once
 }
 
@@ -44,14 +68,45 @@ type TxOptions struct {
 }
 
 // New initializes the DB using the given engine.
-func New(ctx context.Context, ng *kv.Engine) (*Database, error) {
+func New(ctx context.Context, ng kv.Engine) (*Database, error) {
 	db := Database{
-		ng:      ng,
-		Catalog: NewCatalog(),
-		txmu:    &sync.RWMutex{},
+		ng:          ng,
+		Catalog:     NewCatalog(),
+		txmu:        &sync.RWMutex{},
+		LockManager: lock.NewLockManager(),
+		ChangeFeed:  NewChangeFeed(),
+		ChangeLog:   NewChangeLog(),
+		Metrics:     new(Metrics),
 		TransientStorePool: &TransientStorePool{
 			ng: ng,
 		},
+		AnalyzeSampleSize: DefaultAnalyzeSampleSize,
+	}
+
+	// read-only databases must already be initialized
+	if ng.ReadOnly() {
+		tx, err := db.Begin(false)
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+
+		err = db.Catalog.Init(tx)
+		if err != nil {
+			return nil, err
+		}
+
+		err = db.ChangeLog.load(tx)
+		if err != nil {
+			return nil, err
+		}
+
+		err = db.ChangeFeed.load(tx)
+		if err != nil {
+			return nil, err
+		}
+
+		return &db, nil
 	}
 
 	tx, err := db.Begin(true)
@@ -65,6 +120,16 @@ func New(ctx context.Context, ng *kv.Engine) (*Database, error) {
 		return nil, err
 	}
 
+	err = db.ChangeLog.load(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.ChangeFeed.load(tx)
+	if err != nil {
+		return nil, err
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		return nil, err
@@ -74,7 +139,7 @@ func New(ctx context.Context, ng *kv.Engine) (*Database, error) {
 }
 
 // NewTransientStore creates a temporary store to be used for creating temporary indices.
-func (db *Database) NewTransientStore(ctx context.Context) (*kv.TransientStore, func() error, error) {
+func (db *Database) NewTransientStore(ctx context.Context) (kv.TransientStore, func() error, error) {
 	tdb, err := db.TransientStorePool.Get(context.Background())
 	if err != nil {
 		return nil, nil, err
@@ -85,6 +150,12 @@ func (db *Database) NewTransientStore(ctx context.Context) (*kv.TransientStore,
 	}, nil
 }
 
+// Compact reclaims the space used by the data deleted by committed transactions,
+// for example after dropping a table, and returns the number of bytes reclaimed.
+func (db *Database) Compact() (int64, error) {
+	return db.ng.Compact()
+}
+
 // Close the database.
 func (db *Database) Close() error {
 	var err error
@@ -105,6 +176,11 @@ func (db *Database) closeDatabase() error {
 	db.txmu.Lock()
 	defer db.txmu.Unlock()
 
+	// sequences can't be modified on read-only databases
+	if db.ng.ReadOnly() {
+		return nil
+	}
+
 	// release all sequences
 	tx, err := db.beginTx(context.Background(), nil)
 	if err != nil {
@@ -156,9 +232,9 @@ func (db *Database) BeginTx(ctx context.Context, opts *TxOptions) (*Transaction,
 		opts = new(TxOptions)
 	}
 
+	// read-only transactions read from a snapshot
+	// and don't need to prevent the database from being closed.
 	if !opts.ReadOnly {
-		db.txmu.Lock()
-	} else {
 		db.txmu.RLock()
 	}
 
@@ -166,10 +242,21 @@ func (db *Database) BeginTx(ctx context.Context, opts *TxOptions) (*Transaction,
 	defer db.attachedTxMu.Unlock()
 
 	if db.attachedTransaction != nil {
+		if !opts.ReadOnly {
+			db.txmu.RUnlock()
+		}
 		return nil, errors.New("cannot open a transaction within a transaction")
 	}
 
-	return db.beginTx(ctx, opts)
+	tx, err := db.beginTx(ctx, opts)
+	if err != nil {
+		if !opts.ReadOnly {
+			db.txmu.RUnlock()
+		}
+		return nil, err
+	}
+
+	return tx, nil
 }
 
 // beginTx creates a transaction without locks.
@@ -178,17 +265,50 @@ func (db *Database) beginTx(ctx context.Context, opts *TxOptions) (*Transaction,
 		opts = &TxOptions{}
 	}
 
-	ntx, err := db.ng.Begin(kv.TxOptions{
-		Writable: !opts.ReadOnly,
-	})
-	if err != nil {
+	var ntx kv.Transaction
+	begin := func() (err error) {
+		ntx, err = db.ng.Begin(kv.TxOptions{
+			Writable: !opts.ReadOnly,
+		})
+		return
+	}
+
+	// read/write transactions use the catalog of the database,
+	// read-only transactions the objects committed as of their snapshot.
+	catalog := db.Catalog
+	if opts.ReadOnly {
+		cache, err := db.Catalog.Cache.beginSnapshot(begin)
+		if err != nil {
+			return nil, err
+		}
+
+		catalog = &Catalog{
+			Cache:        cache,
+			CatalogTable: db.Catalog.CatalogTable,
+			Statistics:   db.Catalog.Statistics,
+		}
+	} else if err := begin(); err != nil {
 		return nil, err
 	}
 
 	tx := Transaction{
-		Tx:       ntx,
-		Writable: !opts.ReadOnly,
-		DBMu:     db.txmu,
+		ID:          atomic.AddUint64(&db.lastTxID, 1),
+		Tx:          ntx,
+		Writable:    !opts.ReadOnly,
+		Catalog:     catalog,
+		DBMu:        db.txmu,
+		LockManager: db.LockManager,
+		Metrics:     db.Metrics,
+		ctx:         ctx,
+	}
+	db.Metrics.txStarted(tx.Writable)
+
+	if tx.Writable && db.ChangeFeed.Enabled() {
+		tx.ChangeFeed = db.ChangeFeed
+	}
+
+	if tx.Writable && db.ChangeLog.Enabled() {
+		tx.ChangeLog = db.ChangeLog
 	}
 
 	if opts.Attached {
@@ -1,11 +1,15 @@
 package database_test
 
 import (
+	"context"
 	"testing"
 	"time"
 
 	"github.com/genjidb/genji"
+	"github.com/genjidb/genji/document"
+	errs "github.com/genjidb/genji/errors"
 	"github.com/genjidb/genji/internal/testutil/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // See issue https://github.com/genjidb/genji/issues/298
@@ -67,3 +71,271 @@ func TestConcurrentTransactionManagement(t *testing.T) {
 		t.Fatal("deadlock")
 	}
 }
+
+func TestReadOnlyTransactionsDontWaitForWriters(t *testing.T) {
+	db, err := genji.Open(":memory:")
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, db.Close())
+	}()
+
+	err = db.Exec("CREATE TABLE test; INSERT INTO test (a) VALUES (1)")
+	assert.NoError(t, err)
+
+	wtx, err := db.Begin(true)
+	assert.NoError(t, err)
+	defer wtx.Rollback()
+
+	err = wtx.Exec("INSERT INTO test (a) VALUES (2)")
+	assert.NoError(t, err)
+
+	type result struct {
+		count int64
+		err   error
+	}
+
+	done := make(chan result)
+	go func() {
+		var res result
+		defer func() { done <- res }()
+
+		rtx, err := db.Begin(false)
+		if err != nil {
+			res.err = err
+			return
+		}
+		defer rtx.Rollback()
+
+		d, err := rtx.QueryDocument("SELECT COUNT(*) FROM test")
+		if err != nil {
+			res.err = err
+			return
+		}
+		res.err = document.Scan(d, &res.count)
+	}()
+
+	select {
+	case res := <-done:
+		assert.NoError(t, res.err)
+		require.Equal(t, int64(1), res.count)
+	case <-time.After(time.Second):
+		t.Fatal("read-only transaction blocked by writer")
+	}
+
+	assert.NoError(t, wtx.Commit())
+}
+
+func TestReadOnlyTransactionsCatalogSnapshot(t *testing.T) {
+	db, err := genji.Open(":memory:")
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, db.Close())
+	}()
+
+	err = db.Exec("CREATE TABLE keep; INSERT INTO keep (a) VALUES (1)")
+	assert.NoError(t, err)
+
+	// started before the changes are committed
+	rtx, err := db.Begin(false)
+	assert.NoError(t, err)
+	defer rtx.Rollback()
+
+	wtx, err := db.Begin(true)
+	assert.NoError(t, err)
+	defer wtx.Rollback()
+
+	err = wtx.Exec("CREATE TABLE foo; INSERT INTO foo (a) VALUES (1); DROP TABLE keep")
+	assert.NoError(t, err)
+
+	// uncommitted changes to the catalog are not visible
+	_, err = rtx.QueryDocument("SELECT COUNT(*) FROM foo")
+	require.True(t, errs.IsNotFoundError(err))
+
+	check := func(tx *genji.Tx) {
+		t.Helper()
+
+		var count int64
+		d, err := tx.QueryDocument("SELECT COUNT(*) FROM keep")
+		assert.NoError(t, err)
+		assert.NoError(t, document.Scan(d, &count))
+		require.Equal(t, int64(1), count)
+	}
+
+	check(rtx)
+
+	rtx2, err := db.Begin(false)
+	assert.NoError(t, err)
+	defer rtx2.Rollback()
+	check(rtx2)
+
+	assert.NoError(t, wtx.Commit())
+
+	// transactions started before the commit still see the catalog as of their snapshot
+	check(rtx)
+	_, err = rtx.QueryDocument("SELECT COUNT(*) FROM foo")
+	require.True(t, errs.IsNotFoundError(err))
+
+	// new transactions see the committed changes
+	rtx3, err := db.Begin(false)
+	assert.NoError(t, err)
+	defer rtx3.Rollback()
+
+	_, err = rtx3.QueryDocument("SELECT COUNT(*) FROM keep")
+	require.True(t, errs.IsNotFoundError(err))
+	d, err := rtx3.QueryDocument("SELECT COUNT(*) FROM foo")
+	assert.NoError(t, err)
+	var count int64
+	assert.NoError(t, document.Scan(d, &count))
+	require.Equal(t, int64(1), count)
+}
+
+func TestConcurrentWriters(t *testing.T) {
+	db, err := genji.Open(":memory:")
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, db.Close())
+	}()
+
+	err = db.Exec("CREATE TABLE foo(a INT PRIMARY KEY); CREATE TABLE bar(a INT PRIMARY KEY)")
+	assert.NoError(t, err)
+
+	t.Run("different tables", func(t *testing.T) {
+		tx1, err := db.Begin(true)
+		assert.NoError(t, err)
+		defer tx1.Rollback()
+
+		err = tx1.Exec("INSERT INTO foo (a) VALUES (1)")
+		assert.NoError(t, err)
+
+		done := make(chan error)
+		go func() {
+			tx2, err := db.Begin(true)
+			if err != nil {
+				done <- err
+				return
+			}
+			defer tx2.Rollback()
+
+			err = tx2.Exec("INSERT INTO bar (a) VALUES (1)")
+			if err != nil {
+				done <- err
+				return
+			}
+
+			done <- tx2.Commit()
+		}()
+
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("writer blocked by a writer on another table")
+		}
+
+		assert.NoError(t, tx1.Commit())
+	})
+
+	t.Run("same document", func(t *testing.T) {
+		tx1, err := db.Begin(true)
+		assert.NoError(t, err)
+		defer tx1.Rollback()
+
+		err = tx1.Exec("INSERT INTO foo (a) VALUES (2)")
+		assert.NoError(t, err)
+
+		done := make(chan error)
+		go func() {
+			tx2, err := db.Begin(true)
+			if err != nil {
+				done <- err
+				return
+			}
+			defer tx2.Rollback()
+
+			err = tx2.Exec("INSERT INTO foo (a) VALUES (2)")
+			if err != nil {
+				done <- err
+				return
+			}
+
+			done <- tx2.Commit()
+		}()
+
+		select {
+		case <-done:
+			t.Fatal("writer should wait for the lock to be released")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		assert.NoError(t, tx1.Commit())
+
+		// once the first transaction is committed, the second one sees the document
+		err = <-done
+		assert.Error(t, err)
+	})
+}
+
+func TestDeadlockDetection(t *testing.T) {
+	db, err := genji.Open(":memory:")
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, db.Close())
+	}()
+
+	err = db.Exec("CREATE TABLE foo(a INT PRIMARY KEY)")
+	assert.NoError(t, err)
+
+	tx1, err := db.Begin(true)
+	assert.NoError(t, err)
+	defer tx1.Rollback()
+
+	tx2, err := db.Begin(true)
+	assert.NoError(t, err)
+	defer tx2.Rollback()
+
+	assert.NoError(t, tx1.Exec("INSERT INTO foo (a) VALUES (1)"))
+	assert.NoError(t, tx2.Exec("INSERT INTO foo (a) VALUES (2)"))
+
+	done := make(chan error)
+	go func() {
+		// waits for tx2
+		done <- tx1.Exec("INSERT INTO foo (a) VALUES (2)")
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	// waiting for tx1 would cause a deadlock
+	err = tx2.Exec("INSERT INTO foo (a) VALUES (1)")
+	require.True(t, errs.IsDeadlockError(err))
+	assert.NoError(t, tx2.Rollback())
+
+	assert.NoError(t, <-done)
+	assert.NoError(t, tx1.Commit())
+}
+
+func TestLockTimeout(t *testing.T) {
+	db, err := genji.Open(":memory:")
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, db.Close())
+	}()
+
+	err = db.Exec("CREATE TABLE foo(a INT PRIMARY KEY)")
+	assert.NoError(t, err)
+
+	tx1, err := db.Begin(true)
+	assert.NoError(t, err)
+	defer tx1.Rollback()
+
+	assert.NoError(t, tx1.Exec("INSERT INTO foo (a) VALUES (1)"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	tx2, err := db.WithContext(ctx).Begin(true)
+	assert.NoError(t, err)
+	defer tx2.Rollback()
+
+	err = tx2.Exec("INSERT INTO foo (a) VALUES (1)")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
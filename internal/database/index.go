@@ -164,17 +164,24 @@ func (idx *Index) IterateOnRange(rng *tree.Range, reverse bool, fn func(key tree
 		panic("range cannot be empty")
 	}
 
+	return idx.IterateEntriesOnRange(rng, reverse, func(itmKey, key tree.Key) error {
+		return fn(key)
+	})
+}
+
+// IterateEntriesOnRange works like IterateOnRange but also passes the key of the index entry
+// to fn, which can be used to check later if the entry still exists.
+// If rng is nil, it iterates over the whole index.
+func (idx *Index) IterateEntriesOnRange(rng *tree.Range, reverse bool, fn func(itmKey tree.Key, key tree.Key) error) error {
 	// if one of the boundaries is nil, ensure the iteration only returns
 	// keys of the same type as the other boundary's first value.
-	if rng.Min == nil {
+	if rng != nil && rng.Min == nil && rng.Max != nil {
 		rng.Min = tree.NewMinKeyForType(types.ValueType(rng.Max[0]))
-	} else if rng.Max == nil {
+	} else if rng != nil && rng.Max == nil && rng.Min != nil {
 		rng.Max = tree.NewMaxKeyForType(types.ValueType(rng.Min[0]))
 	}
 
-	return idx.iterateOnRange(rng, reverse, func(itmKey, key tree.Key) error {
-		return fn(key)
-	})
+	return idx.iterateOnRange(rng, reverse, fn)
 }
 
 func (idx *Index) Iterate(reverse bool, fn func(key tree.Key) error) error {
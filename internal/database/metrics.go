@@ -0,0 +1,276 @@
+package database
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	errs "github.com/genjidb/genji/errors"
+	"github.com/genjidb/genji/internal/kv"
+)
+
+// Metrics counts the operations run by a database since it was opened.
+// Counters are updated atomically and can be read at any time.
+type Metrics struct {
+	// read/write transactions started, committed and rolled back.
+	TxStarted    int64
+	TxCommitted  int64
+	TxRolledBack int64
+	// read-only transactions started.
+	TxReadOnly int64
+
+	// number of statements executed successfully, by type.
+	statements sync.Map
+	// metrics of every table, by name.
+	tables sync.Map
+}
+
+// TableMetrics counts the documents read and written in a table.
+type TableMetrics struct {
+	DocumentsRead    int64
+	DocumentsWritten int64
+}
+
+func (t *TableMetrics) read() {
+	if t != nil {
+		atomic.AddInt64(&t.DocumentsRead, 1)
+	}
+}
+
+func (t *TableMetrics) written() {
+	if t != nil {
+		atomic.AddInt64(&t.DocumentsWritten, 1)
+	}
+}
+
+// tableMetrics returns the counters of the documents read and written in the given table
+// by the transaction. They are added to the metrics of the database when the transaction ends,
+// the documents written only if it is committed.
+func (tx *Transaction) tableMetrics(name string) *TableMetrics {
+	if tx.Metrics == nil {
+		return nil
+	}
+
+	t, ok := tx.tables[name]
+	if !ok {
+		if tx.tables == nil {
+			tx.tables = make(map[string]*TableMetrics)
+		}
+		t = new(TableMetrics)
+		tx.tables[name] = t
+	}
+
+	return t
+}
+
+// documentsWritten returns the number of documents written by the transaction, by table.
+func (tx *Transaction) documentsWritten() map[string]int64 {
+	if len(tx.tables) == 0 {
+		return nil
+	}
+
+	written := make(map[string]int64, len(tx.tables))
+	for name, t := range tx.tables {
+		written[name] = atomic.LoadInt64(&t.DocumentsWritten)
+	}
+
+	return written
+}
+
+// recordTableMetrics adds the documents read and written by the transaction
+// to the metrics of the database. Written documents are only added if the
+// transaction was committed.
+func (tx *Transaction) recordTableMetrics(committed bool) {
+	for name, t := range tx.tables {
+		m := tx.Metrics.Table(name)
+		atomic.AddInt64(&m.DocumentsRead, atomic.LoadInt64(&t.DocumentsRead))
+		if committed {
+			atomic.AddInt64(&m.DocumentsWritten, atomic.LoadInt64(&t.DocumentsWritten))
+		}
+	}
+
+	tx.tables = nil
+}
+
+// RecordStatement increments the number of statements of the given type.
+func (m *Metrics) RecordStatement(typ string) {
+	if m == nil {
+		return
+	}
+
+	v, ok := m.statements.Load(typ)
+	if !ok {
+		v, _ = m.statements.LoadOrStore(typ, new(int64))
+	}
+
+	atomic.AddInt64(v.(*int64), 1)
+}
+
+// Table returns the metrics of the given table.
+func (m *Metrics) Table(name string) *TableMetrics {
+	if m == nil {
+		return nil
+	}
+
+	v, ok := m.tables.Load(name)
+	if !ok {
+		v, _ = m.tables.LoadOrStore(name, new(TableMetrics))
+	}
+
+	return v.(*TableMetrics)
+}
+
+// dropTable deletes the metrics of a table once the transaction
+// dropping it is committed, so that a new table with the same name starts from zero.
+func (m *Metrics) dropTable(tx *Transaction, name string) {
+	if m == nil {
+		return
+	}
+
+	tx.OnCommitHooks = append(tx.OnCommitHooks, func() {
+		m.tables.Delete(name)
+	})
+}
+
+// renameTable moves the metrics of a table to its new name once
+// the transaction renaming it is committed. They are added to the documents
+// read and written by the transaction after the table was renamed.
+func (m *Metrics) renameTable(tx *Transaction, oldName, newName string) {
+	if m == nil {
+		return
+	}
+
+	tx.OnCommitHooks = append(tx.OnCommitHooks, func() {
+		v, ok := m.tables.LoadAndDelete(oldName)
+		if !ok {
+			return
+		}
+		old := v.(*TableMetrics)
+
+		t := m.Table(newName)
+		atomic.AddInt64(&t.DocumentsRead, atomic.LoadInt64(&old.DocumentsRead))
+		atomic.AddInt64(&t.DocumentsWritten, atomic.LoadInt64(&old.DocumentsWritten))
+	})
+}
+
+func (m *Metrics) txStarted(writable bool) {
+	switch {
+	case m == nil:
+	case writable:
+		atomic.AddInt64(&m.TxStarted, 1)
+	default:
+		atomic.AddInt64(&m.TxReadOnly, 1)
+	}
+}
+
+func (m *Metrics) txCommitted() {
+	if m != nil {
+		atomic.AddInt64(&m.TxCommitted, 1)
+	}
+}
+
+func (m *Metrics) txRolledBack() {
+	if m != nil {
+		atomic.AddInt64(&m.TxRolledBack, 1)
+	}
+}
+
+// Stats describes the state of the database.
+type Stats struct {
+	// Metrics of the kv engine.
+	Engine kv.Stats
+
+	// Number of read/write transactions started, committed and rolled back.
+	TxStarted    int64
+	TxCommitted  int64
+	TxRolledBack int64
+	// Number of read-only transactions started.
+	TxReadOnly int64
+
+	// Number of statements executed successfully, by type.
+	Statements map[string]int64
+
+	TransientStores TransientStoreStats
+
+	// Statistics of every table, sorted by name.
+	Tables []TableStats
+}
+
+// TableStats describes the usage of a table and of its indexes.
+type TableStats struct {
+	TableName        string
+	DocumentsRead    int64
+	DocumentsWritten int64
+	// Estimated size of the table on disk, in bytes, excluding its indexes.
+	Size uint64
+	// Statistics of the indexes of the table, sorted by name.
+	Indexes []IndexStats
+}
+
+// IndexStats describes the usage of an index.
+type IndexStats struct {
+	IndexName string
+	// Estimated size of the index on disk, in bytes.
+	Size uint64
+}
+
+// Stats returns the metrics of the engine, the counters of the database
+// and the estimated size of every table and index.
+func (db *Database) Stats() (*Stats, error) {
+	s := Stats{
+		Engine:          db.ng.Stats(),
+		TxStarted:       atomic.LoadInt64(&db.Metrics.TxStarted),
+		TxCommitted:     atomic.LoadInt64(&db.Metrics.TxCommitted),
+		TxRolledBack:    atomic.LoadInt64(&db.Metrics.TxRolledBack),
+		TxReadOnly:      atomic.LoadInt64(&db.Metrics.TxReadOnly),
+		Statements:      make(map[string]int64),
+		TransientStores: db.TransientStorePool.Stats(),
+	}
+
+	db.Metrics.statements.Range(func(k, v interface{}) bool {
+		s.Statements[k.(string)] = atomic.LoadInt64(v.(*int64))
+		return true
+	})
+
+	for _, name := range db.Catalog.Cache.ListObjects(RelationTableType) {
+		info, err := db.Catalog.GetTableInfo(name)
+		if errs.IsNotFoundError(err) {
+			// the table was dropped concurrently
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		m := db.Metrics.Table(name)
+		ts := TableStats{
+			TableName:        name,
+			DocumentsRead:    atomic.LoadInt64(&m.DocumentsRead),
+			DocumentsWritten: atomic.LoadInt64(&m.DocumentsWritten),
+		}
+
+		ts.Size, err = db.ng.StoreSize(info.StoreName)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, idx := range db.Catalog.Cache.GetTableIndexes(name) {
+			size, err := db.ng.StoreSize(idx.StoreName)
+			if err != nil {
+				return nil, err
+			}
+
+			ts.Indexes = append(ts.Indexes, IndexStats{
+				IndexName: idx.IndexName,
+				Size:      size,
+			})
+		}
+		sort.Slice(ts.Indexes, func(i, j int) bool {
+			return ts.Indexes[i].IndexName < ts.Indexes[j].IndexName
+		})
+
+		s.Tables = append(s.Tables, ts)
+	}
+
+	return &s, nil
+}
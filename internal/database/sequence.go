@@ -3,10 +3,12 @@ package database
 import (
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/cockroachdb/errors"
 	"github.com/genjidb/genji/document"
 	errs "github.com/genjidb/genji/errors"
+	"github.com/genjidb/genji/internal/lock"
 	"github.com/genjidb/genji/internal/tree"
 	"github.com/genjidb/genji/types"
 )
@@ -47,8 +49,10 @@ var sequenceTableInfo = &TableInfo{
 }
 
 // A Sequence manages a sequence of numbers.
-// It is not thread safe.
+// It is safe for concurrent use by multiple transactions.
 type Sequence struct {
+	mu sync.Mutex
+
 	Info *SequenceInfo
 
 	CurrentValue *int64
@@ -59,18 +63,18 @@ type Sequence struct {
 // NewSequence creates a new or existing sequence. If currentValue is not nil
 // next call to Next will increase the lease.
 func NewSequence(info *SequenceInfo, currentValue *int64) Sequence {
-	seq := Sequence{
-		Info:         info,
-		CurrentValue: currentValue,
-	}
-
 	// currentValue is not nil, the sequence already exists in the database
 	// and the lease needs to be extended.
+	var cached uint64
 	if currentValue != nil {
-		seq.Cached = seq.Info.Cache
+		cached = info.Cache
 	}
 
-	return seq
+	return Sequence{
+		Info:         info,
+		CurrentValue: currentValue,
+		Cached:       cached,
+	}
 }
 
 func (s *Sequence) key() (tree.Key, error) {
@@ -116,6 +120,35 @@ func (s *Sequence) Next(tx *Transaction, catalog *Catalog) (int64, error) {
 		return 0, errors.New("cannot increment sequence on read-only transaction")
 	}
 
+	// the lease is stored in the sequence table, which might be
+	// modified by other transactions: before increasing it, the transaction
+	// must lock the sequence document.
+	// The lock is acquired without holding the mutex, to avoid blocking
+	// transactions that only use cached values.
+	for {
+		s.mu.Lock()
+		if s.CurrentValue != nil && s.Cached+1 <= s.Info.Cache {
+			break
+		}
+
+		k, err := s.key()
+		if err != nil {
+			s.mu.Unlock()
+			return 0, err
+		}
+		obj := lock.NewDocumentObject(SequenceTableName, k)
+		if tx.HoldsLock(obj, lock.X) {
+			break
+		}
+		s.mu.Unlock()
+
+		err = tx.Lock(obj, lock.X)
+		if err != nil {
+			return 0, err
+		}
+	}
+	defer s.mu.Unlock()
+
 	var newValue int64
 	if s.CurrentValue == nil {
 		newValue = s.Info.Start
@@ -174,6 +207,14 @@ func (s *Sequence) Next(tx *Transaction, catalog *Catalog) (int64, error) {
 		return 0, err
 	}
 
+	// if the lease is rolled back, values generated from it are no longer
+	// covered by the stored lease: force the next call to store a new one.
+	tx.OnRollbackHooks = append(tx.OnRollbackHooks, func() {
+		s.mu.Lock()
+		s.Cached = s.Info.Cache
+		s.mu.Unlock()
+	})
+
 	s.CurrentValue = &newValue
 	return newValue, nil
 }
@@ -237,6 +278,9 @@ func (s *Sequence) GenerateBaseName() string {
 // Release the sequence by storing the actual current value to the sequence table.
 // If the sequence has cache, the cached value is overwritten.
 func (s *Sequence) Release(tx *Transaction, catalog *Catalog) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	if s.CurrentValue == nil {
 		return nil
 	}
@@ -251,6 +295,9 @@ func (s *Sequence) Release(tx *Transaction, catalog *Catalog) error {
 }
 
 func (s *Sequence) Clone() *Sequence {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	return &Sequence{
 		Info:         s.Info.Clone(),
 		CurrentValue: s.CurrentValue,
@@ -0,0 +1,693 @@
+package database
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/genjidb/genji/document"
+	errs "github.com/genjidb/genji/errors"
+	"github.com/genjidb/genji/internal/lock"
+	"github.com/genjidb/genji/internal/tree"
+	"github.com/genjidb/genji/types"
+)
+
+const (
+	StatisticsTableName = InternalPrefix + "statistics"
+
+	// DefaultHistogramBuckets is the maximum number of buckets
+	// of the histograms computed by AnalyzeTable.
+	DefaultHistogramBuckets = 100
+
+	// DefaultAnalyzeSampleSize is the default number of index entries
+	// sampled by AnalyzeTable to compute the statistics of an index.
+	DefaultAnalyzeSampleSize = 30000
+)
+
+var statisticsTableInfo = &TableInfo{
+	TableName: StatisticsTableName,
+	StoreName: []byte(StatisticsTableName),
+	FieldConstraints: []*FieldConstraint{
+		{
+			Path: document.Path{
+				document.PathFragment{
+					FieldName: "table_name",
+				},
+			},
+			Type: types.TextValue,
+		},
+		{
+			Path: document.Path{
+				document.PathFragment{
+					FieldName: "row_count",
+				},
+			},
+			Type: types.IntegerValue,
+		},
+		{
+			Path: document.Path{
+				document.PathFragment{
+					FieldName: "indexes",
+				},
+			},
+			Type: types.ArrayValue,
+		},
+	},
+	TableConstraints: []*TableConstraint{
+		{
+			Paths: []document.Path{
+				document.NewPath("table_name"),
+			},
+			PrimaryKey: true,
+		},
+	},
+}
+
+// TableStatistics describes the content of a table and of its indexes.
+// They are computed by the ANALYZE statement and are used by the planner
+// to estimate the cost of a query.
+type TableStatistics struct {
+	TableName string
+	// Number of documents in the table.
+	RowCount int64
+	// Statistics of every index of the table, by index name.
+	Indexes map[string]*IndexStatistics
+}
+
+// IndexStatistics describes the distribution of the values of an index.
+type IndexStatistics struct {
+	IndexName string
+	// DistinctCount[i] is the number of distinct combinations
+	// of the values of the first i+1 indexed paths.
+	DistinctCount []int64
+	// Histogram of the values of the first indexed path.
+	Histogram Histogram
+}
+
+// A Histogram describes the distribution of a set of values
+// as a list of buckets sorted by upper bound.
+// Each bucket holds roughly the same number of values.
+type Histogram []Bucket
+
+// A Bucket contains the values lower than or equal to its upper bound
+// and greater than the upper bound of the previous bucket.
+type Bucket struct {
+	UpperBound types.Value
+	// Number of values in the bucket.
+	Count int64
+	// Number of distinct values in the bucket.
+	Distinct int64
+}
+
+// AnalyzeTable reads the content of a table and of its indexes
+// and computes their statistics.
+// The statistics of an index are computed from a random sample of at most sampleSize of its entries,
+// and extrapolated to the whole index.
+func AnalyzeTable(tx *Transaction, catalog *Catalog, tableName string, sampleSize int) (*TableStatistics, error) {
+	if sampleSize <= 0 {
+		return nil, errors.Errorf("invalid sample size %d", sampleSize)
+	}
+
+	tb, err := catalog.GetTable(tx, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := TableStatistics{
+		TableName: tableName,
+		Indexes:   make(map[string]*IndexStatistics),
+	}
+
+	err = tb.Tree.IterateOnRange(nil, false, func(tree.Key, types.Value) error {
+		stats.RowCount++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range catalog.ListIndexes(tableName) {
+		idx, err := catalog.GetIndex(tx, name)
+		if err != nil {
+			return nil, err
+		}
+
+		is, err := analyzeIndex(idx, DefaultHistogramBuckets, sampleSize)
+		if err != nil {
+			return nil, err
+		}
+		is.IndexName = name
+		stats.Indexes[name] = is
+	}
+
+	return &stats, nil
+}
+
+// sampleIndex selects sampleSize entries of the index at random using reservoir sampling,
+// and returns them sorted, along with the total number of entries.
+// Entries are only copied when selected and are never decoded.
+func sampleIndex(idx *Index, sampleSize int) ([]tree.Key, int64, error) {
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	var sample []tree.Key
+	var total int64
+
+	err := idx.Tree.IterateOnRange(nil, false, func(k tree.Key, _ types.Value) error {
+		total++
+
+		if len(sample) < sampleSize {
+			sample = append(sample, append(tree.Key(nil), k...))
+			return nil
+		}
+
+		if i := rnd.Int63n(total); i < int64(sampleSize) {
+			sample[i] = append(sample[i][:0], k...)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sort.Slice(sample, func(i, j int) bool {
+		return bytes.Compare(sample[i], sample[j]) < 0
+	})
+
+	return sample, total, nil
+}
+
+// analyzeIndex samples the index and builds an equi-depth histogram of its first path
+// from the sorted sample: buckets are closed once they contain at least
+// len(sample) / maxBuckets values, without ever splitting identical values across two buckets.
+// If the sample doesn't contain every entry, the statistics are extrapolated to the whole index.
+func analyzeIndex(idx *Index, maxBuckets, sampleSize int) (*IndexStatistics, error) {
+	sample, total, err := sampleIndex(idx, sampleSize)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := IndexStatistics{
+		DistinctCount: make([]int64, idx.Arity),
+	}
+
+	n := int64(len(sample))
+	depth := n / int64(maxBuckets)
+	if n%int64(maxBuckets) != 0 {
+		depth++
+	}
+
+	prev := make([][]byte, idx.Arity)
+	// number of consecutive entries sharing the current prefix of each length,
+	// and number of prefixes found only once in the sample
+	runs := make([]int64, idx.Arity)
+	singles := make([]int64, idx.Arity)
+	var cur Bucket
+
+	for _, k := range sample {
+		values, err := k.Decode()
+		if err != nil {
+			return nil, err
+		}
+
+		// compare the encoded prefixes with the ones of the previous entry:
+		// once a prefix differs, all the longer ones differ too
+		changed := false
+		firstChanged := false
+		for i := 0; i < idx.Arity; i++ {
+			prefix, err := tree.NewKey(values[:i+1]...)
+			if err != nil {
+				return nil, err
+			}
+
+			if changed || !bytes.Equal(prefix, prev[i]) {
+				if i == 0 {
+					firstChanged = true
+				}
+				changed = true
+				stats.DistinctCount[i]++
+				prev[i] = prefix
+
+				if runs[i] == 1 {
+					singles[i]++
+				}
+				runs[i] = 0
+			}
+			runs[i]++
+		}
+
+		if firstChanged {
+			if cur.Count >= depth {
+				stats.Histogram = append(stats.Histogram, cur)
+				cur = Bucket{}
+			}
+
+			cur.Distinct++
+			cur.UpperBound = values[0]
+		}
+		cur.Count++
+	}
+
+	for i := range runs {
+		if runs[i] == 1 {
+			singles[i]++
+		}
+	}
+
+	if cur.Count > 0 {
+		stats.Histogram = append(stats.Histogram, cur)
+	}
+
+	if total > n {
+		extrapolateStatistics(&stats, singles, n, total)
+	}
+
+	return &stats, nil
+}
+
+// extrapolateStatistics scales the statistics computed from a sample of n entries
+// to an index of total entries.
+// The number of distinct values is estimated using the Duj1 estimator of Haas and Stokes,
+// based on the number of values found only once in the sample.
+func extrapolateStatistics(stats *IndexStatistics, singles []int64, n, total int64) {
+	ratio := float64(total) / float64(n)
+
+	distinctRatio := 1.0
+	for i, d := range stats.DistinctCount {
+		f1 := float64(singles[i])
+		estimate := float64(n) * float64(d) / (float64(n) - f1 + f1/ratio)
+		estimate = math.Min(math.Round(estimate), float64(total))
+
+		if i == 0 && d > 0 {
+			distinctRatio = estimate / float64(d)
+		}
+		stats.DistinctCount[i] = int64(estimate)
+	}
+
+	for i := range stats.Histogram {
+		b := &stats.Histogram[i]
+		b.Count = int64(math.Round(float64(b.Count) * ratio))
+		b.Distinct = int64(math.Round(float64(b.Distinct) * distinctRatio))
+		if b.Distinct > b.Count {
+			b.Distinct = b.Count
+		}
+	}
+}
+
+// GetTableStatistics returns the statistics of the given table.
+// If the table was never analyzed, it returns a NotFoundError.
+func (c *Catalog) GetTableStatistics(tableName string) (*TableStatistics, error) {
+	return c.Statistics.get(nil, tableName)
+}
+
+// GetIndexStatistics returns the statistics of the given index.
+// If the index was never analyzed, it returns a NotFoundError.
+func (c *Catalog) GetIndexStatistics(indexName string) (*IndexStatistics, error) {
+	info, err := c.GetIndexInfo(indexName)
+	if err != nil {
+		return nil, err
+	}
+
+	ts, err := c.Statistics.get(nil, info.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	is, ok := ts.Indexes[indexName]
+	if !ok {
+		return nil, errors.WithStack(errs.NotFoundError{Name: indexName})
+	}
+
+	return is, nil
+}
+
+// SetTableStatistics stores the statistics of a table, replacing any previous statistics.
+func (c *Catalog) SetTableStatistics(tx *Transaction, stats *TableStatistics) error {
+	tb, err := c.getOrCreateStatisticsTable(tx)
+	if err != nil {
+		return err
+	}
+
+	key, err := tree.NewKey(types.NewTextValue(stats.TableName))
+	if err != nil {
+		return err
+	}
+
+	err = tx.Lock(lock.NewDocumentObject(StatisticsTableName, key), lock.X)
+	if err != nil {
+		return err
+	}
+
+	d := statisticsToDocument(stats)
+	exists, err := tb.Tree.Exists(key)
+	if err != nil {
+		return err
+	}
+	if exists {
+		_, err = tb.Replace(key, d)
+	} else {
+		_, err = tb.InsertWithKey(key, d)
+	}
+	if err != nil {
+		return err
+	}
+
+	c.Statistics.set(tx, stats.TableName, stats)
+	return nil
+}
+
+// deleteTableStatistics deletes the statistics of a table, if any.
+func (c *Catalog) deleteTableStatistics(tx *Transaction, tableName string) error {
+	if _, err := c.Statistics.get(tx, tableName); err != nil {
+		return nil
+	}
+
+	tb, err := c.GetTable(tx, StatisticsTableName)
+	if err != nil {
+		return err
+	}
+
+	key, err := tree.NewKey(types.NewTextValue(tableName))
+	if err != nil {
+		return err
+	}
+
+	err = tb.Delete(key)
+	if err != nil && !errors.Is(err, errs.ErrDocumentNotFound) {
+		return err
+	}
+
+	c.Statistics.set(tx, tableName, nil)
+	return nil
+}
+
+// deleteIndexStatistics removes the statistics of an index from the statistics of its table, if any.
+func (c *Catalog) deleteIndexStatistics(tx *Transaction, info *IndexInfo) error {
+	ts, err := c.Statistics.get(tx, info.TableName)
+	if err != nil {
+		return nil
+	}
+	if _, ok := ts.Indexes[info.IndexName]; !ok {
+		return nil
+	}
+
+	clone := *ts
+	clone.Indexes = make(map[string]*IndexStatistics, len(ts.Indexes))
+	for name, is := range ts.Indexes {
+		if name != info.IndexName {
+			clone.Indexes[name] = is
+		}
+	}
+
+	return c.SetTableStatistics(tx, &clone)
+}
+
+// renameTableStatistics moves the statistics of a table to its new name, if any.
+func (c *Catalog) renameTableStatistics(tx *Transaction, oldName, newName string) error {
+	ts, err := c.Statistics.get(tx, oldName)
+	if err != nil {
+		return nil
+	}
+
+	err = c.deleteTableStatistics(tx, oldName)
+	if err != nil {
+		return err
+	}
+
+	clone := *ts
+	clone.TableName = newName
+	return c.SetTableStatistics(tx, &clone)
+}
+
+func (c *Catalog) getOrCreateStatisticsTable(tx *Transaction) (*Table, error) {
+	tb, err := c.GetTable(tx, StatisticsTableName)
+	if err == nil || !errs.IsNotFoundError(err) {
+		return tb, err
+	}
+
+	err = c.CreateTable(tx, StatisticsTableName, statisticsTableInfo.Clone())
+	if err != nil {
+		return nil, err
+	}
+
+	return c.GetTable(tx, StatisticsTableName)
+}
+
+// LoadStatistics loads the statistics stored in the statistics table, if it exists,
+// replacing the statistics held in memory.
+func (c *Catalog) LoadStatistics(tx *Transaction) error {
+	stats := make(map[string]*TableStatistics)
+
+	tb, err := c.GetTable(tx, StatisticsTableName)
+	if err != nil && !errs.IsNotFoundError(err) {
+		return err
+	}
+
+	if err == nil {
+		err = tb.IterateOnRange(nil, false, func(_ tree.Key, d types.Document) error {
+			ts, err := statisticsFromDocument(d)
+			if err != nil {
+				return err
+			}
+
+			stats[ts.TableName] = ts
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	c.Statistics.mu.Lock()
+	c.Statistics.tables = stats
+	c.Statistics.mu.Unlock()
+
+	return nil
+}
+
+// statisticsCache holds the statistics of every analyzed table.
+// It is shared by all transactions, but the statistics modified by a transaction
+// are only visible to the others once it is committed.
+type statisticsCache struct {
+	mu     sync.RWMutex
+	tables map[string]*TableStatistics
+	// statistics modified by uncommitted transactions, by transaction id.
+	// A nil value means the statistics were deleted.
+	pending map[uint64]map[string]*TableStatistics
+}
+
+func newStatisticsCache() *statisticsCache {
+	return &statisticsCache{
+		tables:  make(map[string]*TableStatistics),
+		pending: make(map[uint64]map[string]*TableStatistics),
+	}
+}
+
+// get returns the statistics of a table, as seen by tx.
+// If tx is nil, only committed statistics are returned.
+func (s *statisticsCache) get(tx *Transaction, tableName string) (*TableStatistics, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ts, ok := s.tables[tableName]
+	if tx != nil {
+		if pts, found := s.pending[tx.ID][tableName]; found {
+			ts, ok = pts, pts != nil
+		}
+	}
+	if !ok {
+		return nil, errors.WithStack(errs.NotFoundError{Name: tableName})
+	}
+
+	return ts, nil
+}
+
+// set replaces the statistics of a table, or deletes them if stats is nil.
+// The new statistics are only visible to tx until it is committed,
+// and are discarded if it is rolled back.
+func (s *statisticsCache) set(tx *Transaction, tableName string, stats *TableStatistics) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending, ok := s.pending[tx.ID]
+	if !ok {
+		pending = make(map[string]*TableStatistics)
+		s.pending[tx.ID] = pending
+	}
+	old, hadOld := pending[tableName]
+	pending[tableName] = stats
+
+	tx.OnRollbackHooks = append(tx.OnRollbackHooks, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		pending := s.pending[tx.ID]
+		if hadOld {
+			pending[tableName] = old
+		} else {
+			delete(pending, tableName)
+		}
+		if len(pending) == 0 {
+			delete(s.pending, tx.ID)
+		}
+	})
+
+	tx.OnCommitHooks = append(tx.OnCommitHooks, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		// the last statistics of the table are installed
+		// by the first hook, the others have nothing to do
+		pending := s.pending[tx.ID]
+		stats, ok := pending[tableName]
+		if !ok {
+			return
+		}
+
+		if stats == nil {
+			delete(s.tables, tableName)
+		} else {
+			s.tables[tableName] = stats
+		}
+
+		delete(pending, tableName)
+		if len(pending) == 0 {
+			delete(s.pending, tx.ID)
+		}
+	})
+}
+
+func statisticsToDocument(ts *TableStatistics) types.Document {
+	buf := document.NewFieldBuffer()
+	buf.Add("table_name", types.NewTextValue(ts.TableName))
+	buf.Add("row_count", types.NewIntegerValue(ts.RowCount))
+
+	indexes := document.NewValueBuffer()
+	for _, name := range sortedIndexNames(ts) {
+		is := ts.Indexes[name]
+
+		distinct := document.NewValueBuffer()
+		for _, n := range is.DistinctCount {
+			distinct.Values = append(distinct.Values, types.NewIntegerValue(n))
+		}
+
+		histogram := document.NewValueBuffer()
+		for _, b := range is.Histogram {
+			histogram.Values = append(histogram.Values, types.NewDocumentValue(document.NewFieldBuffer().
+				Add("upper_bound", b.UpperBound).
+				Add("count", types.NewIntegerValue(b.Count)).
+				Add("distinct", types.NewIntegerValue(b.Distinct))))
+		}
+
+		indexes.Values = append(indexes.Values, types.NewDocumentValue(document.NewFieldBuffer().
+			Add("index_name", types.NewTextValue(name)).
+			Add("distinct", types.NewArrayValue(distinct)).
+			Add("histogram", types.NewArrayValue(histogram))))
+	}
+	buf.Add("indexes", types.NewArrayValue(indexes))
+
+	return buf
+}
+
+func statisticsFromDocument(d types.Document) (*TableStatistics, error) {
+	var ts TableStatistics
+	var err error
+
+	v, err := d.GetByField("table_name")
+	if err != nil {
+		return nil, err
+	}
+	ts.TableName = v.V().(string)
+
+	v, err = d.GetByField("row_count")
+	if err != nil {
+		return nil, err
+	}
+	ts.RowCount = v.V().(int64)
+
+	v, err = d.GetByField("indexes")
+	if err != nil {
+		return nil, err
+	}
+
+	ts.Indexes = make(map[string]*IndexStatistics)
+	err = v.V().(types.Array).Iterate(func(_ int, v types.Value) error {
+		d := v.V().(types.Document)
+
+		var is IndexStatistics
+		v, err := d.GetByField("index_name")
+		if err != nil {
+			return err
+		}
+		is.IndexName = v.V().(string)
+
+		v, err = d.GetByField("distinct")
+		if err != nil {
+			return err
+		}
+		err = v.V().(types.Array).Iterate(func(_ int, v types.Value) error {
+			is.DistinctCount = append(is.DistinctCount, v.V().(int64))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		v, err = d.GetByField("histogram")
+		if err != nil {
+			return err
+		}
+		err = v.V().(types.Array).Iterate(func(_ int, v types.Value) error {
+			d := v.V().(types.Document)
+
+			var b Bucket
+			b.UpperBound, err = d.GetByField("upper_bound")
+			if err != nil {
+				return err
+			}
+			b.UpperBound, err = document.CloneValue(b.UpperBound)
+			if err != nil {
+				return err
+			}
+
+			v, err := d.GetByField("count")
+			if err != nil {
+				return err
+			}
+			b.Count = v.V().(int64)
+
+			v, err = d.GetByField("distinct")
+			if err != nil {
+				return err
+			}
+			b.Distinct = v.V().(int64)
+
+			is.Histogram = append(is.Histogram, b)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		ts.Indexes[is.IndexName] = &is
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ts, nil
+}
+
+func sortedIndexNames(ts *TableStatistics) []string {
+	names := make([]string, 0, len(ts.Indexes))
+	for name := range ts.Indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
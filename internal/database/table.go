@@ -21,6 +21,9 @@ type Table struct {
 	Info *TableInfo
 
 	Catalog *Catalog
+
+	// counts the documents read and written, if not nil.
+	metrics *TableMetrics
 }
 
 // Truncate deletes all the documents from the table.
@@ -34,22 +37,34 @@ func (t *Table) Truncate() error {
 // If no primary key has been selected, a monotonic autoincremented integer key will be generated.
 // It returns the inserted document alongside its key.
 func (t *Table) Insert(d types.Document) (tree.Key, types.Document, error) {
-	if t.Info.ReadOnly {
-		return nil, nil, errors.New("cannot write to read-only table")
+	key, err := t.GenerateKey(d)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	key, err := t.generateKey(t.Info, d)
+	d, err = t.InsertWithKey(key, d)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	return key, d, nil
+}
+
+// InsertWithKey inserts the document into the table using the given key.
+// The key is expected to have been generated using GenerateKey.
+// It returns the inserted document.
+func (t *Table) InsertWithKey(key tree.Key, d types.Document) (types.Document, error) {
+	if t.Info.ReadOnly {
+		return nil, errors.New("cannot write to read-only table")
+	}
+
 	// ensure the key is not already present in the table
 	ok, err := t.Tree.Exists(key)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 	if ok {
-		return nil, nil, &errs.ConstraintViolationError{
+		return nil, &errs.ConstraintViolationError{
 			Constraint: "PRIMARY KEY",
 			Paths:      t.Info.GetPrimaryKey().Paths,
 			Key:        key,
@@ -59,10 +74,16 @@ func (t *Table) Insert(d types.Document) (tree.Key, types.Document, error) {
 	// insert into the table
 	err = t.Tree.Put(key, types.NewDocumentValue(d))
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
+	t.metrics.written()
 
-	return key, d, nil
+	err = t.Tx.recordChange(t.Info.TableName, key, nil, d)
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
 }
 
 // Delete a document by key.
@@ -71,12 +92,34 @@ func (t *Table) Delete(key tree.Key) error {
 		return errors.New("cannot write to read-only table")
 	}
 
+	// the deleted document is only needed if changes are captured
+	var old types.Document
+	if t.Tx.capturesChanges() {
+		v, err := t.Tree.Get(key)
+		if err != nil {
+			if errors.Is(err, kv.ErrKeyNotFound) {
+				return errs.ErrDocumentNotFound
+			}
+
+			return err
+		}
+		old = v.V().(types.Document)
+	}
+
 	err := t.Tree.Delete(key)
 	if errors.Is(err, kv.ErrKeyNotFound) {
 		return errs.ErrDocumentNotFound
 	}
+	if err != nil {
+		return err
+	}
+	t.metrics.written()
+
+	if old != nil {
+		return t.Tx.recordChange(t.Info.TableName, key, old, nil)
+	}
 
-	return err
+	return nil
 }
 
 // Replace a document by key.
@@ -88,7 +131,7 @@ func (t *Table) Replace(key tree.Key, d types.Document) (types.Document, error)
 	}
 
 	// make sure key exists
-	_, err := t.Tree.Get(key)
+	old, err := t.Tree.Get(key)
 	if err != nil {
 		if errors.Is(err, kv.ErrKeyNotFound) {
 			return nil, errs.ErrDocumentNotFound
@@ -99,7 +142,17 @@ func (t *Table) Replace(key tree.Key, d types.Document) (types.Document, error)
 
 	// replace old document with new document
 	err = t.Tree.Put(key, types.NewDocumentValue(d))
-	return d, err
+	if err != nil {
+		return nil, err
+	}
+	t.metrics.written()
+
+	err = t.Tx.recordChange(t.Info.TableName, key, old.V().(types.Document), d)
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
 }
 
 // This document implementation waits until
@@ -143,6 +196,7 @@ func (t *Table) IterateOnRange(rng *Range, reverse bool, fn func(key tree.Key, d
 	var d lazilyDecodedDocument
 
 	return t.Tree.IterateOnRange(r, reverse, func(k tree.Key, v types.Value) error {
+		t.metrics.read()
 		d.Value = v
 		return fn(k, &d)
 	})
@@ -157,17 +211,22 @@ func (t *Table) GetDocument(key tree.Key) (types.Document, error) {
 		}
 		return nil, fmt.Errorf("failed to fetch document %q: %w", key, err)
 	}
+	t.metrics.read()
 
 	return &lazilyDecodedDocument{v}, nil
 }
 
-// generate a key for d based on the table configuration.
+// GenerateKey generates a key for d based on the table configuration.
 // if the table has a primary key, it extracts the field from
 // the document, converts it to the targeted type and returns
 // its encoded version.
 // if there are no primary key in the table, a default
 // key is generated, called the docid.
-func (t *Table) generateKey(info *TableInfo, d types.Document) (tree.Key, error) {
+func (t *Table) GenerateKey(d types.Document) (tree.Key, error) {
+	if t.Info.ReadOnly {
+		return nil, errors.New("cannot write to read-only table")
+	}
+
 	if pk := t.Info.GetPrimaryKey(); pk != nil {
 		vs := make([]types.Value, 0, len(pk.Paths))
 		for _, p := range pk.Paths {
@@ -1,24 +1,233 @@
 package database
 
 import (
+	"context"
 	"sync"
+	"sync/atomic"
 
+	"github.com/cockroachdb/errors"
 	"github.com/genjidb/genji/internal/kv"
+	"github.com/genjidb/genji/internal/lock"
 )
 
 // Transaction represents a database transaction. It provides methods for managing the
 // collection of tables and the transaction itself.
 // Transaction is either read-only or read/write. Read-only can be used to read tables
 // and read/write can be used to read, create, delete and modify tables.
+// Read-only transactions read from a consistent snapshot of the database and can run
+// concurrently with a read/write transaction.
+// Read/write transactions can run concurrently as long as they don't modify the same
+// objects: they acquire locks on the tables and documents they modify, which are
+// released when the transaction is committed or rolled back.
 type Transaction struct {
-	Tx       *kv.Transaction
+	// ID uniquely identifies the transaction within the database.
+	ID       uint64
+	Tx       kv.Transaction
 	Writable bool
 	DBMu     *sync.RWMutex
 
+	// Catalog as seen by the transaction. Read-only transactions
+	// only see the objects committed before they started.
+	Catalog *Catalog
+	// changes made by the transaction to the catalog.
+	catalogChanges []catalogChange
+
+	// LockManager is used to acquire locks on database objects.
+	LockManager *lock.LockManager
+	// Metrics in which the transaction and the documents it reads and writes are counted.
+	// If nil, nothing is counted.
+	Metrics *Metrics
+	// documents read and written by the transaction, by table.
+	tables map[string]*TableMetrics
+	// context used when waiting for locks.
+	ctx context.Context
+	// locks held by the transaction, with the number of times
+	// they were acquired from the lock manager.
+	locks map[lock.Object]*heldLock
+
 	// these functions are run after a successful rollback.
 	OnRollbackHooks []func()
 	// these functions are run after a successful commit.
 	OnCommitHooks []func()
+
+	// savepoints created by the transaction, from the oldest to the newest.
+	savepoints []savepoint
+
+	// ChangeFeed to which changes are published after a successful commit.
+	// If nil, changes are not captured.
+	ChangeFeed *ChangeFeed
+	// changes made by the transaction.
+	changes []Change
+
+	// ChangeLog in which the operations of the transaction are written
+	// when it commits. If nil, operations are not recorded.
+	ChangeLog *ChangeLog
+	// operations recorded by the transaction.
+	logOps []LogOp
+}
+
+type savepoint struct {
+	name           string
+	sp             kv.Savepoint
+	rollbackHooks  int
+	commitHooks    int
+	catalogChanges int
+	changes        int
+	logOps         int
+	// documents written in every table when the savepoint was created.
+	written map[string]int64
+}
+
+type heldLock struct {
+	mode  lock.LockMode
+	count int
+}
+
+// Lock acquires a lock on the given object. It blocks until the lock is granted
+// or until the context of the transaction is canceled.
+// Locks are held until the transaction is committed or rolled back.
+func (tx *Transaction) Lock(obj *lock.Object, mode lock.LockMode) error {
+	if tx.LockManager == nil {
+		return nil
+	}
+
+	h, ok := tx.locks[*obj]
+	// the lock is already held with a mode that covers the requested one.
+	if ok && lock.MaxMode(mode, h.mode) == h.mode {
+		return nil
+	}
+
+	ctx := tx.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	_, err := tx.LockManager.Lock(ctx, tx.ID, obj, mode)
+	if err != nil {
+		return err
+	}
+
+	if tx.locks == nil {
+		tx.locks = make(map[lock.Object]*heldLock)
+	}
+	if !ok {
+		h = new(heldLock)
+		tx.locks[*obj] = h
+	}
+	h.mode = lock.MaxMode(mode, h.mode)
+	h.count++
+
+	return nil
+}
+
+// HoldsLock returns true if the transaction holds a lock on the given object
+// whose mode is at least as strong as mode.
+func (tx *Transaction) HoldsLock(obj *lock.Object, mode lock.LockMode) bool {
+	h, ok := tx.locks[*obj]
+
+	return ok && lock.MaxMode(mode, h.mode) == h.mode
+}
+
+// releaseLocks releases all the locks acquired by the transaction.
+func (tx *Transaction) releaseLocks() {
+	for obj, h := range tx.locks {
+		obj := obj
+		for i := 0; i < h.count; i++ {
+			tx.LockManager.Unlock(tx.ID, &obj)
+		}
+	}
+
+	tx.locks = nil
+}
+
+// Savepoint creates a savepoint with the given name.
+// If a savepoint with the same name already exists, the new one
+// shadows it until it is released.
+func (tx *Transaction) Savepoint(name string) error {
+	sp, err := tx.Tx.Savepoint()
+	if err != nil {
+		return err
+	}
+
+	tx.savepoints = append(tx.savepoints, savepoint{
+		name:           name,
+		sp:             sp,
+		rollbackHooks:  len(tx.OnRollbackHooks),
+		commitHooks:    len(tx.OnCommitHooks),
+		catalogChanges: len(tx.catalogChanges),
+		changes:        len(tx.changes),
+		logOps:         len(tx.logOps),
+		written:        tx.documentsWritten(),
+	})
+
+	return nil
+}
+
+// RollbackTo undoes every change made after the most recent savepoint
+// with the given name, including changes made to the catalog.
+// Savepoints created after it are destroyed but the savepoint itself
+// remains and can be rolled back to again.
+// Locks acquired after the savepoint are kept until the end of the transaction.
+func (tx *Transaction) RollbackTo(name string) error {
+	i := tx.findSavepoint(name)
+	if i < 0 {
+		return errors.Errorf("savepoint %q does not exist", name)
+	}
+	sp := tx.savepoints[i]
+
+	err := tx.Tx.RollbackTo(sp.sp)
+	if err != nil {
+		return err
+	}
+
+	for j := len(tx.OnRollbackHooks) - 1; j >= sp.rollbackHooks; j-- {
+		tx.OnRollbackHooks[j]()
+	}
+	tx.OnRollbackHooks = tx.OnRollbackHooks[:sp.rollbackHooks]
+	tx.OnCommitHooks = tx.OnCommitHooks[:sp.commitHooks]
+	tx.catalogChanges = tx.catalogChanges[:sp.catalogChanges]
+	tx.changes = tx.changes[:sp.changes]
+	tx.logOps = tx.logOps[:sp.logOps]
+	for name, t := range tx.tables {
+		atomic.StoreInt64(&t.DocumentsWritten, sp.written[name])
+	}
+
+	return tx.releaseSavepoints(i + 1)
+}
+
+// ReleaseSavepoint destroys the most recent savepoint with the given name
+// and every savepoint created after it. Changes made after the savepoint
+// are kept.
+func (tx *Transaction) ReleaseSavepoint(name string) error {
+	i := tx.findSavepoint(name)
+	if i < 0 {
+		return errors.Errorf("savepoint %q does not exist", name)
+	}
+
+	return tx.releaseSavepoints(i)
+}
+
+// releaseSavepoints destroys the i-th savepoint and every savepoint created after it.
+func (tx *Transaction) releaseSavepoints(i int) error {
+	for j := len(tx.savepoints) - 1; j >= i; j-- {
+		err := tx.Tx.ReleaseSavepoint(tx.savepoints[j].sp)
+		if err != nil {
+			return err
+		}
+	}
+
+	tx.savepoints = tx.savepoints[:i]
+	return nil
+}
+
+func (tx *Transaction) findSavepoint(name string) int {
+	for i := len(tx.savepoints) - 1; i >= 0; i-- {
+		if tx.savepoints[i].name == name {
+			return i
+		}
+	}
+
+	return -1
 }
 
 // Rollback the transaction. Can be used safely after commit.
@@ -28,10 +237,15 @@ func (tx *Transaction) Rollback() error {
 		return err
 	}
 
+	if tx.Writable {
+		tx.Metrics.txRolledBack()
+	}
+	tx.recordTableMetrics(false)
+
 	defer func() {
+		tx.releaseLocks()
+
 		if tx.Writable {
-			tx.DBMu.Unlock()
-		} else {
 			tx.DBMu.RUnlock()
 		}
 	}()
@@ -46,15 +260,66 @@ func (tx *Transaction) Rollback() error {
 // Commit the transaction. Calling this method on read-only transactions
 // will return an error.
 func (tx *Transaction) Commit() error {
-	err := tx.Tx.Commit()
+	return tx.commit(nil)
+}
+
+// commit the transaction, then call afterCommit, if not nil, before releasing the locks.
+// The transaction remains committed if afterCommit returns an error.
+func (tx *Transaction) commit(afterCommit func() error) error {
+	// the change log entry is written by the transaction itself,
+	// with a position that follows the commit order
+	var logPos uint64
+	if len(tx.logOps) > 0 {
+		tx.ChangeLog.mu.Lock()
+		defer tx.ChangeLog.mu.Unlock()
+
+		var err error
+		logPos, err = tx.writeLogEntry()
+		if err != nil {
+			return err
+		}
+	}
+
+	// changes must be published in commit order
+	if len(tx.changes) > 0 {
+		tx.ChangeFeed.commitMu.Lock()
+		defer tx.ChangeFeed.commitMu.Unlock()
+
+		err := tx.writeFeedPosition()
+		if err != nil {
+			return err
+		}
+	}
+
+	var err error
+	if len(tx.catalogChanges) > 0 {
+		// changes made to the catalog become visible to read-only transactions
+		// at the same time as the data they describe
+		err = tx.Catalog.Cache.commit(tx.catalogChanges, tx.Tx.Commit)
+	} else {
+		err = tx.Tx.Commit()
+	}
 	if err != nil {
 		return err
 	}
+	tx.Metrics.txCommitted()
+	tx.recordTableMetrics(true)
+	tx.catalogChanges = nil
+
+	if logPos > 0 {
+		tx.ChangeLog.last = logPos
+		tx.logOps = nil
+	}
+
+	if len(tx.changes) > 0 {
+		tx.ChangeFeed.publish(tx.changes)
+		tx.changes = nil
+	}
 
 	defer func() {
+		tx.releaseLocks()
+
 		if tx.Writable {
-			tx.DBMu.Unlock()
-		} else {
 			tx.DBMu.RUnlock()
 		}
 	}()
@@ -63,5 +328,9 @@ func (tx *Transaction) Commit() error {
 		tx.OnCommitHooks[i]()
 	}
 
+	if afterCommit != nil {
+		return afterCommit()
+	}
+
 	return nil
 }
@@ -13,15 +13,39 @@ const maxTransientPoolSize = 3
 // TransientStorePool manages a pool of transient stores.
 // It keeps a pool of maxTransientPoolSize stores.
 type TransientStorePool struct {
-	ng *kv.Engine
+	ng kv.Engine
 
 	mu   sync.Mutex
-	Pool []*kv.TransientStore
+	Pool []kv.TransientStore
+	// number of stores created and currently used.
+	created, inUse int64
+}
+
+// TransientStoreStats describes the usage of the transient stores.
+type TransientStoreStats struct {
+	// Number of stores created since the database was opened.
+	Created int64
+	// Number of stores currently in use.
+	InUse int64
+	// Number of stores kept in the pool for reuse.
+	Pooled int64
+}
+
+// Stats returns the usage of the transient stores.
+func (t *TransientStorePool) Stats() TransientStoreStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return TransientStoreStats{
+		Created: t.created,
+		InUse:   t.inUse,
+		Pooled:  int64(len(t.Pool)),
+	}
 }
 
 // Get returns a free engine from the pool, if any. Otherwise it creates a new engine
 // and returns it.
-func (t *TransientStorePool) Get(ctx context.Context) (*kv.TransientStore, error) {
+func (t *TransientStorePool) Get(ctx context.Context) (kv.TransientStore, error) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
@@ -33,17 +57,26 @@ func (t *TransientStorePool) Get(ctx context.Context) (*kv.TransientStore, error
 		}
 
 		t.Pool = t.Pool[:len(t.Pool)-1]
+		t.inUse++
 		return ng, nil
 	}
 
-	return t.ng.NewTransientStore()
+	ts, err := t.ng.NewTransientStore()
+	if err != nil {
+		return nil, err
+	}
+
+	t.created++
+	t.inUse++
+	return ts, nil
 }
 
 // Release sets the store for reuse. If the pool is full, it drops the given store.
-func (t *TransientStorePool) Release(ctx context.Context, ts *kv.TransientStore) error {
+func (t *TransientStorePool) Release(ctx context.Context, ts kv.TransientStore) error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
+	t.inUse--
 	if len(t.Pool) >= maxTransientPoolSize {
 		return ts.Drop()
 	}
@@ -17,7 +17,7 @@ func TestTransientStorePool(t *testing.T) {
 	ctx := context.Background()
 
 	// ask for more than the pool size (3)
-	var tempStores []*kv.TransientStore
+	var tempStores []kv.TransientStore
 	for i := 0; i < 4; i++ {
 		ts, err := db.TransientStorePool.Get(ctx)
 		require.NoError(t, err)
@@ -5,6 +5,7 @@ import (
 
 	"github.com/genjidb/genji/document"
 	"github.com/genjidb/genji/internal/database"
+	"github.com/genjidb/genji/internal/tree"
 	"github.com/genjidb/genji/types"
 )
 
@@ -31,8 +32,15 @@ type Environment struct {
 	DB      *database.Database
 	Catalog *database.Catalog
 	Tx      *database.Transaction
+	// Trees holds temporary trees by name, i.e. the documents
+	// of the common table expressions of a statement.
+	Trees map[string]*tree.Tree
 
 	Outer *Environment
+
+	// values holds the values computed once per execution of
+	// a statement, by key. Only the outermost environment uses it.
+	values map[interface{}]types.Value
 }
 
 func New(d types.Document, params ...Param) *Environment {
@@ -91,6 +99,53 @@ func (e *Environment) SetDocument(d types.Document) {
 	e.Doc = d
 }
 
+// SetTree binds a temporary tree to the given name.
+func (e *Environment) SetTree(name string, t *tree.Tree) {
+	if e.Trees == nil {
+		e.Trees = make(map[string]*tree.Tree)
+	}
+
+	e.Trees[name] = t
+}
+
+// GetTree returns the temporary tree bound to the given name
+// in this environment or in one of its outer environments.
+func (e *Environment) GetTree(name string) (*tree.Tree, bool) {
+	if t, ok := e.Trees[name]; ok {
+		return t, true
+	}
+
+	if e.Outer != nil {
+		return e.Outer.GetTree(name)
+	}
+
+	return nil, false
+}
+
+// SetValue binds v to the given key until the end of the execution
+// of the statement, i.e. in the outermost environment.
+func (e *Environment) SetValue(key interface{}, v types.Value) {
+	for e.Outer != nil {
+		e = e.Outer
+	}
+
+	if e.values == nil {
+		e.values = make(map[interface{}]types.Value)
+	}
+
+	e.values[key] = v
+}
+
+// GetValue returns the value bound to the given key by SetValue.
+func (e *Environment) GetValue(key interface{}) (types.Value, bool) {
+	for e.Outer != nil {
+		e = e.Outer
+	}
+
+	v, ok := e.values[key]
+	return v, ok
+}
+
 func (e *Environment) SetParams(params []Param) {
 	e.Params = params
 }
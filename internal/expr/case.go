@@ -0,0 +1,127 @@
+package expr
+
+import (
+	"strings"
+
+	"github.com/genjidb/genji/internal/environment"
+	"github.com/genjidb/genji/types"
+)
+
+// Case represents the CASE expression:
+//
+//	CASE [operand] WHEN expr THEN result [WHEN expr THEN result]* [ELSE result] END
+//
+// Without operand, it returns the result of the first WHEN clause whose expression is truthy.
+// With an operand, it returns the result of the first WHEN clause whose expression
+// is equal to the operand.
+// If no WHEN clause matches, it returns the result of the ELSE clause, or NULL.
+type Case struct {
+	Operand Expr
+	Whens   []When
+	Else    Expr
+}
+
+// When is a WHEN clause of a CASE expression.
+type When struct {
+	Cond Expr
+	Then Expr
+}
+
+// Eval evaluates the result of the first matching WHEN clause.
+func (c *Case) Eval(env *environment.Environment) (types.Value, error) {
+	var operand types.Value
+	var err error
+
+	if c.Operand != nil {
+		operand, err = c.Operand.Eval(env)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, w := range c.Whens {
+		ok, err := c.matches(env, operand, w.Cond)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return w.Then.Eval(env)
+		}
+	}
+
+	if c.Else != nil {
+		return c.Else.Eval(env)
+	}
+
+	return NullLiteral, nil
+}
+
+func (c *Case) matches(env *environment.Environment, operand types.Value, cond Expr) (bool, error) {
+	v, err := cond.Eval(env)
+	if err != nil {
+		return false, err
+	}
+
+	if c.Operand == nil {
+		return types.IsTruthy(v)
+	}
+
+	// comparing with NULL is never true
+	if operand.Type() == types.NullValue || v.Type() == types.NullValue {
+		return false, nil
+	}
+
+	return types.IsEqual(operand, v)
+}
+
+// IsEqual compares this expression with the other expression and returns
+// true if they are equal.
+func (c *Case) IsEqual(other Expr) bool {
+	o, ok := other.(*Case)
+	if !ok {
+		return false
+	}
+
+	if len(c.Whens) != len(o.Whens) {
+		return false
+	}
+
+	if (c.Operand == nil) != (o.Operand == nil) || c.Operand != nil && !Equal(c.Operand, o.Operand) {
+		return false
+	}
+
+	if (c.Else == nil) != (o.Else == nil) || c.Else != nil && !Equal(c.Else, o.Else) {
+		return false
+	}
+
+	for i := range c.Whens {
+		if !Equal(c.Whens[i].Cond, o.Whens[i].Cond) || !Equal(c.Whens[i].Then, o.Whens[i].Then) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (c *Case) String() string {
+	var sb strings.Builder
+
+	sb.WriteString("CASE")
+	if c.Operand != nil {
+		sb.WriteString(" ")
+		sb.WriteString(c.Operand.String())
+	}
+	for _, w := range c.Whens {
+		sb.WriteString(" WHEN ")
+		sb.WriteString(w.Cond.String())
+		sb.WriteString(" THEN ")
+		sb.WriteString(w.Then.String())
+	}
+	if c.Else != nil {
+		sb.WriteString(" ELSE ")
+		sb.WriteString(c.Else.String())
+	}
+	sb.WriteString(" END")
+
+	return sb.String()
+}
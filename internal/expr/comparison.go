@@ -129,10 +129,10 @@ func (op *BetweenOperator) String() string {
 }
 
 // IsComparisonOperator returns true if e is one of
-// =, !=, >, >=, <, <=, IS, IS NOT, IN, or NOT IN operators.
+// =, !=, >, >=, <, <=, IS, IS NOT, IN, NOT IN, LIKE, NOT LIKE, =~, !~ or BETWEEN operators.
 func IsComparisonOperator(op Operator) bool {
 	switch op.(type) {
-	case *cmpOp, *IsOperator, *IsNotOperator, *InOperator, *NotInOperator, *LikeOperator, *NotLikeOperator, *BetweenOperator:
+	case *cmpOp, *IsOperator, *IsNotOperator, *InOperator, *NotInOperator, *LikeOperator, *NotLikeOperator, *RegexpOperator, *NotRegexpOperator, *BetweenOperator:
 		return true
 	}
 
@@ -126,6 +126,32 @@ type AggregatorBuilder interface {
 	Aggregator() Aggregator
 }
 
+// A WindowFunction is a function whose result depends on the position of the current
+// document in its window, like its rank, or on the other documents of the window.
+type WindowFunction interface {
+	Expr
+
+	EvalWindow(w Window) (types.Value, error)
+}
+
+// A Window gives access to the documents of the partition of the current document,
+// sorted by the ORDER BY clause of the window.
+// Documents with the same ORDER BY values are peers.
+type Window interface {
+	// RowNumber returns the position of the current document in the partition, starting at 1.
+	RowNumber() int64
+	// Rank returns the position of the first peer of the current document.
+	Rank() int64
+	// DenseRank returns the position of the peers of the current document among the
+	// groups of peers of the partition, starting at 1.
+	DenseRank() int64
+	// EvalAt evaluates e against the document located offset documents after the current one,
+	// or before it if offset is negative. It returns false if there is no such document.
+	EvalAt(offset int64, e Expr) (types.Value, bool, error)
+	// EvalFirst evaluates e against the first document of the partition.
+	EvalFirst(e Expr) (types.Value, error)
+}
+
 func Walk(e Expr, fn func(Expr) bool) bool {
 	if e == nil {
 		return true
@@ -136,6 +162,11 @@ func Walk(e Expr, fn func(Expr) bool) bool {
 
 	switch t := e.(type) {
 	case Operator:
+		if b, ok := t.(*BetweenOperator); ok {
+			if !Walk(b.X, fn) {
+				return false
+			}
+		}
 		if !Walk(t.LeftHand(), fn) {
 			return false
 		}
@@ -144,6 +175,8 @@ func Walk(e Expr, fn func(Expr) bool) bool {
 		}
 	case *NamedExpr:
 		return Walk(t.Expr, fn)
+	case Parentheses:
+		return Walk(t.E, fn)
 	case Function:
 		for _, p := range t.Params() {
 			if !Walk(p, fn) {
@@ -162,6 +195,16 @@ func Walk(e Expr, fn func(Expr) bool) bool {
 				return false
 			}
 		}
+	case *Case:
+		if !Walk(t.Operand, fn) {
+			return false
+		}
+		for _, w := range t.Whens {
+			if !Walk(w.Cond, fn) || !Walk(w.Then, fn) {
+				return false
+			}
+		}
+		return Walk(t.Else, fn)
 	}
 
 	return true
@@ -61,6 +61,90 @@ var builtinFunctions = Definitions{
 			return &Avg{Expr: args[0]}, nil
 		},
 	},
+	"row_number": &definition{
+		name:  "row_number",
+		arity: 0,
+		constructorFn: func(args ...expr.Expr) (expr.Function, error) {
+			return &RowNumber{}, nil
+		},
+	},
+	"rank": &definition{
+		name:  "rank",
+		arity: 0,
+		constructorFn: func(args ...expr.Expr) (expr.Function, error) {
+			return &Rank{}, nil
+		},
+	},
+	"dense_rank": &definition{
+		name:  "dense_rank",
+		arity: 0,
+		constructorFn: func(args ...expr.Expr) (expr.Function, error) {
+			return &Rank{Dense: true}, nil
+		},
+	},
+	"lag": &offsetDefinition{
+		name: "lag",
+	},
+	"lead": &offsetDefinition{
+		name: "lead",
+		lead: true,
+	},
+	"first_value": &definition{
+		name:  "first_value",
+		arity: 1,
+		constructorFn: func(args ...expr.Expr) (expr.Function, error) {
+			return &FirstValue{Expr: args[0]}, nil
+		},
+	},
+	"coalesce": &variadicDefinition{
+		name:     "coalesce",
+		minArity: 1,
+		constructorFn: func(args ...expr.Expr) (expr.Function, error) {
+			return &Coalesce{Exprs: args}, nil
+		},
+	},
+	"ifnull": &definition{
+		name:  "ifnull",
+		arity: 2,
+		constructorFn: func(args ...expr.Expr) (expr.Function, error) {
+			return &IfNull{Exprs: args}, nil
+		},
+	},
+	"nullif": &definition{
+		name:  "nullif",
+		arity: 2,
+		constructorFn: func(args ...expr.Expr) (expr.Function, error) {
+			return &NullIf{Exprs: args}, nil
+		},
+	},
+	"greatest": &variadicDefinition{
+		name:     "greatest",
+		minArity: 1,
+		constructorFn: func(args ...expr.Expr) (expr.Function, error) {
+			return &Greatest{Exprs: args}, nil
+		},
+	},
+	"least": &variadicDefinition{
+		name:     "least",
+		minArity: 1,
+		constructorFn: func(args ...expr.Expr) (expr.Function, error) {
+			return &Least{Exprs: args}, nil
+		},
+	},
+	"regexp_replace": &definition{
+		name:  "regexp_replace",
+		arity: 3,
+		constructorFn: func(args ...expr.Expr) (expr.Function, error) {
+			return &RegexpReplace{Exprs: args}, nil
+		},
+	},
+	"regexp_extract": &definition{
+		name:  "regexp_extract",
+		arity: 2,
+		constructorFn: func(args ...expr.Expr) (expr.Function, error) {
+			return &RegexpExtract{Exprs: args}, nil
+		},
+	},
 }
 
 // BuiltinDefinitions returns a map of builtin functions.
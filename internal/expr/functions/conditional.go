@@ -0,0 +1,288 @@
+package functions
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/genjidb/genji/internal/environment"
+	"github.com/genjidb/genji/internal/expr"
+	"github.com/genjidb/genji/types"
+)
+
+// A variadicDefinition is the definition of a function
+// that takes any number of arguments, with a minimum.
+type variadicDefinition struct {
+	name          string
+	minArity      int
+	constructorFn func(...expr.Expr) (expr.Function, error)
+}
+
+func (fd *variadicDefinition) Name() string {
+	return fd.name
+}
+
+func (fd *variadicDefinition) Function(args ...expr.Expr) (expr.Function, error) {
+	if len(args) < fd.minArity {
+		return nil, fmt.Errorf("%s() takes at least %d argument(s), not %d", fd.name, fd.minArity, len(args))
+	}
+	return fd.constructorFn(args...)
+}
+
+func (fd *variadicDefinition) String() string {
+	args := make([]string, 0, fd.minArity+1)
+	for i := 0; i < fd.minArity; i++ {
+		args = append(args, fmt.Sprintf("arg%d", i+1))
+	}
+	args = append(args, "...")
+	return fmt.Sprintf("%s(%s)", fd.name, strings.Join(args, ", "))
+}
+
+// Arity returns the minimum number of arguments of the function.
+func (fd *variadicDefinition) Arity() int {
+	return fd.minArity
+}
+
+// Coalesce is the COALESCE function. It returns the first of its arguments
+// that is not NULL, or NULL if they are all NULL.
+// Arguments are evaluated until one of them is not NULL.
+type Coalesce struct {
+	Exprs []expr.Expr
+}
+
+func (c *Coalesce) Eval(env *environment.Environment) (types.Value, error) {
+	for _, e := range c.Exprs {
+		v, err := e.Eval(env)
+		if err != nil {
+			return nil, err
+		}
+
+		if v.Type() != types.NullValue {
+			return v, nil
+		}
+	}
+
+	return expr.NullLiteral, nil
+}
+
+// IsEqual compares this expression with the other expression and returns
+// true if they are equal.
+func (c *Coalesce) IsEqual(other expr.Expr) bool {
+	o, ok := other.(*Coalesce)
+	if !ok {
+		return false
+	}
+
+	return exprsAreEqual(c.Exprs, o.Exprs)
+}
+
+func (c *Coalesce) Params() []expr.Expr { return c.Exprs }
+
+func (c *Coalesce) String() string {
+	return fmt.Sprintf("COALESCE(%s)", exprsString(c.Exprs))
+}
+
+// IfNull is the IFNULL function. It returns its first argument
+// if it is not NULL, and its second argument otherwise.
+type IfNull struct {
+	Exprs []expr.Expr
+}
+
+func (f *IfNull) Eval(env *environment.Environment) (types.Value, error) {
+	v, err := f.Exprs[0].Eval(env)
+	if err != nil || v.Type() != types.NullValue {
+		return v, err
+	}
+
+	return f.Exprs[1].Eval(env)
+}
+
+// IsEqual compares this expression with the other expression and returns
+// true if they are equal.
+func (f *IfNull) IsEqual(other expr.Expr) bool {
+	o, ok := other.(*IfNull)
+	if !ok {
+		return false
+	}
+
+	return exprsAreEqual(f.Exprs, o.Exprs)
+}
+
+func (f *IfNull) Params() []expr.Expr { return f.Exprs }
+
+func (f *IfNull) String() string {
+	return fmt.Sprintf("IFNULL(%s)", exprsString(f.Exprs))
+}
+
+// NullIf is the NULLIF function. It returns NULL if its two arguments
+// are equal, and its first argument otherwise.
+type NullIf struct {
+	Exprs []expr.Expr
+}
+
+func (f *NullIf) Eval(env *environment.Environment) (types.Value, error) {
+	a, err := f.Exprs[0].Eval(env)
+	if err != nil || a.Type() == types.NullValue {
+		return a, err
+	}
+
+	b, err := f.Exprs[1].Eval(env)
+	if err != nil || b.Type() == types.NullValue {
+		return a, err
+	}
+
+	ok, err := types.IsEqual(a, b)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return expr.NullLiteral, nil
+	}
+
+	return a, nil
+}
+
+// IsEqual compares this expression with the other expression and returns
+// true if they are equal.
+func (f *NullIf) IsEqual(other expr.Expr) bool {
+	o, ok := other.(*NullIf)
+	if !ok {
+		return false
+	}
+
+	return exprsAreEqual(f.Exprs, o.Exprs)
+}
+
+func (f *NullIf) Params() []expr.Expr { return f.Exprs }
+
+func (f *NullIf) String() string {
+	return fmt.Sprintf("NULLIF(%s)", exprsString(f.Exprs))
+}
+
+// Greatest is the GREATEST function. It returns the largest of its arguments
+// that are not NULL, or NULL if they are all NULL.
+// Values are compared like the MAX aggregate function does.
+type Greatest struct {
+	Exprs []expr.Expr
+}
+
+func (g *Greatest) Eval(env *environment.Environment) (types.Value, error) {
+	return evalExtremum(env, g.Exprs, true)
+}
+
+// IsEqual compares this expression with the other expression and returns
+// true if they are equal.
+func (g *Greatest) IsEqual(other expr.Expr) bool {
+	o, ok := other.(*Greatest)
+	if !ok {
+		return false
+	}
+
+	return exprsAreEqual(g.Exprs, o.Exprs)
+}
+
+func (g *Greatest) Params() []expr.Expr { return g.Exprs }
+
+func (g *Greatest) String() string {
+	return fmt.Sprintf("GREATEST(%s)", exprsString(g.Exprs))
+}
+
+// Least is the LEAST function. It returns the smallest of its arguments
+// that are not NULL, or NULL if they are all NULL.
+// Values are compared like the MIN aggregate function does.
+type Least struct {
+	Exprs []expr.Expr
+}
+
+func (l *Least) Eval(env *environment.Environment) (types.Value, error) {
+	return evalExtremum(env, l.Exprs, false)
+}
+
+// IsEqual compares this expression with the other expression and returns
+// true if they are equal.
+func (l *Least) IsEqual(other expr.Expr) bool {
+	o, ok := other.(*Least)
+	if !ok {
+		return false
+	}
+
+	return exprsAreEqual(l.Exprs, o.Exprs)
+}
+
+func (l *Least) Params() []expr.Expr { return l.Exprs }
+
+func (l *Least) String() string {
+	return fmt.Sprintf("LEAST(%s)", exprsString(l.Exprs))
+}
+
+// evalExtremum returns the greatest or the smallest value returned by exprs, ignoring NULL values.
+// Values are compared based on their types, then if the type is equal their value is compared.
+// Numbers are considered of the same type.
+func evalExtremum(env *environment.Environment, exprs []expr.Expr, greatest bool) (types.Value, error) {
+	var res types.Value
+
+	for _, e := range exprs {
+		v, err := e.Eval(env)
+		if err != nil {
+			return nil, err
+		}
+		if v.Type() == types.NullValue {
+			continue
+		}
+
+		if res == nil {
+			res = v
+			continue
+		}
+
+		var ok bool
+		if res.Type() == v.Type() || res.Type().IsNumber() && v.Type().IsNumber() {
+			if greatest {
+				ok, err = types.IsGreaterThan(v, res)
+			} else {
+				ok, err = types.IsLesserThan(v, res)
+			}
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			ok = (v.Type() > res.Type()) == greatest
+		}
+
+		if ok {
+			res = v
+		}
+	}
+
+	if res == nil {
+		return expr.NullLiteral, nil
+	}
+
+	return res, nil
+}
+
+func exprsAreEqual(a, b []expr.Expr) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !expr.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func exprsString(exprs []expr.Expr) string {
+	var sb strings.Builder
+
+	for i, e := range exprs {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(e.String())
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,126 @@
+package functions
+
+import (
+	"fmt"
+
+	"github.com/genjidb/genji/internal/environment"
+	"github.com/genjidb/genji/internal/expr"
+	"github.com/genjidb/genji/types"
+)
+
+// RegexpReplace is the regexp_replace function. It replaces every match
+// of a pattern in its first argument by a replacement text.
+// The replacement text can refer to capture groups with $1, $2 or ${name}.
+type RegexpReplace struct {
+	Exprs []expr.Expr
+
+	cache expr.RegexpCache
+}
+
+func (f *RegexpReplace) Eval(env *environment.Environment) (types.Value, error) {
+	args, ok, err := evalTextArgs(env, "regexp_replace", f.Exprs)
+	if err != nil || !ok {
+		return expr.NullLiteral, err
+	}
+
+	re, err := f.cache.Compile(f.Exprs[1], args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return types.NewTextValue(re.ReplaceAllString(args[0], args[2])), nil
+}
+
+// IsEqual compares this expression with the other expression and returns
+// true if they are equal.
+func (f *RegexpReplace) IsEqual(other expr.Expr) bool {
+	o, ok := other.(*RegexpReplace)
+	if !ok {
+		return false
+	}
+
+	return exprsAreEqual(f.Exprs, o.Exprs)
+}
+
+func (f *RegexpReplace) Params() []expr.Expr { return f.Exprs }
+
+func (f *RegexpReplace) String() string {
+	return fmt.Sprintf("regexp_replace(%s)", exprsString(f.Exprs))
+}
+
+// RegexpExtract is the regexp_extract function. It returns the first match
+// of a pattern in its first argument, or NULL if there is none.
+// If the pattern contains capture groups, the text matched by the first group is returned instead.
+type RegexpExtract struct {
+	Exprs []expr.Expr
+
+	cache expr.RegexpCache
+}
+
+func (f *RegexpExtract) Eval(env *environment.Environment) (types.Value, error) {
+	args, ok, err := evalTextArgs(env, "regexp_extract", f.Exprs)
+	if err != nil || !ok {
+		return expr.NullLiteral, err
+	}
+
+	re, err := f.cache.Compile(f.Exprs[1], args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	m := re.FindStringSubmatchIndex(args[0])
+	if m == nil {
+		return expr.NullLiteral, nil
+	}
+
+	if re.NumSubexp() > 0 {
+		m = m[2:]
+	}
+	// the group didn't participate in the match
+	if m[0] < 0 {
+		return expr.NullLiteral, nil
+	}
+
+	return types.NewTextValue(args[0][m[0]:m[1]]), nil
+}
+
+// IsEqual compares this expression with the other expression and returns
+// true if they are equal.
+func (f *RegexpExtract) IsEqual(other expr.Expr) bool {
+	o, ok := other.(*RegexpExtract)
+	if !ok {
+		return false
+	}
+
+	return exprsAreEqual(f.Exprs, o.Exprs)
+}
+
+func (f *RegexpExtract) Params() []expr.Expr { return f.Exprs }
+
+func (f *RegexpExtract) String() string {
+	return fmt.Sprintf("regexp_extract(%s)", exprsString(f.Exprs))
+}
+
+// evalTextArgs evaluates exprs and returns their values, which must be texts.
+// If one of them is NULL, it returns false.
+func evalTextArgs(env *environment.Environment, name string, exprs []expr.Expr) ([]string, bool, error) {
+	args := make([]string, len(exprs))
+
+	for i, e := range exprs {
+		v, err := e.Eval(env)
+		if err != nil {
+			return nil, false, err
+		}
+
+		switch v.Type() {
+		case types.NullValue:
+			return nil, false, nil
+		case types.TextValue:
+			args[i] = v.V().(string)
+		default:
+			return nil, false, fmt.Errorf("%s() expects arg%d to be text, got %s", name, i+1, v.Type())
+		}
+	}
+
+	return args, true, nil
+}
@@ -34,3 +34,148 @@
 > typeof(NULL)
 'null'
 
+
+-- test: coalesce
+! coalesce()
+'coalesce() takes at least 1 argument(s), not 0'
+
+> coalesce(NULL)
+NULL
+
+> coalesce(1)
+1
+
+> coalesce(NULL, 2, 3)
+2
+
+> coalesce(NULL, NULL, 'a')
+'a'
+
+-- test: ifnull
+! ifnull(1)
+'ifnull() takes 2 argument(s), not 1'
+
+> ifnull(NULL, 2)
+2
+
+> ifnull(1, 2)
+1
+
+> ifnull(NULL, NULL)
+NULL
+
+-- test: nullif
+! nullif(1, 2, 3)
+'nullif() takes 2 argument(s), not 3'
+
+> nullif(1, 1)
+NULL
+
+> nullif(1, 1.0)
+NULL
+
+> nullif(1, 2)
+1
+
+> nullif(NULL, 1)
+NULL
+
+> nullif(1, NULL)
+1
+
+> nullif('a', 'a')
+NULL
+
+-- test: greatest
+! greatest()
+'greatest() takes at least 1 argument(s), not 0'
+
+> greatest(1, 3, 2)
+3
+
+> greatest(1, 3.5, 2)
+3.5
+
+> greatest(NULL, 1, NULL)
+1
+
+> greatest(NULL, NULL)
+NULL
+
+> greatest('a', 'c', 'b')
+'c'
+
+> greatest(1, 'a')
+'a'
+
+-- test: least
+! least()
+'least() takes at least 1 argument(s), not 0'
+
+> least(3, 1, 2)
+1
+
+> least(3, 1.5, 2)
+1.5
+
+> least(NULL, 1, NULL)
+1
+
+> least(NULL, NULL)
+NULL
+
+> least('a', 'c', 'b')
+'a'
+
+> least(1, 'a')
+1
+
+-- test: regexp_replace
+! regexp_replace('a', 'b')
+'regexp_replace() takes 3 argument(s), not 2'
+
+> regexp_replace('foo bar', 'o+', '0')
+'f0 bar'
+
+> regexp_replace('foo boo', 'o', '0')
+'f00 b00'
+
+> regexp_replace('2021-10-05', '(\\d+)-(\\d+)-(\\d+)', '$3/$2/$1')
+'05/10/2021'
+
+> regexp_replace('foo', 'x', 'y')
+'foo'
+
+> regexp_replace(NULL, 'o', '0')
+NULL
+
+> regexp_replace('foo', NULL, '0')
+NULL
+
+! regexp_replace(1, 'o', '0')
+'regexp_replace() expects arg1 to be text'
+
+! regexp_replace('foo', '(', '0')
+'error parsing regexp'
+
+-- test: regexp_extract
+! regexp_extract('a')
+'regexp_extract() takes 2 argument(s), not 1'
+
+> regexp_extract('level=error msg=oops', 'level=\\w+')
+'level=error'
+
+> regexp_extract('level=error msg=oops', 'msg=(\\w+)')
+'oops'
+
+> regexp_extract('level=error', 'msg=(\\w+)')
+NULL
+
+> regexp_extract('abc', 'a(x)?')
+NULL
+
+> regexp_extract(NULL, 'a')
+NULL
+
+! regexp_extract('abc', 1)
+'regexp_extract() expects arg2 to be text'
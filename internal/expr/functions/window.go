@@ -0,0 +1,259 @@
+package functions
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/genjidb/genji/document"
+	"github.com/genjidb/genji/internal/environment"
+	"github.com/genjidb/genji/internal/expr"
+	"github.com/genjidb/genji/types"
+)
+
+var _ expr.WindowFunction = (*RowNumber)(nil)
+
+// RowNumber is the ROW_NUMBER window function. It returns the position
+// of the current document in its partition, starting at 1.
+type RowNumber struct{}
+
+func (r *RowNumber) Eval(*environment.Environment) (types.Value, error) {
+	return nil, errors.New("misuse of window function ROW_NUMBER()")
+}
+
+// EvalWindow implements the expr.WindowFunction interface.
+func (r *RowNumber) EvalWindow(w expr.Window) (types.Value, error) {
+	return types.NewIntegerValue(w.RowNumber()), nil
+}
+
+// IsEqual compares this expression with the other expression and returns
+// true if they are equal.
+func (r *RowNumber) IsEqual(other expr.Expr) bool {
+	_, ok := other.(*RowNumber)
+	return ok
+}
+
+func (r *RowNumber) Params() []expr.Expr { return nil }
+
+func (r *RowNumber) String() string {
+	return "ROW_NUMBER()"
+}
+
+// Rank is the RANK window function. It returns the position of the first peer
+// of the current document in its partition, which leaves gaps between the ranks
+// of the groups of peers.
+type Rank struct {
+	// If true, the ranks of the groups of peers are consecutive (DENSE_RANK).
+	Dense bool
+}
+
+func (r *Rank) Eval(*environment.Environment) (types.Value, error) {
+	return nil, errors.Errorf("misuse of window function %s", r)
+}
+
+// EvalWindow implements the expr.WindowFunction interface.
+func (r *Rank) EvalWindow(w expr.Window) (types.Value, error) {
+	if r.Dense {
+		return types.NewIntegerValue(w.DenseRank()), nil
+	}
+
+	return types.NewIntegerValue(w.Rank()), nil
+}
+
+// IsEqual compares this expression with the other expression and returns
+// true if they are equal.
+func (r *Rank) IsEqual(other expr.Expr) bool {
+	o, ok := other.(*Rank)
+	return ok && r.Dense == o.Dense
+}
+
+func (r *Rank) Params() []expr.Expr { return nil }
+
+func (r *Rank) String() string {
+	if r.Dense {
+		return "DENSE_RANK()"
+	}
+
+	return "RANK()"
+}
+
+// Offset is the LAG and LEAD window functions. They return the value of an expression
+// evaluated against the document located N documents before (LAG) or after (LEAD) the current one
+// in its partition, or a default value if there is none.
+type Offset struct {
+	Expr expr.Expr
+	// N is the number of documents between the current document and the one Expr
+	// is evaluated against. If nil, it defaults to 1.
+	N expr.Expr
+	// Default is returned if there is no such document. If nil, it defaults to NULL.
+	Default expr.Expr
+	// If true, the document is located after the current document (LEAD).
+	Lead bool
+}
+
+func (o *Offset) Eval(*environment.Environment) (types.Value, error) {
+	return nil, errors.Errorf("misuse of window function %s()", o.name())
+}
+
+// EvalWindow implements the expr.WindowFunction interface.
+func (o *Offset) EvalWindow(w expr.Window) (types.Value, error) {
+	n := int64(1)
+	if o.N != nil {
+		// the offset and the default value can only reference
+		// the current document
+		v, _, err := w.EvalAt(0, o.N)
+		if err != nil {
+			return nil, err
+		}
+		v, err = document.CastAsInteger(v)
+		if err != nil {
+			return nil, err
+		}
+		n = v.V().(int64)
+		if n < 0 {
+			return nil, errors.Errorf("%s() offset must not be negative", o.name())
+		}
+	}
+
+	if !o.Lead {
+		n = -n
+	}
+
+	v, ok, err := w.EvalAt(n, o.Expr)
+	if err != nil || ok {
+		return v, err
+	}
+
+	if o.Default == nil {
+		return types.NewNullValue(), nil
+	}
+
+	v, _, err = w.EvalAt(0, o.Default)
+	return v, err
+}
+
+// IsEqual compares this expression with the other expression and returns
+// true if they are equal.
+func (o *Offset) IsEqual(other expr.Expr) bool {
+	if other == nil {
+		return false
+	}
+
+	oo, ok := other.(*Offset)
+	if !ok {
+		return false
+	}
+
+	return o.Lead == oo.Lead &&
+		expr.Equal(o.Expr, oo.Expr) &&
+		expr.Equal(o.N, oo.N) &&
+		expr.Equal(o.Default, oo.Default)
+}
+
+func (o *Offset) Params() []expr.Expr {
+	params := []expr.Expr{o.Expr}
+	if o.N != nil {
+		params = append(params, o.N)
+	}
+	if o.Default != nil {
+		params = append(params, o.Default)
+	}
+
+	return params
+}
+
+func (o *Offset) name() string {
+	if o.Lead {
+		return "LEAD"
+	}
+
+	return "LAG"
+}
+
+func (o *Offset) String() string {
+	var sb strings.Builder
+
+	sb.WriteString(o.name())
+	sb.WriteByte('(')
+	for i, p := range o.Params() {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		fmt.Fprintf(&sb, "%v", p)
+	}
+	sb.WriteByte(')')
+
+	return sb.String()
+}
+
+// FirstValue is the FIRST_VALUE window function. It returns the value of an expression
+// evaluated against the first document of the partition of the current document.
+type FirstValue struct {
+	Expr expr.Expr
+}
+
+func (f *FirstValue) Eval(*environment.Environment) (types.Value, error) {
+	return nil, errors.New("misuse of window function FIRST_VALUE()")
+}
+
+// EvalWindow implements the expr.WindowFunction interface.
+func (f *FirstValue) EvalWindow(w expr.Window) (types.Value, error) {
+	return w.EvalFirst(f.Expr)
+}
+
+// IsEqual compares this expression with the other expression and returns
+// true if they are equal.
+func (f *FirstValue) IsEqual(other expr.Expr) bool {
+	if other == nil {
+		return false
+	}
+
+	o, ok := other.(*FirstValue)
+	if !ok {
+		return false
+	}
+
+	return expr.Equal(f.Expr, o.Expr)
+}
+
+func (f *FirstValue) Params() []expr.Expr { return []expr.Expr{f.Expr} }
+
+func (f *FirstValue) String() string {
+	return fmt.Sprintf("FIRST_VALUE(%v)", f.Expr)
+}
+
+// An offsetDefinition is the definition of the LAG and LEAD functions,
+// whose last two arguments are optional.
+type offsetDefinition struct {
+	name string
+	lead bool
+}
+
+func (fd *offsetDefinition) Name() string {
+	return fd.name
+}
+
+func (fd *offsetDefinition) Function(args ...expr.Expr) (expr.Function, error) {
+	if len(args) == 0 || len(args) > 3 {
+		return nil, fmt.Errorf("%s() takes 1 to 3 arguments, not %d", fd.name, len(args))
+	}
+
+	o := Offset{Expr: args[0], Lead: fd.lead}
+	if len(args) > 1 {
+		o.N = args[1]
+	}
+	if len(args) > 2 {
+		o.Default = args[2]
+	}
+
+	return &o, nil
+}
+
+func (fd *offsetDefinition) String() string {
+	return fmt.Sprintf("%s(arg1, arg2, arg3)", fd.name)
+}
+
+// Arity returns the maximum number of arguments of the function.
+func (fd *offsetDefinition) Arity() int {
+	return 3
+}
@@ -0,0 +1,87 @@
+package expr
+
+import (
+	"regexp"
+	"sync"
+
+	"github.com/genjidb/genji/internal/environment"
+	"github.com/genjidb/genji/internal/sql/scanner"
+	"github.com/genjidb/genji/types"
+)
+
+// A RegexpCache compiles the patterns of regular expressions.
+// Patterns returned by a literal or a parameter don't change during the execution
+// of a statement: the last one is kept and only compiled once.
+type RegexpCache struct {
+	mu      sync.Mutex
+	pattern string
+	re      *regexp.Regexp
+}
+
+// Compile returns the compiled pattern, which is the value returned by e.
+func (c *RegexpCache) Compile(e Expr, pattern string) (*regexp.Regexp, error) {
+	switch e.(type) {
+	case LiteralValue, NamedParam, PositionalParam:
+	default:
+		return regexp.Compile(pattern)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.re != nil && c.pattern == pattern {
+		return c.re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	c.pattern, c.re = pattern, re
+	return re, nil
+}
+
+type RegexpOperator struct {
+	*simpleOperator
+
+	cache *RegexpCache
+}
+
+// Regexp creates an expression that evaluates to the result of a =~ b.
+// The pattern uses the RE2 syntax accepted by the regexp package.
+func Regexp(a, b Expr) Expr {
+	return &RegexpOperator{&simpleOperator{a, b, scanner.EQREGEX}, new(RegexpCache)}
+}
+
+func (op *RegexpOperator) Eval(env *environment.Environment) (types.Value, error) {
+	return op.simpleOperator.eval(env, func(a, b types.Value) (types.Value, error) {
+		if a.Type() != types.TextValue || b.Type() != types.TextValue {
+			return NullLiteral, nil
+		}
+
+		re, err := op.cache.Compile(op.b, b.V().(string))
+		if err != nil {
+			return NullLiteral, err
+		}
+
+		if re.MatchString(a.V().(string)) {
+			return TrueLiteral, nil
+		}
+
+		return FalseLiteral, nil
+	})
+}
+
+type NotRegexpOperator struct {
+	RegexpOperator
+}
+
+// NotRegexp creates an expression that evaluates to the result of a !~ b.
+func NotRegexp(a, b Expr) Expr {
+	return &NotRegexpOperator{RegexpOperator{&simpleOperator{a, b, scanner.NEQREGEX}, new(RegexpCache)}}
+}
+
+func (op *NotRegexpOperator) Eval(env *environment.Environment) (types.Value, error) {
+	return invertBoolResult(op.RegexpOperator.Eval)(env)
+}
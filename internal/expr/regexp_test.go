@@ -0,0 +1,95 @@
+package expr_test
+
+import (
+	"testing"
+
+	"github.com/genjidb/genji/internal/environment"
+	"github.com/genjidb/genji/internal/expr"
+	"github.com/genjidb/genji/internal/testutil"
+	"github.com/genjidb/genji/internal/testutil/assert"
+	"github.com/genjidb/genji/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegexpExpr(t *testing.T) {
+	tests := []struct {
+		expr  string
+		res   types.Value
+		fails bool
+	}{
+		{"'foo' =~ 'f.o'", types.NewBoolValue(true), false},
+		{"'foo' =~ '^o'", types.NewBoolValue(false), false},
+		{"'foo' =~ '(?i)FOO'", types.NewBoolValue(true), false},
+		{"'foo' !~ 'f.o'", types.NewBoolValue(false), false},
+		{"'foo' !~ '^o'", types.NewBoolValue(true), false},
+		{"NULL =~ 'foo'", nullLiteral, false},
+		{"'foo' =~ NULL", nullLiteral, false},
+		{"NULL !~ 'foo'", nullLiteral, false},
+		{"1 =~ '1'", nullLiteral, false},
+		{"a =~ '1'", nullLiteral, false},
+		{"'foo' =~ '('", nullLiteral, true},
+		{"'foo' !~ '('", nullLiteral, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			testutil.TestExpr(t, test.expr, envWithDoc, test.res, test.fails)
+		})
+	}
+}
+
+func TestRegexpCache(t *testing.T) {
+	t.Run("literal", func(t *testing.T) {
+		var c expr.RegexpCache
+		e := testutil.TextValue("^a+$")
+
+		re1, err := c.Compile(e, "^a+$")
+		assert.NoError(t, err)
+		re2, err := c.Compile(e, "^a+$")
+		assert.NoError(t, err)
+		require.Same(t, re1, re2)
+	})
+
+	t.Run("param", func(t *testing.T) {
+		var c expr.RegexpCache
+		e := expr.NamedParam("p")
+
+		re1, err := c.Compile(e, "^a+$")
+		assert.NoError(t, err)
+		re2, err := c.Compile(e, "^a+$")
+		assert.NoError(t, err)
+		require.Same(t, re1, re2)
+
+		// the same statement can be executed again with different parameters
+		re3, err := c.Compile(e, "^b+$")
+		assert.NoError(t, err)
+		require.NotSame(t, re1, re3)
+		require.Equal(t, "^b+$", re3.String())
+	})
+
+	t.Run("path", func(t *testing.T) {
+		var c expr.RegexpCache
+		e := testutil.ParsePath(t, "a")
+
+		re1, err := c.Compile(e, "^a+$")
+		assert.NoError(t, err)
+		re2, err := c.Compile(e, "^a+$")
+		assert.NoError(t, err)
+		require.NotSame(t, re1, re2)
+	})
+
+	t.Run("operator with params", func(t *testing.T) {
+		e := expr.Regexp(testutil.TextValue("aaa"), expr.PositionalParam(1))
+
+		env := environment.New(nil)
+		env.SetParams([]environment.Param{{Value: "^a+$"}})
+		v, err := e.Eval(env)
+		assert.NoError(t, err)
+		require.Equal(t, types.NewBoolValue(true), v)
+
+		env.SetParams([]environment.Param{{Value: "^b+$"}})
+		v, err = e.Eval(env)
+		assert.NoError(t, err)
+		require.Equal(t, types.NewBoolValue(false), v)
+	})
+}
@@ -0,0 +1,89 @@
+package kv
+
+import (
+	"bytes"
+	"sync"
+)
+
+// Engines store every key of every store in a single ordered keyspace:
+// - the existence of a store is recorded by the key StoreKey(name)
+// - the keys of a store are prefixed by StorePrefix(name)
+// This layout is shared by all engines so that their content
+// can be copied from one engine to another.
+
+const (
+	separator   byte = 0x1F
+	storeKey         = "__genji.store"
+	storePrefix      = 's'
+)
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return &[]byte{}
+	},
+}
+
+// StoreKey returns the key recording the existence of a store.
+func StoreKey(name []byte) []byte {
+	var buf bytes.Buffer
+	buf.Grow(len(storeKey) + 1 + len(name))
+	buf.WriteString(storeKey)
+	buf.WriteByte(separator)
+	buf.Write(name)
+
+	return buf.Bytes()
+}
+
+// StorePrefix returns the prefix of the keys of a store.
+func StorePrefix(name []byte) []byte {
+	buf := bufferPool.Get().(*[]byte)
+	if cap(*buf) < len(name)+3 {
+		*buf = make([]byte, 0, len(name)+3)
+	}
+	prefix := (*buf)[:0]
+	prefix = append(prefix, storePrefix)
+	prefix = append(prefix, separator)
+	prefix = append(prefix, name...)
+
+	return prefix
+}
+
+// build a long key for each key of a store
+// in the form: storePrefix + <sep> + 0 + key.
+// the 0 is used to separate the actual key
+// from the rest of the prexix and to ensure
+// we can quickly access the latest key of the store
+// by replacing 0 by anything bigger.
+// The returned key can be passed to ReleaseKey once it is no longer used.
+func BuildKey(prefix, k []byte) []byte {
+	buf := bufferPool.Get().(*[]byte)
+	if cap(*buf) < len(prefix)+len(k)+2 {
+		*buf = make([]byte, 0, len(prefix)+len(k)+2)
+	}
+	key := (*buf)[:0]
+	key = append(key, prefix...)
+	key = append(key, separator)
+	key = append(key, 0)
+	key = append(key, k...)
+	return key
+}
+
+// BuildEndKey returns the smallest key greater than all the keys of the store
+// with the given prefix.
+// The returned key can be passed to ReleaseKey once it is no longer used.
+func BuildEndKey(prefix []byte) []byte {
+	k := BuildKey(prefix, nil)
+	k[len(prefix)+1] = 0xff
+	return k
+}
+
+// TrimPrefix returns the key relative to the store with the given prefix.
+func TrimPrefix(k []byte, prefix []byte) []byte {
+	return k[len(prefix)+2:]
+}
+
+// ReleaseKey puts a key created by BuildKey, BuildEndKey or StorePrefix back in the pool.
+// The key must not be used afterwards.
+func ReleaseKey(k []byte) {
+	bufferPool.Put(&k)
+}
@@ -0,0 +1,198 @@
+// Package kv defines the interfaces implemented by the key-value engines
+// used to store the data of the database.
+// Engines store keys in lexicographic order and group them in stores.
+package kv
+
+import (
+	"github.com/cockroachdb/errors"
+)
+
+// Common errors returned by the engine implementations.
+var (
+	// ErrTransactionReadOnly is returned when attempting to call write methods on a read-only transaction.
+	ErrTransactionReadOnly = errors.New("transaction is read-only")
+
+	// ErrTransactionTooLarge is returned when the writes of a transaction exceed the size limit of the engine.
+	ErrTransactionTooLarge = errors.New("transaction is too large")
+
+	// ErrTransactionDiscarded is returned when calling Rollback or Commit after a transaction is no longer valid.
+	ErrTransactionDiscarded = errors.New("transaction has been discarded")
+
+	// ErrStoreNotFound is returned when the targeted store doesn't exist.
+	ErrStoreNotFound = errors.New("store not found")
+
+	// ErrStoreAlreadyExists must be returned when attempting to create a store with the
+	// same name as an existing one.
+	ErrStoreAlreadyExists = errors.New("store already exists")
+
+	// ErrKeyNotFound is returned when the targeted key doesn't exist.
+	ErrKeyNotFound = errors.New("key not found")
+
+	// ErrDuplicateKey is returned by loaders when the same key is added twice to a store.
+	ErrDuplicateKey = errors.New("duplicate key")
+
+	// ErrEngineReadOnly is returned when attempting to begin a read/write transaction
+	// on an engine opened in read-only mode.
+	ErrEngineReadOnly = errors.New("database is read-only")
+)
+
+// An Engine manages stores and the transactions used to access them.
+type Engine interface {
+	// Begin a transaction.
+	// Read-only transactions must never observe writes committed
+	// after they began.
+	Begin(opts TxOptions) (Transaction, error)
+	// NewTransientStore creates a store used to hold temporary data,
+	// outside of any transaction.
+	NewTransientStore() (TransientStore, error)
+	// NewLoader creates a loader used to add large amounts of keys
+	// without going through a transaction.
+	NewLoader() (Loader, error)
+	// ReadOnly returns true if read/write transactions can't be created.
+	ReadOnly() bool
+	// Checkpoint creates a consistent copy of the committed data
+	// in the given directory, which must not exist.
+	// The copy can be opened as an on-disk database.
+	Checkpoint(dir string) error
+	// Compact reclaims the space used by deleted keys, for example
+	// after dropping a store, and returns the number of bytes reclaimed.
+	Compact() (int64, error)
+	// Stats returns metrics describing the state of the engine.
+	Stats() Stats
+	// StoreSize returns the estimated space used by the keys of the store, in bytes.
+	StoreSize(name []byte) (uint64, error)
+	// Close the engine.
+	Close() error
+}
+
+// Stats describes the state of an engine.
+// Metrics that don't apply to an engine are left empty.
+type Stats struct {
+	// Space used on disk, in bytes.
+	DiskUsage uint64
+	// Size of the data held in memory, waiting to be written to disk.
+	MemTableSize uint64
+	// Size of the write-ahead log, in bytes.
+	WALSize uint64
+	// Size of the block cache and number of lookups that found or missed a block.
+	CacheSize   int64
+	CacheHits   int64
+	CacheMisses int64
+	// Number of flushes and compactions since the engine was opened.
+	Flushes     int64
+	Compactions int64
+	// Estimated number of bytes compactions still need to rewrite.
+	CompactionDebt uint64
+	// Number of files or memory tables that might be read to find a key.
+	ReadAmplification int
+}
+
+// TxOptions is used to configure a transaction upon creation.
+type TxOptions struct {
+	Writable bool
+}
+
+// A Transaction gives access to the stores of an engine.
+// Writes are only visible to other transactions once committed.
+// Transactions are not safe for concurrent use.
+type Transaction interface {
+	// Commit the transaction.
+	Commit() error
+	// Rollback the transaction. Can be used safely after commit.
+	Rollback() error
+	// Savepoint returns a savepoint at the current position of the transaction.
+	Savepoint() (Savepoint, error)
+	// RollbackTo undoes every write made after the given savepoint.
+	RollbackTo(sp Savepoint) error
+	// ReleaseSavepoint destroys the savepoint, which can no longer be rolled back to.
+	// Writes made after it are kept.
+	ReleaseSavepoint(sp Savepoint) error
+	// GetStore returns a store by name.
+	GetStore(name []byte) Store
+	// CreateStore creates a store.
+	// If the store already exists, returns ErrStoreAlreadyExists.
+	CreateStore(name []byte) error
+	// StoreExists returns true if a store with the given name exists.
+	StoreExists(name []byte) (bool, error)
+	// DropStore deletes the store and all its keys.
+	DropStore(name []byte) error
+}
+
+// A Savepoint marks a position in a transaction.
+// Its content depends on the engine that created it.
+type Savepoint interface{}
+
+// A Store is an ordered collection of key-value pairs
+// accessed through a transaction.
+type Store interface {
+	// Put stores a key value pair. If it already exists, it overrides it.
+	Put(k, v []byte) error
+	// Get returns a value associated with the given key. If not found, returns ErrKeyNotFound.
+	Get(k []byte) ([]byte, error)
+	// Exists returns true if the key exists.
+	Exists(k []byte) (bool, error)
+	// Delete a record by key. If not found, returns ErrKeyNotFound.
+	Delete(k []byte) error
+	// Truncate deletes all the records of the store.
+	Truncate() error
+	// Iterator returns an iterator over the keys of the store.
+	// If opts is nil, the iterator covers the whole store.
+	Iterator(opts *IterOptions) Iterator
+}
+
+// IterOptions restrict the keys returned by an iterator.
+// Bounds are expressed relative to the store.
+type IterOptions struct {
+	// Inclusive lower bound. If nil, iteration starts with the first key of the store.
+	LowerBound []byte
+	// Exclusive upper bound. If nil, iteration ends with the last key of the store.
+	UpperBound []byte
+}
+
+// An Iterator iterates over the keys of a store, in lexicographic order.
+// Keys returned by Key are relative to the store.
+// The key and value returned by an iterator are only valid until
+// the next call that moves the iterator.
+type Iterator interface {
+	First() bool
+	Last() bool
+	SeekGE(key []byte) bool
+	Next() bool
+	Prev() bool
+	Valid() bool
+	Key() []byte
+	Value() []byte
+	Error() error
+	Close() error
+}
+
+// A TransientStore holds temporary data, for example to sort documents.
+// Its content is never persisted.
+type TransientStore interface {
+	// Put stores a key value pair. If it already exists, it overrides it.
+	Put(k, v []byte) error
+	// Iterator returns an iterator over the keys of the store.
+	Iterator(opts *IterOptions) Iterator
+	// Reset deletes the content of the store so that it can be reused.
+	Reset() error
+	// Drop releases any resource (files, memory, etc.) used by the store.
+	Drop() error
+}
+
+// A Loader adds keys to the stores of an engine without going through a transaction,
+// which is a lot faster when loading large amounts of data.
+// Keys can be added in any order. They only become visible once committed, all at once.
+// Keys added to a store override the existing keys of that store.
+// Loaders are not safe for concurrent use.
+type Loader interface {
+	// Put adds a key to the given store, which must exist.
+	Put(store, k, v []byte) error
+	// Commit sorts the keys and writes them to the engine.
+	// If fn is not nil, it is called for every key in order, store by store,
+	// before any key is written. If fn returns an error, nothing is written.
+	// If the same key was added twice to a store, it returns ErrDuplicateKey.
+	Commit(fn func(store, k, v []byte) error) error
+	// Close releases the resources used by the loader.
+	// Keys that were not committed are discarded.
+	Close() error
+}
@@ -1,13 +1,12 @@
-package kv_test
+// Package kvtest provides a test suite verifying the behavior of kv engines.
+package kvtest
 
 import (
 	"bytes"
 	"context"
-	"io/ioutil"
-	"os"
-	"path/filepath"
 	"testing"
 
+	"github.com/cockroachdb/errors"
 	"github.com/genjidb/genji"
 	"github.com/genjidb/genji/document"
 	"github.com/genjidb/genji/internal/kv"
@@ -17,17 +16,56 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func builder(t testing.TB) *kv.Engine {
-	dir := tempDir(t)
+// A Builder creates a new engine for each test.
+type Builder func(t testing.TB) kv.Engine
+
+// testSuite runs the whole test suite against the engines created by the given builder.
+func TestSuite(t *testing.T, builder Builder) {
+	t.Run("Engine", func(t *testing.T) { testEngine(t, builder) })
+	t.Run("TransactionCommitRollback", func(t *testing.T) { testTransactionCommitRollback(t, builder) })
+	t.Run("TransactionCreateStore", func(t *testing.T) { testTransactionCreateStore(t, builder) })
+	t.Run("TransactionGetStore", func(t *testing.T) { testTransactionGetStore(t, builder) })
+	t.Run("TransactionDropStore", func(t *testing.T) { testTransactionDropStore(t, builder) })
+	t.Run("StorePut", func(t *testing.T) { testStorePut(t, builder) })
+	t.Run("StoreGet", func(t *testing.T) { testStoreGet(t, builder) })
+	t.Run("StoreDelete", func(t *testing.T) { testStoreDelete(t, builder) })
+	t.Run("StoreTruncate", func(t *testing.T) { testStoreTruncate(t, builder) })
+	t.Run("Queries", func(t *testing.T) { testQueries(t, builder) })
+	t.Run("QueriesSameTransaction", func(t *testing.T) { testQueriesSameTransaction(t, builder) })
+	t.Run("Transient", func(t *testing.T) { testTransient(t, builder) })
+	t.Run("Loader", func(t *testing.T) { testLoader(t, builder) })
+}
 
-	ng, err := kv.NewEngine(filepath.Join(dir, "pebble"), nil)
-	assert.NoError(t, err)
+// testEngine runs a list of tests against the provided kv.
+func testEngine(t *testing.T, builder Builder) {
+	t.Run("Compact", func(t *testing.T) {
+		ng := builder(t)
+		defer func() {
+			assert.NoError(t, ng.Close())
+		}()
 
-	return ng
-}
+		tx, err := ng.Begin(kv.TxOptions{
+			Writable: true,
+		})
+		assert.NoError(t, err)
+		defer tx.Rollback()
+
+		err = tx.CreateStore([]byte("test"))
+		assert.NoError(t, err)
+		err = tx.GetStore([]byte("test")).Put([]byte("foo"), []byte("FOO"))
+		assert.NoError(t, err)
+		assert.NoError(t, tx.Commit())
+
+		reclaimed, err := ng.Compact()
+		assert.NoError(t, err)
+		require.GreaterOrEqual(t, reclaimed, int64(0))
+
+		tx, err = ng.Begin(kv.TxOptions{})
+		assert.NoError(t, err)
+		defer tx.Rollback()
+		require.Equal(t, []byte("FOO"), getValue(t, tx.GetStore([]byte("test")), []byte("foo")))
+	})
 
-// TestEngine runs a list of tests against the provided kv.
-func TestEngine(t *testing.T) {
 	t.Run("Close", func(t *testing.T) {
 		ng := builder(t)
 
@@ -35,15 +73,15 @@ func TestEngine(t *testing.T) {
 	})
 }
 
-func getValue(t *testing.T, st *kv.Store, key []byte) []byte {
+func getValue(t *testing.T, st kv.Store, key []byte) []byte {
 	v, err := st.Get([]byte(key))
 	assert.NoError(t, err)
 	return v
 }
 
-// TestTransactionCommitRollback runs a list of tests to verify Commit and Rollback
+// testTransactionCommitRollback runs a list of tests to verify Commit and Rollback
 // behaviour of transactions created from the given kv.
-func TestTransactionCommitRollback(t *testing.T) {
+func testTransactionCommitRollback(t *testing.T, builder Builder) {
 	ng := builder(t)
 	defer func() {
 		assert.NoError(t, ng.Close())
@@ -165,24 +203,24 @@ func TestTransactionCommitRollback(t *testing.T) {
 		// this test checks if rollback undoes data changes correctly and if commit keeps data correctly
 		tests := []struct {
 			name    string
-			initFn  func(*kv.Transaction) error
-			writeFn func(*kv.Transaction, *error)
-			readFn  func(*kv.Transaction, *error)
+			initFn  func(kv.Transaction) error
+			writeFn func(kv.Transaction, *error)
+			readFn  func(kv.Transaction, *error)
 		}{
 			{
 				"DropStore",
-				func(tx *kv.Transaction) error { return tx.CreateStore([]byte("store")) },
-				func(tx *kv.Transaction, err *error) { *err = tx.DropStore([]byte("store")) },
-				func(tx *kv.Transaction, err *error) { *err = tx.CreateStore([]byte("store")) },
+				func(tx kv.Transaction) error { return tx.CreateStore([]byte("store")) },
+				func(tx kv.Transaction, err *error) { *err = tx.DropStore([]byte("store")) },
+				func(tx kv.Transaction, err *error) { *err = tx.CreateStore([]byte("store")) },
 			},
 			{
 				"StorePut",
-				func(tx *kv.Transaction) error { return tx.CreateStore([]byte("store")) },
-				func(tx *kv.Transaction, err *error) {
+				func(tx kv.Transaction) error { return tx.CreateStore([]byte("store")) },
+				func(tx kv.Transaction, err *error) {
 					st := tx.GetStore([]byte("store"))
 					assert.NoError(t, st.Put([]byte("foo"), []byte("FOO")))
 				},
-				func(tx *kv.Transaction, err *error) {
+				func(tx kv.Transaction, err *error) {
 					st := tx.GetStore([]byte("store"))
 					_, *err = st.Get([]byte("foo"))
 				},
@@ -283,13 +321,13 @@ func TestTransactionCommitRollback(t *testing.T) {
 	t.Run("Data should be visible within the same transaction", func(t *testing.T) {
 		tests := []struct {
 			name    string
-			writeFn func(*kv.Store, *error)
-			readFn  func(*kv.Store, *error)
+			writeFn func(kv.Store, *error)
+			readFn  func(kv.Store, *error)
 		}{
 			{
 				"CreateStore",
-				func(st *kv.Store, err *error) { *err = st.Put([]byte("a"), []byte("1")) },
-				func(st *kv.Store, err *error) { _, *err = st.Get([]byte("a")) },
+				func(st kv.Store, err *error) { *err = st.Put([]byte("a"), []byte("1")) },
+				func(st kv.Store, err *error) { _, *err = st.Get([]byte("a")) },
 			},
 		}
 
@@ -317,10 +355,123 @@ func TestTransactionCommitRollback(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("Read-only transactions should read from a snapshot", func(t *testing.T) {
+		ng := builder(t)
+		defer func() {
+			assert.NoError(t, ng.Close())
+		}()
+
+		tx, err := ng.Begin(kv.TxOptions{
+			Writable: true,
+		})
+		assert.NoError(t, err)
+		defer tx.Rollback()
+
+		err = tx.CreateStore([]byte("store"))
+		assert.NoError(t, err)
+		err = tx.GetStore([]byte("store")).Put([]byte("a"), []byte("1"))
+		assert.NoError(t, err)
+		err = tx.Commit()
+		assert.NoError(t, err)
+
+		// start a read-only transaction before writing
+		rtx, err := ng.Begin(kv.TxOptions{
+			Writable: false,
+		})
+		assert.NoError(t, err)
+		defer rtx.Rollback()
+
+		wtx, err := ng.Begin(kv.TxOptions{
+			Writable: true,
+		})
+		assert.NoError(t, err)
+		defer wtx.Rollback()
+
+		st := wtx.GetStore([]byte("store"))
+		assert.NoError(t, st.Put([]byte("a"), []byte("2")))
+		assert.NoError(t, st.Put([]byte("b"), []byte("3")))
+		assert.NoError(t, wtx.Commit())
+
+		rst := rtx.GetStore([]byte("store"))
+		require.Equal(t, []byte("1"), getValue(t, rst, []byte("a")))
+		_, err = rst.Get([]byte("b"))
+		assert.ErrorIs(t, err, kv.ErrKeyNotFound)
+
+		it := rst.Iterator(nil)
+		var count int
+		for it.First(); it.Valid(); it.Next() {
+			count++
+		}
+		assert.NoError(t, it.Close())
+		require.Equal(t, 1, count)
+
+		// a new read-only transaction sees the changes
+		rtx2, err := ng.Begin(kv.TxOptions{
+			Writable: false,
+		})
+		assert.NoError(t, err)
+		defer rtx2.Rollback()
+
+		require.Equal(t, []byte("2"), getValue(t, rtx2.GetStore([]byte("store")), []byte("a")))
+	})
+
+	t.Run("RollbackTo should undo writes made after the savepoint", func(t *testing.T) {
+		ng := builder(t)
+		defer func() {
+			assert.NoError(t, ng.Close())
+		}()
+
+		tx, err := ng.Begin(kv.TxOptions{
+			Writable: true,
+		})
+		assert.NoError(t, err)
+		defer tx.Rollback()
+
+		err = tx.CreateStore([]byte("store"))
+		assert.NoError(t, err)
+		st := tx.GetStore([]byte("store"))
+		assert.NoError(t, st.Put([]byte("a"), []byte("1")))
+		assert.NoError(t, st.Put([]byte("b"), []byte("1")))
+
+		sp, err := tx.Savepoint()
+		assert.NoError(t, err)
+
+		assert.NoError(t, st.Put([]byte("a"), []byte("2")))
+		assert.NoError(t, st.Delete([]byte("b")))
+		assert.NoError(t, st.Put([]byte("c"), []byte("2")))
+
+		assert.NoError(t, tx.RollbackTo(sp))
+
+		require.Equal(t, []byte("1"), getValue(t, st, []byte("a")))
+		require.Equal(t, []byte("1"), getValue(t, st, []byte("b")))
+		_, err = st.Get([]byte("c"))
+		assert.ErrorIs(t, err, kv.ErrKeyNotFound)
+
+		// the savepoint can be used again
+		assert.NoError(t, st.Put([]byte("d"), []byte("3")))
+		assert.NoError(t, tx.RollbackTo(sp))
+		_, err = st.Get([]byte("d"))
+		assert.ErrorIs(t, err, kv.ErrKeyNotFound)
+
+		assert.NoError(t, tx.Commit())
+
+		rtx, err := ng.Begin(kv.TxOptions{
+			Writable: false,
+		})
+		assert.NoError(t, err)
+		defer rtx.Rollback()
+
+		rst := rtx.GetStore([]byte("store"))
+		require.Equal(t, []byte("1"), getValue(t, rst, []byte("a")))
+		require.Equal(t, []byte("1"), getValue(t, rst, []byte("b")))
+		_, err = rst.Get([]byte("c"))
+		assert.ErrorIs(t, err, kv.ErrKeyNotFound)
+	})
 }
 
-// TestTransactionCreateStore verifies CreateStore behaviour.
-func TestTransactionCreateStore(t *testing.T) {
+// testTransactionCreateStore verifies CreateStore behaviour.
+func testTransactionCreateStore(t *testing.T, builder Builder) {
 	t.Run("Should create a store", func(t *testing.T) {
 		ng := builder(t)
 		defer func() {
@@ -359,8 +510,8 @@ func TestTransactionCreateStore(t *testing.T) {
 	})
 }
 
-// TestTransactionGetStore verifies GetStore behaviour.
-func TestTransactionGetStore(t *testing.T) {
+// testTransactionGetStore verifies GetStore behaviour.
+func testTransactionGetStore(t *testing.T, builder Builder) {
 	t.Run("Should return the right store", func(t *testing.T) {
 		ng := builder(t)
 		defer func() {
@@ -400,8 +551,8 @@ func TestTransactionGetStore(t *testing.T) {
 	})
 }
 
-// TestTransactionDropStore verifies DropStore behaviour.
-func TestTransactionDropStore(t *testing.T) {
+// testTransactionDropStore verifies DropStore behaviour.
+func testTransactionDropStore(t *testing.T, builder Builder) {
 	t.Run("Should drop a store", func(t *testing.T) {
 		ng := builder(t)
 		defer func() {
@@ -423,9 +574,53 @@ func TestTransactionDropStore(t *testing.T) {
 		err = tx.CreateStore([]byte("store"))
 		assert.NoError(t, err)
 	})
+
+	t.Run("Should delete the keys of the store only", func(t *testing.T) {
+		ng := builder(t)
+		defer func() {
+			assert.NoError(t, ng.Close())
+		}()
+
+		tx, err := ng.Begin(kv.TxOptions{
+			Writable: true,
+		})
+		assert.NoError(t, err)
+		defer tx.Rollback()
+
+		for _, name := range []string{"store", "store2"} {
+			err = tx.CreateStore([]byte(name))
+			assert.NoError(t, err)
+			err = tx.GetStore([]byte(name)).Put([]byte("foo"), []byte("FOO"))
+			assert.NoError(t, err)
+		}
+		assert.NoError(t, tx.Commit())
+
+		tx, err = ng.Begin(kv.TxOptions{
+			Writable: true,
+		})
+		assert.NoError(t, err)
+		defer tx.Rollback()
+
+		err = tx.DropStore([]byte("store"))
+		assert.NoError(t, err)
+		assert.NoError(t, tx.Commit())
+
+		tx, err = ng.Begin(kv.TxOptions{
+			Writable: true,
+		})
+		assert.NoError(t, err)
+		defer tx.Rollback()
+
+		err = tx.CreateStore([]byte("store"))
+		assert.NoError(t, err)
+		_, err = tx.GetStore([]byte("store")).Get([]byte("foo"))
+		assert.ErrorIs(t, err, kv.ErrKeyNotFound)
+
+		require.Equal(t, []byte("FOO"), getValue(t, tx.GetStore([]byte("store2")), []byte("foo")))
+	})
 }
 
-func storeBuilder(t testing.TB) (*kv.Store, func()) {
+func storeBuilder(t testing.TB, builder Builder) (kv.Store, func()) {
 	ng := builder(t)
 	tx, err := ng.Begin(kv.TxOptions{
 		Writable: true,
@@ -442,10 +637,10 @@ func storeBuilder(t testing.TB) (*kv.Store, func()) {
 	}
 }
 
-// TestStorePut verifies Put behaviour.
-func TestStorePut(t *testing.T) {
+// testStorePut verifies Put behaviour.
+func testStorePut(t *testing.T, builder Builder) {
 	t.Run("Should insert data", func(t *testing.T) {
-		st, cleanup := storeBuilder(t)
+		st, cleanup := storeBuilder(t, builder)
 		defer cleanup()
 
 		err := st.Put([]byte("foo"), []byte("FOO"))
@@ -456,7 +651,7 @@ func TestStorePut(t *testing.T) {
 	})
 
 	t.Run("Should replace existing key", func(t *testing.T) {
-		st, cleanup := storeBuilder(t)
+		st, cleanup := storeBuilder(t, builder)
 		defer cleanup()
 
 		err := st.Put([]byte("foo"), []byte("FOO"))
@@ -470,7 +665,7 @@ func TestStorePut(t *testing.T) {
 	})
 
 	t.Run("Should fail when key is nil or empty", func(t *testing.T) {
-		st, cleanup := storeBuilder(t)
+		st, cleanup := storeBuilder(t, builder)
 		defer cleanup()
 
 		err := st.Put(nil, []byte("FOO"))
@@ -481,7 +676,7 @@ func TestStorePut(t *testing.T) {
 	})
 
 	t.Run("Should fail when value is nil or empty", func(t *testing.T) {
-		st, cleanup := storeBuilder(t)
+		st, cleanup := storeBuilder(t, builder)
 		defer cleanup()
 
 		err := st.Put([]byte("foo"), nil)
@@ -492,10 +687,10 @@ func TestStorePut(t *testing.T) {
 	})
 }
 
-// TestStoreGet verifies Get behaviour.
-func TestStoreGet(t *testing.T) {
+// testStoreGet verifies Get behaviour.
+func testStoreGet(t *testing.T, builder Builder) {
 	t.Run("Should fail if not found", func(t *testing.T) {
-		st, cleanup := storeBuilder(t)
+		st, cleanup := storeBuilder(t, builder)
 		defer cleanup()
 
 		r, err := st.Get([]byte("id"))
@@ -504,7 +699,7 @@ func TestStoreGet(t *testing.T) {
 	})
 
 	t.Run("Should return the right key", func(t *testing.T) {
-		st, cleanup := storeBuilder(t)
+		st, cleanup := storeBuilder(t, builder)
 		defer cleanup()
 
 		err := st.Put([]byte("foo"), []byte("FOO"))
@@ -520,10 +715,10 @@ func TestStoreGet(t *testing.T) {
 	})
 }
 
-// TestStoreDelete verifies Delete behaviour.
-func TestStoreDelete(t *testing.T) {
+// testStoreDelete verifies Delete behaviour.
+func testStoreDelete(t *testing.T, builder Builder) {
 	t.Run("Should fail if not found", func(t *testing.T) {
-		st, cleanup := storeBuilder(t)
+		st, cleanup := storeBuilder(t, builder)
 		defer cleanup()
 
 		err := st.Delete([]byte("id"))
@@ -531,7 +726,7 @@ func TestStoreDelete(t *testing.T) {
 	})
 
 	t.Run("Should delete the right document", func(t *testing.T) {
-		st, cleanup := storeBuilder(t)
+		st, cleanup := storeBuilder(t, builder)
 		defer cleanup()
 
 		err := st.Put([]byte("foo"), []byte("FOO"))
@@ -559,7 +754,7 @@ func TestStoreDelete(t *testing.T) {
 		defer it.Close()
 		i := 0
 		for it.First(); it.Valid(); it.Next() {
-			require.Equal(t, []byte("foo"), kv.TrimPrefix(it.Key(), st.Prefix))
+			require.Equal(t, []byte("foo"), it.Key())
 			i++
 		}
 		require.Equal(t, 1, i)
@@ -605,10 +800,10 @@ func TestStoreDelete(t *testing.T) {
 	})
 }
 
-// TestStoreTruncate verifies Truncate behaviour.
-func TestStoreTruncate(t *testing.T) {
+// testStoreTruncate verifies Truncate behaviour.
+func testStoreTruncate(t *testing.T, builder Builder) {
 	t.Run("Should succeed if store is empty", func(t *testing.T) {
-		st, cleanup := storeBuilder(t)
+		st, cleanup := storeBuilder(t, builder)
 		defer cleanup()
 
 		err := st.Truncate()
@@ -616,7 +811,7 @@ func TestStoreTruncate(t *testing.T) {
 	})
 
 	t.Run("Should truncate the store", func(t *testing.T) {
-		st, cleanup := storeBuilder(t)
+		st, cleanup := storeBuilder(t, builder)
 		defer cleanup()
 
 		err := st.Put([]byte("foo"), []byte("FOO"))
@@ -633,10 +828,71 @@ func TestStoreTruncate(t *testing.T) {
 		assert.NoError(t, it.Error())
 		require.False(t, it.Valid())
 	})
+
+	t.Run("Should keep the keys written after truncating", func(t *testing.T) {
+		ng := builder(t)
+		defer func() {
+			assert.NoError(t, ng.Close())
+		}()
+
+		tx, err := ng.Begin(kv.TxOptions{
+			Writable: true,
+		})
+		assert.NoError(t, err)
+		defer tx.Rollback()
+
+		err = tx.CreateStore([]byte("test"))
+		assert.NoError(t, err)
+		st := tx.GetStore([]byte("test"))
+		err = st.Put([]byte("foo"), []byte("FOO"))
+		assert.NoError(t, err)
+		assert.NoError(t, tx.Commit())
+
+		tx, err = ng.Begin(kv.TxOptions{
+			Writable: true,
+		})
+		assert.NoError(t, err)
+		defer tx.Rollback()
+		st = tx.GetStore([]byte("test"))
+
+		sp, err := tx.Savepoint()
+		assert.NoError(t, err)
+
+		err = st.Truncate()
+		assert.NoError(t, err)
+		err = st.Put([]byte("bar"), []byte("BAR"))
+		assert.NoError(t, err)
+
+		_, err = st.Get([]byte("foo"))
+		assert.ErrorIs(t, err, kv.ErrKeyNotFound)
+		require.Equal(t, []byte("BAR"), getValue(t, st, []byte("bar")))
+
+		// rolling back to the savepoint restores the truncated keys
+		err = tx.RollbackTo(sp)
+		assert.NoError(t, err)
+		require.Equal(t, []byte("FOO"), getValue(t, st, []byte("foo")))
+		_, err = st.Get([]byte("bar"))
+		assert.ErrorIs(t, err, kv.ErrKeyNotFound)
+
+		err = st.Truncate()
+		assert.NoError(t, err)
+		err = st.Put([]byte("bar"), []byte("BAR"))
+		assert.NoError(t, err)
+		assert.NoError(t, tx.Commit())
+
+		tx, err = ng.Begin(kv.TxOptions{})
+		assert.NoError(t, err)
+		defer tx.Rollback()
+		st = tx.GetStore([]byte("test"))
+
+		_, err = st.Get([]byte("foo"))
+		assert.ErrorIs(t, err, kv.ErrKeyNotFound)
+		require.Equal(t, []byte("BAR"), getValue(t, st, []byte("bar")))
+	})
 }
 
-// TestQueries test simple queries against the kv.
-func TestQueries(t *testing.T) {
+// testQueries test simple queries against the kv.
+func testQueries(t *testing.T, builder Builder) {
 	t.Run("SELECT", func(t *testing.T) {
 		ng := builder(t)
 		defer func() {
@@ -747,8 +1003,8 @@ func TestQueries(t *testing.T) {
 	})
 }
 
-// TestQueriesSameTransaction test simple queries in the same transaction.
-func TestQueriesSameTransaction(t *testing.T) {
+// testQueriesSameTransaction test simple queries in the same transaction.
+func testQueriesSameTransaction(t *testing.T, builder Builder) {
 	t.Run("SELECT", func(t *testing.T) {
 		ng := builder(t)
 		defer func() {
@@ -848,36 +1104,183 @@ func TestQueriesSameTransaction(t *testing.T) {
 	})
 }
 
-func TestTransient(t *testing.T) {
-	ng := testutil.NewEngine(t)
+func testTransient(t *testing.T, builder Builder) {
+	ng := builder(t)
+	defer ng.Close()
 
 	ts, err := ng.NewTransientStore()
 	assert.NoError(t, err)
 
-	dir := ts.Path
-
 	err = ts.Put([]byte("foo"), []byte("bar"))
 	assert.NoError(t, err)
+	err = ts.Put([]byte("baz"), []byte("qux"))
+	assert.NoError(t, err)
 
 	it := ts.Iterator(nil)
-	defer it.Close()
 
 	it.SeekGE([]byte("foo"))
 	require.True(t, it.Valid())
+	require.Equal(t, []byte("foo"), it.Key())
+	require.Equal(t, []byte("bar"), it.Value())
+	assert.NoError(t, it.Close())
+
+	it = ts.Iterator(&kv.IterOptions{UpperBound: []byte("foo")})
+	var keys []string
+	for it.First(); it.Valid(); it.Next() {
+		keys = append(keys, string(it.Key()))
+	}
+	assert.NoError(t, it.Close())
+	require.Equal(t, []string{"baz"}, keys)
 
-	err = ts.Drop()
+	err = ts.Reset()
 	assert.NoError(t, err)
 
-	_, err = os.Stat(dir)
-	require.True(t, os.IsNotExist(err))
-}
+	it = ts.Iterator(nil)
+	require.False(t, it.First())
+	assert.NoError(t, it.Close())
 
-func tempDir(t testing.TB) string {
-	dir, err := ioutil.TempDir("", "genji")
+	err = ts.Drop()
 	assert.NoError(t, err)
+}
+
+// testLoader verifies the behaviour of loaders.
+func testLoader(t *testing.T, builder Builder) {
+	setup := func(t *testing.T) kv.Engine {
+		ng := builder(t)
+		t.Cleanup(func() {
+			assert.NoError(t, ng.Close())
+		})
+
+		tx, err := ng.Begin(kv.TxOptions{
+			Writable: true,
+		})
+		assert.NoError(t, err)
+		defer tx.Rollback()
+
+		for _, name := range []string{"a", "b"} {
+			err = tx.CreateStore([]byte(name))
+			assert.NoError(t, err)
+		}
+		err = tx.GetStore([]byte("a")).Put([]byte("0"), []byte("existing"))
+		assert.NoError(t, err)
+		assert.NoError(t, tx.Commit())
+
+		return ng
+	}
+
+	keys := func(t *testing.T, ng kv.Engine, store string) []string {
+		tx, err := ng.Begin(kv.TxOptions{})
+		assert.NoError(t, err)
+		defer tx.Rollback()
+
+		var list []string
+		it := tx.GetStore([]byte(store)).Iterator(nil)
+		for it.First(); it.Valid(); it.Next() {
+			list = append(list, string(it.Key())+"="+string(it.Value()))
+		}
+		assert.NoError(t, it.Close())
+		return list
+	}
+
+	t.Run("Should write the keys in order on commit", func(t *testing.T) {
+		ng := setup(t)
+
+		l, err := ng.NewLoader()
+		assert.NoError(t, err)
+		defer l.Close()
+
+		for _, k := range []string{"3", "1", "2"} {
+			err = l.Put([]byte("b"), []byte(k), []byte("b"+k))
+			assert.NoError(t, err)
+			err = l.Put([]byte("a"), []byte(k), []byte("a"+k))
+			assert.NoError(t, err)
+		}
+
+		// keys are not visible before commit
+		require.Equal(t, []string{"0=existing"}, keys(t, ng, "a"))
+		require.Empty(t, keys(t, ng, "b"))
+
+		var calls []string
+		err = l.Commit(func(store, k, v []byte) error {
+			calls = append(calls, string(store)+":"+string(k)+"="+string(v))
+			return nil
+		})
+		assert.NoError(t, err)
+
+		require.Equal(t, []string{"a:1=a1", "a:2=a2", "a:3=a3", "b:1=b1", "b:2=b2", "b:3=b3"}, calls)
+		require.Equal(t, []string{"0=existing", "1=a1", "2=a2", "3=a3"}, keys(t, ng, "a"))
+		require.Equal(t, []string{"1=b1", "2=b2", "3=b3"}, keys(t, ng, "b"))
+
+		err = l.Put([]byte("a"), []byte("4"), []byte("a4"))
+		require.Error(t, err)
+	})
+
+	t.Run("Should discard the keys on close", func(t *testing.T) {
+		ng := setup(t)
+
+		l, err := ng.NewLoader()
+		assert.NoError(t, err)
+
+		err = l.Put([]byte("b"), []byte("1"), []byte("b1"))
+		assert.NoError(t, err)
+		assert.NoError(t, l.Close())
+
+		require.Empty(t, keys(t, ng, "b"))
+	})
+
+	t.Run("Should fail if the store doesn't exist", func(t *testing.T) {
+		ng := setup(t)
+
+		l, err := ng.NewLoader()
+		assert.NoError(t, err)
+		defer l.Close()
+
+		err = l.Put([]byte("c"), []byte("1"), []byte("c1"))
+		assert.ErrorIs(t, err, kv.ErrStoreNotFound)
+	})
+
+	t.Run("Should fail on duplicate keys", func(t *testing.T) {
+		ng := setup(t)
+
+		l, err := ng.NewLoader()
+		assert.NoError(t, err)
+		defer l.Close()
+
+		for _, k := range []string{"1", "2", "1"} {
+			err = l.Put([]byte("b"), []byte(k), []byte("b"+k))
+			assert.NoError(t, err)
+		}
+		err = l.Put([]byte("a"), []byte("1"), []byte("a1"))
+		assert.NoError(t, err)
+
+		err = l.Commit(nil)
+		assert.ErrorIs(t, err, kv.ErrDuplicateKey)
+
+		require.Equal(t, []string{"0=existing"}, keys(t, ng, "a"))
+		require.Empty(t, keys(t, ng, "b"))
+	})
+
+	t.Run("Should not write anything if fn fails", func(t *testing.T) {
+		ng := setup(t)
+
+		l, err := ng.NewLoader()
+		assert.NoError(t, err)
+		defer l.Close()
+
+		for _, k := range []string{"1", "2", "3"} {
+			err = l.Put([]byte("a"), []byte(k), []byte("a"+k))
+			assert.NoError(t, err)
+		}
+
+		errStop := errors.New("stop")
+		err = l.Commit(func(store, k, v []byte) error {
+			if string(k) == "3" {
+				return errStop
+			}
+			return nil
+		})
+		assert.ErrorIs(t, err, errStop)
 
-	t.Cleanup(func() {
-		os.RemoveAll(dir)
+		require.Equal(t, []string{"0=existing"}, keys(t, ng, "a"))
 	})
-	return dir
 }
@@ -0,0 +1,713 @@
+// Package memkv implements an in-memory kv engine.
+// It is much cheaper to open than a Pebble engine and is used
+// for in-memory databases and tests.
+package memkv
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/pebble"
+	"github.com/genjidb/genji/internal/kv"
+	"github.com/genjidb/genji/internal/kv/pebblekv"
+)
+
+// ErrEngineClosed is returned when beginning a transaction on a closed engine.
+var ErrEngineClosed = errors.New("engine is closed")
+
+// maximum size of the batches written by Checkpoint.
+const checkpointBatchSize = 4 << 20
+
+// Engine is an in-memory kv engine.
+// Its data is stored in an immutable tree: read-only transactions
+// take a snapshot of the tree when they begin and never block each other.
+// Like Pebble batches, read/write transactions see the latest committed data
+// plus their own writes: they keep their writes in a separate tree, which is merged
+// with the committed one when reading. On commit, their writes are applied to the committed tree.
+type Engine struct {
+	mu     sync.Mutex
+	root   *node
+	closed bool
+}
+
+// NewEngine creates an empty in-memory engine.
+func NewEngine() *Engine {
+	return &Engine{}
+}
+
+// Begin a transaction.
+func (e *Engine) Begin(opts kv.TxOptions) (kv.Transaction, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return nil, errors.WithStack(ErrEngineClosed)
+	}
+
+	return &Transaction{
+		ng:       e,
+		root:     e.root,
+		writable: opts.Writable,
+	}, nil
+}
+
+// NewTransientStore creates an in-memory transient store.
+func (e *Engine) NewTransientStore() (kv.TransientStore, error) {
+	return &TransientStore{}, nil
+}
+
+// ReadOnly always returns false.
+func (e *Engine) ReadOnly() bool {
+	return false
+}
+
+// Checkpoint writes the committed data in a new Pebble database
+// created in the given directory, which must not exist.
+func (e *Engine) Checkpoint(dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(dir); err == nil {
+		return errors.Errorf("%q already exists", dir)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	e.mu.Lock()
+	root := e.root
+	e.mu.Unlock()
+
+	ng, err := pebblekv.NewEngine(dir, pebblekv.Options{})
+	if err != nil {
+		return err
+	}
+	defer ng.Close()
+
+	b := ng.DB.NewBatch()
+	err = walk(root, nil, nil, func(n *node) error {
+		err := b.Set(n.key, n.value, nil)
+		if err != nil {
+			return err
+		}
+
+		if len(b.Repr()) < checkpointBatchSize {
+			return nil
+		}
+
+		err = b.Commit(pebble.NoSync)
+		if err != nil {
+			return err
+		}
+		b = ng.DB.NewBatch()
+		return nil
+	})
+	if err != nil {
+		_ = b.Close()
+		return err
+	}
+
+	return b.Commit(pebble.Sync)
+}
+
+// Compact always returns 0: deleted keys are removed from the tree
+// when the transaction commits and their memory is released by the garbage collector.
+func (e *Engine) Compact() (int64, error) {
+	return 0, nil
+}
+
+// Stats only reports the size of the data held in memory.
+func (e *Engine) Stats() kv.Stats {
+	e.mu.Lock()
+	root := e.root
+	e.mu.Unlock()
+
+	size, _ := treeSize(root, nil, nil)
+	return kv.Stats{MemTableSize: size}
+}
+
+// StoreSize returns the size of the keys and values of the store.
+func (e *Engine) StoreSize(name []byte) (uint64, error) {
+	e.mu.Lock()
+	root := e.root
+	e.mu.Unlock()
+
+	prefix := kv.StorePrefix(name)
+	defer kv.ReleaseKey(prefix)
+	start := kv.BuildKey(prefix, nil)
+	defer kv.ReleaseKey(start)
+	end := kv.BuildEndKey(prefix)
+	defer kv.ReleaseKey(end)
+
+	return treeSize(root, start, end)
+}
+
+// treeSize returns the size of the keys and values between start and end.
+func treeSize(root *node, start, end []byte) (uint64, error) {
+	var size uint64
+	err := walk(root, start, end, func(n *node) error {
+		size += uint64(len(n.key) + len(n.value))
+		return nil
+	})
+
+	return size, err
+}
+
+// Close the engine and release its data.
+func (e *Engine) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.closed = true
+	e.root = nil
+	return nil
+}
+
+// A keyRange is a range of keys deleted by a transaction,
+// between start (inclusive) and end (exclusive).
+type keyRange struct {
+	start, end []byte
+}
+
+func (r *keyRange) contains(key []byte) bool {
+	return bytes.Compare(key, r.start) >= 0 && bytes.Compare(key, r.end) < 0
+}
+
+// A Transaction reads from a snapshot of the engine, if read-only.
+// Otherwise, it keeps its writes in its own tree, which is merged with
+// the latest committed tree when reading and applied to it on commit.
+type Transaction struct {
+	ng *Engine
+	// committed tree when the transaction began, for read-only transactions.
+	root *node
+	// writes of the transaction. Deleted keys are kept as tombstones.
+	writes *node
+	// ranges deleted by the transaction. The keys of writes
+	// were all written after the ranges were deleted.
+	deletedRanges []keyRange
+	writable      bool
+	discarded     bool
+}
+
+// Rollback the transaction. Can be used safely after commit.
+func (t *Transaction) Rollback() error {
+	if t.discarded {
+		return errors.WithStack(kv.ErrTransactionDiscarded)
+	}
+
+	t.discarded = true
+	return nil
+}
+
+// Commit the transaction.
+func (t *Transaction) Commit() error {
+	if t.discarded {
+		return errors.WithStack(kv.ErrTransactionDiscarded)
+	}
+
+	if !t.writable {
+		return kv.ErrTransactionReadOnly
+	}
+
+	t.discarded = true
+
+	t.ng.mu.Lock()
+	defer t.ng.mu.Unlock()
+
+	if t.ng.closed {
+		return errors.WithStack(ErrEngineClosed)
+	}
+
+	root := t.ng.root
+	for _, r := range t.deletedRanges {
+		root = removeRange(root, r.start, r.end)
+	}
+
+	_ = walk(t.writes, nil, nil, func(n *node) error {
+		if n.tombstone {
+			root = remove(root, n.key)
+		} else {
+			root = put(root, n.key, n.value)
+		}
+		return nil
+	})
+
+	t.ng.root = root
+	return nil
+}
+
+// view returns the tree read by the transaction.
+func (t *Transaction) view() *view {
+	if !t.writable {
+		return &view{base: t.root}
+	}
+
+	t.ng.mu.Lock()
+	base := t.ng.root
+	t.ng.mu.Unlock()
+
+	return &view{base: base, writes: t.writes, deletedRanges: t.deletedRanges}
+}
+
+// get returns the node read by the transaction for the given key.
+func (t *Transaction) get(key []byte) *node {
+	if !t.writable {
+		return get(t.root, key)
+	}
+
+	return t.view().get(key)
+}
+
+// savepoint records the state of the transaction when the savepoint was created.
+type savepoint struct {
+	writes        *node
+	deletedRanges int
+}
+
+// Savepoint creates a savepoint at the current position of the transaction.
+func (t *Transaction) Savepoint() (kv.Savepoint, error) {
+	if t.discarded {
+		return nil, errors.WithStack(kv.ErrTransactionDiscarded)
+	}
+
+	return &savepoint{writes: t.writes, deletedRanges: len(t.deletedRanges)}, nil
+}
+
+// RollbackTo undoes every write made after the given savepoint.
+func (t *Transaction) RollbackTo(s kv.Savepoint) error {
+	if t.discarded {
+		return errors.WithStack(kv.ErrTransactionDiscarded)
+	}
+
+	sp, ok := s.(*savepoint)
+	if !ok || sp.deletedRanges > len(t.deletedRanges) {
+		return errors.New("invalid savepoint")
+	}
+
+	t.writes = sp.writes
+	// the ranges might still be read by iterators: the next
+	// deleted range must not overwrite them
+	t.deletedRanges = t.deletedRanges[:sp.deletedRanges:sp.deletedRanges]
+	return nil
+}
+
+// ReleaseSavepoint destroys the savepoint. Savepoints don't hold any resource.
+func (t *Transaction) ReleaseSavepoint(s kv.Savepoint) error {
+	if t.discarded {
+		return errors.WithStack(kv.ErrTransactionDiscarded)
+	}
+
+	return nil
+}
+
+func (t *Transaction) set(key, value []byte) {
+	t.writes = put(t.writes, key, value)
+}
+
+func (t *Transaction) delete(key []byte) {
+	t.writes = putTombstone(t.writes, key)
+}
+
+func (t *Transaction) deleteRange(start, end []byte) {
+	t.writes = removeRange(t.writes, start, end)
+	t.deletedRanges = append(t.deletedRanges, keyRange{start: start, end: end})
+}
+
+// GetStore returns a store by name.
+func (t *Transaction) GetStore(name []byte) kv.Store {
+	return &Store{
+		tx:     t,
+		prefix: kv.StorePrefix(name),
+		name:   name,
+	}
+}
+
+// CreateStore creates a store.
+// If the store already exists, returns ErrStoreAlreadyExists.
+func (t *Transaction) CreateStore(name []byte) error {
+	if !t.writable {
+		return errors.WithStack(kv.ErrTransactionReadOnly)
+	}
+
+	key := kv.StoreKey(name)
+	if t.get(key) != nil {
+		return errors.WithStack(kv.ErrStoreAlreadyExists)
+	}
+
+	t.set(key, nil)
+	return nil
+}
+
+// StoreExists returns true if a store with the given name exists.
+func (t *Transaction) StoreExists(name []byte) (bool, error) {
+	return t.get(kv.StoreKey(name)) != nil, nil
+}
+
+// DropStore deletes the store and all its keys.
+func (t *Transaction) DropStore(name []byte) error {
+	if !t.writable {
+		return errors.WithStack(kv.ErrTransactionReadOnly)
+	}
+
+	err := t.GetStore(name).Truncate()
+	if err != nil {
+		return err
+	}
+
+	t.delete(kv.StoreKey(name))
+	return nil
+}
+
+// A Store prefixes all of its keys with the prefix of the store.
+type Store struct {
+	tx     *Transaction
+	prefix []byte
+	name   []byte
+}
+
+// Put stores a key value pair. If it already exists, it overrides it.
+func (s *Store) Put(k, v []byte) error {
+	if !s.tx.writable {
+		return kv.ErrTransactionReadOnly
+	}
+
+	if len(k) == 0 {
+		return errors.New("cannot store empty key")
+	}
+
+	if len(v) == 0 {
+		return errors.New("cannot store empty value")
+	}
+
+	// keys built by kv.BuildKey are owned by the caller
+	// as long as they are not released.
+	s.tx.set(kv.BuildKey(s.prefix, k), append([]byte(nil), v...))
+	return nil
+}
+
+// Get returns a value associated with the given key. If not found, returns ErrKeyNotFound.
+func (s *Store) Get(k []byte) ([]byte, error) {
+	key := kv.BuildKey(s.prefix, k)
+	n := s.tx.get(key)
+	kv.ReleaseKey(key)
+	if n == nil {
+		return nil, errors.WithStack(kv.ErrKeyNotFound)
+	}
+
+	return append([]byte(nil), n.value...), nil
+}
+
+// Exists returns true if the key exists.
+func (s *Store) Exists(k []byte) (bool, error) {
+	key := kv.BuildKey(s.prefix, k)
+	n := s.tx.get(key)
+	kv.ReleaseKey(key)
+
+	return n != nil, nil
+}
+
+// Delete a record by key. If not found, returns ErrKeyNotFound.
+func (s *Store) Delete(k []byte) error {
+	if !s.tx.writable {
+		return kv.ErrTransactionReadOnly
+	}
+
+	key := kv.BuildKey(s.prefix, k)
+	if s.tx.get(key) == nil {
+		kv.ReleaseKey(key)
+		return errors.WithStack(kv.ErrKeyNotFound)
+	}
+
+	s.tx.delete(key)
+	return nil
+}
+
+// Truncate deletes all the records of the store.
+func (s *Store) Truncate() error {
+	if !s.tx.writable {
+		return kv.ErrTransactionReadOnly
+	}
+
+	if s.tx.get(kv.StoreKey(s.name)) == nil {
+		return errors.WithStack(kv.ErrKeyNotFound)
+	}
+
+	s.tx.deleteRange(kv.BuildKey(s.prefix, nil), kv.BuildEndKey(s.prefix))
+	return nil
+}
+
+// Iterator returns an iterator over the keys of the store.
+// The iterator reads from the state of the transaction
+// when the iterator was created.
+func (s *Store) Iterator(opts *kv.IterOptions) kv.Iterator {
+	if opts == nil {
+		opts = &kv.IterOptions{}
+	}
+
+	it := iterator{
+		view:   s.tx.view(),
+		prefix: s.prefix,
+		lower:  kv.BuildKey(s.prefix, opts.LowerBound),
+	}
+	if opts.UpperBound != nil {
+		it.upper = kv.BuildKey(s.prefix, opts.UpperBound)
+	} else {
+		it.upper = kv.BuildEndKey(s.prefix)
+	}
+
+	return &it
+}
+
+// iterator iterates over a snapshot of the tree.
+// Each move looks up the next node from the root.
+type iterator struct {
+	view *view
+	// prefix of the store, nil for transient stores.
+	prefix []byte
+	// absolute bounds. If upper is nil, the iterator is unbounded.
+	lower, upper []byte
+	cur          *node
+}
+
+func (it *iterator) check() bool {
+	if it.cur == nil {
+		return false
+	}
+
+	if it.upper != nil && bytes.Compare(it.cur.key, it.upper) >= 0 {
+		it.cur = nil
+		return false
+	}
+
+	if bytes.Compare(it.cur.key, it.lower) < 0 {
+		it.cur = nil
+		return false
+	}
+
+	return true
+}
+
+func (it *iterator) First() bool {
+	it.cur = it.view.seekGE(it.lower, true)
+	return it.check()
+}
+
+func (it *iterator) Last() bool {
+	it.cur = it.view.seekLT(it.upper)
+	return it.check()
+}
+
+func (it *iterator) SeekGE(key []byte) bool {
+	k := key
+	if it.prefix != nil {
+		k = kv.BuildKey(it.prefix, key)
+		defer kv.ReleaseKey(k)
+	}
+
+	if bytes.Compare(k, it.lower) < 0 {
+		k = it.lower
+	}
+
+	it.cur = it.view.seekGE(k, true)
+	return it.check()
+}
+
+func (it *iterator) Next() bool {
+	if it.cur == nil {
+		return false
+	}
+
+	it.cur = it.view.seekGE(it.cur.key, false)
+	return it.check()
+}
+
+func (it *iterator) Prev() bool {
+	if it.cur == nil {
+		return false
+	}
+
+	it.cur = it.view.seekLT(it.cur.key)
+	return it.check()
+}
+
+func (it *iterator) Valid() bool {
+	return it.cur != nil
+}
+
+func (it *iterator) Key() []byte {
+	if it.prefix == nil {
+		return it.cur.key
+	}
+
+	return kv.TrimPrefix(it.cur.key, it.prefix)
+}
+
+func (it *iterator) Value() []byte {
+	return it.cur.value
+}
+
+func (it *iterator) Error() error {
+	return nil
+}
+
+func (it *iterator) Close() error {
+	return nil
+}
+
+// A view merges the writes of a transaction with a committed tree.
+type view struct {
+	base          *node
+	writes        *node
+	deletedRanges []keyRange
+}
+
+// deletedRange returns the range deleted by the transaction containing key, if any.
+func (v *view) deletedRange(key []byte) *keyRange {
+	for i := range v.deletedRanges {
+		if v.deletedRanges[i].contains(key) {
+			return &v.deletedRanges[i]
+		}
+	}
+
+	return nil
+}
+
+// get returns the node with the given key.
+func (v *view) get(key []byte) *node {
+	if n := get(v.writes, key); n != nil {
+		if n.tombstone {
+			return nil
+		}
+		return n
+	}
+
+	if v.deletedRange(key) != nil {
+		return nil
+	}
+
+	return get(v.base, key)
+}
+
+// seekGE returns the node with the smallest key greater than key,
+// or equal to key if inclusive is true.
+func (v *view) seekGE(key []byte, inclusive bool) *node {
+	seek := seekGT
+	if inclusive {
+		seek = seekGE
+	}
+
+	for {
+		b, w := v.seekBase(key, seek), seek(v.writes, key)
+
+		// the writes of the transaction replace the committed nodes
+		if w == nil || (b != nil && bytes.Compare(w.key, b.key) > 0) {
+			return b
+		}
+		if !w.tombstone {
+			return w
+		}
+
+		key, seek = w.key, seekGT
+	}
+}
+
+// seekBase returns the first committed node following key,
+// skipping the ones of the deleted ranges.
+func (v *view) seekBase(key []byte, seek func(n *node, key []byte) *node) *node {
+	for {
+		b := seek(v.base, key)
+		if b == nil {
+			return nil
+		}
+
+		r := v.deletedRange(b.key)
+		if r == nil {
+			return b
+		}
+
+		key, seek = r.end, seekGE
+	}
+}
+
+// seekLT returns the node with the greatest key lower than key.
+// If key is nil, it returns the node with the greatest key.
+func (v *view) seekLT(key []byte) *node {
+	for {
+		b, w := v.seekBaseLT(key), seekLT(v.writes, key)
+
+		if w == nil || (b != nil && bytes.Compare(w.key, b.key) < 0) {
+			return b
+		}
+		if !w.tombstone {
+			return w
+		}
+
+		key = w.key
+	}
+}
+
+// seekBaseLT returns the last committed node preceding key,
+// skipping the ones of the deleted ranges.
+func (v *view) seekBaseLT(key []byte) *node {
+	for {
+		b := seekLT(v.base, key)
+		if b == nil {
+			return nil
+		}
+
+		r := v.deletedRange(b.key)
+		if r == nil {
+			return b
+		}
+
+		key = r.start
+	}
+}
+
+// A TransientStore is an in-memory transient store.
+type TransientStore struct {
+	root *node
+}
+
+// Put stores a key value pair. If it already exists, it overrides it.
+func (s *TransientStore) Put(k, v []byte) error {
+	if len(k) == 0 {
+		return errors.New("cannot store empty key")
+	}
+
+	if len(v) == 0 {
+		return errors.New("cannot store empty value")
+	}
+
+	s.root = put(s.root, append([]byte(nil), k...), append([]byte(nil), v...))
+	return nil
+}
+
+// Iterator returns an iterator over the keys of the store.
+func (s *TransientStore) Iterator(opts *kv.IterOptions) kv.Iterator {
+	it := iterator{
+		view: &view{base: s.root},
+	}
+	if opts != nil {
+		it.lower = opts.LowerBound
+		it.upper = opts.UpperBound
+	}
+
+	return &it
+}
+
+// Reset deletes the content of the store.
+func (s *TransientStore) Reset() error {
+	s.root = nil
+	return nil
+}
+
+// Drop releases the content of the store.
+func (s *TransientStore) Drop() error {
+	s.root = nil
+	return nil
+}
@@ -0,0 +1,185 @@
+package memkv_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/genjidb/genji/internal/kv"
+	"github.com/genjidb/genji/internal/kv/kvtest"
+	"github.com/genjidb/genji/internal/kv/memkv"
+	"github.com/genjidb/genji/internal/kv/pebblekv"
+	"github.com/genjidb/genji/internal/testutil/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func builder(t testing.TB) kv.Engine {
+	return memkv.NewEngine()
+}
+
+func TestEngine(t *testing.T) {
+	kvtest.TestSuite(t, builder)
+}
+
+func TestConcurrentCommits(t *testing.T) {
+	ng := memkv.NewEngine()
+	defer ng.Close()
+
+	tx, err := ng.Begin(kv.TxOptions{Writable: true})
+	assert.NoError(t, err)
+	assert.NoError(t, tx.CreateStore([]byte("a")))
+	assert.NoError(t, tx.CreateStore([]byte("b")))
+	assert.NoError(t, tx.GetStore([]byte("a")).Put([]byte("1"), []byte("1")))
+	assert.NoError(t, tx.Commit())
+
+	tx1, err := ng.Begin(kv.TxOptions{Writable: true})
+	assert.NoError(t, err)
+	tx2, err := ng.Begin(kv.TxOptions{Writable: true})
+	assert.NoError(t, err)
+	rtx, err := ng.Begin(kv.TxOptions{})
+	assert.NoError(t, err)
+	defer rtx.Rollback()
+
+	assert.NoError(t, tx1.GetStore([]byte("a")).Put([]byte("2"), []byte("2")))
+	assert.NoError(t, tx2.GetStore([]byte("b")).Put([]byte("1"), []byte("1")))
+	assert.NoError(t, tx2.DropStore([]byte("a")))
+	assert.NoError(t, tx2.CreateStore([]byte("a")))
+	assert.NoError(t, tx1.Commit())
+	// the writes of tx2 are applied on top of the ones of tx1
+	assert.NoError(t, tx2.Commit())
+
+	tx, err = ng.Begin(kv.TxOptions{})
+	assert.NoError(t, err)
+	defer tx.Rollback()
+
+	ok, err := tx.GetStore([]byte("a")).Exists([]byte("2"))
+	assert.NoError(t, err)
+	require.False(t, ok)
+	ok, err = tx.GetStore([]byte("b")).Exists([]byte("1"))
+	assert.NoError(t, err)
+	require.True(t, ok)
+
+	// read-only transactions are not affected
+	v, err := rtx.GetStore([]byte("a")).Get([]byte("1"))
+	assert.NoError(t, err)
+	require.Equal(t, []byte("1"), v)
+	ok, err = rtx.StoreExists([]byte("b"))
+	assert.NoError(t, err)
+	require.True(t, ok)
+	ok, err = rtx.GetStore([]byte("b")).Exists([]byte("1"))
+	assert.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestWritesMergedWithCommits(t *testing.T) {
+	ng := memkv.NewEngine()
+	defer ng.Close()
+
+	put := func(tx kv.Transaction, keys ...string) {
+		for _, k := range keys {
+			assert.NoError(t, tx.GetStore([]byte("a")).Put([]byte(k), []byte(k)))
+		}
+	}
+
+	keys := func(tx kv.Transaction, reverse bool) []string {
+		it := tx.GetStore([]byte("a")).Iterator(nil)
+		defer it.Close()
+
+		var keys []string
+		if reverse {
+			for it.Last(); it.Valid(); it.Prev() {
+				keys = append([]string{string(it.Key())}, keys...)
+			}
+		} else {
+			for it.First(); it.Valid(); it.Next() {
+				keys = append(keys, string(it.Key()))
+			}
+		}
+		assert.NoError(t, it.Error())
+		return keys
+	}
+
+	commit := func(keys ...string) {
+		tx, err := ng.Begin(kv.TxOptions{Writable: true})
+		assert.NoError(t, err)
+		put(tx, keys...)
+		assert.NoError(t, tx.Commit())
+	}
+
+	tx, err := ng.Begin(kv.TxOptions{Writable: true})
+	assert.NoError(t, err)
+	assert.NoError(t, tx.CreateStore([]byte("a")))
+	put(tx, "1", "2", "3")
+	assert.NoError(t, tx.Commit())
+
+	tx, err = ng.Begin(kv.TxOptions{Writable: true})
+	assert.NoError(t, err)
+	defer tx.Rollback()
+
+	put(tx, "4")
+	assert.NoError(t, tx.GetStore([]byte("a")).Delete([]byte("2")))
+	commit("2", "5")
+
+	// the writes of the transaction replace the committed keys
+	for _, reverse := range []bool{false, true} {
+		require.Equal(t, []string{"1", "3", "4", "5"}, keys(tx, reverse))
+	}
+	_, err = tx.GetStore([]byte("a")).Get([]byte("2"))
+	assert.ErrorIs(t, err, kv.ErrKeyNotFound)
+
+	sp, err := tx.Savepoint()
+	assert.NoError(t, err)
+
+	// truncating hides the keys committed afterwards too
+	assert.NoError(t, tx.GetStore([]byte("a")).Truncate())
+	put(tx, "6")
+	commit("7")
+	for _, reverse := range []bool{false, true} {
+		require.Equal(t, []string{"6"}, keys(tx, reverse))
+	}
+
+	assert.NoError(t, tx.RollbackTo(sp))
+	require.Equal(t, []string{"1", "3", "4", "5", "7"}, keys(tx, false))
+	assert.NoError(t, tx.Commit())
+
+	tx, err = ng.Begin(kv.TxOptions{})
+	assert.NoError(t, err)
+	defer tx.Rollback()
+	require.Equal(t, []string{"1", "3", "4", "5", "7"}, keys(tx, false))
+}
+
+func TestCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genji")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ng := memkv.NewEngine()
+	defer ng.Close()
+
+	tx, err := ng.Begin(kv.TxOptions{Writable: true})
+	assert.NoError(t, err)
+	assert.NoError(t, tx.CreateStore([]byte("a")))
+	assert.NoError(t, tx.GetStore([]byte("a")).Put([]byte("foo"), []byte("bar")))
+	assert.NoError(t, tx.Commit())
+
+	path := filepath.Join(dir, "checkpoint")
+	assert.NoError(t, ng.Checkpoint(path))
+	assert.Error(t, ng.Checkpoint(path))
+
+	png, err := pebblekv.NewEngine(path, pebblekv.Options{})
+	assert.NoError(t, err)
+	defer png.Close()
+
+	tx, err = png.Begin(kv.TxOptions{})
+	assert.NoError(t, err)
+	defer tx.Rollback()
+
+	ok, err := tx.StoreExists([]byte("a"))
+	assert.NoError(t, err)
+	require.True(t, ok)
+
+	v, err := tx.GetStore([]byte("a")).Get([]byte("foo"))
+	assert.NoError(t, err)
+	require.Equal(t, []byte("bar"), v)
+}
@@ -0,0 +1,138 @@
+package memkv
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/cockroachdb/errors"
+	"github.com/genjidb/genji/internal/kv"
+)
+
+// ErrLoaderClosed is returned when using a loader that was committed or closed.
+var ErrLoaderClosed = errors.New("loader is closed")
+
+// NewLoader creates a loader which adds all of its keys
+// to the committed tree at once.
+func (e *Engine) NewLoader() (kv.Loader, error) {
+	return &Loader{
+		ng:     e,
+		stores: make(map[string]*loaderStore),
+	}, nil
+}
+
+// A Loader keeps the keys in memory until they are committed.
+type Loader struct {
+	ng     *Engine
+	stores map[string]*loaderStore
+	closed bool
+}
+
+type loaderStore struct {
+	name    []byte
+	prefix  []byte
+	entries []*node
+}
+
+// Put adds a key to the given store.
+// If the same key was already added to the store, Commit returns ErrDuplicateKey.
+func (l *Loader) Put(store, k, v []byte) error {
+	if l.closed {
+		return errors.WithStack(ErrLoaderClosed)
+	}
+
+	if len(k) == 0 {
+		return errors.New("cannot store empty key")
+	}
+
+	if len(v) == 0 {
+		return errors.New("cannot store empty value")
+	}
+
+	s, ok := l.stores[string(store)]
+	if !ok {
+		l.ng.mu.Lock()
+		n := get(l.ng.root, kv.StoreKey(store))
+		l.ng.mu.Unlock()
+		if n == nil {
+			return errors.WithStack(kv.ErrStoreNotFound)
+		}
+
+		prefix := kv.StorePrefix(store)
+		s = &loaderStore{
+			name:   append([]byte(nil), store...),
+			prefix: append([]byte(nil), prefix...),
+		}
+		kv.ReleaseKey(prefix)
+		l.stores[string(store)] = s
+	}
+
+	key := kv.BuildKey(s.prefix, k)
+	s.entries = append(s.entries, &node{
+		key:   append([]byte(nil), key...),
+		value: append([]byte(nil), v...),
+	})
+	kv.ReleaseKey(key)
+
+	return nil
+}
+
+// Commit sorts the keys of every store and adds them to the committed tree.
+// The loader can't be used afterwards.
+func (l *Loader) Commit(fn func(store, k, v []byte) error) error {
+	if l.closed {
+		return errors.WithStack(ErrLoaderClosed)
+	}
+	defer l.Close()
+
+	stores := make([]*loaderStore, 0, len(l.stores))
+	for _, s := range l.stores {
+		stores = append(stores, s)
+	}
+	sort.Slice(stores, func(i, j int) bool {
+		return bytes.Compare(stores[i].prefix, stores[j].prefix) < 0
+	})
+
+	for _, s := range stores {
+		sort.Slice(s.entries, func(i, j int) bool {
+			return bytes.Compare(s.entries[i].key, s.entries[j].key) < 0
+		})
+
+		for i, n := range s.entries {
+			if i > 0 && bytes.Equal(s.entries[i-1].key, n.key) {
+				return errors.WithStack(kv.ErrDuplicateKey)
+			}
+
+			if fn == nil {
+				continue
+			}
+			err := fn(s.name, kv.TrimPrefix(n.key, s.prefix), n.value)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	l.ng.mu.Lock()
+	defer l.ng.mu.Unlock()
+
+	if l.ng.closed {
+		return errors.WithStack(ErrEngineClosed)
+	}
+
+	root := l.ng.root
+	for _, s := range stores {
+		for _, n := range s.entries {
+			root = put(root, n.key, n.value)
+		}
+	}
+	l.ng.root = root
+
+	return nil
+}
+
+// Close discards the keys that were not committed.
+func (l *Loader) Close() error {
+	l.closed = true
+	l.stores = nil
+	return nil
+}
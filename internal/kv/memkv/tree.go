@@ -0,0 +1,209 @@
+package memkv
+
+import (
+	"bytes"
+	"hash/fnv"
+)
+
+// A node of an immutable treap.
+// Nodes are never modified once they are part of a tree: every write
+// copies the path from the root to the modified node, which makes
+// snapshots free, as they only need to keep a reference to the root.
+// The priority of a node is derived from its key, which keeps the
+// treap balanced on average without requiring a random source.
+type node struct {
+	key, value []byte
+	priority   uint32
+	// tombstone is true for the nodes of the writes of a transaction
+	// deleting their key from the committed tree.
+	tombstone   bool
+	left, right *node
+}
+
+func priority(key []byte) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write(key)
+	return h.Sum32()
+}
+
+// split returns the nodes whose keys are lower than key,
+// the node whose key is equal to key, if any, and the nodes
+// whose keys are greater than key.
+func split(n *node, key []byte) (l, eq, r *node) {
+	if n == nil {
+		return nil, nil, nil
+	}
+
+	switch c := bytes.Compare(key, n.key); {
+	case c == 0:
+		return n.left, n, n.right
+	case c < 0:
+		l, eq, r = split(n.left, key)
+		cp := *n
+		cp.left = r
+		return l, eq, &cp
+	default:
+		l, eq, r = split(n.right, key)
+		cp := *n
+		cp.right = l
+		return &cp, eq, r
+	}
+}
+
+// merge two trees. All the keys of l must be lower than the keys of r.
+func merge(l, r *node) *node {
+	if l == nil {
+		return r
+	}
+	if r == nil {
+		return l
+	}
+
+	if l.priority > r.priority {
+		cp := *l
+		cp.right = merge(l.right, r)
+		return &cp
+	}
+
+	cp := *r
+	cp.left = merge(l, r.left)
+	return &cp
+}
+
+// put returns a new tree containing the key-value pair.
+func put(root *node, key, value []byte) *node {
+	return insert(root, &node{
+		key:      key,
+		value:    value,
+		priority: priority(key),
+	})
+}
+
+// putTombstone returns a new tree containing a tombstone for the given key.
+func putTombstone(root *node, key []byte) *node {
+	return insert(root, &node{
+		key:       key,
+		priority:  priority(key),
+		tombstone: true,
+	})
+}
+
+// insert returns a new tree containing n, replacing the node with the same key, if any.
+func insert(root *node, n *node) *node {
+	l, _, r := split(root, n.key)
+	return merge(merge(l, n), r)
+}
+
+// remove returns a new tree without the given key.
+func remove(root *node, key []byte) *node {
+	l, _, r := split(root, key)
+	return merge(l, r)
+}
+
+// removeRange returns a new tree without the keys between start (inclusive)
+// and end (exclusive).
+func removeRange(root *node, start, end []byte) *node {
+	l, _, rest := split(root, start)
+	_, eq, r := split(rest, end)
+	if eq != nil {
+		cp := *eq
+		cp.left, cp.right = nil, nil
+		r = merge(&cp, r)
+	}
+
+	return merge(l, r)
+}
+
+// get returns the node with the given key.
+func get(n *node, key []byte) *node {
+	for n != nil {
+		switch c := bytes.Compare(key, n.key); {
+		case c == 0:
+			return n
+		case c < 0:
+			n = n.left
+		default:
+			n = n.right
+		}
+	}
+
+	return nil
+}
+
+// seekGE returns the node with the smallest key greater than or equal to key.
+func seekGE(n *node, key []byte) *node {
+	var found *node
+	for n != nil {
+		if bytes.Compare(n.key, key) >= 0 {
+			found = n
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+
+	return found
+}
+
+// seekGT returns the node with the smallest key greater than key.
+func seekGT(n *node, key []byte) *node {
+	var found *node
+	for n != nil {
+		if bytes.Compare(n.key, key) > 0 {
+			found = n
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+
+	return found
+}
+
+// seekLT returns the node with the greatest key lower than key.
+// If key is nil, it returns the node with the greatest key.
+func seekLT(n *node, key []byte) *node {
+	var found *node
+	for n != nil {
+		if key == nil || bytes.Compare(n.key, key) < 0 {
+			found = n
+			n = n.right
+		} else {
+			n = n.left
+		}
+	}
+
+	return found
+}
+
+// walk calls fn for every node whose key is between start (inclusive)
+// and end (exclusive), in order.
+// If end is nil, it walks until the last node.
+func walk(n *node, start, end []byte, fn func(n *node) error) error {
+	if n == nil {
+		return nil
+	}
+
+	lower := bytes.Compare(n.key, start) >= 0
+	upper := end == nil || bytes.Compare(n.key, end) < 0
+
+	if lower {
+		err := walk(n.left, start, end, fn)
+		if err != nil {
+			return err
+		}
+	}
+
+	if lower && upper {
+		err := fn(n)
+		if err != nil {
+			return err
+		}
+	}
+
+	if upper {
+		return walk(n.right, start, end, fn)
+	}
+
+	return nil
+}
@@ -0,0 +1,603 @@
+// Package pebblekv implements a kv engine using Pebble.
+package pebblekv
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/genjidb/genji/internal/kv"
+)
+
+// Engine is a kv engine storing its data in a Pebble database.
+type Engine struct {
+	DB   *pebble.DB
+	opts Options
+}
+
+// Options are used to configure the engine.
+type Options struct {
+	// Options passed to Pebble's Open function.
+	// If nil, Pebble's default options are used.
+	Pebble *pebble.Options
+
+	// If true, committing a transaction doesn't wait for the
+	// write-ahead log to be synced to disk. A crash might lose
+	// the most recent transactions but never corrupts the database.
+	NoSync bool
+
+	// Directory where transient stores are created.
+	// If empty, os.TempDir() is used.
+	TempDir string
+
+	// Maximum size in bytes of the writes a transaction keeps in memory.
+	// If zero, the size of transactions is not limited.
+	MaxTxSize int
+
+	// If true, transactions exceeding MaxTxSize write their pending
+	// writes to a temporary database instead of failing
+	// with kv.ErrTransactionTooLarge. The writes are merged into the
+	// database on commit.
+	// Spilled transactions can't be rolled back to a savepoint: transactions
+	// with savepoints that weren't released still fail with kv.ErrTransactionTooLarge,
+	// and savepoints can't be created once a transaction is spilled.
+	SpillTx bool
+}
+
+// NewEngine creates a Pebble kv engine.
+// If opts.Pebble.FS is an in-memory filesystem, the data is only kept in memory.
+func NewEngine(path string, opts Options) (*Engine, error) {
+	db, err := pebble.Open(path, opts.Pebble)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Engine{
+		DB:   db,
+		opts: opts,
+	}, nil
+}
+
+// ReadOnly returns true if the engine was opened in read-only mode.
+func (e *Engine) ReadOnly() bool {
+	return e.opts.Pebble != nil && e.opts.Pebble.ReadOnly
+}
+
+// Begin creates a transaction using Pebble's batch API.
+// Read-only transactions are pinned to a Pebble snapshot taken when
+// the transaction begins, so that they never observe writes committed
+// after that point.
+func (e *Engine) Begin(opts kv.TxOptions) (kv.Transaction, error) {
+	var batch *txBatch
+	var snapshot *pebble.Snapshot
+
+	if opts.Writable && e.ReadOnly() {
+		return nil, errors.WithStack(kv.ErrEngineReadOnly)
+	}
+
+	if opts.Writable {
+		batch = &txBatch{Batch: e.DB.NewIndexedBatch()}
+	} else {
+		snapshot = e.DB.NewSnapshot()
+	}
+
+	return &Transaction{
+		ng:       e,
+		batch:    batch,
+		snapshot: snapshot,
+		writable: opts.Writable,
+	}, nil
+}
+
+// NewTransientStore creates a store backed by a temporary Pebble database
+// without write-ahead log.
+func (e *Engine) NewTransientStore() (kv.TransientStore, error) {
+	// build engine with fast options
+
+	var inMemory bool
+	if e.opts.Pebble != nil {
+		_, inMemory = e.opts.Pebble.FS.(*vfs.MemFS)
+	}
+
+	opt := pebble.Options{
+		DisableWAL: true,
+	}
+
+	var path string
+	if inMemory {
+		opt.FS = vfs.NewMem()
+	} else {
+		dir := e.opts.TempDir
+		if dir == "" {
+			dir = os.TempDir()
+		}
+		path = filepath.Join(dir, fmt.Sprintf(".genji-transient-%d", time.Now().Unix()+rand.Int63()))
+
+	}
+	opt.Logger = nil
+
+	db, err := pebble.Open(path, &opt)
+	if err != nil {
+		return nil, err
+	}
+
+	s := TransientStore{
+		DB:    db,
+		Path:  path,
+		batch: db.NewIndexedBatch(),
+	}
+
+	err = s.Reset()
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// Checkpoint creates a consistent copy of the database in the given directory,
+// which must not exist. The copy can be opened as a regular database.
+// Only committed transactions are part of the copy.
+// The engine remains usable during the whole operation.
+func (e *Engine) Checkpoint(dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(dir); err == nil {
+		return errors.Errorf("%q already exists", dir)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	var opts []pebble.CheckpointOption
+	// commits might not have been synced to disk
+	if !e.ReadOnly() {
+		opts = append(opts, pebble.WithFlushedWAL())
+	}
+
+	var mem *vfs.MemFS
+	if e.opts.Pebble != nil {
+		mem, _ = e.opts.Pebble.FS.(*vfs.MemFS)
+	}
+	if mem == nil {
+		return e.DB.Checkpoint(dir, opts...)
+	}
+
+	// in-memory databases are checkpointed in memory, then copied to disk
+	tmp := fmt.Sprintf("checkpoint-%d", time.Now().Unix()+rand.Int63())
+	err = e.DB.Checkpoint(tmp, opts...)
+	if err != nil {
+		return err
+	}
+	defer mem.RemoveAll(tmp)
+
+	_, err = vfs.Clone(mem, vfs.Default, tmp, dir)
+	return err
+}
+
+// Compact compacts the whole keyspace. Keys deleted by range deletions,
+// for example by dropping a store, are only removed from the disk
+// once the range is compacted, which Pebble otherwise does in the background.
+// It returns the difference between the size of the sstables before and after the compaction,
+// which doesn't account for the data that was only held in memory.
+func (e *Engine) Compact() (int64, error) {
+	if e.ReadOnly() {
+		return 0, errors.WithStack(kv.ErrEngineReadOnly)
+	}
+
+	// measure before flushing the memtables: flushing range deletions
+	// might trigger compactions that reclaim space.
+	before := e.DB.Metrics().Total().Size
+
+	err := e.DB.Flush()
+	if err != nil {
+		return 0, err
+	}
+
+	// every key starts with a printable character
+	err = e.DB.Compact([]byte{0}, []byte{0xff})
+	if err != nil {
+		return 0, err
+	}
+
+	reclaimed := before - e.DB.Metrics().Total().Size
+	if reclaimed < 0 {
+		reclaimed = 0
+	}
+
+	return reclaimed, nil
+}
+
+// Stats returns the metrics of the Pebble database.
+func (e *Engine) Stats() kv.Stats {
+	m := e.DB.Metrics()
+
+	return kv.Stats{
+		DiskUsage:         m.DiskSpaceUsage(),
+		MemTableSize:      m.MemTable.Size,
+		WALSize:           m.WAL.PhysicalSize,
+		CacheSize:         m.BlockCache.Size,
+		CacheHits:         m.BlockCache.Hits,
+		CacheMisses:       m.BlockCache.Misses,
+		Flushes:           m.Flush.Count,
+		Compactions:       m.Compact.Count,
+		CompactionDebt:    m.Compact.EstimatedDebt,
+		ReadAmplification: m.ReadAmp(),
+	}
+}
+
+// StoreSize returns the estimated space used on disk by the keys of the store.
+// Keys that were not flushed from the memtables yet are not taken into account.
+func (e *Engine) StoreSize(name []byte) (uint64, error) {
+	prefix := kv.StorePrefix(name)
+	defer kv.ReleaseKey(prefix)
+	start := kv.BuildKey(prefix, nil)
+	defer kv.ReleaseKey(start)
+	end := kv.BuildEndKey(prefix)
+	defer kv.ReleaseKey(end)
+
+	return e.DB.EstimateDiskUsage(start, end)
+}
+
+// Close the engine and underlying Pebble database.
+func (e *Engine) Close() error {
+	return e.DB.Close()
+}
+
+// A Transaction uses Pebble's batches for read/write transactions
+// and Pebble's snapshots for read-only ones.
+// If the writes of a transaction exceed the maximum size of a transaction,
+// they are either rejected or spilled to a temporary database,
+// depending on the options of the engine.
+type Transaction struct {
+	ng       *Engine
+	batch    *txBatch
+	snapshot *pebble.Snapshot
+	// writes of the transaction, once spilled. The batch is nil.
+	spill *spill
+	// number of savepoints that weren't released.
+	// Transactions can't be spilled while they have savepoints.
+	savepoints int
+	writable   bool
+	discarded  bool
+}
+
+// Rollback the transaction. Can be used safely after commit.
+func (t *Transaction) Rollback() error {
+	if t.discarded {
+		return errors.WithStack(kv.ErrTransactionDiscarded)
+	}
+
+	t.discarded = true
+
+	// batches are pooled by Pebble and must not be closed twice.
+	if t.batch != nil {
+		t.batch.retire()
+	}
+
+	if t.spill != nil {
+		return t.spill.drop()
+	}
+
+	if t.snapshot != nil {
+		return t.snapshot.Close()
+	}
+
+	return nil
+}
+
+// Commit the transaction.
+// Spilled transactions are written to an sstable which is ingested
+// by Pebble in one atomic operation.
+func (t *Transaction) Commit() error {
+	if t.discarded {
+		return errors.WithStack(kv.ErrTransactionDiscarded)
+	}
+
+	if !t.writable {
+		return kv.ErrTransactionReadOnly
+	}
+
+	t.discarded = true
+
+	if t.spill != nil {
+		err := t.spill.commit()
+		if err != nil {
+			_ = t.spill.drop()
+			return err
+		}
+
+		return t.spill.drop()
+	}
+
+	defer t.batch.retire()
+
+	return t.batch.Commit(&pebble.WriteOptions{Sync: !t.ng.opts.NoSync})
+}
+
+// get returns the value of the key as seen by the transaction.
+func (t *Transaction) get(key []byte) ([]byte, io.Closer, error) {
+	switch {
+	case t.spill != nil:
+		return t.spill.get(key)
+	case t.writable:
+		return t.batch.Get(key)
+	default:
+		return t.snapshot.Get(key)
+	}
+}
+
+func (t *Transaction) set(key, value []byte) error {
+	err := t.reserve(len(key) + len(value))
+	if err != nil {
+		return err
+	}
+
+	if t.spill != nil {
+		return t.spill.set(key, value)
+	}
+
+	return t.batch.Set(key, value, nil)
+}
+
+func (t *Transaction) delete(key []byte) error {
+	err := t.reserve(len(key))
+	if err != nil {
+		return err
+	}
+
+	if t.spill != nil {
+		return t.spill.delete(key)
+	}
+
+	return t.batch.Delete(key, nil)
+}
+
+func (t *Transaction) deleteRange(start, end []byte) error {
+	err := t.reserve(len(start) + len(end))
+	if err != nil {
+		return err
+	}
+
+	if t.spill != nil {
+		return t.spill.deleteRange(start, end)
+	}
+
+	return t.batch.DeleteRange(start, end, nil)
+}
+
+func (t *Transaction) newIter(opts *pebble.IterOptions) iterator {
+	switch {
+	case t.spill != nil:
+		return t.spill.newIter(opts)
+	case t.writable:
+		return t.batch.newIter(opts)
+	default:
+		return t.snapshot.NewIter(opts)
+	}
+}
+
+// reserve ensures the batch can hold n more bytes.
+// Otherwise, it either returns kv.ErrTransactionTooLarge, leaving the batch untouched,
+// or moves the content of the batch to a spill.
+// Spills can't be rolled back to a savepoint: transactions with savepoints
+// that weren't released are never spilled.
+func (t *Transaction) reserve(n int) error {
+	max := t.ng.opts.MaxTxSize
+	if t.spill != nil || max <= 0 || len(t.batch.Repr())+n <= max {
+		return nil
+	}
+
+	if !t.ng.opts.SpillTx {
+		return errors.WithStack(kv.ErrTransactionTooLarge)
+	}
+
+	if t.savepoints > 0 {
+		return errors.Wrap(kv.ErrTransactionTooLarge, "transactions with savepoints can't be spilled to disk, release them first")
+	}
+
+	s, err := newSpill(t.ng)
+	if err != nil {
+		return err
+	}
+
+	err = s.add(t.batch.Batch)
+	if err != nil {
+		_ = s.drop()
+		return err
+	}
+
+	t.batch.retire()
+	t.batch = nil
+	t.spill = s
+	return nil
+}
+
+// savepoint records the size of the batch when the savepoint was created.
+type savepoint struct {
+	offset   int
+	released bool
+}
+
+// Savepoint creates a savepoint at the current position of the transaction.
+// Rolling back to a savepoint undoes every write made
+// after the savepoint was created.
+// Spilled transactions can't be rolled back to a savepoint: creating one
+// once the transaction is spilled fails, and the transaction
+// is not spilled until its savepoints are released.
+func (t *Transaction) Savepoint() (kv.Savepoint, error) {
+	if t.discarded {
+		return nil, errors.WithStack(kv.ErrTransactionDiscarded)
+	}
+
+	// read-only transactions have nothing to undo
+	if !t.writable {
+		return &savepoint{}, nil
+	}
+
+	if t.spill != nil {
+		return nil, errors.New("cannot create a savepoint once the transaction was spilled to disk")
+	}
+
+	t.savepoints++
+	return &savepoint{offset: len(t.batch.Repr())}, nil
+}
+
+// ReleaseSavepoint destroys the savepoint. Once all the savepoints
+// of the transaction are released, it can be spilled.
+func (t *Transaction) ReleaseSavepoint(s kv.Savepoint) error {
+	if t.discarded {
+		return errors.WithStack(kv.ErrTransactionDiscarded)
+	}
+
+	if !t.writable {
+		return nil
+	}
+
+	sp, ok := s.(*savepoint)
+	if !ok {
+		return errors.New("invalid savepoint")
+	}
+
+	if !sp.released {
+		sp.released = true
+		t.savepoints--
+	}
+
+	return nil
+}
+
+// RollbackTo undoes every write made after the given savepoint.
+// Pebble batches can't be truncated, so a new batch is created
+// and every write that precedes the savepoint is replayed on it.
+func (t *Transaction) RollbackTo(s kv.Savepoint) error {
+	if t.discarded {
+		return errors.WithStack(kv.ErrTransactionDiscarded)
+	}
+
+	if !t.writable {
+		return nil
+	}
+
+	sp, ok := s.(*savepoint)
+	if !ok || sp.released {
+		return errors.New("invalid savepoint")
+	}
+
+	repr := t.batch.Repr()
+	if sp.offset > len(repr) {
+		return errors.New("invalid savepoint")
+	}
+
+	batch := t.ng.DB.NewIndexedBatch()
+
+	r, _ := pebble.ReadBatch(repr[:sp.offset])
+	for len(r) > 0 {
+		kind, k, v, ok := r.Next()
+		if !ok {
+			_ = batch.Close()
+			return errors.New("invalid batch")
+		}
+
+		var err error
+		switch kind {
+		case pebble.InternalKeyKindSet:
+			err = batch.Set(k, v, nil)
+		case pebble.InternalKeyKindDelete:
+			err = batch.Delete(k, nil)
+		case pebble.InternalKeyKindSingleDelete:
+			err = batch.SingleDelete(k, nil)
+		case pebble.InternalKeyKindRangeDelete:
+			err = batch.DeleteRange(k, v, nil)
+		case pebble.InternalKeyKindMerge:
+			err = batch.Merge(k, v, nil)
+		case pebble.InternalKeyKindLogData:
+			err = batch.LogData(k, nil)
+		default:
+			err = errors.Newf("unsupported batch operation %d", kind)
+		}
+		if err != nil {
+			_ = batch.Close()
+			return err
+		}
+	}
+
+	t.batch.retire()
+	t.batch = &txBatch{Batch: batch}
+
+	return nil
+}
+
+// GetStore returns a store by name.
+func (t *Transaction) GetStore(name []byte) kv.Store {
+	return &Store{
+		ng:       t.ng,
+		tx:       t,
+		Prefix:   kv.StorePrefix(name),
+		writable: t.writable,
+		name:     name,
+	}
+}
+
+// CreateStore creates a store.
+// If the store already exists, returns ErrStoreAlreadyExists.
+func (t *Transaction) CreateStore(name []byte) error {
+	if !t.writable {
+		return errors.WithStack(kv.ErrTransactionReadOnly)
+	}
+
+	key := kv.StoreKey(name)
+	_, closer, err := t.get(key)
+	if err == nil {
+		_ = closer.Close()
+		return errors.WithStack(kv.ErrStoreAlreadyExists)
+	}
+	if !errors.Is(err, pebble.ErrNotFound) {
+		return err
+	}
+
+	return t.set(key, nil)
+}
+
+// StoreExists returns true if a store with the given name exists.
+func (t *Transaction) StoreExists(name []byte) (bool, error) {
+	_, closer, err := t.get(kv.StoreKey(name))
+	if err != nil {
+		if errors.Is(err, pebble.ErrNotFound) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, closer.Close()
+}
+
+// DropStore deletes the store and all its keys.
+// Like Truncate, it uses a range deletion.
+func (t *Transaction) DropStore(name []byte) error {
+	if !t.writable {
+		return errors.WithStack(kv.ErrTransactionReadOnly)
+	}
+
+	s := t.GetStore(name)
+	err := s.Truncate()
+	if err != nil {
+		return err
+	}
+
+	err = t.delete(kv.StoreKey(name))
+	if errors.Is(err, pebble.ErrNotFound) {
+		return errors.WithStack(kv.ErrStoreNotFound)
+	}
+
+	return err
+}
@@ -0,0 +1,358 @@
+package pebblekv_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/genjidb/genji/internal/kv"
+	"github.com/genjidb/genji/internal/kv/kvtest"
+	"github.com/genjidb/genji/internal/kv/pebblekv"
+	"github.com/genjidb/genji/internal/testutil/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func builder(t testing.TB) kv.Engine {
+	dir := tempDir(t)
+
+	ng, err := pebblekv.NewEngine(filepath.Join(dir, "pebble"), pebblekv.Options{})
+	assert.NoError(t, err)
+
+	return ng
+}
+
+func TestEngine(t *testing.T) {
+	kvtest.TestSuite(t, builder)
+}
+
+func TestTransientDrop(t *testing.T) {
+	ng, err := pebblekv.NewEngine(filepath.Join(tempDir(t), "pebble"), pebblekv.Options{TempDir: tempDir(t)})
+	assert.NoError(t, err)
+	defer ng.Close()
+
+	ts, err := ng.NewTransientStore()
+	assert.NoError(t, err)
+
+	dir := ts.(*pebblekv.TransientStore).Path
+
+	err = ts.Put([]byte("foo"), []byte("bar"))
+	assert.NoError(t, err)
+
+	_, err = os.Stat(dir)
+	assert.NoError(t, err)
+
+	err = ts.Drop()
+	assert.NoError(t, err)
+
+	_, err = os.Stat(dir)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestCompact(t *testing.T) {
+	ng := builder(t).(*pebblekv.Engine)
+	defer ng.Close()
+
+	tx, err := ng.Begin(kv.TxOptions{Writable: true})
+	assert.NoError(t, err)
+	defer tx.Rollback()
+
+	err = tx.CreateStore([]byte("test"))
+	assert.NoError(t, err)
+	st := tx.GetStore([]byte("test"))
+	value := bytes.Repeat([]byte("a"), 100)
+	for i := 0; i < 10000; i++ {
+		err = st.Put([]byte(fmt.Sprintf("key-%05d", i)), value)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tx.Commit())
+	// write the keys to disk
+	assert.NoError(t, ng.DB.Flush())
+
+	tx, err = ng.Begin(kv.TxOptions{Writable: true})
+	assert.NoError(t, err)
+	defer tx.Rollback()
+
+	err = tx.DropStore([]byte("test"))
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit())
+
+	reclaimed, err := ng.Compact()
+	assert.NoError(t, err)
+	require.Greater(t, reclaimed, int64(10000))
+
+	// nothing left to reclaim
+	reclaimed, err = ng.Compact()
+	assert.NoError(t, err)
+	require.Zero(t, reclaimed)
+}
+
+func TestTransactionSize(t *testing.T) {
+	t.Run("Should fail if the transaction is too large", func(t *testing.T) {
+		ng, err := pebblekv.NewEngine(filepath.Join(tempDir(t), "pebble"), pebblekv.Options{MaxTxSize: 1000})
+		assert.NoError(t, err)
+		defer ng.Close()
+
+		tx, err := ng.Begin(kv.TxOptions{Writable: true})
+		assert.NoError(t, err)
+		defer tx.Rollback()
+
+		assert.NoError(t, tx.CreateStore([]byte("test")))
+		st := tx.GetStore([]byte("test"))
+
+		var i int
+		for ; ; i++ {
+			err = st.Put([]byte(fmt.Sprintf("key-%03d", i)), bytes.Repeat([]byte("a"), 50))
+			if err != nil {
+				break
+			}
+		}
+		assert.ErrorIs(t, err, kv.ErrTransactionTooLarge)
+		require.Greater(t, i, 10)
+
+		// the failed write was not applied
+		_, err = st.Get([]byte(fmt.Sprintf("key-%03d", i)))
+		assert.ErrorIs(t, err, kv.ErrKeyNotFound)
+		assert.NoError(t, tx.Commit())
+
+		tx, err = ng.Begin(kv.TxOptions{})
+		assert.NoError(t, err)
+		defer tx.Rollback()
+		require.Equal(t, i, countKeys(t, tx.GetStore([]byte("test"))))
+	})
+
+	newEngine := func(t *testing.T) (*pebblekv.Engine, string) {
+		tmp := tempDir(t)
+		ng, err := pebblekv.NewEngine(filepath.Join(tempDir(t), "pebble"), pebblekv.Options{
+			MaxTxSize: 1000,
+			SpillTx:   true,
+			TempDir:   tmp,
+		})
+		assert.NoError(t, err)
+		t.Cleanup(func() { ng.Close() })
+
+		return ng, tmp
+	}
+
+	requireEmptyDir := func(t *testing.T, dir string) {
+		t.Helper()
+
+		entries, err := ioutil.ReadDir(dir)
+		assert.NoError(t, err)
+		require.Empty(t, entries)
+	}
+
+	t.Run("Should spill large transactions", func(t *testing.T) {
+		ng, tmp := newEngine(t)
+
+		stores := []string{"a", "b"}
+		model := make(map[string]map[string]string)
+
+		// write committed data
+		tx, err := ng.Begin(kv.TxOptions{Writable: true})
+		assert.NoError(t, err)
+		for _, name := range stores {
+			model[name] = make(map[string]string)
+			assert.NoError(t, tx.CreateStore([]byte(name)))
+			for i := 0; i < 200; i += 2 {
+				k, v := fmt.Sprintf("key-%03d", i), fmt.Sprintf("committed-%d", i)
+				assert.NoError(t, tx.GetStore([]byte(name)).Put([]byte(k), []byte(v)))
+				model[name][k] = v
+			}
+		}
+		assert.NoError(t, tx.Commit())
+
+		tx, err = ng.Begin(kv.TxOptions{Writable: true})
+		assert.NoError(t, err)
+		defer tx.Rollback()
+
+		rnd := rand.New(rand.NewSource(42))
+		for i := 0; i < 3000; i++ {
+			name := stores[rnd.Intn(len(stores))]
+			st := tx.GetStore([]byte(name))
+			k := fmt.Sprintf("key-%03d", rnd.Intn(200))
+
+			switch n := rnd.Intn(100); {
+			case n < 60:
+				v := fmt.Sprintf("value-%d", i)
+				assert.NoError(t, st.Put([]byte(k), []byte(v)))
+				model[name][k] = v
+			case n < 99:
+				err = st.Delete([]byte(k))
+				if _, ok := model[name][k]; ok {
+					assert.NoError(t, err)
+					delete(model[name], k)
+				} else {
+					assert.ErrorIs(t, err, kv.ErrKeyNotFound)
+				}
+			default:
+				assert.NoError(t, st.Truncate())
+				model[name] = make(map[string]string)
+			}
+
+			if i%100 == 0 {
+				for _, name := range stores {
+					requireStore(t, tx.GetStore([]byte(name)), model[name])
+				}
+			}
+		}
+
+		// the writes don't fit in memory
+		entries, err := ioutil.ReadDir(tmp)
+		assert.NoError(t, err)
+		require.Len(t, entries, 1)
+
+		assert.NoError(t, tx.Commit())
+		requireEmptyDir(t, tmp)
+
+		tx, err = ng.Begin(kv.TxOptions{})
+		assert.NoError(t, err)
+		defer tx.Rollback()
+		for _, name := range stores {
+			requireStore(t, tx.GetStore([]byte(name)), model[name])
+		}
+	})
+
+	t.Run("Should discard spilled writes on rollback", func(t *testing.T) {
+		ng, tmp := newEngine(t)
+
+		tx, err := ng.Begin(kv.TxOptions{Writable: true})
+		assert.NoError(t, err)
+		assert.NoError(t, tx.CreateStore([]byte("test")))
+		assert.NoError(t, tx.Commit())
+
+		tx, err = ng.Begin(kv.TxOptions{Writable: true})
+		assert.NoError(t, err)
+		st := tx.GetStore([]byte("test"))
+		for i := 0; i < 100; i++ {
+			assert.NoError(t, st.Put([]byte(fmt.Sprintf("key-%03d", i)), bytes.Repeat([]byte("a"), 50)))
+		}
+		require.Equal(t, 100, countKeys(t, st))
+		assert.NoError(t, tx.Rollback())
+		requireEmptyDir(t, tmp)
+
+		tx, err = ng.Begin(kv.TxOptions{})
+		assert.NoError(t, err)
+		defer tx.Rollback()
+		require.Zero(t, countKeys(t, tx.GetStore([]byte("test"))))
+	})
+
+	t.Run("Should not spill transactions with savepoints", func(t *testing.T) {
+		ng, tmp := newEngine(t)
+
+		tx, err := ng.Begin(kv.TxOptions{Writable: true})
+		assert.NoError(t, err)
+		defer tx.Rollback()
+
+		assert.NoError(t, tx.CreateStore([]byte("test")))
+		sp, err := tx.Savepoint()
+		assert.NoError(t, err)
+
+		st := tx.GetStore([]byte("test"))
+		put := func(i int) error {
+			return st.Put([]byte(fmt.Sprintf("key-%03d", i)), bytes.Repeat([]byte("a"), 50))
+		}
+
+		var i int
+		for ; ; i++ {
+			err = put(i)
+			if err != nil {
+				break
+			}
+		}
+		assert.ErrorIs(t, err, kv.ErrTransactionTooLarge)
+		requireEmptyDir(t, tmp)
+
+		// the transaction can still be rolled back to the savepoint
+		assert.NoError(t, tx.RollbackTo(sp))
+		require.Zero(t, countKeys(t, st))
+
+		// once released, the transaction can be spilled
+		for j := 0; j < i; j++ {
+			assert.NoError(t, put(j))
+		}
+		assert.NoError(t, tx.ReleaseSavepoint(sp))
+		assert.NoError(t, put(i))
+		require.Equal(t, i+1, countKeys(t, st))
+		entries, err := ioutil.ReadDir(tmp)
+		assert.NoError(t, err)
+		require.Len(t, entries, 1)
+
+		// but no savepoint can be created anymore
+		_, err = tx.Savepoint()
+		assert.Error(t, err)
+		require.Error(t, tx.RollbackTo(sp))
+
+		assert.NoError(t, tx.Commit())
+		requireEmptyDir(t, tmp)
+	})
+}
+
+// requireStore ensures the store contains exactly the keys of the model,
+// using Get and iterators in both directions.
+func requireStore(t *testing.T, st kv.Store, model map[string]string) {
+	t.Helper()
+
+	keys := make([]string, 0, len(model))
+	for k, v := range model {
+		got, err := st.Get([]byte(k))
+		assert.NoError(t, err)
+		require.Equal(t, v, string(got))
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	it := st.Iterator(nil)
+	defer it.Close()
+
+	list := []string{}
+	for it.First(); it.Valid(); it.Next() {
+		require.Equal(t, model[string(it.Key())], string(it.Value()))
+		list = append(list, string(it.Key()))
+	}
+	assert.NoError(t, it.Error())
+	require.Equal(t, keys, list)
+
+	list = list[:0]
+	for it.Last(); it.Valid(); it.Prev() {
+		list = append([]string{string(it.Key())}, list...)
+	}
+	require.Equal(t, keys, list)
+
+	// change direction in the middle of the store
+	if len(keys) > 2 {
+		require.True(t, it.SeekGE([]byte(keys[1])))
+		require.True(t, it.Prev())
+		require.Equal(t, keys[0], string(it.Key()))
+		require.True(t, it.Next())
+		require.Equal(t, keys[1], string(it.Key()))
+	}
+}
+
+func countKeys(t *testing.T, st kv.Store) int {
+	t.Helper()
+
+	it := st.Iterator(nil)
+	defer it.Close()
+
+	var n int
+	for it.First(); it.Valid(); it.Next() {
+		n++
+	}
+	assert.NoError(t, it.Error())
+	return n
+}
+
+func tempDir(t testing.TB) string {
+	dir, err := ioutil.TempDir("", "genji")
+	assert.NoError(t, err)
+
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+	return dir
+}
@@ -0,0 +1,491 @@
+package pebblekv
+
+import (
+	"bytes"
+	"container/heap"
+	"fmt"
+	"sort"
+
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/sstable"
+	"github.com/genjidb/genji/internal/kv"
+)
+
+var (
+	// size of the keys and values kept in memory by a loader
+	// before they are written to temporary sstables.
+	loaderBufferSize = 64 << 20
+	// size after which the sstables ingested by a loader are split.
+	loaderFileSize uint64 = 128 << 20
+)
+
+// ErrLoaderClosed is returned when using a loader that was committed or closed.
+var ErrLoaderClosed = errors.New("loader is closed")
+
+// NewLoader creates a loader which writes sorted sstables
+// and ingests them into the database.
+func (e *Engine) NewLoader() (kv.Loader, error) {
+	if e.ReadOnly() {
+		return nil, errors.WithStack(kv.ErrEngineReadOnly)
+	}
+
+	return &Loader{
+		ng:     e,
+		opts:   e.opts.Pebble.Clone().EnsureDefaults(),
+		stores: make(map[string]*loaderStore),
+	}, nil
+}
+
+// A Loader buffers keys in memory and, once they take too much space,
+// sorts them and writes them to temporary sstables.
+// On commit, the keys of each store are merged into a list of sstables,
+// which are then ingested by Pebble in one atomic operation.
+// Ingestion bypasses the memtables and the write-ahead log,
+// which makes loading large amounts of data much faster than using batches.
+type Loader struct {
+	ng     *Engine
+	opts   *pebble.Options
+	stores map[string]*loaderStore
+	// size of the buffered keys and values.
+	size int
+	// directory where sstables are written, created on first use.
+	dir    string
+	files  int
+	closed bool
+}
+
+type loaderStore struct {
+	name    []byte
+	prefix  []byte
+	entries []loaderEntry
+	// temporary sstables containing the keys that didn't fit in memory.
+	chunks []string
+}
+
+type loaderEntry struct {
+	key, value []byte
+}
+
+// sort the buffered entries of the store and ensure they are unique.
+func (s *loaderStore) sort() error {
+	sort.Slice(s.entries, func(i, j int) bool {
+		return bytes.Compare(s.entries[i].key, s.entries[j].key) < 0
+	})
+
+	for i := 1; i < len(s.entries); i++ {
+		if bytes.Equal(s.entries[i-1].key, s.entries[i].key) {
+			return errors.WithStack(kv.ErrDuplicateKey)
+		}
+	}
+
+	return nil
+}
+
+// Put adds a key to the given store. If the same key was already added to the store,
+// it returns ErrDuplicateKey, either immediately or when committing.
+func (l *Loader) Put(store, k, v []byte) error {
+	if l.closed {
+		return errors.WithStack(ErrLoaderClosed)
+	}
+
+	if len(k) == 0 {
+		return errors.New("cannot store empty key")
+	}
+
+	if len(v) == 0 {
+		return errors.New("cannot store empty value")
+	}
+
+	s, ok := l.stores[string(store)]
+	if !ok {
+		_, closer, err := l.ng.DB.Get(kv.StoreKey(store))
+		if errors.Is(err, pebble.ErrNotFound) {
+			return errors.WithStack(kv.ErrStoreNotFound)
+		}
+		if err != nil {
+			return err
+		}
+		_ = closer.Close()
+
+		prefix := kv.StorePrefix(store)
+		s = &loaderStore{
+			name:   append([]byte(nil), store...),
+			prefix: append([]byte(nil), prefix...),
+		}
+		kv.ReleaseKey(prefix)
+		l.stores[string(store)] = s
+	}
+
+	key := kv.BuildKey(s.prefix, k)
+	s.entries = append(s.entries, loaderEntry{
+		key:   append([]byte(nil), key...),
+		value: append([]byte(nil), v...),
+	})
+	kv.ReleaseKey(key)
+
+	l.size += len(key) + len(v)
+	if l.size < loaderBufferSize {
+		return nil
+	}
+
+	return l.spill()
+}
+
+// spill writes the buffered keys of every store to a temporary sstable.
+func (l *Loader) spill() error {
+	for _, s := range l.stores {
+		if len(s.entries) == 0 {
+			continue
+		}
+
+		err := s.sort()
+		if err != nil {
+			return err
+		}
+
+		path, w, err := l.createTable()
+		if err != nil {
+			return err
+		}
+		s.chunks = append(s.chunks, path)
+
+		for _, e := range s.entries {
+			err = w.Set(e.key, e.value)
+			if err != nil {
+				_ = w.Close()
+				return err
+			}
+		}
+
+		err = w.Close()
+		if err != nil {
+			return err
+		}
+
+		s.entries = nil
+	}
+
+	l.size = 0
+	return nil
+}
+
+// Commit merges the keys of every store and ingests them.
+// The loader can't be used afterwards.
+func (l *Loader) Commit(fn func(store, k, v []byte) error) error {
+	if l.closed {
+		return errors.WithStack(ErrLoaderClosed)
+	}
+	defer l.Close()
+
+	// stores must be written in key order: the ingested sstables
+	// must not overlap.
+	stores := make([]*loaderStore, 0, len(l.stores))
+	for _, s := range l.stores {
+		stores = append(stores, s)
+	}
+	sort.Slice(stores, func(i, j int) bool {
+		return bytes.Compare(stores[i].prefix, stores[j].prefix) < 0
+	})
+
+	var out loaderOutput
+	for _, s := range stores {
+		err := l.merge(s, &out, fn)
+		if err != nil {
+			_ = out.close()
+			return err
+		}
+	}
+
+	err := out.close()
+	if err != nil {
+		return err
+	}
+
+	if len(out.paths) == 0 {
+		return nil
+	}
+
+	return l.ng.DB.Ingest(out.paths)
+}
+
+// merge the chunks and buffered keys of the store, and write them to out.
+func (l *Loader) merge(s *loaderStore, out *loaderOutput, fn func(store, k, v []byte) error) error {
+	err := s.sort()
+	if err != nil {
+		return err
+	}
+
+	sources := make([]mergeSource, 0, len(s.chunks)+1)
+	defer func() {
+		for _, src := range sources {
+			_ = src.Close()
+		}
+	}()
+
+	for _, path := range s.chunks {
+		src, err := l.openTable(path)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, src)
+	}
+	sources = append(sources, &entriesSource{entries: s.entries})
+
+	h := make(mergeHeap, 0, len(sources))
+	for _, src := range sources {
+		it := mergeItem{src: src}
+		it.key, it.value = src.First()
+		if it.key != nil {
+			h = append(h, &it)
+		} else if err := src.Error(); err != nil {
+			return err
+		}
+	}
+	heap.Init(&h)
+
+	var prev []byte
+	for h.Len() > 0 {
+		it := h[0]
+		if prev != nil && bytes.Equal(prev, it.key) {
+			return errors.WithStack(kv.ErrDuplicateKey)
+		}
+
+		if fn != nil {
+			err = fn(s.name, kv.TrimPrefix(it.key, s.prefix), it.value)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = out.set(l, it.key, it.value)
+		if err != nil {
+			return err
+		}
+		prev = append(prev[:0], it.key...)
+
+		it.key, it.value = it.src.Next()
+		if it.key != nil {
+			heap.Fix(&h, 0)
+			continue
+		}
+
+		err = it.src.Error()
+		if err != nil {
+			return err
+		}
+		heap.Pop(&h)
+	}
+
+	return nil
+}
+
+// tempDir returns the directory where sstables are written, creating it if necessary.
+// It is created in the filesystem of the database so that Pebble can link
+// the sstables instead of copying them.
+func (l *Loader) tempDir() (string, error) {
+	if l.dir != "" {
+		return l.dir, nil
+	}
+
+	dir := l.ng.tempPath(l.opts.FS, ".genji-load")
+	err := l.opts.FS.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	l.dir = dir
+	return dir, nil
+}
+
+func (l *Loader) createTable() (string, *sstable.Writer, error) {
+	dir, err := l.tempDir()
+	if err != nil {
+		return "", nil, err
+	}
+
+	l.files++
+	path := l.opts.FS.PathJoin(dir, fmt.Sprintf("%06d.sst", l.files))
+	f, err := l.opts.FS.Create(path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return path, sstable.NewWriter(f, l.opts.MakeWriterOptions(0)), nil
+}
+
+func (l *Loader) openTable(path string) (*tableSource, error) {
+	f, err := l.opts.FS.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := sstable.NewReader(f, l.opts.MakeReaderOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	it, err := r.NewIter(nil, nil)
+	if err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+
+	return &tableSource{r: r, it: it}, nil
+}
+
+// Close removes the temporary sstables.
+// Keys that were not committed are discarded.
+func (l *Loader) Close() error {
+	if l.closed {
+		return nil
+	}
+
+	l.closed = true
+	l.stores = nil
+	if l.dir == "" {
+		return nil
+	}
+
+	return l.opts.FS.RemoveAll(l.dir)
+}
+
+// loaderOutput writes keys to a list of sstables, starting
+// a new one whenever the current one becomes too big.
+type loaderOutput struct {
+	w     *sstable.Writer
+	paths []string
+}
+
+func (o *loaderOutput) set(l *Loader, k, v []byte) error {
+	if o.w == nil {
+		path, w, err := l.createTable()
+		if err != nil {
+			return err
+		}
+		o.paths = append(o.paths, path)
+		o.w = w
+	}
+
+	err := o.w.Set(k, v)
+	if err != nil {
+		return err
+	}
+
+	if o.w.EstimatedSize() < loaderFileSize {
+		return nil
+	}
+
+	return o.close()
+}
+
+func (o *loaderOutput) close() error {
+	if o.w == nil {
+		return nil
+	}
+
+	w := o.w
+	o.w = nil
+	return w.Close()
+}
+
+// a mergeSource returns sorted keys. The returned key and value
+// are only valid until the next call.
+// Once it returns a nil key, Error must be checked.
+type mergeSource interface {
+	First() (key, value []byte)
+	Next() (key, value []byte)
+	Error() error
+	Close() error
+}
+
+type tableSource struct {
+	r  *sstable.Reader
+	it sstable.Iterator
+}
+
+func (s *tableSource) First() ([]byte, []byte) {
+	k, v := s.it.First()
+	if k == nil {
+		return nil, nil
+	}
+
+	return k.UserKey, v
+}
+
+func (s *tableSource) Next() ([]byte, []byte) {
+	k, v := s.it.Next()
+	if k == nil {
+		return nil, nil
+	}
+
+	return k.UserKey, v
+}
+
+func (s *tableSource) Error() error {
+	return s.it.Error()
+}
+
+func (s *tableSource) Close() error {
+	err := s.it.Close()
+	if err != nil {
+		_ = s.r.Close()
+		return err
+	}
+
+	return s.r.Close()
+}
+
+type entriesSource struct {
+	entries []loaderEntry
+	i       int
+}
+
+func (s *entriesSource) First() ([]byte, []byte) {
+	s.i = 0
+	return s.current()
+}
+
+func (s *entriesSource) Next() ([]byte, []byte) {
+	s.i++
+	return s.current()
+}
+
+func (s *entriesSource) current() ([]byte, []byte) {
+	if s.i >= len(s.entries) {
+		return nil, nil
+	}
+
+	return s.entries[s.i].key, s.entries[s.i].value
+}
+
+func (s *entriesSource) Error() error {
+	return nil
+}
+
+func (s *entriesSource) Close() error {
+	return nil
+}
+
+type mergeItem struct {
+	src        mergeSource
+	key, value []byte
+}
+
+// mergeHeap returns the source with the smallest key first.
+type mergeHeap []*mergeItem
+
+func (h mergeHeap) Len() int           { return len(h) }
+func (h mergeHeap) Less(i, j int) bool { return bytes.Compare(h[i].key, h[j].key) < 0 }
+func (h mergeHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *mergeHeap) Push(x interface{}) {
+	*h = append(*h, x.(*mergeItem))
+}
+
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	it := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return it
+}
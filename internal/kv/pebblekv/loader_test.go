@@ -0,0 +1,83 @@
+package pebblekv
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/genjidb/genji/internal/kv"
+	"github.com/genjidb/genji/internal/testutil/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoaderSpill(t *testing.T) {
+	defer func(bufferSize int, fileSize uint64) {
+		loaderBufferSize, loaderFileSize = bufferSize, fileSize
+	}(loaderBufferSize, loaderFileSize)
+	// spill every few keys and split the ingested files
+	loaderBufferSize = 100
+	loaderFileSize = 1000
+
+	fs := vfs.NewMem()
+	ng, err := NewEngine("pebble", Options{Pebble: &pebble.Options{FS: fs}})
+	assert.NoError(t, err)
+	defer ng.Close()
+
+	tx, err := ng.Begin(kv.TxOptions{Writable: true})
+	assert.NoError(t, err)
+	defer tx.Rollback()
+	assert.NoError(t, tx.CreateStore([]byte("a")))
+	assert.NoError(t, tx.Commit())
+
+	load := func(keys ...int) error {
+		l, err := ng.NewLoader()
+		assert.NoError(t, err)
+		defer l.Close()
+
+		for _, k := range keys {
+			err = l.Put([]byte("a"), []byte(fmt.Sprintf("%04d", k)), []byte("value"))
+			if err != nil {
+				return err
+			}
+		}
+
+		var prev string
+		return l.Commit(func(store, k, v []byte) error {
+			require.Less(t, prev, string(k))
+			prev = string(k)
+			return nil
+		})
+	}
+
+	// every key added twice, in separate chunks
+	keys := make([]int, 0, 2000)
+	for i := 999; i >= 0; i-- {
+		keys = append(keys, i)
+	}
+	err = load(append(keys, keys...)...)
+	assert.ErrorIs(t, err, kv.ErrDuplicateKey)
+
+	err = load(keys...)
+	assert.NoError(t, err)
+
+	tx, err = ng.Begin(kv.TxOptions{})
+	assert.NoError(t, err)
+	defer tx.Rollback()
+
+	var count int
+	it := tx.GetStore([]byte("a")).Iterator(nil)
+	for it.First(); it.Valid(); it.Next() {
+		require.Equal(t, fmt.Sprintf("%04d", count), string(it.Key()))
+		count++
+	}
+	assert.NoError(t, it.Close())
+	require.Equal(t, 1000, count)
+
+	// temporary files are removed
+	files, err := fs.List("")
+	assert.NoError(t, err)
+	for _, f := range files {
+		require.NotContains(t, f, ".genji-load")
+	}
+}
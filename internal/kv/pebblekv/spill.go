@@ -0,0 +1,584 @@
+package pebblekv
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/sstable"
+	"github.com/cockroachdb/pebble/vfs"
+)
+
+// Values written to the database of a spill are prefixed by a tag
+// indicating whether the key was set or deleted by the transaction.
+const (
+	tagDelete byte = iota
+	tagSet
+)
+
+// tempPath returns a unique path for temporary files in the given filesystem.
+// Files are created in the temporary directory of the engine, unless the
+// filesystem is in memory.
+func (e *Engine) tempPath(fs vfs.FS, prefix string) string {
+	var dir string
+	if _, inMemory := fs.(*vfs.MemFS); !inMemory {
+		dir = e.opts.TempDir
+		if dir == "" {
+			dir = os.TempDir()
+		}
+	}
+
+	return fs.PathJoin(dir, fmt.Sprintf("%s-%d", prefix, time.Now().Unix()+rand.Int63()))
+}
+
+// iterator is implemented by Pebble iterators and by the iterators
+// of spilled transactions.
+type iterator interface {
+	First() bool
+	Last() bool
+	SeekGE(key []byte) bool
+	SeekLT(key []byte) bool
+	Next() bool
+	Prev() bool
+	Valid() bool
+	Key() []byte
+	Value() []byte
+	Error() error
+	Close() error
+}
+
+// A txBatch is an indexed batch used by a transaction.
+// Iterators created on a batch keep using it after the transaction
+// replaced it, for example after spilling it: the batch is only closed
+// once it is retired and all of its iterators are closed.
+type txBatch struct {
+	*pebble.Batch
+	iters   int
+	retired bool
+}
+
+func (b *txBatch) newIter(opts *pebble.IterOptions) *batchIterator {
+	b.iters++
+	return &batchIterator{Iterator: b.Batch.NewIter(opts), b: b}
+}
+
+// retire closes the batch once its iterators are closed.
+func (b *txBatch) retire() {
+	if b.retired {
+		return
+	}
+
+	b.retired = true
+	if b.iters == 0 {
+		_ = b.Batch.Close()
+	}
+}
+
+func (b *txBatch) release() {
+	b.iters--
+	if b.retired && b.iters == 0 {
+		_ = b.Batch.Close()
+	}
+}
+
+type batchIterator struct {
+	*pebble.Iterator
+	b *txBatch
+}
+
+func (it *batchIterator) Close() error {
+	err := it.Iterator.Close()
+	it.b.release()
+	return err
+}
+
+// A spill holds the writes of a transaction that exceeded the maximum size of
+// its batch. They are written to a temporary Pebble database, without write-ahead log,
+// where they are stored with a tag indicating whether they set or delete a key.
+// Range deletions are applied to the temporary database and recorded separately
+// to hide the committed keys they cover.
+// On commit, the writes are written to an sstable which is ingested by the engine,
+// which applies them atomically.
+type spill struct {
+	ng   *Engine
+	db   *pebble.DB
+	fs   vfs.FS
+	path string
+	// batch buffering the writes before they are written to db.
+	batch *txBatch
+	// range deletions made by the transaction, sorted and non overlapping.
+	ranges []keyRange
+}
+
+func newSpill(ng *Engine) (*spill, error) {
+	opts := pebble.Options{
+		DisableWAL: true,
+	}
+
+	var inMemory bool
+	if ng.opts.Pebble != nil {
+		_, inMemory = ng.opts.Pebble.FS.(*vfs.MemFS)
+	}
+	if inMemory {
+		opts.FS = vfs.NewMem()
+	}
+	opts.EnsureDefaults()
+
+	path := ng.tempPath(opts.FS, ".genji-spill")
+	db, err := pebble.Open(path, &opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &spill{
+		ng:    ng,
+		db:    db,
+		fs:    opts.FS,
+		path:  path,
+		batch: &txBatch{Batch: db.NewIndexedBatch()},
+	}, nil
+}
+
+// add replays the writes of the batch.
+func (s *spill) add(b *pebble.Batch) error {
+	r, _ := pebble.ReadBatch(b.Repr())
+	for len(r) > 0 {
+		kind, k, v, ok := r.Next()
+		if !ok {
+			return errors.New("invalid batch")
+		}
+
+		var err error
+		switch kind {
+		case pebble.InternalKeyKindSet:
+			err = s.set(k, v)
+		case pebble.InternalKeyKindDelete, pebble.InternalKeyKindSingleDelete:
+			err = s.delete(k)
+		case pebble.InternalKeyKindRangeDelete:
+			err = s.deleteRange(k, v)
+		default:
+			err = errors.Newf("unsupported batch operation %d", kind)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// get returns the value of the key, looking for it in the writes of the transaction
+// then in the committed data, unless it was deleted by the transaction.
+func (s *spill) get(key []byte) ([]byte, io.Closer, error) {
+	v, closer, err := s.batch.Get(key)
+	if errors.Is(err, pebble.ErrNotFound) {
+		if _, ok := findRange(s.ranges, key); ok {
+			return nil, nil, pebble.ErrNotFound
+		}
+
+		return s.ng.DB.Get(key)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if v[0] == tagDelete {
+		_ = closer.Close()
+		return nil, nil, pebble.ErrNotFound
+	}
+
+	return v[1:], closer, nil
+}
+
+func (s *spill) set(key, value []byte) error {
+	v := make([]byte, len(value)+1)
+	v[0] = tagSet
+	copy(v[1:], value)
+
+	err := s.batch.Set(key, v, nil)
+	if err != nil {
+		return err
+	}
+
+	return s.flushIfNeeded()
+}
+
+func (s *spill) delete(key []byte) error {
+	err := s.batch.Set(key, []byte{tagDelete}, nil)
+	if err != nil {
+		return err
+	}
+
+	return s.flushIfNeeded()
+}
+
+func (s *spill) deleteRange(start, end []byte) error {
+	err := s.batch.DeleteRange(start, end, nil)
+	if err != nil {
+		return err
+	}
+
+	s.ranges = addRange(s.ranges, keyRange{
+		start: append([]byte(nil), start...),
+		end:   append([]byte(nil), end...),
+	})
+
+	return s.flushIfNeeded()
+}
+
+// flushIfNeeded writes the batch to the database once it
+// reaches the maximum size of a transaction.
+func (s *spill) flushIfNeeded() error {
+	if len(s.batch.Repr()) < s.ng.opts.MaxTxSize {
+		return nil
+	}
+
+	return s.flush()
+}
+
+func (s *spill) flush() error {
+	if s.batch.Empty() {
+		return nil
+	}
+
+	err := s.batch.Commit(pebble.NoSync)
+	if err != nil {
+		return err
+	}
+
+	s.batch.retire()
+	s.batch = &txBatch{Batch: s.db.NewIndexedBatch()}
+	return nil
+}
+
+func (s *spill) newIter(opts *pebble.IterOptions) *mergedIterator {
+	return &mergedIterator{
+		writes:    s.batch.newIter(opts),
+		committed: s.ng.DB.NewIter(opts),
+		// the ranges are never modified, only replaced
+		ranges: s.ranges,
+	}
+}
+
+// commit writes the writes of the transaction to an sstable and ingests it.
+func (s *spill) commit() error {
+	err := s.flush()
+	if err != nil {
+		return err
+	}
+
+	opts := s.ng.opts.Pebble.Clone().EnsureDefaults()
+	path := s.ng.tempPath(opts.FS, ".genji-spill")
+	f, err := opts.FS.Create(path + ".sst")
+	if err != nil {
+		return err
+	}
+	defer opts.FS.Remove(path + ".sst")
+
+	w := sstable.NewWriter(f, opts.MakeWriterOptions(0))
+
+	var empty bool
+	err = func() error {
+		it := s.db.NewIter(nil)
+		defer it.Close()
+
+		empty = !it.First() && len(s.ranges) == 0
+		for ; it.Valid(); it.Next() {
+			v := it.Value()
+			if v[0] == tagDelete {
+				err = w.Delete(it.Key())
+			} else {
+				err = w.Set(it.Key(), v[1:])
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if err := it.Error(); err != nil {
+			return err
+		}
+
+		// range deletions don't delete the keys of the same sstable
+		for _, r := range s.ranges {
+			err = w.DeleteRange(r.start, r.end)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}()
+	if err != nil {
+		_ = w.Close()
+		return err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+
+	if empty {
+		return nil
+	}
+
+	return s.ng.DB.Ingest([]string{path + ".sst"})
+}
+
+// drop releases the temporary database.
+func (s *spill) drop() error {
+	s.batch.retire()
+
+	err := s.db.Close()
+
+	rmErr := s.fs.RemoveAll(s.path)
+	if err != nil {
+		return err
+	}
+
+	return rmErr
+}
+
+// keyRange is a range of keys, from start (inclusive) to end (exclusive).
+type keyRange struct {
+	start, end []byte
+}
+
+// findRange returns the range containing the key, if any.
+func findRange(ranges []keyRange, key []byte) (keyRange, bool) {
+	i := sort.Search(len(ranges), func(i int) bool {
+		return bytes.Compare(ranges[i].end, key) > 0
+	})
+	if i < len(ranges) && bytes.Compare(ranges[i].start, key) <= 0 {
+		return ranges[i], true
+	}
+
+	return keyRange{}, false
+}
+
+// addRange returns a new list of ranges containing r,
+// merged with the ranges it overlaps or touches.
+func addRange(ranges []keyRange, r keyRange) []keyRange {
+	list := make([]keyRange, 0, len(ranges)+1)
+
+	var i int
+	for ; i < len(ranges) && bytes.Compare(ranges[i].end, r.start) < 0; i++ {
+		list = append(list, ranges[i])
+	}
+	for ; i < len(ranges) && bytes.Compare(ranges[i].start, r.end) <= 0; i++ {
+		if bytes.Compare(ranges[i].start, r.start) < 0 {
+			r.start = ranges[i].start
+		}
+		if bytes.Compare(ranges[i].end, r.end) > 0 {
+			r.end = ranges[i].end
+		}
+	}
+	list = append(list, r)
+
+	return append(list, ranges[i:]...)
+}
+
+// mergedIterator iterates over the keys seen by a spilled transaction:
+// the keys written by the transaction, and the committed keys that
+// were not deleted by the transaction.
+// When both contain the same key, the key written by the transaction is returned.
+type mergedIterator struct {
+	writes    iterator
+	committed iterator
+	ranges    []keyRange
+
+	// iterator positioned on the current key, nil if the iterator is not valid.
+	cur     iterator
+	reverse bool
+	key     []byte
+}
+
+func (it *mergedIterator) First() bool {
+	it.writes.First()
+	it.committed.First()
+	it.reverse = false
+	return it.settleForward()
+}
+
+func (it *mergedIterator) Last() bool {
+	it.writes.Last()
+	it.committed.Last()
+	it.reverse = true
+	return it.settleReverse()
+}
+
+func (it *mergedIterator) SeekGE(key []byte) bool {
+	it.writes.SeekGE(key)
+	it.committed.SeekGE(key)
+	it.reverse = false
+	return it.settleForward()
+}
+
+func (it *mergedIterator) SeekLT(key []byte) bool {
+	it.writes.SeekLT(key)
+	it.committed.SeekLT(key)
+	it.reverse = true
+	return it.settleReverse()
+}
+
+func (it *mergedIterator) Next() bool {
+	if it.cur == nil {
+		return false
+	}
+
+	// move both iterators after the current key
+	it.key = append(it.key[:0], it.cur.Key()...)
+	for _, i := range []iterator{it.writes, it.committed} {
+		if it.reverse {
+			i.SeekGE(it.key)
+		}
+		if i.Valid() && bytes.Equal(i.Key(), it.key) {
+			i.Next()
+		}
+	}
+
+	it.reverse = false
+	return it.settleForward()
+}
+
+func (it *mergedIterator) Prev() bool {
+	if it.cur == nil {
+		return false
+	}
+
+	// move both iterators before the current key
+	it.key = append(it.key[:0], it.cur.Key()...)
+	for _, i := range []iterator{it.writes, it.committed} {
+		if !it.reverse {
+			i.SeekLT(it.key)
+		} else if i.Valid() && bytes.Equal(i.Key(), it.key) {
+			i.Prev()
+		}
+	}
+
+	it.reverse = true
+	return it.settleReverse()
+}
+
+// settleForward positions the iterator on the smallest key
+// of both iterators that was not deleted.
+func (it *mergedIterator) settleForward() bool {
+	for {
+		for it.committed.Valid() {
+			r, ok := findRange(it.ranges, it.committed.Key())
+			if !ok {
+				break
+			}
+			it.committed.SeekGE(r.end)
+		}
+
+		w, c := it.writes.Valid(), it.committed.Valid()
+		if !w && !c {
+			it.cur = nil
+			return false
+		}
+
+		cmp := -1
+		if w && c {
+			cmp = bytes.Compare(it.writes.Key(), it.committed.Key())
+		} else if c {
+			cmp = 1
+		}
+		if cmp > 0 {
+			it.cur = it.committed
+			return true
+		}
+
+		if it.writes.Value()[0] != tagDelete {
+			it.cur = it.writes
+			return true
+		}
+
+		// skip deleted keys
+		if cmp == 0 {
+			it.committed.Next()
+		}
+		it.writes.Next()
+	}
+}
+
+// settleReverse positions the iterator on the greatest key
+// of both iterators that was not deleted.
+func (it *mergedIterator) settleReverse() bool {
+	for {
+		for it.committed.Valid() {
+			r, ok := findRange(it.ranges, it.committed.Key())
+			if !ok {
+				break
+			}
+			it.committed.SeekLT(r.start)
+		}
+
+		w, c := it.writes.Valid(), it.committed.Valid()
+		if !w && !c {
+			it.cur = nil
+			return false
+		}
+
+		cmp := 1
+		if w && c {
+			cmp = bytes.Compare(it.writes.Key(), it.committed.Key())
+		} else if c {
+			cmp = -1
+		}
+		if cmp < 0 {
+			it.cur = it.committed
+			return true
+		}
+
+		if it.writes.Value()[0] != tagDelete {
+			it.cur = it.writes
+			return true
+		}
+
+		// skip deleted keys
+		if cmp == 0 {
+			it.committed.Prev()
+		}
+		it.writes.Prev()
+	}
+}
+
+func (it *mergedIterator) Valid() bool {
+	return it.cur != nil
+}
+
+func (it *mergedIterator) Key() []byte {
+	return it.cur.Key()
+}
+
+func (it *mergedIterator) Value() []byte {
+	if it.cur == it.writes {
+		return it.cur.Value()[1:]
+	}
+
+	return it.cur.Value()
+}
+
+func (it *mergedIterator) Error() error {
+	if err := it.writes.Error(); err != nil {
+		return err
+	}
+
+	return it.committed.Error()
+}
+
+func (it *mergedIterator) Close() error {
+	err := it.writes.Close()
+	if cerr := it.committed.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
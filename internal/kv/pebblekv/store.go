@@ -0,0 +1,272 @@
+package pebblekv
+
+import (
+	"os"
+
+	"github.com/cockroachdb/errors"
+	"github.com/cockroachdb/pebble"
+	"github.com/genjidb/genji/internal/kv"
+)
+
+// A Store prefixes all of its keys with the prefix of the store.
+type Store struct {
+	ng       *Engine
+	tx       *Transaction
+	Prefix   []byte
+	writable bool
+	name     []byte
+}
+
+// Put stores a key value pair. If it already exists, it overrides it.
+func (s *Store) Put(k, v []byte) error {
+	if !s.writable {
+		return kv.ErrTransactionReadOnly
+	}
+
+	if len(k) == 0 {
+		return errors.New("cannot store empty key")
+	}
+
+	if len(v) == 0 {
+		return errors.New("cannot store empty value")
+	}
+
+	key := kv.BuildKey(s.Prefix, k)
+	err := s.tx.set(key, v)
+	kv.ReleaseKey(key)
+	return err
+}
+
+// Get returns a value associated with the given key. If not found, returns ErrKeyNotFound.
+func (s *Store) Get(k []byte) ([]byte, error) {
+	key := kv.BuildKey(s.Prefix, k)
+	value, closer, err := s.tx.get(key)
+	kv.ReleaseKey(key)
+	if err != nil {
+		if errors.Is(err, pebble.ErrNotFound) {
+			return nil, errors.WithStack(kv.ErrKeyNotFound)
+		}
+
+		return nil, err
+	}
+
+	cp := make([]byte, len(value))
+	copy(cp, value)
+
+	err = closer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return cp, nil
+}
+
+// Exists returns true if the key exists.
+func (s *Store) Exists(k []byte) (bool, error) {
+	key := kv.BuildKey(s.Prefix, k)
+	_, closer, err := s.tx.get(key)
+	kv.ReleaseKey(key)
+	if err != nil {
+		if errors.Is(err, pebble.ErrNotFound) {
+			return false, nil
+		}
+
+		return false, err
+	}
+	err = closer.Close()
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Delete a record by key. If not found, returns ErrKeyNotFound.
+func (s *Store) Delete(k []byte) error {
+	if !s.writable {
+		return kv.ErrTransactionReadOnly
+	}
+
+	key := kv.BuildKey(s.Prefix, k)
+	_, closer, err := s.tx.get(key)
+	if err != nil {
+		kv.ReleaseKey(key)
+		if errors.Is(err, pebble.ErrNotFound) {
+			return errors.WithStack(kv.ErrKeyNotFound)
+		}
+
+		return err
+	}
+	err = closer.Close()
+	if err != nil {
+		kv.ReleaseKey(key)
+		return err
+	}
+
+	err = s.tx.delete(key)
+	kv.ReleaseKey(key)
+	return err
+}
+
+// Truncate deletes all the records of the store.
+// The keys are removed using a single range deletion, which keeps the size
+// of the transaction constant regardless of the number of keys in the store.
+// Their space is reclaimed by Pebble's compactions.
+func (s *Store) Truncate() error {
+	if !s.writable {
+		return kv.ErrTransactionReadOnly
+	}
+
+	_, closer, err := s.tx.get(kv.StoreKey(s.name))
+	if err != nil {
+		if errors.Is(err, pebble.ErrNotFound) {
+			return errors.WithStack(kv.ErrKeyNotFound)
+		}
+
+		return err
+	}
+	err = closer.Close()
+	if err != nil {
+		return err
+	}
+
+	lowerBound := kv.BuildKey(s.Prefix, nil)
+	upperBound := kv.BuildEndKey(s.Prefix)
+	defer kv.ReleaseKey(lowerBound)
+	defer kv.ReleaseKey(upperBound)
+
+	return s.tx.deleteRange(lowerBound, upperBound)
+}
+
+// Iterator returns an iterator over the keys of the store.
+// If opts is nil, the iterator covers the whole store.
+func (s *Store) Iterator(opts *kv.IterOptions) kv.Iterator {
+	if opts == nil {
+		opts = &kv.IterOptions{}
+	}
+
+	it := Iterator{
+		prefix:     s.Prefix,
+		lowerBound: kv.BuildKey(s.Prefix, opts.LowerBound),
+	}
+	if opts.UpperBound != nil {
+		it.upperBound = kv.BuildKey(s.Prefix, opts.UpperBound)
+	} else {
+		it.upperBound = kv.BuildEndKey(s.Prefix)
+	}
+
+	popts := pebble.IterOptions{
+		LowerBound: it.lowerBound,
+		UpperBound: it.upperBound,
+	}
+	it.iterator = s.tx.newIter(&popts)
+
+	return &it
+}
+
+// An Iterator wraps a Pebble iterator, or the iterator of a spilled transaction,
+// and returns keys relative to the store.
+type Iterator struct {
+	iterator
+
+	// prefix of the store, nil for transient stores.
+	prefix                 []byte
+	lowerBound, upperBound []byte
+}
+
+// SeekGE moves the iterator to the first key greater than or equal to the given key.
+func (it *Iterator) SeekGE(key []byte) bool {
+	if it.prefix == nil {
+		return it.iterator.SeekGE(key)
+	}
+
+	// Pebble might keep a reference to the key,
+	// it must not be shared with the pool.
+	k := kv.BuildKey(it.prefix, key)
+	cp := append([]byte(nil), k...)
+	kv.ReleaseKey(k)
+	return it.iterator.SeekGE(cp)
+}
+
+// Key returns the key relative to the store.
+func (it *Iterator) Key() []byte {
+	if it.prefix == nil {
+		return it.iterator.Key()
+	}
+
+	return kv.TrimPrefix(it.iterator.Key(), it.prefix)
+}
+
+// Close the iterator.
+func (it *Iterator) Close() error {
+	err := it.iterator.Close()
+	if it.lowerBound != nil {
+		kv.ReleaseKey(it.lowerBound)
+	}
+	if it.upperBound != nil {
+		kv.ReleaseKey(it.upperBound)
+	}
+	return err
+}
+
+// A TransientStore is a store backed by a temporary Pebble database.
+type TransientStore struct {
+	DB    *pebble.DB
+	Path  string
+	batch *pebble.Batch
+}
+
+// Put stores a key value pair. If it already exists, it overrides it.
+func (s *TransientStore) Put(k, v []byte) error {
+	if len(k) == 0 {
+		return errors.New("cannot store empty key")
+	}
+
+	if len(v) == 0 {
+		return errors.New("cannot store empty value")
+	}
+
+	if s.batch == nil {
+		s.batch = s.DB.NewIndexedBatch()
+	}
+
+	return s.batch.Set(k, v, nil)
+}
+
+// Iterator returns an iterator over the keys of the store.
+func (s *TransientStore) Iterator(opts *kv.IterOptions) kv.Iterator {
+	var popts pebble.IterOptions
+	if opts != nil {
+		popts.LowerBound = opts.LowerBound
+		popts.UpperBound = opts.UpperBound
+	}
+
+	return &Iterator{
+		iterator: s.batch.NewIter(&popts),
+	}
+}
+
+// Drop releases any resource (files, memory, etc.) used by a transient store.
+func (s *TransientStore) Drop() error {
+	if s.batch != nil {
+		_ = s.batch.Close()
+	}
+
+	_ = s.DB.Close()
+
+	err := os.RemoveAll(s.Path)
+	if err != nil {
+		return err
+	}
+
+	s.batch = nil
+	s.DB = nil
+	return nil
+}
+
+// Reset resets the transient store to be reused.
+func (s *TransientStore) Reset() error {
+	if s.batch != nil {
+		s.batch.Reset()
+	}
+	return nil
+}
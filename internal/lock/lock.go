@@ -146,3 +146,9 @@ type LockRequest struct {
 	Count       int           // Number of times this lock was requested
 	Txid        uint64        // Transaction ID
 }
+
+// isHeld returns true if the lock is held by the transaction, either
+// because it was granted or because it is being converted to another mode.
+func (r *LockRequest) isHeld() bool {
+	return r.Status == LockGranted || r.Status == LockConverting
+}
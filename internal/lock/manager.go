@@ -5,24 +5,38 @@ import (
 	"sync"
 
 	"github.com/cockroachdb/errors"
+	errs "github.com/genjidb/genji/errors"
 )
 
 // A LockManager is used to acquire locks on database objects.
 // It is used by the transaction manager to ensure that
 // transactions do not interfere with each other.
+// Before a transaction starts waiting for a lock, the lock manager
+// looks for cycles in the wait-for graph of the transactions. If waiting
+// would cause a deadlock, the lock request is denied and an *errs.DeadlockError
+// is returned.
 type LockManager struct {
 	mu sync.Mutex
 
 	locks map[Object]*LockHeader
+	// waiting holds the request each waiting transaction is blocked on:
+	// the edges of the wait-for graph leaving a transaction are
+	// the blockers of its request.
+	waiting map[uint64]*LockRequest
 }
 
 // NewLockManager creates a lock manager.
 func NewLockManager() *LockManager {
 	var lm LockManager
 	lm.locks = make(map[Object]*LockHeader)
+	lm.waiting = make(map[uint64]*LockRequest)
 	return &lm
 }
 
+// Lock acquires a lock on the given object for the transaction txid.
+// If the lock can't be granted immediately, it blocks until the lock is granted,
+// or until ctx is canceled. If waiting for the lock would create a deadlock,
+// it returns an *errs.DeadlockError immediately.
 func (lm *LockManager) Lock(ctx context.Context, txid uint64, obj *Object, mode LockMode) (bool, error) {
 	lm.mu.Lock()
 	head, ok := lm.locks[*obj]
@@ -45,9 +59,10 @@ func (lm *LockManager) Lock(ctx context.Context, txid uint64, obj *Object, mode
 	}
 
 	// A lock exists for this object.
-	// Lock the queue header and unlock the map.
+	// Lock the queue header. The map stays locked until
+	// the request is either granted or queued, so that
+	// the wait-for graph can be inspected safely.
 	head.mu.Lock()
-	lm.mu.Unlock()
 
 	// check if a lock request is already in the queue for this couple txid / obj
 	var req, last *LockRequest
@@ -84,6 +99,7 @@ func (lm *LockManager) Lock(ctx context.Context, txid uint64, obj *Object, mode
 			head.GroupMode = MaxMode(mode, head.GroupMode)
 			req.Status = LockGranted
 			head.mu.Unlock()
+			lm.mu.Unlock()
 			return true, nil
 		}
 
@@ -93,20 +109,14 @@ func (lm *LockManager) Lock(ctx context.Context, txid uint64, obj *Object, mode
 		req.WakeUp = make(chan struct{})
 		head.mu.Unlock()
 
-		select {
-		case <-ctx.Done():
-			lm.Unlock(txid, obj)
-			return false, errors.Wrap(ctx.Err(), "lock timeout")
-		case <-req.WakeUp:
-			return true, nil
-		}
+		return lm.wait(ctx, req)
 	}
 
 	// A lock request is already in the queue for this couple txid / obj.
 	// Check if the lock is compatible with all locks of the granted group.
 	compatible := true
-	for other := head.Queue; other != nil && other.Status == LockGranted; other = other.Next {
-		if other != req && !other.Mode.IsCompatibleWith(mode) {
+	for other := head.Queue; other != nil; other = other.Next {
+		if other != req && other.isHeld() && !other.Mode.IsCompatibleWith(mode) {
 			compatible = false
 		}
 	}
@@ -118,25 +128,152 @@ func (lm *LockManager) Lock(ctx context.Context, txid uint64, obj *Object, mode
 		req.WakeUp = make(chan struct{})
 		head.mu.Unlock()
 
-		select {
-		case <-ctx.Done():
-			// if the context gets canceled, the transaction will rollback,
-			// and call unlock on all objects that were locked by this transaction
-			return false, errors.Wrap(ctx.Err(), "lock timeout")
-		case <-req.WakeUp:
-			return true, nil
-		}
+		return lm.wait(ctx, req)
 	}
 
 	// The lock is compatible with all locks of the granted group.
 	// Update the counter, the group mode and return.
 	req.Count++
-	req.Mode = mode
-	head.GroupMode = MaxMode(mode, head.GroupMode)
+	req.Mode = MaxMode(mode, req.Mode)
+	head.GroupMode = MaxMode(req.Mode, head.GroupMode)
+	head.mu.Unlock()
+	lm.mu.Unlock()
 
 	return true, nil
 }
 
+// wait blocks until the given request is granted or ctx is canceled.
+// It must be called with lm.mu locked and unlocks it.
+func (lm *LockManager) wait(ctx context.Context, req *LockRequest) (bool, error) {
+	lm.waiting[req.Txid] = req
+
+	if lm.hasCycle(req.Txid) {
+		lm.cancel(req)
+		lm.mu.Unlock()
+		return false, errors.WithStack(&errs.DeadlockError{Txid: req.Txid})
+	}
+	lm.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		lm.mu.Lock()
+		defer lm.mu.Unlock()
+
+		// the lock might have been granted in the meantime
+		if !lm.cancel(req) {
+			return true, nil
+		}
+
+		return false, errors.Wrap(ctx.Err(), "lock timeout")
+	case <-req.WakeUp:
+		return true, nil
+	}
+}
+
+// cancel removes a waiting request from the queue, or reverts a converting
+// request to its previously granted mode, and wakes up any request that can be
+// granted as a result. It returns false if the request was already granted.
+// It must be called with lm.mu locked.
+func (lm *LockManager) cancel(req *LockRequest) bool {
+	head := req.Head
+	head.mu.Lock()
+	defer head.mu.Unlock()
+
+	switch req.Status {
+	case LockGranted:
+		return false
+	case LockConverting:
+		req.Status = LockGranted
+		req.ConvertMode = Free
+	default:
+		var prev *LockRequest
+		for r := head.Queue; r != nil && r != req; r = r.Next {
+			prev = r
+		}
+		if prev != nil {
+			prev.Next = req.Next
+		} else {
+			head.Queue = req.Next
+		}
+
+		if head.Queue == nil {
+			delete(lm.waiting, req.Txid)
+			delete(lm.locks, *head.Object)
+			return true
+		}
+	}
+
+	delete(lm.waiting, req.Txid)
+	lm.grant(head)
+	return true
+}
+
+// hasCycle returns true if the transaction txid is part of a cycle
+// in the wait-for graph, by following the edges leaving txid.
+// It must be called with lm.mu locked.
+func (lm *LockManager) hasCycle(txid uint64) bool {
+	visited := make(map[uint64]bool)
+	stack := []uint64{txid}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		// transactions that are not waiting have no edges
+		req, ok := lm.waiting[id]
+		if !ok || visited[id] {
+			continue
+		}
+		visited[id] = true
+
+		req.Head.mu.Lock()
+		blockers := req.Head.blockers(req)
+		req.Head.mu.Unlock()
+
+		for _, b := range blockers {
+			if b == txid {
+				return true
+			}
+			stack = append(stack, b)
+		}
+	}
+
+	return false
+}
+
+// blockers returns the list of transactions the given request is waiting for:
+// every transaction holding an incompatible lock and every transaction that
+// is ahead of the request in the queue and still waiting.
+// It must be called with head.mu locked.
+func (head *LockHeader) blockers(req *LockRequest) []uint64 {
+	mode := req.Mode
+	if req.Status == LockConverting {
+		mode = req.ConvertMode
+	}
+
+	var ids []uint64
+	ahead := true
+	for other := head.Queue; other != nil; other = other.Next {
+		if other == req {
+			ahead = false
+			continue
+		}
+		if other.Txid == req.Txid {
+			continue
+		}
+
+		if ahead && other.Status != LockGranted {
+			ids = append(ids, other.Txid)
+			continue
+		}
+
+		if other.isHeld() && !other.Mode.IsCompatibleWith(mode) {
+			ids = append(ids, other.Txid)
+		}
+	}
+
+	return ids
+}
+
 func (lm *LockManager) Unlock(txid uint64, obj *Object) bool {
 	lm.mu.Lock()
 	head, ok := lm.locks[*obj]
@@ -186,14 +323,42 @@ func (lm *LockManager) Unlock(txid uint64, obj *Object) bool {
 		head.Queue = req.Next
 	}
 
+	lm.grant(head)
+
+	head.mu.Unlock()
+	lm.mu.Unlock()
+
+	return true
+}
+
+// grant wakes up the requests of head that can be granted
+// and removes them from the wait-for graph.
+// It must be called with lm.mu and head.mu locked.
+func (lm *LockManager) grant(head *LockHeader) {
+	for _, req := range head.grant() {
+		delete(lm.waiting, req.Txid)
+	}
+}
+
+// grant refreshes the group mode and wakes up all the requests
+// that are compatible with it, in order. It returns the granted requests.
+// It must be called with head.mu locked.
+func (head *LockHeader) grant() []*LockRequest {
+	var granted []*LockRequest
+
 	head.Waiting = false
 	head.GroupMode = Free
 
+	// refresh the group mode with granted requests
+	for req := head.Queue; req != nil; req = req.Next {
+		if req.isHeld() {
+			head.GroupMode = MaxMode(req.Mode, head.GroupMode)
+		}
+	}
+
 	// wake up all compatible requests
-	for req = head.Queue; req != nil; req = req.Next {
-		// refresh the group mode with granted requests
+	for req := head.Queue; req != nil; req = req.Next {
 		if req.Status == LockGranted {
-			head.GroupMode = MaxMode(req.Mode, head.GroupMode)
 			continue
 		}
 
@@ -202,8 +367,8 @@ func (lm *LockManager) Unlock(txid uint64, obj *Object) bool {
 			// if a lock is converting, only wake up the request if the
 			// new mode is compatible with every other member of the group.
 			compatible := true
-			for other := head.Queue; other != nil && other.Status == LockGranted; other = other.Next {
-				if other == req {
+			for other := head.Queue; other != nil; other = other.Next {
+				if other == req || !other.isHeld() {
 					continue
 				}
 
@@ -215,8 +380,10 @@ func (lm *LockManager) Unlock(txid uint64, obj *Object) bool {
 			if compatible {
 				req.Status = LockGranted
 				req.Count++
+				req.Mode = MaxMode(req.ConvertMode, req.Mode)
 				head.GroupMode = MaxMode(req.Mode, head.GroupMode)
 				close(req.WakeUp)
+				granted = append(granted, req)
 			} else {
 				// stop here
 				head.Waiting = true
@@ -233,6 +400,7 @@ func (lm *LockManager) Unlock(txid uint64, obj *Object) bool {
 				req.Status = LockGranted
 				head.GroupMode = MaxMode(req.Mode, head.GroupMode)
 				close(req.WakeUp)
+				granted = append(granted, req)
 			} else {
 				// stop here
 				head.Waiting = true
@@ -243,8 +411,5 @@ func (lm *LockManager) Unlock(txid uint64, obj *Object) bool {
 		}
 	}
 
-	head.mu.Unlock()
-	lm.mu.Unlock()
-
-	return true
+	return granted
 }
@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	errs "github.com/genjidb/genji/errors"
 	"github.com/stretchr/testify/require"
 )
 
@@ -39,6 +40,27 @@ func TestLockManagerLock(t *testing.T) {
 		require.Equal(t, 2, m.locks[*doc].Queue.Count)
 	})
 
+	t.Run("lock twice, then lock from another transaction", func(t *testing.T) {
+		m := NewLockManager()
+
+		doc := NewDocumentObject("t", []byte("a"))
+
+		ok, err := m.Lock(getCtx(t), 1, doc, S)
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		ok, err = m.Lock(getCtx(t), 1, doc, IS)
+		require.NoError(t, err)
+		require.True(t, ok)
+		// the mode must not be downgraded
+		require.Equal(t, S, m.locks[*doc].Queue.Mode)
+
+		ok, err = m.Lock(getCtx(t), 2, doc, S)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, 2, queueLen(m.locks[*doc].Queue))
+	})
+
 	t.Run("same object: S", func(t *testing.T) {
 		m := NewLockManager()
 
@@ -241,3 +263,115 @@ func TestLockManagerUnlock(t *testing.T) {
 		<-ch2
 	})
 }
+
+func TestLockManagerDeadlock(t *testing.T) {
+	t.Run("two transactions", func(t *testing.T) {
+		m := NewLockManager()
+
+		a := NewDocumentObject("t", []byte("a"))
+		b := NewDocumentObject("t", []byte("b"))
+
+		ok, err := m.Lock(getCtx(t), 1, a, X)
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		ok, err = m.Lock(getCtx(t), 2, b, X)
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		ch := make(chan error)
+		go func() {
+			_, err := m.Lock(getCtx(t), 1, b, X)
+			ch <- err
+		}()
+
+		// wait for tx 1 to be queued
+		for {
+			m.mu.Lock()
+			n := queueLen(m.locks[*b].Queue)
+			m.mu.Unlock()
+			if n == 2 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		ok, err = m.Lock(getCtx(t), 2, a, X)
+		require.False(t, ok)
+		require.True(t, errs.IsDeadlockError(err))
+		require.Equal(t, 1, queueLen(m.locks[*a].Queue))
+
+		// tx 2 is aborted and releases its locks
+		m.Unlock(2, b)
+		require.NoError(t, <-ch)
+
+		// granted and denied requests are removed from the wait-for graph
+		require.Empty(t, m.waiting)
+	})
+
+	t.Run("conversion", func(t *testing.T) {
+		m := NewLockManager()
+
+		tb := NewTableObject("t")
+
+		ok, err := m.Lock(getCtx(t), 1, tb, S)
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		ok, err = m.Lock(getCtx(t), 2, tb, S)
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		ch := make(chan error)
+		go func() {
+			_, err := m.Lock(getCtx(t), 1, tb, X)
+			ch <- err
+		}()
+
+		for {
+			m.mu.Lock()
+			status := m.locks[*tb].Queue.Status
+			m.mu.Unlock()
+			if status == LockConverting {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		ok, err = m.Lock(getCtx(t), 2, tb, X)
+		require.False(t, ok)
+		require.True(t, errs.IsDeadlockError(err))
+
+		m.Unlock(2, tb)
+		require.NoError(t, <-ch)
+		require.Equal(t, X, m.locks[*tb].GroupMode)
+		require.Empty(t, m.waiting)
+	})
+}
+
+func TestLockManagerTimeout(t *testing.T) {
+	m := NewLockManager()
+
+	doc := NewDocumentObject("t", []byte("a"))
+
+	ok, err := m.Lock(getCtx(t), 1, doc, X)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	ok, err = m.Lock(ctx, 2, doc, S)
+	require.Error(t, err)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.False(t, ok)
+	require.Equal(t, 1, queueLen(m.locks[*doc].Queue))
+	require.False(t, m.locks[*doc].Waiting)
+	require.Empty(t, m.waiting)
+
+	// other transactions can still acquire the lock once it is released
+	m.Unlock(1, doc)
+	ok, err = m.Lock(getCtx(t), 3, doc, S)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
@@ -0,0 +1,252 @@
+package planner
+
+import (
+	"math"
+
+	"github.com/genjidb/genji/internal/database"
+	"github.com/genjidb/genji/internal/expr"
+	"github.com/genjidb/genji/internal/stream"
+	"github.com/genjidb/genji/types"
+)
+
+// Cost model used when the table has been analyzed.
+// Costs are expressed in number of documents or index entries read.
+const (
+	// cost of reading one document or one index entry sequentially.
+	seqReadCost = 1
+	// additional cost of fetching a document from the table
+	// for each entry read from an index.
+	docFetchCost = 3
+
+	// selectivity of an equality when no statistics are available for the index.
+	defaultEqualitySelectivity = 0.1
+	// selectivity of a range when its bounds can't be compared with the histogram.
+	defaultRangeSelectivity = 1.0 / 3
+)
+
+// costEstimator estimates the cost of reading a table using the statistics
+// computed by the ANALYZE statement.
+type costEstimator struct {
+	stats *database.TableStatistics
+	// true if the stream has a TempTreeSort node.
+	sorted bool
+}
+
+// tableScanCost returns the cost of reading the whole table,
+// plus the cost of sorting its documents if needed.
+func (e *costEstimator) tableScanCost() float64 {
+	rows := float64(e.stats.RowCount)
+
+	cost := rows * seqReadCost
+	if e.sorted {
+		cost += sortCost(rows)
+	}
+
+	return cost
+}
+
+// candidateCost returns the cost of reading the documents selected
+// by the ranges of the candidate, plus the cost of sorting them if the candidate
+// doesn't return them in the right order.
+func (e *costEstimator) candidateCost(c *candidate) float64 {
+	rows := float64(e.stats.RowCount) * e.candidateSelectivity(c)
+
+	cost := rows * seqReadCost
+	if c.isIndex {
+		cost += rows * docFetchCost
+	}
+	if e.sorted && !c.sorts {
+		cost += sortCost(rows)
+	}
+
+	return cost
+}
+
+// candidateSelectivity returns the estimated fraction of the table
+// returned by the ranges of the candidate.
+func (e *costEstimator) candidateSelectivity(c *candidate) float64 {
+	if len(c.ranges) == 0 {
+		return 1
+	}
+
+	var is *database.IndexStatistics
+	if c.isIndex {
+		is = e.stats.Indexes[c.treeName]
+	}
+
+	var sel float64
+	for i := range c.ranges {
+		sel += e.rangeSelectivity(&c.ranges[i], c, is)
+	}
+
+	// at least one document is expected to be read
+	if e.stats.RowCount > 0 {
+		sel = math.Max(sel, 1/float64(e.stats.RowCount))
+	}
+
+	return math.Min(sel, 1)
+}
+
+// rangeSelectivity estimates the fraction of the table selected by the range.
+// Ranges are built from equalities on the first paths, and optionally a comparison
+// on the last one.
+func (e *costEstimator) rangeSelectivity(r *stream.Range, c *candidate, is *database.IndexStatistics) float64 {
+	n := len(r.Min)
+	if len(r.Max) > n {
+		n = len(r.Max)
+	}
+
+	if r.Exact {
+		// the range selects at most one document
+		if n == len(c.paths) && (!c.isIndex || c.isUnique) && e.stats.RowCount > 0 {
+			return 1 / float64(e.stats.RowCount)
+		}
+
+		if n == 1 && is != nil {
+			if v, ok := literalValue(r.Min[0]); ok {
+				return e.histogramEqualSelectivity(is.Histogram, v)
+			}
+		}
+
+		return e.prefixSelectivity(is, n)
+	}
+
+	sel := e.prefixSelectivity(is, n-1)
+
+	if n == 1 && is != nil {
+		min, max, ok := rangeBounds(r)
+		if ok {
+			return e.histogramRangeSelectivity(is.Histogram, min, max)
+		}
+	}
+
+	return sel * defaultRangeSelectivity
+}
+
+// prefixSelectivity returns the selectivity of an equality on the n first paths of the index.
+func (e *costEstimator) prefixSelectivity(is *database.IndexStatistics, n int) float64 {
+	if n == 0 {
+		return 1
+	}
+
+	if is != nil && n <= len(is.DistinctCount) && is.DistinctCount[n-1] > 0 {
+		return 1 / float64(is.DistinctCount[n-1])
+	}
+
+	return math.Pow(defaultEqualitySelectivity, float64(n))
+}
+
+// histogramEqualSelectivity estimates the selectivity of an equality
+// on the first path of the index, assuming values are uniformly distributed
+// within the bucket that contains v.
+func (e *costEstimator) histogramEqualSelectivity(h database.Histogram, v types.Value) float64 {
+	if e.stats.RowCount == 0 {
+		return 0
+	}
+
+	for _, b := range h {
+		if !valuesAreComparable(b.UpperBound, v) {
+			continue
+		}
+
+		ok, err := types.IsGreaterThanOrEqual(b.UpperBound, v)
+		if err != nil || !ok {
+			continue
+		}
+
+		return float64(b.Count) / float64(b.Distinct) / float64(e.stats.RowCount)
+	}
+
+	return 0
+}
+
+// histogramRangeSelectivity estimates the selectivity of a range on the first path of the index.
+// Buckets entirely within the range are fully counted, buckets that overlap one of the
+// boundaries are counted by half.
+// The values of each bucket are greater than the upper bound of the previous bucket.
+func (e *costEstimator) histogramRangeSelectivity(h database.Histogram, min, max types.Value) float64 {
+	if e.stats.RowCount == 0 {
+		return 0
+	}
+
+	var rows float64
+	var lower types.Value
+	for i, b := range h {
+		if i > 0 {
+			lower = h[i-1].UpperBound
+		}
+
+		bound := min
+		if bound == nil {
+			bound = max
+		}
+		if !valuesAreComparable(b.UpperBound, bound) {
+			continue
+		}
+
+		// bucket entirely below the range
+		if min != nil && isLesserThan(b.UpperBound, min) {
+			continue
+		}
+
+		// bucket entirely above the range
+		if max != nil && lower != nil && valuesAreComparable(lower, max) && !isLesserThan(lower, max) {
+			continue
+		}
+
+		aboveMin := min == nil || (lower != nil && valuesAreComparable(lower, min) && !isLesserThan(lower, min))
+		belowMax := max == nil || !isLesserThan(max, b.UpperBound)
+		if aboveMin && belowMax {
+			rows += float64(b.Count)
+		} else {
+			rows += float64(b.Count) / 2
+		}
+	}
+
+	return rows / float64(e.stats.RowCount)
+}
+
+// sortCost returns the cost of sorting n documents using a temporary tree.
+func sortCost(n float64) float64 {
+	if n <= 0 {
+		return 0
+	}
+
+	return n * (2 + math.Log2(n))
+}
+
+// rangeBounds returns the boundaries of a range on a single path,
+// if they are literal values.
+func rangeBounds(r *stream.Range) (min, max types.Value, ok bool) {
+	if len(r.Min) > 0 {
+		if min, ok = literalValue(r.Min[0]); !ok {
+			return nil, nil, false
+		}
+	}
+
+	if len(r.Max) > 0 {
+		if max, ok = literalValue(r.Max[0]); !ok {
+			return nil, nil, false
+		}
+	}
+
+	return min, max, min != nil || max != nil
+}
+
+func literalValue(e expr.Expr) (types.Value, bool) {
+	lv, ok := e.(expr.LiteralValue)
+	if !ok || lv.Value.Type() == types.NullValue {
+		return nil, false
+	}
+
+	return lv.Value, true
+}
+
+func valuesAreComparable(a, b types.Value) bool {
+	return a.Type() == b.Type() || (a.Type().IsNumber() && b.Type().IsNumber())
+}
+
+func isLesserThan(a, b types.Value) bool {
+	ok, err := types.IsLesserThan(a, b)
+	return err == nil && ok
+}
@@ -0,0 +1,162 @@
+package planner
+
+import (
+	"github.com/genjidb/genji/internal/expr"
+	"github.com/genjidb/genji/internal/stream"
+)
+
+// inlineCommonTableExpr replaces the scan of a common table expression read once,
+// by the FROM clause of the statement, by the stream of the expression.
+// The filters following the scan are moved before the projection of the expression,
+// so that they can be used to select an index of the table it reads:
+//   cte.With(c AS (table.Scan("t") | docs.Project(a AS b)), cte.Scan(c) | docs.Filter(b = 1) | docs.Project(*))
+// becomes:
+//   table.Scan("t") | docs.Filter(a = 1) | docs.Project(a AS b) | docs.Project(*)
+// Only expressions reading a single table, with an optional WHERE clause, are inlined,
+// and only if all the filters following the scan can be moved.
+// The inlined expression is removed from the WITH operator.
+// It returns true if the expression was inlined.
+func inlineCommonTableExpr(w *stream.WithOperator) bool {
+	scan, ok := w.Stream.First().(*stream.CTEScanOperator)
+	if !ok || scan.CTE.Materialized || scan.CTE.Recursive != nil {
+		return false
+	}
+	cte := scan.CTE
+
+	// the expression must read a table, filter its documents and project them
+	if _, ok := cte.Stream.First().(*stream.TableScanOperator); !ok {
+		return false
+	}
+	proj, ok := cte.Stream.Op.(*stream.DocsProjectOperator)
+	if !ok {
+		return false
+	}
+	for n := cte.Stream.First().GetNext(); n != proj; n = n.GetNext() {
+		if _, ok := n.(*stream.DocsFilterOperator); !ok {
+			return false
+		}
+	}
+
+	var filters []*stream.DocsFilterOperator
+	n := scan.GetNext()
+	for ; n != nil; n = n.GetNext() {
+		f, ok := n.(*stream.DocsFilterOperator)
+		if !ok {
+			break
+		}
+		if !canUnprojectExpr(f.Expr, proj) {
+			return false
+		}
+		filters = append(filters, f)
+	}
+
+	// the paths of the other operators would be associated with the table
+	// when selecting an index, while they reference the projected documents
+	for ; n != nil; n = n.GetNext() {
+		switch n.(type) {
+		case *stream.DocsAliasOperator, *stream.DocsTempTreeSortOperator:
+			return false
+		}
+	}
+
+	for _, f := range filters {
+		w.Stream.Remove(f)
+		f.Expr = unprojectExpr(f.Expr, proj)
+		stream.InsertBefore(proj, f)
+	}
+
+	if next := scan.GetNext(); next != nil {
+		scan.SetNext(nil)
+		next.SetPrev(proj)
+		proj.SetNext(next)
+	} else {
+		w.Stream.Op = proj
+	}
+
+	for i, c := range w.CTEs {
+		if c == cte {
+			w.CTEs = append(w.CTEs[:i], w.CTEs[i+1:]...)
+			break
+		}
+	}
+
+	return true
+}
+
+// canUnprojectExpr returns true if e, which is evaluated against the documents
+// returned by p, can be evaluated against the documents read by p instead.
+// The paths of e must reference fields projected from a path, unless p only
+// projects a wildcard, and they can only be operands of operators.
+func canUnprojectExpr(e expr.Expr, p *stream.DocsProjectOperator) bool {
+	ok := true
+
+	expr.Walk(e, func(e expr.Expr) bool {
+		switch t := e.(type) {
+		case expr.Path:
+			_, ok = unprojectPath(t, p)
+		case expr.Operator, expr.Parentheses:
+		default:
+			ok = !exprContainsPath(e)
+			return false
+		}
+
+		return ok
+	})
+
+	return ok
+}
+
+// unprojectExpr replaces the paths of e by the paths of the fields they reference.
+// e must have been checked by canUnprojectExpr.
+func unprojectExpr(e expr.Expr, p *stream.DocsProjectOperator) expr.Expr {
+	switch t := e.(type) {
+	case expr.Path:
+		path, _ := unprojectPath(t, p)
+		return path
+	case expr.Parentheses:
+		t.E = unprojectExpr(t.E, p)
+		return t
+	case expr.Operator:
+		if b, ok := t.(*expr.BetweenOperator); ok {
+			b.X = unprojectExpr(b.X, p)
+		}
+		t.SetLeftHandExpr(unprojectExpr(t.LeftHand(), p))
+		t.SetRightHandExpr(unprojectExpr(t.RightHand(), p))
+		return t
+	}
+
+	return e
+}
+
+// unprojectPath returns the path of the field referenced by path,
+// which references a field of the documents returned by p.
+func unprojectPath(path expr.Path, p *stream.DocsProjectOperator) (expr.Path, bool) {
+	if len(p.Exprs) == 1 {
+		if _, ok := p.Exprs[0].(expr.Wildcard); ok {
+			return path, true
+		}
+	}
+
+	if len(path) == 0 || path[0].FieldName == "" {
+		return nil, false
+	}
+
+	for _, e := range p.Exprs {
+		ne, ok := e.(*expr.NamedExpr)
+		if !ok {
+			return nil, false
+		}
+		if ne.Name() != path[0].FieldName {
+			continue
+		}
+
+		src, ok := ne.Expr.(expr.Path)
+		if !ok {
+			return nil, false
+		}
+
+		return append(append(expr.Path(nil), src...), path[1:]...), true
+	}
+
+	return nil, false
+}
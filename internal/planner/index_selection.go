@@ -2,6 +2,8 @@ package planner
 
 import (
 	"github.com/genjidb/genji/document"
+	errs "github.com/genjidb/genji/errors"
+	"github.com/genjidb/genji/internal/database"
 	"github.com/genjidb/genji/internal/expr"
 	"github.com/genjidb/genji/internal/sql/scanner"
 	"github.com/genjidb/genji/internal/stream"
@@ -57,6 +59,28 @@ import (
 // Because a table can have multiple indexes, we need to establish which of these
 // indexes should be used to run the query, if not all of them.
 // For that we generate a cost for each selected index and return the one with the cheapest cost.
+//
+// If the table was analyzed, the cost is estimated using its statistics:
+// the histogram and the number of distinct values of each index are used to estimate
+// how many documents each candidate reads. Reading from an index also requires fetching
+// every selected document from the table, which can make an index scan more expensive
+// than reading the whole table, for example when the index selects most of the table.
+// In that case, the table.Scan node is kept.
+// Otherwise, the cost is determined using heuristics based on the type of ranges
+// and indexes.
+//
+// Joins
+//
+// When documents are named using the name or the alias of their table, paths can be qualified
+// with that name, i.e. "t.a". If the stream contains joins, only filter nodes located before
+// the first join can be selected and their paths must be qualified, to make sure they reference
+// the table being scanned.
+// The right stream of a join is optimized separately, knowing the names of the tables of the
+// outer stream. Paths qualified with these names are constant for each iteration of the right
+// stream and can be used to read from an index:
+//   SELECT * FROM a JOIN b ON a.x = b.y
+//   table.Scan("a") | docs.Alias(a) | join.NestedLoop(index.Scan("b_y_idx", [{"min": [a.x], "exact": true}]) | docs.Alias(b) | docs.Filter(a.x = b.y))
+// These filter nodes are kept because an index would select the documents matching a NULL value.
 func SelectIndex(sctx *StreamContext) error {
 	// Lookup the seq scan node.
 	// We will assume that at this point
@@ -87,15 +111,100 @@ func SelectIndex(sctx *StreamContext) error {
 		sctx:      sctx,
 	}
 
+	if alias, ok := seq.GetNext().(*stream.DocsAliasOperator); ok {
+		is.name = alias.Name
+	}
+
+	// only the filter nodes located before the first join
+	// can be associated with the table
+	is.filters = sctx.Filters
+	for n := seq.GetNext(); n != nil; n = n.GetNext() {
+		if _, ok := n.(*stream.JoinOperator); ok {
+			is.qualified = true
+			is.filters = nil
+			for _, f := range sctx.Filters {
+				if isBefore(f, n) {
+					is.filters = append(is.filters, f)
+				}
+			}
+			break
+		}
+	}
+
 	return is.selectIndex()
 }
 
+// isBefore returns true if op is located before other in the stream.
+func isBefore(op, other stream.Operator) bool {
+	for n := other.GetPrev(); n != nil; n = n.GetPrev() {
+		if n == op {
+			return true
+		}
+	}
+
+	return false
+}
+
 // indexSelector analyses a stream and generates a plan for each of them that
 // can benefit from using an index.
 // It then compares the cost of each plan and returns the cheapest stream.
 type indexSelector struct {
 	tableScan *stream.TableScanOperator
 	sctx      *StreamContext
+	// filter nodes that can be associated with the table
+	filters []*stream.DocsFilterOperator
+	// name of the documents of the table, if they are named
+	name string
+	// if true, paths must be qualified with the name of the table
+	qualified bool
+}
+
+// tablePath returns the path of the table referenced by p, if any.
+func (i *indexSelector) tablePath(p document.Path) (document.Path, bool) {
+	if i.name != "" && len(p) > 0 && p[0].FieldName == i.name {
+		// a path made of the name only references the whole document
+		if len(p) == 1 {
+			return nil, false
+		}
+
+		return p[1:], true
+	}
+
+	// paths starting with the name of an outer table reference its documents
+	if i.qualified || (len(p) > 0 && i.isOuterName(p[0].FieldName)) {
+		return nil, false
+	}
+
+	return p, true
+}
+
+// isOperand returns true if e can be evaluated before reading the table,
+// i.e. if it doesn't contain any path, apart from the ones referencing
+// the documents of the outer stream, and if its subqueries don't reference the table.
+func (i *indexSelector) isOperand(e expr.Expr) bool {
+	ok := true
+
+	expr.Walk(e, func(e expr.Expr) bool {
+		if p, isPath := e.(expr.Path); isPath {
+			ok = len(p) > 0 && i.isOuterName(p[0].FieldName)
+		}
+		if sq, isSubquery := stream.SubqueryOf(e); isSubquery && i.name != "" {
+			ok = !sq.IsCorrelatedTo(i.name)
+		}
+		return ok
+	})
+
+	return ok
+}
+
+func (i *indexSelector) isOuterName(name string) bool {
+	for _, n := range i.sctx.OuterNames {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (i *indexSelector) selectIndex() error {
@@ -105,7 +214,7 @@ func (i *indexSelector) selectIndex() error {
 	nodes := make(indexableNodes, 0, len(i.sctx.Filters)+1)
 
 	// get all contiguous filter nodes that can be indexed
-	for _, f := range i.sctx.Filters {
+	for _, f := range i.filters {
 		filter := i.isFilterIndexable(f)
 		if filter == nil {
 			continue
@@ -127,9 +236,7 @@ func (i *indexSelector) selectIndex() error {
 		}
 	}
 
-	// select the cheapest plan
-	var selected *candidate
-	var cost int
+	var candidates []*candidate
 
 	// start with the primary key of the table
 	tb, err := i.sctx.Catalog.GetTableInfo(i.tableScan.TableName)
@@ -138,9 +245,9 @@ func (i *indexSelector) selectIndex() error {
 	}
 	pk := tb.GetPrimaryKey()
 	if pk != nil {
-		selected = i.associateIndexWithNodes(tb.TableName, false, false, pk.Paths, nodes)
-		if selected != nil {
-			cost = selected.Cost()
+		c := i.associateIndexWithNodes(tb.TableName, false, false, pk.Paths, nodes)
+		if c != nil {
+			candidates = append(candidates, c)
 		}
 	}
 
@@ -152,24 +259,23 @@ func (i *indexSelector) selectIndex() error {
 			return err
 		}
 
-		candidate := i.associateIndexWithNodes(idxInfo.IndexName, true, idxInfo.Unique, idxInfo.Paths, nodes)
-
-		if candidate == nil {
-			continue
-		}
-
-		if selected == nil {
-			selected = candidate
-			cost = selected.Cost()
-			continue
+		c := i.associateIndexWithNodes(idxInfo.IndexName, true, idxInfo.Unique, idxInfo.Paths, nodes)
+		if c != nil {
+			candidates = append(candidates, c)
 		}
+	}
 
-		c := candidate.Cost()
+	// select the cheapest plan
+	stats, err := i.sctx.Catalog.GetTableStatistics(i.tableScan.TableName)
+	if err != nil && !errs.IsNotFoundError(err) {
+		return err
+	}
 
-		if len(selected.nodes) < len(candidate.nodes) || (len(selected.nodes) == len(candidate.nodes) && c < cost) {
-			cost = c
-			selected = candidate
-		}
+	var selected *candidate
+	if stats != nil {
+		selected = i.selectCheapestCandidate(stats, candidates)
+	} else {
+		selected = selectCandidateUsingHeuristics(candidates)
 	}
 
 	if selected == nil {
@@ -180,6 +286,14 @@ func (i *indexSelector) selectIndex() error {
 	for _, f := range selected.nodes {
 		switch tp := f.node.(type) {
 		case *stream.DocsFilterOperator:
+			// filters using the outer stream or the value of a subquery are kept,
+			// in case the operand evaluates to NULL
+			if exprContainsPath(f.operand) || exprContainsScalarSubquery(f.operand) {
+				if f.orderBy != nil {
+					i.sctx.removeTempTreeNodeNode(f.orderBy.node.(*stream.DocsTempTreeSortOperator))
+				}
+				continue
+			}
 			i.sctx.removeFilterNode(tp)
 			if f.orderBy != nil {
 				i.sctx.removeTempTreeNodeNode(f.orderBy.node.(*stream.DocsTempTreeSortOperator))
@@ -189,6 +303,19 @@ func (i *indexSelector) selectIndex() error {
 		}
 	}
 
+	// the selected root must lock the documents it reads
+	// if the seq scan was expected to do so
+	if i.tableScan.ForUpdate {
+		for _, op := range selected.replaceRootBy {
+			switch t := op.(type) {
+			case *stream.TableScanOperator:
+				t.ForUpdate = true
+			case *stream.IndexScanOperator:
+				t.ForUpdate = true
+			}
+		}
+	}
+
 	// we replace the seq scan node by the selected root
 	s := i.sctx.Stream
 	s.Remove(s.First())
@@ -204,6 +331,55 @@ func (i *indexSelector) selectIndex() error {
 	return nil
 }
 
+// selectCheapestCandidate estimates the cost of each candidate using the statistics of the table
+// and returns the cheapest one, or nil if reading the whole table is cheaper.
+func (i *indexSelector) selectCheapestCandidate(stats *database.TableStatistics, candidates []*candidate) *candidate {
+	e := costEstimator{
+		stats:  stats,
+		sorted: len(i.sctx.TempTreeSorts) > 0,
+	}
+
+	var selected *candidate
+	var cost float64
+	for _, c := range candidates {
+		cc := e.candidateCost(c)
+		if selected == nil || cc < cost || (cc == cost && len(selected.nodes) < len(c.nodes)) {
+			selected = c
+			cost = cc
+		}
+	}
+
+	if selected != nil && e.tableScanCost() < cost {
+		return nil
+	}
+
+	return selected
+}
+
+// selectCandidateUsingHeuristics selects the candidate that is associated with
+// the most nodes, and then the one with the cheapest heuristic cost.
+func selectCandidateUsingHeuristics(candidates []*candidate) *candidate {
+	var selected *candidate
+	var cost int
+
+	for _, c := range candidates {
+		if selected == nil {
+			selected = c
+			cost = selected.Cost()
+			continue
+		}
+
+		cc := c.Cost()
+
+		if len(selected.nodes) < len(c.nodes) || (len(selected.nodes) == len(c.nodes) && cc < cost) {
+			cost = cc
+			selected = c
+		}
+	}
+
+	return selected
+}
+
 func (i *indexSelector) isFilterIndexable(f *stream.DocsFilterOperator) *indexableNode {
 	// only operators can associate this node to an index
 	op, ok := f.Expr.(expr.Operator)
@@ -217,33 +393,74 @@ func (i *indexSelector) isFilterIndexable(f *stream.DocsFilterOperator) *indexab
 	}
 
 	// determine if the operator could benefit from an index
-	ok, path, e := operatorCanUseIndex(op)
+	ok, path, e := i.operatorCanUseIndex(op)
 	if !ok {
 		return nil
 	}
 
+	tok := op.Token()
+	// expr OP path: the operator must be reversed
+	// to compare the path with the expression
+	if _, ok := i.isTablePath(op.RightHand()); ok && tok != scanner.IN && tok != scanner.BETWEEN {
+		tok = reverseComparison(tok)
+	}
+
 	node := indexableNode{
 		node:     f,
 		path:     path,
-		operator: op.Token(),
+		operator: tok,
 		operand:  e,
 	}
 
 	return &node
 }
 
+// reverseComparison returns the operator to use when swapping
+// the operands of a comparison, i.e. 3 < a becomes a > 3.
+func reverseComparison(tok scanner.Token) scanner.Token {
+	switch tok {
+	case scanner.GT:
+		return scanner.LT
+	case scanner.GTE:
+		return scanner.LTE
+	case scanner.LT:
+		return scanner.GT
+	case scanner.LTE:
+		return scanner.GTE
+	}
+
+	return tok
+}
+
 func (i *indexSelector) isTempTreeSortIndexable(n *stream.DocsTempTreeSortOperator) *indexableNode {
-	// only paths can be associated with an index
-	path, ok := n.Expr.(expr.Path)
-	if !ok {
+	// an index can only return documents sorted
+	// in the same direction by all the keys
+	if !n.IsUniform() {
 		return nil
 	}
 
+	// only paths can be associated with an index
+	var paths []document.Path
+	for _, k := range n.Keys {
+		p, ok := k.Expr.(expr.Path)
+		if !ok {
+			return nil
+		}
+
+		path, ok := i.tablePath(document.Path(p))
+		if !ok {
+			return nil
+		}
+
+		paths = append(paths, path)
+	}
+
 	return &indexableNode{
-		node:     n,
-		path:     document.Path(path),
-		desc:     n.Desc,
-		operator: scanner.ORDER,
+		node:      n,
+		path:      paths[0],
+		sortPaths: paths,
+		desc:      n.IsReverse(),
+		operator:  scanner.ORDER,
 	}
 }
 
@@ -267,7 +484,9 @@ func (i *indexSelector) associateIndexWithNodes(treeName string, isIndex bool, i
 
 	var hasIn bool
 	var sorter *indexableNode
-	for _, p := range paths {
+	// true if the results are returned in the order expected by the TempSort node
+	var sorted bool
+	for pos, p := range paths {
 		ns := nodes.getByPath(p)
 		if len(ns) == 0 {
 			break
@@ -276,9 +495,13 @@ func (i *indexSelector) associateIndexWithNodes(treeName string, isIndex bool, i
 		// get the filter node and the TempSort node if any
 		var filter *indexableNode
 		for i, n := range ns {
-			if n.operator == scanner.ORDER && sorter == nil {
-				sorter = ns[i]
-				desc = sorter.desc
+			if n.operator == scanner.ORDER {
+				// the next paths of the tree must be the
+				// other paths the documents are sorted by
+				if sorter == nil && n.isPrefixOf(paths[pos:]) {
+					sorter = ns[i]
+					desc = sorter.desc
+				}
 				continue
 			}
 			if filter == nil {
@@ -294,10 +517,14 @@ func (i *indexSelector) associateIndexWithNodes(treeName string, isIndex bool, i
 			break
 		}
 
+		// the values of a subquery are not returned in the expected order
+		isSubquery := isSubqueryList(filter.operand)
+
 		// if we have both a filter and a TempSort node, we can merge them
-		if filter != nil && sorter != nil {
+		if filter != nil && sorter != nil && !isSubquery {
 			filter.orderBy = sorter
 			sorter = nil
+			sorted = true
 		}
 
 		if filter.operator == scanner.IN {
@@ -311,8 +538,9 @@ func (i *indexSelector) associateIndexWithNodes(treeName string, isIndex bool, i
 			found = append(found, filter)
 		}
 
-		// we must stop at the first operator that is not a IN or a =
-		if filter.operator != scanner.EQ && filter.operator != scanner.IN {
+		// we must stop at the first operator that is not a IN or a =,
+		// or at the first subquery, which can only be the last value of a range
+		if (filter.operator != scanner.EQ && filter.operator != scanner.IN) || isSubquery {
 			break
 		}
 	}
@@ -325,9 +553,12 @@ func (i *indexSelector) associateIndexWithNodes(treeName string, isIndex bool, i
 	if len(found) == 0 {
 		c := candidate{
 			nodes:      []*indexableNode{sorter},
+			treeName:   treeName,
+			paths:      paths,
 			rangesCost: 10_000,
 			isIndex:    isIndex,
 			isUnique:   isUnique,
+			sorts:      true,
 		}
 
 		if !isIndex {
@@ -356,9 +587,12 @@ func (i *indexSelector) associateIndexWithNodes(treeName string, isIndex bool, i
 	}
 
 	// in case we found an orphan sorter node and we need to assign it to the first filter node
-	// for deletion
-	if sorter != nil {
+	// for deletion.
+	// The values of an IN operator are read one range at a time: the documents
+	// are not sorted by the paths that follow it.
+	if sorter != nil && !hasSubquery(found) && !hasIn {
 		found[0].orderBy = sorter
+		sorted = true
 	}
 
 	// in case there is an IN operator in the list, we need to generate multiple ranges.
@@ -373,9 +607,13 @@ func (i *indexSelector) associateIndexWithNodes(treeName string, isIndex bool, i
 
 	c := candidate{
 		nodes:      found,
+		treeName:   treeName,
+		paths:      paths,
+		ranges:     ranges,
 		rangesCost: ranges.Cost(),
 		isIndex:    isIndex,
 		isUnique:   isUnique,
+		sorts:      sorted,
 	}
 
 	if !isIndex {
@@ -415,10 +653,10 @@ func (i *indexSelector) buildRangesFromFilterNodes(paths []document.Path, filter
 
 	for _, f := range filters {
 		var row []expr.Expr
-		if f.operator != scanner.IN {
-			row = []expr.Expr{f.operand}
+		if l, ok := f.operand.(expr.LiteralExprList); ok && f.operator == scanner.IN {
+			row = l
 		} else {
-			row = f.operand.(expr.LiteralExprList)
+			row = []expr.Expr{f.operand}
 		}
 
 		l = append(l, row)
@@ -434,12 +672,32 @@ func (i *indexSelector) buildRangesFromFilterNodes(paths []document.Path, filter
 	var ranges stream.Ranges
 
 	i.walkExpr(l, func(row []expr.Expr) {
-		ranges = append(ranges, i.buildRangeFromOperator(scanner.EQ, paths[:len(row)], row...))
+		rng := i.buildRangeFromOperator(scanner.EQ, paths[:len(row)], row...)
+		// a subquery returns the list of values to match
+		rng.In = isSubqueryList(row[len(row)-1])
+		ranges = append(ranges, rng)
 	})
 
 	return ranges
 }
 
+// hasSubquery returns true if one of the nodes is matched
+// with the values returned by a subquery.
+func hasSubquery(nodes []*indexableNode) bool {
+	for _, n := range nodes {
+		if isSubqueryList(n.operand) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isSubqueryList(e expr.Expr) bool {
+	sq, ok := e.(*stream.SubqueryExpr)
+	return ok && sq.List
+}
+
 func (i *indexSelector) walkExpr(l [][]expr.Expr, fn func(row []expr.Expr)) {
 	curLine := l[0]
 
@@ -545,21 +803,39 @@ type indexableNode struct {
 	// For TempTreeSort nodes
 	// the expression of the node
 	// has been broken into
-	// <path> <direction>
-	// Ex:  ORDER BY a.b[0] ASC
+	// <paths> <direction>
+	// Ex:  ORDER BY a.b[0] ASC, c ASC
 	// Gives:
 	// - path: a.b[0]
+	// - sortPaths: a.b[0], c
 	// - desc: false
-	path     document.Path
-	operator scanner.Token
-	operand  expr.Expr
-	desc     bool
+	path      document.Path
+	sortPaths []document.Path
+	operator  scanner.Token
+	operand   expr.Expr
+	desc      bool
 
 	// merged TempTreeSort node to remove
 	// from the stream
 	orderBy *indexableNode
 }
 
+// isPrefixOf returns true if the paths the TempSort node
+// sorts the documents by are the first of the given paths.
+func (n *indexableNode) isPrefixOf(paths []document.Path) bool {
+	if len(n.sortPaths) > len(paths) {
+		return false
+	}
+
+	for i, p := range n.sortPaths {
+		if !p.IsEqual(paths[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
 type indexableNodes []*indexableNode
 
 // getByPath returns all indexable nodes for the given path.
@@ -584,6 +860,14 @@ type candidate struct {
 	// replace the table.Scan by these nodes
 	replaceRootBy []stream.Operator
 
+	// name of the index or of the table and the list of paths
+	// it is sorted by.
+	treeName string
+	paths    []document.Path
+
+	// ranges read from the tree
+	ranges stream.Ranges
+
 	// cost of the associated ranges
 	rangesCost int
 
@@ -593,6 +877,8 @@ type candidate struct {
 	isIndex bool
 	// if it's an index, does it have a unique constraint
 	isUnique bool
+	// does it return the results in the order expected by the TempSort node
+	sorts bool
 }
 
 func (c *candidate) Cost() int {
@@ -621,22 +907,26 @@ func operatorIsIndexCompatible(op expr.Operator) bool {
 	return false
 }
 
-func operatorCanUseIndex(op expr.Operator) (bool, document.Path, expr.Expr) {
-	lf, leftIsPath := op.LeftHand().(expr.Path)
-	rf, rightIsPath := op.RightHand().(expr.Path)
+func (i *indexSelector) operatorCanUseIndex(op expr.Operator) (bool, document.Path, expr.Expr) {
+	lf, leftIsPath := i.isTablePath(op.LeftHand())
+	rf, rightIsPath := i.isTablePath(op.RightHand())
 
 	// Special case for IN operator: only left operand is valid for index usage
 	// valid:   a IN [1, 2, 3]
 	// invalid: 1 IN a
 	// invalid: a IN (b + 1, 2)
 	if op.Token() == scanner.IN {
-		if leftIsPath && !rightIsPath && !exprContainsPath(op.RightHand()) {
+		if leftIsPath && i.isOperand(op.RightHand()) {
 			rh := op.RightHand()
-			// The IN operator can use indexes only if the right hand side is an expression list.
+			// The IN operator can use indexes only if the right hand side is an expression list
+			// or a subquery which doesn't reference the table.
+			if isSubqueryList(rh) {
+				return true, lf, rh
+			}
 			if _, ok := rh.(expr.LiteralExprList); !ok {
 				return false, nil, nil
 			}
-			return true, document.Path(lf), rh
+			return true, lf, rh
 		}
 
 		return false, nil, nil
@@ -646,27 +936,50 @@ func operatorCanUseIndex(op expr.Operator) (bool, document.Path, expr.Expr) {
 	// we can only use the index if the "x" is a path and "a" and "b" don't contain path expressions.
 	if op.Token() == scanner.BETWEEN {
 		bt := op.(*expr.BetweenOperator)
-		x, xIsPath := bt.X.(expr.Path)
-		if !xIsPath || exprContainsPath(bt.LeftHand()) || exprContainsPath(bt.RightHand()) {
+		x, xIsPath := i.isTablePath(bt.X)
+		if !xIsPath || !i.isOperand(bt.LeftHand()) || !i.isOperand(bt.RightHand()) {
 			return false, nil, nil
 		}
 
-		return true, document.Path(x), expr.LiteralExprList{bt.LeftHand(), bt.RightHand()}
+		return true, x, expr.LiteralExprList{bt.LeftHand(), bt.RightHand()}
 	}
 
 	// path OP expr
-	if leftIsPath && !rightIsPath && !exprContainsPath(op.RightHand()) {
-		return true, document.Path(lf), op.RightHand()
+	if leftIsPath && i.isOperand(op.RightHand()) {
+		return true, lf, op.RightHand()
 	}
 
 	// expr OP path
-	if rightIsPath && !leftIsPath && !exprContainsPath(op.LeftHand()) {
-		return true, document.Path(rf), op.LeftHand()
+	if rightIsPath && i.isOperand(op.LeftHand()) {
+		return true, rf, op.LeftHand()
 	}
 
 	return false, nil, nil
 }
 
+// isTablePath returns the path of the table referenced by e, if e is a path.
+func (i *indexSelector) isTablePath(e expr.Expr) (document.Path, bool) {
+	p, ok := e.(expr.Path)
+	if !ok {
+		return nil, false
+	}
+
+	return i.tablePath(document.Path(p))
+}
+
+func exprContainsScalarSubquery(e expr.Expr) bool {
+	var found bool
+
+	expr.Walk(e, func(e expr.Expr) bool {
+		if sq, ok := e.(*stream.SubqueryExpr); ok && !sq.List {
+			found = true
+		}
+		return !found
+	})
+
+	return found
+}
+
 func exprContainsPath(e expr.Expr) bool {
 	var hasPath bool
 
@@ -0,0 +1,150 @@
+package planner
+
+import (
+	"github.com/genjidb/genji/internal/expr"
+	"github.com/genjidb/genji/internal/stream"
+)
+
+// PushDownJoinFiltersRule moves conditions as close as possible to the
+// table they reference, so that they can be used to read from an index.
+// It only moves conditions whose paths are all qualified with the name of a table.
+// Conditions of the ON clause that reference the joined table are moved to its stream:
+//   table.Scan("a") | docs.Alias(a) | join.NestedLoop(table.Scan("b") | docs.Alias(b), a.x = b.y AND a.z > 1)
+// becomes:
+//   table.Scan("a") | docs.Alias(a) | join.NestedLoop(table.Scan("b") | docs.Alias(b) | docs.Filter(a.x = b.y), a.z > 1)
+// Filter nodes only referencing the first table are moved before the first join.
+// Other filter nodes are moved to the stream of the last table they reference,
+// unless it is joined using a LEFT JOIN, whose unmatched documents must be filtered after being joined.
+func PushDownJoinFiltersRule(sctx *StreamContext) error {
+	var names []string
+	var joins []*stream.JoinOperator
+
+	for n := sctx.Stream.First(); n != nil; n = n.GetNext() {
+		switch t := n.(type) {
+		case *stream.DocsAliasOperator:
+			if names == nil {
+				names = append(names, t.Name)
+			}
+		case *stream.JoinOperator:
+			joins = append(joins, t)
+			names = append(names, streamName(t.Right))
+		}
+	}
+
+	if len(joins) == 0 {
+		return nil
+	}
+
+	for i, j := range joins {
+		if j.On == nil {
+			continue
+		}
+
+		var remaining expr.Expr
+		for _, e := range splitANDExpr(j.On) {
+			if pos, ok := lastTableUsedBy(e, names[:i+2]); ok && pos == i+1 {
+				j.Right.Pipe(stream.DocsFilter(e))
+				continue
+			}
+
+			if remaining == nil {
+				remaining = e
+			} else {
+				remaining = expr.And(remaining, e)
+			}
+		}
+		j.On = remaining
+	}
+
+	filters := append([]*stream.DocsFilterOperator(nil), sctx.Filters...)
+	for _, f := range filters {
+		pos, ok := lastTableUsedBy(f.Expr, names)
+		if !ok {
+			continue
+		}
+
+		if pos == 0 {
+			sctx.Stream.Remove(f)
+			stream.InsertBefore(joins[0], f)
+			continue
+		}
+
+		j := joins[pos-1]
+		if j.Left {
+			continue
+		}
+
+		sctx.removeFilterNode(f)
+		j.Right.Pipe(f)
+	}
+
+	return nil
+}
+
+// lastTableUsedBy returns the position of the last table referenced by e.
+// It returns false if e contains a path that is not qualified with the name of a table.
+func lastTableUsedBy(e expr.Expr, names []string) (int, bool) {
+	var pos int
+	ok := true
+
+	expr.Walk(e, func(e expr.Expr) bool {
+		p, isPath := e.(expr.Path)
+		if !isPath {
+			return true
+		}
+
+		ok = false
+		for i, name := range names {
+			if len(p) > 0 && p[0].FieldName == name {
+				ok = true
+				if i > pos {
+					pos = i
+				}
+				break
+			}
+		}
+
+		return ok
+	})
+
+	return pos, ok
+}
+
+// streamName returns the name given to the documents of a stream.
+func streamName(s *stream.Stream) string {
+	for n := s.First(); n != nil; n = n.GetNext() {
+		if a, ok := n.(*stream.DocsAliasOperator); ok {
+			return a.Name
+		}
+	}
+
+	return ""
+}
+
+// OptimizeJoinsRule optimizes the right stream of each join.
+// The names of the tables of the outer stream are used to select indexes
+// using the values of the outer documents.
+func OptimizeJoinsRule(sctx *StreamContext) error {
+	names := append([]string(nil), sctx.OuterNames...)
+
+	for n := sctx.Stream.First(); n != nil; n = n.GetNext() {
+		switch t := n.(type) {
+		case *stream.DocsAliasOperator:
+			if len(names) == len(sctx.OuterNames) {
+				names = append(names, t.Name)
+			}
+		case *stream.JoinOperator:
+			name := streamName(t.Right)
+
+			s, err := optimize(t.Right, sctx.Catalog, names)
+			if err != nil {
+				return err
+			}
+			t.Right = s
+
+			names = append(names, name)
+		}
+	}
+
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/genjidb/genji/internal/database"
 	"github.com/genjidb/genji/internal/environment"
 	"github.com/genjidb/genji/internal/expr"
+	"github.com/genjidb/genji/internal/expr/functions"
 	"github.com/genjidb/genji/internal/sql/scanner"
 	"github.com/genjidb/genji/internal/stream"
 	"github.com/genjidb/genji/types"
@@ -15,6 +16,7 @@ var optimizerRules = []func(sctx *StreamContext) error{
 	PrecalculateExprRule,
 	RemoveUnnecessaryProjection,
 	RemoveUnnecessaryFilterNodesRule,
+	PushDownJoinFiltersRule,
 	RemoveUnnecessaryTempSortNodesRule,
 	SelectIndex,
 }
@@ -24,33 +26,73 @@ var optimizerRules = []func(sctx *StreamContext) error{
 // Depending on the rule, the tree may be modified in place or
 // replaced by a new one.
 func Optimize(s *stream.Stream, catalog *database.Catalog) (*stream.Stream, error) {
-	if firstNode, ok := s.First().(*stream.ConcatOperator); ok {
-		// If the first operation is a concat, optimize all streams individually.
-		for i, st := range firstNode.Streams {
-			ss, err := Optimize(st, catalog)
+	return optimizeStream(s, catalog, nil)
+}
+
+// optimizeStream optimizes s, which may reference the documents of
+// the outer stream using outerNames, if it is the stream of a subquery.
+func optimizeStream(s *stream.Stream, catalog *database.Catalog, outerNames []string) (*stream.Stream, error) {
+	if firstNode, ok := s.First().(*stream.WithOperator); ok {
+		// If the first operation is a with, inline the expression read by the statement,
+		// if possible, so that it is optimized with the stream of the statement.
+		// The with operator is removed if it has no other expression.
+		if inlineCommonTableExpr(firstNode) && len(firstNode.CTEs) == 0 && s.Op == firstNode {
+			return optimizeStream(firstNode.Stream, catalog, outerNames)
+		}
+
+		// Optimize the streams of the common table expressions
+		// and the stream of the statement individually.
+		for _, cte := range firstNode.CTEs {
+			ss, err := optimizeStream(cte.Stream, catalog, nil)
 			if err != nil {
 				return nil, err
 			}
-			firstNode.Streams[i] = ss
+			cte.Stream = ss
+
+			if cte.Recursive != nil {
+				ss, err = optimizeStream(cte.Recursive, catalog, nil)
+				if err != nil {
+					return nil, err
+				}
+				cte.Recursive = ss
+			}
 		}
 
+		ss, err := optimizeStream(firstNode.Stream, catalog, outerNames)
+		if err != nil {
+			return nil, err
+		}
+		firstNode.Stream = ss
+
 		return s, nil
 	}
 
-	if firstNode, ok := s.First().(*stream.UnionOperator); ok {
-		// If the first operation is a union, optimize all streams individually.
-		for i, st := range firstNode.Streams {
-			ss, err := Optimize(st, catalog)
+	// If the first operation combines multiple streams, optimize all streams individually.
+	var streams []*stream.Stream
+	switch firstNode := s.First().(type) {
+	case *stream.ConcatOperator:
+		streams = firstNode.Streams
+	case *stream.UnionOperator:
+		streams = firstNode.Streams
+	case *stream.IntersectOperator:
+		streams = firstNode.Streams
+	case *stream.ExceptOperator:
+		streams = firstNode.Streams
+	}
+
+	if streams != nil {
+		for i, st := range streams {
+			ss, err := optimizeStream(st, catalog, outerNames)
 			if err != nil {
 				return nil, err
 			}
-			firstNode.Streams[i] = ss
+			streams[i] = ss
 		}
 
 		return s, nil
 	}
 
-	return optimize(s, catalog)
+	return optimize(s, catalog, outerNames)
 }
 
 type StreamContext struct {
@@ -59,6 +101,9 @@ type StreamContext struct {
 	Filters       []*stream.DocsFilterOperator
 	Projections   []*stream.DocsProjectOperator
 	TempTreeSorts []*stream.DocsTempTreeSortOperator
+	// When optimizing the right stream of a join,
+	// names of the tables of the outer stream.
+	OuterNames []string
 }
 
 func NewStreamContext(s *stream.Stream) *StreamContext {
@@ -69,19 +114,37 @@ func NewStreamContext(s *stream.Stream) *StreamContext {
 	n := s.First()
 
 	prevIsFilter := false
+	// documents sorted by a window or a grouping sets operator are not
+	// in the order of the table or of an index anymore
+	windowed := false
+	// filters located after an aggregation, such as the one
+	// of a HAVING clause, filter the groups, not the documents
+	aggregated := false
 
 	for n != nil {
 		switch t := n.(type) {
 		case *stream.DocsFilterOperator:
-			if prevIsFilter || len(sctx.Filters) == 0 {
+			if !aggregated && (prevIsFilter || len(sctx.Filters) == 0) {
 				sctx.Filters = append(sctx.Filters, t)
 				prevIsFilter = true
 			}
 		case *stream.DocsProjectOperator:
 			sctx.Projections = append(sctx.Projections, t)
 			prevIsFilter = false
+		case *stream.DocsWindowOperator:
+			windowed = true
+			prevIsFilter = false
+		case *stream.DocsGroupAggregateOperator:
+			aggregated = true
+			prevIsFilter = false
+		case *stream.DocsGroupingSetsOperator:
+			aggregated = true
+			windowed = true
+			prevIsFilter = false
 		case *stream.DocsTempTreeSortOperator:
-			sctx.TempTreeSorts = append(sctx.TempTreeSorts, t)
+			if !windowed {
+				sctx.TempTreeSorts = append(sctx.TempTreeSorts, t)
+			}
 			prevIsFilter = false
 		}
 
@@ -128,9 +191,10 @@ func (sctx *StreamContext) removeProjectionNode(index int) {
 	sctx.Projections = append(sctx.Projections[:index], sctx.Projections[index+1:]...)
 }
 
-func optimize(s *stream.Stream, catalog *database.Catalog) (*stream.Stream, error) {
+func optimize(s *stream.Stream, catalog *database.Catalog, outerNames []string) (*stream.Stream, error) {
 	sctx := NewStreamContext(s)
 	sctx.Catalog = catalog
+	sctx.OuterNames = outerNames
 
 	for _, rule := range optimizerRules {
 		err := rule(sctx)
@@ -138,10 +202,22 @@ func optimize(s *stream.Stream, catalog *database.Catalog) (*stream.Stream, erro
 			return nil, err
 		}
 		if sctx.Stream == nil || sctx.Stream.Op == nil {
-			break
+			return sctx.Stream, nil
 		}
 	}
 
+	// the right streams of the joins and the streams of the subqueries
+	// are optimized once the rules were applied to the outer stream
+	err := OptimizeJoinsRule(sctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = OptimizeSubqueriesRule(sctx)
+	if err != nil {
+		return nil, err
+	}
+
 	return sctx.Stream, nil
 }
 
@@ -226,7 +302,12 @@ func PrecalculateExprRule(sctx *StreamContext) error {
 				}
 			}
 		case *stream.DocsTempTreeSortOperator:
-			t.Expr, err = precalculateExpr(t.Expr)
+			for i := range t.Keys {
+				t.Keys[i].Expr, err = precalculateExpr(t.Keys[i].Expr)
+				if err != nil {
+					return err
+				}
+			}
 		case *stream.PathsSetOperator:
 			t.Expr, err = precalculateExpr(t.Expr)
 		case *stream.DocsEmitOperator:
@@ -354,11 +435,92 @@ func precalculateExpr(e expr.Expr) (expr.Expr, error) {
 			// we replace this expression with the result of its evaluation
 			return expr.LiteralValue{Value: v}, nil
 		}
+	case *expr.Case:
+		// the operand, conditions and results are all precalculated,
+		// the case is replaced by its result if they are all constant
+		exprs := []*expr.Expr{&t.Operand, &t.Else}
+		for i := range t.Whens {
+			exprs = append(exprs, &t.Whens[i].Cond, &t.Whens[i].Then)
+		}
+
+		literalsOnly := true
+		for _, pe := range exprs {
+			if *pe == nil {
+				continue
+			}
+
+			newExpr, err := precalculateExpr(*pe)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := newExpr.(expr.LiteralValue); !ok {
+				literalsOnly = false
+			}
+			*pe = newExpr
+		}
+
+		if literalsOnly {
+			return precalculatedValue(t)
+		}
+	case *functions.Coalesce:
+		return precalculateFunction(t, t.Exprs)
+	case *functions.IfNull:
+		return precalculateFunction(t, t.Exprs)
+	case *functions.NullIf:
+		return precalculateFunction(t, t.Exprs)
+	case *functions.Greatest:
+		return precalculateFunction(t, t.Exprs)
+	case *functions.Least:
+		return precalculateFunction(t, t.Exprs)
+	case *functions.RegexpReplace:
+		return precalculateFunction(t, t.Exprs)
+	case *functions.RegexpExtract:
+		return precalculateFunction(t, t.Exprs)
 	}
 
 	return e, nil
 }
 
+// precalculateFunction precalculates the parameters of a function whose result only depends on them,
+// and replaces the function by its result if they are all constant.
+func precalculateFunction(fn expr.Function, params []expr.Expr) (expr.Expr, error) {
+	literalsOnly, err := precalculateParams(params)
+	if err != nil || !literalsOnly {
+		return fn, err
+	}
+
+	return precalculatedValue(fn)
+}
+
+// precalculateParams replaces each expression of the list by its precalculated version,
+// and returns true if they are all constant.
+func precalculateParams(params []expr.Expr) (bool, error) {
+	literalsOnly := true
+
+	for i, p := range params {
+		newExpr, err := precalculateExpr(p)
+		if err != nil {
+			return false, err
+		}
+		if _, ok := newExpr.(expr.LiteralValue); !ok {
+			literalsOnly = false
+		}
+		params[i] = newExpr
+	}
+
+	return literalsOnly, nil
+}
+
+// precalculatedValue evaluates a constant expression.
+func precalculatedValue(e expr.Expr) (expr.Expr, error) {
+	v, err := e.Eval(&environment.Environment{})
+	if err != nil {
+		return nil, err
+	}
+
+	return expr.LiteralValue{Value: v}, nil
+}
+
 // RemoveUnnecessaryFilterNodesRule removes any filter node whose
 // condition is a constant expression that evaluates to a truthy value.
 // if it evaluates to a falsy value, it considers that the tree
@@ -406,7 +568,9 @@ func RemoveUnnecessaryFilterNodesRule(sctx *StreamContext) error {
 // RemoveUnnecessaryProjection removes any project node whose
 // expression is a wildcard only.
 func RemoveUnnecessaryProjection(sctx *StreamContext) error {
-	for i, p := range sctx.Projections {
+	// iterate backwards, removed nodes are removed from the list
+	for i := len(sctx.Projections) - 1; i >= 0; i-- {
+		p := sctx.Projections[i]
 		if len(p.Exprs) == 1 {
 			if _, ok := p.Exprs[0].(expr.Wildcard); ok {
 				sctx.removeProjectionNode(i)
@@ -422,7 +586,10 @@ func RemoveUnnecessaryProjection(sctx *StreamContext) error {
 // In the following case, we can remove the second TempSort node.
 // 		SELECT * FROM foo GROUP BY a ORDER BY a
 //		table.Scan('foo') | docs.TempSort(a) | docs.GroupBy(a) | docs.TempSort(a)
-// This only works if both temp sort nodes use the same path
+// This only works if the paths of the second node are the
+// first paths of the first one:
+// 		SELECT * FROM foo GROUP BY a, b ORDER BY a DESC
+//		table.Scan('foo') | docs.TempSort(a DESC, b) | docs.GroupBy(a, b)
 func RemoveUnnecessaryTempSortNodesRule(sctx *StreamContext) error {
 	if len(sctx.TempTreeSorts) > 2 {
 		panic("unexpected number of TempSort nodes")
@@ -432,23 +599,35 @@ func RemoveUnnecessaryTempSortNodesRule(sctx *StreamContext) error {
 		return nil
 	}
 
-	lpath, ok := sctx.TempTreeSorts[0].Expr.(expr.Path)
-	if !ok {
-		return nil
-	}
+	lkeys := sctx.TempTreeSorts[0].Keys
+	rkeys := sctx.TempTreeSorts[1].Keys
 
-	rpath, ok := sctx.TempTreeSorts[1].Expr.(expr.Path)
-	if !ok {
+	if len(rkeys) > len(lkeys) {
 		return nil
 	}
 
-	if !lpath.IsEqual(rpath) {
-		return nil
+	for i := range rkeys {
+		lpath, ok := lkeys[i].Expr.(expr.Path)
+		if !ok {
+			return nil
+		}
+
+		rpath, ok := rkeys[i].Expr.(expr.Path)
+		if !ok {
+			return nil
+		}
+
+		if !lpath.IsEqual(rpath) {
+			return nil
+		}
 	}
 
 	// we remove the rightmost one
 	// and we override the direction of the first one
-	sctx.TempTreeSorts[0].Desc = sctx.TempTreeSorts[1].Desc
+	for i := range rkeys {
+		lkeys[i].Desc = rkeys[i].Desc
+		lkeys[i].NullsLast = rkeys[i].NullsLast
+	}
 	sctx.removeTempTreeNodeNode(sctx.TempTreeSorts[1])
 
 	return nil
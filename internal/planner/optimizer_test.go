@@ -161,6 +161,31 @@ func TestPrecalculateExprRule(t *testing.T) {
 				Add("b", types.NewDoubleValue(-39)),
 			)},
 		},
+		{
+			"constant case: CASE WHEN 1 > 2 THEN 'a' ELSE 'b' END -> 'b'",
+			parser.MustParseExpr("CASE WHEN 1 > 2 THEN 'a' ELSE 'b' END"),
+			testutil.TextValue("b"),
+		},
+		{
+			"non-constant case: CASE a WHEN 1 + 1 THEN 'a' END -> CASE a WHEN 2 THEN 'a' END",
+			parser.MustParseExpr("CASE a WHEN 1 + 1 THEN 'a' END"),
+			parser.MustParseExpr("CASE a WHEN 2 THEN 'a' END"),
+		},
+		{
+			"constant conditional function: COALESCE(NULL, 1 + 1) -> 2",
+			parser.MustParseExpr("COALESCE(NULL, 1 + 1)"),
+			testutil.IntegerValue(2),
+		},
+		{
+			"non-constant conditional function: GREATEST(a, 1 + 1) -> GREATEST(a, 2)",
+			parser.MustParseExpr("GREATEST(a, 1 + 1)"),
+			parser.MustParseExpr("GREATEST(a, 2)"),
+		},
+		{
+			"conditional function in operator: a > IFNULL(NULL, 3) -> a > 3",
+			parser.MustParseExpr("a > IFNULL(NULL, 3)"),
+			parser.MustParseExpr("a > 3"),
+		},
 	}
 
 	for _, test := range tests {
@@ -872,4 +897,44 @@ func TestOptimize(t *testing.T) {
 		assert.NoError(t, err)
 		require.Equal(t, want.String(), got.String())
 	})
+
+	t.Run("subqueries", func(t *testing.T) {
+		db, tx, cleanup := testutil.NewTestTx(t)
+		defer cleanup()
+		testutil.MustExec(t, db, tx, `
+				CREATE TABLE a(id INT PRIMARY KEY);
+				CREATE TABLE b(id INT PRIMARY KEY, aid INT);
+				CREATE INDEX b_aid ON b(aid);
+			`)
+
+		correlated := st.Exists(
+			st.New(st.TableScan("b")).
+				Pipe(st.DocsAlias("b")).
+				Pipe(st.DocsFilter(parser.MustParseExpr("b.aid = a.id"))).
+				Pipe(st.DocsProject(testutil.ParseNamedExpr(t, "1"))),
+			"a",
+		)
+		uncorrelated := st.Subquery(
+			st.New(st.TableScan("b")).
+				Pipe(st.DocsFilter(parser.MustParseExpr("aid = 1"))).
+				Pipe(st.DocsProject(testutil.ParseNamedExpr(t, "id"))),
+			"aid", "id",
+		)
+
+		got, err := planner.Optimize(
+			st.New(st.TableScan("a")).
+				Pipe(st.DocsAlias("a")).
+				Pipe(st.DocsFilter(correlated)).
+				Pipe(st.DocsProject(&expr.NamedExpr{ExprName: "x", Expr: uncorrelated})),
+			db.Catalog)
+		assert.NoError(t, err)
+
+		require.Equal(t, `index.Scan("b_aid", [{"min": [a.id], "exact": true}]) | docs.Alias(b) | docs.Filter(b.aid = a.id) | docs.Project(1)`, correlated.Stream.String())
+		require.Equal(t, `index.Scan("b_aid", [{"min": [1], "exact": true}]) | docs.Project(id)`, uncorrelated.Stream.String())
+
+		// only the uncorrelated subquery is evaluated once per execution
+		require.False(t, correlated.Cached)
+		require.True(t, uncorrelated.Cached)
+		require.Equal(t, []expr.Expr{uncorrelated}, got.Subqueries)
+	})
 }
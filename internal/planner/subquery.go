@@ -0,0 +1,83 @@
+package planner
+
+import (
+	"github.com/genjidb/genji/internal/expr"
+	"github.com/genjidb/genji/internal/stream"
+)
+
+// OptimizeSubqueriesRule optimizes the stream of each subquery used by the stream.
+// The names of the tables of the stream are used to select indexes
+// using the values of the documents referenced by correlated subqueries:
+//   table.Scan("a") | docs.Alias(a) | docs.Filter(EXISTS (table.Scan("b") | docs.Alias(b) | docs.Filter(b.aid = a.id)))
+// becomes:
+//   table.Scan("a") | docs.Alias(a) | docs.Filter(EXISTS (index.Scan("b_aid", [{"min": [a.id], "exact": true}]) | docs.Alias(b) | docs.Filter(b.aid = a.id)))
+// Uncorrelated subqueries are cached and added to the subqueries of the stream,
+// which evaluates them before returning any document.
+func OptimizeSubqueriesRule(sctx *StreamContext) error {
+	names := append([]string(nil), sctx.OuterNames...)
+	var exprs []expr.Expr
+
+	for n := sctx.Stream.First(); n != nil; n = n.GetNext() {
+		switch t := n.(type) {
+		case *stream.DocsAliasOperator:
+			names = append(names, t.Name)
+		case *stream.JoinOperator:
+			names = append(names, streamName(t.Right))
+			exprs = append(exprs, t.On)
+		case *stream.DocsFilterOperator:
+			exprs = append(exprs, t.Expr)
+		case *stream.DocsProjectOperator:
+			exprs = append(exprs, t.Exprs...)
+		case *stream.DocsTempTreeSortOperator:
+			for _, k := range t.Keys {
+				exprs = append(exprs, k.Expr)
+			}
+		case *stream.PathsSetOperator:
+			exprs = append(exprs, t.Expr)
+		case *stream.TableScanOperator:
+			exprs = appendRangesExprs(exprs, t.Ranges)
+		case *stream.IndexScanOperator:
+			exprs = appendRangesExprs(exprs, t.Ranges)
+		}
+	}
+
+	// the same subquery may be used by a filter and by the range of a scan
+	optimized := make(map[*stream.SubqueryExpr]bool)
+
+	var err error
+	for _, e := range exprs {
+		expr.Walk(e, func(e expr.Expr) bool {
+			sq, ok := stream.SubqueryOf(e)
+			if !ok || optimized[sq] {
+				return true
+			}
+			optimized[sq] = true
+
+			sq.Stream, err = optimizeStream(sq.Stream, sctx.Catalog, names)
+			if err != nil {
+				return false
+			}
+
+			// subqueries that don't reference the documents of the stream
+			// or of the outer streams return the same value for every document
+			if !sq.IsCorrelatedTo(names...) {
+				sq.Cached = true
+				sctx.Stream.Subqueries = append(sctx.Stream.Subqueries, e)
+			}
+			return true
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func appendRangesExprs(exprs []expr.Expr, ranges stream.Ranges) []expr.Expr {
+	for _, rng := range ranges {
+		exprs = append(exprs, rng.Min, rng.Max)
+	}
+
+	return exprs
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/genjidb/genji/internal/database"
 	"github.com/genjidb/genji/internal/environment"
 	"github.com/genjidb/genji/internal/query/statement"
+	"github.com/genjidb/genji/internal/stream"
 	"github.com/genjidb/genji/types"
 )
 
@@ -74,7 +75,7 @@ func (q *Query) Prepare(context *Context) error {
 		stmt, err := p.Prepare(&statement.Context{
 			DB:      context.DB,
 			Tx:      tx,
-			Catalog: context.DB.Catalog,
+			Catalog: tx.Catalog,
 		})
 		if err != nil {
 			return err
@@ -117,6 +118,7 @@ func (q Query) Run(context *Context) (*statement.Result, error) {
 				return nil, err
 			}
 
+			context.DB.Metrics.RecordStatement(statementType(stmt))
 			continue
 		}
 
@@ -132,7 +134,7 @@ func (q Query) Run(context *Context) (*statement.Result, error) {
 		res, err = stmt.Run(&statement.Context{
 			DB:      context.DB,
 			Tx:      q.tx,
-			Catalog: context.DB.Catalog,
+			Catalog: q.tx.Catalog,
 			Params:  context.Params,
 		})
 		if err != nil {
@@ -157,6 +159,16 @@ func (q Query) Run(context *Context) (*statement.Result, error) {
 			}
 		}
 
+		// only statements that ran successfully are counted.
+		// the result of the last one is iterated by the caller,
+		// which can still fail: it is counted once the result is closed.
+		typ := statementType(stmt)
+		if i+1 < len(q.Statements) {
+			context.DB.Metrics.RecordStatement(typ)
+		} else {
+			res.OnSuccess = func() { context.DB.Metrics.RecordStatement(typ) }
+		}
+
 		// it there is an opened transaction but there are still statements
 		// to be executed, close the current transaction.
 		if q.tx != nil && q.autoCommit && i+1 < len(q.Statements) {
@@ -187,3 +199,71 @@ func (q Query) Run(context *Context) (*statement.Result, error) {
 type queryAlterer interface {
 	alterQuery(ctx context.Context, db *database.Database, q *Query) error
 }
+
+// statementType returns the type of the statement, used to count
+// the statements executed by the database.
+func statementType(stmt statement.Statement) string {
+	switch t := stmt.(type) {
+	case *statement.PreparedStreamStmt:
+		return streamType(t.Stream)
+	case *statement.SelectStmt:
+		return "SELECT"
+	case *statement.InsertStmt:
+		return "INSERT"
+	case *statement.UpdateStmt:
+		return "UPDATE"
+	case *statement.DeleteStmt:
+		return "DELETE"
+	case *statement.CreateTableStmt, *statement.CreateIndexStmt, *statement.CreateSequenceStmt:
+		return "CREATE"
+	case statement.DropTableStmt, statement.DropIndexStmt, statement.DropSequenceStmt:
+		return "DROP"
+	case statement.AlterStmt, statement.AlterTableAddField:
+		return "ALTER"
+	case *statement.ReIndexStmt:
+		return "REINDEX"
+	case statement.AnalyzeStmt:
+		return "ANALYZE"
+	case statement.VacuumStmt:
+		return "VACUUM"
+	case *statement.ExplainStmt:
+		return "EXPLAIN"
+	case BeginStmt:
+		return "BEGIN"
+	case CommitStmt:
+		return "COMMIT"
+	case RollbackStmt:
+		return "ROLLBACK"
+	case SavepointStmt:
+		return "SAVEPOINT"
+	case ReleaseStmt:
+		return "RELEASE"
+	}
+
+	return "OTHER"
+}
+
+// streamType returns the type of the statement a prepared stream was built from,
+// based on the operators that modify tables.
+func streamType(s *stream.Stream) string {
+	var reindex bool
+
+	for op := s.First(); op != nil; op = op.GetNext() {
+		switch op.(type) {
+		case *stream.TableInsertOperator:
+			return "INSERT"
+		case *stream.TableReplaceOperator:
+			return "UPDATE"
+		case *stream.TableDeleteOperator:
+			return "DELETE"
+		case *stream.IndexInsertOperator:
+			reindex = true
+		}
+	}
+
+	if reindex {
+		return "REINDEX"
+	}
+
+	return "SELECT"
+}
@@ -0,0 +1,49 @@
+package statement
+
+import (
+	"strings"
+
+	"github.com/genjidb/genji/internal/database"
+)
+
+// AnalyzeStmt is a DSL that allows creating an ANALYZE statement.
+type AnalyzeStmt struct {
+	// Name of the table to analyze. If empty, every table is analyzed.
+	TableName string
+}
+
+// IsReadOnly always returns false. It implements the Statement interface.
+func (stmt AnalyzeStmt) IsReadOnly() bool {
+	return false
+}
+
+// Run computes the statistics of the table, or of every table if no table name was provided,
+// and stores them in the catalog.
+// It implements the Statement interface.
+func (stmt AnalyzeStmt) Run(ctx *Context) (Result, error) {
+	var res Result
+
+	tableNames := []string{stmt.TableName}
+	if stmt.TableName == "" {
+		tableNames = tableNames[:0]
+		for _, name := range ctx.Catalog.Cache.ListObjects(database.RelationTableType) {
+			if !strings.HasPrefix(name, database.InternalPrefix) {
+				tableNames = append(tableNames, name)
+			}
+		}
+	}
+
+	for _, name := range tableNames {
+		stats, err := database.AnalyzeTable(ctx.Tx, ctx.Catalog, name, ctx.DB.AnalyzeSampleSize)
+		if err != nil {
+			return res, err
+		}
+
+		err = ctx.Catalog.SetTableStatistics(ctx.Tx, stats)
+		if err != nil {
+			return res, err
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,179 @@
+package statement_test
+
+import (
+	"testing"
+
+	errs "github.com/genjidb/genji/errors"
+	"github.com/genjidb/genji/internal/database"
+	"github.com/genjidb/genji/internal/database/catalogstore"
+	"github.com/genjidb/genji/internal/environment"
+	"github.com/genjidb/genji/internal/testutil"
+	"github.com/genjidb/genji/internal/testutil/assert"
+	"github.com/genjidb/genji/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyze(t *testing.T) {
+	db, tx, cleanup := testutil.NewTestTx(t)
+	defer cleanup()
+
+	testutil.MustExec(t, db, tx, `
+		CREATE TABLE test(a INT, b INT, c TEXT);
+		CREATE INDEX test_a ON test(a);
+		CREATE INDEX test_b_c ON test(b, c);
+		CREATE TABLE other;
+	`)
+
+	for i := 0; i < 1000; i++ {
+		testutil.MustExec(t, db, tx, `INSERT INTO test (a, b, c) VALUES (?, ?, ?)`,
+			environment.Param{Value: i}, environment.Param{Value: i % 10}, environment.Param{Value: string(rune('a' + i%3))})
+	}
+
+	t.Run("Unknown table", func(t *testing.T) {
+		err := testutil.Exec(db, tx, "ANALYZE unknown")
+		assert.Error(t, err)
+	})
+
+	t.Run("Not analyzed", func(t *testing.T) {
+		_, err := db.Catalog.GetTableStatistics("test")
+		require.True(t, errs.IsNotFoundError(err))
+	})
+
+	testutil.MustExec(t, db, tx, "ANALYZE")
+
+	t.Run("Not committed", func(t *testing.T) {
+		_, err := db.Catalog.GetTableStatistics("test")
+		require.True(t, errs.IsNotFoundError(err))
+	})
+
+	err := tx.Commit()
+	assert.NoError(t, err)
+
+	tx, err = db.Begin(true)
+	assert.NoError(t, err)
+	defer tx.Rollback()
+
+	t.Run("Table", func(t *testing.T) {
+		ts, err := db.Catalog.GetTableStatistics("test")
+		assert.NoError(t, err)
+		require.Equal(t, int64(1000), ts.RowCount)
+		require.Len(t, ts.Indexes, 2)
+
+		ts, err = db.Catalog.GetTableStatistics("other")
+		assert.NoError(t, err)
+		require.Equal(t, int64(0), ts.RowCount)
+	})
+
+	t.Run("Unique values", func(t *testing.T) {
+		is, err := db.Catalog.GetIndexStatistics("test_a")
+		assert.NoError(t, err)
+		require.Equal(t, []int64{1000}, is.DistinctCount)
+		require.Len(t, is.Histogram, database.DefaultHistogramBuckets)
+
+		var total int64
+		for _, b := range is.Histogram {
+			require.Equal(t, int64(10), b.Count)
+			require.Equal(t, int64(10), b.Distinct)
+			total += b.Count
+		}
+		require.Equal(t, int64(1000), total)
+		require.Equal(t, types.NewIntegerValue(999), is.Histogram[len(is.Histogram)-1].UpperBound)
+	})
+
+	t.Run("Composite", func(t *testing.T) {
+		is, err := db.Catalog.GetIndexStatistics("test_b_c")
+		assert.NoError(t, err)
+		require.Equal(t, []int64{10, 30}, is.DistinctCount)
+
+		// identical values are never split across buckets
+		require.Len(t, is.Histogram, 10)
+		for i, b := range is.Histogram {
+			require.Equal(t, int64(100), b.Count)
+			require.Equal(t, int64(1), b.Distinct)
+			require.Equal(t, types.NewIntegerValue(int64(i)), b.UpperBound)
+		}
+	})
+
+	t.Run("Persistence", func(t *testing.T) {
+		c := database.NewCatalog()
+		err := catalogstore.LoadCatalog(tx, c)
+		assert.NoError(t, err)
+
+		ts, err := c.GetTableStatistics("test")
+		assert.NoError(t, err)
+		expected, err := db.Catalog.GetTableStatistics("test")
+		assert.NoError(t, err)
+		require.Equal(t, expected, ts)
+	})
+
+	t.Run("Sample", func(t *testing.T) {
+		ts, err := database.AnalyzeTable(tx, db.Catalog, "test", 100)
+		assert.NoError(t, err)
+		require.Equal(t, int64(1000), ts.RowCount)
+
+		// every sampled value is unique: the index is assumed to only contain unique values
+		is := ts.Indexes["test_a"]
+		require.Equal(t, []int64{1000}, is.DistinctCount)
+		require.Len(t, is.Histogram, 100)
+		var total int64
+		for _, b := range is.Histogram {
+			total += b.Count
+		}
+		require.Equal(t, int64(1000), total)
+
+		is = ts.Indexes["test_b_c"]
+		require.InDelta(t, 10, is.DistinctCount[0], 1)
+		require.InDelta(t, 30, is.DistinctCount[1], 10)
+
+		_, err = database.AnalyzeTable(tx, db.Catalog, "test", 0)
+		assert.Error(t, err)
+	})
+
+	drop := `
+		DROP INDEX test_a;
+		ALTER TABLE test RENAME TO test2;
+		DROP TABLE other;
+	`
+
+	t.Run("Rollback", func(t *testing.T) {
+		testutil.MustExec(t, db, tx, drop)
+
+		// the statistics are only modified once the transaction is committed
+		ts, err := db.Catalog.GetTableStatistics("test")
+		assert.NoError(t, err)
+		require.Contains(t, ts.Indexes, "test_a")
+		_, err = db.Catalog.GetTableStatistics("test2")
+		require.True(t, errs.IsNotFoundError(err))
+
+		err = tx.Rollback()
+		assert.NoError(t, err)
+
+		_, err = db.Catalog.GetTableStatistics("test2")
+		require.True(t, errs.IsNotFoundError(err))
+		_, err = db.Catalog.GetTableStatistics("test")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Drop and rename", func(t *testing.T) {
+		tx, err := db.Begin(true)
+		assert.NoError(t, err)
+		defer tx.Rollback()
+
+		testutil.MustExec(t, db, tx, drop)
+		err = tx.Commit()
+		assert.NoError(t, err)
+
+		_, err = db.Catalog.GetIndexStatistics("test_a")
+		require.True(t, errs.IsNotFoundError(err))
+		_, err = db.Catalog.GetTableStatistics("test")
+		require.True(t, errs.IsNotFoundError(err))
+		_, err = db.Catalog.GetTableStatistics("other")
+		require.True(t, errs.IsNotFoundError(err))
+
+		ts, err := db.Catalog.GetTableStatistics("test2")
+		assert.NoError(t, err)
+		require.Equal(t, "test2", ts.TableName)
+		require.Len(t, ts.Indexes, 1)
+		require.Contains(t, ts.Indexes, "test_b_c")
+	})
+}
@@ -0,0 +1,130 @@
+package statement
+
+import (
+	"github.com/cockroachdb/errors"
+	"github.com/genjidb/genji/internal/expr"
+	"github.com/genjidb/genji/internal/sql/scanner"
+	"github.com/genjidb/genji/internal/stream"
+)
+
+// DefineCommonTableExpr sets the streams of cte, whose documents are returned by the statement.
+// If fields is not empty, the fields selected by the statement are renamed using these names.
+// refs is the number of times the statement references cte, including in its subqueries.
+// A statement referencing cte defines a recursive expression: it must be a compound statement
+// whose last SELECT references cte once, in its FROM clause. The documents returned by the other
+// SELECT statements are the initial documents of the expression, and the last one is evaluated
+// repeatedly, reading the documents returned by its previous evaluation. Its fields are named
+// after the ones of the first SELECT.
+func (stmt *SelectStmt) DefineCommonTableExpr(cte *stream.CommonTableExpr, fields []string, refs int) error {
+	if len(fields) > 0 {
+		for _, core := range stmt.CompoundSelect {
+			err := core.renameFields(fields)
+			if err != nil {
+				return errors.Wrapf(err, "common table expression %q", cte.Name)
+			}
+		}
+	}
+
+	if refs == 0 {
+		st, err := stmt.toStream(nil)
+		if err != nil {
+			return err
+		}
+		if !st.ReadOnly {
+			return errors.Errorf("common table expression %q must be read-only", cte.Name)
+		}
+
+		cte.Stream = st.Stream
+		return nil
+	}
+
+	n := len(stmt.CompoundSelect)
+	if n == 1 || refs != 1 || stmt.CompoundSelect[n-1].countReads(cte) != 1 || stmt.CompoundOperators[n-2].Token != scanner.UNION {
+		return errors.Errorf("recursive common table expression %q must be referenced once, by the FROM clause of the last SELECT of a UNION", cte.Name)
+	}
+
+	if len(stmt.OrderBy) > 0 || stmt.LimitExpr != nil || stmt.OffsetExpr != nil {
+		return errors.Errorf("ORDER BY, LIMIT and OFFSET are not supported by recursive common table expression %q", cte.Name)
+	}
+
+	last := stmt.CompoundSelect[n-1]
+	if names, ok := stmt.CompoundSelect[0].fieldNames(); ok && len(fields) == 0 {
+		if _, ok := last.fieldNames(); ok && len(names) == len(last.ProjectionExprs) {
+			_ = last.renameFields(names)
+		}
+	}
+
+	initial := SelectStmt{
+		CompoundSelect:    stmt.CompoundSelect[:n-1],
+		CompoundOperators: stmt.CompoundOperators[:n-2],
+	}
+
+	st, err := initial.toStream(nil)
+	if err != nil {
+		return err
+	}
+
+	recursive, err := last.Prepare(nil)
+	if err != nil {
+		return err
+	}
+
+	if !st.ReadOnly || !recursive.ReadOnly {
+		return errors.Errorf("common table expression %q must be read-only", cte.Name)
+	}
+
+	cte.Stream = st.Stream
+	cte.Recursive = recursive.Stream
+	cte.Distinct = !stmt.CompoundOperators[n-2].All
+	return nil
+}
+
+// countReads returns the number of times the FROM clause of the statement reads cte.
+func (stmt *SelectCoreStmt) countReads(cte *stream.CommonTableExpr) int {
+	var n int
+
+	if stmt.CTE == cte {
+		n++
+	}
+
+	for _, j := range stmt.Joins {
+		if j.CTE == cte {
+			n++
+		}
+	}
+
+	return n
+}
+
+// fieldNames returns the names of the fields selected by the statement,
+// or false if they are selected using a wildcard.
+func (stmt *SelectCoreStmt) fieldNames() ([]string, bool) {
+	names := make([]string, 0, len(stmt.ProjectionExprs))
+
+	for _, e := range stmt.ProjectionExprs {
+		ne, ok := e.(*expr.NamedExpr)
+		if !ok {
+			return nil, false
+		}
+		names = append(names, ne.ExprName)
+	}
+
+	return names, true
+}
+
+// renameFields renames the fields selected by the statement.
+func (stmt *SelectCoreStmt) renameFields(names []string) error {
+	if _, ok := stmt.fieldNames(); !ok {
+		return errors.New("cannot rename the fields selected using a wildcard")
+	}
+
+	if len(names) != len(stmt.ProjectionExprs) {
+		return errors.Errorf("%d fields selected but %d names specified", len(stmt.ProjectionExprs), len(names))
+	}
+
+	for i, e := range stmt.ProjectionExprs {
+		e.(*expr.NamedExpr).ExprName = names[i]
+	}
+
+	return nil
+}
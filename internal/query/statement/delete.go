@@ -6,7 +6,6 @@ import (
 	"github.com/genjidb/genji/document"
 	"github.com/genjidb/genji/internal/environment"
 	"github.com/genjidb/genji/internal/expr"
-	"github.com/genjidb/genji/internal/sql/scanner"
 	"github.com/genjidb/genji/internal/stream"
 )
 
@@ -14,12 +13,11 @@ import (
 type DeleteStmt struct {
 	basePreparedStatement
 
-	TableName        string
-	WhereExpr        expr.Expr
-	OffsetExpr       expr.Expr
-	OrderBy          expr.Path
-	LimitExpr        expr.Expr
-	OrderByDirection scanner.Token
+	TableName  string
+	WhereExpr  expr.Expr
+	OffsetExpr expr.Expr
+	OrderBy    []stream.SortKey
+	LimitExpr  expr.Expr
 }
 
 func NewDeleteStatement() *DeleteStmt {
@@ -34,18 +32,27 @@ func NewDeleteStatement() *DeleteStmt {
 }
 
 func (stmt *DeleteStmt) Prepare(c *Context) (Statement, error) {
-	s := stream.New(stream.TableScan(stmt.TableName))
+	// documents are locked before being read to prevent concurrent transactions
+	// from modifying them between the evaluation of the WHERE clause and the write
+	scan := stream.TableScan(stmt.TableName)
+	scan.ForUpdate = true
+	s := stream.New(scan)
+
+	// documents must be named to be referenced by correlated subqueries
+	exprs := []expr.Expr{stmt.WhereExpr}
+	for _, k := range stmt.OrderBy {
+		exprs = append(exprs, k.Expr)
+	}
+	if isReferencedBySubqueries(stmt.TableName, exprs...) {
+		s = s.Pipe(stream.DocsAlias(stmt.TableName))
+	}
 
 	if stmt.WhereExpr != nil {
 		s = s.Pipe(stream.DocsFilter(stmt.WhereExpr))
 	}
 
-	if stmt.OrderBy != nil {
-		if stmt.OrderByDirection == scanner.DESC {
-			s = s.Pipe(stream.DocsTempTreeSortReverse(stmt.OrderBy))
-		} else {
-			s = s.Pipe(stream.DocsTempTreeSort(stmt.OrderBy))
-		}
+	if len(stmt.OrderBy) > 0 {
+		s = s.Pipe(stream.DocsTempTreeSortKeys(stmt.OrderBy...))
 	}
 
 	if stmt.OffsetExpr != nil {
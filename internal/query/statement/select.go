@@ -2,76 +2,352 @@ package statement
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/cockroachdb/errors"
 	"github.com/genjidb/genji/document"
+	"github.com/genjidb/genji/internal/database"
 	"github.com/genjidb/genji/internal/environment"
 	"github.com/genjidb/genji/internal/expr"
 	"github.com/genjidb/genji/internal/sql/scanner"
 	"github.com/genjidb/genji/internal/stream"
+	"github.com/genjidb/genji/internal/stringutil"
 )
 
 type SelectCoreStmt struct {
-	TableName       string
-	Distinct        bool
-	WhereExpr       expr.Expr
-	GroupByExpr     expr.Expr
+	TableName    string
+	TableAlias   string
+	Joins        []*JoinClause
+	Distinct     bool
+	WhereExpr    expr.Expr
+	GroupByExprs []expr.Expr
+	// GroupingSets are the sets of GroupByExprs the documents are grouped by,
+	// if the GROUP BY clause uses ROLLUP or GROUPING SETS.
+	GroupingSets    [][]expr.Expr
+	HavingExpr      expr.Expr
 	ProjectionExprs []expr.Expr
+
+	// CTE is the common table expression read by the statement, if
+	// TableName is the name of one of the expressions of the WITH clause.
+	CTE *stream.CommonTableExpr
+
+	// true if the statement is used as a subquery
+	subquery bool
+}
+
+// A JoinClause joins a table with the tables of the FROM clause.
+type JoinClause struct {
+	// Type is either INNER, LEFT or CROSS.
+	Type       scanner.Token
+	TableName  string
+	TableAlias string
+	// On is nil for CROSS joins.
+	On expr.Expr
+	// CTE is the common table expression read by the join, if any.
+	CTE *stream.CommonTableExpr
+}
+
+// Name returns the name used to reference the table in the query.
+func (j *JoinClause) Name() string {
+	if j.TableAlias != "" {
+		return j.TableAlias
+	}
+
+	return j.TableName
+}
+
+// checkColumns returns an error if an unqualified column of the statement is a field of more
+// than one of its tables, according to their schemas, and checks the statements of its subqueries.
+// The columns of the tables that don't declare them are checked when the documents are read.
+func (stmt *SelectCoreStmt) checkColumns(catalog *database.Catalog) error {
+	var tables []*database.TableInfo
+	if len(stmt.Joins) > 0 {
+		if stmt.CTE == nil {
+			tables = appendTableInfo(tables, catalog, stmt.TableName)
+		}
+		for _, j := range stmt.Joins {
+			if j.CTE == nil {
+				tables = appendTableInfo(tables, catalog, j.TableName)
+			}
+		}
+	}
+
+	names := stmt.names()
+
+	var err error
+	for _, e := range stmt.exprs() {
+		expr.Walk(e, func(e expr.Expr) bool {
+			switch t := e.(type) {
+			case expr.Path:
+				if len(t) == 0 || containsName(names, t[0].FieldName) {
+					return true
+				}
+
+				var n int
+				for _, ti := range tables {
+					if ti.FieldConstraints.Get(document.Path(t[:1])) != nil {
+						n++
+					}
+				}
+				if n > 1 {
+					err = fmt.Errorf("ambiguous column %q, use the name of the table to select it", t[0].FieldName)
+				}
+			case *stream.SubqueryExpr, *stream.ExistsExpr:
+				sq, _ := stream.SubqueryOf(t)
+				if sel, ok := sq.Stmt.(*SelectStmt); ok {
+					for _, core := range sel.CompoundSelect {
+						err = core.checkColumns(catalog)
+						if err != nil {
+							break
+						}
+					}
+				}
+			}
+			return err == nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// appendTableInfo appends the info of the given table to tables, if it exists.
+func appendTableInfo(tables []*database.TableInfo, catalog *database.Catalog, tableName string) []*database.TableInfo {
+	ti, err := catalog.GetTableInfo(tableName)
+	if err != nil {
+		// the error is returned when the table is read
+		return tables
+	}
+
+	return append(tables, ti)
+}
+
+// scan returns the operator reading the documents of the given table,
+// or of the common table expression if not nil.
+func scan(tableName string, cte *stream.CommonTableExpr) stream.Operator {
+	if cte != nil {
+		return stream.CTEScan(cte)
+	}
+
+	return stream.TableScan(tableName)
+}
+
+// windowExprs returns the window expressions used by the given expressions, without duplicates.
+func windowExprs(exprs ...expr.Expr) []*stream.WindowExpr {
+	var windows []*stream.WindowExpr
+
+	for _, e := range exprs {
+		expr.Walk(e, func(e expr.Expr) bool {
+			w, ok := e.(*stream.WindowExpr)
+			if !ok {
+				return true
+			}
+
+			for _, ww := range windows {
+				if ww.IsEqual(w) {
+					return true
+				}
+			}
+			windows = append(windows, w)
+			return true
+		})
+	}
+
+	return windows
+}
+
+// groupedExpr returns the expression evaluating e against the documents returned by
+// the aggregation of a grouped statement. Aggregate functions are added to aggregators and
+// the sub-expressions of e that are one of the GROUP BY expressions are replaced by
+// the path of the field holding their value.
+// Any other path must appear in the GROUP BY clause.
+func (stmt *SelectCoreStmt) groupedExpr(e expr.Expr, aggregators *[]expr.AggregatorBuilder) (expr.Expr, error) {
+	if agg, ok := e.(expr.AggregatorBuilder); ok {
+		for _, a := range *aggregators {
+			if expr.Equal(a, e) {
+				return e, nil
+			}
+		}
+
+		*aggregators = append(*aggregators, agg)
+		return e, nil
+	}
+
+	for _, g := range stmt.GroupByExprs {
+		if expr.Equal(e, g) {
+			return expr.Path(document.NewPath(e.String())), nil
+		}
+	}
+
+	var err error
+
+	switch t := e.(type) {
+	case *expr.NamedExpr:
+		ne := *t
+		ne.Expr, err = stmt.groupedExpr(t.Expr, aggregators)
+		return &ne, err
+	case expr.Parentheses:
+		t.E, err = stmt.groupedExpr(t.E, aggregators)
+		return t, err
+	case expr.Operator:
+		if b, ok := t.(*expr.BetweenOperator); ok {
+			b.X, err = stmt.groupedExpr(b.X, aggregators)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		lh, err := stmt.groupedExpr(t.LeftHand(), aggregators)
+		if err != nil {
+			return nil, err
+		}
+		rh, err := stmt.groupedExpr(t.RightHand(), aggregators)
+		if err != nil {
+			return nil, err
+		}
+		t.SetLeftHandExpr(lh)
+		t.SetRightHandExpr(rh)
+		return t, nil
+	case *expr.Case:
+		c := expr.Case{Whens: make([]expr.When, len(t.Whens))}
+		if t.Operand != nil {
+			c.Operand, err = stmt.groupedExpr(t.Operand, aggregators)
+			if err != nil {
+				return nil, err
+			}
+		}
+		for i, w := range t.Whens {
+			c.Whens[i].Cond, err = stmt.groupedExpr(w.Cond, aggregators)
+			if err != nil {
+				return nil, err
+			}
+			c.Whens[i].Then, err = stmt.groupedExpr(w.Then, aggregators)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if t.Else != nil {
+			c.Else, err = stmt.groupedExpr(t.Else, aggregators)
+		}
+		return &c, err
+	}
+
+	// any other expression must not read the documents
+	expr.Walk(e, func(e expr.Expr) bool {
+		switch e.(type) {
+		case expr.Path, expr.Wildcard:
+			err = fmt.Errorf("field %q must appear in the GROUP BY clause or be used in an aggregate function", e)
+			return false
+		default:
+			return true
+		}
+	})
+
+	return e, err
 }
 
-func (stmt *SelectCoreStmt) Prepare(*Context) (*StreamStmt, error) {
+func (stmt *SelectCoreStmt) Prepare(ctx *Context) (*StreamStmt, error) {
 	isReadOnly := true
 
+	// subqueries are built by the parser, without a catalog,
+	// they are checked with the outer statement
+	if ctx != nil && ctx.Catalog != nil {
+		err := stmt.checkColumns(ctx.Catalog)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	var s *stream.Stream
 
 	if stmt.TableName != "" {
-		s = s.Pipe(stream.TableScan(stmt.TableName))
+		s = s.Pipe(scan(stmt.TableName, stmt.CTE))
+
+		// documents must be named to be joined, to be
+		// referenced using the alias of the table or by a subquery.
+		// The documents of a subquery are always named, to make sure
+		// they are not confused with the ones of the outer statement
+		name := stmt.name()
+		if stmt.TableAlias != "" || len(stmt.Joins) > 0 || stmt.subquery || isReferencedBySubqueries(name, stmt.exprs()...) {
+			s = s.Pipe(stream.DocsAlias(name))
+
+			names := map[string]bool{name: true}
+			for _, j := range stmt.Joins {
+				if names[j.Name()] {
+					return nil, fmt.Errorf("table name %q specified more than once", j.Name())
+				}
+				names[j.Name()] = true
+
+				right := stream.New(scan(j.TableName, j.CTE)).Pipe(stream.DocsAlias(j.Name()))
+				if j.Type == scanner.LEFT {
+					s = s.Pipe(stream.LeftJoin(right, j.On))
+				} else {
+					s = s.Pipe(stream.Join(right, j.On))
+				}
+			}
+		}
 	}
 
 	if stmt.WhereExpr != nil {
+		if len(windowExprs(stmt.WhereExpr)) > 0 {
+			return nil, errors.New("window functions are not allowed in WHERE")
+		}
+
 		s = s.Pipe(stream.DocsFilter(stmt.WhereExpr))
 	}
 
-	// when using GROUP BY, only aggregation functions or GroupByExpr can be selected
-	if stmt.GroupByExpr != nil {
-		var invalidProjectedField expr.Expr
+	// when using GROUP BY, only aggregation functions or expressions
+	// of the GROUP BY clause can be selected.
+	// HAVING without GROUP BY aggregates all the documents in a single group
+	if len(stmt.GroupByExprs) > 0 || stmt.HavingExpr != nil {
+		if len(windowExprs(stmt.ProjectionExprs...)) > 0 {
+			return nil, errors.New("window functions cannot be used with GROUP BY")
+		}
+
 		var aggregators []expr.AggregatorBuilder
+		var err error
 
 		for i, pe := range stmt.ProjectionExprs {
-			ne, ok := pe.(*expr.NamedExpr)
-			if !ok {
-				invalidProjectedField = pe
-				break
+			stmt.ProjectionExprs[i], err = stmt.groupedExpr(pe, &aggregators)
+			if err != nil {
+				return nil, err
 			}
-			e := ne.Expr
+		}
 
-			// check if the projected expression is an aggregation function
-			if agg, ok := e.(expr.AggregatorBuilder); ok {
-				aggregators = append(aggregators, agg)
-				continue
+		var having expr.Expr
+		if stmt.HavingExpr != nil {
+			if len(windowExprs(stmt.HavingExpr)) > 0 {
+				return nil, errors.New("window functions are not allowed in HAVING")
 			}
 
-			// check if this is the same expression as the one used in the GROUP BY clause
-			if expr.Equal(e, stmt.GroupByExpr) {
-				// if so, replace the expression with a path expression
-				stmt.ProjectionExprs[i] = &expr.NamedExpr{
-					ExprName: ne.ExprName,
-					Expr:     expr.Path(document.NewPath(e.String())),
-				}
-				continue
+			having, err = stmt.groupedExpr(stmt.HavingExpr, &aggregators)
+			if err != nil {
+				return nil, err
 			}
+		}
 
-			// otherwise it's an error
-			invalidProjectedField = ne
-			break
+		// add Aggregation node
+		switch {
+		case stmt.GroupingSets != nil:
+			s = s.Pipe(stream.DocsGroupingSets(stmt.GroupByExprs, stmt.GroupingSets, aggregators...))
+		case len(stmt.GroupByExprs) == 0:
+			s = s.Pipe(stream.DocsGroupAggregate(nil, aggregators...))
+		default:
+			// documents are sorted by the tuple of values of the GROUP BY expressions
+			keys := make([]stream.SortKey, len(stmt.GroupByExprs))
+			for i, e := range stmt.GroupByExprs {
+				keys[i].Expr = e
+			}
+
+			s = s.Pipe(stream.DocsTempTreeSortKeys(keys...))
+			s = s.Pipe(stream.DocsGroupAggregate(stmt.GroupByExprs, aggregators...))
 		}
 
-		if invalidProjectedField != nil {
-			return nil, fmt.Errorf("field %q must appear in the GROUP BY clause or be used in an aggregate function", invalidProjectedField)
+		if having != nil {
+			s = s.Pipe(stream.DocsFilter(having))
 		}
-		// add Aggregation node
-		s = s.Pipe(stream.DocsTempTreeSort(stmt.GroupByExpr))
-		s = s.Pipe(stream.DocsGroupAggregate(stmt.GroupByExpr, aggregators...))
 	} else {
 		// if there is no GROUP BY clause, check if there are any aggregation function
 		// and if so add an aggregation node
@@ -94,6 +370,16 @@ func (stmt *SelectCoreStmt) Prepare(*Context) (*StreamStmt, error) {
 		if len(aggregators) > 0 {
 			s = s.Pipe(stream.DocsGroupAggregate(nil, aggregators...))
 		}
+
+		// add Window node, computing the window functions
+		// of each document before the projection
+		if windows := windowExprs(stmt.ProjectionExprs...); len(windows) > 0 {
+			if len(aggregators) > 0 {
+				return nil, errors.New("window functions cannot be used with aggregate functions")
+			}
+
+			s = s.Pipe(stream.DocsWindow(windows...))
+		}
 	}
 
 	// If there is no FROM clause ensure there is no wildcard or path
@@ -141,16 +427,25 @@ func (stmt *SelectCoreStmt) Prepare(*Context) (*StreamStmt, error) {
 	}, nil
 }
 
+// CompoundOperator combines the documents returned by two SELECT statements.
+type CompoundOperator struct {
+	// UNION, INTERSECT or EXCEPT
+	Token scanner.Token
+	// if true, duplicates are kept
+	All bool
+}
+
 // SelectStmt holds SELECT configuration.
 type SelectStmt struct {
 	basePreparedStatement
 
 	CompoundSelect    []*SelectCoreStmt
-	CompoundOperators []scanner.Token
-	OrderBy           expr.Path
-	OrderByDirection  scanner.Token
+	CompoundOperators []CompoundOperator
+	OrderBy           []stream.SortKey
 	OffsetExpr        expr.Expr
 	LimitExpr         expr.Expr
+	// CTEs are the common table expressions defined by the WITH clause.
+	CTEs []*stream.CommonTableExpr
 }
 
 func NewSelectStatement() *SelectStmt {
@@ -166,13 +461,65 @@ func NewSelectStatement() *SelectStmt {
 
 // Prepare implements the Preparer interface.
 func (stmt *SelectStmt) Prepare(ctx *Context) (Statement, error) {
+	st, err := stmt.toStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return st.Prepare(ctx)
+}
+
+// orderByKeys returns the keys of the ORDER BY clause.
+// Documents are sorted after the projection: keys that are projected expressions
+// are read from the field of the projected document instead of being evaluated.
+func (stmt *SelectStmt) orderByKeys() []stream.SortKey {
+	if len(stmt.OrderBy) == 0 {
+		return nil
+	}
+
+	fields := make(map[string]string)
+	for _, pe := range stmt.CompoundSelect[0].ProjectionExprs {
+		if ne, ok := pe.(*expr.NamedExpr); ok {
+			fields[ne.Expr.String()] = ne.Name()
+			continue
+		}
+
+		if _, ok := pe.(expr.Wildcard); ok {
+			continue
+		}
+
+		fields[pe.String()] = pe.String()
+	}
+
+	keys := make([]stream.SortKey, len(stmt.OrderBy))
+	for i, k := range stmt.OrderBy {
+		keys[i] = k
+
+		// paths refer to the fields of the projected document
+		if _, ok := k.Expr.(expr.Path); ok {
+			continue
+		}
+
+		if name, ok := fields[k.Expr.String()]; ok {
+			keys[i].Expr = expr.Path(document.NewPath(name))
+		}
+	}
+
+	return keys
+}
+
+// toStream builds the stream of the statement, without optimizing it.
+func (stmt *SelectStmt) toStream(ctx *Context) (*StreamStmt, error) {
 	var s *stream.Stream
 
-	var prev scanner.Token
+	var prev CompoundOperator
 
 	var coreStmts []*stream.Stream
 	var readOnly bool = true
 
+	// the projection of the first SELECT may be rewritten when it is prepared
+	orderBy := stmt.orderByKeys()
+
 	for i, coreSelect := range stmt.CompoundSelect {
 		coreStmt, err := coreSelect.Prepare(ctx)
 		if err != nil {
@@ -191,17 +538,25 @@ func (stmt *SelectStmt) Prepare(ctx *Context) (Statement, error) {
 			readOnly = false
 		}
 
-		var tok scanner.Token
+		var tok CompoundOperator
 		if i < len(stmt.CompoundOperators) {
 			tok = stmt.CompoundOperators[i]
 		}
 
-		if prev != 0 && prev != tok {
-			switch prev {
-			case scanner.UNION:
+		if prev.Token != 0 && prev != tok {
+			switch {
+			case prev.Token == scanner.UNION && !prev.All:
 				s = stream.New(stream.Union(coreStmts...))
-			case scanner.ALL:
+			case prev.Token == scanner.UNION:
 				s = stream.New(stream.Concat(coreStmts...))
+			case prev.Token == scanner.INTERSECT && !prev.All:
+				s = stream.New(stream.Intersect(coreStmts...))
+			case prev.Token == scanner.INTERSECT:
+				s = stream.New(stream.IntersectAll(coreStmts...))
+			case prev.Token == scanner.EXCEPT && !prev.All:
+				s = stream.New(stream.Except(coreStmts...))
+			case prev.Token == scanner.EXCEPT:
+				s = stream.New(stream.ExceptAll(coreStmts...))
 			}
 
 			coreStmts = []*stream.Stream{s}
@@ -210,12 +565,8 @@ func (stmt *SelectStmt) Prepare(ctx *Context) (Statement, error) {
 		prev = tok
 	}
 
-	if stmt.OrderBy != nil {
-		if stmt.OrderByDirection == scanner.DESC {
-			s = s.Pipe(stream.DocsTempTreeSortReverse(stmt.OrderBy))
-		} else {
-			s = s.Pipe(stream.DocsTempTreeSort(stmt.OrderBy))
-		}
+	if len(orderBy) > 0 {
+		s = s.Pipe(stream.DocsTempTreeSortKeys(orderBy...))
 	}
 
 	if stmt.OffsetExpr != nil {
@@ -254,10 +605,126 @@ func (stmt *SelectStmt) Prepare(ctx *Context) (Statement, error) {
 		s = s.Pipe(stream.DocsTake(v.V().(int64)))
 	}
 
-	st := StreamStmt{
+	if len(stmt.CTEs) > 0 {
+		s = stream.New(stream.With(s, stmt.CTEs...))
+	}
+
+	return &StreamStmt{
 		Stream:   s,
 		ReadOnly: readOnly,
+	}, nil
+}
+
+func (stmt *SelectStmt) String() string {
+	var b strings.Builder
+
+	for i, core := range stmt.CompoundSelect {
+		if i > 0 {
+			op := stmt.CompoundOperators[i-1]
+			b.WriteString(" " + op.Token.String() + " ")
+			if op.All {
+				b.WriteString("ALL ")
+			}
+		}
+		b.WriteString(core.String())
 	}
 
-	return st.Prepare(ctx)
+	if len(stmt.OrderBy) > 0 {
+		b.WriteString(" ORDER BY ")
+		for i, k := range stmt.OrderBy {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(k.String())
+		}
+	}
+
+	if stmt.LimitExpr != nil {
+		fmt.Fprintf(&b, " LIMIT %s", stmt.LimitExpr)
+	}
+
+	if stmt.OffsetExpr != nil {
+		fmt.Fprintf(&b, " OFFSET %s", stmt.OffsetExpr)
+	}
+
+	return b.String()
+}
+
+func (stmt *SelectCoreStmt) String() string {
+	var b strings.Builder
+
+	b.WriteString("SELECT ")
+	if stmt.Distinct {
+		b.WriteString("DISTINCT ")
+	}
+
+	for i, e := range stmt.ProjectionExprs {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(e.String())
+		if ne, ok := e.(*expr.NamedExpr); ok && ne.Name() != ne.Expr.String() {
+			b.WriteString(" AS " + stringutil.NormalizeIdentifier(ne.Name(), '`'))
+		}
+	}
+
+	if stmt.TableName != "" {
+		b.WriteString(" FROM " + tableString(stmt.TableName, stmt.TableAlias))
+	}
+
+	for _, j := range stmt.Joins {
+		switch j.Type {
+		case scanner.CROSS:
+			b.WriteString(", " + tableString(j.TableName, j.TableAlias))
+		case scanner.LEFT:
+			fmt.Fprintf(&b, " LEFT JOIN %s ON %s", tableString(j.TableName, j.TableAlias), j.On)
+		default:
+			fmt.Fprintf(&b, " JOIN %s ON %s", tableString(j.TableName, j.TableAlias), j.On)
+		}
+	}
+
+	if stmt.WhereExpr != nil {
+		fmt.Fprintf(&b, " WHERE %s", stmt.WhereExpr)
+	}
+
+	if stmt.GroupingSets != nil {
+		b.WriteString(" GROUP BY GROUPING SETS (")
+		for i, set := range stmt.GroupingSets {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString("(" + exprsString(set) + ")")
+		}
+		b.WriteString(")")
+	} else if len(stmt.GroupByExprs) > 0 {
+		b.WriteString(" GROUP BY " + exprsString(stmt.GroupByExprs))
+	}
+
+	if stmt.HavingExpr != nil {
+		fmt.Fprintf(&b, " HAVING %s", stmt.HavingExpr)
+	}
+
+	return b.String()
+}
+
+func tableString(name, alias string) string {
+	s := stringutil.NormalizeIdentifier(name, '`')
+	if alias != "" {
+		s += " AS " + stringutil.NormalizeIdentifier(alias, '`')
+	}
+
+	return s
+}
+
+func exprsString(exprs []expr.Expr) string {
+	var b strings.Builder
+
+	for i, e := range exprs {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(e.String())
+	}
+
+	return b.String()
 }
@@ -338,6 +338,27 @@ func TestSelectStmt(t *testing.T) {
 		assert.NoError(t, err)
 		require.Equal(t, 2, seq)
 	})
+
+	t.Run("uncorrelated subquery returning multiple documents", func(t *testing.T) {
+		db, err := genji.Open(":memory:")
+		assert.NoError(t, err)
+		defer db.Close()
+
+		err = db.Exec(`
+			CREATE TABLE test;
+			INSERT INTO test (a) VALUES (1), (2);
+		`)
+		assert.NoError(t, err)
+
+		res, err := db.Query("SELECT a, (SELECT a FROM test) AS b FROM test")
+		assert.NoError(t, err)
+		defer res.Close()
+
+		// the error must be returned by the statement, even if
+		// the projected fields are not read
+		err = res.Iterate(func(d types.Document) error { return nil })
+		assert.Error(t, err)
+	})
 }
 
 func TestDistinct(t *testing.T) {
@@ -47,8 +47,11 @@ type Preparer interface {
 type Result struct {
 	Iterator document.Iterator
 	Tx       *database.Transaction
-	closed   bool
-	err      error
+	// OnSuccess is called when the result is closed, unless iterating it
+	// or committing its transaction failed.
+	OnSuccess func()
+	closed    bool
+	err       error
 }
 
 func (r *Result) Iterate(fn func(d types.Document) error) error {
@@ -82,5 +85,9 @@ func (r *Result) Close() (err error) {
 		}
 	}
 
+	if err == nil && r.err == nil && r.OnSuccess != nil {
+		r.OnSuccess()
+	}
+
 	return err
 }
@@ -0,0 +1,127 @@
+package statement
+
+import (
+	"github.com/genjidb/genji/internal/expr"
+	"github.com/genjidb/genji/internal/stream"
+)
+
+// Subquery returns an expression that evaluates to the value of the only document
+// returned by the statement. Its stream is optimized along with the stream of the outer statement.
+func (stmt *SelectStmt) Subquery() (*stream.SubqueryExpr, error) {
+	for _, core := range stmt.CompoundSelect {
+		core.subquery = true
+	}
+
+	st, err := stmt.toStream(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	sq := stream.Subquery(st.Stream, stmt.freeNames()...)
+	sq.Stmt = stmt
+	return sq, nil
+}
+
+// freeNames returns the names the statement may use to reference
+// the tables of an outer statement.
+func (stmt *SelectStmt) freeNames() []string {
+	var free, names []string
+
+	for _, core := range stmt.CompoundSelect {
+		free = appendFreeNames(free, core.names(), core.exprs()...)
+		names = append(names, core.names()...)
+	}
+
+	for _, k := range stmt.OrderBy {
+		free = appendFreeNames(free, names, k.Expr)
+	}
+
+	return free
+}
+
+// name returns the name used to reference the documents of the table.
+func (stmt *SelectCoreStmt) name() string {
+	if stmt.TableAlias != "" {
+		return stmt.TableAlias
+	}
+
+	return stmt.TableName
+}
+
+// names returns the names of all the tables of the statement.
+func (stmt *SelectCoreStmt) names() []string {
+	if stmt.TableName == "" {
+		return nil
+	}
+
+	names := []string{stmt.name()}
+	for _, j := range stmt.Joins {
+		names = append(names, j.Name())
+	}
+
+	return names
+}
+
+// exprs returns the expressions used by the statement.
+func (stmt *SelectCoreStmt) exprs() []expr.Expr {
+	exprs := append([]expr.Expr{stmt.WhereExpr, stmt.HavingExpr}, stmt.GroupByExprs...)
+	exprs = append(exprs, stmt.ProjectionExprs...)
+	for _, j := range stmt.Joins {
+		exprs = append(exprs, j.On)
+	}
+
+	return exprs
+}
+
+// isReferencedBySubqueries returns true if one of the subqueries
+// of the given expressions references the given table name.
+func isReferencedBySubqueries(name string, exprs ...expr.Expr) bool {
+	var found bool
+
+	for _, e := range exprs {
+		expr.Walk(e, func(e expr.Expr) bool {
+			if sq, ok := stream.SubqueryOf(e); ok && sq.IsCorrelatedTo(name) {
+				found = true
+			}
+			return !found
+		})
+	}
+
+	return found
+}
+
+// appendFreeNames appends to free the first part of each path of the given expressions,
+// and the free names of their subqueries, unless it is one of names.
+func appendFreeNames(free []string, names []string, exprs ...expr.Expr) []string {
+	add := func(name string) {
+		if !containsName(names, name) && !containsName(free, name) {
+			free = append(free, name)
+		}
+	}
+
+	for _, e := range exprs {
+		expr.Walk(e, func(e expr.Expr) bool {
+			if p, ok := e.(expr.Path); ok && len(p) > 0 {
+				add(p[0].FieldName)
+			}
+			if sq, ok := stream.SubqueryOf(e); ok {
+				for _, n := range sq.FreeNames {
+					add(n)
+				}
+			}
+			return true
+		})
+	}
+
+	return free
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
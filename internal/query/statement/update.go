@@ -42,7 +42,20 @@ type UpdateSetPair struct {
 
 // Prepare implements the Preparer interface.
 func (stmt *UpdateStmt) Prepare(c *Context) (Statement, error) {
-	s := stream.New(stream.TableScan(stmt.TableName))
+	// documents are locked before being read to prevent concurrent transactions
+	// from modifying them between the evaluation of the WHERE clause and the write
+	scan := stream.TableScan(stmt.TableName)
+	scan.ForUpdate = true
+	s := stream.New(scan)
+
+	// documents must be named to be referenced by correlated subqueries
+	exprs := []expr.Expr{stmt.WhereExpr}
+	for _, pair := range stmt.SetPairs {
+		exprs = append(exprs, pair.E)
+	}
+	if isReferencedBySubqueries(stmt.TableName, exprs...) {
+		s = s.Pipe(stream.DocsAlias(stmt.TableName))
+	}
 
 	if stmt.WhereExpr != nil {
 		s = s.Pipe(stream.DocsFilter(stmt.WhereExpr))
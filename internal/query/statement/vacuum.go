@@ -0,0 +1,45 @@
+package statement
+
+import (
+	"github.com/cockroachdb/errors"
+	"github.com/genjidb/genji/internal/expr"
+	"github.com/genjidb/genji/internal/stream"
+	"github.com/genjidb/genji/types"
+)
+
+// VacuumStmt is a DSL that allows creating a VACUUM statement.
+type VacuumStmt struct{}
+
+// IsReadOnly returns true: VACUUM doesn't modify the content of the database.
+// It implements the Statement interface.
+func (stmt VacuumStmt) IsReadOnly() bool {
+	return true
+}
+
+// Run reclaims the space used by deleted data, for example after dropping or truncating tables,
+// and returns the number of bytes reclaimed.
+// Only the data deleted by committed transactions can be reclaimed, which is why
+// VACUUM can't be run within an explicit transaction.
+// It implements the Statement interface.
+func (stmt VacuumStmt) Run(ctx *Context) (Result, error) {
+	if ctx.DB.GetAttachedTx() != nil {
+		return Result{}, errors.New("cannot VACUUM from within a transaction")
+	}
+
+	reclaimed, err := ctx.DB.Compact()
+	if err != nil {
+		return Result{}, err
+	}
+
+	s := PreparedStreamStmt{
+		Stream: &stream.Stream{
+			Op: stream.DocsProject(
+				&expr.NamedExpr{
+					ExprName: "reclaimed_bytes",
+					Expr:     expr.LiteralValue{Value: types.NewIntegerValue(reclaimed)},
+				}),
+		},
+		ReadOnly: true,
+	}
+	return s.Run(ctx)
+}
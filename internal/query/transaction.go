@@ -41,7 +41,11 @@ func (stmt BeginStmt) Run(ctx *statement.Context) (statement.Result, error) {
 }
 
 // RollbackStmt is a statement that rollbacks the current active transaction.
-type RollbackStmt struct{}
+// If SavepointName is set, only the changes made after the savepoint are rolled back
+// and the transaction remains active.
+type RollbackStmt struct {
+	SavepointName string
+}
 
 // Prepare implements the Preparer interface.
 func (stmt RollbackStmt) Prepare(*statement.Context) (statement.Statement, error) {
@@ -53,6 +57,10 @@ func (stmt RollbackStmt) alterQuery(ctx context.Context, db *database.Database,
 		return errors.New("cannot rollback with no active transaction")
 	}
 
+	if stmt.SavepointName != "" {
+		return q.tx.RollbackTo(stmt.SavepointName)
+	}
+
 	err := q.tx.Rollback()
 	if err != nil {
 		return err
@@ -99,3 +107,55 @@ func (stmt CommitStmt) IsReadOnly() bool {
 func (stmt CommitStmt) Run(ctx *statement.Context) (statement.Result, error) {
 	return statement.Result{}, errors.New("cannot commit with no active transaction")
 }
+
+// SavepointStmt is a statement that creates a savepoint in the current active transaction.
+type SavepointStmt struct {
+	Name string
+}
+
+// Prepare implements the Preparer interface.
+func (stmt SavepointStmt) Prepare(*statement.Context) (statement.Statement, error) {
+	return stmt, nil
+}
+
+func (stmt SavepointStmt) alterQuery(ctx context.Context, db *database.Database, q *Query) error {
+	if q.tx == nil || q.autoCommit {
+		return errors.New("cannot create a savepoint with no active transaction")
+	}
+
+	return q.tx.Savepoint(stmt.Name)
+}
+
+func (stmt SavepointStmt) IsReadOnly() bool {
+	return true
+}
+
+func (stmt SavepointStmt) Run(ctx *statement.Context) (statement.Result, error) {
+	return statement.Result{}, errors.New("cannot create a savepoint with no active transaction")
+}
+
+// ReleaseStmt is a statement that destroys a savepoint of the current active transaction.
+type ReleaseStmt struct {
+	Name string
+}
+
+// Prepare implements the Preparer interface.
+func (stmt ReleaseStmt) Prepare(*statement.Context) (statement.Statement, error) {
+	return stmt, nil
+}
+
+func (stmt ReleaseStmt) alterQuery(ctx context.Context, db *database.Database, q *Query) error {
+	if q.tx == nil || q.autoCommit {
+		return errors.New("cannot release a savepoint with no active transaction")
+	}
+
+	return q.tx.ReleaseSavepoint(stmt.Name)
+}
+
+func (stmt ReleaseStmt) IsReadOnly() bool {
+	return true
+}
+
+func (stmt ReleaseStmt) Run(ctx *statement.Context) (statement.Result, error) {
+	return statement.Result{}, errors.New("cannot release a savepoint with no active transaction")
+}
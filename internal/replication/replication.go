@@ -0,0 +1,543 @@
+// Package replication replays the change log of a database on another database.
+//
+// Entries of the change log are transferred as a stream of frames,
+// each frame containing the position of the entry, encoded as an unsigned varint,
+// followed by the length of the entry, encoded as an unsigned varint, and the entry itself.
+package replication
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+
+	"github.com/cockroachdb/errors"
+	errs "github.com/genjidb/genji/errors"
+	"github.com/genjidb/genji/internal/database"
+	"github.com/genjidb/genji/internal/database/catalogstore"
+	"github.com/genjidb/genji/internal/kv"
+	"github.com/genjidb/genji/internal/tree"
+	"github.com/genjidb/genji/types"
+)
+
+// StoreName is the name of the store in which a follower
+// keeps the position of the last entry it applied.
+var StoreName = []byte(database.InternalPrefix + "replication")
+
+var positionKey = []byte("position")
+
+// MaxEntrySize is the maximum size, in bytes, of an entry of the change log
+// transferred in a frame. Larger sizes are considered invalid, to avoid allocating
+// an arbitrary amount of memory when reading a corrupted stream.
+const MaxEntrySize = 1 << 30
+
+// ErrChangeLogDisabled is returned when exporting the change log of a database
+// that doesn't have one.
+var ErrChangeLogDisabled = errors.New("change log is not enabled")
+
+// Export writes to w every entry of the change log of db whose position is greater than from,
+// and returns the position of the last entry written, or from if there was none.
+// The entries are read from a consistent snapshot of the database.
+// If the entries following from were pruned, it returns database.ErrPositionUnavailable.
+func Export(ctx context.Context, db *database.Database, w io.Writer, from uint64) (uint64, error) {
+	if !db.ChangeLog.Enabled() {
+		return from, errors.WithStack(ErrChangeLogDisabled)
+	}
+
+	tx, err := db.BeginTx(ctx, &database.TxOptions{ReadOnly: true})
+	if err != nil {
+		return from, err
+	}
+	defer tx.Rollback()
+
+	bw := bufio.NewWriter(w)
+	last := from
+	err = db.ChangeLog.Iterate(tx, from, func(position uint64, entry []byte) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if position != last+1 {
+			return errors.Wrapf(database.ErrPositionUnavailable, "position %d was pruned from the change log", last+1)
+		}
+
+		err := writeFrame(bw, position, entry)
+		if err != nil {
+			return err
+		}
+
+		last = position
+		return nil
+	})
+	if err != nil {
+		return last, err
+	}
+
+	return last, bw.Flush()
+}
+
+// Position returns the position of the last entry applied to db.
+// If no entry was ever applied, it returns the position of the change log of db,
+// which is set when the database is a backup of the leader, or zero.
+func Position(db *database.Database) (uint64, error) {
+	tx, err := db.Begin(false)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	v, err := tx.Tx.GetStore(StoreName).Get(positionKey)
+	if errors.Is(err, kv.ErrKeyNotFound) {
+		return db.ChangeLog.Position(), nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	pos, n := binary.Uvarint(v)
+	if n <= 0 {
+		return 0, errors.New("invalid replication position")
+	}
+
+	return pos, nil
+}
+
+// Apply reads entries from r and replays them on db, in order, until r returns io.EOF.
+// Each entry is applied in its own transaction, which also stores the position of the entry:
+// entries that were already applied are skipped, which allows to resume replication
+// after a failure by exporting the entries following the position returned by Position.
+// It returns the position of the last applied entry.
+// The database must not be modified by other means and Apply must not be called concurrently.
+func Apply(ctx context.Context, db *database.Database, r io.Reader) (uint64, error) {
+	pos, err := Position(db)
+	if err != nil {
+		return 0, err
+	}
+
+	br := bufio.NewReader(r)
+	for {
+		if err := ctx.Err(); err != nil {
+			return pos, err
+		}
+
+		p, entry, err := readFrame(br)
+		if errors.Is(err, io.EOF) {
+			return pos, nil
+		}
+		if err != nil {
+			return pos, err
+		}
+
+		if p <= pos {
+			continue
+		}
+		if p != pos+1 {
+			return pos, errors.Wrapf(database.ErrPositionUnavailable, "expected position %d, got %d", pos+1, p)
+		}
+
+		err = applyEntry(ctx, db, p, entry)
+		if err != nil {
+			return pos, errors.Wrapf(err, "failed to apply entry %d", p)
+		}
+
+		pos = p
+	}
+}
+
+func applyEntry(ctx context.Context, db *database.Database, position uint64, entry []byte) error {
+	ops, err := database.DecodeLogEntry(entry)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	a := applier{tx: tx}
+	err = a.loadCatalog()
+	if err != nil {
+		return err
+	}
+
+	for _, op := range ops {
+		if op.TableName == database.TableName {
+			err = a.applyCatalogOp(&op)
+		} else {
+			err = a.applyTableOp(&op)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	err = a.dropUnusedStores()
+	if err != nil {
+		return err
+	}
+
+	err = writePosition(tx, position)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	if !a.catalogChanged {
+		return nil
+	}
+
+	// refresh the catalog used by the queries run on the database
+	rtx, err := db.Begin(false)
+	if err != nil {
+		return err
+	}
+	defer rtx.Rollback()
+
+	return catalogstore.ReloadCatalog(rtx, db.Catalog)
+}
+
+func writePosition(tx *database.Transaction, position uint64) error {
+	err := tx.Tx.CreateStore(StoreName)
+	if err != nil && !errors.Is(err, kv.ErrStoreAlreadyExists) {
+		return err
+	}
+
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, position)
+
+	return tx.Tx.GetStore(StoreName).Put(positionKey, buf[:n])
+}
+
+// applier applies the operations of one entry within a transaction.
+// Documents are written directly to the trees of their tables: constraints
+// were already checked by the leader. Indexes are maintained by the applier.
+type applier struct {
+	tx *database.Transaction
+	// catalog of tables and indexes, reloaded after every change
+	// made to the catalog table.
+	catalog *database.Catalog
+	// stores whose catalog object was deleted or replaced.
+	unused         [][]byte
+	catalogChanged bool
+}
+
+func (a *applier) loadCatalog() error {
+	c := database.NewCatalog()
+	err := c.CatalogTable.Init(a.tx, c)
+	if err != nil {
+		return err
+	}
+
+	tables, indexes, err := catalogstore.LoadTablesAndIndexes(a.tx, c.CatalogTable)
+	if err != nil {
+		return err
+	}
+	c.Cache.Load(tables, indexes, nil)
+
+	a.catalog = c
+	return nil
+}
+
+func (a *applier) applyCatalogOp(op *database.LogOp) error {
+	tb := a.catalog.CatalogTable.Table(a.tx)
+
+	old, err := getDocument(tb.Tree, op.Key)
+	if err != nil {
+		return err
+	}
+
+	err = putDocument(tb.Tree, op)
+	if err != nil {
+		return err
+	}
+	a.catalogChanged = true
+
+	var oldStore, newStore []byte
+	if old != nil {
+		oldStore, err = storeName(old)
+		if err != nil {
+			return err
+		}
+		// the store is dropped at the end of the entry if no longer used,
+		// as renaming a table deletes and recreates its catalog object.
+		if oldStore != nil {
+			a.unused = append(a.unused, oldStore)
+		}
+	}
+	if op.Doc != nil {
+		newStore, err = storeName(op.Doc)
+		if err != nil {
+			return err
+		}
+		if newStore != nil {
+			err = a.tx.Tx.CreateStore(newStore)
+			if err != nil && !errors.Is(err, kv.ErrStoreAlreadyExists) {
+				return err
+			}
+		}
+	}
+
+	err = a.loadCatalog()
+	if err != nil {
+		return err
+	}
+
+	if op.Doc == nil || newStore == nil || bytes.Equal(oldStore, newStore) {
+		return nil
+	}
+
+	// the leader builds new indexes without recording any change:
+	// build them from the content of the table.
+	tp, err := op.Doc.GetByField("type")
+	if err != nil {
+		return err
+	}
+	if tp.V().(string) != database.RelationIndexType {
+		return nil
+	}
+
+	name, err := op.Doc.GetByField("name")
+	if err != nil {
+		return err
+	}
+
+	return a.buildIndex(name.V().(string))
+}
+
+func (a *applier) buildIndex(name string) error {
+	info, err := a.catalog.GetIndexInfo(name)
+	if err != nil {
+		return err
+	}
+
+	idx, err := a.catalog.GetIndex(a.tx, name)
+	if err != nil {
+		return err
+	}
+
+	err = idx.Truncate()
+	if err != nil {
+		return err
+	}
+
+	tb, err := a.catalog.GetTable(a.tx, info.TableName)
+	if err != nil {
+		return err
+	}
+
+	return tb.IterateOnRange(nil, false, func(key tree.Key, d types.Document) error {
+		return idx.Set(indexValues(info, d), key)
+	})
+}
+
+func (a *applier) applyTableOp(op *database.LogOp) error {
+	tb, err := a.catalog.GetTable(a.tx, op.TableName)
+	if err != nil {
+		if errs.IsNotFoundError(err) {
+			return errors.Wrap(err, "the follower must be created from a backup of the leader taken after enabling the change log")
+		}
+		return err
+	}
+
+	old, err := getDocument(tb.Tree, op.Key)
+	if err != nil {
+		return err
+	}
+
+	var infos []*database.IndexInfo
+	var indexes []*database.Index
+	for _, name := range a.catalog.ListIndexes(op.TableName) {
+		info, err := a.catalog.GetIndexInfo(name)
+		if err != nil {
+			return err
+		}
+		idx, err := a.catalog.GetIndex(a.tx, name)
+		if err != nil {
+			return err
+		}
+
+		infos = append(infos, info)
+		indexes = append(indexes, idx)
+	}
+
+	if old != nil {
+		for i, idx := range indexes {
+			err = idx.Delete(indexValues(infos[i], old), op.Key)
+			if err != nil && !errors.Is(err, kv.ErrKeyNotFound) {
+				return err
+			}
+		}
+	}
+
+	err = putDocument(tb.Tree, op)
+	if err != nil {
+		return err
+	}
+
+	if op.Doc != nil {
+		for i, idx := range indexes {
+			err = idx.Set(indexValues(infos[i], op.Doc), op.Key)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// dropUnusedStores drops the stores that are no longer
+// referenced by any table or index of the catalog.
+func (a *applier) dropUnusedStores() error {
+	if len(a.unused) == 0 {
+		return nil
+	}
+
+	used := make(map[string]struct{})
+	for _, name := range a.catalog.Cache.ListObjects(database.RelationTableType) {
+		info, err := a.catalog.GetTableInfo(name)
+		if err != nil {
+			return err
+		}
+		used[string(info.StoreName)] = struct{}{}
+	}
+	for _, name := range a.catalog.Cache.ListObjects(database.RelationIndexType) {
+		info, err := a.catalog.GetIndexInfo(name)
+		if err != nil {
+			return err
+		}
+		used[string(info.StoreName)] = struct{}{}
+	}
+
+	for _, st := range a.unused {
+		if _, ok := used[string(st)]; ok {
+			continue
+		}
+
+		ok, err := a.tx.Tx.StoreExists(st)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		err = a.tx.Tx.DropStore(st)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getDocument returns the document stored under key, or nil if it doesn't exist.
+func getDocument(tr *tree.Tree, key tree.Key) (types.Document, error) {
+	v, err := tr.Get(key)
+	if errors.Is(err, kv.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return v.V().(types.Document), nil
+}
+
+func putDocument(tr *tree.Tree, op *database.LogOp) error {
+	if op.Doc != nil {
+		return tr.Put(op.Key, types.NewDocumentValue(op.Doc))
+	}
+
+	err := tr.Delete(op.Key)
+	if errors.Is(err, kv.ErrKeyNotFound) {
+		return nil
+	}
+	return err
+}
+
+func storeName(d types.Document) ([]byte, error) {
+	v, err := d.GetByField("store_name")
+	if errors.Is(err, types.ErrFieldNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return v.V().([]byte), nil
+}
+
+func indexValues(info *database.IndexInfo, d types.Document) []types.Value {
+	vs := make([]types.Value, 0, len(info.Paths))
+	for _, path := range info.Paths {
+		v, err := path.GetValueFromDocument(d)
+		if err != nil {
+			v = types.NewNullValue()
+		}
+		vs = append(vs, v)
+	}
+
+	return vs
+}
+
+func writeFrame(w *bufio.Writer, position uint64, entry []byte) error {
+	if len(entry) > MaxEntrySize {
+		return errors.Errorf("entry %d of %d bytes exceeds the maximum size of %d bytes", position, len(entry), MaxEntrySize)
+	}
+
+	var buf [binary.MaxVarintLen64]byte
+
+	n := binary.PutUvarint(buf[:], position)
+	_, err := w.Write(buf[:n])
+	if err != nil {
+		return err
+	}
+
+	n = binary.PutUvarint(buf[:], uint64(len(entry)))
+	_, err = w.Write(buf[:n])
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(entry)
+	return err
+}
+
+func readFrame(r *bufio.Reader) (uint64, []byte, error) {
+	position, err := binary.ReadUvarint(r)
+	if err != nil {
+		// io.EOF is only returned if no byte was read
+		return 0, nil, err
+	}
+
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, nil, errors.Wrap(noEOF(err), "failed to read entry")
+	}
+	if size > MaxEntrySize {
+		return 0, nil, errors.Errorf("entry %d of %d bytes exceeds the maximum size of %d bytes", position, size, MaxEntrySize)
+	}
+
+	entry := make([]byte, size)
+	_, err = io.ReadFull(r, entry)
+	if err != nil {
+		return 0, nil, errors.Wrap(noEOF(err), "failed to read entry")
+	}
+
+	return position, entry, nil
+}
+
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
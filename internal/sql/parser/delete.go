@@ -31,8 +31,8 @@ func (p *Parser) parseDeleteStatement() (statement.Statement, error) {
 		return nil, err
 	}
 
-	// Parse order by: "ORDER BY path [ASC|DESC]?"
-	stmt.OrderBy, stmt.OrderByDirection, err = p.parseOrderBy()
+	// Parse order by: "ORDER BY expr [ASC|DESC] [NULLS FIRST|LAST] [, ...]*"
+	stmt.OrderBy, err = p.parseOrderBy()
 	if err != nil {
 		return nil, err
 	}
@@ -19,32 +19,32 @@ func TestParserDelete(t *testing.T) {
 		s        string
 		expected *stream.Stream
 	}{
-		{"NoCond", "DELETE FROM test", stream.New(stream.TableScan("test")).Pipe(stream.TableDelete("test"))},
+		{"NoCond", "DELETE FROM test", stream.New(tableScanForUpdate("test")).Pipe(stream.TableDelete("test"))},
 		{"WithCond", "DELETE FROM test WHERE age = 10",
-			stream.New(stream.TableScan("test")).
+			stream.New(tableScanForUpdate("test")).
 				Pipe(stream.DocsFilter(parser.MustParseExpr("age = 10"))).
 				Pipe(stream.TableDelete("test")),
 		},
 		{"WithOffset", "DELETE FROM test WHERE age = 10 OFFSET 20",
-			stream.New(stream.TableScan("test")).
+			stream.New(tableScanForUpdate("test")).
 				Pipe(stream.DocsFilter(parser.MustParseExpr("age = 10"))).
 				Pipe(stream.DocsSkip(20)).
 				Pipe(stream.TableDelete("test")),
 		},
 		{"WithLimit", "DELETE FROM test LIMIT 10",
-			stream.New(stream.TableScan("test")).
+			stream.New(tableScanForUpdate("test")).
 				Pipe(stream.DocsTake(10)).
 				Pipe(stream.TableDelete("test")),
 		},
 		{"WithOrderByThenOffset", "DELETE FROM test WHERE age = 10 ORDER BY age OFFSET 20",
-			stream.New(stream.TableScan("test")).
+			stream.New(tableScanForUpdate("test")).
 				Pipe(stream.DocsFilter(parser.MustParseExpr("age = 10"))).
 				Pipe(stream.DocsTempTreeSort(parser.MustParseExpr("age"))).
 				Pipe(stream.DocsSkip(20)).
 				Pipe(stream.TableDelete("test")),
 		},
 		{"WithOrderByThenLimitThenOffset", "DELETE FROM test WHERE age = 10 ORDER BY age LIMIT 10 OFFSET 20",
-			stream.New(stream.TableScan("test")).
+			stream.New(tableScanForUpdate("test")).
 				Pipe(stream.DocsFilter(parser.MustParseExpr("age = 10"))).
 				Pipe(stream.DocsTempTreeSort(parser.MustParseExpr("age"))).
 				Pipe(stream.DocsSkip(20)).
@@ -74,3 +74,11 @@ func TestParserDelete(t *testing.T) {
 		})
 	}
 }
+
+// tableScanForUpdate returns the table scan used by UPDATE and DELETE statements,
+// which locks the documents it reads.
+func tableScanForUpdate(tableName string) *stream.TableScanOperator {
+	op := stream.TableScan(tableName)
+	op.ForUpdate = true
+	return op
+}
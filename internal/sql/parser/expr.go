@@ -12,6 +12,7 @@ import (
 	"github.com/genjidb/genji/internal/expr"
 	"github.com/genjidb/genji/internal/expr/functions"
 	"github.com/genjidb/genji/internal/sql/scanner"
+	"github.com/genjidb/genji/internal/stream"
 	"github.com/genjidb/genji/types"
 )
 
@@ -93,18 +94,12 @@ func (p *Parser) parseOperator(minPrecedence int, allowed ...scanner.Token) (fun
 		return nil, 0, nil
 	}
 
-	// Ignore currently unused operators.
-	if op == scanner.EQREGEX || op == scanner.NEQREGEX {
-		p.Unscan()
-		return nil, 0, nil
-	}
-
 	if op == scanner.NOT {
 		tok, pos, lit := p.ScanIgnoreWhitespace()
 		if tok.Precedence() >= minPrecedence {
 			switch {
 			case tok == scanner.IN && tok.Precedence() >= minPrecedence:
-				return expr.NotIn, op, nil
+				return inSubquery(expr.NotIn), op, nil
 			case tok == scanner.LIKE && tok.Precedence() >= minPrecedence:
 				return expr.NotLike, op, nil
 			}
@@ -152,7 +147,7 @@ func (p *Parser) parseOperator(minPrecedence int, allowed ...scanner.Token) (fun
 	case scanner.BITWISEXOR:
 		return expr.BitwiseXor, op, nil
 	case scanner.IN:
-		return expr.In, op, nil
+		return inSubquery(expr.In), op, nil
 	case scanner.IS:
 		if tok, _, _ := p.ScanIgnoreWhitespace(); tok == scanner.NOT {
 			return expr.IsNot, op, nil
@@ -161,6 +156,10 @@ func (p *Parser) parseOperator(minPrecedence int, allowed ...scanner.Token) (fun
 		return expr.Is, op, nil
 	case scanner.LIKE:
 		return expr.Like, op, nil
+	case scanner.EQREGEX:
+		return expr.Regexp, op, nil
+	case scanner.NEQREGEX:
+		return expr.NotRegexp, op, nil
 	case scanner.CONCAT:
 		return expr.Concat, op, nil
 	case scanner.BETWEEN:
@@ -181,6 +180,20 @@ func (p *Parser) parseOperator(minPrecedence int, allowed ...scanner.Token) (fun
 	return nil, 0, nil
 }
 
+// inSubquery wraps the constructor of an IN operator so that a subquery
+// used as its right hand side evaluates to the list of values it returns.
+func inSubquery(op func(lhs, rhs expr.Expr) expr.Expr) func(lhs, rhs expr.Expr) expr.Expr {
+	return func(lhs, rhs expr.Expr) expr.Expr {
+		if sq, ok := rhs.(*stream.SubqueryExpr); ok {
+			list := *sq
+			list.List = true
+			rhs = &list
+		}
+
+		return op(lhs, rhs)
+	}
+}
+
 // parseUnaryExpr parses an non-binary expression.
 func (p *Parser) parseUnaryExpr(allowed ...scanner.Token) (expr.Expr, error) {
 	tok, pos, lit := p.ScanIgnoreWhitespace()
@@ -194,6 +207,9 @@ func (p *Parser) parseUnaryExpr(allowed ...scanner.Token) (expr.Expr, error) {
 	case scanner.CAST:
 		p.Unscan()
 		return p.parseCastExpression()
+	case scanner.CASE:
+		p.Unscan()
+		return p.parseCaseExpression()
 	case scanner.IDENT:
 		tok1, _, _ := p.Scan()
 		// if the next token is a left parenthesis, this is a global function
@@ -294,6 +310,12 @@ func (p *Parser) parseUnaryExpr(allowed ...scanner.Token) (expr.Expr, error) {
 		p.Unscan()
 		return p.parseExprList(scanner.LSBRACKET, scanner.RSBRACKET)
 	case scanner.LPAREN:
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok == scanner.SELECT {
+			p.Unscan()
+			return p.parseSubquery()
+		}
+		p.Unscan()
+
 		e, err := p.ParseExpr()
 		if err != nil {
 			return nil, err
@@ -321,6 +343,18 @@ func (p *Parser) parseUnaryExpr(allowed ...scanner.Token) (expr.Expr, error) {
 			return nil, err
 		}
 		return expr.Not(e), nil
+	case scanner.EXISTS:
+		err := p.parseTokens(scanner.LPAREN)
+		if err != nil {
+			return nil, err
+		}
+
+		sq, err := p.parseSubquery()
+		if err != nil {
+			return nil, err
+		}
+
+		return &stream.ExistsExpr{SubqueryExpr: *sq}, nil
 	case scanner.NEXT:
 		err := p.parseTokens(scanner.VALUE, scanner.FOR)
 		if err != nil {
@@ -337,6 +371,22 @@ func (p *Parser) parseUnaryExpr(allowed ...scanner.Token) (expr.Expr, error) {
 	}
 }
 
+// parseSubquery parses a SELECT statement followed by a right parenthesis.
+// This function assumes the left parenthesis has already been consumed.
+func (p *Parser) parseSubquery() (*stream.SubqueryExpr, error) {
+	stmt, err := p.parseSelectStatement()
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.parseTokens(scanner.RPAREN)
+	if err != nil {
+		return nil, err
+	}
+
+	return stmt.Subquery()
+}
+
 // parseInteger parses an integer.
 func (p *Parser) parseInteger() (int64, error) {
 	tok, pos, lit := p.ScanIgnoreWhitespace()
@@ -634,10 +684,89 @@ func (p *Parser) parseExprList(leftToken, rightToken scanner.Token) (expr.Litera
 	return p.parseExprListUntil(rightToken)
 }
 
-// parseFunction parses a function call.
+// parseFunction parses a function call, followed by an OVER clause
+// if the function is a window function:
+//   function OVER ([PARTITION BY expr [, expr]*] [ORDER BY sort_key [, sort_key]*])
+// Aggregate functions followed by an OVER clause are evaluated over the window.
+func (p *Parser) parseFunction() (expr.Expr, error) {
+	fn, err := p.parseFunctionCall()
+	if err != nil {
+		return nil, err
+	}
+
+	_, isWindowFunction := fn.(expr.WindowFunction)
+	_, isAggregator := fn.(expr.AggregatorBuilder)
+
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != scanner.OVER {
+		p.Unscan()
+		if isWindowFunction {
+			return nil, newParseError(scanner.Tokstr(tok, lit), []string{"OVER"}, pos)
+		}
+
+		return fn, nil
+	}
+
+	if !isWindowFunction && !isAggregator {
+		return nil, errors.Errorf("%s is not a window function", fn)
+	}
+
+	// window functions can't be nested
+	for _, param := range fn.(expr.Function).Params() {
+		var nested bool
+		expr.Walk(param, func(e expr.Expr) bool {
+			_, nested = e.(*stream.WindowExpr)
+			return !nested
+		})
+		if nested {
+			return nil, errors.Errorf("window functions cannot be nested in %s", fn)
+		}
+	}
+
+	w := stream.WindowExpr{Func: fn}
+
+	if err := p.parseTokens(scanner.LPAREN); err != nil {
+		return nil, err
+	}
+
+	// parse optional PARTITION BY
+	if ok, err := p.parseOptional(scanner.PARTITION, scanner.BY); err != nil {
+		return nil, err
+	} else if ok {
+		for {
+			e, err := p.ParseExpr()
+			if err != nil {
+				return nil, err
+			}
+			w.Window.PartitionBy = append(w.Window.PartitionBy, e)
+
+			if tok, _, _ := p.ScanIgnoreWhitespace(); tok != scanner.COMMA {
+				p.Unscan()
+				break
+			}
+		}
+	}
+
+	// parse optional ORDER BY
+	if ok, err := p.parseOptional(scanner.ORDER, scanner.BY); err != nil {
+		return nil, err
+	} else if ok {
+		w.Window.OrderBy, err = p.parseSortKeys()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.parseTokens(scanner.RPAREN); err != nil {
+		return nil, err
+	}
+
+	return &w, nil
+}
+
+// parseFunctionCall parses a function call.
 // a function is an identifier followed by a parenthesis,
 // an optional coma-separated list of expressions and a closing parenthesis.
-func (p *Parser) parseFunction() (expr.Expr, error) {
+func (p *Parser) parseFunctionCall() (expr.Expr, error) {
 	// Parse function name.
 	funcName, err := p.parseIdent()
 	if err != nil {
@@ -742,6 +871,74 @@ func (p *Parser) parseCastExpression() (expr.Expr, error) {
 	return expr.Cast{Expr: e, CastAs: tp}, nil
 }
 
+// parseCaseExpression parses a CASE expression:
+//   CASE [expr] WHEN expr THEN expr [WHEN expr THEN expr]* [ELSE expr] END
+func (p *Parser) parseCaseExpression() (expr.Expr, error) {
+	// Parse required CASE token.
+	if err := p.parseTokens(scanner.CASE); err != nil {
+		return nil, err
+	}
+
+	var c expr.Case
+	var err error
+
+	// Parse optional operand.
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok != scanner.WHEN {
+		p.Unscan()
+		c.Operand, err = p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		p.Unscan()
+	}
+
+	// Parse at least one WHEN clause.
+	for {
+		if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != scanner.WHEN {
+			if len(c.Whens) == 0 {
+				return nil, newParseError(scanner.Tokstr(tok, lit), []string{"WHEN"}, pos)
+			}
+			p.Unscan()
+			break
+		}
+
+		var w expr.When
+		w.Cond, err = p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.parseTokens(scanner.THEN); err != nil {
+			return nil, err
+		}
+
+		w.Then, err = p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+
+		c.Whens = append(c.Whens, w)
+	}
+
+	// Parse optional ELSE clause.
+	if ok, err := p.parseOptional(scanner.ELSE); err != nil {
+		return nil, err
+	} else if ok {
+		c.Else, err = p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Parse required END token.
+	if err := p.parseTokens(scanner.END); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
 // tokenIsAllowed is a helper function that determines if a token is allowed.
 func tokenIsAllowed(tok scanner.Token, allowed ...scanner.Token) bool {
 	if allowed == nil {
@@ -130,6 +130,9 @@ func TestParserExpr(t *testing.T) {
 		{"IS NOT", "age IS NOT NULL", expr.IsNot(testutil.ParsePath(t, "age"), testutil.NullValue()), false},
 		{"LIKE", "name LIKE 'foo'", expr.Like(testutil.ParsePath(t, "name"), testutil.TextValue("foo")), false},
 		{"NOT LIKE", "name NOT LIKE 'foo'", expr.NotLike(testutil.ParsePath(t, "name"), testutil.TextValue("foo")), false},
+		{"=~", "name =~ '^fo+$'", expr.Regexp(testutil.ParsePath(t, "name"), testutil.TextValue("^fo+$")), false},
+		{"!~", "name !~ ?", expr.NotRegexp(testutil.ParsePath(t, "name"), expr.PositionalParam(1)), false},
+		{"=~ precedence", "name =~ 'a' || 'b' AND true", expr.And(expr.Regexp(testutil.ParsePath(t, "name"), expr.Concat(testutil.TextValue("a"), testutil.TextValue("b"))), testutil.BoolValue(true)), false},
 		{"NOT =", "name NOT = 'foo'", nil, true},
 		{"precedence", "4 > 1 + 2", expr.Gt(
 			testutil.IntegerValue(4),
@@ -166,12 +169,29 @@ func TestParserExpr(t *testing.T) {
 		{"NEXT VALUE FOR", "NEXT VALUE FOR hello", expr.NextValueFor{SeqName: "hello"}, false},
 		{"NEXT VALUE FOR", "NEXT VALUE FOR `good morning`", expr.NextValueFor{SeqName: "good morning"}, false},
 		{"NEXT VALUE FOR", "NEXT VALUE FOR 10", nil, true},
+		{"CASE", "CASE WHEN a > 1 THEN 'big' WHEN a IS NULL THEN 'none' ELSE 'small' END",
+			&expr.Case{
+				Whens: []expr.When{
+					{Cond: expr.Gt(testutil.ParsePath(t, "a"), testutil.IntegerValue(1)), Then: testutil.TextValue("big")},
+					{Cond: expr.Is(testutil.ParsePath(t, "a"), testutil.NullValue()), Then: testutil.TextValue("none")},
+				},
+				Else: testutil.TextValue("small"),
+			}, false},
+		{"CASE with operand", "CASE a WHEN 1 THEN 'one' END",
+			&expr.Case{
+				Operand: testutil.ParsePath(t, "a"),
+				Whens:   []expr.When{{Cond: testutil.IntegerValue(1), Then: testutil.TextValue("one")}},
+			}, false},
+		{"CASE without WHEN", "CASE a ELSE 1 END", nil, true},
+		{"CASE without END", "CASE WHEN a THEN 1", nil, true},
 
 		// functions
 		{"pk() function", "pk()", &functions.PK{}, false},
 		{"count(expr) function", "count(a)", &functions.Count{Expr: testutil.ParsePath(t, "a")}, false},
 		{"count(*) function", "count(*)", &functions.Count{Wildcard: true}, false},
 		{"packaged function", "math.floor(1.2)", testutil.FunctionExpr(t, "math.floor", testutil.DoubleValue(1.2)), false},
+		{"coalesce function", "COALESCE(a, b, 1)", &functions.Coalesce{Exprs: []expr.Expr{testutil.ParsePath(t, "a"), testutil.ParsePath(t, "b"), testutil.IntegerValue(1)}}, false},
+		{"nullif function", "nullif(a, '')", &functions.NullIf{Exprs: []expr.Expr{testutil.ParsePath(t, "a"), testutil.TextValue("")}}, false},
 	}
 
 	for _, test := range tests {
@@ -3,28 +3,64 @@ package parser
 import (
 	"github.com/genjidb/genji/internal/expr"
 	"github.com/genjidb/genji/internal/sql/scanner"
+	"github.com/genjidb/genji/internal/stream"
 )
 
-func (p *Parser) parseOrderBy() (expr.Path, scanner.Token, error) {
+// parseOrderBy parses the ORDER BY clause, if it exists.
+func (p *Parser) parseOrderBy() ([]stream.SortKey, error) {
 	// parse ORDER token
 	ok, err := p.parseOptional(scanner.ORDER, scanner.BY)
 	if err != nil || !ok {
-		return nil, 0, err
+		return nil, err
 	}
 
-	// parse path
-	path, err := p.parsePath()
-	if err != nil {
-		return nil, 0, err
-	}
+	return p.parseSortKeys()
+}
 
-	// parse optional ASC or DESC
-	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == scanner.ASC || tok == scanner.DESC {
-		return expr.Path(path), tok, nil
-	}
-	p.Unscan()
+// parseSortKeys parses a list of sort keys:
+//   expr [ASC|DESC] [NULLS FIRST|LAST] [, expr [ASC|DESC] [NULLS FIRST|LAST]]*
+func (p *Parser) parseSortKeys() ([]stream.SortKey, error) {
+	var keys []stream.SortKey
+
+	for {
+		e, err := p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
 
-	return expr.Path(path), 0, nil
+		k := stream.SortKey{Expr: e}
+
+		// parse optional ASC or DESC
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok == scanner.DESC {
+			k.Desc = true
+		} else if tok != scanner.ASC {
+			p.Unscan()
+		}
+
+		// NULL values are sorted first in ascending order
+		// and last in descending order, unless specified otherwise
+		k.NullsLast = k.Desc
+		if ok, err := p.parseOptional(scanner.NULLS); err != nil {
+			return nil, err
+		} else if ok {
+			tok, pos, lit := p.ScanIgnoreWhitespace()
+			switch tok {
+			case scanner.FIRST:
+				k.NullsLast = false
+			case scanner.LAST:
+				k.NullsLast = true
+			default:
+				return nil, newParseError(scanner.Tokstr(tok, lit), []string{"FIRST", "LAST"}, pos)
+			}
+		}
+
+		keys = append(keys, k)
+
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok != scanner.COMMA {
+			p.Unscan()
+			return keys, nil
+		}
+	}
 }
 
 func (p *Parser) parseLimit() (expr.Expr, error) {
@@ -12,6 +12,7 @@ import (
 	"github.com/genjidb/genji/internal/query"
 	"github.com/genjidb/genji/internal/query/statement"
 	"github.com/genjidb/genji/internal/sql/scanner"
+	"github.com/genjidb/genji/internal/stream"
 )
 
 // Parser represents an Genji SQL Parser.
@@ -20,6 +21,11 @@ type Parser struct {
 	orderedParams int
 	namedParams   int
 	packagesTable functions.Packages
+
+	// common table expressions that can be read by the statement being parsed,
+	// and the number of times each one is read
+	ctes    []*stream.CommonTableExpr
+	cteRefs map[*stream.CommonTableExpr]int
 }
 
 // NewParser returns a new instance of Parser.
@@ -94,6 +100,8 @@ func (p *Parser) ParseStatement() (statement.Statement, error) {
 	switch tok {
 	case scanner.ALTER:
 		return p.parseAlterStatement()
+	case scanner.ANALYZE:
+		return p.parseAnalyzeStatement()
 	case scanner.BEGIN:
 		return p.parseBeginStatement()
 	case scanner.COMMIT:
@@ -116,10 +124,18 @@ func (p *Parser) ParseStatement() (statement.Statement, error) {
 		return p.parseReIndexStatement()
 	case scanner.ROLLBACK:
 		return p.parseRollbackStatement()
+	case scanner.SAVEPOINT:
+		return p.parseSavepointStatement()
+	case scanner.RELEASE:
+		return p.parseReleaseStatement()
+	case scanner.VACUUM:
+		return p.parseVacuumStatement()
+	case scanner.WITH:
+		return p.parseWithStatement()
 	}
 
 	return nil, newParseError(scanner.Tokstr(tok, lit), []string{
-		"ALTER", "BEGIN", "COMMIT", "SELECT", "DELETE", "UPDATE", "INSERT", "CREATE", "DROP", "EXPLAIN", "REINDEX", "ROLLBACK",
+		"ALTER", "ANALYZE", "BEGIN", "COMMIT", "SELECT", "DELETE", "UPDATE", "INSERT", "CREATE", "DROP", "EXPLAIN", "REINDEX", "ROLLBACK", "SAVEPOINT", "RELEASE", "VACUUM", "WITH",
 	}, pos)
 }
 
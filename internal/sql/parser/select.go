@@ -12,14 +12,14 @@ import (
 func (p *Parser) parseSelectStatement() (*statement.SelectStmt, error) {
 	stmt := statement.NewSelectStatement()
 
-	// Parse SELECT ... [UNION | UNION ALL | INTERSECT] SELECT ...
+	// Parse SELECT ... [UNION | INTERSECT | EXCEPT [ALL]] SELECT ...
 	err := p.parseCompoundSelectStatement(stmt)
 	if err != nil {
 		return nil, err
 	}
 
-	// Parse order by: "ORDER BY path [ASC|DESC]?"
-	stmt.OrderBy, stmt.OrderByDirection, err = p.parseOrderBy()
+	// Parse order by: "ORDER BY expr [ASC|DESC] [NULLS FIRST|LAST] [, ...]*"
+	stmt.OrderBy, err = p.parseOrderBy()
 	if err != nil {
 		return nil, err
 	}
@@ -46,26 +46,21 @@ func (p *Parser) parseCompoundSelectStatement(stmt *statement.SelectStmt) error
 			return err
 		}
 
-		// Parse optional compound operator
-		tok, _, _ := p.ScanIgnoreWhitespace()
-		if tok == scanner.UNION {
-			all, err := p.parseOptional(scanner.ALL)
-			if err != nil {
-				return err
-			}
-			if all {
-				tok = scanner.ALL
-			}
-		}
-
 		stmt.CompoundSelect = append(stmt.CompoundSelect, core)
 
-		if tok != scanner.UNION && tok != scanner.ALL {
+		// Parse optional compound operator
+		tok, _, _ := p.ScanIgnoreWhitespace()
+		if tok != scanner.UNION && tok != scanner.INTERSECT && tok != scanner.EXCEPT {
 			p.Unscan()
 			break
 		}
 
-		stmt.CompoundOperators = append(stmt.CompoundOperators, tok)
+		all, err := p.parseOptional(scanner.ALL)
+		if err != nil {
+			return err
+		}
+
+		stmt.CompoundOperators = append(stmt.CompoundOperators, statement.CompoundOperator{Token: tok, All: all})
 	}
 
 	return nil
@@ -92,7 +87,7 @@ func (p *Parser) parseSelectCore() (*statement.SelectCoreStmt, error) {
 	}
 
 	// Parse "FROM".
-	stmt.TableName, err = p.parseFrom()
+	err = p.parseFrom(&stmt)
 	if err != nil {
 		return nil, err
 	}
@@ -103,8 +98,14 @@ func (p *Parser) parseSelectCore() (*statement.SelectCoreStmt, error) {
 		return nil, err
 	}
 
-	// Parse group by: "GROUP BY expr"
-	stmt.GroupByExpr, err = p.parseGroupBy()
+	// Parse group by: "GROUP BY expr [, expr]*"
+	stmt.GroupByExprs, stmt.GroupingSets, err = p.parseGroupBy()
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse "HAVING expr", without a GROUP BY clause all the documents form a single group
+	stmt.HavingExpr, err = p.parseHaving()
 	if err != nil {
 		return nil, err
 	}
@@ -168,29 +169,254 @@ func (p *Parser) parseProjectedExpr() (expr.Expr, error) {
 	return ne, nil
 }
 
-func (p *Parser) parseFrom() (string, error) {
+// parseFrom parses the optional FROM clause: a table followed by
+// any number of joined tables.
+func (p *Parser) parseFrom(stmt *statement.SelectCoreStmt) error {
 	if ok, err := p.parseOptional(scanner.FROM); !ok || err != nil {
-		return "", err
+		return err
+	}
+
+	var err error
+	stmt.TableName, stmt.TableAlias, err = p.parseTableRef()
+	if err != nil {
+		return err
+	}
+	stmt.CTE = p.readCommonTableExpr(stmt.TableName)
+
+	for {
+		join, err := p.parseJoin()
+		if err != nil {
+			return err
+		}
+		if join == nil {
+			return nil
+		}
+
+		stmt.Joins = append(stmt.Joins, join)
 	}
+}
 
-	// Parse table name
-	ident, err := p.parseIdent()
+// parseTableRef parses a table name followed by an optional alias:
+// "table_name [[AS] alias]".
+func (p *Parser) parseTableRef() (name string, alias string, err error) {
+	name, err = p.parseIdent()
 	if err != nil {
 		pErr := errors.Unwrap(err).(*ParseError)
 		pErr.Expected = []string{"table_name"}
-		return ident, pErr
+		return name, "", pErr
 	}
 
-	return ident, nil
+	tok, _, lit := p.ScanIgnoreWhitespace()
+	switch tok {
+	case scanner.AS:
+		alias, err = p.parseIdent()
+		if err != nil {
+			return "", "", err
+		}
+	case scanner.IDENT:
+		alias = lit
+	default:
+		p.Unscan()
+	}
+
+	return name, alias, nil
 }
 
-func (p *Parser) parseGroupBy() (expr.Expr, error) {
+// parseJoin parses a join, if any:
+//   "," table_ref
+//   [INNER | CROSS | LEFT [OUTER]] JOIN table_ref [ON expr]
+// The ON clause is required for INNER and LEFT joins and forbidden for CROSS joins.
+func (p *Parser) parseJoin() (*statement.JoinClause, error) {
+	var join statement.JoinClause
+
+	tok, _, _ := p.ScanIgnoreWhitespace()
+	switch tok {
+	case scanner.COMMA:
+		join.Type = scanner.CROSS
+	case scanner.JOIN:
+		join.Type = scanner.INNER
+	case scanner.INNER, scanner.CROSS:
+		join.Type = tok
+		if err := p.parseTokens(scanner.JOIN); err != nil {
+			return nil, err
+		}
+	case scanner.LEFT:
+		join.Type = tok
+		if _, err := p.parseOptional(scanner.OUTER); err != nil {
+			return nil, err
+		}
+		if err := p.parseTokens(scanner.JOIN); err != nil {
+			return nil, err
+		}
+	default:
+		p.Unscan()
+		return nil, nil
+	}
+
+	var err error
+	join.TableName, join.TableAlias, err = p.parseTableRef()
+	if err != nil {
+		return nil, err
+	}
+	join.CTE = p.readCommonTableExpr(join.TableName)
+
+	if join.Type == scanner.CROSS {
+		return &join, nil
+	}
+
+	if err := p.parseTokens(scanner.ON); err != nil {
+		return nil, err
+	}
+
+	join.On, err = p.ParseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	return &join, nil
+}
+
+// parseGroupBy parses the GROUP BY clause, if it exists. It returns the list of
+// the expressions used by the clause, without duplicates, and the sets of
+// expressions to group by, if the clause uses ROLLUP or GROUPING SETS:
+//   GROUP BY element [, element]*
+// where element is one of:
+//   expr
+//   ROLLUP (expr [, expr]*)
+//   GROUPING SETS (set [, set]*), where set is "expr" or "([expr [, expr]*])"
+// The grouping sets of a clause with several elements are the
+// concatenations of the grouping sets of each element.
+func (p *Parser) parseGroupBy() ([]expr.Expr, [][]expr.Expr, error) {
 	ok, err := p.parseOptional(scanner.GROUP, scanner.BY)
 	if err != nil || !ok {
+		return nil, nil, err
+	}
+
+	var exprs []expr.Expr
+	sets := [][]expr.Expr{nil}
+	var withSets bool
+
+	for {
+		elementSets, isSets, err := p.parseGroupingElement()
+		if err != nil {
+			return nil, nil, err
+		}
+		withSets = withSets || isSets
+
+		var product [][]expr.Expr
+		for _, set := range sets {
+			for _, es := range elementSets {
+				product = append(product, append(append([]expr.Expr(nil), set...), es...))
+			}
+		}
+		sets = product
+
+		for _, es := range elementSets {
+			exprs = appendExprs(exprs, es...)
+		}
+
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok != scanner.COMMA {
+			p.Unscan()
+			break
+		}
+	}
+
+	if !withSets {
+		return exprs, nil, nil
+	}
+
+	for i := range sets {
+		sets[i] = appendExprs(nil, sets[i]...)
+	}
+
+	return exprs, sets, nil
+}
+
+// parseGroupingElement parses one element of a GROUP BY clause and returns its grouping sets.
+// It returns true if the element is a ROLLUP or a GROUPING SETS.
+func (p *Parser) parseGroupingElement() ([][]expr.Expr, bool, error) {
+	tok, pos, _ := p.ScanIgnoreWhitespace()
+	switch tok {
+	case scanner.ROLLUP:
+		list, err := p.parseExprList(scanner.LPAREN, scanner.RPAREN)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(list) == 0 {
+			return nil, false, newParseError(scanner.Tokstr(scanner.RPAREN, ")"), []string{"expression"}, pos)
+		}
+
+		// ROLLUP (a, b) groups by (a, b), (a) and ()
+		sets := make([][]expr.Expr, 0, len(list)+1)
+		for i := len(list); i >= 0; i-- {
+			sets = append(sets, list[:i])
+		}
+
+		return sets, true, nil
+	case scanner.GROUPING:
+		if err := p.parseTokens(scanner.SETS, scanner.LPAREN); err != nil {
+			return nil, false, err
+		}
+
+		var sets [][]expr.Expr
+		for {
+			var set []expr.Expr
+			if tok, _, _ := p.ScanIgnoreWhitespace(); tok == scanner.LPAREN {
+				list, err := p.parseExprListUntil(scanner.RPAREN)
+				if err != nil {
+					return nil, false, err
+				}
+				set = list
+			} else {
+				p.Unscan()
+				e, err := p.ParseExpr()
+				if err != nil {
+					return nil, false, err
+				}
+				set = []expr.Expr{e}
+			}
+			sets = append(sets, set)
+
+			tok, pos, lit := p.ScanIgnoreWhitespace()
+			if tok == scanner.RPAREN {
+				return sets, true, nil
+			}
+			if tok != scanner.COMMA {
+				return nil, false, newParseError(scanner.Tokstr(tok, lit), []string{",", ")"}, pos)
+			}
+		}
+	default:
+		p.Unscan()
+		e, err := p.ParseExpr()
+		if err != nil {
+			return nil, false, err
+		}
+
+		return [][]expr.Expr{{e}}, false, nil
+	}
+}
+
+// appendExprs appends to exprs the given expressions that are not already part of it.
+func appendExprs(exprs []expr.Expr, others ...expr.Expr) []expr.Expr {
+LOOP:
+	for _, o := range others {
+		for _, e := range exprs {
+			if expr.Equal(e, o) {
+				continue LOOP
+			}
+		}
+
+		exprs = append(exprs, o)
+	}
+
+	return exprs
+}
+
+// parseHaving parses the "HAVING" clause of the query, if it exists.
+func (p *Parser) parseHaving() (expr.Expr, error) {
+	if ok, err := p.parseOptional(scanner.HAVING); !ok || err != nil {
 		return nil, err
 	}
 
-	// parse expr
-	e, err := p.ParseExpr()
-	return e, err
+	return p.ParseExpr()
 }
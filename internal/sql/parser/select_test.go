@@ -2,6 +2,7 @@ package parser_test
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"github.com/genjidb/genji/document"
@@ -80,10 +81,65 @@ func TestParserSelect(t *testing.T) {
 			stream.New(stream.TableScan("test")).
 				Pipe(stream.DocsFilter(parser.MustParseExpr("age = 10"))).
 				Pipe(stream.DocsTempTreeSort(parser.MustParseExpr("a.b.c"))).
-				Pipe(stream.DocsGroupAggregate(parser.MustParseExpr("a.b.c"))).
+				Pipe(stream.DocsGroupAggregate([]expr.Expr{parser.MustParseExpr("a.b.c")})).
 				Pipe(stream.DocsProject(&expr.NamedExpr{ExprName: "a.b.c", Expr: expr.Path(document.NewPath("a.b.c"))})),
 			true, false,
 		},
+		{"WithMultipleGroupByAndHaving", "SELECT a, b.c, COUNT(*) FROM test GROUP BY a, b.c HAVING COUNT(*) > 1",
+			stream.New(stream.TableScan("test")).
+				Pipe(stream.DocsTempTreeSortKeys(stream.SortKey{Expr: parser.MustParseExpr("a")}, stream.SortKey{Expr: parser.MustParseExpr("b.c")})).
+				Pipe(stream.DocsGroupAggregate([]expr.Expr{parser.MustParseExpr("a"), parser.MustParseExpr("b.c")}, &functions.Count{Wildcard: true})).
+				Pipe(stream.DocsFilter(parser.MustParseExpr("COUNT(*) > 1"))).
+				Pipe(stream.DocsProject(
+					&expr.NamedExpr{ExprName: "a", Expr: expr.Path(document.NewPath("a"))},
+					&expr.NamedExpr{ExprName: "b.c", Expr: expr.Path(document.NewPath("b.c"))},
+					&expr.NamedExpr{ExprName: "COUNT(*)", Expr: &functions.Count{Wildcard: true}},
+				)),
+			true, false,
+		},
+		{"WithGroupByRollup", "SELECT a, b, COUNT(*) FROM test GROUP BY ROLLUP(a, b)",
+			stream.New(stream.TableScan("test")).
+				Pipe(stream.DocsGroupingSets(
+					[]expr.Expr{parser.MustParseExpr("a"), parser.MustParseExpr("b")},
+					[][]expr.Expr{
+						{parser.MustParseExpr("a"), parser.MustParseExpr("b")},
+						{parser.MustParseExpr("a")},
+						nil,
+					},
+					&functions.Count{Wildcard: true},
+				)).
+				Pipe(stream.DocsProject(
+					&expr.NamedExpr{ExprName: "a", Expr: expr.Path(document.NewPath("a"))},
+					&expr.NamedExpr{ExprName: "b", Expr: expr.Path(document.NewPath("b"))},
+					&expr.NamedExpr{ExprName: "COUNT(*)", Expr: &functions.Count{Wildcard: true}},
+				)),
+			true, false,
+		},
+		{"WithGroupingSets", "SELECT a, b, c FROM test GROUP BY a, GROUPING SETS ((b, c), (), c)",
+			stream.New(stream.TableScan("test")).
+				Pipe(stream.DocsGroupingSets(
+					[]expr.Expr{parser.MustParseExpr("a"), parser.MustParseExpr("b"), parser.MustParseExpr("c")},
+					[][]expr.Expr{
+						{parser.MustParseExpr("a"), parser.MustParseExpr("b"), parser.MustParseExpr("c")},
+						{parser.MustParseExpr("a")},
+						{parser.MustParseExpr("a"), parser.MustParseExpr("c")},
+					},
+				)).
+				Pipe(stream.DocsProject(
+					&expr.NamedExpr{ExprName: "a", Expr: expr.Path(document.NewPath("a"))},
+					&expr.NamedExpr{ExprName: "b", Expr: expr.Path(document.NewPath("b"))},
+					&expr.NamedExpr{ExprName: "c", Expr: expr.Path(document.NewPath("c"))},
+				)),
+			true, false,
+		},
+		{"WithHavingWithoutGroupBy", "SELECT COUNT(*) FROM test HAVING COUNT(*) > 1",
+			stream.New(stream.TableScan("test")).
+				Pipe(stream.DocsGroupAggregate(nil, &functions.Count{Wildcard: true})).
+				Pipe(stream.DocsFilter(parser.MustParseExpr("COUNT(*) > 1"))).
+				Pipe(stream.DocsProject(&expr.NamedExpr{ExprName: "COUNT(*)", Expr: &functions.Count{Wildcard: true}})),
+			true, false,
+		},
+		{"WithEmptyRollup", "SELECT a FROM test GROUP BY ROLLUP()", nil, true, true},
 		{"WithOrderBy", "SELECT * FROM test WHERE age = 10 ORDER BY a.b.c",
 			stream.New(stream.TableScan("test")).
 				Pipe(stream.DocsFilter(parser.MustParseExpr("age = 10"))).
@@ -102,6 +158,22 @@ func TestParserSelect(t *testing.T) {
 				Pipe(stream.DocsTempTreeSortReverse(testutil.ParsePath(t, "a.b.c"))),
 			true, false,
 		},
+		{"WithOrderBy multiple keys", "SELECT * FROM test ORDER BY a DESC, b NULLS LAST, c DESC NULLS FIRST",
+			stream.New(stream.TableScan("test")).
+				Pipe(stream.DocsTempTreeSortKeys(
+					stream.SortKey{Expr: parser.MustParseExpr("a"), Desc: true, NullsLast: true},
+					stream.SortKey{Expr: parser.MustParseExpr("b"), NullsLast: true},
+					stream.SortKey{Expr: parser.MustParseExpr("c"), Desc: true},
+				)),
+			true, false,
+		},
+		{"WithOrderBy expression", "SELECT * FROM test ORDER BY a + 1",
+			stream.New(stream.TableScan("test")).
+				Pipe(stream.DocsTempTreeSort(parser.MustParseExpr("a + 1"))),
+			true, false,
+		},
+		{"WithOrderBy NULLS without position", "SELECT * FROM test ORDER BY a NULLS", nil, true, true},
+		{"WithOrderBy trailing comma", "SELECT * FROM test ORDER BY a,", nil, true, true},
 		{"WithLimit", "SELECT * FROM test WHERE age = 10 LIMIT 20",
 			stream.New(stream.TableScan("test")).
 				Pipe(stream.DocsFilter(parser.MustParseExpr("age = 10"))).
@@ -187,6 +259,42 @@ func TestParserSelect(t *testing.T) {
 			true, false,
 		},
 
+		{"WithIntersect", "SELECT * FROM test1 INTERSECT SELECT * FROM test2",
+			stream.New(stream.Intersect(
+				stream.New(stream.TableScan("test1")),
+				stream.New(stream.TableScan("test2")),
+			)),
+			true, false,
+		},
+		{"WithIntersectAll", "SELECT * FROM test1 INTERSECT ALL SELECT * FROM test2 INTERSECT ALL SELECT * FROM test",
+			stream.New(stream.IntersectAll(
+				stream.New(stream.TableScan("test1")),
+				stream.New(stream.TableScan("test2")),
+				stream.New(stream.TableScan("test")),
+			)),
+			true, false,
+		},
+		{"WithExcept", "SELECT * FROM test1 EXCEPT SELECT * FROM test2 ORDER BY a",
+			stream.New(stream.Except(
+				stream.New(stream.TableScan("test1")),
+				stream.New(stream.TableScan("test2")),
+			)).Pipe(stream.DocsTempTreeSort(testutil.ParsePath(t, "a"))),
+			true, false,
+		},
+		{"WithExceptAllThenUnion", "SELECT * FROM test1 EXCEPT ALL SELECT * FROM test2 UNION SELECT * FROM test",
+			stream.New(stream.Union(
+				stream.New(stream.ExceptAll(
+					stream.New(stream.TableScan("test1")),
+					stream.New(stream.TableScan("test2")),
+				)),
+				stream.New(stream.TableScan("test")),
+			)),
+			true, false,
+		},
+		{"WithExceptAfterLimit", "SELECT * FROM test1 LIMIT 10 EXCEPT SELECT * FROM test2",
+			nil,
+			true, true,
+		},
 		{"WithUnion", "SELECT * FROM test1 UNION SELECT * FROM test2",
 			stream.New(stream.Union(
 				stream.New(stream.TableScan("test1")),
@@ -308,6 +416,175 @@ func TestParserSelect(t *testing.T) {
 			)),
 			false, false,
 		},
+		{"WithAlias", "SELECT t.a FROM test AS t",
+			stream.New(stream.TableScan("test")).
+				Pipe(stream.DocsAlias("t")).
+				Pipe(stream.DocsProject(testutil.ParseNamedExpr(t, "t.a"))),
+			true, false,
+		},
+		{"WithAliasWithoutAs", "SELECT * FROM test t",
+			stream.New(stream.TableScan("test")).Pipe(stream.DocsAlias("t")),
+			true, false,
+		},
+		{"WithJoin", "SELECT * FROM a JOIN b ON a.x = b.y",
+			stream.New(stream.TableScan("a")).
+				Pipe(stream.DocsAlias("a")).
+				Pipe(stream.Join(
+					stream.New(stream.TableScan("b")).
+						Pipe(stream.DocsAlias("b")).
+						Pipe(stream.DocsFilter(parser.MustParseExpr("a.x = b.y"))),
+					nil,
+				)),
+			true, false,
+		},
+		{"WithInnerJoinAndWhere", "SELECT * FROM a INNER JOIN b AS c ON a.x = c.y AND a.z > 1 WHERE c.w = 1",
+			stream.New(stream.TableScan("a")).
+				Pipe(stream.DocsAlias("a")).
+				Pipe(stream.Join(
+					stream.New(stream.TableScan("b")).
+						Pipe(stream.DocsAlias("c")).
+						Pipe(stream.DocsFilter(parser.MustParseExpr("a.x = c.y"))).
+						Pipe(stream.DocsFilter(parser.MustParseExpr("c.w = 1"))),
+					parser.MustParseExpr("a.z > 1"),
+				)),
+			true, false,
+		},
+		{"WithLeftJoin", "SELECT * FROM a LEFT OUTER JOIN b ON a.x = b.y WHERE b.w = 1",
+			stream.New(stream.TableScan("a")).
+				Pipe(stream.DocsAlias("a")).
+				Pipe(stream.LeftJoin(
+					stream.New(stream.TableScan("b")).
+						Pipe(stream.DocsAlias("b")).
+						Pipe(stream.DocsFilter(parser.MustParseExpr("a.x = b.y"))),
+					nil,
+				)).
+				Pipe(stream.DocsFilter(parser.MustParseExpr("b.w = 1"))),
+			true, false,
+		},
+		{"WithCrossJoin", "SELECT * FROM a CROSS JOIN b, c",
+			stream.New(stream.TableScan("a")).
+				Pipe(stream.DocsAlias("a")).
+				Pipe(stream.Join(stream.New(stream.TableScan("b")).Pipe(stream.DocsAlias("b")), nil)).
+				Pipe(stream.Join(stream.New(stream.TableScan("c")).Pipe(stream.DocsAlias("c")), nil)),
+			true, false,
+		},
+		{"WithJoinWithoutOn", "SELECT * FROM a JOIN b", nil, true, true},
+		{"WithCrossJoinAndOn", "SELECT * FROM a CROSS JOIN b ON a.x = b.y", nil, true, true},
+		{"WithLeftWithoutJoin", "SELECT * FROM a LEFT b ON a.x = b.y", nil, true, true},
+		{"WithInSubquery", "SELECT * FROM a WHERE x IN (SELECT y FROM b)",
+			func() *stream.Stream {
+				sq := stream.SubqueryList(
+					stream.New(stream.TableScan("b")).
+						Pipe(stream.DocsAlias("b")).
+						Pipe(stream.DocsProject(testutil.ParseNamedExpr(t, "y"))),
+					"y",
+				)
+				sq.Stmt = parseSubqueryStmt(t, "SELECT y FROM b")
+				sq.Cached = true
+
+				s := stream.New(stream.TableScan("a")).
+					Pipe(stream.DocsFilter(expr.In(testutil.ParsePath(t, "x"), sq)))
+				s.Subqueries = []expr.Expr{sq}
+				return s
+			}(),
+			true, false,
+		},
+		{"WithCorrelatedExists", "SELECT * FROM a WHERE NOT EXISTS (SELECT 1 FROM b WHERE b.x = a.y)",
+			stream.New(stream.TableScan("a")).
+				Pipe(stream.DocsAlias("a")).
+				Pipe(stream.DocsFilter(expr.Not(func() expr.Expr {
+					e := stream.Exists(
+						stream.New(stream.TableScan("b")).
+							Pipe(stream.DocsAlias("b")).
+							Pipe(stream.DocsFilter(parser.MustParseExpr("b.x = a.y"))).
+							Pipe(stream.DocsProject(testutil.ParseNamedExpr(t, "1"))),
+						"a",
+					)
+					e.Stmt = parseSubqueryStmt(t, "SELECT 1 FROM b WHERE b.x = a.y")
+					return e
+				}()))),
+			true, false,
+		},
+		{"WithScalarSubquery", "SELECT (SELECT COUNT(*) FROM b WHERE b.x = a.y) AS n FROM a",
+			stream.New(stream.TableScan("a")).
+				Pipe(stream.DocsAlias("a")).
+				Pipe(stream.DocsProject(&expr.NamedExpr{
+					ExprName: "n",
+					Expr: func() expr.Expr {
+						sq := stream.Subquery(
+							stream.New(stream.TableScan("b")).
+								Pipe(stream.DocsAlias("b")).
+								Pipe(stream.DocsFilter(parser.MustParseExpr("b.x = a.y"))).
+								Pipe(stream.DocsGroupAggregate(nil, &functions.Count{Wildcard: true})).
+								Pipe(stream.DocsProject(testutil.ParseNamedExpr(t, "COUNT(*)"))),
+							"a",
+						)
+						sq.Stmt = parseSubqueryStmt(t, "SELECT COUNT(*) FROM b WHERE b.x = a.y")
+						return sq
+					}(),
+				})),
+			true, false,
+		},
+		{"WithEmptySubquery", "SELECT * FROM a WHERE x IN (SELECT)", nil, true, true},
+		{"WithExistsWithoutParentheses", "SELECT * FROM a WHERE EXISTS SELECT 1 FROM b", nil, true, true},
+		// expressions read once are inlined
+		{"WithCommonTableExpr", "WITH t AS (SELECT * FROM a WHERE x > 1) SELECT * FROM t",
+			stream.New(stream.TableScan("a")).Pipe(stream.DocsFilter(parser.MustParseExpr("x > 1"))),
+			true, false,
+		},
+		{"WithMaterializedCommonTableExpr", "WITH t AS (SELECT * FROM a) SELECT * FROM t, t AS u",
+			func() *stream.Stream {
+				cte := &stream.CommonTableExpr{
+					Name:         "t",
+					Stream:       stream.New(stream.TableScan("a")),
+					Materialized: true,
+				}
+				return stream.New(stream.With(
+					stream.New(stream.CTEScan(cte)).
+						Pipe(stream.DocsAlias("t")).
+						Pipe(stream.Join(stream.New(stream.CTEScan(cte)).Pipe(stream.DocsAlias("u")), nil)),
+					cte,
+				))
+			}(),
+			true, false,
+		},
+		{"WithRecursiveCommonTableExpr", "WITH RECURSIVE t(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM t WHERE n < 5) SELECT * FROM t",
+			func() *stream.Stream {
+				cte := &stream.CommonTableExpr{
+					Name:   "t",
+					Stream: stream.New(stream.DocsProject(&expr.NamedExpr{ExprName: "n", Expr: parser.MustParseExpr("1")})),
+				}
+				cte.Recursive = stream.New(stream.CTEScan(cte)).
+					Pipe(stream.DocsFilter(parser.MustParseExpr("n < 5"))).
+					Pipe(stream.DocsProject(&expr.NamedExpr{ExprName: "n", Expr: parser.MustParseExpr("n + 1")}))
+				return stream.New(stream.With(stream.New(stream.CTEScan(cte)), cte))
+			}(),
+			true, false,
+		},
+		{"WithCommonTableExprDefinedTwice", "WITH t AS (SELECT * FROM a), t AS (SELECT * FROM b) SELECT * FROM t", nil, true, true},
+		{"WithCommonTableExprWithoutSelect", "WITH t AS (SELECT * FROM a) DELETE FROM a", nil, true, true},
+		{"WithRecursiveCommonTableExprWithoutUnion", "WITH RECURSIVE t AS (SELECT * FROM t) SELECT * FROM t", nil, true, true},
+		{"WindowFunction", "SELECT ROW_NUMBER() OVER (PARTITION BY a ORDER BY b DESC, c) AS rn FROM test",
+			func() *stream.Stream {
+				w := &stream.WindowExpr{
+					Func: &functions.RowNumber{},
+					Window: stream.Window{
+						PartitionBy: []expr.Expr{parser.MustParseExpr("a")},
+						OrderBy: []stream.SortKey{
+							{Expr: parser.MustParseExpr("b"), Desc: true, NullsLast: true},
+							{Expr: parser.MustParseExpr("c")},
+						},
+					},
+				}
+				return stream.New(stream.TableScan("test")).
+					Pipe(stream.DocsWindow(w)).
+					Pipe(stream.DocsProject(&expr.NamedExpr{ExprName: "rn", Expr: w}))
+			}(),
+			true, false,
+		},
+		{"WindowFunctionWithoutOver", "SELECT ROW_NUMBER() FROM test", nil, true, true},
+		{"WindowFunctionOverScalarFunction", "SELECT typeof(a) OVER () FROM test", nil, true, true},
+		{"NestedWindowFunctions", "SELECT LAG(ROW_NUMBER() OVER ()) OVER () FROM test", nil, true, true},
 	}
 
 	for _, test := range tests {
@@ -343,6 +620,19 @@ func TestParserSelect(t *testing.T) {
 	}
 }
 
+// parseSubqueryStmt parses the SELECT statement of a subquery.
+func parseSubqueryStmt(t testing.TB, s string) *statement.SelectStmt {
+	t.Helper()
+
+	stmt, err := parser.NewParser(strings.NewReader(s)).ParseStatement()
+	assert.NoError(t, err)
+
+	sq, err := stmt.(*statement.SelectStmt).Subquery()
+	assert.NoError(t, err)
+
+	return sq.Stmt.(*statement.SelectStmt)
+}
+
 func BenchmarkSelect(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		_, _ = parser.ParseQuery("SELECT a, b.c[100].d AS `foo` FROM `some table` WHERE d.e[100] >= 12 AND c.d IN ([1, true], [2, false]) GROUP BY d.e[0] LIMIT 10 + 10 OFFSET 20 - 20 ORDER BY d DESC")
@@ -24,6 +24,15 @@ func TestParserTransactions(t *testing.T) {
 		{"BEGIN WRITE", query.BeginStmt{}, true},
 		{"ROLLBACK", query.RollbackStmt{}, false},
 		{"ROLLBACK TRANSACTION", query.RollbackStmt{}, false},
+		{"ROLLBACK TO foo", query.RollbackStmt{SavepointName: "foo"}, false},
+		{"ROLLBACK TO SAVEPOINT foo", query.RollbackStmt{SavepointName: "foo"}, false},
+		{"ROLLBACK TRANSACTION TO SAVEPOINT foo", query.RollbackStmt{SavepointName: "foo"}, false},
+		{"ROLLBACK TO", query.RollbackStmt{}, true},
+		{"SAVEPOINT foo", query.SavepointStmt{Name: "foo"}, false},
+		{"SAVEPOINT", query.SavepointStmt{}, true},
+		{"RELEASE foo", query.ReleaseStmt{Name: "foo"}, false},
+		{"RELEASE SAVEPOINT foo", query.ReleaseStmt{Name: "foo"}, false},
+		{"RELEASE", query.ReleaseStmt{}, true},
 		{"COMMIT", query.CommitStmt{}, false},
 		{"COMMIT TRANSACTION", query.CommitStmt{}, false},
 	}
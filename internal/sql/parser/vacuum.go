@@ -0,0 +1,16 @@
+package parser
+
+import (
+	"github.com/genjidb/genji/internal/query/statement"
+	"github.com/genjidb/genji/internal/sql/scanner"
+)
+
+// parseVacuumStatement parses a vacuum statement.
+func (p *Parser) parseVacuumStatement() (statement.Statement, error) {
+	// Parse "VACUUM".
+	if err := p.parseTokens(scanner.VACUUM); err != nil {
+		return nil, err
+	}
+
+	return statement.VacuumStmt{}, nil
+}
@@ -0,0 +1,35 @@
+package parser_test
+
+import (
+	"testing"
+
+	"github.com/genjidb/genji/internal/query/statement"
+	"github.com/genjidb/genji/internal/sql/parser"
+	"github.com/genjidb/genji/internal/testutil/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParserVacuum(t *testing.T) {
+	tests := []struct {
+		name     string
+		s        string
+		expected statement.Statement
+		errored  bool
+	}{
+		{"Basic", "VACUUM", statement.VacuumStmt{}, false},
+		{"With extra", "VACUUM test", nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			q, err := parser.ParseQuery(test.s)
+			if test.errored {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			require.Len(t, q.Statements, 1)
+			require.EqualValues(t, test.expected, q.Statements[0])
+		})
+	}
+}
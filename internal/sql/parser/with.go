@@ -0,0 +1,134 @@
+package parser
+
+import (
+	"github.com/cockroachdb/errors"
+	"github.com/genjidb/genji/internal/query/statement"
+	"github.com/genjidb/genji/internal/sql/scanner"
+	"github.com/genjidb/genji/internal/stream"
+)
+
+// parseWithStatement parses a SELECT statement preceded by a WITH clause and returns a Statement AST object:
+//   WITH [RECURSIVE] name [(field, ...)] AS (select_stmt) [, name [(field, ...)] AS (select_stmt)]* select_stmt
+// Each expression can be read by the following ones and by the final statement.
+// Expressions read more than once are materialized.
+func (p *Parser) parseWithStatement() (*statement.SelectStmt, error) {
+	if err := p.parseTokens(scanner.WITH); err != nil {
+		return nil, err
+	}
+
+	recursive, err := p.parseOptional(scanner.RECURSIVE)
+	if err != nil {
+		return nil, err
+	}
+
+	// the expressions can only be read by this statement
+	defer func(ctes []*stream.CommonTableExpr, refs map[*stream.CommonTableExpr]int) {
+		p.ctes, p.cteRefs = ctes, refs
+	}(p.ctes, p.cteRefs)
+	p.ctes = nil
+	p.cteRefs = make(map[*stream.CommonTableExpr]int)
+
+	for {
+		cte, err := p.parseCommonTableExpr(recursive)
+		if err != nil {
+			return nil, err
+		}
+		p.ctes = append(p.ctes, cte)
+
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok != scanner.COMMA {
+			p.Unscan()
+			break
+		}
+	}
+
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != scanner.SELECT {
+		return nil, newParseError(scanner.Tokstr(tok, lit), []string{"SELECT"}, pos)
+	}
+	p.Unscan()
+
+	stmt, err := p.parseSelectStatement()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cte := range p.ctes {
+		cte.Materialized = p.cteRefs[cte] > 1
+	}
+	stmt.CTEs = p.ctes
+
+	return stmt, nil
+}
+
+// parseCommonTableExpr parses "name [(field, ...)] AS (select_stmt)".
+// If recursive is true, select_stmt can read the expression it defines.
+func (p *Parser) parseCommonTableExpr(recursive bool) (*stream.CommonTableExpr, error) {
+	name, err := p.parseIdent()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cte := range p.ctes {
+		if cte.Name == name {
+			return nil, errors.Errorf("common table expression %q specified more than once", name)
+		}
+	}
+
+	var fields []string
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == scanner.LPAREN {
+		fields, err = p.parseIdentList()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.parseTokens(scanner.RPAREN); err != nil {
+			return nil, err
+		}
+	} else {
+		p.Unscan()
+	}
+
+	if err := p.parseTokens(scanner.AS, scanner.LPAREN); err != nil {
+		return nil, err
+	}
+
+	cte := stream.CommonTableExpr{Name: name}
+
+	ctes := p.ctes
+	if recursive {
+		p.ctes = append(ctes[:len(ctes):len(ctes)], &cte)
+	}
+
+	stmt, err := p.parseSelectStatement()
+	p.ctes = ctes
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.parseTokens(scanner.RPAREN); err != nil {
+		return nil, err
+	}
+
+	// the expression reading itself doesn't count as a reference
+	refs := p.cteRefs[&cte]
+	delete(p.cteRefs, &cte)
+
+	err = stmt.DefineCommonTableExpr(&cte, fields, refs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cte, nil
+}
+
+// readCommonTableExpr returns the common table expression with the given name, if any,
+// and counts the reference.
+func (p *Parser) readCommonTableExpr(name string) *stream.CommonTableExpr {
+	for _, cte := range p.ctes {
+		if cte.Name == name {
+			p.cteRefs[cte]++
+			return cte
+		}
+	}
+
+	return nil
+}
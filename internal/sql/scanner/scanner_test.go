@@ -127,11 +127,13 @@ func TestScanner_Scan(t *testing.T) {
 		{s: `BEGIN`, tok: BEGIN},
 		{s: `BETWEEN`, tok: BETWEEN},
 		{s: `CACHE`, tok: CACHE},
+		{s: `CASE`, tok: CASE},
 		{s: `CAST`, tok: CAST},
 		{s: `CHECK`, tok: CHECK},
 		{s: `COMMIT`, tok: COMMIT},
 		{s: `CONFLICT`, tok: CONFLICT},
 		{s: `CREATE`, tok: CREATE},
+		{s: `CROSS`, tok: CROSS},
 		{s: `CYCLE`, tok: CYCLE},
 		{s: `DEFAULT`, tok: DEFAULT},
 		{s: `DELETE`, tok: DELETE},
@@ -139,16 +141,27 @@ func TestScanner_Scan(t *testing.T) {
 		{s: `DO`, tok: DO},
 		{s: `DISTINCT`, tok: DISTINCT},
 		{s: `DROP`, tok: DROP},
+		{s: `ELSE`, tok: ELSE},
+		{s: `END`, tok: END},
+		{s: `EXCEPT`, tok: EXCEPT},
 		{s: `EXPLAIN`, tok: EXPLAIN},
 		{s: `GROUP`, tok: GROUP},
+		{s: `GROUPING`, tok: GROUPING},
+		{s: `HAVING`, tok: HAVING},
 		{s: `FIELD`, tok: FIELD},
+		{s: `FIRST`, tok: FIRST},
 		{s: `FOR`, tok: FOR},
 		{s: `FROM`, tok: FROM},
 		{s: `IGNORE`, tok: IGNORE},
 		{s: `INCREMENT`, tok: INCREMENT},
 		{s: `INDEX`, tok: INDEX},
+		{s: `INNER`, tok: INNER},
 		{s: `INSERT`, tok: INSERT},
+		{s: `INTERSECT`, tok: INTERSECT},
 		{s: `INTO`, tok: INTO},
+		{s: `JOIN`, tok: JOIN},
+		{s: `LAST`, tok: LAST},
+		{s: `LEFT`, tok: LEFT},
 		{s: `LIMIT`, tok: LIMIT},
 		{s: `MAXVALUE`, tok: MAXVALUE},
 		{s: `MINVALUE`, tok: MINVALUE},
@@ -156,21 +169,29 @@ func TestScanner_Scan(t *testing.T) {
 		{s: `NO`, tok: NO},
 		{s: `NOT`, tok: NOT},
 		{s: `NOTHING`, tok: NOTHING},
+		{s: `NULLS`, tok: NULLS},
 		{s: `ONLY`, tok: ONLY},
 		{s: `OFFSET`, tok: OFFSET},
 		{s: `ORDER`, tok: ORDER},
+		{s: `OUTER`, tok: OUTER},
+		{s: `OVER`, tok: OVER},
+		{s: `PARTITION`, tok: PARTITION},
 		{s: `PRIMARY`, tok: PRIMARY},
 		{s: `READ`, tok: READ},
+		{s: `RECURSIVE`, tok: RECURSIVE},
 		{s: `REINDEX`, tok: REINDEX},
 		{s: `RENAME`, tok: RENAME},
 		{s: `REPLACE`, tok: REPLACE},
 		{s: `RETURNING`, tok: RETURNING},
 		{s: `ROLLBACK`, tok: ROLLBACK},
+		{s: `ROLLUP`, tok: ROLLUP},
 		{s: `SELECT`, tok: SELECT},
 		{s: `SEQUENCE`, tok: SEQUENCE},
 		{s: `SET`, tok: SET},
+		{s: `SETS`, tok: SETS},
 		{s: `START`, tok: START},
 		{s: `TABLE`, tok: TABLE},
+		{s: `THEN`, tok: THEN},
 		{s: `TO`, tok: TO},
 		{s: `TRANSACTION`, tok: TRANSACTION},
 		{s: `UPDATE`, tok: UPDATE},
@@ -178,6 +199,7 @@ func TestScanner_Scan(t *testing.T) {
 		{s: `UNSET`, tok: UNSET},
 		{s: `VALUE`, tok: VALUE},
 		{s: `VALUES`, tok: VALUES},
+		{s: `WHEN`, tok: WHEN},
 		{s: `WITH`, tok: WITH},
 		{s: `WHERE`, tok: WHERE},
 		{s: `WRITE`, tok: WRITE},
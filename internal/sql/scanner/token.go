@@ -79,16 +79,19 @@ const (
 	ADD_KEYWORD
 	ALL
 	ALTER
+	ANALYZE
 	AS
 	ASC
 	BEGIN
 	BY
 	CACHE
+	CASE
 	CAST
 	CHECK
 	COMMIT
 	CONFLICT
 	CREATE
+	CROSS
 	CYCLE
 	DEFAULT
 	DELETE
@@ -96,19 +99,30 @@ const (
 	DISTINCT
 	DO
 	DROP
+	ELSE
+	END
+	EXCEPT
 	EXISTS
 	EXPLAIN
 	FIELD
+	FIRST
 	FOR
 	FROM
 	GROUP
+	GROUPING
+	HAVING
 	IF
 	IGNORE
 	INCREMENT
 	INDEX
+	INNER
 	INSERT
+	INTERSECT
 	INTO
+	JOIN
 	KEY
+	LAST
+	LEFT
 	LIMIT
 	MAXVALUE
 	MINVALUE
@@ -116,31 +130,43 @@ const (
 	NO
 	NOT
 	NOTHING
+	NULLS
 	OFFSET
 	ON
 	ONLY
 	ORDER
+	OUTER
+	OVER
+	PARTITION
 	PRECISION
 	PRIMARY
 	READ
+	RECURSIVE
 	REINDEX
+	RELEASE
 	RENAME
 	REPLACE
 	RETURNING
 	ROLLBACK
+	ROLLUP
+	SAVEPOINT
 	SELECT
 	SEQUENCE
 	SET
+	SETS
 	START
 	TABLE
+	THEN
 	TO
 	TRANSACTION
 	UNION
 	UNIQUE
 	UNSET
 	UPDATE
+	VACUUM
 	VALUE
 	VALUES
+	WHEN
 	WITH
 	WHERE
 	WRITE
@@ -224,16 +250,19 @@ var tokens = [...]string{
 	ADD_KEYWORD: "ADD",
 	ALL:         "ALL",
 	ALTER:       "ALTER",
+	ANALYZE:     "ANALYZE",
 	AS:          "AS",
 	ASC:         "ASC",
 	BEGIN:       "BEGIN",
 	BY:          "BY",
 	CACHE:       "CACHE",
+	CASE:        "CASE",
 	CAST:        "CAST",
 	CHECK:       "CHECK",
 	COMMIT:      "COMMIT",
 	CONFLICT:    "CONFLICT",
 	CREATE:      "CREATE",
+	CROSS:       "CROSS",
 	CYCLE:       "CYCLE",
 	DO:          "DO",
 	DEFAULT:     "DEFAULT",
@@ -241,19 +270,30 @@ var tokens = [...]string{
 	DESC:        "DESC",
 	DISTINCT:    "DISTINCT",
 	DROP:        "DROP",
+	ELSE:        "ELSE",
+	END:         "END",
+	EXCEPT:      "EXCEPT",
 	EXISTS:      "EXISTS",
 	EXPLAIN:     "EXPLAIN",
 	GROUP:       "GROUP",
+	GROUPING:    "GROUPING",
+	HAVING:      "HAVING",
 	KEY:         "KEY",
+	LAST:        "LAST",
 	FIELD:       "FIELD",
+	FIRST:       "FIRST",
 	FOR:         "FOR",
 	FROM:        "FROM",
 	IF:          "IF",
 	IGNORE:      "IGNORE",
 	INCREMENT:   "INCREMENT",
 	INDEX:       "INDEX",
+	INNER:       "INNER",
 	INSERT:      "INSERT",
+	INTERSECT:   "INTERSECT",
 	INTO:        "INTO",
+	JOIN:        "JOIN",
+	LEFT:        "LEFT",
 	LIMIT:       "LIMIT",
 	MAXVALUE:    "MAXVALUE",
 	MINVALUE:    "MINVALUE",
@@ -261,31 +301,43 @@ var tokens = [...]string{
 	NO:          "NO",
 	NOT:         "NOT",
 	NOTHING:     "NOTHING",
+	NULLS:       "NULLS",
 	OFFSET:      "OFFSET",
 	ON:          "ON",
 	ONLY:        "ONLY",
 	ORDER:       "ORDER",
+	OUTER:       "OUTER",
+	OVER:        "OVER",
+	PARTITION:   "PARTITION",
 	PRECISION:   "PRECISION",
 	PRIMARY:     "PRIMARY",
 	READ:        "READ",
+	RECURSIVE:   "RECURSIVE",
 	REINDEX:     "REINDEX",
+	RELEASE:     "RELEASE",
 	RENAME:      "RENAME",
 	RETURNING:   "RETURNING",
 	REPLACE:     "REPLACE",
 	ROLLBACK:    "ROLLBACK",
+	ROLLUP:      "ROLLUP",
+	SAVEPOINT:   "SAVEPOINT",
 	START:       "START",
 	SELECT:      "SELECT",
 	SET:         "SET",
+	SETS:        "SETS",
 	SEQUENCE:    "SEQUENCE",
 	TABLE:       "TABLE",
+	THEN:        "THEN",
 	TO:          "TO",
 	TRANSACTION: "TRANSACTION",
 	UNION:       "UNION",
 	UNIQUE:      "UNIQUE",
 	UNSET:       "UNSET",
 	UPDATE:      "UPDATE",
+	VACUUM:      "VACUUM",
 	VALUE:       "VALUE",
 	VALUES:      "VALUES",
+	WHEN:        "WHEN",
 	WITH:        "WITH",
 	WHERE:       "WHERE",
 	WRITE:       "WRITE",
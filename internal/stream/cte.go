@@ -0,0 +1,305 @@
+package stream
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/genjidb/genji/internal/database"
+	"github.com/genjidb/genji/internal/environment"
+	"github.com/genjidb/genji/internal/tree"
+	"github.com/genjidb/genji/types"
+)
+
+// A CommonTableExpr is a named stream defined by the WITH clause of a statement,
+// which can be read like a table by the other streams of the statement.
+type CommonTableExpr struct {
+	Name string
+	// Stream returns the documents of the expression.
+	// If the expression is recursive, it returns its initial documents.
+	Stream *Stream
+	// Recursive is nil unless the expression is recursive.
+	// It is evaluated repeatedly, reading the documents returned by its previous evaluation
+	// using the name of the expression, until it doesn't return any document.
+	Recursive *Stream
+	// If true, documents already returned by a recursive expression are ignored (UNION),
+	// otherwise they are all returned (UNION ALL).
+	Distinct bool
+	// If true, the documents are stored in a temporary tree by the WITH operator,
+	// otherwise the expression is evaluated each time it is read.
+	Materialized bool
+}
+
+// Iterate evaluates the expression and calls fn for each document it returns.
+func (c *CommonTableExpr) Iterate(in *environment.Environment, fn func(out *environment.Environment) error) error {
+	if c.Recursive == nil {
+		return c.Stream.Iterate(in, fn)
+	}
+
+	return c.iterateRecursive(in, fn)
+}
+
+// iterateRecursive returns the initial documents, then evaluates the recursive stream
+// using the documents returned by the previous step until it doesn't return any document.
+// The documents of each step are stored in a temporary tree, bound to the name of the expression.
+func (c *CommonTableExpr) iterateRecursive(in *environment.Environment, fn func(out *environment.Environment) error) (err error) {
+	db := in.GetDB()
+
+	// documents already returned, used to ignore duplicates
+	var seen *tree.Tree
+	if c.Distinct {
+		tr, cleanup, err := database.NewTransientTree(db)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		seen = tr
+	}
+
+	var counter int64
+	// set to true if fn asked to stop the iteration,
+	// which some operators ignore
+	var closed bool
+
+	step := func(s *Stream, env *environment.Environment, next *tree.Tree) error {
+		return s.Iterate(env, func(out *environment.Environment) error {
+			d, ok := out.GetDocument()
+			if !ok {
+				return errors.New("missing document")
+			}
+
+			v := types.NewDocumentValue(d)
+
+			if seen != nil {
+				k, err := tree.NewKey(v)
+				if err != nil {
+					return err
+				}
+
+				ok, err := seen.Exists(k)
+				if err != nil || ok {
+					return err
+				}
+
+				err = seen.Put(k, nil)
+				if err != nil {
+					return err
+				}
+			}
+
+			k, err := tree.NewKey(types.NewIntegerValue(counter))
+			if err != nil {
+				return err
+			}
+			counter++
+
+			err = next.Put(k, v)
+			if err != nil {
+				return err
+			}
+
+			err = fn(out)
+			if errors.Is(err, ErrStreamClosed) {
+				closed = true
+			}
+			return err
+		})
+	}
+
+	working, cleanup, err := database.NewTransientTree(db)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		e := cleanup()
+		if err == nil {
+			err = e
+		}
+	}()
+
+	err = step(c.Stream, in, working)
+
+	// number of documents returned before the last step
+	var n int64
+	for err == nil && !closed && counter > n {
+		n = counter
+
+		next, nextCleanup, err := database.NewTransientTree(db)
+		if err != nil {
+			return err
+		}
+
+		var newEnv environment.Environment
+		newEnv.SetOuter(in)
+		newEnv.SetTree(c.Name, working)
+
+		err = step(c.Recursive, &newEnv, next)
+
+		// the documents of the previous step are not needed anymore
+		e := cleanup()
+		working, cleanup = next, nextCleanup
+		if err != nil {
+			return err
+		}
+		if e != nil {
+			return e
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if closed {
+		return errors.WithStack(ErrStreamClosed)
+	}
+
+	return nil
+}
+
+func (c *CommonTableExpr) String() string {
+	var sb strings.Builder
+
+	sb.WriteString(c.Name)
+	sb.WriteString(" AS ")
+	if c.Materialized {
+		sb.WriteString("MATERIALIZED ")
+	}
+	sb.WriteByte('(')
+	sb.WriteString(c.Stream.String())
+	if c.Recursive != nil {
+		if c.Distinct {
+			sb.WriteString(" UNION ")
+		} else {
+			sb.WriteString(" UNION ALL ")
+		}
+		sb.WriteString(c.Recursive.String())
+	}
+	sb.WriteByte(')')
+
+	return sb.String()
+}
+
+// A CTEScanOperator iterates over the documents of a common table expression.
+type CTEScanOperator struct {
+	baseOperator
+	CTE *CommonTableExpr
+}
+
+// CTEScan creates an iterator that iterates over the documents of a common table expression.
+// The documents are read from the temporary tree bound to the name of the expression, if any,
+// otherwise the expression is evaluated.
+func CTEScan(cte *CommonTableExpr) *CTEScanOperator {
+	return &CTEScanOperator{CTE: cte}
+}
+
+// Iterate implements the Operator interface.
+func (op *CTEScanOperator) Iterate(in *environment.Environment, fn func(out *environment.Environment) error) error {
+	var newEnv environment.Environment
+	newEnv.SetOuter(in)
+
+	if tr, ok := in.GetTree(op.CTE.Name); ok {
+		err := tr.IterateOnRange(nil, false, func(k tree.Key, v types.Value) error {
+			newEnv.SetDocument(v.V().(types.Document))
+
+			return fn(&newEnv)
+		})
+		if errors.Is(err, ErrStreamClosed) {
+			err = nil
+		}
+		return err
+	}
+
+	if op.CTE.Materialized {
+		return errors.Errorf("common table expression %q not materialized", op.CTE.Name)
+	}
+
+	// only the documents are returned, not the environment
+	// they were produced in
+	err := op.CTE.Iterate(in, func(out *environment.Environment) error {
+		d, ok := out.GetDocument()
+		if !ok {
+			return errors.New("missing document")
+		}
+
+		newEnv.SetDocument(d)
+		return fn(&newEnv)
+	})
+	if errors.Is(err, ErrStreamClosed) {
+		err = nil
+	}
+	return err
+}
+
+func (op *CTEScanOperator) String() string {
+	return fmt.Sprintf("cte.Scan(%s)", op.CTE.Name)
+}
+
+// A WithOperator evaluates the materialized common table expressions
+// of a statement before iterating over its stream.
+type WithOperator struct {
+	baseOperator
+	CTEs   []*CommonTableExpr
+	Stream *Stream
+}
+
+// With creates an operator that iterates over s, which can read the given common table expressions.
+func With(s *Stream, ctes ...*CommonTableExpr) *WithOperator {
+	return &WithOperator{Stream: s, CTEs: ctes}
+}
+
+// Iterate stores the documents of each materialized expression in a temporary tree,
+// in order, then iterates over the stream.
+func (op *WithOperator) Iterate(in *environment.Environment, fn func(out *environment.Environment) error) error {
+	var newEnv environment.Environment
+	newEnv.SetOuter(in)
+
+	for _, cte := range op.CTEs {
+		if !cte.Materialized {
+			continue
+		}
+
+		tr, cleanup, err := database.NewTransientTree(in.GetDB())
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		var counter int64
+		err = cte.Iterate(&newEnv, func(out *environment.Environment) error {
+			d, ok := out.GetDocument()
+			if !ok {
+				return errors.New("missing document")
+			}
+
+			k, err := tree.NewKey(types.NewIntegerValue(counter))
+			if err != nil {
+				return err
+			}
+			counter++
+
+			return tr.Put(k, types.NewDocumentValue(d))
+		})
+		if err != nil {
+			return err
+		}
+
+		newEnv.SetTree(cte.Name, tr)
+	}
+
+	return op.Stream.Iterate(&newEnv, fn)
+}
+
+func (op *WithOperator) String() string {
+	var sb strings.Builder
+
+	sb.WriteString("cte.With(")
+	for _, cte := range op.CTEs {
+		sb.WriteString(cte.String())
+		sb.WriteString(", ")
+	}
+	sb.WriteString(op.Stream.String())
+	sb.WriteByte(')')
+
+	return sb.String()
+}
@@ -0,0 +1,106 @@
+package stream_test
+
+import (
+	"testing"
+
+	"github.com/genjidb/genji/document"
+	"github.com/genjidb/genji/internal/environment"
+	"github.com/genjidb/genji/internal/expr"
+	"github.com/genjidb/genji/internal/sql/parser"
+	"github.com/genjidb/genji/internal/stream"
+	"github.com/genjidb/genji/internal/testutil"
+	"github.com/genjidb/genji/internal/testutil/assert"
+	"github.com/genjidb/genji/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCTE(t *testing.T) {
+	emit := func(docs ...string) *stream.Stream {
+		return stream.New(stream.DocsEmit(testutil.ParseExprs(t, docs...)...))
+	}
+
+	// recursive returns an expression counting from 1 to 3,
+	// whose recursive step adds incr to n
+	recursive := func(incr string, distinct bool) *stream.CommonTableExpr {
+		cte := stream.CommonTableExpr{
+			Name:     "c",
+			Stream:   emit(`{"n": 1}`),
+			Distinct: distinct,
+		}
+		cte.Recursive = stream.New(stream.CTEScan(&cte)).
+			Pipe(stream.DocsFilter(parser.MustParseExpr("n < 3"))).
+			Pipe(stream.DocsProject(&expr.NamedExpr{ExprName: "n", Expr: parser.MustParseExpr("n + " + incr)}))
+		return &cte
+	}
+
+	tests := []struct {
+		name     string
+		cte      *stream.CommonTableExpr
+		pipe     []stream.Operator
+		expected []string
+	}{
+		{"inline", &stream.CommonTableExpr{Name: "c", Stream: emit(`{"a": 1}`, `{"a": 2}`)}, nil, []string{`{"a": 1}`, `{"a": 2}`}},
+		{"materialized", &stream.CommonTableExpr{Name: "c", Stream: emit(`{"a": 1}`, `{"a": 2}`), Materialized: true}, nil, []string{`{"a": 1}`, `{"a": 2}`}},
+		{"recursive", recursive("1", false), nil, []string{`{"n": 1}`, `{"n": 2}`, `{"n": 3}`}},
+		{"recursive/materialized", func() *stream.CommonTableExpr {
+			cte := recursive("1", false)
+			cte.Materialized = true
+			return cte
+		}(), nil, []string{`{"n": 1}`, `{"n": 2}`, `{"n": 3}`}},
+		{"recursive/take", recursive("1", false), []stream.Operator{stream.DocsTake(2)}, []string{`{"n": 1}`, `{"n": 2}`}},
+		{"recursive/distinct", recursive("0", true), nil, []string{`{"n": 1}`}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db, tx, cleanup := testutil.NewTestTx(t)
+			defer cleanup()
+
+			var env environment.Environment
+			env.DB = db
+			env.Tx = tx
+			env.Catalog = db.Catalog
+
+			s := stream.New(stream.CTEScan(test.cte))
+			for _, op := range test.pipe {
+				s = s.Pipe(op)
+			}
+			s = stream.New(stream.With(s, test.cte))
+
+			var got []types.Document
+			err := s.Iterate(&env, func(env *environment.Environment) error {
+				d, ok := env.GetDocument()
+				require.True(t, ok)
+
+				fb := document.NewFieldBuffer()
+				fb.Copy(d)
+				got = append(got, fb)
+				return nil
+			})
+			assert.NoError(t, err)
+
+			require.Equal(t, len(test.expected), len(got))
+			for i := range got {
+				testutil.RequireDocJSONEq(t, got[i], test.expected[i])
+			}
+		})
+	}
+
+	t.Run("not materialized", func(t *testing.T) {
+		var env environment.Environment
+
+		cte := stream.CommonTableExpr{Name: "c", Stream: emit(`{"a": 1}`), Materialized: true}
+		err := stream.New(stream.CTEScan(&cte)).Iterate(&env, func(*environment.Environment) error {
+			return nil
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("String", func(t *testing.T) {
+		cte := recursive("1", true)
+		cte.Materialized = true
+
+		s := stream.With(stream.New(stream.CTEScan(cte)), cte)
+		require.Equal(t, `cte.With(c AS MATERIALIZED (docs.Emit({n: 1}) UNION cte.Scan(c) | docs.Filter(n < 3) | docs.Project(n + 1)), cte.Scan(c))`, s.String())
+	})
+}
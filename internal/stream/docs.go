@@ -1,6 +1,7 @@
 package stream
 
 import (
+	"bytes"
 	"fmt"
 	"strings"
 
@@ -11,6 +12,7 @@ import (
 	"github.com/genjidb/genji/internal/expr"
 	"github.com/genjidb/genji/internal/tree"
 	"github.com/genjidb/genji/types"
+	"github.com/genjidb/genji/types/encoding"
 )
 
 type DocsEmitOperator struct {
@@ -280,49 +282,42 @@ func (op *DocsSkipOperator) String() string {
 type DocsGroupAggregateOperator struct {
 	baseOperator
 	Builders []expr.AggregatorBuilder
-	E        expr.Expr
+	Exprs    []expr.Expr
 }
 
 // DocsGroupAggregate consumes the incoming stream and outputs one value per group.
+// Documents are grouped by the tuple of values of the groupBy expressions.
 // It assumes the stream is sorted by groupBy.
-func DocsGroupAggregate(groupBy expr.Expr, builders ...expr.AggregatorBuilder) *DocsGroupAggregateOperator {
-	return &DocsGroupAggregateOperator{E: groupBy, Builders: builders}
+func DocsGroupAggregate(groupBy []expr.Expr, builders ...expr.AggregatorBuilder) *DocsGroupAggregateOperator {
+	return &DocsGroupAggregateOperator{Exprs: groupBy, Builders: builders}
 }
 
 func (op *DocsGroupAggregateOperator) Iterate(in *environment.Environment, f func(out *environment.Environment) error) error {
-	var lastGroup types.Value
+	var lastGroup []types.Value
 	var ga *groupAggregator
 
-	var groupExpr string
-	if op.E != nil {
-		groupExpr = fmt.Sprintf("%s", op.E)
-	}
-
 	err := op.Prev.Iterate(in, func(out *environment.Environment) error {
-		if op.E == nil {
+		if len(op.Exprs) == 0 {
 			if ga == nil {
-				ga = newGroupAggregator(nil, groupExpr, op.Builders)
+				ga = newGroupAggregator(nil, op.Exprs, op.Builders)
 			}
 
 			return ga.Aggregate(out)
 		}
 
-		group, err := op.E.Eval(out)
+		group, err := op.evalGroup(out)
 		if err != nil {
 			return err
 		}
 
 		// handle the first document of the stream
 		if lastGroup == nil {
-			lastGroup, err = document.CloneValue(group)
-			if err != nil {
-				return err
-			}
-			ga = newGroupAggregator(lastGroup, groupExpr, op.Builders)
+			lastGroup = group
+			ga = newGroupAggregator(lastGroup, op.Exprs, op.Builders)
 			return ga.Aggregate(out)
 		}
 
-		ok, err := types.IsEqual(lastGroup, group)
+		ok, err := groupsAreEqual(lastGroup, group)
 		if err != nil {
 			return err
 		}
@@ -340,12 +335,8 @@ func (op *DocsGroupAggregateOperator) Iterate(in *environment.Environment, f fun
 			return err
 		}
 
-		lastGroup, err = document.CloneValue(group)
-		if err != nil {
-			return err
-		}
-
-		ga = newGroupAggregator(lastGroup, groupExpr, op.Builders)
+		lastGroup = group
+		ga = newGroupAggregator(lastGroup, op.Exprs, op.Builders)
 		return ga.Aggregate(out)
 	})
 	if err != nil {
@@ -358,7 +349,7 @@ func (op *DocsGroupAggregateOperator) Iterate(in *environment.Environment, f fun
 	// we want the following result:
 	// {"COUNT(*)": 0}
 	if ga == nil {
-		ga = newGroupAggregator(nil, "", op.Builders)
+		ga = newGroupAggregator(nil, nil, op.Builders)
 	}
 
 	e, err := ga.Flush(in)
@@ -368,14 +359,48 @@ func (op *DocsGroupAggregateOperator) Iterate(in *environment.Environment, f fun
 	return f(e)
 }
 
+// evalGroup evaluates the group expressions against the document of env.
+// The values are cloned, as they are kept until the end of the group.
+func (op *DocsGroupAggregateOperator) evalGroup(env *environment.Environment) ([]types.Value, error) {
+	group := make([]types.Value, len(op.Exprs))
+
+	for i, e := range op.Exprs {
+		v, err := e.Eval(env)
+		if err != nil {
+			return nil, err
+		}
+
+		group[i], err = document.CloneValue(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return group, nil
+}
+
+func groupsAreEqual(a, b []types.Value) (bool, error) {
+	for i := range a {
+		ok, err := types.IsEqual(a[i], b[i])
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
 func (op *DocsGroupAggregateOperator) String() string {
 	var sb strings.Builder
 
 	sb.WriteString("docs.GroupAggregate(")
-	if op.E != nil {
-		sb.WriteString(op.E.String())
-	} else {
+	switch len(op.Exprs) {
+	case 0:
 		sb.WriteString("NULL")
+	case 1:
+		sb.WriteString(op.Exprs[0].String())
+	default:
+		sb.WriteString(expr.LiteralExprList(op.Exprs).String())
 	}
 
 	for _, agg := range op.Builders {
@@ -391,12 +416,12 @@ func (op *DocsGroupAggregateOperator) String() string {
 // It applies all the aggregators for each documents and returns a new document with the
 // result of the aggregation.
 type groupAggregator struct {
-	group       types.Value
-	groupExpr   string
+	group       []types.Value
+	groupExprs  []expr.Expr
 	aggregators []expr.Aggregator
 }
 
-func newGroupAggregator(group types.Value, groupExpr string, builders []expr.AggregatorBuilder) *groupAggregator {
+func newGroupAggregator(group []types.Value, groupExprs []expr.Expr, builders []expr.AggregatorBuilder) *groupAggregator {
 	newAggregators := make([]expr.Aggregator, len(builders))
 	for i, b := range builders {
 		newAggregators[i] = b.Aggregator()
@@ -405,7 +430,7 @@ func newGroupAggregator(group types.Value, groupExpr string, builders []expr.Agg
 	return &groupAggregator{
 		aggregators: newAggregators,
 		group:       group,
-		groupExpr:   groupExpr,
+		groupExprs:  groupExprs,
 	}
 }
 
@@ -423,9 +448,10 @@ func (g *groupAggregator) Aggregate(env *environment.Environment) error {
 func (g *groupAggregator) Flush(env *environment.Environment) (*environment.Environment, error) {
 	fb := document.NewFieldBuffer()
 
-	// add the current group to the document
-	if g.groupExpr != "" {
-		fb.Add(g.groupExpr, g.group)
+	// add the current group to the document,
+	// each value in a field named after its expression
+	for i, v := range g.group {
+		fb.Add(g.groupExprs[i].String(), v)
 	}
 
 	for _, agg := range g.aggregators {
@@ -443,22 +469,386 @@ func (g *groupAggregator) Flush(env *environment.Environment) (*environment.Envi
 	return &newEnv, nil
 }
 
-// A DocsTempTreeSortOperator consumes every value of the stream and outputs them in order.
-type DocsTempTreeSortOperator struct {
+// A DocsGroupingSetsOperator groups the documents of the stream by several sets of expressions,
+// and outputs one value per group of each set.
+type DocsGroupingSetsOperator struct {
 	baseOperator
+	// Exprs are all the expressions used by the sets.
+	Exprs []expr.Expr
+	// Sets are subsets of Exprs.
+	Sets     [][]expr.Expr
+	Builders []expr.AggregatorBuilder
+}
+
+// DocsGroupingSets consumes the incoming stream and outputs one value per group, for each
+// of the given sets, in order. The value of the expressions that are not part of a set
+// is NULL in the values of the groups of that set.
+// It creates a temporary tree, in which each document is stored once per set,
+// and uses it to sort the documents by set and group.
+func DocsGroupingSets(exprs []expr.Expr, sets [][]expr.Expr, builders ...expr.AggregatorBuilder) *DocsGroupingSetsOperator {
+	return &DocsGroupingSetsOperator{Exprs: exprs, Sets: sets, Builders: builders}
+}
+
+func (op *DocsGroupingSetsOperator) Iterate(in *environment.Environment, f func(out *environment.Environment) error) error {
+	db := in.GetDB()
+
+	tr, cleanup, err := database.NewTransientTree(db)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	// for each set, whether each expression is part of it
+	masks := make([][]bool, len(op.Sets))
+	for i, set := range op.Sets {
+		masks[i] = make([]bool, len(op.Exprs))
+		for j, e := range op.Exprs {
+			for _, se := range set {
+				if expr.Equal(e, se) {
+					masks[i][j] = true
+					break
+				}
+			}
+		}
+	}
+
+	var counter int64
+	// names of the tables, if the documents come from a join
+	var names []string
+
+	err = op.Prev.Iterate(in, func(out *environment.Environment) error {
+		values := make([]types.Value, len(op.Exprs))
+		for i, e := range op.Exprs {
+			v, err := e.Eval(out)
+			if err != nil {
+				return err
+			}
+			values[i] = v
+		}
+
+		doc, ok := out.GetDocument()
+		if !ok {
+			panic("missing document")
+		}
+
+		if jd, ok := doc.(*JoinedDocument); ok && names == nil {
+			names = append([]string(nil), jd.Names...)
+		}
+
+		tableName, _ := out.Get(environment.TableKey)
+		key, _ := out.Get(environment.DocPKKey)
+
+		for i, mask := range masks {
+			group := document.NewValueBuffer()
+			for j, v := range values {
+				if !mask[j] {
+					v = types.NewNullValue()
+				}
+				group.Append(v)
+			}
+
+			tk, err := tree.NewKey(types.NewIntegerValue(int64(i)), types.NewArrayValue(group), tableName, key, types.NewIntegerValue(counter))
+			if err != nil {
+				return err
+			}
+
+			err = tr.Put(tk, types.NewDocumentValue(doc))
+			if err != nil {
+				return err
+			}
+		}
+
+		counter++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var newEnv environment.Environment
+	newEnv.SetOuter(in)
+
+	lastSet := int64(-1)
+	var lastGroup []types.Value
+	var ga *groupAggregator
+
+	// flush emits the current group, if any, and the groups of the empty sets
+	// between the set of the current group and the given set, which have no documents.
+	flush := func(set int64) error {
+		if ga != nil {
+			e, err := ga.Flush(in)
+			if err != nil {
+				return err
+			}
+			err = f(e)
+			if err != nil {
+				return err
+			}
+			ga = nil
+		}
+
+		for i := lastSet + 1; i < set; i++ {
+			if len(op.Sets[i]) > 0 {
+				continue
+			}
+
+			nulls := make([]types.Value, len(op.Exprs))
+			for j := range nulls {
+				nulls[j] = types.NewNullValue()
+			}
+
+			e, err := newGroupAggregator(nulls, op.Exprs, op.Builders).Flush(in)
+			if err != nil {
+				return err
+			}
+			err = f(e)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	err = tr.IterateOnRange(nil, false, func(k tree.Key, v types.Value) error {
+		kv, err := k.Decode()
+		if err != nil {
+			return err
+		}
+
+		set := kv[0].V().(int64)
+
+		groupValue, err := document.CloneValue(kv[1])
+		if err != nil {
+			return err
+		}
+		group := groupValue.V().(*document.ValueBuffer).Values
+
+		if ga != nil && set == lastSet {
+			ok, err := groupsAreEqual(lastGroup, group)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				err = flush(set)
+				if err != nil {
+					return err
+				}
+			}
+		} else {
+			err = flush(set)
+			if err != nil {
+				return err
+			}
+		}
+
+		if ga == nil {
+			lastSet = set
+			lastGroup = group
+			ga = newGroupAggregator(group, op.Exprs, op.Builders)
+		}
+
+		tableName := kv[2]
+		if tableName.Type() != types.NullValue {
+			newEnv.Set(environment.TableKey, tableName)
+		}
+
+		docKey := kv[3]
+		if docKey.Type() != types.NullValue {
+			newEnv.Set(environment.DocPKKey, docKey)
+		}
+
+		doc := v.V().(types.Document)
+
+		if names != nil {
+			jd, err := rejoin(names, doc)
+			if err != nil {
+				return err
+			}
+			jd.setNames(&newEnv)
+			doc = jd
+		}
+
+		newEnv.SetDocument(doc)
+
+		return ga.Aggregate(&newEnv)
+	})
+	if err != nil {
+		return err
+	}
+
+	return flush(int64(len(op.Sets)))
+}
+
+func (op *DocsGroupingSetsOperator) String() string {
+	var sb strings.Builder
+
+	sb.WriteString("docs.GroupingSets(")
+	for i, set := range op.Sets {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+
+		sb.WriteByte('(')
+		for j, e := range set {
+			if j > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(e.String())
+		}
+		sb.WriteByte(')')
+	}
+
+	for _, agg := range op.Builders {
+		sb.WriteString(", ")
+		sb.WriteString(agg.(fmt.Stringer).String())
+	}
+
+	sb.WriteString(")")
+	return sb.String()
+}
+
+// A SortKey is an expression used to sort documents,
+// in ascending order unless Desc is true.
+type SortKey struct {
 	Expr expr.Expr
 	Desc bool
+	// NullsLast is true if NULL values are sorted after the other values.
+	// By default, they are sorted first in ascending order and last in descending order.
+	NullsLast bool
+}
+
+// hasDefaultNulls returns true if NULL values are sorted at their default position.
+func (k SortKey) hasDefaultNulls() bool {
+	return k.NullsLast == k.Desc
+}
+
+func (k SortKey) String() string {
+	s := k.Expr.String()
+	if k.Desc {
+		s += " DESC"
+	}
+
+	if !k.hasDefaultNulls() {
+		if k.NullsLast {
+			s += " NULLS LAST"
+		} else {
+			s += " NULLS FIRST"
+		}
+	}
+
+	return s
+}
+
+// appendSortValue appends to vb the values stored in the key of a temporary tree to sort
+// documents by the given value. Values sorted in descending order are replaced
+// by a blob whose encoding is sorted in the reverse order of the one of the value.
+// If NULL values are not sorted at their default position, the value is preceded
+// by an integer sorting NULL values before or after the others.
+func (k SortKey) appendSortValue(vb *document.ValueBuffer, v types.Value) error {
+	if !k.hasDefaultNulls() {
+		isNull := v.Type() == types.NullValue
+		if isNull == k.NullsLast {
+			vb.Append(types.NewIntegerValue(1))
+		} else {
+			vb.Append(types.NewIntegerValue(0))
+		}
+	}
+
+	if !k.Desc {
+		vb.Append(v)
+		return nil
+	}
+
+	var buf bytes.Buffer
+	err := encoding.EncodeValue(&buf, v)
+	if err != nil {
+		return err
+	}
+
+	b := buf.Bytes()
+	for i := range b {
+		b[i] = ^b[i]
+	}
+	// the encoding of a value can be a prefix of the encoding of another one,
+	// i.e. with texts, the terminator makes sure the longest one comes first
+	b = append(b, 0xFF)
+
+	vb.Append(types.NewBlobValue(b))
+	return nil
+}
+
+// A DocsTempTreeSortOperator consumes every value of the stream and outputs them in order.
+type DocsTempTreeSortOperator struct {
+	baseOperator
+	Keys []SortKey
 }
 
 // DocsTempTreeSort consumes every value of the stream, sorts them by the given expr and outputs them in order.
 // It creates a temporary index and uses it to sort the stream.
 func DocsTempTreeSort(e expr.Expr) *DocsTempTreeSortOperator {
-	return &DocsTempTreeSortOperator{Expr: e}
+	return DocsTempTreeSortKeys(SortKey{Expr: e})
 }
 
 // DocsTempTreeSortReverse does the same as TempTreeSort but in descending order.
 func DocsTempTreeSortReverse(e expr.Expr) *DocsTempTreeSortOperator {
-	return &DocsTempTreeSortOperator{Expr: e, Desc: true}
+	return DocsTempTreeSortKeys(SortKey{Expr: e, Desc: true, NullsLast: true})
+}
+
+// DocsTempTreeSortKeys does the same as TempTreeSort but sorts the documents by several keys,
+// each one in its own direction.
+func DocsTempTreeSortKeys(keys ...SortKey) *DocsTempTreeSortOperator {
+	return &DocsTempTreeSortOperator{Keys: keys}
+}
+
+// IsReverse returns true if the documents are sorted in descending order
+// by each key, with NULL values at their default position.
+// The temporary tree is then read in reverse order.
+func (op *DocsTempTreeSortOperator) IsReverse() bool {
+	return op.IsUniform() && op.Keys[0].Desc
+}
+
+// IsUniform returns true if all the keys are sorted in the same direction
+// with NULL values at their default position.
+func (op *DocsTempTreeSortOperator) IsUniform() bool {
+	for _, k := range op.Keys {
+		if k.Desc != op.Keys[0].Desc || !k.hasDefaultNulls() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sortValue returns the first value of the key of a document in the temporary tree.
+func (op *DocsTempTreeSortOperator) sortValue(env *environment.Environment) (types.Value, error) {
+	uniform := op.IsUniform()
+
+	if uniform && len(op.Keys) == 1 {
+		return op.Keys[0].Expr.Eval(env)
+	}
+
+	vb := document.NewValueBuffer()
+	for _, k := range op.Keys {
+		v, err := k.Expr.Eval(env)
+		if err != nil {
+			return nil, err
+		}
+
+		// if all keys are sorted in the same direction, the tree is read
+		// in that direction and the values are stored as is
+		if uniform {
+			vb.Append(v)
+			continue
+		}
+
+		err = k.appendSortValue(vb, v)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return types.NewArrayValue(vb), nil
 }
 
 func (op *DocsTempTreeSortOperator) Iterate(in *environment.Environment, fn func(out *environment.Environment) error) error {
@@ -471,10 +861,12 @@ func (op *DocsTempTreeSortOperator) Iterate(in *environment.Environment, fn func
 	defer cleanup()
 
 	var counter int64
+	// names of the tables, if the documents come from a join
+	var names []string
 
 	err = op.Prev.Iterate(in, func(out *environment.Environment) error {
-		// evaluate the sort expression
-		v, err := op.Expr.Eval(out)
+		// evaluate the sort expressions
+		v, err := op.sortValue(out)
 		if err != nil {
 			return err
 		}
@@ -484,6 +876,10 @@ func (op *DocsTempTreeSortOperator) Iterate(in *environment.Environment, fn func
 			panic("missing document")
 		}
 
+		if jd, ok := doc.(*JoinedDocument); ok && names == nil {
+			names = append([]string(nil), jd.Names...)
+		}
+
 		tableName, _ := out.Get(environment.TableKey)
 
 		key, _ := out.Get(environment.DocPKKey)
@@ -504,7 +900,7 @@ func (op *DocsTempTreeSortOperator) Iterate(in *environment.Environment, fn func
 	var newEnv environment.Environment
 	newEnv.SetOuter(in)
 
-	return tr.IterateOnRange(nil, op.Desc, func(k tree.Key, v types.Value) error {
+	return tr.IterateOnRange(nil, op.IsReverse(), func(k tree.Key, v types.Value) error {
 		kv, err := k.Decode()
 		if err != nil {
 			return err
@@ -522,6 +918,15 @@ func (op *DocsTempTreeSortOperator) Iterate(in *environment.Environment, fn func
 
 		doc := v.V().(types.Document)
 
+		if names != nil {
+			jd, err := rejoin(names, doc)
+			if err != nil {
+				return err
+			}
+			jd.setNames(&newEnv)
+			doc = jd
+		}
+
 		newEnv.SetDocument(doc)
 
 		return fn(&newEnv)
@@ -529,9 +934,24 @@ func (op *DocsTempTreeSortOperator) Iterate(in *environment.Environment, fn func
 }
 
 func (op *DocsTempTreeSortOperator) String() string {
-	if op.Desc {
-		return fmt.Sprintf("docs.TempTreeSortReverse(%s)", op.Expr)
+	if len(op.Keys) == 1 && op.IsUniform() {
+		if op.Keys[0].Desc {
+			return fmt.Sprintf("docs.TempTreeSortReverse(%s)", op.Keys[0].Expr)
+		}
+
+		return fmt.Sprintf("docs.TempTreeSort(%s)", op.Keys[0].Expr)
 	}
 
-	return fmt.Sprintf("docs.TempTreeSort(%s)", op.Expr)
+	var sb strings.Builder
+
+	sb.WriteString("docs.TempTreeSort(")
+	for i, k := range op.Keys {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(k.String())
+	}
+	sb.WriteString(")")
+
+	return sb.String()
 }
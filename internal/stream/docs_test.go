@@ -141,7 +141,7 @@ func TestProject(t *testing.T) {
 func TestAggregate(t *testing.T) {
 	tests := []struct {
 		name     string
-		groupBy  expr.Expr
+		groupBy  []expr.Expr
 		builders []expr.AggregatorBuilder
 		in       []types.Document
 		want     []types.Document
@@ -165,7 +165,7 @@ func TestAggregate(t *testing.T) {
 		},
 		{
 			"count/groupBy",
-			parser.MustParseExpr("a % 2"),
+			[]expr.Expr{parser.MustParseExpr("a % 2")},
 			[]expr.AggregatorBuilder{&functions.Count{Expr: parser.MustParseExpr("a")}, &functions.Avg{Expr: parser.MustParseExpr("a")}},
 			generateSeqDocs(t, 10),
 			[]types.Document{testutil.MakeDocument(t, `{"a % 2": 0, "COUNT(a)": 5, "AVG(a)": 4.0}`), testutil.MakeDocument(t, `{"a % 2": 1, "COUNT(a)": 5, "AVG(a)": 5.0}`)},
@@ -181,12 +181,25 @@ func TestAggregate(t *testing.T) {
 		},
 		{
 			"no aggregator",
-			parser.MustParseExpr("a % 2"),
+			[]expr.Expr{parser.MustParseExpr("a % 2")},
 			nil,
 			generateSeqDocs(t, 4),
 			testutil.MakeDocuments(t, `{"a % 2": 0}`, `{"a % 2": 1}`),
 			false,
 		},
+		{
+			"count/groupBy multiple expressions",
+			[]expr.Expr{parser.MustParseExpr("a % 2"), parser.MustParseExpr("a < 5")},
+			[]expr.AggregatorBuilder{&functions.Count{Expr: parser.MustParseExpr("a")}},
+			generateSeqDocs(t, 10),
+			testutil.MakeDocuments(t,
+				`{"a % 2": 0, "a < 5": false, "COUNT(a)": 2}`,
+				`{"a % 2": 0, "a < 5": true, "COUNT(a)": 3}`,
+				`{"a % 2": 1, "a < 5": false, "COUNT(a)": 3}`,
+				`{"a % 2": 1, "a < 5": true, "COUNT(a)": 2}`,
+			),
+			false,
+		},
 	}
 
 	for _, test := range tests {
@@ -207,7 +220,7 @@ func TestAggregate(t *testing.T) {
 
 			s := stream.New(stream.TableScan("test"))
 			if test.groupBy != nil {
-				s = s.Pipe(stream.DocsTempTreeSort(test.groupBy))
+				s = s.Pipe(stream.DocsTempTreeSort(expr.LiteralExprList(test.groupBy)))
 			}
 
 			s = s.Pipe(stream.DocsGroupAggregate(test.groupBy, test.builders...))
@@ -231,9 +244,63 @@ func TestAggregate(t *testing.T) {
 	}
 
 	t.Run("String", func(t *testing.T) {
-		require.Equal(t, `docs.GroupAggregate(a % 2, a(), b())`, stream.DocsGroupAggregate(parser.MustParseExpr("a % 2"), makeAggregatorBuilders("a()", "b()")...).String())
+		require.Equal(t, `docs.GroupAggregate(a % 2, a(), b())`, stream.DocsGroupAggregate([]expr.Expr{parser.MustParseExpr("a % 2")}, makeAggregatorBuilders("a()", "b()")...).String())
 		require.Equal(t, `docs.GroupAggregate(NULL, a(), b())`, stream.DocsGroupAggregate(nil, makeAggregatorBuilders("a()", "b()")...).String())
-		require.Equal(t, `docs.GroupAggregate(a % 2)`, stream.DocsGroupAggregate(parser.MustParseExpr("a % 2")).String())
+		require.Equal(t, `docs.GroupAggregate(a % 2)`, stream.DocsGroupAggregate([]expr.Expr{parser.MustParseExpr("a % 2")}).String())
+		require.Equal(t, `docs.GroupAggregate([a, b.c], a())`, stream.DocsGroupAggregate([]expr.Expr{parser.MustParseExpr("a"), parser.MustParseExpr("b.c")}, makeAggregatorBuilders("a()")...).String())
+	})
+}
+
+func TestGroupingSets(t *testing.T) {
+	db, tx, cleanup := testutil.NewTestTx(t)
+	defer cleanup()
+
+	testutil.MustExec(t, db, tx, "CREATE TABLE test(a int)")
+
+	for _, doc := range generateSeqDocs(t, 6) {
+		testutil.MustExec(t, db, tx, "INSERT INTO test VALUES ?", environment.Param{Value: doc})
+	}
+
+	var env environment.Environment
+	env.DB = db
+	env.Tx = tx
+	env.Catalog = db.Catalog
+
+	even := parser.MustParseExpr("a % 2")
+	small := parser.MustParseExpr("a < 2")
+	op := stream.DocsGroupingSets(
+		[]expr.Expr{even, small},
+		[][]expr.Expr{{even, small}, {even}, nil},
+		&functions.Count{Wildcard: true},
+	)
+
+	var got []types.Document
+	err := stream.New(stream.TableScan("test")).Pipe(op).Iterate(&env, func(env *environment.Environment) error {
+		d, ok := env.GetDocument()
+		require.True(t, ok)
+		var fb document.FieldBuffer
+		fb.Copy(d)
+		got = append(got, &fb)
+		return nil
+	})
+	assert.NoError(t, err)
+
+	want := testutil.MakeDocuments(t,
+		`{"a % 2": 0, "a < 2": false, "COUNT(*)": 2}`,
+		`{"a % 2": 0, "a < 2": true, "COUNT(*)": 1}`,
+		`{"a % 2": 1, "a < 2": false, "COUNT(*)": 2}`,
+		`{"a % 2": 1, "a < 2": true, "COUNT(*)": 1}`,
+		`{"a % 2": 0, "a < 2": null, "COUNT(*)": 3}`,
+		`{"a % 2": 1, "a < 2": null, "COUNT(*)": 3}`,
+		`{"a % 2": null, "a < 2": null, "COUNT(*)": 6}`,
+	)
+	require.Equal(t, len(want), len(got))
+	for i := range got {
+		testutil.RequireDocEqual(t, want[i], got[i])
+	}
+
+	t.Run("String", func(t *testing.T) {
+		require.Equal(t, `docs.GroupingSets((a % 2, a < 2), (a % 2), (), COUNT(*))`, op.String())
 	})
 }
 
@@ -376,5 +443,73 @@ func TestTempTreeSort(t *testing.T) {
 
 	t.Run("String", func(t *testing.T) {
 		require.Equal(t, `docs.TempTreeSort(a)`, stream.DocsTempTreeSort(parser.MustParseExpr("a")).String())
+		require.Equal(t, `docs.TempTreeSort(a, b)`, stream.DocsTempTreeSortKeys(
+			stream.SortKey{Expr: parser.MustParseExpr("a")},
+			stream.SortKey{Expr: parser.MustParseExpr("b")},
+		).String())
+		require.Equal(t, `docs.TempTreeSort(a DESC, b NULLS LAST)`, stream.DocsTempTreeSortKeys(
+			stream.SortKey{Expr: parser.MustParseExpr("a"), Desc: true, NullsLast: true},
+			stream.SortKey{Expr: parser.MustParseExpr("b"), NullsLast: true},
+		).String())
 	})
 }
+
+func TestTempTreeSortKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []stream.SortKey
+		want []string
+	}{
+		{
+			"ASC, ASC",
+			[]stream.SortKey{{Expr: parser.MustParseExpr("a")}, {Expr: parser.MustParseExpr("b")}},
+			[]string{`{"a": null, "b": 3}`, `{"a": 1, "b": null}`, `{"a": 1, "b": 1}`, `{"a": 1, "b": 2}`, `{"a": 2, "b": 1}`},
+		},
+		{
+			"DESC, ASC",
+			[]stream.SortKey{{Expr: parser.MustParseExpr("a"), Desc: true, NullsLast: true}, {Expr: parser.MustParseExpr("b")}},
+			[]string{`{"a": 2, "b": 1}`, `{"a": 1, "b": null}`, `{"a": 1, "b": 1}`, `{"a": 1, "b": 2}`, `{"a": null, "b": 3}`},
+		},
+		{
+			"NULLS LAST, DESC NULLS FIRST",
+			[]stream.SortKey{{Expr: parser.MustParseExpr("a"), NullsLast: true}, {Expr: parser.MustParseExpr("b"), Desc: true}},
+			[]string{`{"a": 1, "b": null}`, `{"a": 1, "b": 2}`, `{"a": 1, "b": 1}`, `{"a": 2, "b": 1}`, `{"a": null, "b": 3}`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db, tx, cleanup := testutil.NewTestTx(t)
+			defer cleanup()
+
+			testutil.MustExec(t, db, tx, `
+				CREATE TABLE test(a int, b int);
+				INSERT INTO test (a, b) VALUES (1, 2), (null, 3), (2, 1), (1, null), (1, 1);
+			`)
+
+			var env environment.Environment
+			env.DB = db
+			env.Tx = tx
+			env.Catalog = db.Catalog
+
+			s := stream.New(stream.TableScan("test")).Pipe(stream.DocsTempTreeSortKeys(test.keys...))
+
+			var got []types.Document
+			err := s.Iterate(&env, func(env *environment.Environment) error {
+				d, ok := env.GetDocument()
+				require.True(t, ok)
+
+				fb := document.NewFieldBuffer()
+				fb.Copy(d)
+				got = append(got, fb)
+				return nil
+			})
+			assert.NoError(t, err)
+
+			require.Equal(t, len(test.want), len(got))
+			for i := range got {
+				testutil.RequireDocEqual(t, testutil.MakeDocument(t, test.want[i]), got[i])
+			}
+		})
+	}
+}
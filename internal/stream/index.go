@@ -9,6 +9,7 @@ import (
 	errs "github.com/genjidb/genji/errors"
 	"github.com/genjidb/genji/internal/database"
 	"github.com/genjidb/genji/internal/environment"
+	"github.com/genjidb/genji/internal/lock"
 	"github.com/genjidb/genji/internal/tree"
 	"github.com/genjidb/genji/types"
 )
@@ -24,6 +25,9 @@ type IndexScanOperator struct {
 	Ranges Ranges
 	// Reverse indicates the direction used to traverse the index.
 	Reverse bool
+	// ForUpdate locks each document before it is read by the rest of the stream.
+	// Documents whose index entry was removed while waiting for the lock are skipped.
+	ForUpdate bool
 }
 
 // IndexScan creates an iterator that iterates over each document of the given table.
@@ -88,18 +92,27 @@ func (it *IndexScanOperator) Iterate(in *environment.Environment, fn func(out *e
 	}
 	newEnv.SetDocument(&ptr)
 
-	if len(it.Ranges) == 0 {
-		return index.Iterate(it.Reverse, func(key tree.Key) error {
-			ptr.key = key
-			ptr.Doc = nil
-			newEnv.Set(environment.DocPKKey, types.NewBlobValue(key))
+	visit := func(itmKey, key tree.Key) error {
+		if it.ForUpdate {
+			ok, err := lockIndexedDocument(tx, index, table.Info.Name(), itmKey, key)
+			if err != nil || !ok {
+				return err
+			}
+		}
+
+		ptr.key = key
+		ptr.Doc = nil
+		newEnv.Set(environment.DocPKKey, types.NewBlobValue(key))
 
-			return fn(&newEnv)
-		})
+		return fn(&newEnv)
+	}
+
+	if len(it.Ranges) == 0 {
+		return index.IterateEntriesOnRange(nil, it.Reverse, visit)
 	}
 
 	ranges, err := it.Ranges.Eval(in)
-	if err != nil || len(ranges) != len(it.Ranges) {
+	if err != nil {
 		return err
 	}
 
@@ -109,13 +122,7 @@ func (it *IndexScanOperator) Iterate(in *environment.Environment, fn func(out *e
 			return err
 		}
 
-		err = index.IterateOnRange(r, it.Reverse, func(key tree.Key) error {
-			ptr.key = key
-			ptr.Doc = nil
-			newEnv.Set(environment.DocPKKey, types.NewBlobValue(key))
-
-			return fn(&newEnv)
-		})
+		err = index.IterateEntriesOnRange(r, it.Reverse, visit)
 		if errors.Is(err, ErrStreamClosed) {
 			err = nil
 		}
@@ -183,6 +190,13 @@ func (op *IndexValidateOperator) Iterate(in *environment.Environment, fn func(ou
 		}
 
 		if !hasNull {
+			// lock the indexed value to prevent concurrent transactions
+			// from inserting the same value.
+			err := lockIndexValue(tx, op.indexName, vs)
+			if err != nil {
+				return err
+			}
+
 			duplicate, key, err := idx.Exists(vs)
 			if err != nil {
 				return err
@@ -251,6 +265,15 @@ func (op *IndexInsertOperator) Iterate(in *environment.Environment, fn func(out
 			vs = append(vs, v)
 		}
 
+		if info.Unique {
+			err = lockIndexValue(tx, op.indexName, vs)
+		} else {
+			err = tx.Lock(lock.NewTableObject(op.indexName), lock.IX)
+		}
+		if err != nil {
+			return err
+		}
+
 		err = idx.Set(vs, key.V().([]byte))
 		if err != nil {
 			return fmt.Errorf("error while inserting index value: %w", err)
@@ -323,6 +346,15 @@ func (op *IndexDeleteOperator) Iterate(in *environment.Environment, fn func(out
 			vs = append(vs, v)
 		}
 
+		if info.Unique {
+			err = lockIndexValue(tx, op.indexName, vs)
+		} else {
+			err = tx.Lock(lock.NewTableObject(op.indexName), lock.IX)
+		}
+		if err != nil {
+			return err
+		}
+
 		err = idx.Delete(vs, key)
 		if err != nil {
 			return err
@@ -336,6 +368,36 @@ func (op *IndexDeleteOperator) String() string {
 	return fmt.Sprintf("index.Delete(%q)", op.indexName)
 }
 
+// lockIndexValue acquires an intent exclusive lock on the index
+// and an exclusive lock on the given indexed values.
+// Locks are held until the transaction is committed or rolled back.
+func lockIndexValue(tx *database.Transaction, indexName string, vs []types.Value) error {
+	err := tx.Lock(lock.NewTableObject(indexName), lock.IX)
+	if err != nil {
+		return err
+	}
+
+	k, err := tree.NewKey(vs...)
+	if err != nil {
+		return err
+	}
+
+	return tx.Lock(lock.NewDocumentObject(indexName, k), lock.X)
+}
+
+// lockIndexedDocument locks the document identified by key, then ensures
+// the index entry that references it still exists: another transaction might have
+// modified or deleted the document before the lock was acquired.
+// It returns false if the entry doesn't exist anymore.
+func lockIndexedDocument(tx *database.Transaction, idx *database.Index, tableName string, itmKey, key tree.Key) (bool, error) {
+	err := lockDocument(tx, tableName, key)
+	if err != nil {
+		return false, err
+	}
+
+	return idx.Tree.Exists(itmKey)
+}
+
 // DocumentPointer holds a document key and lazily loads the document on demand when the Iterate or GetByField method is called.
 // It implements the types.Document and the document.Keyer interfaces.
 type DocumentPointer struct {
@@ -0,0 +1,319 @@
+package stream
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/genjidb/genji/document"
+	"github.com/genjidb/genji/internal/environment"
+	"github.com/genjidb/genji/internal/expr"
+	"github.com/genjidb/genji/types"
+)
+
+// A JoinedDocument holds the documents of one or more tables, each one
+// identified by the name or the alias of its table.
+// The document of a table can be selected using its name, i.e. "t.a" selects
+// the field "a" of the table "t". Other fields are looked up in each document,
+// and must not be found in more than one of them.
+// A JoinedDocument of a single table iterates over the fields of its document,
+// otherwise it iterates over the document of each table, by name.
+type JoinedDocument struct {
+	Names []string
+	// Docs contains one document per name.
+	// A nil document is used when a LEFT JOIN didn't find any match.
+	Docs []types.Document
+}
+
+// GetByField implements the types.Document interface.
+func (d *JoinedDocument) GetByField(field string) (types.Value, error) {
+	for i, name := range d.Names {
+		if name == field {
+			return documentOrNull(d.Docs[i]), nil
+		}
+	}
+
+	var found types.Value
+	for _, doc := range d.Docs {
+		if doc == nil {
+			continue
+		}
+
+		v, err := doc.GetByField(field)
+		if errors.Is(err, types.ErrFieldNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if found != nil {
+			return nil, fmt.Errorf("ambiguous column %q, use the name of the table to select it", field)
+		}
+		found = v
+	}
+
+	if found == nil {
+		return nil, types.ErrFieldNotFound
+	}
+
+	return found, nil
+}
+
+// Iterate implements the types.Document interface.
+func (d *JoinedDocument) Iterate(fn func(field string, value types.Value) error) error {
+	if len(d.Docs) == 1 {
+		if d.Docs[0] == nil {
+			return nil
+		}
+		return d.Docs[0].Iterate(fn)
+	}
+
+	for i, name := range d.Names {
+		v := types.NewNullValue()
+		if d.Docs[i] != nil {
+			// the documents are reused by the operators that produced them,
+			// copy them to make sure the returned values remain valid
+			fb := document.NewFieldBuffer()
+			err := fb.Copy(d.Docs[i])
+			if err != nil {
+				return err
+			}
+			v = types.NewDocumentValue(fb)
+		}
+
+		err := fn(name, v)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (d *JoinedDocument) MarshalJSON() ([]byte, error) {
+	return document.MarshalJSON(d)
+}
+
+// rejoin turns a document produced by iterating over a JoinedDocument with the given names,
+// for example after being stored in a temporary tree, into a JoinedDocument.
+func rejoin(names []string, doc types.Document) (*JoinedDocument, error) {
+	jd := JoinedDocument{
+		Names: names,
+		Docs:  make([]types.Document, len(names)),
+	}
+
+	if len(names) == 1 {
+		jd.Docs[0] = doc
+		return &jd, nil
+	}
+
+	for i, name := range names {
+		v, err := doc.GetByField(name)
+		if err != nil {
+			return nil, err
+		}
+		if v.Type() == types.DocumentValue {
+			jd.Docs[i] = v.V().(types.Document)
+		}
+	}
+
+	return &jd, nil
+}
+
+func documentOrNull(d types.Document) types.Value {
+	if d == nil {
+		return types.NewNullValue()
+	}
+
+	return types.NewDocumentValue(d)
+}
+
+// setNames stores the documents of d in the environment, by name, so that they can be
+// referenced by the operators that don't have access to the document, like the ones of the
+// right stream of a join, or the ones following a projection.
+func (d *JoinedDocument) setNames(env *environment.Environment) {
+	for i, name := range d.Names {
+		env.Set(document.Path{document.PathFragment{FieldName: name}}, documentOrNull(d.Docs[i]))
+	}
+}
+
+// A DocsAliasOperator names the documents of the stream using
+// the name or the alias of their table.
+type DocsAliasOperator struct {
+	baseOperator
+	Name string
+}
+
+// DocsAlias creates an operator that names each document of the stream.
+// Its output is a JoinedDocument, which allows selecting fields using the name
+// of the table, i.e. "t.a", and which can be joined with other tables.
+func DocsAlias(name string) *DocsAliasOperator {
+	return &DocsAliasOperator{Name: name}
+}
+
+// Iterate implements the Operator interface.
+func (op *DocsAliasOperator) Iterate(in *environment.Environment, fn func(out *environment.Environment) error) error {
+	var newEnv environment.Environment
+	jd := JoinedDocument{
+		Names: []string{op.Name},
+		Docs:  make([]types.Document, 1),
+	}
+
+	return op.Prev.Iterate(in, func(out *environment.Environment) error {
+		d, ok := out.GetDocument()
+		if !ok {
+			return errors.New("missing document")
+		}
+
+		jd.Docs[0] = d
+		newEnv.SetOuter(out)
+		jd.setNames(&newEnv)
+		newEnv.SetDocument(&jd)
+
+		return fn(&newEnv)
+	})
+}
+
+func (op *DocsAliasOperator) String() string {
+	return fmt.Sprintf("docs.Alias(%s)", op.Name)
+}
+
+// A JoinOperator joins each document of the stream with the documents of another stream
+// using a nested loop: for each document of the stream, the right stream is iterated
+// and every document for which the On condition is truthy is joined with the document of the stream.
+// The right stream is iterated using the environment of the document of the stream,
+// which allows it to read from an index using the values of that document.
+// Each document of both streams must be a JoinedDocument.
+type JoinOperator struct {
+	baseOperator
+	Right *Stream
+	// On is evaluated for each pair of documents.
+	// If nil, every pair is returned.
+	On expr.Expr
+	// If true, documents without any match are returned once,
+	// joined with NULL documents (LEFT JOIN).
+	Left bool
+}
+
+// Join creates an operator that returns the documents of the stream joined
+// with the documents of the right stream that satisfy the on condition.
+func Join(right *Stream, on expr.Expr) *JoinOperator {
+	return &JoinOperator{Right: right, On: on}
+}
+
+// LeftJoin does the same as Join but also returns the documents without any match,
+// joined with NULL documents.
+func LeftJoin(right *Stream, on expr.Expr) *JoinOperator {
+	return &JoinOperator{Right: right, On: on, Left: true}
+}
+
+// Iterate implements the Operator interface.
+func (op *JoinOperator) Iterate(in *environment.Environment, fn func(out *environment.Environment) error) error {
+	var newEnv environment.Environment
+	var jd JoinedDocument
+	var closed bool
+
+	emit := func(out *environment.Environment, right *JoinedDocument, on expr.Expr) (bool, error) {
+		d, _ := out.GetDocument()
+		left := d.(*JoinedDocument)
+
+		jd.Names = append(append(jd.Names[:0], left.Names...), right.Names...)
+		jd.Docs = append(append(jd.Docs[:0], left.Docs...), right.Docs...)
+		newEnv.SetOuter(out)
+		right.setNames(&newEnv)
+		newEnv.SetDocument(&jd)
+
+		if on != nil {
+			v, err := on.Eval(&newEnv)
+			if err != nil {
+				return false, err
+			}
+
+			ok, err := types.IsTruthy(v)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+
+		err := fn(&newEnv)
+		if errors.Is(err, ErrStreamClosed) {
+			// scans ignore this error, make sure it's
+			// returned once the right stream is closed
+			closed = true
+		}
+		return true, err
+	}
+
+	return op.Prev.Iterate(in, func(out *environment.Environment) error {
+		d, ok := out.GetDocument()
+		if !ok {
+			return errors.New("missing document")
+		}
+		if _, ok := d.(*JoinedDocument); !ok {
+			return errors.New("cannot join a document without a name")
+		}
+
+		var matched bool
+		err := op.Right.Iterate(out, func(rout *environment.Environment) error {
+			d, ok := rout.GetDocument()
+			if !ok {
+				return errors.New("missing document")
+			}
+			right, ok := d.(*JoinedDocument)
+			if !ok {
+				return errors.New("cannot join a document without a name")
+			}
+
+			ok, err := emit(out, right, op.On)
+			matched = matched || ok
+			return err
+		})
+		if err == nil && closed {
+			err = errors.WithStack(ErrStreamClosed)
+		}
+		if err != nil || matched || !op.Left {
+			return err
+		}
+
+		names := op.rightNames()
+		_, err = emit(out, &JoinedDocument{
+			Names: names,
+			Docs:  make([]types.Document, len(names)),
+		}, nil)
+		return err
+	})
+}
+
+// rightNames returns the names of the documents returned by the right stream.
+func (op *JoinOperator) rightNames() []string {
+	var names []string
+
+	for n := op.Right.First(); n != nil; n = n.GetNext() {
+		if a, ok := n.(*DocsAliasOperator); ok {
+			names = append(names, a.Name)
+		}
+	}
+
+	return names
+}
+
+func (op *JoinOperator) String() string {
+	var sb strings.Builder
+
+	if op.Left {
+		sb.WriteString("join.LeftNestedLoop(")
+	} else {
+		sb.WriteString("join.NestedLoop(")
+	}
+
+	sb.WriteString(op.Right.String())
+	if op.On != nil {
+		sb.WriteString(", ")
+		sb.WriteString(op.On.String())
+	}
+	sb.WriteByte(')')
+
+	return sb.String()
+}
@@ -0,0 +1,146 @@
+package stream_test
+
+import (
+	"testing"
+
+	"github.com/genjidb/genji/document"
+	"github.com/genjidb/genji/internal/environment"
+	"github.com/genjidb/genji/internal/expr"
+	"github.com/genjidb/genji/internal/sql/parser"
+	"github.com/genjidb/genji/internal/stream"
+	"github.com/genjidb/genji/internal/testutil"
+	"github.com/genjidb/genji/internal/testutil/assert"
+	"github.com/genjidb/genji/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocsAlias(t *testing.T) {
+	s := stream.New(stream.DocsEmit(testutil.ParseExprs(t, `{"a": 1}`, `{"a": 2}`)...)).
+		Pipe(stream.DocsAlias("t")).
+		Pipe(stream.DocsProject(testutil.ParseNamedExpr(t, "t.a"), testutil.ParseNamedExpr(t, "a")))
+
+	var got testutil.Docs
+	err := s.Iterate(new(environment.Environment), func(out *environment.Environment) error {
+		d, ok := out.GetDocument()
+		require.True(t, ok)
+
+		clone, err := document.CloneValue(types.NewDocumentValue(d))
+		if err != nil {
+			return err
+		}
+
+		got = append(got, clone.V().(types.Document))
+		return nil
+	})
+	assert.NoError(t, err)
+
+	testutil.MakeDocuments(t, `{"t.a": 1, "a": 1}`, `{"t.a": 2, "a": 2}`).RequireEqual(t, got)
+
+	t.Run("String", func(t *testing.T) {
+		require.Equal(t, `docs.Alias(t)`, stream.DocsAlias("t").String())
+	})
+}
+
+func TestJoin(t *testing.T) {
+	left := testutil.ParseExprs(t, `{"id": 1}`, `{"id": 2}`, `{"id": 3}`)
+	right := testutil.ParseExprs(t, `{"aid": 1, "b": 1}`, `{"aid": 1, "b": 2}`, `{"aid": 2, "b": 3}`)
+
+	tests := []struct {
+		name     string
+		on       expr.Expr
+		isLeft   bool
+		expected testutil.Docs
+	}{
+		{
+			"cross",
+			nil, false,
+			testutil.MakeDocuments(t,
+				`{"l": {"id": 1}, "r": {"aid": 1, "b": 1}}`,
+				`{"l": {"id": 1}, "r": {"aid": 1, "b": 2}}`,
+				`{"l": {"id": 1}, "r": {"aid": 2, "b": 3}}`,
+				`{"l": {"id": 2}, "r": {"aid": 1, "b": 1}}`,
+				`{"l": {"id": 2}, "r": {"aid": 1, "b": 2}}`,
+				`{"l": {"id": 2}, "r": {"aid": 2, "b": 3}}`,
+				`{"l": {"id": 3}, "r": {"aid": 1, "b": 1}}`,
+				`{"l": {"id": 3}, "r": {"aid": 1, "b": 2}}`,
+				`{"l": {"id": 3}, "r": {"aid": 2, "b": 3}}`,
+			),
+		},
+		{
+			"inner",
+			parser.MustParseExpr("l.id = r.aid"), false,
+			testutil.MakeDocuments(t,
+				`{"l": {"id": 1}, "r": {"aid": 1, "b": 1}}`,
+				`{"l": {"id": 1}, "r": {"aid": 1, "b": 2}}`,
+				`{"l": {"id": 2}, "r": {"aid": 2, "b": 3}}`,
+			),
+		},
+		{
+			"left",
+			parser.MustParseExpr("l.id = r.aid"), true,
+			testutil.MakeDocuments(t,
+				`{"l": {"id": 1}, "r": {"aid": 1, "b": 1}}`,
+				`{"l": {"id": 1}, "r": {"aid": 1, "b": 2}}`,
+				`{"l": {"id": 2}, "r": {"aid": 2, "b": 3}}`,
+				`{"l": {"id": 3}, "r": null}`,
+			),
+		},
+		{
+			"unqualified paths",
+			parser.MustParseExpr("id = aid AND b > 1"), false,
+			testutil.MakeDocuments(t,
+				`{"l": {"id": 1}, "r": {"aid": 1, "b": 2}}`,
+				`{"l": {"id": 2}, "r": {"aid": 2, "b": 3}}`,
+			),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := stream.New(stream.DocsEmit(right...)).Pipe(stream.DocsAlias("r"))
+			op := stream.Join(r, test.on)
+			if test.isLeft {
+				op = stream.LeftJoin(r, test.on)
+			}
+
+			s := stream.New(stream.DocsEmit(left...)).
+				Pipe(stream.DocsAlias("l")).
+				Pipe(op)
+
+			var got testutil.Docs
+			err := s.Iterate(new(environment.Environment), func(out *environment.Environment) error {
+				d, ok := out.GetDocument()
+				require.True(t, ok)
+
+				clone, err := document.CloneValue(types.NewDocumentValue(d))
+				if err != nil {
+					return err
+				}
+
+				got = append(got, clone.V().(types.Document))
+				return nil
+			})
+			assert.NoError(t, err)
+			test.expected.RequireEqual(t, got)
+		})
+	}
+
+	t.Run("Without name", func(t *testing.T) {
+		s := stream.New(stream.DocsEmit(left...)).
+			Pipe(stream.Join(stream.New(stream.DocsEmit(right...)).Pipe(stream.DocsAlias("r")), nil))
+
+		err := s.Iterate(new(environment.Environment), func(out *environment.Environment) error {
+			return nil
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("String", func(t *testing.T) {
+		r := stream.New(stream.TableScan("b")).Pipe(stream.DocsAlias("b"))
+
+		require.Equal(t, `join.NestedLoop(table.Scan("b") | docs.Alias(b), a.id = b.aid)`,
+			stream.Join(r, parser.MustParseExpr("a.id = b.aid")).String())
+		require.Equal(t, `join.LeftNestedLoop(table.Scan("b") | docs.Alias(b))`,
+			stream.LeftJoin(r, nil).String())
+	})
+}
@@ -1,12 +1,14 @@
 package stream
 
 import (
+	"sort"
 	"strings"
 
 	"github.com/genjidb/genji/document"
 	"github.com/genjidb/genji/internal/database"
 	"github.com/genjidb/genji/internal/environment"
 	"github.com/genjidb/genji/internal/expr"
+	"github.com/genjidb/genji/types"
 )
 
 // Range represents a range to select values after or before
@@ -22,6 +24,10 @@ type Range struct {
 	// If set to true, Max will be ignored for comparison
 	// and for determining the global upper bound.
 	Exact bool
+	// Used to match exactly each value of the array the last
+	// expression of Min evaluates to, i.e. the result of a subquery.
+	// Exact must be set to true.
+	In bool
 }
 
 func (r *Range) Eval(env *environment.Environment) (*database.Range, error) {
@@ -87,6 +93,13 @@ func (r *Range) String() string {
 		needsComa = true
 	}
 
+	if r.In {
+		if needsComa {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(`"in": true`)
+	}
+
 	sb.WriteByte('}')
 
 	return sb.String()
@@ -101,6 +114,10 @@ func (r *Range) IsEqual(other *Range) bool {
 		return false
 	}
 
+	if r.In != other.In {
+		return false
+	}
+
 	if len(r.Min) != len(other.Min) {
 		return false
 	}
@@ -127,6 +144,15 @@ func (r Ranges) Eval(env *environment.Environment) ([]*database.Range, error) {
 	ranges := make([]*database.Range, 0, len(r))
 
 	for i := range r {
+		if r[i].In {
+			rngs, err := r[i].evalIn(env)
+			if err != nil {
+				return nil, err
+			}
+			ranges = append(ranges, rngs...)
+			continue
+		}
+
 		rng, err := r[i].Eval(env)
 		if err != nil {
 			return nil, err
@@ -139,6 +165,67 @@ func (r Ranges) Eval(env *environment.Environment) ([]*database.Range, error) {
 	return ranges, nil
 }
 
+// evalIn returns one range for each distinct value of the array
+// the last expression of Min evaluates to, sorted in ascending order.
+// NULL values are ignored, as they can't be equal to any value.
+func (r *Range) evalIn(env *environment.Environment) ([]*database.Range, error) {
+	min, err := r.Min.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := min.V().(*document.ValueBuffer).Values
+	n := len(prefix) - 1
+	last := prefix[n]
+	// the capacity is limited to prevent ranges from sharing the same array
+	prefix = prefix[:n:n]
+
+	if last.Type() != types.ArrayValue {
+		return nil, nil
+	}
+
+	var values []types.Value
+	err = last.V().(types.Array).Iterate(func(i int, v types.Value) error {
+		if v.Type() != types.NullValue {
+			values = append(values, v)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(values, func(i, j int) bool {
+		a, b := values[i], values[j]
+		if a.Type() != b.Type() && !(a.Type().IsNumber() && b.Type().IsNumber()) {
+			return a.Type() < b.Type()
+		}
+
+		ok, _ := types.IsLesserThan(a, b)
+		return ok
+	})
+
+	ranges := make([]*database.Range, 0, len(values))
+	for i, v := range values {
+		if i > 0 {
+			ok, err := types.IsEqual(values[i-1], v)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				continue
+			}
+		}
+
+		ranges = append(ranges, &database.Range{
+			Min:   append(prefix, v),
+			Exact: true,
+		})
+	}
+
+	return ranges, nil
+}
+
 // Append rng to r and return the new slice.
 // Duplicate ranges are ignored.
 func (r Ranges) Append(rng Range) Ranges {
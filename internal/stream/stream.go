@@ -1,13 +1,16 @@
 package stream
 
 import (
+	"bytes"
 	"fmt"
 	"strings"
 
 	"github.com/cockroachdb/errors"
+	"github.com/genjidb/genji/document"
 	errs "github.com/genjidb/genji/errors"
 	"github.com/genjidb/genji/internal/database"
 	"github.com/genjidb/genji/internal/environment"
+	"github.com/genjidb/genji/internal/expr"
 	"github.com/genjidb/genji/internal/tree"
 	"github.com/genjidb/genji/types"
 )
@@ -17,6 +20,10 @@ var ErrStreamClosed = errors.New("stream closed")
 
 type Stream struct {
 	Op Operator
+	// Subqueries are the cached subqueries used by the operators of the stream.
+	// They are evaluated before the first document is returned, so that
+	// their errors are returned by the stream itself.
+	Subqueries []expr.Expr
 }
 
 func New(op Operator) *Stream {
@@ -36,6 +43,13 @@ func (s *Stream) Iterate(in *environment.Environment, fn func(out *environment.E
 		return nil
 	}
 
+	for _, sq := range s.Subqueries {
+		_, err := sq.Eval(in)
+		if err != nil {
+			return err
+		}
+	}
+
 	return s.Op.Iterate(in, fn)
 }
 
@@ -252,6 +266,209 @@ func (it *ConcatOperator) String() string {
 	return s.String()
 }
 
+// IntersectOperator is an operator that returns the documents
+// returned by all of its streams.
+type IntersectOperator struct {
+	baseOperator
+	Streams []*Stream
+	// if true, duplicates are kept: a document is returned as many times
+	// as the minimum number of times it is returned by each stream.
+	All bool
+}
+
+// Intersect returns a new IntersectOperator that removes duplicates.
+func Intersect(s ...*Stream) *IntersectOperator {
+	return &IntersectOperator{Streams: s}
+}
+
+// IntersectAll returns a new IntersectOperator that keeps duplicates.
+func IntersectAll(s ...*Stream) *IntersectOperator {
+	return &IntersectOperator{Streams: s, All: true}
+}
+
+// Iterate iterates over all the streams and returns their intersection.
+func (it *IntersectOperator) Iterate(in *environment.Environment, fn func(out *environment.Environment) error) error {
+	return iterateCounts(in, it.Streams, func(counts []int) int {
+		n := counts[0]
+		for _, c := range counts[1:] {
+			if c < n {
+				n = c
+			}
+		}
+
+		if !it.All && n > 1 {
+			n = 1
+		}
+
+		return n
+	}, fn)
+}
+
+func (it *IntersectOperator) String() string {
+	if it.All {
+		return streamsString("intersectAll", it.Streams)
+	}
+
+	return streamsString("intersect", it.Streams)
+}
+
+// ExceptOperator is an operator that returns the documents
+// returned by its first stream but not by the others.
+type ExceptOperator struct {
+	baseOperator
+	Streams []*Stream
+	// if true, duplicates are kept: each document returned by the other streams
+	// only removes one of the documents returned by the first stream.
+	All bool
+}
+
+// Except returns a new ExceptOperator that removes duplicates.
+func Except(s ...*Stream) *ExceptOperator {
+	return &ExceptOperator{Streams: s}
+}
+
+// ExceptAll returns a new ExceptOperator that keeps duplicates.
+func ExceptAll(s ...*Stream) *ExceptOperator {
+	return &ExceptOperator{Streams: s, All: true}
+}
+
+// Iterate iterates over all the streams and returns the documents of the first stream
+// that are not returned by the others.
+func (it *ExceptOperator) Iterate(in *environment.Environment, fn func(out *environment.Environment) error) error {
+	return iterateCounts(in, it.Streams, func(counts []int) int {
+		n := counts[0]
+		for _, c := range counts[1:] {
+			if !it.All && c > 0 {
+				return 0
+			}
+			n -= c
+		}
+
+		if !it.All && n > 1 {
+			n = 1
+		}
+
+		return n
+	}, fn)
+}
+
+func (it *ExceptOperator) String() string {
+	if it.All {
+		return streamsString("exceptAll", it.Streams)
+	}
+
+	return streamsString("except", it.Streams)
+}
+
+// iterateCounts stores the documents of all the streams in a temporary tree, counting
+// how many times each distinct document is returned by each stream.
+// Each document is then returned as many times as returned by the count function.
+func iterateCounts(in *environment.Environment, streams []*Stream, count func(counts []int) int, fn func(out *environment.Environment) error) error {
+	tr, cleanup, err := database.NewTransientTree(in.GetDB())
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	// the documents are stored by document first, then by stream,
+	// so that all the copies of a document are read one after the other
+	var counter int64
+	for i, s := range streams {
+		err := s.Iterate(in, func(out *environment.Environment) error {
+			doc, ok := out.GetDocument()
+			if !ok {
+				return errors.New("missing document")
+			}
+
+			key, err := tree.NewKey(types.NewDocumentValue(doc), types.NewIntegerValue(int64(i)), types.NewIntegerValue(counter))
+			if err != nil {
+				return err
+			}
+			counter++
+
+			return tr.Put(key, nil)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	var newEnv environment.Environment
+	newEnv.SetOuter(in)
+
+	var prev tree.Key
+	var doc types.Document
+	counts := make([]int, len(streams))
+
+	emit := func() error {
+		n := count(counts)
+		for i := 0; i < n; i++ {
+			newEnv.SetDocument(doc)
+			err := fn(&newEnv)
+			if err != nil {
+				return err
+			}
+		}
+
+		for i := range counts {
+			counts[i] = 0
+		}
+		return nil
+	}
+
+	err = tr.IterateOnRange(nil, false, func(k tree.Key, _ types.Value) error {
+		kv, err := k.Decode()
+		if err != nil {
+			return err
+		}
+
+		dk, err := tree.NewKey(kv[0])
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Equal(prev, dk) {
+			if prev != nil {
+				err = emit()
+				if err != nil {
+					return err
+				}
+			}
+
+			v, err := document.CloneValue(kv[0])
+			if err != nil {
+				return err
+			}
+			prev = dk
+			doc = v.V().(types.Document)
+		}
+
+		counts[kv[1].V().(int64)]++
+		return nil
+	})
+	if err != nil || prev == nil {
+		return err
+	}
+
+	return emit()
+}
+
+func streamsString(name string, streams []*Stream) string {
+	var s strings.Builder
+
+	s.WriteString(name)
+	s.WriteRune('(')
+	for i, st := range streams {
+		if i > 0 {
+			s.WriteString(", ")
+		}
+		s.WriteString(st.String())
+	}
+	s.WriteRune(')')
+
+	return s.String()
+}
+
 // OnConflictOperator handles any conflicts that occur during the iteration.
 type OnConflictOperator struct {
 	baseOperator
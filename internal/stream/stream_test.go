@@ -137,6 +137,81 @@ func TestUnion(t *testing.T) {
 	})
 }
 
+func TestIntersectAndExcept(t *testing.T) {
+	first := testutil.ParseExprs(t, `{"a": 1}`, `{"a": 1}`, `{"a": 2}`, `{"a": 3}`, `{"a": 3}`, `{"a": 3}`)
+	second := testutil.ParseExprs(t, `{"a": 3}`, `{"a": 1}`, `{"a": 4}`, `{"a": 3}`)
+
+	tests := []struct {
+		name     string
+		op       func(s ...*stream.Stream) stream.Operator
+		expected testutil.Docs
+	}{
+		{
+			"intersect",
+			func(s ...*stream.Stream) stream.Operator { return stream.Intersect(s...) },
+			testutil.MakeDocuments(t, `{"a": 1}`, `{"a": 3}`),
+		},
+		{
+			"intersect all",
+			func(s ...*stream.Stream) stream.Operator { return stream.IntersectAll(s...) },
+			testutil.MakeDocuments(t, `{"a": 1}`, `{"a": 3}`, `{"a": 3}`),
+		},
+		{
+			"except",
+			func(s ...*stream.Stream) stream.Operator { return stream.Except(s...) },
+			testutil.MakeDocuments(t, `{"a": 2}`),
+		},
+		{
+			"except all",
+			func(s ...*stream.Stream) stream.Operator { return stream.ExceptAll(s...) },
+			testutil.MakeDocuments(t, `{"a": 1}`, `{"a": 2}`, `{"a": 3}`),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db, tx, cleanup := testutil.NewTestTx(t)
+			defer cleanup()
+
+			st := stream.New(test.op(
+				stream.New(stream.DocsEmit(first...)),
+				stream.New(stream.DocsEmit(second...)),
+			))
+			var env environment.Environment
+			env.Tx = tx
+			env.DB = db
+			env.Catalog = db.Catalog
+
+			var got testutil.Docs
+			err := st.Iterate(&env, func(env *environment.Environment) error {
+				d, ok := env.GetDocument()
+				require.True(t, ok)
+
+				clone, err := document.CloneValue(types.NewDocumentValue(d))
+				if err != nil {
+					return err
+				}
+
+				got = append(got, clone.V().(types.Document))
+				return nil
+			})
+			assert.NoError(t, err)
+			require.Equal(t, len(test.expected), len(got))
+			test.expected.RequireEqual(t, got)
+		})
+	}
+
+	t.Run("String", func(t *testing.T) {
+		s1 := stream.New(stream.DocsEmit(testutil.ParseExprs(t, `{"a": 1}`)...))
+		s2 := stream.New(stream.DocsEmit(testutil.ParseExprs(t, `{"a": 2}`)...))
+
+		require.Equal(t, `intersect(docs.Emit({a: 1}), docs.Emit({a: 2}))`, stream.New(stream.Intersect(s1, s2)).String())
+		require.Equal(t, `intersectAll(docs.Emit({a: 1}), docs.Emit({a: 2}))`, stream.New(stream.IntersectAll(s1, s2)).String())
+		require.Equal(t, `except(docs.Emit({a: 1}), docs.Emit({a: 2}))`, stream.New(stream.Except(s1, s2)).String())
+		require.Equal(t, `exceptAll(docs.Emit({a: 1}), docs.Emit({a: 2}))`, stream.New(stream.ExceptAll(s1, s2)).String())
+	})
+}
+
 func TestConcatOperator(t *testing.T) {
 	in1 := testutil.ParseExprs(t, `{"a": 10}`, `{"a": 11}`)
 	in2 := testutil.ParseExprs(t, `{"a": 12}`, `{"a": 13}`)
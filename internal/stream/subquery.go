@@ -0,0 +1,215 @@
+package stream
+
+import (
+	"fmt"
+
+	"github.com/cockroachdb/errors"
+	"github.com/genjidb/genji/document"
+	"github.com/genjidb/genji/internal/environment"
+	"github.com/genjidb/genji/internal/expr"
+	"github.com/genjidb/genji/types"
+)
+
+// A SubqueryExpr is an expression that evaluates to the documents returned by a stream,
+// usually built from a SELECT statement.
+// The stream is iterated using the environment of the expression, which allows it to
+// reference the documents of the outer statement using the name of their table (correlated subquery).
+type SubqueryExpr struct {
+	Stream *Stream
+	// FreeNames are the names the stream may use to reference the documents of
+	// the outer statement: the first part of each path that doesn't start with the name
+	// of one of the tables of the subquery.
+	FreeNames []string
+	// If true, the expression evaluates to an array containing the value
+	// of each document (IN (SELECT ...)). Otherwise, it evaluates to the value of the only
+	// document returned by the stream, or NULL if there are none (scalar subquery).
+	List bool
+	// Cached is true if the stream doesn't reference the documents of the outer statements.
+	// The expression is then evaluated once per execution of the statement.
+	Cached bool
+	// Stmt is the statement the stream was built from, if any.
+	Stmt fmt.Stringer
+}
+
+// Subquery creates an expression that evaluates to the value of the only
+// document returned by s.
+func Subquery(s *Stream, freeNames ...string) *SubqueryExpr {
+	return &SubqueryExpr{Stream: s, FreeNames: freeNames}
+}
+
+// SubqueryList creates an expression that evaluates to an array
+// containing the value of each document returned by s.
+func SubqueryList(s *Stream, freeNames ...string) *SubqueryExpr {
+	return &SubqueryExpr{Stream: s, FreeNames: freeNames, List: true}
+}
+
+// Eval implements the expr.Expr interface.
+func (s *SubqueryExpr) Eval(env *environment.Environment) (types.Value, error) {
+	return s.evalOnce(env, s.eval)
+}
+
+// evalOnce calls eval, or returns the value it returned during the current
+// execution of the statement if the expression is cached.
+func (s *SubqueryExpr) evalOnce(env *environment.Environment, eval func(env *environment.Environment) (types.Value, error)) (types.Value, error) {
+	if !s.Cached {
+		return eval(env)
+	}
+
+	if v, ok := env.GetValue(s); ok {
+		return v, nil
+	}
+
+	v, err := eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	env.SetValue(s, v)
+	return v, nil
+}
+
+func (s *SubqueryExpr) eval(env *environment.Environment) (types.Value, error) {
+	var vb *document.ValueBuffer
+	if s.List {
+		vb = document.NewValueBuffer()
+	}
+
+	var found types.Value
+
+	err := s.Stream.Iterate(env, func(out *environment.Environment) error {
+		d, ok := out.GetDocument()
+		if !ok {
+			return errors.New("missing document")
+		}
+
+		v, err := columnValue(d)
+		if err != nil {
+			return err
+		}
+
+		// the value might be reused by the stream
+		v, err = document.CloneValue(v)
+		if err != nil {
+			return err
+		}
+
+		if s.List {
+			vb.Append(v)
+			return nil
+		}
+
+		if found != nil {
+			return errors.New("subquery returned more than one document")
+		}
+		found = v
+		return nil
+	})
+	if err != nil && !errors.Is(err, ErrStreamClosed) {
+		return nil, err
+	}
+
+	if s.List {
+		return types.NewArrayValue(vb), nil
+	}
+
+	if found == nil {
+		return types.NewNullValue(), nil
+	}
+
+	return found, nil
+}
+
+// columnValue returns the value of the only field of d.
+func columnValue(d types.Document) (types.Value, error) {
+	var v types.Value
+
+	err := d.Iterate(func(field string, value types.Value) error {
+		if v != nil {
+			return errors.New("subquery must return only one field")
+		}
+		v = value
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if v == nil {
+		return nil, errors.New("subquery must return one field")
+	}
+
+	return v, nil
+}
+
+// SubqueryOf returns the subquery evaluated by e,
+// if e is a subquery or an EXISTS expression.
+func SubqueryOf(e expr.Expr) (*SubqueryExpr, bool) {
+	switch t := e.(type) {
+	case *SubqueryExpr:
+		return t, true
+	case *ExistsExpr:
+		return &t.SubqueryExpr, true
+	}
+
+	return nil, false
+}
+
+// IsCorrelatedTo returns true if the subquery may reference
+// the documents named after one of the given names.
+func (s *SubqueryExpr) IsCorrelatedTo(names ...string) bool {
+	for _, fn := range s.FreeNames {
+		for _, n := range names {
+			if fn == n {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (s *SubqueryExpr) String() string {
+	if s.Stmt != nil {
+		return fmt.Sprintf("(%s)", s.Stmt)
+	}
+
+	return fmt.Sprintf("(%s)", s.Stream)
+}
+
+// An ExistsExpr is an expression that evaluates to true if
+// its stream returns at least one document.
+type ExistsExpr struct {
+	SubqueryExpr
+}
+
+// Exists creates an expression that evaluates to true if s returns at least one document.
+func Exists(s *Stream, freeNames ...string) *ExistsExpr {
+	return &ExistsExpr{SubqueryExpr{Stream: s, FreeNames: freeNames}}
+}
+
+// Eval implements the expr.Expr interface.
+func (e *ExistsExpr) Eval(env *environment.Environment) (types.Value, error) {
+	return e.evalOnce(env, e.eval)
+}
+
+func (e *ExistsExpr) eval(env *environment.Environment) (types.Value, error) {
+	var found bool
+
+	err := e.Stream.Iterate(env, func(out *environment.Environment) error {
+		found = true
+		return errors.WithStack(ErrStreamClosed)
+	})
+	if err != nil && !errors.Is(err, ErrStreamClosed) {
+		return nil, err
+	}
+
+	return types.NewBoolValue(found), nil
+}
+
+func (e *ExistsExpr) String() string {
+	if e.Stmt != nil {
+		return fmt.Sprintf("EXISTS (%s)", e.Stmt)
+	}
+
+	return fmt.Sprintf("EXISTS (%s)", e.Stream)
+}
@@ -0,0 +1,109 @@
+package stream_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/genjidb/genji/document"
+	"github.com/genjidb/genji/internal/environment"
+	"github.com/genjidb/genji/internal/expr"
+	"github.com/genjidb/genji/internal/query/statement"
+	"github.com/genjidb/genji/internal/sql/parser"
+	"github.com/genjidb/genji/internal/stream"
+	"github.com/genjidb/genji/internal/testutil"
+	"github.com/genjidb/genji/internal/testutil/assert"
+	"github.com/genjidb/genji/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubquery(t *testing.T) {
+	emit := func(docs ...string) *stream.Stream {
+		return stream.New(stream.DocsEmit(testutil.ParseExprs(t, docs...)...))
+	}
+
+	tests := []struct {
+		name     string
+		e        expr.Expr
+		expected string
+		fails    bool
+	}{
+		{"scalar", stream.Subquery(emit(`{"a": 1}`)), `1`, false},
+		{"scalar/empty", stream.Subquery(emit()), `NULL`, false},
+		{"scalar/multiple docs", stream.Subquery(emit(`{"a": 1}`, `{"a": 2}`)), ``, true},
+		{"scalar/multiple fields", stream.Subquery(emit(`{"a": 1, "b": 2}`)), ``, true},
+		{"list", stream.SubqueryList(emit(`{"a": 1}`, `{"a": 2}`)), `[1, 2]`, false},
+		{"list/empty", stream.SubqueryList(emit()), `[]`, false},
+		{"exists", stream.Exists(emit(`{"a": 1}`, `{"a": 2}`)), `true`, false},
+		{"exists/empty", stream.Exists(emit()), `false`, false},
+		{"correlated", stream.Subquery(
+			emit(`{"a": 1}`).Pipe(stream.DocsProject(testutil.ParseNamedExpr(t, "o.b"))),
+			"o",
+		), `2`, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var outer environment.Environment
+			outer.Set(document.NewPath("o"), types.NewDocumentValue(testutil.MakeDocument(t, `{"b": 2}`)))
+
+			v, err := test.e.Eval(&outer)
+			if test.fails {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			require.Equal(t, test.expected, v.String())
+		})
+	}
+
+	t.Run("String", func(t *testing.T) {
+		s := stream.New(stream.TableScan("b")).Pipe(stream.DocsAlias("b"))
+
+		require.Equal(t, `(table.Scan("b") | docs.Alias(b))`, stream.Subquery(s).String())
+		require.Equal(t, `(table.Scan("b") | docs.Alias(b))`, stream.SubqueryList(s).String())
+		require.Equal(t, `EXISTS (table.Scan("b") | docs.Alias(b))`, stream.Exists(s).String())
+
+		// subqueries built from a statement print the statement
+		stmt, err := parser.NewParser(strings.NewReader("SELECT y AS z FROM b WHERE x > 1 ORDER BY y DESC LIMIT 1")).ParseStatement()
+		assert.NoError(t, err)
+		sq, err := stmt.(*statement.SelectStmt).Subquery()
+		assert.NoError(t, err)
+		require.Equal(t, "(SELECT y AS z FROM b WHERE x > 1 ORDER BY y DESC LIMIT 1)", sq.String())
+		require.Equal(t, "EXISTS (SELECT y AS z FROM b WHERE x > 1 ORDER BY y DESC LIMIT 1)", (&stream.ExistsExpr{SubqueryExpr: *sq}).String())
+	})
+
+	t.Run("Cached", func(t *testing.T) {
+		sq := stream.Subquery(emit(`{"a": 1}`).Pipe(stream.DocsProject(testutil.ParseNamedExpr(t, "o"))))
+		sq.Cached = true
+
+		var outer environment.Environment
+		outer.Set(document.NewPath("o"), types.NewIntegerValue(1))
+		var inner environment.Environment
+		inner.SetOuter(&outer)
+
+		v, err := sq.Eval(&inner)
+		assert.NoError(t, err)
+		require.Equal(t, `1`, v.String())
+
+		// the value is computed once per execution
+		outer.Set(document.NewPath("o"), types.NewIntegerValue(2))
+		v, err = sq.Eval(&inner)
+		assert.NoError(t, err)
+		require.Equal(t, `1`, v.String())
+
+		var other environment.Environment
+		other.Set(document.NewPath("o"), types.NewIntegerValue(2))
+		v, err = sq.Eval(&other)
+		assert.NoError(t, err)
+		require.Equal(t, `2`, v.String())
+	})
+}
+
+func TestSubqueryIsCorrelatedTo(t *testing.T) {
+	s := stream.Subquery(stream.New(stream.TableScan("b")), "a", "c")
+
+	require.True(t, s.IsCorrelatedTo("a"))
+	require.True(t, s.IsCorrelatedTo("b", "c"))
+	require.False(t, s.IsCorrelatedTo("b"))
+	require.False(t, stream.Subquery(s.Stream).IsCorrelatedTo("a"))
+}
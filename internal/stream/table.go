@@ -6,8 +6,10 @@ import (
 	"strings"
 
 	"github.com/cockroachdb/errors"
+	errs "github.com/genjidb/genji/errors"
 	"github.com/genjidb/genji/internal/database"
 	"github.com/genjidb/genji/internal/environment"
+	"github.com/genjidb/genji/internal/lock"
 	"github.com/genjidb/genji/internal/tree"
 	"github.com/genjidb/genji/types"
 )
@@ -18,6 +20,8 @@ type TableScanOperator struct {
 	TableName string
 	Ranges    Ranges
 	Reverse   bool
+	// ForUpdate locks each document before it is read by the rest of the stream.
+	ForUpdate bool
 }
 
 // TableScan creates an iterator that iterates over each document of the given table that match the given ranges.
@@ -83,6 +87,24 @@ func (it *TableScanOperator) Iterate(in *environment.Environment, fn func(out *e
 
 	for _, rng := range ranges {
 		err = table.IterateOnRange(rng, it.Reverse, func(key tree.Key, d types.Document) error {
+			if it.ForUpdate {
+				// the iterator might return a stale version of the document
+				// if another transaction modified it before the lock was acquired:
+				// read it again once it is locked.
+				err := lockDocument(in.GetTx(), it.TableName, key)
+				if err != nil {
+					return err
+				}
+
+				d, err = table.GetDocument(key)
+				if errors.Is(err, errs.ErrDocumentNotFound) {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+			}
+
 			newEnv.Set(environment.DocPKKey, types.NewBlobValue(key))
 			newEnv.SetDocument(d)
 
@@ -179,7 +201,17 @@ func (op *TableInsertOperator) Iterate(in *environment.Environment, f func(out *
 			}
 		}
 
-		key, d, err := table.Insert(d)
+		key, err := table.GenerateKey(d)
+		if err != nil {
+			return err
+		}
+
+		err = lockDocument(out.GetTx(), op.Name, key)
+		if err != nil {
+			return err
+		}
+
+		d, err = table.InsertWithKey(key, d)
 		if err != nil {
 			return err
 		}
@@ -229,7 +261,12 @@ func (op *TableReplaceOperator) Iterate(in *environment.Environment, f func(out
 			return errors.New("missing key")
 		}
 
-		_, err := table.Replace(key.V().([]byte), d)
+		err := lockDocument(out.GetTx(), op.Name, key.V().([]byte))
+		if err != nil {
+			return err
+		}
+
+		_, err = table.Replace(key.V().([]byte), d)
 		if err != nil {
 			return err
 		}
@@ -277,7 +314,12 @@ func (op *TableDeleteOperator) Iterate(in *environment.Environment, f func(out *
 			return errors.New("missing key")
 		}
 
-		err := table.Delete(key.V().([]byte))
+		err := lockDocument(out.GetTx(), op.Name, key.V().([]byte))
+		if err != nil {
+			return err
+		}
+
+		err = table.Delete(key.V().([]byte))
 		if err != nil {
 			return err
 		}
@@ -289,3 +331,15 @@ func (op *TableDeleteOperator) Iterate(in *environment.Environment, f func(out *
 func (op *TableDeleteOperator) String() string {
 	return fmt.Sprintf("table.Delete('%s')", op.Name)
 }
+
+// lockDocument acquires an intent exclusive lock on the table
+// and an exclusive lock on the document identified by key.
+// Locks are held until the transaction is committed or rolled back.
+func lockDocument(tx *database.Transaction, tableName string, key []byte) error {
+	err := tx.Lock(lock.NewTableObject(tableName), lock.IX)
+	if err != nil {
+		return err
+	}
+
+	return tx.Lock(lock.NewDocumentObject(tableName, key), lock.X)
+}
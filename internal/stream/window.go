@@ -0,0 +1,639 @@
+package stream
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/genjidb/genji/document"
+	"github.com/genjidb/genji/internal/database"
+	"github.com/genjidb/genji/internal/environment"
+	"github.com/genjidb/genji/internal/expr"
+	"github.com/genjidb/genji/internal/tree"
+	"github.com/genjidb/genji/types"
+	"github.com/genjidb/genji/types/encoding"
+)
+
+// A Window defines how the documents are grouped and sorted when
+// evaluating a window function: its OVER clause.
+type Window struct {
+	PartitionBy []expr.Expr
+	OrderBy     []SortKey
+}
+
+// key returns the key used to sort the document of env in a temporary tree:
+// the encoded array of the partition values, the encoded array of the order values,
+// the table name, the primary key of the document and a counter.
+// The partition and order values are stored as blobs because they are only compared:
+// empty documents nested in another value can't be decoded.
+func (w *Window) key(env *environment.Environment, counter int64) (tree.Key, error) {
+	partition := document.NewValueBuffer()
+	for _, e := range w.PartitionBy {
+		v, err := e.Eval(env)
+		if err != nil {
+			return nil, err
+		}
+		partition.Append(v)
+	}
+
+	order := document.NewValueBuffer()
+	for _, k := range w.OrderBy {
+		v, err := k.Expr.Eval(env)
+		if err != nil {
+			return nil, err
+		}
+		err = k.appendSortValue(order, v)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	p, err := encodeValue(types.NewArrayValue(partition))
+	if err != nil {
+		return nil, err
+	}
+	o, err := encodeValue(types.NewArrayValue(order))
+	if err != nil {
+		return nil, err
+	}
+
+	tableName, _ := env.Get(environment.TableKey)
+	pk, _ := env.Get(environment.DocPKKey)
+
+	return tree.NewKey(p, o, tableName, pk, types.NewIntegerValue(counter))
+}
+
+// encodeValue returns a blob containing the encoding of v.
+// Blobs are sorted like the encoded values.
+func encodeValue(v types.Value) (types.Value, error) {
+	var buf bytes.Buffer
+	err := encoding.EncodeValue(&buf, v)
+	if err != nil {
+		return nil, err
+	}
+
+	return types.NewBlobValue(buf.Bytes()), nil
+}
+
+func (w *Window) String() string {
+	var sb strings.Builder
+
+	if len(w.PartitionBy) > 0 {
+		sb.WriteString("PARTITION BY ")
+		for i, e := range w.PartitionBy {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(e.String())
+		}
+	}
+
+	if len(w.OrderBy) > 0 {
+		if len(w.PartitionBy) > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString("ORDER BY ")
+		for i, k := range w.OrderBy {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(k.String())
+		}
+	}
+
+	return sb.String()
+}
+
+// A WindowExpr is a window function, or an aggregate function, evaluated over
+// the documents of the partition of each document. Aggregate functions are evaluated
+// over the documents of the partition up to the last peer of the current document
+// if the window is sorted, otherwise over the whole partition.
+// Its value is computed by the DocsWindow operator.
+type WindowExpr struct {
+	// Func is an expr.WindowFunction or an expr.AggregatorBuilder.
+	Func   expr.Expr
+	Window Window
+}
+
+// Eval returns the value computed by the DocsWindow operator for the current document.
+func (w *WindowExpr) Eval(env *environment.Environment) (types.Value, error) {
+	v, ok := env.Get(w.path())
+	if !ok {
+		return nil, errors.Errorf("misuse of window function %s", w.Func)
+	}
+
+	return v, nil
+}
+
+// IsEqual compares this expression with the other expression and returns
+// true if they are equal.
+func (w *WindowExpr) IsEqual(other expr.Expr) bool {
+	o, ok := other.(*WindowExpr)
+	if !ok {
+		return false
+	}
+
+	return w.String() == o.String()
+}
+
+// path returns the path of the value of the expression in the environment.
+func (w *WindowExpr) path() document.Path {
+	return document.Path{document.PathFragment{FieldName: w.String()}}
+}
+
+func (w *WindowExpr) String() string {
+	return fmt.Sprintf("%s OVER (%s)", w.Func, &w.Window)
+}
+
+// A DocsWindowOperator computes the value of window expressions for each document of the stream.
+type DocsWindowOperator struct {
+	baseOperator
+	Exprs []*WindowExpr
+}
+
+// DocsWindow consumes every document of the stream, sorts them in a temporary tree by partition
+// and order for each window and computes the value of each expression. The values can then be
+// read by evaluating the expressions against the documents the operator outputs.
+func DocsWindow(exprs ...*WindowExpr) *DocsWindowOperator {
+	return &DocsWindowOperator{Exprs: exprs}
+}
+
+// a windowGroup holds the expressions evaluated over the same window,
+// and their position in the list of expressions of the operator.
+type windowGroup struct {
+	window    *Window
+	exprs     []*WindowExpr
+	positions []int
+}
+
+// groups returns the expressions of the operator, grouped by window.
+func (op *DocsWindowOperator) groups() []*windowGroup {
+	var groups []*windowGroup
+
+	for i, e := range op.Exprs {
+		var g *windowGroup
+		for _, gg := range groups {
+			if gg.window.String() == e.Window.String() {
+				g = gg
+				break
+			}
+		}
+		if g == nil {
+			g = &windowGroup{window: &e.Window}
+			groups = append(groups, g)
+		}
+
+		g.exprs = append(g.exprs, e)
+		g.positions = append(g.positions, i)
+	}
+
+	return groups
+}
+
+// Iterate sorts the documents in a temporary tree for the first window and computes the values
+// of its expressions in order. Each document is then stored, with its values, in a new tree sorted
+// for the next window, and so on. The documents are returned in the order of the last window.
+func (op *DocsWindowOperator) Iterate(in *environment.Environment, fn func(out *environment.Environment) error) error {
+	db := in.GetDB()
+	groups := op.groups()
+
+	tr, cleanup, err := database.NewTransientTree(db)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	var counter int64
+	// names of the tables, if the documents come from a join
+	var names []string
+
+	iterate := func(fn func(out *environment.Environment) error) error {
+		if op.Prev != nil {
+			return op.Prev.Iterate(in, fn)
+		}
+
+		// without a table, the window contains one empty document
+		var newEnv environment.Environment
+		newEnv.SetOuter(in)
+		newEnv.SetDocument(document.NewFieldBuffer())
+		return fn(&newEnv)
+	}
+
+	err = iterate(func(out *environment.Environment) error {
+		doc, ok := out.GetDocument()
+		if !ok {
+			return errors.New("missing document")
+		}
+
+		if jd, ok := doc.(*JoinedDocument); ok && names == nil {
+			names = append([]string(nil), jd.Names...)
+		}
+
+		k, err := groups[0].window.key(out, counter)
+		if err != nil {
+			return err
+		}
+		counter++
+
+		// the values of the expressions are stored after the document
+		values := document.NewValueBuffer(types.NewDocumentValue(doc))
+		for range op.Exprs {
+			values.Append(types.NewNullValue())
+		}
+
+		return putValues(tr, k, values)
+	})
+	if err != nil {
+		return err
+	}
+
+	paths := make([]document.Path, len(op.Exprs))
+	for i, e := range op.Exprs {
+		paths[i] = e.path()
+	}
+
+	for i, g := range groups {
+		last := i == len(groups)-1
+
+		var next *tree.Tree
+		if !last {
+			var nextCleanup func() error
+			next, nextCleanup, err = database.NewTransientTree(db)
+			if err != nil {
+				return err
+			}
+			defer nextCleanup()
+		}
+
+		counter = 0
+		w := windowIterator{tr: tr, group: g, env: in, names: names}
+		err = w.iterate(func(out *environment.Environment, values *document.ValueBuffer) error {
+			if !last {
+				k, err := groups[i+1].window.key(out, counter)
+				if err != nil {
+					return err
+				}
+				counter++
+
+				return putValues(next, k, values)
+			}
+
+			for j, p := range paths {
+				out.Set(p, values.Values[j+1])
+			}
+
+			return fn(out)
+		})
+		if err != nil {
+			return err
+		}
+
+		tr = next
+	}
+
+	return nil
+}
+
+func (op *DocsWindowOperator) String() string {
+	var sb strings.Builder
+
+	sb.WriteString("docs.Window(")
+	for i, e := range op.Exprs {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(e.String())
+	}
+	sb.WriteByte(')')
+
+	return sb.String()
+}
+
+// a windowIterator iterates over the documents of a temporary tree, sorted by
+// window, and computes the values of the expressions of the window for each one.
+// It implements the expr.Window interface for the current document.
+type windowIterator struct {
+	tr    *tree.Tree
+	group *windowGroup
+	env   *environment.Environment
+	names []string
+
+	key            tree.Key
+	current        *environment.Environment
+	partition      types.Value
+	partitionStart tree.Key
+	rowNumber      int64
+	rank           int64
+	denseRank      int64
+}
+
+// iterate calls fn for each document of the tree, in order, with its values.
+func (w *windowIterator) iterate(fn func(out *environment.Environment, values *document.ValueBuffer) error) error {
+	var order types.Value
+	var aggregators []expr.Aggregator
+	aggValues := make([]types.Value, len(w.group.exprs))
+
+	return w.tr.IterateOnRange(nil, false, func(k tree.Key, v types.Value) error {
+		kv, err := k.Decode()
+		if err != nil {
+			return err
+		}
+
+		newPartition := w.partition == nil
+		if !newPartition {
+			ok, err := types.IsEqual(w.partition, kv[0])
+			if err != nil {
+				return err
+			}
+			newPartition = !ok
+		}
+
+		newPeers := newPartition
+		if !newPeers {
+			ok, err := types.IsEqual(order, kv[1])
+			if err != nil {
+				return err
+			}
+			newPeers = !ok
+		}
+
+		w.key = append(w.key[:0], k...)
+		if newPartition {
+			w.partition = kv[0]
+			w.partitionStart = append(w.partitionStart[:0], k...)
+			w.rowNumber = 0
+			w.denseRank = 0
+
+			aggregators = aggregators[:0]
+			for _, e := range w.group.exprs {
+				if b, ok := e.Func.(expr.AggregatorBuilder); ok {
+					aggregators = append(aggregators, b.Aggregator())
+				}
+			}
+		}
+		order = kv[1]
+
+		w.rowNumber++
+		if newPeers {
+			w.rank = w.rowNumber
+			w.denseRank++
+
+			// aggregate the documents of the partition up to the last peer
+			if len(aggregators) > 0 {
+				err = w.iteratePeers(func(env *environment.Environment) error {
+					for _, agg := range aggregators {
+						err := agg.Aggregate(env)
+						if err != nil {
+							return err
+						}
+					}
+
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+
+				var j int
+				for i, e := range w.group.exprs {
+					if _, ok := e.Func.(expr.AggregatorBuilder); ok {
+						aggValues[i], err = aggregators[j].Eval(w.env)
+						if err != nil {
+							return err
+						}
+						aggValues[i], err = document.CloneValue(aggValues[i])
+						if err != nil {
+							return err
+						}
+						j++
+					}
+				}
+			}
+		}
+
+		values, err := w.values(v)
+		if err != nil {
+			return err
+		}
+
+		w.current, err = w.rowEnv(kv, values)
+		if err != nil {
+			return err
+		}
+
+		for i, e := range w.group.exprs {
+			val := aggValues[i]
+			if wf, ok := e.Func.(expr.WindowFunction); ok {
+				val, err = wf.EvalWindow(w)
+				if err != nil {
+					return err
+				}
+			}
+
+			values.Values[w.group.positions[i]+1] = val
+		}
+
+		return fn(w.current, values)
+	})
+}
+
+// putValues stores the document and its values in the tree.
+// Each value is encoded separately, as a blob, because the document
+// might be empty, like the values of the expressions, and empty documents
+// nested in another value can't be decoded.
+func putValues(tr *tree.Tree, k tree.Key, values *document.ValueBuffer) error {
+	vb := document.NewValueBuffer()
+	for _, v := range values.Values {
+		b, err := encodeValue(v)
+		if err != nil {
+			return err
+		}
+		vb.Append(b)
+	}
+
+	return tr.Put(k, types.NewArrayValue(vb))
+}
+
+// values decodes the document and the values stored in the tree by putValues.
+func (w *windowIterator) values(v types.Value) (*document.ValueBuffer, error) {
+	vb := document.NewValueBuffer()
+	err := v.V().(types.Array).Iterate(func(i int, b types.Value) error {
+		v, err := encoding.DecodeValue(b.V().([]byte))
+		if err != nil {
+			return err
+		}
+
+		vb.Append(v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return vb, nil
+}
+
+// rowEnv returns the environment of a document stored in the tree.
+func (w *windowIterator) rowEnv(kv []types.Value, values *document.ValueBuffer) (*environment.Environment, error) {
+	var env environment.Environment
+	env.SetOuter(w.env)
+
+	if kv[2].Type() != types.NullValue {
+		env.Set(environment.TableKey, kv[2])
+	}
+	if kv[3].Type() != types.NullValue {
+		env.Set(environment.DocPKKey, kv[3])
+	}
+
+	doc := values.Values[0].V().(types.Document)
+	if w.names != nil {
+		jd, err := rejoin(w.names, doc)
+		if err != nil {
+			return nil, err
+		}
+		jd.setNames(&env)
+		doc = jd
+	}
+	env.SetDocument(doc)
+
+	return &env, nil
+}
+
+// iterateRange calls fn for each document of the partition of the current document
+// in the given range, until fn returns ErrStreamClosed.
+func (w *windowIterator) iterateRange(rng *tree.Range, reverse bool, fn func(kv []types.Value, env *environment.Environment) error) error {
+	err := w.tr.IterateOnRange(rng, reverse, func(k tree.Key, v types.Value) error {
+		kv, err := k.Decode()
+		if err != nil {
+			return err
+		}
+
+		ok, err := types.IsEqual(w.partition, kv[0])
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrStreamClosed
+		}
+
+		values, err := w.values(v)
+		if err != nil {
+			return err
+		}
+
+		env, err := w.rowEnv(kv, values)
+		if err != nil {
+			return err
+		}
+
+		return fn(kv, env)
+	})
+	if errors.Is(err, ErrStreamClosed) {
+		err = nil
+	}
+	return err
+}
+
+// iteratePeers calls fn for the current document and the following ones
+// with the same ORDER BY values.
+func (w *windowIterator) iteratePeers(fn func(env *environment.Environment) error) error {
+	var order types.Value
+
+	return w.iterateRange(&tree.Range{Min: w.key}, false, func(kv []types.Value, env *environment.Environment) error {
+		if order == nil {
+			order = kv[1]
+		} else {
+			ok, err := types.IsEqual(order, kv[1])
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return ErrStreamClosed
+			}
+		}
+
+		return fn(env)
+	})
+}
+
+// RowNumber implements the expr.Window interface.
+func (w *windowIterator) RowNumber() int64 {
+	return w.rowNumber
+}
+
+// Rank implements the expr.Window interface.
+func (w *windowIterator) Rank() int64 {
+	return w.rank
+}
+
+// DenseRank implements the expr.Window interface.
+func (w *windowIterator) DenseRank() int64 {
+	return w.denseRank
+}
+
+// EvalAt implements the expr.Window interface.
+func (w *windowIterator) EvalAt(offset int64, e expr.Expr) (types.Value, bool, error) {
+	if offset == 0 {
+		v, err := e.Eval(w.current)
+		return v, true, err
+	}
+
+	// the partition doesn't contain enough documents before the current one
+	if offset < 0 && -offset >= w.rowNumber {
+		return nil, false, nil
+	}
+
+	rng := tree.Range{Min: w.key, Exclusive: true}
+	reverse := offset < 0
+	if reverse {
+		rng = tree.Range{Max: w.key, Exclusive: true}
+		offset = -offset
+	}
+
+	var found types.Value
+	var n int64
+	err := w.iterateRange(&rng, reverse, func(_ []types.Value, env *environment.Environment) error {
+		n++
+		if n < offset {
+			return nil
+		}
+
+		v, err := e.Eval(env)
+		if err != nil {
+			return err
+		}
+
+		// the value might be reused by the tree
+		found, err = document.CloneValue(v)
+		if err != nil {
+			return err
+		}
+
+		return ErrStreamClosed
+	})
+
+	return found, found != nil, err
+}
+
+// EvalFirst implements the expr.Window interface.
+func (w *windowIterator) EvalFirst(e expr.Expr) (types.Value, error) {
+	var found types.Value
+
+	err := w.iterateRange(&tree.Range{Min: w.partitionStart}, false, func(_ []types.Value, env *environment.Environment) error {
+		v, err := e.Eval(env)
+		if err != nil {
+			return err
+		}
+
+		// the value might be reused by the tree
+		found, err = document.CloneValue(v)
+		if err != nil {
+			return err
+		}
+
+		return ErrStreamClosed
+	})
+
+	return found, err
+}
@@ -0,0 +1,82 @@
+package stream_test
+
+import (
+	"testing"
+
+	"github.com/genjidb/genji/document"
+	"github.com/genjidb/genji/internal/environment"
+	"github.com/genjidb/genji/internal/expr"
+	"github.com/genjidb/genji/internal/expr/functions"
+	"github.com/genjidb/genji/internal/sql/parser"
+	"github.com/genjidb/genji/internal/stream"
+	"github.com/genjidb/genji/internal/testutil"
+	"github.com/genjidb/genji/internal/testutil/assert"
+	"github.com/genjidb/genji/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocsWindow(t *testing.T) {
+	rowNumber := &stream.WindowExpr{
+		Func: &functions.RowNumber{},
+		Window: stream.Window{
+			PartitionBy: []expr.Expr{parser.MustParseExpr("a")},
+			OrderBy:     []stream.SortKey{{Expr: parser.MustParseExpr("b"), Desc: true, NullsLast: true}},
+		},
+	}
+	sum := &stream.WindowExpr{
+		Func: &functions.Sum{Expr: parser.MustParseExpr("b")},
+		Window: stream.Window{
+			OrderBy: []stream.SortKey{{Expr: parser.MustParseExpr("b")}},
+		},
+	}
+
+	db, tx, cleanup := testutil.NewTestTx(t)
+	defer cleanup()
+
+	testutil.MustExec(t, db, tx, `
+		CREATE TABLE test(a int, b int);
+		INSERT INTO test (a, b) VALUES (1, 1), (2, 5), (1, 3), (1, 3);
+	`)
+
+	var env environment.Environment
+	env.DB = db
+	env.Tx = tx
+	env.Catalog = db.Catalog
+
+	s := stream.New(stream.TableScan("test")).
+		Pipe(stream.DocsWindow(rowNumber, sum)).
+		Pipe(stream.DocsProject(
+			&expr.NamedExpr{ExprName: "a", Expr: parser.MustParseExpr("a")},
+			&expr.NamedExpr{ExprName: "b", Expr: parser.MustParseExpr("b")},
+			&expr.NamedExpr{ExprName: "rn", Expr: rowNumber},
+			&expr.NamedExpr{ExprName: "sum", Expr: sum},
+		))
+
+	var got []types.Document
+	err := s.Iterate(&env, func(env *environment.Environment) error {
+		d, ok := env.GetDocument()
+		require.True(t, ok)
+
+		fb := document.NewFieldBuffer()
+		err := fb.Copy(d)
+		assert.NoError(t, err)
+		got = append(got, fb)
+		return nil
+	})
+	assert.NoError(t, err)
+
+	want := []types.Document{
+		testutil.MakeDocument(t, `{"a": 1, "b": 1, "rn": 3, "sum": 1}`),
+		testutil.MakeDocument(t, `{"a": 1, "b": 3, "rn": 1, "sum": 7}`),
+		testutil.MakeDocument(t, `{"a": 1, "b": 3, "rn": 2, "sum": 7}`),
+		testutil.MakeDocument(t, `{"a": 2, "b": 5, "rn": 1, "sum": 12}`),
+	}
+	require.Equal(t, len(want), len(got))
+	for i := range got {
+		testutil.RequireDocEqual(t, want[i], got[i])
+	}
+
+	t.Run("String", func(t *testing.T) {
+		require.Equal(t, `docs.Window(ROW_NUMBER() OVER (PARTITION BY a ORDER BY b DESC), SUM(b) OVER (ORDER BY b))`, stream.DocsWindow(rowNumber, sum).String())
+	})
+}
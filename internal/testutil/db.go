@@ -4,12 +4,11 @@ import (
 	"context"
 	"testing"
 
-	"github.com/cockroachdb/pebble"
-	"github.com/cockroachdb/pebble/vfs"
 	"github.com/genjidb/genji/internal/database"
 	"github.com/genjidb/genji/internal/database/catalogstore"
 	"github.com/genjidb/genji/internal/environment"
 	"github.com/genjidb/genji/internal/kv"
+	"github.com/genjidb/genji/internal/kv/memkv"
 	"github.com/genjidb/genji/internal/query"
 	"github.com/genjidb/genji/internal/query/statement"
 	"github.com/genjidb/genji/internal/sql/parser"
@@ -18,16 +17,13 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func NewEngine(t testing.TB) *kv.Engine {
+func NewEngine(t testing.TB) kv.Engine {
 	t.Helper()
 
-	ng, err := kv.NewEngine("", &pebble.Options{FS: vfs.NewMem()})
-	assert.NoError(t, err)
-
-	return ng
+	return memkv.NewEngine()
 }
 
-func NewTestStore(t testing.TB, name string) *kv.Store {
+func NewTestStore(t testing.TB, name string) kv.Store {
 	t.Helper()
 
 	ng := NewEngine(t)
@@ -54,7 +50,7 @@ func NewTestDB(t testing.TB) (*database.Database, func()) {
 	return NewTestDBWithEngine(t, NewEngine(t))
 }
 
-func NewTestDBWithEngine(t testing.TB, ng *kv.Engine) (*database.Database, func()) {
+func NewTestDBWithEngine(t testing.TB, ng kv.Engine) (*database.Database, func()) {
 	t.Helper()
 
 	db, err := database.New(context.Background(), ng)
@@ -82,6 +82,18 @@ func MakeValueBuffer(t testing.TB, jsonArray string) *document.ValueBuffer {
 
 type Docs []types.Document
 
+// Iterate implements the document.Iterator interface.
+func (docs Docs) Iterate(fn func(d types.Document) error) error {
+	for _, d := range docs {
+		err := fn(d)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (docs Docs) RequireEqual(t testing.TB, others Docs) {
 	t.Helper()
 
@@ -3,7 +3,6 @@ package tree
 import (
 	"bytes"
 
-	"github.com/cockroachdb/pebble"
 	"github.com/genjidb/genji/internal/kv"
 	"github.com/genjidb/genji/types"
 	"github.com/genjidb/genji/types/encoding"
@@ -19,17 +18,17 @@ import (
 // of the types package operators.
 // A Tree doesn't support duplicate keys.
 type Tree struct {
-	Store          *kv.Store
-	TransientStore *kv.TransientStore
+	Store          kv.Store
+	TransientStore kv.TransientStore
 }
 
-func New(store *kv.Store) *Tree {
+func New(store kv.Store) *Tree {
 	return &Tree{
 		Store: store,
 	}
 }
 
-func NewTransient(store *kv.TransientStore) *Tree {
+func NewTransient(store kv.TransientStore) *Tree {
 	return &Tree{
 		TransientStore: store,
 	}
@@ -82,12 +81,30 @@ func (t *Tree) Get(key Key) (types.Value, error) {
 // Exists returns true if the key exists in the tree.
 func (t *Tree) Exists(key Key) (bool, error) {
 	if t.TransientStore != nil {
-		panic("Exists not implemented on transient tree")
+		return t.transientExists(key)
 	}
 
 	return t.Store.Exists(key)
 }
 
+// transient stores can only be iterated, look for the key
+// among the ones it prefixes.
+func (t *Tree) transientExists(key Key) (bool, error) {
+	it := t.TransientStore.Iterator(&kv.IterOptions{
+		LowerBound: t.buildStartKeyInclusive(key),
+		UpperBound: t.buildEndKeyInclusive(key),
+	})
+	defer it.Close()
+
+	for it.First(); it.Valid(); it.Next() {
+		if bytes.Equal(it.Key(), key) {
+			return true, nil
+		}
+	}
+
+	return false, it.Error()
+}
+
 // Delete a key from the tree. If the key doesn't exist,
 // it returns kv.ErrKeyNotFound.
 func (t *Tree) Delete(key Key) error {
@@ -152,8 +169,8 @@ func (t *Tree) IterateOnRange(rng *Range, reverse bool, fn func(Key, types.Value
 		}
 	}
 
-	var it *kv.Iterator
-	opts := pebble.IterOptions{
+	var it kv.Iterator
+	opts := kv.IterOptions{
 		LowerBound: start,
 		UpperBound: end,
 	}
@@ -176,7 +193,7 @@ func (t *Tree) IterateOnRange(rng *Range, reverse bool, fn func(Key, types.Value
 		value.encoded = it.Value()
 		value.v = nil
 
-		err := fn(bytes.TrimPrefix(it.Key(), t.buildKey(nil)), &value)
+		err := fn(it.Key(), &value)
 		if err != nil {
 			return err
 		}
@@ -191,26 +208,22 @@ func (t *Tree) IterateOnRange(rng *Range, reverse bool, fn func(Key, types.Value
 	return it.Error()
 }
 
+// keys are relative to the underlying store.
+// A copy is made to ensure the given key is never modified.
 func (t *Tree) buildKey(key Key) []byte {
-	if t.Store != nil {
-		return kv.BuildKey(t.Store.Prefix, key)
-	}
-
-	return key
+	return append([]byte(nil), key...)
 }
 
+// a nil start key means the iteration starts
+// with the first key of the store.
 func (t *Tree) buildFirstKey() []byte {
-	return t.buildKey(nil)
+	return nil
 }
 
+// a nil end key means the iteration ends
+// with the last key of the store.
 func (t *Tree) buildLastKey() []byte {
-	k := t.buildKey(nil)
-	if len(k) == 0 {
-		return []byte{0xFF}
-	}
-
-	k[len(k)-1] = 0xff
-	return k
+	return nil
 }
 
 func (t *Tree) buildStartKeyInclusive(key []byte) []byte {
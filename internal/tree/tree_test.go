@@ -69,6 +69,36 @@ func TestTreeGet(t *testing.T) {
 	}
 }
 
+func TestTreeExists(t *testing.T) {
+	ts, err := testutil.NewEngine(t).NewTransientStore()
+	assert.NoError(t, err)
+
+	trees := map[string]*tree.Tree{
+		"store":     tree.New(testutil.NewTestStore(t, "store")),
+		"transient": tree.NewTransient(ts),
+	}
+
+	for name, tr := range trees {
+		t.Run(name, func(t *testing.T) {
+			err := tr.Put(key1, val)
+			assert.NoError(t, err)
+
+			ok, err := tr.Exists(key1)
+			assert.NoError(t, err)
+			require.True(t, ok)
+
+			ok, err = tr.Exists(key2)
+			assert.NoError(t, err)
+			require.False(t, ok)
+
+			// key1 prefixes this key
+			ok, err = tr.Exists(MustNewKey(t, types.NewBoolValue(true)))
+			assert.NoError(t, err)
+			require.False(t, ok)
+		})
+	}
+}
+
 func TestTreeDelete(t *testing.T) {
 	tests := []struct {
 		name  string
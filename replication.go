@@ -0,0 +1,57 @@
+package genji
+
+import (
+	"context"
+	"io"
+
+	"github.com/genjidb/genji/internal/replication"
+)
+
+// ExportChangeLog writes to w the changes committed after the given position,
+// which is usually the position of the last change applied by a follower,
+// and returns the position of the last exported change.
+// The database must have been opened with the ChangeLog option.
+// The output can be applied to another database using ApplyChangeLog.
+func (db *DB) ExportChangeLog(ctx context.Context, w io.Writer, from uint64) (uint64, error) {
+	return replication.Export(ctx, db.DB, w, from)
+}
+
+// ChangeLogPosition returns the position of the last change recorded in the change log.
+func (db *DB) ChangeLogPosition() uint64 {
+	return db.DB.ChangeLog.Position()
+}
+
+// PruneChangeLog deletes the changes recorded up to the given position,
+// once every follower has applied them. The last change is always kept.
+func (db *DB) PruneChangeLog(position uint64) error {
+	tx, err := db.DB.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = db.DB.ChangeLog.Prune(tx, position)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ApplyChangeLog reads the changes exported by the leader using ExportChangeLog
+// and applies them to the database, which then acts as a follower.
+// Changes that were already applied are skipped and it returns the position
+// of the last applied change, which can be passed to ExportChangeLog
+// to resume replication.
+// A follower must either be empty and replicate a leader that recorded its changes since it was created,
+// or be created from a backup of the leader taken after the change log was enabled.
+// It must not be modified by other means and can be opened in read-only mode between replications.
+func (db *DB) ApplyChangeLog(ctx context.Context, r io.Reader) (uint64, error) {
+	return replication.Apply(ctx, db.DB, r)
+}
+
+// ReplicationPosition returns the position of the last change applied to the database
+// by ApplyChangeLog.
+func (db *DB) ReplicationPosition() (uint64, error) {
+	return replication.Position(db.DB)
+}
@@ -0,0 +1,205 @@
+package genji_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/genjidb/genji"
+	"github.com/genjidb/genji/internal/testutil"
+	"github.com/genjidb/genji/internal/testutil/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func queryJSON(t *testing.T, db *genji.DB, q string) string {
+	t.Helper()
+
+	res, err := db.Query(q)
+	assert.NoError(t, err)
+	defer res.Close()
+
+	var buf bytes.Buffer
+	err = testutil.IteratorToJSONArray(&buf, res)
+	assert.NoError(t, err)
+	return buf.String()
+}
+
+func replicate(t *testing.T, leader, follower *genji.DB) uint64 {
+	t.Helper()
+
+	from, err := follower.ReplicationPosition()
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	last, err := leader.ExportChangeLog(context.Background(), &buf, from)
+	assert.NoError(t, err)
+
+	pos, err := follower.ApplyChangeLog(context.Background(), &buf)
+	assert.NoError(t, err)
+	require.Equal(t, last, pos)
+
+	return pos
+}
+
+func TestReplication(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genji")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	leader, err := genji.OpenWithOptions(filepath.Join(dir, "leader"), &genji.Options{ChangeLog: true})
+	assert.NoError(t, err)
+	defer leader.Close()
+
+	followerPath := filepath.Join(dir, "follower")
+	follower, err := genji.Open(followerPath)
+	assert.NoError(t, err)
+
+	err = leader.Exec(`
+		CREATE TABLE foo(a INT PRIMARY KEY, b TEXT);
+		CREATE INDEX foo_b ON foo(b);
+		CREATE TABLE bar(a INT, b INT UNIQUE);
+		CREATE SEQUENCE seq;
+		INSERT INTO foo (a, b) VALUES (1, 'a'), (2, 'b'), (3, 'c');
+		INSERT INTO bar (a, b) VALUES (NEXT VALUE FOR seq, 10), (NEXT VALUE FOR seq, 20);
+		UPDATE foo SET b = 'z' WHERE a = 2;
+		DELETE FROM foo WHERE a = 3;
+	`)
+	assert.NoError(t, err)
+
+	// rolled back transactions are not replicated
+	tx, err := leader.Begin(true)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Exec("INSERT INTO foo (a, b) VALUES (10, 'x')"))
+	assert.NoError(t, tx.Rollback())
+
+	pos := replicate(t, leader, follower)
+	require.Equal(t, leader.ChangeLogPosition(), pos)
+
+	require.JSONEq(t, queryJSON(t, leader, "SELECT * FROM foo"), queryJSON(t, follower, "SELECT * FROM foo"))
+	require.JSONEq(t, queryJSON(t, leader, "SELECT * FROM bar"), queryJSON(t, follower, "SELECT * FROM bar"))
+	require.JSONEq(t, `[{"a": 2, "b": "z"}]`, queryJSON(t, follower, "SELECT * FROM foo WHERE b = 'z'"))
+
+	// DDL statements are replicated too
+	err = leader.Exec(`
+		ALTER TABLE foo RENAME TO foo2;
+		DROP INDEX foo_b;
+		CREATE UNIQUE INDEX foo2_b ON foo2(b);
+		DROP TABLE bar;
+		INSERT INTO foo2 (a, b) VALUES (4, 'd');
+	`)
+	assert.NoError(t, err)
+
+	// applying the same changes twice has no effect
+	var buf bytes.Buffer
+	_, err = leader.ExportChangeLog(context.Background(), &buf, 0)
+	assert.NoError(t, err)
+	pos, err = follower.ApplyChangeLog(context.Background(), &buf)
+	assert.NoError(t, err)
+	require.Equal(t, leader.ChangeLogPosition(), pos)
+
+	assert.NoError(t, follower.Close())
+
+	// followers can be opened in read-only mode
+	follower, err = genji.OpenWithOptions(followerPath, &genji.Options{ReadOnly: true})
+	assert.NoError(t, err)
+	defer follower.Close()
+
+	pos, err = follower.ReplicationPosition()
+	assert.NoError(t, err)
+	require.Equal(t, leader.ChangeLogPosition(), pos)
+
+	require.JSONEq(t, queryJSON(t, leader, "SELECT * FROM foo2"), queryJSON(t, follower, "SELECT * FROM foo2"))
+	require.JSONEq(t, queryJSON(t, leader, "SELECT name, sql FROM __genji_catalog"), queryJSON(t, follower, "SELECT name, sql FROM __genji_catalog"))
+	require.JSONEq(t, `[{"a": 4, "b": "d"}]`, queryJSON(t, follower, "SELECT * FROM foo2 WHERE b = 'd'"))
+	require.JSONEq(t, `[{"plan": "index.Scan(\"foo2_b\", [{\"min\": [\"d\"], \"exact\": true}])"}]`, queryJSON(t, follower, "EXPLAIN SELECT * FROM foo2 WHERE b = 'd'"))
+
+	err = follower.Exec("INSERT INTO foo2 (a, b) VALUES (5, 'e')")
+	assert.Error(t, err)
+}
+
+func TestReplicationFromBackup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genji")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	leaderPath := filepath.Join(dir, "leader")
+	leader, err := genji.Open(leaderPath)
+	assert.NoError(t, err)
+
+	err = leader.Exec(`
+		CREATE TABLE foo(a INT PRIMARY KEY);
+		INSERT INTO foo (a) VALUES (1), (2);
+	`)
+	assert.NoError(t, err)
+	assert.NoError(t, leader.Close())
+
+	// enable the change log on an existing database
+	leader, err = genji.OpenWithOptions(leaderPath, &genji.Options{ChangeLog: true})
+	assert.NoError(t, err)
+	defer leader.Close()
+
+	assert.NoError(t, leader.Exec("INSERT INTO foo (a) VALUES (3)"))
+
+	followerPath := filepath.Join(dir, "follower")
+	assert.NoError(t, leader.Backup(context.Background(), followerPath))
+
+	assert.NoError(t, leader.Exec("INSERT INTO foo (a) VALUES (4)"))
+	assert.NoError(t, leader.Exec("CREATE INDEX foo_a ON foo(a)"))
+
+	follower, err := genji.Open(followerPath)
+	assert.NoError(t, err)
+	defer follower.Close()
+
+	pos, err := follower.ReplicationPosition()
+	assert.NoError(t, err)
+	require.Equal(t, uint64(1), pos)
+
+	// replicate through a pipe
+	r, w := io.Pipe()
+	errc := make(chan error, 1)
+	go func() {
+		_, err := leader.ExportChangeLog(context.Background(), w, pos)
+		errc <- err
+		w.CloseWithError(err)
+	}()
+
+	pos, err = follower.ApplyChangeLog(context.Background(), r)
+	assert.NoError(t, err)
+	assert.NoError(t, <-errc)
+	require.Equal(t, uint64(3), pos)
+
+	require.JSONEq(t, `[{"a": 1}, {"a": 2}, {"a": 3}, {"a": 4}]`, queryJSON(t, follower, "SELECT * FROM foo"))
+	require.JSONEq(t, `[{"a": 4}]`, queryJSON(t, follower, "SELECT * FROM foo WHERE a > 3"))
+
+	// pruned changes can no longer be exported
+	assert.NoError(t, leader.PruneChangeLog(2))
+	_, err = leader.ExportChangeLog(context.Background(), io.Discard, 1)
+	assert.Error(t, err)
+
+	var buf bytes.Buffer
+	_, err = leader.ExportChangeLog(context.Background(), &buf, 2)
+	assert.NoError(t, err)
+	require.NotZero(t, buf.Len())
+
+	// gaps are detected by the follower
+	empty, err := genji.Open(":memory:")
+	assert.NoError(t, err)
+	defer empty.Close()
+
+	_, err = empty.ApplyChangeLog(context.Background(), &buf)
+	assert.Error(t, err)
+
+	// truncated input
+	_, err = follower.ApplyChangeLog(context.Background(), strings.NewReader("\x04\x10abc"))
+	assert.Error(t, err)
+
+	// invalid entry size, which must not be allocated
+	_, err = follower.ApplyChangeLog(context.Background(), strings.NewReader("\x04\xff\xff\xff\xff\xff\xff\xff\xff\x7fabc"))
+	assert.Error(t, err)
+	require.Contains(t, err.Error(), "exceeds the maximum size")
+}
@@ -0,0 +1,44 @@
+-- setup:
+CREATE TABLE a(id INT PRIMARY KEY, x INT);
+CREATE TABLE b(id INT PRIMARY KEY, aid INT);
+INSERT INTO a (id, x) VALUES (1, 10), (2, 20), (3, 30);
+INSERT INTO b (id, aid) VALUES (1, 1), (2, 1), (3, 2);
+
+-- suite: no index
+
+-- suite: index on b
+CREATE INDEX ON b(aid);
+
+-- suite: index on a
+CREATE INDEX ON a(x);
+
+-- test: exists
+DELETE FROM a WHERE EXISTS (SELECT 1 FROM b WHERE b.aid = a.id);
+SELECT * FROM a;
+/* result:
+{"id": 3, "x": 30}
+*/
+
+-- test: not exists
+DELETE FROM a WHERE NOT EXISTS (SELECT 1 FROM b WHERE b.aid = a.id);
+SELECT * FROM a;
+/* result:
+{"id": 1, "x": 10}
+{"id": 2, "x": 20}
+*/
+
+-- test: correlated subquery in a comparison
+DELETE FROM a WHERE x < (SELECT count(*) FROM b WHERE b.aid = a.id) * 15;
+SELECT * FROM a;
+/* result:
+{"id": 2, "x": 20}
+{"id": 3, "x": 30}
+*/
+
+-- test: correlated subquery with ORDER BY
+DELETE FROM a WHERE x > 10 ORDER BY (SELECT count(*) FROM b WHERE b.aid = a.id) LIMIT 1;
+SELECT * FROM a;
+/* result:
+{"id": 1, "x": 10}
+{"id": 2, "x": 20}
+*/
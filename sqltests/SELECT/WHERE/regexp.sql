@@ -0,0 +1,72 @@
+-- setup:
+CREATE TABLE logs(id int primary key, level text, msg text);
+INSERT INTO logs (id, level, msg) VALUES
+    (1, 'error', 'connection refused: 10.0.0.1:5432'),
+    (2, 'info', 'user alice logged in'),
+    (3, 'warn', 'slow query: 1200ms'),
+    (4, 'ERROR', 'disk full'),
+    (5, 'info', 'user bob logged out');
+INSERT INTO logs (id, level) VALUES (6, 'debug');
+
+-- suite: no index
+
+-- suite: index on level
+CREATE INDEX ON logs(level);
+
+-- test: =~
+SELECT id FROM logs WHERE msg =~ '^user \\w+ logged';
+/* result:
+{id: 2}
+{id: 5}
+*/
+
+-- test: !~
+SELECT id FROM logs WHERE level !~ '^(info|debug)$';
+/* result:
+{id: 1}
+{id: 3}
+{id: 4}
+*/
+
+-- test: NULL never matches
+SELECT id FROM logs WHERE msg !~ 'user';
+/* result:
+{id: 1}
+{id: 3}
+{id: 4}
+*/
+
+-- test: case insensitive
+SELECT id FROM logs WHERE level =~ '(?i)^error$';
+/* result:
+{id: 1}
+{id: 4}
+*/
+
+-- test: pattern from another field
+SELECT id FROM logs WHERE 'error' =~ level;
+/* result:
+{id: 1}
+*/
+
+-- test: pattern from concatenation
+SELECT id FROM logs WHERE msg =~ 'logged ' || 'out';
+/* result:
+{id: 5}
+*/
+
+-- test: regexp_extract
+SELECT id, regexp_extract(msg, '(\\d+)ms') AS ms FROM logs WHERE msg =~ 'ms$';
+/* result:
+{id: 3, ms: "1200"}
+*/
+
+-- test: regexp_replace
+SELECT id, regexp_replace(msg, '\\d+\\.\\d+\\.\\d+\\.\\d+', 'x.x.x.x') AS msg FROM logs WHERE id = 1;
+/* result:
+{id: 1, msg: "connection refused: x.x.x.x:5432"}
+*/
+
+-- test: invalid pattern
+SELECT id FROM logs WHERE msg =~ '(';
+-- error:
@@ -0,0 +1,96 @@
+-- setup:
+CREATE TABLE test;
+INSERT INTO test (id, a, b) VALUES (1, 10, 'x');
+INSERT INTO test (id, a) VALUES (2, 20);
+INSERT INTO test (id, b) VALUES (3, 'y');
+INSERT INTO test (id, a, b) VALUES (4, NULL, '');
+
+-- test: coalesce with missing fields
+SELECT id, COALESCE(a, -1) AS a, COALESCE(b, 'none') AS b FROM test;
+/* result:
+{id: 1.0, a: 10.0, b: "x"}
+{id: 2.0, a: 20.0, b: "none"}
+{id: 3.0, a: -1, b: "y"}
+{id: 4.0, a: -1, b: ""}
+*/
+
+-- test: ifnull and nullif
+SELECT id, IFNULL(NULLIF(b, ''), 'none') AS b FROM test;
+/* result:
+{id: 1.0, b: "x"}
+{id: 2.0, b: "none"}
+{id: 3.0, b: "y"}
+{id: 4.0, b: "none"}
+*/
+
+-- test: greatest and least
+SELECT id, GREATEST(a, 15) AS g, LEAST(a, 15) AS l FROM test WHERE id < 4;
+/* result:
+{id: 1.0, g: 15, l: 10.0}
+{id: 2.0, g: 20.0, l: 15}
+{id: 3.0, g: 15, l: 15}
+*/
+
+-- test: searched case
+SELECT id, CASE WHEN a IS NULL THEN 'missing' WHEN a > 15 THEN 'big' ELSE 'small' END AS size FROM test;
+/* result:
+{id: 1.0, size: "small"}
+{id: 2.0, size: "big"}
+{id: 3.0, size: "missing"}
+{id: 4.0, size: "missing"}
+*/
+
+-- test: simple case
+SELECT id, CASE b WHEN 'x' THEN 1 WHEN 'y' THEN 2 END AS n FROM test;
+/* result:
+{id: 1.0, n: 1}
+{id: 2.0, n: NULL}
+{id: 3.0, n: 2}
+{id: 4.0, n: NULL}
+*/
+
+-- test: case in where
+SELECT id FROM test WHERE CASE WHEN a IS NULL THEN b = 'y' ELSE a > 15 END;
+/* result:
+{id: 2.0}
+{id: 3.0}
+*/
+
+-- test: coalesce in where
+SELECT id FROM test WHERE COALESCE(a, 0) < 15;
+/* result:
+{id: 1.0}
+{id: 3.0}
+{id: 4.0}
+*/
+
+-- test: case in order by
+SELECT id, CASE WHEN a IS NULL THEN 0 ELSE a END AS s FROM test ORDER BY CASE WHEN a IS NULL THEN 0 ELSE a END DESC, id;
+/* result:
+{id: 2.0, s: 20.0}
+{id: 1.0, s: 10.0}
+{id: 3.0, s: 0}
+{id: 4.0, s: 0}
+*/
+
+-- test: case with group by
+SELECT CASE WHEN a IS NULL THEN 'missing' ELSE 'present' END AS k, COUNT(*) AS n FROM test GROUP BY CASE WHEN a IS NULL THEN 'missing' ELSE 'present' END;
+/* result:
+{k: "missing", n: 2}
+{k: "present", n: 2}
+*/
+
+-- test: case of aggregates
+SELECT b IS NULL AS k, CASE WHEN COUNT(*) > 1 THEN 'many' ELSE 'one' END AS n FROM test GROUP BY b IS NULL;
+/* result:
+{k: false, n: "many"}
+{k: true, n: "one"}
+*/
+
+-- test: case with ungrouped field
+SELECT CASE WHEN a > 1 THEN 1 END FROM test GROUP BY b;
+-- error:
+
+-- test: coalesce with no arguments
+SELECT COALESCE() FROM test;
+-- error:
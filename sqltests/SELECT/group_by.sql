@@ -0,0 +1,115 @@
+-- setup:
+CREATE TABLE sales(id INT PRIMARY KEY, region TEXT, product TEXT, qty INT, info.store TEXT);
+INSERT INTO sales (id, region, product, qty, info) VALUES
+    (1, 'eu', 'apple', 10, {store: 'paris'}),
+    (2, 'eu', 'apple', 5, {store: 'lyon'}),
+    (3, 'eu', 'pear', 7, {store: 'paris'}),
+    (4, 'us', 'apple', 3, {store: 'nyc'}),
+    (5, 'us', 'pear', 2, {store: 'nyc'}),
+    (6, 'us', 'pear', 8, {store: 'sf'});
+
+-- test: multiple expressions
+SELECT region, product, COUNT(*), SUM(qty) FROM sales GROUP BY region, product;
+/* result:
+{region: "eu", product: "apple", "COUNT(*)": 2, "SUM(qty)": 15}
+{region: "eu", product: "pear", "COUNT(*)": 1, "SUM(qty)": 7}
+{region: "us", product: "apple", "COUNT(*)": 1, "SUM(qty)": 3}
+{region: "us", product: "pear", "COUNT(*)": 2, "SUM(qty)": 10}
+*/
+
+-- test: nested path and function
+SELECT info.store, typeof(qty) AS t, COUNT(*) AS n FROM sales GROUP BY info.store, typeof(qty);
+/* result:
+{"info.store": "lyon", t: "integer", n: 1}
+{"info.store": "nyc", t: "integer", n: 2}
+{"info.store": "paris", t: "integer", n: 2}
+{"info.store": "sf", t: "integer", n: 1}
+*/
+
+-- test: expressions of grouped fields
+SELECT region || '/' || product AS k, SUM(qty) * 2 AS s FROM sales GROUP BY region, product;
+/* result:
+{k: "eu/apple", s: 30}
+{k: "eu/pear", s: 14}
+{k: "us/apple", s: 6}
+{k: "us/pear", s: 20}
+*/
+
+-- test: having
+SELECT region, product FROM sales GROUP BY region, product HAVING COUNT(*) > 1;
+/* result:
+{region: "eu", product: "apple"}
+{region: "us", product: "pear"}
+*/
+
+-- test: having on a grouped field
+SELECT region, SUM(qty) AS total FROM sales GROUP BY region HAVING region = 'us' OR SUM(qty) > 100;
+/* result:
+{region: "us", total: 13}
+*/
+
+-- test: having with where
+SELECT product, MAX(qty) AS m FROM sales WHERE region = 'eu' GROUP BY product HAVING MAX(qty) >= 7;
+/* result:
+{product: "apple", m: 10}
+{product: "pear", m: 7}
+*/
+
+-- test: rollup
+SELECT region, product, SUM(qty) AS total FROM sales GROUP BY ROLLUP(region, product);
+/* result:
+{region: "eu", product: "apple", total: 15}
+{region: "eu", product: "pear", total: 7}
+{region: "us", product: "apple", total: 3}
+{region: "us", product: "pear", total: 10}
+{region: "eu", product: NULL, total: 22}
+{region: "us", product: NULL, total: 13}
+{region: NULL, product: NULL, total: 35}
+*/
+
+-- test: grouping sets
+SELECT region, product, COUNT(*) AS n FROM sales GROUP BY GROUPING SETS ((region), (product), ());
+/* result:
+{region: "eu", product: NULL, n: 3}
+{region: "us", product: NULL, n: 3}
+{region: NULL, product: "apple", n: 3}
+{region: NULL, product: "pear", n: 3}
+{region: NULL, product: NULL, n: 6}
+*/
+
+-- test: rollup with having
+SELECT region, product, SUM(qty) AS total FROM sales GROUP BY region, ROLLUP(product) HAVING SUM(qty) > 10;
+/* result:
+{region: "eu", product: "apple", total: 15}
+{region: "eu", product: NULL, total: 22}
+{region: "us", product: NULL, total: 13}
+*/
+
+-- test: rollup on empty input
+SELECT region, COUNT(*) AS n FROM sales WHERE qty > 100 GROUP BY ROLLUP(region);
+/* result:
+{region: NULL, n: 0}
+*/
+
+-- test: ungrouped field
+SELECT region, product FROM sales GROUP BY region;
+-- error:
+
+-- test: ungrouped field in having
+SELECT region FROM sales GROUP BY region HAVING qty > 1;
+-- error:
+
+-- test: having without group by
+SELECT COUNT(*) FROM sales HAVING COUNT(*) > 1;
+/* result:
+{"COUNT(*)": 6}
+*/
+
+-- test: having without group by filtering the single group
+SELECT COUNT(*), SUM(qty) FROM sales WHERE region = 'us' HAVING SUM(qty) > 100;
+/* result:
+*/
+
+-- test: ungrouped field in having without group by
+SELECT COUNT(*) FROM sales HAVING qty > 1;
+-- error:
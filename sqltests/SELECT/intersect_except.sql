@@ -0,0 +1,113 @@
+-- setup:
+CREATE TABLE foo(id INT PRIMARY KEY, a INT);
+CREATE TABLE bar(id INT PRIMARY KEY, a INT);
+INSERT INTO foo (id, a) VALUES (1, 1), (2, 1), (3, 2), (4, 3), (5, 3), (6, 3);
+INSERT INTO bar (id, a) VALUES (1, 1), (2, 3), (3, 3), (4, 4);
+
+-- test: intersect
+SELECT a FROM foo
+INTERSECT
+SELECT a FROM bar;
+/* result:
+{a: 1}
+{a: 3}
+*/
+
+-- test: intersect all
+SELECT a FROM foo
+INTERSECT ALL
+SELECT a FROM bar;
+/* result:
+{a: 1}
+{a: 3}
+{a: 3}
+*/
+
+-- test: intersect whole documents
+SELECT * FROM foo
+INTERSECT
+SELECT * FROM bar;
+/* result:
+{id: 1, a: 1}
+*/
+
+-- test: multiple intersects
+SELECT a FROM foo
+INTERSECT
+SELECT a FROM bar
+INTERSECT
+SELECT a FROM foo WHERE a > 1;
+/* result:
+{a: 3}
+*/
+
+-- test: except
+SELECT a FROM foo
+EXCEPT
+SELECT a FROM bar;
+/* result:
+{a: 2}
+*/
+
+-- test: except all
+SELECT a FROM foo
+EXCEPT ALL
+SELECT a FROM bar;
+/* result:
+{a: 1}
+{a: 2}
+{a: 3}
+*/
+
+-- test: except is not symmetric
+SELECT a FROM bar
+EXCEPT
+SELECT a FROM foo;
+/* result:
+{a: 4}
+*/
+
+-- test: multiple excepts
+SELECT a FROM foo
+EXCEPT ALL
+SELECT a FROM bar
+EXCEPT ALL
+SELECT a FROM foo WHERE id = 1;
+/* result:
+{a: 2}
+{a: 3}
+*/
+
+-- test: except with empty result
+SELECT a FROM foo WHERE a = 1
+EXCEPT
+SELECT a FROM bar;
+/* result:
+*/
+
+-- test: operators are applied from left to right
+SELECT a FROM foo
+EXCEPT
+SELECT a FROM bar
+UNION
+SELECT a FROM bar WHERE a = 4;
+/* result:
+{a: 2}
+{a: 4}
+*/
+
+-- test: with order by and limit
+SELECT a FROM foo
+INTERSECT ALL
+SELECT a FROM bar
+ORDER BY a DESC
+LIMIT 2;
+/* result:
+{a: 3}
+{a: 3}
+*/
+
+-- test: missing select
+SELECT a FROM foo
+EXCEPT;
+-- error:
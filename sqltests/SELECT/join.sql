@@ -0,0 +1,123 @@
+-- setup:
+CREATE TABLE a(id INT PRIMARY KEY, x INT);
+CREATE TABLE b(id INT PRIMARY KEY, aid INT, y TEXT);
+INSERT INTO a (id, x) VALUES (1, 10), (2, 20), (3, 30);
+INSERT INTO b (id, aid, y) VALUES (1, 1, 'foo'), (2, 1, 'bar'), (3, 2, 'baz');
+
+-- test: inner join
+SELECT a.id, b.y FROM a JOIN b ON a.id = b.aid;
+/* result:
+{"a.id": 1, "b.y": "foo"}
+{"a.id": 1, "b.y": "bar"}
+{"a.id": 2, "b.y": "baz"}
+*/
+
+-- test: inner join using an index
+CREATE INDEX b_aid ON b(aid);
+SELECT a.id, b.y FROM a INNER JOIN b ON a.id = b.aid;
+/* result:
+{"a.id": 1, "b.y": "foo"}
+{"a.id": 1, "b.y": "bar"}
+{"a.id": 2, "b.y": "baz"}
+*/
+
+-- test: wildcard
+SELECT * FROM a JOIN b ON a.id = b.aid WHERE b.y = 'baz';
+/* result:
+{"a": {"id": 2, "x": 20}, "b": {"id": 3, "aid": 2, "y": "baz"}}
+*/
+
+-- test: left join
+SELECT a.id, b.y FROM a LEFT JOIN b ON a.id = b.aid;
+/* result:
+{"a.id": 1, "b.y": "foo"}
+{"a.id": 1, "b.y": "bar"}
+{"a.id": 2, "b.y": "baz"}
+{"a.id": 3, "b.y": null}
+*/
+
+-- test: left outer join without match
+SELECT * FROM a LEFT OUTER JOIN b ON a.id = b.aid WHERE b IS NULL;
+/* result:
+{"a": {"id": 3, "x": 30}, "b": null}
+*/
+
+-- test: cross join
+SELECT COUNT(*) FROM a CROSS JOIN b;
+/* result:
+{"COUNT(*)": 9}
+*/
+
+-- test: comma
+SELECT a.id, b.id FROM a, b WHERE a.x = 30 AND b.y = 'foo';
+/* result:
+{"a.id": 3, "b.id": 1}
+*/
+
+-- test: aliases
+SELECT t.x, u.y FROM a AS t JOIN b u ON t.id = u.aid AND u.y > 'bar';
+/* result:
+{"t.x": 10, "u.y": "foo"}
+{"t.x": 20, "u.y": "baz"}
+*/
+
+-- test: self join
+SELECT b1.id, b2.id FROM b AS b1 JOIN b AS b2 ON b1.aid = b2.aid AND b1.id < b2.id;
+/* result:
+{"b1.id": 1, "b2.id": 2}
+*/
+
+-- test: unqualified fields
+SELECT x, y FROM a JOIN b ON a.id = b.aid WHERE aid = 2;
+/* result:
+{"x": 20, "y": "baz"}
+*/
+
+-- test: group by
+SELECT a.x, COUNT(b.id) FROM a LEFT JOIN b ON a.id = b.aid GROUP BY a.x;
+/* result:
+{"a.x": 10, "COUNT(b.id)": 2}
+{"a.x": 20, "COUNT(b.id)": 1}
+{"a.x": 30, "COUNT(b.id)": 0}
+*/
+
+-- test: order by
+SELECT a.id, b.y FROM a JOIN b ON a.id = b.aid ORDER BY b.y;
+/* result:
+{"a.id": 1, "b.y": "bar"}
+{"a.id": 2, "b.y": "baz"}
+{"a.id": 1, "b.y": "foo"}
+*/
+
+-- test: alias of a single table
+SELECT t.x, id FROM a AS t WHERE t.x > 10;
+/* result:
+{"t.x": 20, "id": 2}
+{"t.x": 30, "id": 3}
+*/
+
+-- test: same table twice
+SELECT * FROM a JOIN a ON a.id = a.id;
+-- error:
+
+-- test: join without condition
+SELECT * FROM a JOIN b;
+-- error:
+
+-- test: ambiguous field in WHERE
+SELECT a.id FROM a JOIN b ON a.id = b.aid WHERE id = 1;
+-- error: ambiguous column "id"
+
+-- test: ambiguous field in ON
+SELECT a.id FROM a JOIN b ON id = b.aid;
+-- error: ambiguous column "id"
+
+-- test: ambiguous field without documents
+CREATE TABLE c(id INT PRIMARY KEY);
+SELECT id FROM a, c;
+-- error: ambiguous column "id"
+
+-- test: ambiguous field in subquery
+CREATE TABLE c(id INT PRIMARY KEY);
+SELECT * FROM a WHERE EXISTS (SELECT 1 FROM b, c WHERE id = 1);
+-- error: ambiguous column "id"
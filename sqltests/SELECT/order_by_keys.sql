@@ -0,0 +1,83 @@
+-- setup:
+CREATE TABLE test(id INT PRIMARY KEY, a INT, b INT, c TEXT);
+INSERT INTO test (id, a, b, c) VALUES (1, 1, 2, 'x'), (2, 2, 1, 'Y'), (3, 1, NULL, 'z'), (4, NULL, 3, 'W'), (5, 2, 3, 'v'), (6, 1, 1, 'U');
+
+-- suite: no index
+
+-- suite: with composite index
+CREATE INDEX ON test(a, b);
+
+-- test: multiple keys
+SELECT id, a, b FROM test ORDER BY a, b;
+/* result:
+{id: 4, a: NULL, b: 3}
+{id: 3, a: 1, b: NULL}
+{id: 6, a: 1, b: 1}
+{id: 1, a: 1, b: 2}
+{id: 2, a: 2, b: 1}
+{id: 5, a: 2, b: 3}
+*/
+
+-- test: multiple keys DESC
+SELECT id, a, b FROM test ORDER BY a DESC, b DESC;
+/* result:
+{id: 5, a: 2, b: 3}
+{id: 2, a: 2, b: 1}
+{id: 1, a: 1, b: 2}
+{id: 6, a: 1, b: 1}
+{id: 3, a: 1, b: NULL}
+{id: 4, a: NULL, b: 3}
+*/
+
+-- test: mixed directions
+SELECT id, a, b FROM test ORDER BY a DESC, b ASC;
+/* result:
+{id: 2, a: 2, b: 1}
+{id: 5, a: 2, b: 3}
+{id: 3, a: 1, b: NULL}
+{id: 6, a: 1, b: 1}
+{id: 1, a: 1, b: 2}
+{id: 4, a: NULL, b: 3}
+*/
+
+-- test: nulls last
+SELECT id, a, b FROM test ORDER BY a NULLS LAST, b DESC NULLS FIRST;
+/* result:
+{id: 3, a: 1, b: NULL}
+{id: 1, a: 1, b: 2}
+{id: 6, a: 1, b: 1}
+{id: 5, a: 2, b: 3}
+{id: 2, a: 2, b: 1}
+{id: 4, a: NULL, b: 3}
+*/
+
+-- test: expression
+SELECT id, b - a FROM test ORDER BY b - a DESC, id;
+/* result:
+{id: 1, "b - a": 1}
+{id: 5, "b - a": 1}
+{id: 6, "b - a": 0}
+{id: 2, "b - a": -1}
+{id: 3, "b - a": NULL}
+{id: 4, "b - a": NULL}
+*/
+
+-- test: aliased expression
+SELECT id, b - a AS d FROM test ORDER BY b - a, id LIMIT 3;
+/* result:
+{id: 3, d: NULL}
+{id: 4, d: NULL}
+{id: 2, d: -1}
+*/
+
+-- test: ties are sorted by primary key
+SELECT id, a FROM test WHERE a IS NOT NULL ORDER BY a DESC, id LIMIT 3 OFFSET 1;
+/* result:
+{id: 5, a: 2}
+{id: 1, a: 1}
+{id: 3, a: 1}
+*/
+
+-- test: nulls without first or last
+SELECT id FROM test ORDER BY a NULLS;
+-- error:
@@ -0,0 +1,123 @@
+-- setup:
+CREATE TABLE a(id INT PRIMARY KEY, x INT);
+CREATE TABLE b(id INT PRIMARY KEY, aid INT, y TEXT);
+INSERT INTO a (id, x) VALUES (1, 10), (2, 20), (3, 30);
+INSERT INTO b (id, aid, y) VALUES (1, 1, 'foo'), (2, 1, 'bar'), (3, 2, 'baz'), (4, null, 'qux');
+
+-- test: in
+SELECT * FROM a WHERE id IN (SELECT aid FROM b);
+/* result:
+{"id": 1, "x": 10}
+{"id": 2, "x": 20}
+*/
+
+-- test: in using an index
+CREATE INDEX a_x ON a(x);
+SELECT * FROM a WHERE x IN (SELECT aid * 10 FROM b);
+/* result:
+{"id": 1, "x": 10}
+{"id": 2, "x": 20}
+*/
+
+-- test: not in
+SELECT * FROM a WHERE id NOT IN (SELECT aid FROM b WHERE aid IS NOT NULL);
+/* result:
+{"id": 3, "x": 30}
+*/
+
+-- test: exists
+SELECT * FROM a WHERE EXISTS (SELECT 1 FROM b WHERE b.aid = a.id);
+/* result:
+{"id": 1, "x": 10}
+{"id": 2, "x": 20}
+*/
+
+-- test: exists using an index
+CREATE INDEX b_aid ON b(aid);
+SELECT * FROM a WHERE EXISTS (SELECT 1 FROM b WHERE b.aid = a.id);
+/* result:
+{"id": 1, "x": 10}
+{"id": 2, "x": 20}
+*/
+
+-- test: not exists
+SELECT * FROM a WHERE NOT EXISTS (SELECT 1 FROM b WHERE b.aid = a.id);
+/* result:
+{"id": 3, "x": 30}
+*/
+
+-- test: correlated in
+SELECT * FROM a WHERE x IN (SELECT aid * 10 FROM b WHERE b.aid = a.id);
+/* result:
+{"id": 1, "x": 10}
+{"id": 2, "x": 20}
+*/
+
+-- test: nested correlated subqueries
+SELECT id FROM a WHERE EXISTS (SELECT 1 FROM b WHERE b.aid = a.id AND EXISTS (SELECT 1 FROM a AS c WHERE c.id = b.id AND c.x = a.x));
+/* result:
+{"id": 1}
+*/
+
+-- test: scalar subquery in projection
+SELECT id, (SELECT COUNT(*) FROM b WHERE b.aid = a.id) AS n FROM a;
+/* result:
+{"id": 1, "n": 2}
+{"id": 2, "n": 1}
+{"id": 3, "n": 0}
+*/
+
+-- test: scalar subquery in condition
+SELECT * FROM a WHERE x = (SELECT MAX(x) FROM a);
+/* result:
+{"id": 3, "x": 30}
+*/
+
+-- test: scalar subquery without documents
+SELECT (SELECT y FROM b WHERE id = 10) AS y;
+/* result:
+{"y": null}
+*/
+
+-- test: scalar subquery name
+SELECT (SELECT MAX(aid) FROM b) FROM a WHERE id = 1;
+/* result:
+{"(SELECT MAX(aid) FROM b)": 2}
+*/
+
+-- test: scalar subquery with multiple documents
+SELECT * FROM a WHERE x = (SELECT aid FROM b);
+-- error:
+
+-- test: scalar subquery with multiple documents in projection
+SELECT id, (SELECT aid FROM b) AS n FROM a;
+-- error:
+
+-- test: subquery with multiple fields
+SELECT * FROM a WHERE x IN (SELECT aid, y FROM b);
+-- error:
+
+-- test: update
+UPDATE a SET x = (SELECT COUNT(*) FROM b) WHERE id IN (SELECT aid FROM b);
+SELECT * FROM a;
+/* result:
+{"id": 1, "x": 4}
+{"id": 2, "x": 4}
+{"id": 3, "x": 30}
+*/
+
+-- test: delete
+DELETE FROM a WHERE id IN (SELECT aid FROM b WHERE y = 'baz');
+SELECT * FROM a;
+/* result:
+{"id": 1, "x": 10}
+{"id": 3, "x": 30}
+*/
+
+-- test: uncorrelated subquery evaluated once
+DELETE FROM a WHERE x = (SELECT MIN(x) FROM a);
+SELECT * FROM a;
+/* result:
+{"id": 2, "x": 20}
+{"id": 3, "x": 30}
+*/
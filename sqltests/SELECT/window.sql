@@ -0,0 +1,137 @@
+-- setup:
+CREATE TABLE ev(id INT PRIMARY KEY, device TEXT, ts INT, v INT);
+INSERT INTO ev (id, device, ts, v) VALUES (1, 'a', 10, 5), (2, 'a', 20, 3), (3, 'b', 10, 7), (4, 'a', 30, 3), (5, 'b', 40, 1), (6, 'c', 5, 2), (7, 'a', 40, 8);
+
+-- test: row_number
+SELECT id, device, ROW_NUMBER() OVER (PARTITION BY device ORDER BY ts DESC) AS rn FROM ev ORDER BY id;
+/* result:
+{id: 1, device: "a", rn: 4}
+{id: 2, device: "a", rn: 3}
+{id: 3, device: "b", rn: 2}
+{id: 4, device: "a", rn: 2}
+{id: 5, device: "b", rn: 1}
+{id: 6, device: "c", rn: 1}
+{id: 7, device: "a", rn: 1}
+*/
+
+-- test: latest N per device
+WITH r AS (
+    SELECT id, device, ts, ROW_NUMBER() OVER (PARTITION BY device ORDER BY ts DESC) AS rn FROM ev
+)
+SELECT id, device, ts FROM r WHERE rn <= 2;
+/* result:
+{id: 7, device: "a", ts: 40}
+{id: 4, device: "a", ts: 30}
+{id: 5, device: "b", ts: 40}
+{id: 3, device: "b", ts: 10}
+{id: 6, device: "c", ts: 5}
+*/
+
+-- test: rank and dense_rank
+SELECT id, v, RANK() OVER (ORDER BY v) AS r, DENSE_RANK() OVER (ORDER BY v) AS dr FROM ev;
+/* result:
+{id: 5, v: 1, r: 1, dr: 1}
+{id: 6, v: 2, r: 2, dr: 2}
+{id: 2, v: 3, r: 3, dr: 3}
+{id: 4, v: 3, r: 3, dr: 3}
+{id: 1, v: 5, r: 5, dr: 4}
+{id: 3, v: 7, r: 6, dr: 5}
+{id: 7, v: 8, r: 7, dr: 6}
+*/
+
+-- test: lag, lead and first_value
+SELECT
+    id,
+    ts,
+    LAG(ts) OVER (PARTITION BY device ORDER BY ts) AS prev,
+    LEAD(ts, 1, -1) OVER (PARTITION BY device ORDER BY ts) AS nxt,
+    FIRST_VALUE(id) OVER (PARTITION BY device ORDER BY ts) AS fst
+FROM ev;
+/* result:
+{id: 1, ts: 10, prev: NULL, nxt: 20, fst: 1}
+{id: 2, ts: 20, prev: 10, nxt: 30, fst: 1}
+{id: 4, ts: 30, prev: 20, nxt: 40, fst: 1}
+{id: 7, ts: 40, prev: 30, nxt: -1, fst: 1}
+{id: 3, ts: 10, prev: NULL, nxt: 40, fst: 3}
+{id: 5, ts: 40, prev: 10, nxt: -1, fst: 3}
+{id: 6, ts: 5, prev: NULL, nxt: -1, fst: 6}
+*/
+
+-- test: lag with offset
+SELECT id, LAG(id, 2) OVER (ORDER BY id) AS l FROM ev WHERE id < 5;
+/* result:
+{id: 1, l: NULL}
+{id: 2, l: NULL}
+{id: 3, l: 1}
+{id: 4, l: 2}
+*/
+
+-- test: running aggregates
+SELECT id, v, SUM(v) OVER (ORDER BY v) AS s, COUNT(*) OVER () AS c, MIN(v) OVER (PARTITION BY device) AS m FROM ev ORDER BY id;
+/* result:
+{id: 1, v: 5, s: 14, c: 7, m: 3}
+{id: 2, v: 3, s: 9, c: 7, m: 3}
+{id: 3, v: 7, s: 21, c: 7, m: 1}
+{id: 4, v: 3, s: 9, c: 7, m: 3}
+{id: 5, v: 1, s: 1, c: 7, m: 1}
+{id: 6, v: 2, s: 3, c: 7, m: 2}
+{id: 7, v: 8, s: 29, c: 7, m: 3}
+*/
+
+-- test: avg and max by partition
+SELECT id, AVG(v) OVER (PARTITION BY device) AS a, MAX(v) OVER (PARTITION BY device ORDER BY ts) AS m FROM ev WHERE device = 'a';
+/* result:
+{id: 1, a: 4.75, m: 5}
+{id: 2, a: 4.75, m: 5}
+{id: 4, a: 4.75, m: 5}
+{id: 7, a: 4.75, m: 8}
+*/
+
+-- test: with limit
+SELECT id, ROW_NUMBER() OVER (ORDER BY ts DESC, id) AS rn FROM ev LIMIT 3;
+/* result:
+{id: 5, rn: 1}
+{id: 7, rn: 2}
+{id: 4, rn: 3}
+*/
+
+-- test: without table
+SELECT ROW_NUMBER() OVER () AS rn;
+/* result:
+{rn: 1}
+*/
+
+-- test: empty documents
+SELECT id, RANK() OVER (PARTITION BY {} ORDER BY [{}] DESC) AS r, FIRST_VALUE({a: {}}) OVER (PARTITION BY device) AS f FROM ev WHERE id < 3;
+/* result:
+{id: 1, r: 1, f: {a: {}}}
+{id: 2, r: 1, f: {a: {}}}
+*/
+
+-- test: in WHERE
+SELECT id FROM ev WHERE ROW_NUMBER() OVER () > 1;
+-- error:
+
+-- test: without OVER
+SELECT id, ROW_NUMBER() FROM ev;
+-- error:
+
+-- test: OVER on a scalar function
+SELECT id, typeof(v) OVER () FROM ev;
+-- error:
+
+-- test: with aggregate functions
+SELECT COUNT(*), ROW_NUMBER() OVER () FROM ev;
+-- error:
+
+-- test: with GROUP BY
+SELECT device, ROW_NUMBER() OVER () FROM ev GROUP BY device;
+-- error:
+
+-- test: nested
+SELECT LAG(ROW_NUMBER() OVER ()) OVER () FROM ev;
+-- error:
+
+-- test: negative offset
+SELECT LAG(id, -1) OVER () FROM ev;
+-- error:
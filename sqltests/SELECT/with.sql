@@ -0,0 +1,160 @@
+-- setup:
+CREATE TABLE emp(id INT PRIMARY KEY, name TEXT, manager_id INT);
+CREATE INDEX emp_manager ON emp(manager_id);
+INSERT INTO emp (id, name, manager_id) VALUES
+    (1, 'ceo', null),
+    (2, 'cto', 1),
+    (3, 'cfo', 1),
+    (4, 'dev1', 2),
+    (5, 'dev2', 2),
+    (6, 'intern', 4);
+
+-- test: simple
+WITH t AS (SELECT id, name FROM emp WHERE manager_id = 1) SELECT * FROM t;
+/* result:
+{"id": 2, "name": "cto"}
+{"id": 3, "name": "cfo"}
+*/
+
+-- test: filter
+WITH t AS (SELECT id, name FROM emp WHERE manager_id = 1) SELECT name FROM t WHERE id > 2;
+/* result:
+{"name": "cfo"}
+*/
+
+-- test: field names
+WITH t(i, n) AS (SELECT id, name FROM emp WHERE manager_id = 1) SELECT n FROM t WHERE i = 2;
+/* result:
+{"n": "cto"}
+*/
+
+-- test: filter on a field not selected
+WITH t AS (SELECT id FROM emp) SELECT * FROM t WHERE manager_id = 1;
+/* result:
+*/
+
+-- test: filter and order by field names
+WITH t(i, m) AS (SELECT id, manager_id FROM emp) SELECT i FROM t WHERE m = 2 OR i = 1 ORDER BY i DESC;
+/* result:
+{"i": 5}
+{"i": 4}
+{"i": 1}
+*/
+
+-- test: read twice
+WITH t AS (SELECT id, manager_id FROM emp) SELECT t.id, u.id FROM t JOIN t AS u ON t.manager_id = u.id WHERE u.id = 2;
+/* result:
+{"t.id": 4, "u.id": 2}
+{"t.id": 5, "u.id": 2}
+*/
+
+-- test: multiple
+WITH a AS (SELECT id FROM emp WHERE id < 4), b AS (SELECT id FROM a WHERE id > 1) SELECT * FROM b;
+/* result:
+{"id": 2}
+{"id": 3}
+*/
+
+-- test: subquery
+WITH managers AS (SELECT manager_id FROM emp WHERE manager_id IS NOT NULL)
+SELECT name FROM emp WHERE id NOT IN (SELECT manager_id FROM managers);
+/* result:
+{"name": "cfo"}
+{"name": "dev2"}
+{"name": "intern"}
+*/
+
+-- test: order by and limit
+WITH t AS (SELECT id, name FROM emp ORDER BY id DESC LIMIT 3) SELECT name FROM t ORDER BY name;
+/* result:
+{"name": "dev1"}
+{"name": "dev2"}
+{"name": "intern"}
+*/
+
+-- test: recursive
+WITH RECURSIVE sub AS (
+    SELECT id, name FROM emp WHERE id = 2
+    UNION ALL
+    SELECT emp.id, emp.name FROM sub JOIN emp ON emp.manager_id = sub.id
+)
+SELECT * FROM sub;
+/* result:
+{"id": 2, "name": "cto"}
+{"id": 4, "name": "dev1"}
+{"id": 5, "name": "dev2"}
+{"id": 6, "name": "intern"}
+*/
+
+-- test: recursive with field names
+WITH RECURSIVE cnt(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM cnt WHERE n < 5) SELECT * FROM cnt;
+/* result:
+{"n": 1}
+{"n": 2}
+{"n": 3}
+{"n": 4}
+{"n": 5}
+*/
+
+-- test: recursive with limit
+WITH RECURSIVE cnt AS (SELECT 1 AS n UNION ALL SELECT n + 1 FROM cnt) SELECT * FROM cnt LIMIT 3;
+/* result:
+{"n": 1}
+{"n": 2}
+{"n": 3}
+*/
+
+-- test: recursive union
+WITH RECURSIVE cnt AS (SELECT 1 AS n UNION SELECT (n + 1) % 3 FROM cnt) SELECT * FROM cnt;
+/* result:
+{"n": 1}
+{"n": 2}
+{"n": 0}
+*/
+
+-- test: recursive read twice
+WITH RECURSIVE cnt AS (SELECT 1 AS n UNION ALL SELECT n + 1 FROM cnt WHERE n < 2)
+SELECT a.n, b.n FROM cnt AS a, cnt AS b;
+/* result:
+{"a.n": 1, "b.n": 1}
+{"a.n": 1, "b.n": 2}
+{"a.n": 2, "b.n": 1}
+{"a.n": 2, "b.n": 2}
+*/
+
+-- test: not visible outside the statement
+WITH t AS (SELECT id FROM emp) SELECT * FROM t;
+SELECT * FROM t;
+-- error:
+
+-- test: read by itself
+WITH t AS (SELECT id FROM t) SELECT * FROM t;
+-- error:
+
+-- test: defined twice
+WITH t AS (SELECT id FROM emp), t AS (SELECT name FROM emp) SELECT * FROM t;
+-- error:
+
+-- test: wrong number of field names
+WITH t(a, b) AS (SELECT id FROM emp) SELECT * FROM t;
+-- error:
+
+-- test: recursive without union
+WITH RECURSIVE t AS (SELECT id FROM t) SELECT * FROM t;
+-- error:
+
+-- test: recursive read by the initial select
+WITH RECURSIVE t AS (SELECT id FROM t UNION ALL SELECT id FROM emp) SELECT * FROM t;
+-- error:
+
+-- test: recursive with order by
+WITH RECURSIVE t AS (SELECT 1 AS n UNION ALL SELECT n + 1 FROM t WHERE n < 3 ORDER BY n) SELECT * FROM t;
+-- error:
+
+-- test: not read-only
+WITH t AS (INSERT INTO emp (id) VALUES (7) RETURNING id) SELECT * FROM t;
+-- error:
+
+-- test: recursive with intersect
+WITH RECURSIVE t AS (SELECT 1 AS n INTERSECT SELECT n + 1 FROM t WHERE n < 3) SELECT * FROM t;
+-- error:
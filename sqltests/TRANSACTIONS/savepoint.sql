@@ -0,0 +1,107 @@
+-- setup:
+CREATE TABLE test(a int primary key, b int);
+
+-- test: rollback to savepoint
+BEGIN;
+INSERT INTO test (a, b) VALUES (1, 1);
+SAVEPOINT sp;
+INSERT INTO test (a, b) VALUES (2, 2);
+UPDATE test SET b = 10 WHERE a = 1;
+ROLLBACK TO SAVEPOINT sp;
+COMMIT;
+SELECT * FROM test;
+/* result:
+{
+  "a": 1,
+  "b": 1
+}
+*/
+
+-- test: rollback to savepoint twice
+BEGIN;
+SAVEPOINT sp;
+INSERT INTO test (a, b) VALUES (1, 1);
+ROLLBACK TO sp;
+INSERT INTO test (a, b) VALUES (2, 2);
+ROLLBACK TO sp;
+INSERT INTO test (a, b) VALUES (3, 3);
+COMMIT;
+SELECT * FROM test;
+/* result:
+{
+  "a": 3,
+  "b": 3
+}
+*/
+
+-- test: nested savepoints
+BEGIN;
+SAVEPOINT a;
+INSERT INTO test (a, b) VALUES (1, 1);
+SAVEPOINT b;
+INSERT INTO test (a, b) VALUES (2, 2);
+ROLLBACK TO a;
+INSERT INTO test (a, b) VALUES (3, 3);
+COMMIT;
+SELECT * FROM test;
+/* result:
+{
+  "a": 3,
+  "b": 3
+}
+*/
+
+-- test: release savepoint
+BEGIN;
+SAVEPOINT sp;
+INSERT INTO test (a, b) VALUES (1, 1);
+RELEASE SAVEPOINT sp;
+COMMIT;
+SELECT * FROM test;
+/* result:
+{
+  "a": 1,
+  "b": 1
+}
+*/
+
+-- test: rollback to released savepoint
+BEGIN;
+SAVEPOINT sp;
+RELEASE sp;
+ROLLBACK TO sp;
+-- error:
+
+-- test: rollback to savepoint undoes CREATE TABLE
+BEGIN;
+SAVEPOINT sp;
+CREATE TABLE foo;
+ROLLBACK TO sp;
+CREATE TABLE foo(a int);
+INSERT INTO foo (a) VALUES (1);
+COMMIT;
+SELECT * FROM foo;
+/* result:
+{
+  "a": 1
+}
+*/
+
+-- test: rollback to savepoint undoes CREATE INDEX
+BEGIN;
+SAVEPOINT sp;
+CREATE UNIQUE INDEX test_b_idx ON test(b);
+ROLLBACK TO sp;
+INSERT INTO test (a, b) VALUES (1, 1);
+INSERT INTO test (a, b) VALUES (2, 1);
+COMMIT;
+SELECT COUNT(*) FROM test;
+/* result:
+{
+  "COUNT(*)": 2
+}
+*/
+
+-- test: savepoint outside of a transaction
+SAVEPOINT sp;
+-- error:
@@ -0,0 +1,49 @@
+-- setup:
+CREATE TABLE a(id INT PRIMARY KEY, x INT);
+CREATE TABLE b(id INT PRIMARY KEY, aid INT, y INT);
+INSERT INTO a (id, x) VALUES (1, 10), (2, 20), (3, 30);
+INSERT INTO b (id, aid, y) VALUES (1, 1, 100), (2, 1, 200), (3, 2, 300);
+
+-- suite: no index
+
+-- suite: index on b
+CREATE INDEX ON b(aid);
+
+-- suite: index on a
+CREATE INDEX ON a(x);
+
+-- test: correlated subquery in SET
+UPDATE a SET x = (SELECT max(y) FROM b WHERE b.aid = a.id);
+SELECT * FROM a;
+/* result:
+{"id": 1, "x": 200}
+{"id": 2, "x": 300}
+{"id": 3, "x": null}
+*/
+
+-- test: correlated subquery in WHERE
+UPDATE a SET x = 0 WHERE EXISTS (SELECT 1 FROM b WHERE b.aid = a.id);
+SELECT * FROM a;
+/* result:
+{"id": 1, "x": 0}
+{"id": 2, "x": 0}
+{"id": 3, "x": 30}
+*/
+
+-- test: correlated subquery using the updated field
+UPDATE a SET x = x + (SELECT count(*) FROM b WHERE b.aid = a.id) WHERE x > 10;
+SELECT * FROM a;
+/* result:
+{"id": 1, "x": 10}
+{"id": 2, "x": 21}
+{"id": 3, "x": 30}
+*/
+
+-- test: qualified field of the updated table
+UPDATE a SET x = a.id WHERE EXISTS (SELECT 1 FROM b WHERE b.y = a.x * 10);
+SELECT * FROM a;
+/* result:
+{"id": 1, "x": 1}
+{"id": 2, "x": 2}
+{"id": 3, "x": 3}
+*/
@@ -0,0 +1,54 @@
+-- test: searched case
+> CASE WHEN 1 > 2 THEN 'a' WHEN 2 > 1 THEN 'b' END
+'b'
+
+> CASE WHEN 1 > 2 THEN 'a' ELSE 'c' END
+'c'
+
+> CASE WHEN 1 > 2 THEN 'a' END
+NULL
+
+> CASE WHEN NULL THEN 'a' ELSE 'b' END
+'b'
+
+> CASE WHEN 1 THEN 'a' WHEN 1 THEN 'b' END
+'a'
+
+> CASE WHEN true THEN 1 + 1 END + 1
+3
+
+-- test: simple case
+> CASE 2 WHEN 1 THEN 'one' WHEN 2 THEN 'two' ELSE 'other' END
+'two'
+
+> CASE 2.0 WHEN 1 THEN 'one' WHEN 2 THEN 'two' END
+'two'
+
+> CASE 3 WHEN 1 THEN 'one' WHEN 2 THEN 'two' ELSE 'other' END
+'other'
+
+> CASE NULL WHEN NULL THEN 'null' ELSE 'other' END
+'other'
+
+> CASE 1 + 1 WHEN 1 + 1 THEN 'two' END
+'two'
+
+-- test: nested case
+> CASE WHEN true THEN CASE 1 WHEN 1 THEN 'a' END ELSE 'b' END
+'a'
+
+-- test: invalid case
+! CASE END
+'found END'
+
+! CASE 1 END
+'found END, expected WHEN'
+
+! CASE WHEN 1 END
+'found END, expected THEN'
+
+! CASE WHEN 1 THEN 2
+'found EOF, expected END'
+
+! CASE WHEN 1 THEN 2 ELSE 3
+'found EOF, expected END'
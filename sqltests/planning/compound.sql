@@ -0,0 +1,20 @@
+-- setup:
+CREATE TABLE foo(a INT PRIMARY KEY, b INT);
+CREATE TABLE bar(a INT PRIMARY KEY, b INT);
+CREATE INDEX ON bar(b);
+
+-- test: intersect
+EXPLAIN SELECT a FROM foo WHERE a = 1 INTERSECT SELECT a FROM bar WHERE b = 2;
+/* result:
+{
+    "plan": 'intersect(table.Scan("foo", [{"min": [1], "exact": true}]) | docs.Project(a), index.Scan("bar_b_idx", [{"min": [2], "exact": true}]) | docs.Project(a))'
+}
+*/
+
+-- test: except all
+EXPLAIN SELECT a FROM foo WHERE a > 1 EXCEPT ALL SELECT a FROM bar WHERE b = 2 ORDER BY a;
+/* result:
+{
+    "plan": 'exceptAll(table.Scan("foo", [{"min": [1], "exclusive": true}]) | docs.Project(a), index.Scan("bar_b_idx", [{"min": [2], "exact": true}]) | docs.Project(a)) | docs.TempTreeSort(a)'
+}
+*/
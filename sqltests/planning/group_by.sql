@@ -0,0 +1,43 @@
+-- setup:
+CREATE TABLE sales(id INT PRIMARY KEY, region TEXT, product TEXT, qty INT);
+CREATE INDEX sales_region ON sales(region);
+
+-- test: single expression
+EXPLAIN SELECT region, COUNT(*) FROM sales GROUP BY region;
+/* result:
+{
+    plan: "index.Scan(\"sales_region\") | docs.GroupAggregate(region, COUNT(*)) | docs.Project(region, COUNT(*))"
+}
+*/
+
+-- test: multiple expressions
+EXPLAIN SELECT region, product, COUNT(*) FROM sales GROUP BY region, product;
+/* result:
+{
+    plan: "table.Scan(\"sales\") | docs.TempTreeSort(region, product) | docs.GroupAggregate([region, product], COUNT(*)) | docs.Project(region, product, COUNT(*))"
+}
+*/
+
+-- test: having is not used to select an index
+EXPLAIN SELECT region, COUNT(*) FROM sales WHERE qty > 1 GROUP BY region, product HAVING region = 'eu';
+/* result:
+{
+    plan: "table.Scan(\"sales\") | docs.Filter(qty > 1) | docs.TempTreeSort(region, product) | docs.GroupAggregate([region, product], COUNT(*)) | docs.Filter(region = \"eu\") | docs.Project(region, COUNT(*))"
+}
+*/
+
+-- test: rollup
+EXPLAIN SELECT region, product, SUM(qty) FROM sales GROUP BY ROLLUP(region, product) ORDER BY region;
+/* result:
+{
+    plan: "table.Scan(\"sales\") | docs.GroupingSets((region, product), (region), (), SUM(qty)) | docs.Project(region, product, SUM(qty)) | docs.TempTreeSort(region)"
+}
+*/
+
+-- test: having without group by
+EXPLAIN SELECT COUNT(*) FROM sales WHERE region = 'eu' HAVING COUNT(*) > 1;
+/* result:
+{
+    plan: "index.Scan(\"sales_region\", [{\"min\": [\"eu\"], \"exact\": true}]) | docs.GroupAggregate(NULL, COUNT(*)) | docs.Filter(COUNT(*) > 1) | docs.Project(COUNT(*))"
+}
+*/
@@ -0,0 +1,77 @@
+-- setup:
+CREATE TABLE a(id INT PRIMARY KEY, x INT);
+CREATE TABLE b(id INT PRIMARY KEY, aid INT, y TEXT);
+CREATE INDEX b_aid ON b(aid);
+CREATE TABLE c(id INT PRIMARY KEY, z INT);
+
+-- test: nested loop without index
+EXPLAIN SELECT * FROM a JOIN c ON a.x = c.z;
+/* result:
+{
+    plan: "table.Scan(\"a\") | docs.Alias(a) | join.NestedLoop(table.Scan(\"c\") | docs.Alias(c) | docs.Filter(a.x = c.z))"
+}
+*/
+
+-- test: index lookup using the outer document
+EXPLAIN SELECT * FROM a JOIN b ON a.id = b.aid;
+/* result:
+{
+    plan: "table.Scan(\"a\") | docs.Alias(a) | join.NestedLoop(index.Scan(\"b_aid\", [{\"min\": [a.id], \"exact\": true}]) | docs.Alias(b) | docs.Filter(a.id = b.aid))"
+}
+*/
+
+-- test: primary key lookup using the outer document
+EXPLAIN SELECT * FROM b JOIN a ON a.id = b.aid;
+/* result:
+{
+    plan: "table.Scan(\"b\") | docs.Alias(b) | join.NestedLoop(table.Scan(\"a\", [{\"min\": [b.aid], \"exact\": true}]) | docs.Alias(a) | docs.Filter(a.id = b.aid))"
+}
+*/
+
+-- test: where pushed down
+EXPLAIN SELECT * FROM a JOIN b ON a.id = b.aid WHERE b.y = 'foo' AND a.id < 3;
+/* result:
+{
+    plan: "table.Scan(\"a\", [{\"max\": [3], \"exclusive\": true}]) | docs.Alias(a) | join.NestedLoop(index.Scan(\"b_aid\", [{\"min\": [a.id], \"exact\": true}]) | docs.Alias(b) | docs.Filter(a.id = b.aid) | docs.Filter(b.y = \"foo\"))"
+}
+*/
+
+-- test: on conditions on the left table are kept
+EXPLAIN SELECT * FROM a JOIN b ON a.id = b.aid AND a.x > 1;
+/* result:
+{
+    plan: "table.Scan(\"a\") | docs.Alias(a) | join.NestedLoop(index.Scan(\"b_aid\", [{\"min\": [a.id], \"exact\": true}]) | docs.Alias(b) | docs.Filter(a.id = b.aid), a.x > 1)"
+}
+*/
+
+-- test: left join where not pushed down
+EXPLAIN SELECT * FROM a LEFT JOIN b ON a.id = b.aid WHERE b.y = 'foo';
+/* result:
+{
+    plan: "table.Scan(\"a\") | docs.Alias(a) | join.LeftNestedLoop(index.Scan(\"b_aid\", [{\"min\": [a.id], \"exact\": true}]) | docs.Alias(b) | docs.Filter(a.id = b.aid)) | docs.Filter(b.y = \"foo\")"
+}
+*/
+
+-- test: cross join
+EXPLAIN SELECT * FROM a, c WHERE c.z > 10;
+/* result:
+{
+    plan: "table.Scan(\"a\") | docs.Alias(a) | join.NestedLoop(table.Scan(\"c\") | docs.Alias(c) | docs.Filter(c.z > 10))"
+}
+*/
+
+-- test: unqualified paths are not pushed down
+EXPLAIN SELECT * FROM a JOIN b ON a.id = b.aid WHERE y = 'foo';
+/* result:
+{
+    plan: "table.Scan(\"a\") | docs.Alias(a) | join.NestedLoop(index.Scan(\"b_aid\", [{\"min\": [a.id], \"exact\": true}]) | docs.Alias(b) | docs.Filter(a.id = b.aid)) | docs.Filter(y = \"foo\")"
+}
+*/
+
+-- test: three tables
+EXPLAIN SELECT * FROM a JOIN b ON a.id = b.aid JOIN c ON c.id = b.id;
+/* result:
+{
+    plan: "table.Scan(\"a\") | docs.Alias(a) | join.NestedLoop(index.Scan(\"b_aid\", [{\"min\": [a.id], \"exact\": true}]) | docs.Alias(b) | docs.Filter(a.id = b.aid)) | join.NestedLoop(table.Scan(\"c\", [{\"min\": [b.id], \"exact\": true}]) | docs.Alias(c) | docs.Filter(c.id = b.id))"
+}
+*/
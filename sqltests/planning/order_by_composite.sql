@@ -84,3 +84,83 @@ EXPLAIN SELECT * FROM test WHERE b = 10 ORDER BY a DESC;
     "plan": 'index.ScanReverse("test_a_b") | docs.Filter(b = 10)'
 }
 */
+
+-- test: composite order by, indexed prefix
+EXPLAIN SELECT * FROM test ORDER BY a, b;
+/* result:
+{
+    "plan": 'index.Scan("test_a_b")'
+}
+*/
+
+-- test: composite order by, indexed prefix, DESC
+EXPLAIN SELECT * FROM test ORDER BY a DESC, b DESC;
+/* result:
+{
+    "plan": 'index.ScanReverse("test_a_b")'
+}
+*/
+
+-- test: composite order by, mixed directions
+EXPLAIN SELECT * FROM test ORDER BY a DESC, b;
+/* result:
+{
+    "plan": 'table.Scan("test") | docs.TempTreeSort(a DESC, b)'
+}
+*/
+
+-- test: composite order by, nulls last
+EXPLAIN SELECT * FROM test ORDER BY a NULLS LAST, b;
+/* result:
+{
+    "plan": 'table.Scan("test") | docs.TempTreeSort(a NULLS LAST, b)'
+}
+*/
+
+-- test: composite order by, not a prefix
+EXPLAIN SELECT * FROM test ORDER BY a, c;
+/* result:
+{
+    "plan": 'table.Scan("test") | docs.TempTreeSort(a, c)'
+}
+*/
+
+-- test: composite order by, more keys than the index
+EXPLAIN SELECT * FROM test ORDER BY a, b, c;
+/* result:
+{
+    "plan": 'table.Scan("test") | docs.TempTreeSort(a, b, c)'
+}
+*/
+
+-- test: filtering and composite order by
+EXPLAIN SELECT * FROM test WHERE a > 1 ORDER BY a, b;
+/* result:
+{
+    "plan": 'index.Scan("test_a_b", [{"min": [1], "exclusive": true}])'
+}
+*/
+
+-- test: filtering with IN and sorting on the second path
+EXPLAIN SELECT * FROM test WHERE a IN (1, 2) ORDER BY b;
+/* result:
+{
+    "plan": 'table.Scan("test") | docs.Filter(a IN [1, 2]) | docs.TempTreeSort(b)'
+}
+*/
+
+-- test: group by composite index
+EXPLAIN SELECT a, b, COUNT(*) FROM test GROUP BY a, b;
+/* result:
+{
+    "plan": 'index.Scan("test_a_b") | docs.GroupAggregate([a, b], COUNT(*)) | docs.Project(a, b, COUNT(*))'
+}
+*/
+
+-- test: group by composite index, order by prefix
+EXPLAIN SELECT a, b, COUNT(*) FROM test GROUP BY a, b ORDER BY a DESC;
+/* result:
+{
+    "plan": 'table.Scan("test") | docs.TempTreeSort(a DESC, b) | docs.GroupAggregate([a, b], COUNT(*)) | docs.Project(a, b, COUNT(*))'
+}
+*/
@@ -24,3 +24,44 @@ EXPLAIN SELECT * FROM test WHERE 4 * 3 BETWEEN 3 + 6 AND 5 * 10;
     plan: "table.Scan(\"test\")"
 }
 */
+
+-- test: precalculate constant CASE
+EXPLAIN SELECT * FROM test WHERE a > CASE WHEN 1 > 2 THEN 10 ELSE 20 END;
+/* result:
+{
+    plan: "table.Scan(\"test\") | docs.Filter(a > 20)"
+}
+*/
+
+-- test: precalculate CASE with path
+EXPLAIN SELECT * FROM test WHERE CASE a WHEN 1 + 1 THEN true END;
+/* result:
+{
+    plan: "table.Scan(\"test\") | docs.Filter(CASE a WHEN 2 THEN true END)"
+}
+*/
+
+-- test: precalculate conditional functions
+EXPLAIN SELECT * FROM test WHERE a = COALESCE(NULL, 1 + 1) AND b < GREATEST(1, 3);
+/* result:
+{
+    plan: "table.Scan(\"test\") | docs.Filter(a = 2) | docs.Filter(b < 3)"
+}
+*/
+
+-- test: precalculate conditional functions with path
+EXPLAIN SELECT * FROM test WHERE a = IFNULL(b, 1 + 1);
+/* result:
+{
+    plan: "table.Scan(\"test\") | docs.Filter(a = IFNULL(b, 2))"
+}
+*/
+
+-- test: constant CASE used to select an index
+CREATE INDEX ON test(a);
+EXPLAIN SELECT * FROM test WHERE a = CASE WHEN true THEN 1 END;
+/* result:
+{
+    plan: 'index.Scan("test_a_idx", [{"min": [1], "exact": true}])'
+}
+*/
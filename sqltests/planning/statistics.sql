@@ -0,0 +1,101 @@
+-- setup:
+CREATE TABLE test(a int, status bool, c int);
+
+CREATE UNIQUE INDEX test_a ON test(a);
+
+CREATE INDEX test_status ON test(status);
+
+CREATE INDEX test_c ON test(c);
+
+INSERT INTO
+    test (a, status, c)
+VALUES
+    (1, true, 1),
+    (2, true, 1),
+    (3, true, 1),
+    (4, true, 1),
+    (5, true, 1),
+    (6, true, 1),
+    (7, true, 1),
+    (8, true, 1),
+    (9, true, 1),
+    (10, true, 1),
+    (11, true, 1),
+    (12, true, 1),
+    (13, true, 1),
+    (14, true, 1),
+    (15, true, 1),
+    (16, true, 1),
+    (17, true, 1),
+    (18, true, 2),
+    (19, false, 3),
+    (20, false, 4);
+
+ANALYZE test;
+
+-- test: low selectivity
+EXPLAIN SELECT * FROM test WHERE status = true;
+/* result:
+{
+    "plan": 'table.Scan("test") | docs.Filter(status = true)'
+}
+*/
+
+-- test: high selectivity
+EXPLAIN SELECT * FROM test WHERE status = false;
+/* result:
+{
+    "plan": 'index.Scan("test_status", [{"min": [false], "exact": true}])'
+}
+*/
+
+-- test: cheapest index
+EXPLAIN SELECT * FROM test WHERE status = true AND c = 3;
+/* result:
+{
+    "plan": 'index.Scan("test_c", [{"min": [3], "exact": true}]) | docs.Filter(status = true)'
+}
+*/
+
+-- test: unique index
+EXPLAIN SELECT * FROM test WHERE a = 10 AND c = 1;
+/* result:
+{
+    "plan": 'index.Scan("test_a", [{"min": [10], "exact": true}]) | docs.Filter(c = 1)'
+}
+*/
+
+-- test: large range
+EXPLAIN SELECT * FROM test WHERE a > 5;
+/* result:
+{
+    "plan": 'table.Scan("test") | docs.Filter(a > 5)'
+}
+*/
+
+-- test: small range
+EXPLAIN SELECT * FROM test WHERE a > 18;
+/* result:
+{
+    "plan": 'index.Scan("test_a", [{"min": [18], "exclusive": true}])'
+}
+*/
+
+-- test: order by
+EXPLAIN SELECT * FROM test WHERE status = true ORDER BY a;
+/* result:
+{
+    "plan": 'index.Scan("test_a") | docs.Filter(status = true)'
+}
+*/
+
+-- test: results
+SELECT a FROM test WHERE status = false;
+/* result:
+{
+    "a": 19
+}
+{
+    "a": 20
+}
+*/
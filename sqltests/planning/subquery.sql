@@ -0,0 +1,53 @@
+-- setup:
+CREATE TABLE a(id INT PRIMARY KEY, x INT, y INT);
+CREATE TABLE b(id INT PRIMARY KEY, aid INT);
+CREATE INDEX a_xy ON a(x, y);
+CREATE INDEX b_aid ON b(aid);
+
+-- test: in using the primary key
+EXPLAIN SELECT * FROM a WHERE id IN (SELECT aid FROM b);
+/* result:
+{
+    plan: "table.Scan(\"a\", [{\"min\": [(SELECT aid FROM b)], \"exact\": true, \"in\": true}])"
+}
+*/
+
+-- test: in using an index
+EXPLAIN SELECT * FROM a WHERE x IN (SELECT aid FROM b) AND y = 1;
+/* result:
+{
+    plan: "index.Scan(\"a_xy\", [{\"min\": [(SELECT aid FROM b)], \"exact\": true, \"in\": true}]) | docs.Filter(y = 1)"
+}
+*/
+
+-- test: in using a composite index
+EXPLAIN SELECT * FROM a WHERE x = 1 AND y IN (SELECT aid FROM b);
+/* result:
+{
+    plan: "index.Scan(\"a_xy\", [{\"min\": [1, (SELECT aid FROM b)], \"exact\": true, \"in\": true}])"
+}
+*/
+
+-- test: correlated exists
+EXPLAIN SELECT * FROM a WHERE EXISTS (SELECT 1 FROM b WHERE b.aid = a.id);
+/* result:
+{
+    plan: "table.Scan(\"a\") | docs.Alias(a) | docs.Filter(EXISTS (SELECT 1 FROM b WHERE b.aid = a.id))"
+}
+*/
+
+-- test: correlated in is not used to select an index
+EXPLAIN SELECT * FROM a WHERE id IN (SELECT aid FROM b WHERE b.id = a.x);
+/* result:
+{
+    plan: "table.Scan(\"a\") | docs.Alias(a) | docs.Filter(id IN (SELECT aid FROM b WHERE b.id = a.x))"
+}
+*/
+
+-- test: scalar subquery
+EXPLAIN SELECT * FROM a WHERE x = (SELECT MAX(aid) FROM b);
+/* result:
+{
+    plan: "index.Scan(\"a_xy\", [{\"min\": [(SELECT MAX(aid) FROM b)], \"exact\": true}]) | docs.Filter(x = (SELECT MAX(aid) FROM b))"
+}
+*/
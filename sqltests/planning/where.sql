@@ -54,6 +54,14 @@ EXPLAIN SELECT * FROM test WHERE a < 10 AND b > 5;
  }
 */
 
+-- test: path on the right
+EXPLAIN SELECT * FROM test WHERE 10 < a AND b > 5;
+/* result:
+ {
+    "plan": 'index.Scan("test_a", [{"min": [10], "exclusive": true}]) | docs.Filter(b > 5)'
+ }
+*/
+
 -- test: BETWEEN
 EXPLAIN SELECT * FROM test WHERE a BETWEEN 4 AND 5 AND b > 5;
 /* result:
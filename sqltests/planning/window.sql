@@ -0,0 +1,27 @@
+-- setup:
+CREATE TABLE ev(id INT PRIMARY KEY, device TEXT, ts INT, v INT);
+CREATE INDEX ev_ts ON ev(ts);
+
+-- test: window
+EXPLAIN SELECT id, ROW_NUMBER() OVER (PARTITION BY device ORDER BY ts DESC) FROM ev WHERE v > 1;
+/* result:
+{
+    plan: "table.Scan(\"ev\") | docs.Filter(v > 1) | docs.Window(ROW_NUMBER() OVER (PARTITION BY device ORDER BY ts DESC)) | docs.Project(id, ROW_NUMBER() OVER (PARTITION BY device ORDER BY ts DESC))"
+}
+*/
+
+-- test: same window used twice
+EXPLAIN SELECT RANK() OVER (ORDER BY v), RANK() OVER (ORDER BY v) AS r FROM ev;
+/* result:
+{
+    plan: "table.Scan(\"ev\") | docs.Window(RANK() OVER (ORDER BY v)) | docs.Project(RANK() OVER (ORDER BY v), RANK() OVER (ORDER BY v))"
+}
+*/
+
+-- test: order by is not replaced by an index
+EXPLAIN SELECT id, LAG(ts) OVER (ORDER BY id) FROM ev ORDER BY ts;
+/* result:
+{
+    plan: "table.Scan(\"ev\") | docs.Window(LAG(ts) OVER (ORDER BY id)) | docs.Project(id, LAG(ts) OVER (ORDER BY id)) | docs.TempTreeSort(ts)"
+}
+*/
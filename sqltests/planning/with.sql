@@ -0,0 +1,64 @@
+-- setup:
+CREATE TABLE emp(id INT PRIMARY KEY, name TEXT, manager_id INT);
+CREATE INDEX emp_manager ON emp(manager_id);
+
+-- test: read once
+EXPLAIN WITH t AS (SELECT id, name FROM emp WHERE manager_id = 1) SELECT * FROM t WHERE id > 2;
+/* result:
+{
+    plan: "index.Scan(\"emp_manager\", [{\"min\": [1], \"exact\": true}]) | docs.Filter(id > 2) | docs.Project(id, name)"
+}
+*/
+
+-- test: read once using the primary key
+EXPLAIN WITH t AS (SELECT * FROM emp) SELECT * FROM t WHERE id = 1;
+/* result:
+{
+    plan: "table.Scan(\"emp\", [{\"min\": [1], \"exact\": true}])"
+}
+*/
+
+-- test: read once using a renamed field
+EXPLAIN WITH t(i, m) AS (SELECT id, manager_id FROM emp) SELECT i FROM t WHERE m = 2;
+/* result:
+{
+    plan: "index.Scan(\"emp_manager\", [{\"min\": [2], \"exact\": true}]) | docs.Project(id, manager_id) | docs.Project(i)"
+}
+*/
+
+-- test: read once using a field not selected
+EXPLAIN WITH t AS (SELECT id FROM emp) SELECT * FROM t WHERE manager_id = 1;
+/* result:
+{
+    plan: "cte.With(t AS (table.Scan(\"emp\") | docs.Project(id)), cte.Scan(t) | docs.Filter(manager_id = 1))"
+}
+*/
+
+-- test: read twice
+EXPLAIN WITH t AS (SELECT id, manager_id FROM emp) SELECT * FROM t JOIN t AS u ON t.manager_id = u.id;
+/* result:
+{
+    plan: "cte.With(t AS MATERIALIZED (table.Scan(\"emp\") | docs.Project(id, manager_id)), cte.Scan(t) | docs.Alias(t) | join.NestedLoop(cte.Scan(t) | docs.Alias(u) | docs.Filter(t.manager_id = u.id)))"
+}
+*/
+
+-- test: not read
+EXPLAIN WITH t AS (SELECT id FROM emp) SELECT * FROM emp;
+/* result:
+{
+    plan: "cte.With(t AS (table.Scan(\"emp\") | docs.Project(id)), table.Scan(\"emp\"))"
+}
+*/
+
+-- test: recursive
+EXPLAIN WITH RECURSIVE sub AS (
+    SELECT id, name FROM emp WHERE id = 2
+    UNION ALL
+    SELECT emp.id, emp.name FROM sub JOIN emp ON emp.manager_id = sub.id
+)
+SELECT * FROM sub;
+/* result:
+{
+    plan: "cte.With(sub AS (table.Scan(\"emp\", [{\"min\": [2], \"exact\": true}]) | docs.Project(id, name) UNION ALL cte.Scan(sub) | docs.Alias(sub) | join.NestedLoop(index.Scan(\"emp_manager\", [{\"min\": [sub.id], \"exact\": true}]) | docs.Alias(emp) | docs.Filter(emp.manager_id = sub.id)) | docs.Project(emp.id, emp.name)), cte.Scan(sub))"
+}
+*/
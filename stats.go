@@ -0,0 +1,132 @@
+package genji
+
+// Stats describes the state of the database since it was opened.
+type Stats struct {
+	// Metrics of the storage engine.
+	Engine EngineStats
+
+	// Number of read/write transactions started, committed and rolled back.
+	TransactionsStarted    int64
+	TransactionsCommitted  int64
+	TransactionsRolledBack int64
+	// Number of read-only transactions started.
+	ReadOnlyTransactions int64
+
+	// Number of statements executed successfully, by type: SELECT, INSERT, CREATE, etc.
+	Statements map[string]int64
+
+	// Usage of the temporary stores used to sort documents
+	// and to hold intermediate results.
+	TempStores TempStoreStats
+
+	// Statistics of every table, sorted by name.
+	Tables []TableStats
+}
+
+// EngineStats describes the state of the storage engine.
+// In-memory databases only report MemTableSize.
+type EngineStats struct {
+	// Space used on disk, in bytes.
+	DiskUsage uint64
+	// Size of the data held in memory, in bytes.
+	MemTableSize uint64
+	// Size of the write-ahead log, in bytes.
+	WALSize uint64
+	// Size of the block cache, in bytes.
+	CacheSize int64
+	// Number of block cache lookups that found or missed a block.
+	CacheHits   int64
+	CacheMisses int64
+	// Number of memtable flushes and compactions.
+	Flushes     int64
+	Compactions int64
+	// Estimated number of bytes compactions still need to rewrite.
+	CompactionDebt uint64
+	// Number of files that might be read to find a key.
+	ReadAmplification int
+}
+
+// CacheHitRate returns the ratio of block cache lookups that found a block.
+func (s EngineStats) CacheHitRate() float64 {
+	if s.CacheHits+s.CacheMisses == 0 {
+		return 0
+	}
+
+	return float64(s.CacheHits) / float64(s.CacheHits+s.CacheMisses)
+}
+
+// TempStoreStats describes the usage of temporary stores.
+type TempStoreStats struct {
+	// Number of stores created since the database was opened.
+	Created int64
+	// Number of stores currently in use.
+	InUse int64
+	// Number of stores kept for reuse.
+	Pooled int64
+}
+
+// TableStats describes the usage of a table.
+type TableStats struct {
+	TableName string
+	// Number of documents read and written since the database was opened.
+	DocumentsRead    int64
+	DocumentsWritten int64
+	// Estimated size of the documents on disk, in bytes.
+	// Data that was not flushed from memory yet is not taken into account.
+	Size uint64
+	// Statistics of the indexes of the table, sorted by name.
+	Indexes []IndexStats
+}
+
+// TotalSize returns the estimated size of the table and of its indexes.
+func (t TableStats) TotalSize() uint64 {
+	size := t.Size
+	for _, idx := range t.Indexes {
+		size += idx.Size
+	}
+
+	return size
+}
+
+// IndexStats describes the usage of an index.
+type IndexStats struct {
+	IndexName string
+	// Estimated size of the index on disk, in bytes.
+	Size uint64
+}
+
+// Stats returns the metrics of the storage engine, the counters of the database
+// and the estimated size of every table and index.
+func (db *DB) Stats() (*Stats, error) {
+	ds, err := db.DB.Stats()
+	if err != nil {
+		return nil, err
+	}
+
+	s := Stats{
+		Engine:                 EngineStats(ds.Engine),
+		TransactionsStarted:    ds.TxStarted,
+		TransactionsCommitted:  ds.TxCommitted,
+		TransactionsRolledBack: ds.TxRolledBack,
+		ReadOnlyTransactions:   ds.TxReadOnly,
+		Statements:             ds.Statements,
+		TempStores:             TempStoreStats(ds.TransientStores),
+		Tables:                 make([]TableStats, 0, len(ds.Tables)),
+	}
+
+	for _, t := range ds.Tables {
+		ts := TableStats{
+			TableName:        t.TableName,
+			DocumentsRead:    t.DocumentsRead,
+			DocumentsWritten: t.DocumentsWritten,
+			Size:             t.Size,
+		}
+		for _, idx := range t.Indexes {
+			ts.Indexes = append(ts.Indexes, IndexStats(idx))
+		}
+
+		s.Tables = append(s.Tables, ts)
+	}
+
+	return &s, nil
+}
@@ -0,0 +1,137 @@
+package genji
+
+import (
+	"context"
+
+	"github.com/genjidb/genji/document"
+	"github.com/genjidb/genji/internal/database"
+	"github.com/genjidb/genji/types"
+)
+
+// ChangeType describes how a document was modified.
+type ChangeType int
+
+// Types of changes.
+const (
+	ChangeInsert ChangeType = iota + 1
+	ChangeUpdate
+	ChangeDelete
+)
+
+func (c ChangeType) String() string {
+	switch c {
+	case ChangeInsert:
+		return "insert"
+	case ChangeUpdate:
+		return "update"
+	case ChangeDelete:
+		return "delete"
+	}
+
+	return "unknown"
+}
+
+// A ChangeEvent describes a change made to a document by a committed transaction.
+type ChangeEvent struct {
+	// Position of the event. Positions are strictly increasing and
+	// follow the commit order of transactions. They are never reused,
+	// even after the database is reopened.
+	// It can be passed to SubscribeFrom to resume a subscription.
+	Position  uint64
+	Type      ChangeType
+	TableName string
+	// Primary key of the document.
+	PrimaryKey []types.Value
+	// Document before the change. Nil if the document was inserted.
+	Old types.Document
+	// Document after the change. Nil if the document was deleted.
+	New types.Document
+	// Operations needed to transform Old into New.
+	Ops []document.Op
+}
+
+// A Subscription receives the changes made by committed transactions.
+type Subscription struct {
+	ctx context.Context
+	sub *database.Subscriber
+}
+
+// Subscribe returns a subscription receiving the changes made to the given tables
+// by every transaction committed after the call. If no tables are provided,
+// changes made to all tables are received.
+// Changes are only captured once the first subscription is created: the first call to Subscribe
+// waits for running read/write transactions to complete and must not be called
+// while the caller holds a read/write transaction.
+// The subscription ends when ctx is canceled or when Close is called.
+func (db *DB) Subscribe(ctx context.Context, tables ...string) (*Subscription, error) {
+	sub, err := db.DB.Subscribe(nil, tables...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Subscription{ctx: ctx, sub: sub}, nil
+}
+
+// SubscribeFrom works like Subscribe but also receives the changes that follow the given position,
+// which is usually the position of the last event processed by a previous subscription.
+// Only a limited number of changes are kept in memory: if the changes that follow the position
+// are no longer available, or if the database was reopened since, it returns an error.
+func (db *DB) SubscribeFrom(ctx context.Context, position uint64, tables ...string) (*Subscription, error) {
+	sub, err := db.DB.Subscribe(&position, tables...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Subscription{ctx: ctx, sub: sub}, nil
+}
+
+// Next blocks until the next event is available.
+// If the subscription context is canceled, it returns the context error.
+// Events are returned in commit order.
+// A subscription that doesn't receive its events fast enough is dropped: once the events
+// received before are consumed, Next returns an error and the subscription must be resumed
+// using SubscribeFrom.
+func (s *Subscription) Next() (*ChangeEvent, error) {
+	c, err := s.sub.Next(s.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pk, err := c.Key.Decode()
+	if err != nil {
+		return nil, err
+	}
+
+	ev := ChangeEvent{
+		Position:   c.Position,
+		TableName:  c.TableName,
+		PrimaryKey: pk,
+		Old:        c.Old,
+		New:        c.New,
+	}
+
+	old, new := c.Old, c.New
+	switch {
+	case old == nil:
+		ev.Type = ChangeInsert
+		old = document.NewFieldBuffer()
+	case new == nil:
+		ev.Type = ChangeDelete
+		new = document.NewFieldBuffer()
+	default:
+		ev.Type = ChangeUpdate
+	}
+
+	ev.Ops, err = document.Diff(old, new)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ev, nil
+}
+
+// Close the subscription.
+func (s *Subscription) Close() error {
+	s.sub.Close()
+	return nil
+}
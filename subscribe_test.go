@@ -0,0 +1,199 @@
+package genji_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/genjidb/genji"
+	"github.com/genjidb/genji/document"
+	"github.com/genjidb/genji/internal/database"
+	"github.com/genjidb/genji/internal/testutil"
+	"github.com/genjidb/genji/internal/testutil/assert"
+	"github.com/genjidb/genji/types"
+	"github.com/stretchr/testify/require"
+)
+
+func nextEvent(t *testing.T, sub *genji.Subscription) *genji.ChangeEvent {
+	t.Helper()
+
+	ev, err := sub.Next()
+	assert.NoError(t, err)
+	return ev
+}
+
+func TestSubscribe(t *testing.T) {
+	db, err := genji.Open(":memory:")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	err = db.Exec(`
+		CREATE TABLE foo(a INT PRIMARY KEY, b INT);
+		CREATE TABLE bar(a INT PRIMARY KEY);
+		INSERT INTO foo (a, b) VALUES (1, 1);
+	`)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sub, err := db.Subscribe(ctx, "foo")
+	assert.NoError(t, err)
+	defer sub.Close()
+
+	err = db.Exec(`
+		INSERT INTO foo (a, b) VALUES (2, 2);
+		INSERT INTO bar (a) VALUES (1);
+		UPDATE foo SET b = 10 WHERE a = 1;
+		DELETE FROM foo WHERE a = 2;
+	`)
+	assert.NoError(t, err)
+
+	// rolled back changes are not published
+	tx, err := db.Begin(true)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Exec("INSERT INTO foo (a, b) VALUES (3, 3)"))
+	assert.NoError(t, tx.Rollback())
+
+	// changes undone by a savepoint are not published
+	tx, err = db.Begin(true)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Exec("INSERT INTO foo (a, b) VALUES (4, 4)"))
+	assert.NoError(t, tx.Savepoint("sp"))
+	assert.NoError(t, tx.Exec("INSERT INTO foo (a, b) VALUES (5, 5)"))
+	assert.NoError(t, tx.RollbackTo("sp"))
+	assert.NoError(t, tx.Commit())
+
+	ev := nextEvent(t, sub)
+	require.Equal(t, genji.ChangeInsert, ev.Type)
+	require.Equal(t, "foo", ev.TableName)
+	require.Equal(t, []types.Value{types.NewIntegerValue(2)}, ev.PrimaryKey)
+	require.Nil(t, ev.Old)
+	testutil.RequireDocJSONEq(t, ev.New, `{"a": 2, "b": 2}`)
+	require.Len(t, ev.Ops, 2)
+	first := ev.Position
+
+	ev = nextEvent(t, sub)
+	require.Equal(t, genji.ChangeUpdate, ev.Type)
+	testutil.RequireDocJSONEq(t, ev.Old, `{"a": 1, "b": 1}`)
+	testutil.RequireDocJSONEq(t, ev.New, `{"a": 1, "b": 10}`)
+	require.Len(t, ev.Ops, 1)
+	require.Equal(t, "set", ev.Ops[0].Type)
+	require.Equal(t, document.NewPath("b"), ev.Ops[0].Path)
+	require.Equal(t, int64(10), ev.Ops[0].Value.V())
+	require.Greater(t, ev.Position, first)
+
+	ev = nextEvent(t, sub)
+	require.Equal(t, genji.ChangeDelete, ev.Type)
+	testutil.RequireDocJSONEq(t, ev.Old, `{"a": 2, "b": 2}`)
+	require.Nil(t, ev.New)
+
+	ev = nextEvent(t, sub)
+	require.Equal(t, genji.ChangeInsert, ev.Type)
+	testutil.RequireDocJSONEq(t, ev.New, `{"a": 4, "b": 4}`)
+
+	// no more events
+	shortCtx, shortCancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer shortCancel()
+	sub2, err := db.SubscribeFrom(shortCtx, ev.Position)
+	assert.NoError(t, err)
+	defer sub2.Close()
+	_, err = sub2.Next()
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	t.Run("resume", func(t *testing.T) {
+		sub, err := db.SubscribeFrom(ctx, first)
+		assert.NoError(t, err)
+		defer sub.Close()
+
+		// all tables are received
+		ev := nextEvent(t, sub)
+		require.Equal(t, "bar", ev.TableName)
+		ev = nextEvent(t, sub)
+		require.Equal(t, genji.ChangeUpdate, ev.Type)
+	})
+
+	t.Run("unavailable position", func(t *testing.T) {
+		_, err := db.SubscribeFrom(ctx, 1000)
+		assert.Error(t, err)
+	})
+
+	t.Run("slow subscriber", func(t *testing.T) {
+		db.DB.ChangeFeed.SubscriberBufferSize = 2
+		defer func() { db.DB.ChangeFeed.SubscriberBufferSize = 10000 }()
+
+		sub, err := db.Subscribe(ctx, "bar")
+		assert.NoError(t, err)
+		defer sub.Close()
+
+		err = db.Exec("INSERT INTO bar (a) VALUES (10), (11), (12)")
+		assert.NoError(t, err)
+
+		// changes received before being dropped are returned
+		ev := nextEvent(t, sub)
+		require.Equal(t, []types.Value{types.NewIntegerValue(10)}, ev.PrimaryKey)
+		ev = nextEvent(t, sub)
+		require.Equal(t, []types.Value{types.NewIntegerValue(11)}, ev.PrimaryKey)
+
+		_, err = sub.Next()
+		assert.ErrorIs(t, err, database.ErrSubscriberTooSlow)
+
+		// the subscription can be resumed
+		sub2, err := db.SubscribeFrom(ctx, ev.Position, "bar")
+		assert.NoError(t, err)
+		defer sub2.Close()
+		ev = nextEvent(t, sub2)
+		require.Equal(t, []types.Value{types.NewIntegerValue(12)}, ev.PrimaryKey)
+	})
+}
+
+func TestSubscribePositionsAfterReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genji")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "pebble")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	run := func(q string) uint64 {
+		db, err := genji.Open(path)
+		assert.NoError(t, err)
+		defer db.Close()
+
+		sub, err := db.Subscribe(ctx)
+		assert.NoError(t, err)
+		defer sub.Close()
+
+		err = db.Exec(q)
+		assert.NoError(t, err)
+
+		return nextEvent(t, sub).Position
+	}
+
+	first := run("CREATE TABLE foo(a INT PRIMARY KEY); INSERT INTO foo (a) VALUES (1)")
+	// the position is persisted with the changes
+	second := run("INSERT INTO foo (a) VALUES (2)")
+	require.Equal(t, first+1, second)
+
+	db, err := genji.Open(path)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	// the history of a previous run is not available
+	_, err = db.SubscribeFrom(ctx, first)
+	assert.ErrorIs(t, err, database.ErrPositionUnavailable)
+	require.Contains(t, err.Error(), "no longer available")
+
+	// but subscriptions can be resumed from its last position
+	sub, err := db.SubscribeFrom(ctx, second)
+	assert.NoError(t, err)
+	defer sub.Close()
+
+	err = db.Exec("INSERT INTO foo (a) VALUES (3)")
+	assert.NoError(t, err)
+	require.Equal(t, second+1, nextEvent(t, sub).Position)
+}